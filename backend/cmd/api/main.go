@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
@@ -100,6 +101,7 @@ func main() {
 	router.Use(middleware.SecurityHeadersMiddleware())
 	router.Use(middleware.SecureErrorMiddleware())
 	router.Use(middleware.AuditLogMiddleware())
+	router.Use(middleware.BrandedDomainMiddleware())
 	
 	// Sentry middleware for error tracking
 	if os.Getenv("SENTRY_DSN") != "" {
@@ -128,6 +130,7 @@ func main() {
 	// Short click tracking URL: go.afftokapp.com/c/ABC123
 	clickHandlerEarly := handlers.NewClickHandler(db)
 	router.GET("/c/:id", middleware.BotDetectionMiddleware(), clickHandlerEarly.TrackClick)
+	botChallengeHandler := handlers.NewBotChallengeHandler()
 
 	authHandler := handlers.NewAuthHandler(db)
 	userHandler := handlers.NewUserHandler(db)
@@ -182,6 +185,24 @@ func main() {
 	clickHandler.SetGeoRuleService(geoRuleService)
 	postbackHandler.SetGeoRuleService(geoRuleService)
 
+	// Conversion anomaly detection (per promoter×offer baselines)
+	conversionAnomalyService := services.GetConversionAnomalyService(db)
+	postbackHandler.SetConversionAnomalyService(conversionAnomalyService)
+	adminFraudHandler.SetConversionAnomalyService(conversionAnomalyService)
+
+	// Fraud case management (threats, KYC triggers, anomaly holds, manual)
+	fraudCaseService := services.GetFraudCaseService(db)
+	fraudCaseService.AttachThreatDetector(services.GetThreatDetector())
+	adminFraudCasesHandler := handlers.NewAdminFraudCasesHandler(fraudCaseService)
+
+	// Landing-page beacons (verified visits, referrer checks)
+	landingBeaconService := services.GetLandingBeaconService(db)
+	landingBeaconService.StartSweeper()
+	landingBeaconHandler := handlers.NewLandingBeaconHandler(db)
+	clickHandler.SetLandingBeaconService(landingBeaconService)
+	clickHandlerEarly.SetLandingBeaconService(landingBeaconService)
+	userHandler.SetLandingBeaconService(landingBeaconService)
+
 	// Phase 8.4: Link Signing System
 	linkSigningService := services.NewLinkSigningService()
 	adminLinkSigningHandler := handlers.NewAdminLinkSigningHandler(linkSigningService)
@@ -202,6 +223,61 @@ func main() {
 	// Phase 8.6: Multi-Tenant System
 	middleware.InitTenantMiddleware(db)
 	adminTenantsHandler := handlers.NewAdminTenantsHandler(db)
+	tenantOnboardingHandler := handlers.NewTenantOnboardingHandler(db)
+	tenantUsageHandler := handlers.NewTenantUsageHandler(db)
+
+	// Usage metering: buffered daily counters + storage snapshots
+	usageService := services.GetUsageService(db)
+	usageService.Start()
+	defer usageService.Stop()
+
+	// Tenant billing: subscriptions, overage invoices and dunning
+	tenantBillingHandler := handlers.NewTenantBillingHandler(db)
+	billingService := services.GetBillingService(db)
+	billingService.StartJobs()
+	adminTenantsHandler.SetBillingService(billingService)
+	log.Printf("✅ Tenant billing started (provider: %s)", billingService.ProviderName())
+
+	// Tenant offboarding: data exports and scheduled hard deletes
+	tenantOffboardingHandler := handlers.NewTenantOffboardingHandler(db)
+	offboardingService := services.GetTenantOffboardingService(db)
+	offboardingService.Start()
+	defer offboardingService.Stop()
+
+	// Tenant SSO: per-tenant OIDC / SAML sign-in
+	ssoHandler := handlers.NewSSOHandler(db)
+	pageTemplateHandler := handlers.NewPageTemplateHandler(db)
+
+	// Earnings ledger (double entry)
+	ledgerHandler := handlers.NewLedgerHandler(db)
+	statementHandler := handlers.NewEarningsStatementHandler(db)
+	feeRuleHandler := handlers.NewFeeRuleHandler(db)
+	referralHandler := handlers.NewReferralHandler(db)
+
+	// Payouts (batch lifecycle, payout rails) and advertiser invoices
+	payoutHandler := handlers.NewPayoutHandler(db)
+	payoutService := services.NewPayoutService(db)
+	payoutService.StartRailPolling()
+	defer payoutService.Stop()
+	payoutHandler.SetPayoutService(payoutService)
+	invoiceHandler := handlers.NewInvoiceHandler(db)
+	// Invoice collections: overdue transitions, reminders, pausing offers of late payers
+	invoiceCollectionsService := services.GetInvoiceCollectionsService(db)
+	invoiceCollectionsService.Start()
+	defer invoiceCollectionsService.Stop()
+	walletHandler := handlers.NewAdvertiserWalletHandler(db)
+	// Prepaid wallets: settle conversion charges that were waiting for an FX rate
+	walletService := services.GetAdvertiserWalletService(db)
+	walletService.Start()
+	defer walletService.Stop()
+	log.Printf("✅ Payout rails ready: %v", payoutService.Rails().Status())
+
+	// Exchange rates: daily refresh from the configured source
+	fxHandler := handlers.NewFXHandler(db)
+	fxService := services.GetFXService(db)
+	fxService.StartDailyRefresh()
+	defer fxService.Stop()
+	log.Printf("✅ FX rates ready (source: %s)", fxService.SourceName())
 	
 	// Create default tenant if not exists
 	tenantService := services.NewTenantService(db)
@@ -211,6 +287,39 @@ func main() {
 		log.Println("✅ Default tenant created")
 	}
 
+	// Custom domains: DNS ownership re-checks and ACME certificates (ACME_ENABLED=true)
+	domainVerificationService := services.GetDomainVerificationService(db)
+	domainVerificationService.StartChecker()
+	var certificateService *services.CertificateService
+	if cfg.ACMEEnabled {
+		certificateService = services.NewCertificateService(db, domainVerificationService)
+		certificateService.StartRenewer()
+		adminTenantsHandler.SetCertificateService(certificateService)
+		router.GET("/.well-known/acme-challenge/:token", gin.WrapH(certificateService.HTTPHandler()))
+		log.Println("✅ Custom domain TLS (ACME) enabled")
+	}
+
+	// Add tenant_id to business tables and backfill existing rows into the default tenant
+	if os.Getenv("SKIP_MIGRATION") != "true" {
+		if err := database.MigrateTenantColumns(db); err != nil {
+			log.Printf("⚠️ Tenant column migration warning (non-fatal): %v", err)
+		}
+	}
+
+	// Postgres row-level security as a second isolation layer (TENANT_RLS_ENABLED=true)
+	if cfg.TenantRLSEnabled {
+		if os.Getenv("SKIP_MIGRATION") != "true" {
+			if err := database.MigrateTenantRLS(db); err != nil {
+				log.Printf("⚠️ Tenant RLS migration failed, RLS stays disabled: %v", err)
+			} else {
+				database.SetTenantRLSEnabled(true)
+				log.Println("✅ Tenant row-level security enabled")
+			}
+		} else {
+			database.SetTenantRLSEnabled(true)
+		}
+	}
+
 	// Phase 8.7: Edge CDN Layer
 	edgeIngestHandler := handlers.NewEdgeIngestHandler(db)
 	edgeIngestHandler.SetLinkService(linkService)
@@ -270,6 +379,7 @@ func main() {
 	router.GET("/health", observabilityHandler.GetHealth)
 
 	api := router.Group("/api")
+	api.Use(middleware.TenantResolverMiddleware())
 	{
 		auth := api.Group("/auth")
 		auth.Use(middleware.AuthRateLimitMiddleware())
@@ -278,6 +388,22 @@ func main() {
 			auth.POST("/login", authHandler.Login)
 			auth.POST("/refresh", authHandler.RefreshToken)
 			auth.POST("/logout", authHandler.Logout)
+
+			// Tenant SSO (OIDC / SAML)
+			auth.GET("/sso/:tenant", ssoHandler.GetSSOInfo)
+			auth.GET("/sso/:tenant/login", ssoHandler.BeginLogin)
+			auth.GET("/sso/:tenant/oidc/callback", ssoHandler.OIDCCallback)
+			auth.POST("/sso/:tenant/saml/acs", ssoHandler.SAMLACS)
+			auth.GET("/sso/:tenant/saml/metadata", ssoHandler.SAMLMetadata)
+			auth.POST("/sso-exchange", ssoHandler.ExchangeCode)
+		}
+
+		// Self-service tenant signup (public - no auth required)
+		signup := api.Group("/signup")
+		signup.Use(middleware.AuthRateLimitMiddleware())
+		{
+			signup.POST("", tenantOnboardingHandler.Signup)
+			signup.POST("/verify-email", tenantOnboardingHandler.VerifyEmail)
 		}
 
 		// Advertiser Registration (public - no auth required)
@@ -285,6 +411,8 @@ func main() {
 
 		// Click tracking with bot detection and rate limiting
 		api.GET("/c/:id", middleware.BotDetectionMiddleware(), clickHandler.TrackClick)
+		api.POST("/c/challenge", middleware.ClickRateLimitMiddleware(), botChallengeHandler.VerifyChallenge)
+		api.POST("/beacon", middleware.ClickRateLimitMiddleware(), landingBeaconHandler.RecordBeacon)
 		api.GET("/promoter/:id", promoterHandler.GetPromoterPage)
 		api.GET("/promoter/user/:username", promoterHandler.GetPromoterPageByUsername) // Public - landing page by username
 		api.GET("/r/:code", promoterHandler.GetPromoterPageByCode)                     // Public - landing page by unique code
@@ -302,11 +430,14 @@ func main() {
 		api.GET("/offers/:id", offerHandler.GetOffer)
 
 		protected := api.Group("")
-		protected.Use(middleware.AuthMiddleware())
+		protected.Use(middleware.AuthMiddleware(), middleware.TenantMembershipMiddleware(), middleware.TenantRLSMiddleware(db))
 		{
 			protected.GET("/auth/me", authHandler.GetMe)
 			protected.PUT("/profile", userHandler.UpdateProfile)
 			protected.PUT("/users/me/audience-countries", userHandler.UpdateAudienceCountries)
+			protected.PUT("/users/me/traffic-sources", userHandler.UpdateTrafficSources)
+			protected.PUT("/users/me/reporting-currency", userHandler.UpdateReportingCurrency)
+			protected.GET("/users/me/verified-visits", landingBeaconHandler.GetMyVerifiedVisits)
 
 			protected.GET("/users", userHandler.GetAllUsers)
 			protected.GET("/users/:id", userHandler.GetUser)
@@ -376,10 +507,21 @@ func main() {
 			kyc := protected.Group("/kyc")
 			{
 				kyc.GET("/status", kycSimpleHandler.GetMyKYCStatus) // حالة KYC الحالية
+				kyc.POST("/start", kycSimpleHandler.StartVerification) // بدء التحقق لدى المزود
 			}
 			
-			// Webhook من مزود التحقق الخارجي (SumSub/Veriff/Persona)
+			// Webhook من مزود التحقق الخارجي (SumSub/Veriff) - موقّع
 			api.POST("/kyc/webhook", kycSimpleHandler.ProviderWebhook)
+			api.POST("/kyc/webhook/:provider", kycSimpleHandler.ProviderWebhook)
+
+			// Payment provider webhook (signed, idempotent)
+			api.POST("/billing/webhook", tenantBillingHandler.Webhook)
+
+			// Webhook من قنوات الدفع (Payoneer/PayPal) - موقّع
+			api.POST("/payouts/webhook/:rail", payoutHandler.RailWebhook)
+
+			// Advertiser wallet top-ups from the payment provider (signed, idempotent)
+			api.POST("/wallet/webhook", walletHandler.TopUpWebhook)
 
 			// ========== Advertiser Routes ==========
 			advertiser := protected.Group("/advertiser")
@@ -399,6 +541,92 @@ func main() {
 			advertiser.POST("/geo-rules", adminGeoRulesHandler.CreateGeoRuleForAdvertiser)
 			advertiser.PUT("/geo-rules/:id", adminGeoRulesHandler.UpdateGeoRule)
 			advertiser.DELETE("/geo-rules/:id", adminGeoRulesHandler.DeleteGeoRule)
+
+			// Payouts & invoices
+			advertiser.GET("/payouts", payoutHandler.GetAdvertiserPayouts)
+			advertiser.PUT("/payoneer-email", payoutHandler.UpdateAdvertiserPayoneerEmail)
+			advertiser.GET("/invoices", invoiceHandler.GetMyInvoices)
+			advertiser.GET("/invoices/:id", invoiceHandler.GetInvoice)
+			advertiser.GET("/invoices/:id/pdf", invoiceHandler.DownloadInvoicePDF)
+			advertiser.POST("/invoices/:id/confirm-payment", invoiceHandler.ConfirmPayment)
+			advertiser.GET("/invoices/:id/receipt", invoiceHandler.DownloadReceipt)
+			advertiser.GET("/wallet", walletHandler.GetMyWallet)
+			}
+
+			// ========== Promoter Payouts ==========
+			protected.GET("/promoter/payouts", payoutHandler.GetPromoterPayouts)
+			protected.PUT("/promoter/payoneer-email", payoutHandler.UpdatePromoterPayoneerEmail)
+			protected.GET("/promoter/payout-methods", payoutHandler.GetMyPayoutMethods)
+			protected.POST("/promoter/payout-methods", payoutHandler.AddPayoutMethod)
+			protected.PUT("/promoter/payout-methods/:id/default", payoutHandler.SetDefaultPayoutMethod)
+			protected.DELETE("/promoter/payout-methods/:id", payoutHandler.DeletePayoutMethod)
+
+			// ========== Tenant Onboarding Wizard ==========
+			onboarding := protected.Group("/onboarding")
+			onboarding.Use(middleware.AdminMiddleware())
+			{
+				onboarding.GET("", tenantOnboardingHandler.GetOnboarding)
+				onboarding.POST("/steps/:step", tenantOnboardingHandler.CompleteStep)
+				onboarding.POST("/resend-verification", tenantOnboardingHandler.ResendVerification)
+			}
+
+			// ========== Tenant Usage & Plan Limits ==========
+			protected.GET("/usage", middleware.AdminMiddleware(), tenantUsageHandler.GetMyUsage)
+
+			// ========== Tenant Billing ==========
+			billing := protected.Group("/billing")
+			billing.Use(middleware.AdminMiddleware())
+			{
+				billing.GET("", tenantBillingHandler.GetBilling)
+				billing.GET("/invoices", tenantBillingHandler.GetInvoices)
+				billing.GET("/invoices/:id", tenantBillingHandler.GetInvoice)
+				billing.POST("/invoices/:id/pay", tenantBillingHandler.PayInvoice)
+				billing.POST("/subscription", tenantBillingHandler.ChangeSubscription)
+				billing.POST("/subscription/cancel", tenantBillingHandler.CancelSubscription)
+			}
+
+			// ========== Tenant SSO ==========
+			sso := protected.Group("/sso")
+			sso.Use(middleware.AdminMiddleware(), middleware.RequireFeature("sso"))
+			{
+				sso.GET("", ssoHandler.GetSSOConfig)
+				sso.PUT("", ssoHandler.UpdateSSOConfig)
+				sso.DELETE("", ssoHandler.DeleteSSOConfig)
+			}
+
+			// ========== Tenant Page Templates ==========
+			pageTemplates := protected.Group("/page-templates")
+			pageTemplates.Use(middleware.AdminMiddleware(), middleware.RequireFeature("custom_branding"))
+			{
+				pageTemplates.GET("", pageTemplateHandler.ListPageTemplates)
+				pageTemplates.GET("/:page/:locale", pageTemplateHandler.GetPageTemplate)
+				pageTemplates.PUT("/:page/:locale", pageTemplateHandler.UpdatePageTemplate)
+				pageTemplates.DELETE("/:page/:locale", pageTemplateHandler.DeletePageTemplate)
+				pageTemplates.POST("/:page/:locale/preview", pageTemplateHandler.PreviewPageTemplate)
+			}
+
+			// ========== Earnings Ledger ==========
+			protected.GET("/ledger/me", ledgerHandler.GetMyLedger)
+
+			// ========== Earnings Statements & Tax Profile ==========
+			protected.GET("/statements/monthly/:year/:month", statementHandler.GetMyMonthlyStatement)
+			protected.GET("/statements/annual/:year", statementHandler.GetMyYearEndSummary)
+			protected.GET("/tax-profile", statementHandler.GetMyTaxProfile)
+			protected.PUT("/tax-profile", statementHandler.SaveMyTaxProfile)
+
+			// ========== Referrals & Override Commissions ==========
+			protected.GET("/referrals/invite-link", inviteHandler.GetMyInviteLink)
+			protected.GET("/referrals/me", referralHandler.GetMyReferrals)
+			protected.GET("/referrals/overrides", referralHandler.GetMyOverrides)
+
+			// ========== Tenant Data Export ==========
+			exports := protected.Group("/exports")
+			exports.Use(middleware.AdminMiddleware())
+			{
+				exports.POST("", tenantOffboardingHandler.RequestExport)
+				exports.GET("", tenantOffboardingHandler.GetExports)
+				exports.GET("/:id", tenantOffboardingHandler.GetExport)
+				exports.GET("/:id/download", tenantOffboardingHandler.DownloadExport)
 			}
 
 			admin := protected.Group("/admin")
@@ -419,12 +647,86 @@ func main() {
 				admin.POST("/conversions/:id/approve", postbackHandler.ApproveConversion)
 				admin.POST("/conversions/:id/reject", postbackHandler.RejectConversion)
 
+				// Earnings Ledger (دفتر الأستاذ)
+				admin.GET("/ledger/accounts", ledgerHandler.GetLedgerAccounts)
+				admin.GET("/ledger/trial-balance", ledgerHandler.GetTrialBalance)
+				admin.GET("/ledger/transactions", ledgerHandler.GetLedgerTransactions)
+				admin.GET("/ledger/promoters/:id", ledgerHandler.GetPromoterLedger)
+				admin.POST("/ledger/adjustments", ledgerHandler.CreateLedgerAdjustment)
+				admin.POST("/ledger/backfill", ledgerHandler.BackfillLedger)
+				admin.GET("/ledger/reconcile", ledgerHandler.ReconcileLedger)
+
+				// Promoter statements and tax profiles
+				admin.GET("/promoters/:id/statements/monthly/:year/:month", statementHandler.GetPromoterMonthlyStatement)
+				admin.GET("/promoters/:id/statements/annual/:year", statementHandler.GetPromoterYearEndSummary)
+				admin.GET("/promoters/:id/tax-profile", statementHandler.GetPromoterTaxProfile)
+
+				// Platform fee rules (versioned)
+				admin.GET("/fee-rules", feeRuleHandler.ListFeeRules)
+				admin.PUT("/fee-rules", feeRuleHandler.SaveFeeRule)
+				admin.POST("/fee-rules/preview", feeRuleHandler.PreviewFee)
+				admin.DELETE("/fee-rules/:id", feeRuleHandler.RetireFeeRule)
+
+				// Referrals and override commissions
+				admin.GET("/referrals", referralHandler.ListReferrals)
+				admin.PUT("/referrals/:id/exclusion", referralHandler.SetReferralExcluded)
+				admin.GET("/promoters/:id/overrides", referralHandler.GetPromoterOverrides)
+
+				// Payout lifecycle: generate → review → approve → submit → reconcile
+				admin.GET("/payouts", payoutHandler.GetAllPayouts)
+				admin.GET("/payouts/summary", payoutHandler.GetPayoutsSummary)
+				admin.POST("/payouts/generate", payoutHandler.GeneratePayoutBatch)
+				admin.GET("/payouts/batches", payoutHandler.GetPayoutBatches)
+				admin.GET("/payouts/batches/:id", payoutHandler.GetPayoutBatch)
+				admin.GET("/payouts/batches/:id/export", payoutHandler.ExportPayoutBatchCSV)
+				admin.GET("/payouts/batches/:id/events", payoutHandler.GetPayoutBatchEvents)
+				admin.POST("/payouts/batches/:id/approve", payoutHandler.ApprovePayoutBatch)
+				admin.POST("/payouts/batches/:id/reopen", payoutHandler.ReopenPayoutBatch)
+				admin.POST("/payouts/batches/:id/cancel", payoutHandler.CancelPayoutBatch)
+				admin.POST("/payouts/batches/:id/submit", payoutHandler.SubmitPayoutBatch)
+				admin.POST("/payouts/batches/:id/reconcile", payoutHandler.ReconcilePayoutBatch)
+				admin.POST("/payouts/batches/:id/dispatch", payoutHandler.DispatchPayoutBatch)
+				admin.POST("/payouts/batches/:id/poll", payoutHandler.PollPayoutBatch)
+				admin.GET("/payouts/batches/:id/rails", payoutHandler.GetPayoutBatchRails)
+				admin.GET("/payouts/batches/:id/rails/:rail/file", payoutHandler.DownloadPayoutRailFile)
+				admin.GET("/payouts/rails", payoutHandler.GetPayoutRails)
+				admin.POST("/payouts/:id/hold", payoutHandler.HoldPayout)
+				admin.POST("/payouts/:id/release", payoutHandler.ReleasePayout)
+				admin.GET("/payouts/:id/events", payoutHandler.GetPayoutEvents)
+
+				// Advertiser invoices (platform fee)
+				admin.GET("/invoices", invoiceHandler.AdminGetAllInvoices)
+				admin.GET("/invoices/summary", invoiceHandler.AdminGetInvoiceSummary)
+				admin.POST("/invoices/generate", invoiceHandler.AdminGenerateMonthlyInvoices)
+				admin.GET("/invoices/adjustments", invoiceHandler.AdminListAdjustments)
+				admin.POST("/invoices/adjustments", invoiceHandler.AdminCreateAdjustment)
+				admin.GET("/invoices/tax-rules", invoiceHandler.AdminListTaxRules)
+				admin.PUT("/invoices/tax-rules", invoiceHandler.AdminSaveTaxRule)
+				admin.DELETE("/invoices/tax-rules/:id", invoiceHandler.AdminDeleteTaxRule)
+				admin.GET("/invoices/:id/pdf", invoiceHandler.AdminDownloadInvoicePDF)
+				admin.GET("/invoices/:id/receipt", invoiceHandler.AdminDownloadReceipt)
+				admin.POST("/invoices/:id/confirm", invoiceHandler.AdminConfirmPayment)
+				admin.POST("/invoices/:id/reject", invoiceHandler.AdminRejectPayment)
+
+				// Advertiser prepaid wallets
+				admin.GET("/wallets", walletHandler.ListWallets)
+				admin.GET("/advertisers/:id/wallet", walletHandler.GetAdvertiserWallet)
+				admin.PUT("/advertisers/:id/wallet", walletHandler.ConfigureWallet)
+				admin.POST("/advertisers/:id/wallet/top-ups", walletHandler.TopUpWallet)
+
+				// Exchange rates are platform-wide: imports are super admin only
+				admin.GET("/fx/rates", fxHandler.GetRates)
+				admin.GET("/fx/convert", fxHandler.Convert)
+				admin.POST("/fx/rates/import", middleware.SuperAdminMiddleware(), fxHandler.ImportRates)
+				admin.POST("/fx/rates/refresh", middleware.SuperAdminMiddleware(), fxHandler.RefreshRates)
+
 				// KYC Management (تلقائي)
 				admin.GET("/kyc/pending", kycSimpleHandler.AdminGetUsersRequiringKYC) // المستخدمين بانتظار التحقق
 				admin.GET("/kyc/:id", kycSimpleHandler.AdminGetUserKYC)                // حالة مستخدم محدد
 				admin.POST("/kyc/:id/reset", kycSimpleHandler.AdminResetKYC)           // إعادة تعيين حالة
 				admin.POST("/kyc/:id/verify", kycSimpleHandler.AdminManualVerify)      // تحقق يدوي (طوارئ)
 				admin.POST("/kyc/:id/require", kycSimpleHandler.AdminTriggerKYC)       // تفعيل KYC يدوياً
+				admin.POST("/kyc/:id/reset-attempts", kycSimpleHandler.AdminResetKYCAttempts) // إعادة محاولات التحقق
 
 				// Pending Offers Management (for advertiser submissions)
 				admin.GET("/offers/pending", advertiserHandler.GetPendingOffers)
@@ -443,329 +745,382 @@ func main() {
 			admin.PUT("/badges/:id", badgeHandler.UpdateBadge)
 			admin.DELETE("/badges/:id", badgeHandler.DeleteBadge)
 
+			// Platform operations act across tenants: super admins only. Tenant
+			// admins share the "admin" role and keep the tenant-scoped routes.
+			platform := admin.Group("")
+			platform.Use(middleware.SuperAdminMiddleware())
+
 			// ============================================
 			// PHASE 7: SYSTEM OBSERVABILITY API LAYER
 			// ============================================
 
 			// 1. System Dashboard endpoint
-			admin.GET("/dashboard", adminDashboardHandler.GetDashboard)
+			platform.GET("/dashboard", adminDashboardHandler.GetDashboard)
 
 			// 2. Metrics endpoints
-			admin.GET("/metrics", adminMetricsHandler.GetMetrics)
+			platform.GET("/metrics", adminMetricsHandler.GetMetrics)
 
 			// 3. Metrics Export endpoint
-			admin.GET("/metrics/export", adminMetricsHandler.ExportMetrics)
+			platform.GET("/metrics/export", adminMetricsHandler.ExportMetrics)
 
 			// 4. Health endpoints
-			admin.GET("/health", adminHealthHandler.GetHealth)
-			admin.GET("/connections", adminHealthHandler.GetConnections)
+			platform.GET("/health", adminHealthHandler.GetHealth)
+			platform.GET("/connections", adminHealthHandler.GetConnections)
 
 			// 5. Logs endpoints
-			admin.GET("/logs/recent", adminLogsHandler.GetRecentLogs)
-			admin.GET("/logs/errors", adminLogsHandler.GetErrorLogs)
-			admin.GET("/logs/fraud", adminLogsHandler.GetFraudLogs)
-			admin.GET("/logs/categories", adminLogsHandler.GetLogCategories)
-			admin.GET("/logs/category/:category", adminLogsHandler.GetLogsByCategory)
-			admin.GET("/logs/ip/:ip", adminLogsHandler.GetLogsByIP)
-			admin.GET("/logs/user/:user_id", adminLogsHandler.GetLogsByUser)
-
-			// 6. Fraud insights endpoint
+			platform.GET("/logs/recent", adminLogsHandler.GetRecentLogs)
+			platform.GET("/logs/errors", adminLogsHandler.GetErrorLogs)
+			platform.GET("/logs/fraud", adminLogsHandler.GetFraudLogs)
+			platform.GET("/logs/categories", adminLogsHandler.GetLogCategories)
+			platform.GET("/logs/category/:category", adminLogsHandler.GetLogsByCategory)
+			platform.GET("/logs/ip/:ip", adminLogsHandler.GetLogsByIP)
+			platform.GET("/logs/user/:user_id", adminLogsHandler.GetLogsByUser)
+
+			// 6. Fraud endpoints (insights are tenant-scoped for tenant admins)
 			admin.GET("/fraud/insights", adminFraudHandler.GetFraudInsights)
-			admin.POST("/fraud/block-ip", adminFraudHandler.BlockIP)
-			admin.POST("/fraud/unblock-ip", adminFraudHandler.UnblockIP)
-			admin.GET("/fraud/blocked-ips", adminFraudHandler.GetBlockedIPs)
+			platform.POST("/fraud/block-ip", adminFraudHandler.BlockIP)
+			platform.POST("/fraud/unblock-ip", adminFraudHandler.UnblockIP)
+			platform.GET("/fraud/blocked-ips", adminFraudHandler.GetBlockedIPs)
+			platform.GET("/fraud/challenges", botChallengeHandler.GetChallengeStats)
+			admin.GET("/fraud/anomalies", adminFraudHandler.GetConversionAnomalies)
+			admin.POST("/fraud/anomalies/:id/resolve", adminFraudHandler.ResolveConversionAnomaly)
+			admin.GET("/fraud/verified-visits/:id", landingBeaconHandler.GetPromoterVerifiedVisits)
+			admin.GET("/fraud/cases", adminFraudCasesHandler.ListCases)
+			admin.POST("/fraud/cases", adminFraudCasesHandler.CreateCase)
+			admin.GET("/fraud/cases/:id", adminFraudCasesHandler.GetCase)
+			admin.PUT("/fraud/cases/:id", adminFraudCasesHandler.UpdateCase)
+			admin.POST("/fraud/cases/:id/notes", adminFraudCasesHandler.AddNote)
+			admin.POST("/fraud/cases/:id/links", adminFraudCasesHandler.AddLinks)
+			admin.POST("/fraud/cases/:id/decision", adminFraudCasesHandler.DecideCase)
 
 			// 7. Diagnostics endpoints
-			admin.GET("/diagnostics/redis", adminDiagnosticsHandler.GetRedisDiagnostics)
-			admin.GET("/diagnostics/db", adminDiagnosticsHandler.GetDBDiagnostics)
-			admin.GET("/diagnostics/system", adminDiagnosticsHandler.GetSystemDiagnostics)
+			platform.GET("/diagnostics/redis", adminDiagnosticsHandler.GetRedisDiagnostics)
+			platform.GET("/diagnostics/db", adminDiagnosticsHandler.GetDBDiagnostics)
+			platform.GET("/diagnostics/system", adminDiagnosticsHandler.GetSystemDiagnostics)
 
 			// 8. Stress test endpoints
-			admin.GET("/stress/clicks", adminStressHandler.SimulateClicks)
-			admin.GET("/stress/postbacks", adminStressHandler.SimulatePostbacks)
-			admin.GET("/stress/full", adminStressHandler.RunFullStressTest)
-			admin.GET("/stress/pools", adminStressHandler.GetWorkerPoolStats)
-			admin.GET("/stress/cache", adminStressHandler.GetCacheStats)
+			platform.GET("/stress/clicks", adminStressHandler.SimulateClicks)
+			platform.GET("/stress/postbacks", adminStressHandler.SimulatePostbacks)
+			platform.GET("/stress/full", adminStressHandler.RunFullStressTest)
+			platform.GET("/stress/pools", adminStressHandler.GetWorkerPoolStats)
+			platform.GET("/stress/cache", adminStressHandler.GetCacheStats)
 
 			// ============================================
 			// PHASE 8.1: DATABASE HARDENING API LAYER
 			// ============================================
 
 			// 1. Backup/PITR Info
-			admin.GET("/db/backup-info", adminDBHandler.GetBackupInfo)
+			platform.GET("/db/backup-info", adminDBHandler.GetBackupInfo)
 
 			// 2. Vacuum/Analyze Plan
-			admin.GET("/db/vacuum-plan", adminDBHandler.GetVacuumPlan)
-			admin.GET("/db/stats", adminDBHandler.GetTableStats)
+			platform.GET("/db/vacuum-plan", adminDBHandler.GetVacuumPlan)
+			platform.GET("/db/stats", adminDBHandler.GetTableStats)
 
 			// 3. Index Profiling
-			admin.GET("/db/indexes", adminDBHandler.GetIndexes)
+			platform.GET("/db/indexes", adminDBHandler.GetIndexes)
 
 			// 4. Partitioning
-			admin.GET("/db/partitions", adminDBHandler.GetPartitionStatus)
-			admin.POST("/db/partition/create", adminDBHandler.CreatePartition)
-			admin.POST("/db/partitions/ensure", adminDBHandler.EnsurePartitions)
-			admin.GET("/db/partition/migration-plan", adminDBHandler.GetMigrationPlan)
+			platform.GET("/db/partitions", adminDBHandler.GetPartitionStatus)
+			platform.POST("/db/partition/create", adminDBHandler.CreatePartition)
+			platform.POST("/db/partitions/ensure", adminDBHandler.EnsurePartitions)
+			platform.GET("/db/partition/migration-plan", adminDBHandler.GetMigrationPlan)
 
 			// 5. Connection Pool
-			admin.GET("/db/pool", adminDBHandler.GetConnectionPool)
+			platform.GET("/db/pool", adminDBHandler.GetConnectionPool)
 
 			// 6. Latency & Performance
-			admin.GET("/db/latency", adminDBHandler.GetDBLatency)
-			admin.GET("/db/slow-queries", adminDBHandler.GetSlowQueries)
+			platform.GET("/db/latency", adminDBHandler.GetDBLatency)
+			platform.GET("/db/slow-queries", adminDBHandler.GetSlowQueries)
 
 			// 7. Size
-			admin.GET("/db/size", adminDBHandler.GetDBSize)
+			platform.GET("/db/size", adminDBHandler.GetDBSize)
 
 			// 8. Full Report
-			admin.GET("/db/report", adminDBHandler.GetDBReport)
+			platform.GET("/db/report", adminDBHandler.GetDBReport)
 
 			// ============================================
 			// PHASE 8.2: ADVERTISER API KEYS
 			// ============================================
 
 			// 1. List all API keys
-			admin.GET("/api-keys", adminAPIKeysHandler.GetAllAPIKeys)
+			platform.GET("/api-keys", adminAPIKeysHandler.GetAllAPIKeys)
 
 			// 2. Get single API key (masked)
-			admin.GET("/api-keys/:id", adminAPIKeysHandler.GetAPIKeyByID)
+			platform.GET("/api-keys/:id", adminAPIKeysHandler.GetAPIKeyByID)
 
 			// 3. Get API keys by advertiser
-			admin.GET("/advertisers/:id/api-keys", adminAPIKeysHandler.GetAPIKeysByAdvertiser)
+			platform.GET("/advertisers/:id/api-keys", adminAPIKeysHandler.GetAPIKeysByAdvertiser)
 
 			// 4. Create API key for advertiser
-			admin.POST("/advertisers/:id/api-keys", adminAPIKeysHandler.CreateAPIKey)
+			platform.POST("/advertisers/:id/api-keys", adminAPIKeysHandler.CreateAPIKey)
 
 			// 5. Rotate API key
-			admin.POST("/api-keys/:id/rotate", adminAPIKeysHandler.RotateAPIKey)
+			platform.POST("/api-keys/:id/rotate", adminAPIKeysHandler.RotateAPIKey)
 
 			// 6. Revoke API key
-			admin.POST("/api-keys/:id/revoke", adminAPIKeysHandler.RevokeAPIKey)
+			platform.POST("/api-keys/:id/revoke", adminAPIKeysHandler.RevokeAPIKey)
 
 			// 7. IP management
-			admin.POST("/api-keys/:id/allow-ip", adminAPIKeysHandler.AddAllowedIP)
-			admin.POST("/api-keys/:id/deny-ip", adminAPIKeysHandler.RemoveAllowedIP)
+			platform.POST("/api-keys/:id/allow-ip", adminAPIKeysHandler.AddAllowedIP)
+			platform.POST("/api-keys/:id/deny-ip", adminAPIKeysHandler.RemoveAllowedIP)
 
 			// 8. API Key stats report
-			admin.GET("/security/api-keys/report", adminAPIKeysHandler.GetAPIKeyStats)
+			platform.GET("/security/api-keys/report", adminAPIKeysHandler.GetAPIKeyStats)
 
 			// ============================================
 			// PHASE 8.3: GEO RULES
 			// ============================================
 
 			// 1. List all geo rules
-			admin.GET("/geo-rules", adminGeoRulesHandler.GetAllGeoRules)
+			platform.GET("/geo-rules", adminGeoRulesHandler.GetAllGeoRules)
 
 			// 2. Get single geo rule
-			admin.GET("/geo-rules/:id", adminGeoRulesHandler.GetGeoRuleByID)
+			platform.GET("/geo-rules/:id", adminGeoRulesHandler.GetGeoRuleByID)
 
 			// 3. Get geo rules by offer
-			admin.GET("/offers/:id/geo-rules", adminGeoRulesHandler.GetGeoRulesByOffer)
+			platform.GET("/offers/:id/geo-rules", adminGeoRulesHandler.GetGeoRulesByOffer)
 
 			// 4. Get geo rules by advertiser (reuse existing route pattern)
-			admin.GET("/advertisers/:id/geo-rules", adminGeoRulesHandler.GetGeoRulesByAdvertiser)
+			platform.GET("/advertisers/:id/geo-rules", adminGeoRulesHandler.GetGeoRulesByAdvertiser)
 
 			// 5. Create geo rule
-			admin.POST("/geo-rules", adminGeoRulesHandler.CreateGeoRule)
+			platform.POST("/geo-rules", adminGeoRulesHandler.CreateGeoRule)
 
 			// 6. Update geo rule
-			admin.PUT("/geo-rules/:id", adminGeoRulesHandler.UpdateGeoRule)
+			platform.PUT("/geo-rules/:id", adminGeoRulesHandler.UpdateGeoRule)
 
 			// 7. Delete geo rule
-			admin.DELETE("/geo-rules/:id", adminGeoRulesHandler.DeleteGeoRule)
+			platform.DELETE("/geo-rules/:id", adminGeoRulesHandler.DeleteGeoRule)
 
 			// 8. Geo rule statistics
-			admin.GET("/geo-rules/stats", adminGeoRulesHandler.GetGeoRuleStats)
+			platform.GET("/geo-rules/stats", adminGeoRulesHandler.GetGeoRuleStats)
 
 			// 9. Country codes reference
-			admin.GET("/geo-rules/countries", adminGeoRulesHandler.GetCountryCodes)
+			platform.GET("/geo-rules/countries", adminGeoRulesHandler.GetCountryCodes)
 
 			// 10. Test geo rule
-			admin.POST("/geo-rules/test", adminGeoRulesHandler.TestGeoRule)
+			platform.POST("/geo-rules/test", adminGeoRulesHandler.TestGeoRule)
 
 			// ============================================
 			// PHASE 8.4: LINK SIGNING & TTL VALIDATION
 			// ============================================
 
 			// 1. Link signing configuration
-			admin.GET("/link-signing/config", adminLinkSigningHandler.GetConfig)
-			admin.PUT("/link-signing/config", adminLinkSigningHandler.UpdateConfig)
+			platform.GET("/link-signing/config", adminLinkSigningHandler.GetConfig)
+			platform.PUT("/link-signing/config", adminLinkSigningHandler.UpdateConfig)
 
 			// 2. Test link validation
-			admin.GET("/link-signing/test", adminLinkSigningHandler.TestLink)
+			platform.GET("/link-signing/test", adminLinkSigningHandler.TestLink)
 
 			// 3. Generate signed link (for testing)
-			admin.POST("/link-signing/generate", adminLinkSigningHandler.GenerateSignedLink)
+			platform.POST("/link-signing/generate", adminLinkSigningHandler.GenerateSignedLink)
 
 			// 4. Secret rotation
-			admin.POST("/link-signing/rotate-secret", adminLinkSigningHandler.RotateSecret)
+			platform.POST("/link-signing/rotate-secret", adminLinkSigningHandler.RotateSecret)
 
 			// 5. Replay cache management
-			admin.POST("/link-signing/replay/clear", adminLinkSigningHandler.ClearReplayCache)
-			admin.GET("/link-signing/replay/stats", adminLinkSigningHandler.GetReplayCacheStats)
+			platform.POST("/link-signing/replay/clear", adminLinkSigningHandler.ClearReplayCache)
+			platform.GET("/link-signing/replay/stats", adminLinkSigningHandler.GetReplayCacheStats)
 
 			// 6. Link signing statistics
-			admin.GET("/security/link-signing/stats", adminLinkSigningHandler.GetStats)
+			platform.GET("/security/link-signing/stats", adminLinkSigningHandler.GetStats)
 
 			// ============================================
 			// PHASE 8.5: ADVANCED WEBHOOKS ENGINE
 			// ============================================
 
 			// 1. Webhook Pipelines
-			admin.GET("/webhooks/pipelines", adminWebhooksHandler.GetAllPipelines)
-			admin.GET("/webhooks/pipelines/:id", adminWebhooksHandler.GetPipeline)
-			admin.POST("/webhooks/pipelines", adminWebhooksHandler.CreatePipeline)
-			admin.PUT("/webhooks/pipelines/:id", adminWebhooksHandler.UpdatePipeline)
-			admin.DELETE("/webhooks/pipelines/:id", adminWebhooksHandler.DeletePipeline)
+			platform.GET("/webhooks/pipelines", adminWebhooksHandler.GetAllPipelines)
+			platform.GET("/webhooks/pipelines/:id", adminWebhooksHandler.GetPipeline)
+			platform.POST("/webhooks/pipelines", adminWebhooksHandler.CreatePipeline)
+			platform.PUT("/webhooks/pipelines/:id", adminWebhooksHandler.UpdatePipeline)
+			platform.DELETE("/webhooks/pipelines/:id", adminWebhooksHandler.DeletePipeline)
 
 			// 2. Execution Logs
-			admin.GET("/webhooks/logs/recent", adminWebhooksHandler.GetRecentLogs)
-			admin.GET("/webhooks/logs/:task_id", adminWebhooksHandler.GetExecutionLog)
-			admin.GET("/webhooks/logs/failures", adminWebhooksHandler.GetFailureLogs)
+			platform.GET("/webhooks/logs/recent", adminWebhooksHandler.GetRecentLogs)
+			platform.GET("/webhooks/logs/:task_id", adminWebhooksHandler.GetExecutionLog)
+			platform.GET("/webhooks/logs/failures", adminWebhooksHandler.GetFailureLogs)
 
 			// 3. Dead Letter Queue (DLQ)
-			admin.GET("/webhooks/dlq", adminWebhooksHandler.GetDLQ)
-			admin.POST("/webhooks/dlq/retry/:id", adminWebhooksHandler.RetryDLQItem)
-			admin.DELETE("/webhooks/dlq/:id", adminWebhooksHandler.DeleteDLQItem)
+			platform.GET("/webhooks/dlq", adminWebhooksHandler.GetDLQ)
+			platform.POST("/webhooks/dlq/retry/:id", adminWebhooksHandler.RetryDLQItem)
+			platform.DELETE("/webhooks/dlq/:id", adminWebhooksHandler.DeleteDLQItem)
 
 			// 4. Testing
-			admin.POST("/webhooks/test/pipeline", adminWebhooksHandler.TestPipeline)
-			admin.POST("/webhooks/test/step", adminWebhooksHandler.TestStep)
+			platform.POST("/webhooks/test/pipeline", adminWebhooksHandler.TestPipeline)
+			platform.POST("/webhooks/test/step", adminWebhooksHandler.TestStep)
 
 			// 5. Stats & Reference
-			admin.GET("/webhooks/stats", adminWebhooksHandler.GetStats)
-			admin.GET("/webhooks/trigger-types", adminWebhooksHandler.GetTriggerTypes)
-			admin.GET("/webhooks/signature-modes", adminWebhooksHandler.GetSignatureModes)
+			platform.GET("/webhooks/stats", adminWebhooksHandler.GetStats)
+			platform.GET("/webhooks/trigger-types", adminWebhooksHandler.GetTriggerTypes)
+			platform.GET("/webhooks/signature-modes", adminWebhooksHandler.GetSignatureModes)
 
 			// ============================================
 			// PHASE 8.6: MULTI-TENANT SYSTEM
 			// ============================================
 
+			// Platform-level: super admins only (tenant admins share the "admin" role)
+			tenantsAdmin := admin.Group("/tenants")
+			tenantsAdmin.Use(middleware.SuperAdminMiddleware())
+
 			// 1. Tenant CRUD
-			admin.GET("/tenants", adminTenantsHandler.GetAllTenants)
-			admin.GET("/tenants/report", adminTenantsHandler.GetTenantsReport)
-			admin.GET("/tenants/plans", adminTenantsHandler.GetPlans)
-			admin.GET("/tenants/:id", adminTenantsHandler.GetTenant)
-			admin.POST("/tenants", adminTenantsHandler.CreateTenant)
-			admin.PUT("/tenants/:id", adminTenantsHandler.UpdateTenant)
-			admin.DELETE("/tenants/:id", adminTenantsHandler.DeleteTenant)
+			tenantsAdmin.GET("", adminTenantsHandler.GetAllTenants)
+			tenantsAdmin.GET("/report", adminTenantsHandler.GetTenantsReport)
+			tenantsAdmin.GET("/plans", adminTenantsHandler.GetPlans)
+			tenantsAdmin.GET("/:id", adminTenantsHandler.GetTenant)
+			tenantsAdmin.POST("", adminTenantsHandler.CreateTenant)
+			tenantsAdmin.PUT("/:id", adminTenantsHandler.UpdateTenant)
+			tenantsAdmin.DELETE("/:id", adminTenantsHandler.DeleteTenant)
 
 			// 2. Tenant Status
-			admin.POST("/tenants/:id/suspend", adminTenantsHandler.SuspendTenant)
-			admin.POST("/tenants/:id/activate", adminTenantsHandler.ActivateTenant)
+			tenantsAdmin.POST("/:id/suspend", adminTenantsHandler.SuspendTenant)
+			tenantsAdmin.POST("/:id/activate", adminTenantsHandler.ActivateTenant)
 
 			// 3. Tenant Stats
-			admin.GET("/tenants/:id/stats", adminTenantsHandler.GetTenantStats)
+			tenantsAdmin.GET("/:id/stats", adminTenantsHandler.GetTenantStats)
 
 			// 4. Tenant Domains
-			admin.GET("/tenants/:id/domains", adminTenantsHandler.GetTenantDomains)
-			admin.POST("/tenants/:id/domains", adminTenantsHandler.AddTenantDomain)
-			admin.DELETE("/tenants/:id/domains/:domain", adminTenantsHandler.RemoveTenantDomain)
+			tenantsAdmin.GET("/:id/domains", adminTenantsHandler.GetTenantDomains)
+			tenantsAdmin.POST("/:id/domains", adminTenantsHandler.AddTenantDomain)
+			tenantsAdmin.DELETE("/:id/domains/:domain", adminTenantsHandler.RemoveTenantDomain)
+			tenantsAdmin.POST("/:id/domains/:domain/verify", adminTenantsHandler.VerifyTenantDomain)
+			tenantsAdmin.POST("/:id/domains/:domain/certificate", adminTenantsHandler.RequestTenantDomainCertificate)
 
 			// 5. Tenant Branding
-			admin.GET("/tenants/:id/branding", adminTenantsHandler.GetTenantBranding)
-			admin.PUT("/tenants/:id/branding", adminTenantsHandler.UpdateTenantBranding)
+			tenantsAdmin.GET("/:id/branding", adminTenantsHandler.GetTenantBranding)
+			tenantsAdmin.PUT("/:id/branding", adminTenantsHandler.UpdateTenantBranding)
 
 			// 6. Tenant Plan
-			admin.POST("/tenants/:id/plan", adminTenantsHandler.ChangeTenantPlan)
+			tenantsAdmin.POST("/:id/plan", adminTenantsHandler.ChangeTenantPlan)
 
 			// 7. Tenant Settings
-			admin.GET("/tenants/:id/settings", adminTenantsHandler.GetTenantSettings)
-			admin.PUT("/tenants/:id/settings", adminTenantsHandler.UpdateTenantSettings)
+			tenantsAdmin.GET("/:id/settings", adminTenantsHandler.GetTenantSettings)
+			tenantsAdmin.PUT("/:id/settings", adminTenantsHandler.UpdateTenantSettings)
 
 			// 8. Tenant Features
-			admin.GET("/tenants/:id/features", adminTenantsHandler.GetTenantFeatures)
+			tenantsAdmin.GET("/:id/features", adminTenantsHandler.GetTenantFeatures)
 
 			// 9. Tenant Audit Logs
-			admin.GET("/tenants/:id/audit-logs", adminTenantsHandler.GetTenantAuditLogs)
+			tenantsAdmin.GET("/:id/audit-logs", adminTenantsHandler.GetTenantAuditLogs)
+
+			// 10. Tenant Onboarding
+			tenantsAdmin.GET("/:id/onboarding", tenantOnboardingHandler.GetTenantOnboarding)
+
+			// 11. Usage Metering (billing)
+			tenantsAdmin.GET("/:id/usage", tenantUsageHandler.GetTenantUsage)
+
+			// 12. Billing
+			tenantsAdmin.GET("/:id/billing", tenantBillingHandler.GetTenantBilling)
+			tenantsAdmin.GET("/:id/billing/invoices", tenantBillingHandler.GetTenantInvoices)
+			tenantsAdmin.POST("/:id/billing/invoices/:invoiceId/mark-paid", tenantBillingHandler.MarkTenantInvoicePaid)
+
+			// 13. Offboarding (export & hard delete)
+			tenantsAdmin.POST("/:id/exports", tenantOffboardingHandler.RequestTenantExport)
+			tenantsAdmin.GET("/:id/exports", tenantOffboardingHandler.GetTenantExports)
+			tenantsAdmin.GET("/:id/exports/:exportId/download", tenantOffboardingHandler.DownloadTenantExport)
+			tenantsAdmin.POST("/:id/deletion", tenantOffboardingHandler.ScheduleTenantDeletion)
+			tenantsAdmin.GET("/:id/deletion", tenantOffboardingHandler.GetTenantDeletion)
+			tenantsAdmin.DELETE("/:id/deletion", tenantOffboardingHandler.CancelTenantDeletion)
+			tenantsAdmin.POST("/:id/deletion/retry", tenantOffboardingHandler.RetryTenantDeletion)
+
+			// 14. SSO
+			tenantsAdmin.GET("/:id/sso", ssoHandler.GetTenantSSOConfig)
+			tenantsAdmin.PUT("/:id/sso", ssoHandler.UpdateTenantSSOConfig)
+
+			// 15. Page templates (white label)
+			tenantsAdmin.GET("/:id/templates", pageTemplateHandler.ListTenantPageTemplates)
+			tenantsAdmin.GET("/:id/templates/:page/:locale", pageTemplateHandler.GetTenantPageTemplate)
+			tenantsAdmin.PUT("/:id/templates/:page/:locale", pageTemplateHandler.UpdateTenantPageTemplate)
+			tenantsAdmin.DELETE("/:id/templates/:page/:locale", pageTemplateHandler.DeleteTenantPageTemplate)
+			tenantsAdmin.POST("/:id/templates/:page/:locale/preview", pageTemplateHandler.PreviewTenantPageTemplate)
 
 			// ============================================
 			// PHASE 8.7: EDGE CDN LAYER
 			// ============================================
 
 			// 1. Edge Status
-			admin.GET("/edge/status", adminEdgeHandler.GetEdgeStatus)
-			admin.GET("/edge/regions", adminEdgeHandler.GetEdgeRegions)
-			admin.GET("/edge/router", adminEdgeHandler.GetEdgeRouter)
-			admin.GET("/edge/stats", adminEdgeHandler.GetEdgeFullStats)
+			platform.GET("/edge/status", adminEdgeHandler.GetEdgeStatus)
+			platform.GET("/edge/regions", adminEdgeHandler.GetEdgeRegions)
+			platform.GET("/edge/router", adminEdgeHandler.GetEdgeRouter)
+			platform.GET("/edge/stats", adminEdgeHandler.GetEdgeFullStats)
 
 			// 2. Edge Queue
-			admin.GET("/edge/queue", adminEdgeHandler.GetEdgeQueue)
-			admin.POST("/edge/queue/flush", adminEdgeHandler.FlushEdgeQueue)
+			platform.GET("/edge/queue", adminEdgeHandler.GetEdgeQueue)
+			platform.POST("/edge/queue/flush", adminEdgeHandler.FlushEdgeQueue)
 
 			// 3. Edge Failover
-			admin.GET("/edge/failover", adminEdgeHandler.GetEdgeFailover)
+			platform.GET("/edge/failover", adminEdgeHandler.GetEdgeFailover)
 
 			// 4. Edge Cache
-			admin.POST("/edge/cache/refresh", adminEdgeHandler.RefreshEdgeCache)
+			platform.POST("/edge/cache/refresh", adminEdgeHandler.RefreshEdgeCache)
 
 			// ============================================
 			// PHASE 8.8: ZERO-DROP TRACKING MODE
 			// ============================================
 
 			// 1. Zero-Drop Status
-			admin.GET("/zero-drop/status", adminZeroDropHandler.GetStatus)
-			admin.GET("/zero-drop/metrics", adminZeroDropHandler.GetMetrics)
+			platform.GET("/zero-drop/status", adminZeroDropHandler.GetStatus)
+			platform.GET("/zero-drop/metrics", adminZeroDropHandler.GetMetrics)
 
 			// 2. WAL Management
-			admin.GET("/zero-drop/wal", adminZeroDropHandler.GetWALStatus)
-			admin.GET("/zero-drop/wal/pending", adminZeroDropHandler.GetWALPending)
-			admin.POST("/zero-drop/wal/compact", adminZeroDropHandler.CompactWAL)
+			platform.GET("/zero-drop/wal", adminZeroDropHandler.GetWALStatus)
+			platform.GET("/zero-drop/wal/pending", adminZeroDropHandler.GetWALPending)
+			platform.POST("/zero-drop/wal/compact", adminZeroDropHandler.CompactWAL)
 
 			// 3. Replay & Recovery
-			admin.POST("/zero-drop/replay", adminZeroDropHandler.TriggerReplay)
-			admin.POST("/zero-drop/fix-inconsistencies", adminZeroDropHandler.FixInconsistencies)
+			platform.POST("/zero-drop/replay", adminZeroDropHandler.TriggerReplay)
+			platform.POST("/zero-drop/fix-inconsistencies", adminZeroDropHandler.FixInconsistencies)
 
 			// 4. Redis Streams
-			admin.GET("/zero-drop/streams", adminZeroDropHandler.GetStreamsStatus)
+			platform.GET("/zero-drop/streams", adminZeroDropHandler.GetStreamsStatus)
 
 			// 5. Failover Queue
-			admin.GET("/zero-drop/failover-queue", adminZeroDropHandler.GetFailoverQueueStatus)
-			admin.POST("/zero-drop/failover-queue/flush", adminZeroDropHandler.FlushFailoverQueue)
+			platform.GET("/zero-drop/failover-queue", adminZeroDropHandler.GetFailoverQueueStatus)
+			platform.POST("/zero-drop/failover-queue/flush", adminZeroDropHandler.FlushFailoverQueue)
 
 			// 6. Zero-Drop Mode Control
-			admin.POST("/zero-drop/enable", adminZeroDropHandler.EnableZeroDropMode)
-			admin.POST("/zero-drop/disable", adminZeroDropHandler.DisableZeroDropMode)
-			admin.POST("/zero-drop/tenant/:id/enable", adminZeroDropHandler.EnableZeroDropForTenant)
-			admin.POST("/zero-drop/tenant/:id/disable", adminZeroDropHandler.DisableZeroDropForTenant)
+			platform.POST("/zero-drop/enable", adminZeroDropHandler.EnableZeroDropMode)
+			platform.POST("/zero-drop/disable", adminZeroDropHandler.DisableZeroDropMode)
+			platform.POST("/zero-drop/tenant/:id/enable", adminZeroDropHandler.EnableZeroDropForTenant)
+			platform.POST("/zero-drop/tenant/:id/disable", adminZeroDropHandler.DisableZeroDropForTenant)
 
 			// 7. Postback Queue
-			admin.GET("/postbacks/queue", adminZeroDropHandler.GetPostbackQueue)
-			admin.GET("/postbacks/dlq", adminZeroDropHandler.GetPostbackDLQ)
-			admin.POST("/postbacks/dlq/:id/retry", adminZeroDropHandler.RetryPostbackDLQItem)
-			admin.POST("/postbacks/dlq/retry-all", adminZeroDropHandler.RetryAllPostbackDLQ)
-			admin.DELETE("/postbacks/dlq/:id", adminZeroDropHandler.DeletePostbackDLQItem)
+			platform.GET("/postbacks/queue", adminZeroDropHandler.GetPostbackQueue)
+			platform.GET("/postbacks/dlq", adminZeroDropHandler.GetPostbackDLQ)
+			platform.POST("/postbacks/dlq/:id/retry", adminZeroDropHandler.RetryPostbackDLQItem)
+			platform.POST("/postbacks/dlq/retry-all", adminZeroDropHandler.RetryAllPostbackDLQ)
+			platform.DELETE("/postbacks/dlq/:id", adminZeroDropHandler.DeletePostbackDLQItem)
 
 			// ============================================
 			// PHASE 8.9: LAUNCH MODE (PRODUCTION HARDENING)
 			// ============================================
 
 			// 1. Launch Dashboard
-			admin.GET("/launch-dashboard", adminLaunchHandler.GetLaunchDashboard)
-			admin.GET("/live-metrics", adminLaunchHandler.GetLiveMetrics)
+			platform.GET("/launch-dashboard", adminLaunchHandler.GetLaunchDashboard)
+			platform.GET("/live-metrics", adminLaunchHandler.GetLiveMetrics)
 
 			// 2. Logging Mode
-			admin.GET("/logging/mode", adminLaunchHandler.GetLoggingMode)
-			admin.POST("/logging/mode", adminLaunchHandler.SetLoggingMode)
-			admin.GET("/logging/state", adminLaunchHandler.GetLoggingState)
+			platform.GET("/logging/mode", adminLaunchHandler.GetLoggingMode)
+			platform.POST("/logging/mode", adminLaunchHandler.SetLoggingMode)
+			platform.GET("/logging/state", adminLaunchHandler.GetLoggingState)
 
 			// 3. Threat Protection
-			admin.GET("/security/threats", adminLaunchHandler.GetThreats)
-			admin.GET("/security/anomalies", adminLaunchHandler.GetAnomalies)
-			admin.GET("/security/ip-blocks", adminLaunchHandler.GetIPBlocks)
-			admin.POST("/security/ip-blocks", adminLaunchHandler.BlockIPAddress)
-			admin.DELETE("/security/ip-blocks/:ip", adminLaunchHandler.UnblockIPAddress)
+			platform.GET("/security/threats", adminLaunchHandler.GetThreats)
+			platform.GET("/security/anomalies", adminLaunchHandler.GetAnomalies)
+			platform.GET("/security/ip-blocks", adminLaunchHandler.GetIPBlocks)
+			platform.POST("/security/ip-blocks", adminLaunchHandler.BlockIPAddress)
+			platform.DELETE("/security/ip-blocks/:ip", adminLaunchHandler.UnblockIPAddress)
 
 			// 4. Alerts
-			admin.GET("/alerts/active", adminLaunchHandler.GetActiveAlerts)
-			admin.GET("/alerts/history", adminLaunchHandler.GetAlertHistory)
-			admin.POST("/alerts/:id/acknowledge", adminLaunchHandler.AcknowledgeAlert)
-			admin.GET("/alerts/thresholds", adminLaunchHandler.GetAlertThresholds)
-			admin.PUT("/alerts/thresholds", adminLaunchHandler.UpdateAlertThresholds)
+			platform.GET("/alerts/active", adminLaunchHandler.GetActiveAlerts)
+			platform.GET("/alerts/history", adminLaunchHandler.GetAlertHistory)
+			platform.POST("/alerts/:id/acknowledge", adminLaunchHandler.AcknowledgeAlert)
+			platform.GET("/alerts/thresholds", adminLaunchHandler.GetAlertThresholds)
+			platform.PUT("/alerts/thresholds", adminLaunchHandler.UpdateAlertThresholds)
 
 			// 5. Load Testing
-			admin.POST("/loadtest/run", adminLaunchHandler.RunLoadTest)
-			admin.GET("/loadtest/report", adminLaunchHandler.GetLoadTestReport)
+			platform.POST("/loadtest/run", adminLaunchHandler.RunLoadTest)
+			platform.GET("/loadtest/report", adminLaunchHandler.GetLoadTestReport)
 
 			// ============================================
 			// PHASE 9: QA, SECURITY AUDIT & BENCHMARKS
@@ -775,32 +1130,32 @@ func main() {
 			adminQAHandler := handlers.NewAdminQAHandler(db)
 
 			// 1. E2E Tests
-			admin.POST("/e2e-tests/run", adminQAHandler.RunE2ETest)
-			admin.GET("/e2e-tests/scenarios", adminQAHandler.GetE2EScenarios)
-			admin.GET("/e2e-tests/history", adminQAHandler.GetE2ETestHistory)
-			admin.GET("/e2e-tests/:id", adminQAHandler.GetE2ETestRun)
+			platform.POST("/e2e-tests/run", adminQAHandler.RunE2ETest)
+			platform.GET("/e2e-tests/scenarios", adminQAHandler.GetE2EScenarios)
+			platform.GET("/e2e-tests/history", adminQAHandler.GetE2ETestHistory)
+			platform.GET("/e2e-tests/:id", adminQAHandler.GetE2ETestRun)
 
 			// 2. Consistency Checks
-			admin.GET("/consistency/run", adminQAHandler.RunConsistencyCheck)
-			admin.GET("/consistency/report", adminQAHandler.GetConsistencyReport)
-			admin.GET("/consistency/issues", adminQAHandler.GetConsistencyIssues)
-			admin.POST("/consistency/fix", adminQAHandler.FixConsistencyIssues)
+			platform.GET("/consistency/run", adminQAHandler.RunConsistencyCheck)
+			platform.GET("/consistency/report", adminQAHandler.GetConsistencyReport)
+			platform.GET("/consistency/issues", adminQAHandler.GetConsistencyIssues)
+			platform.POST("/consistency/fix", adminQAHandler.FixConsistencyIssues)
 
 			// 3. Security Audit
-			admin.GET("/security/audit/run", adminQAHandler.RunSecurityAudit)
-			admin.GET("/security/audit/report", adminQAHandler.GetSecurityAuditReport)
-			admin.GET("/security/audit/findings", adminQAHandler.GetSecurityFindings)
+			platform.GET("/security/audit/run", adminQAHandler.RunSecurityAudit)
+			platform.GET("/security/audit/report", adminQAHandler.GetSecurityAuditReport)
+			platform.GET("/security/audit/findings", adminQAHandler.GetSecurityFindings)
 
 			// 4. Benchmarks
-			admin.POST("/benchmarks/run", adminQAHandler.RunBenchmark)
-			admin.GET("/benchmarks/report", adminQAHandler.GetBenchmarkReport)
-			admin.GET("/benchmarks/history", adminQAHandler.GetBenchmarkHistory)
+			platform.POST("/benchmarks/run", adminQAHandler.RunBenchmark)
+			platform.GET("/benchmarks/report", adminQAHandler.GetBenchmarkReport)
+			platform.GET("/benchmarks/history", adminQAHandler.GetBenchmarkHistory)
 
 			// 5. Preflight Check
-			admin.GET("/preflight/check", adminQAHandler.PreflightCheck)
+			platform.GET("/preflight/check", adminQAHandler.PreflightCheck)
 
 			// 6. QA Stats
-			admin.GET("/qa/stats", adminQAHandler.GetQAStats)
+			platform.GET("/qa/stats", adminQAHandler.GetQAStats)
 		}
 		}
 
@@ -818,6 +1173,21 @@ func main() {
 		}
 	}
 
+	// HTTPS for tenants' custom domains; HTTP-01 challenges are answered on the main port
+	if certificateService != nil {
+		go func() {
+			server := &http.Server{
+				Addr:      ":" + cfg.TLSPort,
+				Handler:   router,
+				TLSConfig: certificateService.TLSConfig(),
+			}
+			log.Printf("🔒 Custom domain TLS listening on port %s", cfg.TLSPort)
+			if err := server.ListenAndServeTLS("", ""); err != nil {
+				log.Printf("⚠️ Custom domain TLS server stopped: %v", err)
+			}
+		}()
+	}
+
 	port := cfg.Port
 	log.Printf("🚀 Server starting on port %s in %s mode", port, cfg.Environment)
 	if err := router.Run(":" + port); err != nil {
@@ -30,19 +30,20 @@ const (
 type AlertType string
 
 const (
-	AlertDBLatency        AlertType = "db_latency"
-	AlertRedisLatency     AlertType = "redis_latency"
-	AlertDroppedClicks    AlertType = "dropped_clicks"
-	AlertWALPending       AlertType = "wal_pending"
-	AlertCPUHigh          AlertType = "cpu_high"
-	AlertMemoryHigh       AlertType = "memory_high"
-	AlertBotSpike         AlertType = "bot_spike"
-	AlertGeoBlockSpike    AlertType = "geo_block_spike"
-	AlertAPIKeyBruteForce AlertType = "api_key_brute_force"
-	AlertIngestionBacklog AlertType = "ingestion_backlog"
-	AlertEdgeDisconnect   AlertType = "edge_disconnect"
-	AlertPostbackRetries  AlertType = "postback_retries"
-	AlertSystemHealth     AlertType = "system_health"
+	AlertDBLatency         AlertType = "db_latency"
+	AlertRedisLatency      AlertType = "redis_latency"
+	AlertDroppedClicks     AlertType = "dropped_clicks"
+	AlertWALPending        AlertType = "wal_pending"
+	AlertCPUHigh           AlertType = "cpu_high"
+	AlertMemoryHigh        AlertType = "memory_high"
+	AlertBotSpike          AlertType = "bot_spike"
+	AlertGeoBlockSpike     AlertType = "geo_block_spike"
+	AlertAPIKeyBruteForce  AlertType = "api_key_brute_force"
+	AlertIngestionBacklog  AlertType = "ingestion_backlog"
+	AlertEdgeDisconnect    AlertType = "edge_disconnect"
+	AlertPostbackRetries   AlertType = "postback_retries"
+	AlertSystemHealth      AlertType = "system_health"
+	AlertConversionAnomaly AlertType = "conversion_anomaly"
 )
 
 // ============================================
@@ -129,6 +129,14 @@ func Get(ctx context.Context, key string) (string, error) {
 	return RedisClient.Get(ctx, key).Result()
 }
 
+// GetDel gets a key and deletes it atomically (one-time tokens)
+func GetDel(ctx context.Context, key string) (string, error) {
+	if RedisClient == nil {
+		return "", fmt.Errorf("Redis client not initialized")
+	}
+	return RedisClient.GetDel(ctx, key).Result()
+}
+
 func Delete(ctx context.Context, keys ...string) error {
 	if RedisClient == nil {
 		return fmt.Errorf("Redis client not initialized")
@@ -16,6 +16,9 @@ type Config struct {
 	JWTRefreshExpiration time.Duration
 	AllowedOrigins       string
 	LogLevel             string
+	TenantRLSEnabled     bool   // Postgres row-level security for tenant tables
+	ACMEEnabled          bool   // automatic TLS for verified custom domains
+	TLSPort              string // HTTPS listener for custom domains (ACME)
 }
 
 var AppConfig *Config
@@ -29,6 +32,9 @@ func Load() (*Config, error) {
 		Environment:          os.Getenv("ENV"),
 		AllowedOrigins:       os.Getenv("ALLOWED_ORIGINS"),
 		LogLevel:             os.Getenv("LOG_LEVEL"),
+		TenantRLSEnabled:     os.Getenv("TENANT_RLS_ENABLED") == "true",
+		ACMEEnabled:          os.Getenv("ACME_ENABLED") == "true",
+		TLSPort:              os.Getenv("TLS_PORT"),
 	}
 
 	if config.PostgresURL == "" {
@@ -59,6 +65,10 @@ func Load() (*Config, error) {
 		config.LogLevel = "info"
 	}
 
+	if config.TLSPort == "" {
+		config.TLSPort = "443"
+	}
+
 	jwtExpStr := os.Getenv("JWT_EXPIRATION")
 	if jwtExpStr == "" {
 		jwtExpStr = "24h"
@@ -98,10 +98,40 @@ func AutoMigrate(db *gorm.DB) error {
 		&models.AdminUser{},
 		&models.AfftokUser{},
 		&models.Network{},
+		&models.AffiliateNetwork{},
 		&models.Offer{},
 		&models.UserOffer{},
+		&models.PromoterNetworkAccount{},
 		&models.Click{},
 		&models.Conversion{},
+		&models.ConversionAnomaly{},
+		&models.KYCVerification{},
+		&models.LandingBeacon{},
+		&models.FraudCase{},
+		&models.FraudCaseLink{},
+		&models.FraudCaseNote{},
+		&models.EarningsAdjustment{},
+		// Earnings ledger
+		&models.LedgerAccount{},
+		&models.LedgerTransaction{},
+		&models.LedgerEntry{},
+		&models.EarningsCounter{},
+		// Platform fee rules
+		&models.FeeRule{},
+		// Referrals (override commissions)
+		&models.Referral{},
+		// Payouts
+		&models.PayoutBatch{},
+		&models.Payout{},
+		&models.PayoutEvent{},
+		&models.PayoutMethod{},
+		&models.PayoutRailSubmission{},
+		// Advertiser prepaid wallets
+		&models.AdvertiserWallet{},
+		&models.WalletTransaction{},
+		&models.WalletPendingCharge{},
+		// Exchange rates (platform-wide)
+		&models.FXRate{},
 		&models.Team{},
 		&models.TeamMember{},
 		&models.Badge{},
@@ -121,6 +151,17 @@ func AutoMigrate(db *gorm.DB) error {
 		// Phase 8.6: Multi-Tenant
 		&models.Tenant{},
 		&models.TenantDomain{},
+		&models.TenantCertificate{},
+		&models.TenantOnboarding{},
+		&models.TenantUsageDaily{},
+		&models.TenantSubscription{},
+		&models.TenantInvoice{},
+		&models.TenantInvoiceLine{},
+		&models.BillingWebhookEvent{},
+		&models.TenantExportJob{},
+		&models.TenantDeletionJob{},
+		&models.TenantSSOConfig{},
+		&models.TenantPageTemplate{},
 		&models.TenantAuditLog{},
 		// Contests/Challenges
 		&models.Contest{},
@@ -128,6 +169,11 @@ func AutoMigrate(db *gorm.DB) error {
 		// Invoices
 		&models.Invoice{},
 		&models.InvoiceItem{},
+		&models.InvoiceAdjustment{},
+		&models.TaxRule{},
+		&models.InvoiceSequence{},
+		// Promoter tax profiles
+		&models.PromoterTaxProfile{},
 	)
 
 	if err != nil {
@@ -140,6 +186,16 @@ func AutoMigrate(db *gorm.DB) error {
 	// Generate unique codes for existing users who don't have one
 	generateMissingUniqueCodes(db)
 
+	// Seed predefined affiliate networks (payout minimums come from here)
+	seedAffiliateNetworks(db)
+
+	// Invoices from before itemised billing owe exactly their platform fee
+	backfillInvoiceTotals(db)
+
+	// Fraud, anomaly, KYC and beacon rows created before they inherited a
+	// tenant fell back to the default tenant
+	backfillInheritedTenants(db)
+
 	log.Println("✅ Database migration completed successfully")
 	return nil
 }
@@ -163,6 +219,59 @@ func generateMissingUniqueCodes(db *gorm.DB) {
 	}
 }
 
+// seedAffiliateNetworks inserts the predefined networks when none exist yet
+func seedAffiliateNetworks(db *gorm.DB) {
+	var count int64
+	if err := db.Model(&models.AffiliateNetwork{}).Count(&count).Error; err != nil || count > 0 {
+		return
+	}
+
+	networks := make([]models.AffiliateNetwork, len(models.PredefinedNetworks))
+	copy(networks, models.PredefinedNetworks)
+	if err := db.Create(&networks).Error; err != nil {
+		log.Printf("⚠️ Failed to seed affiliate networks: %v", err)
+		return
+	}
+	log.Printf("✅ Seeded %d affiliate networks", len(networks))
+}
+
+// backfillInvoiceTotals sets subtotal and total of unnumbered (legacy) invoices
+func backfillInvoiceTotals(db *gorm.DB) {
+	result := db.Exec(`UPDATE invoices SET subtotal = platform_amount, total_amount = platform_amount
+		WHERE (number IS NULL OR number = '') AND total_amount = 0 AND platform_amount <> 0`)
+	if result.Error != nil {
+		log.Printf("⚠️ Failed to backfill invoice totals: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("✅ Backfilled totals of %d legacy invoices", result.RowsAffected)
+	}
+}
+
+// backfillInheritedTenants moves rows left on the default tenant to the tenant
+// of the row they belong to
+func backfillInheritedTenants(db *gorm.DB) {
+	inherited := []struct{ table, parent, column string }{
+		{"fraud_cases", "afftok_users", "user_id"},
+		{"conversion_anomalies", "afftok_users", "user_id"},
+		{"kyc_verifications", "afftok_users", "user_id"},
+		{"earnings_adjustments", "afftok_users", "user_id"},
+		{"landing_beacons", "clicks", "click_id"},
+	}
+	for _, t := range inherited {
+		result := db.Exec(fmt.Sprintf(`UPDATE %[1]s SET tenant_id = p.tenant_id FROM %[2]s p
+			WHERE p.id = %[1]s.%[3]s AND %[1]s.tenant_id = ? AND p.tenant_id <> ?`, t.table, t.parent, t.column),
+			models.DefaultTenantID, models.DefaultTenantID)
+		if result.Error != nil {
+			log.Printf("⚠️ Failed to backfill tenant of %s: %v", t.table, result.Error)
+			continue
+		}
+		if result.RowsAffected > 0 {
+			log.Printf("✅ Moved %d %s rows to their tenant", result.RowsAffected, t.table)
+		}
+	}
+}
+
 // createIndexes creates additional indexes for tracking performance
 func createIndexes(db *gorm.DB) {
 	// High-performance indexes for extreme load
@@ -253,6 +362,48 @@ func createIndexes(db *gorm.DB) {
 		
 		// Referral code
 		"CREATE INDEX IF NOT EXISTS idx_users_referral ON afftok_users(referral_code) WHERE referral_code IS NOT NULL",
+		
+		// ============================================
+		// PAYOUTS - one batch per tenant and period
+		// ============================================
+		
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_payout_batches_tenant_period ON payout_batches(tenant_id, period)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_payouts_batch_line ON payouts(batch_id, advertiser_id, publisher_id, currency) WHERE batch_id IS NOT NULL",
+
+		// ============================================
+		// INVOICES - one per advertiser, period and currency; numbers unique per tenant
+		// Unpaid invoices by due date for the collections job
+		// ============================================
+
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_advertiser_period ON invoices(tenant_id, advertiser_id, year, month, currency)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_tenant_number ON invoices(tenant_id, number) WHERE number IS NOT NULL AND number <> ''",
+		"CREATE INDEX IF NOT EXISTS idx_invoices_unpaid_due ON invoices(due_date) WHERE status IN ('pending', 'overdue')",
+
+		// ============================================
+		// TENANT INVOICES - one renewal or overage invoice per subscription period
+		// (the subscription is keyed by tenant); plan changes may repeat
+		// ============================================
+
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_tenant_invoices_period ON tenant_invoices(tenant_id, period_start, reason) WHERE reason <> 'plan_change'",
+
+		// ============================================
+		// TAX PROFILES - one per promoter and tenant
+		// ============================================
+
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_promoter_tax_profiles_user ON promoter_tax_profiles(tenant_id, user_id)",
+
+		// ============================================
+		// FEE RULES - one open version per scope
+		// ============================================
+
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_fee_rules_open ON fee_rules(tenant_id, scope, scope_value) WHERE effective_to IS NULL",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_fee_rules_version ON fee_rules(tenant_id, scope, scope_value, version)",
+
+		// ============================================
+		// REFERRALS - one referrer per promoter
+		// ============================================
+
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_promoter_referrals_referee ON promoter_referrals(tenant_id, referee_id)",
 	}
 
 	log.Println("📊 Creating performance indexes...")
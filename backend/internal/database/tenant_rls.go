@@ -0,0 +1,194 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// POSTGRES ROW-LEVEL SECURITY (DEFENCE IN DEPTH)
+// ============================================
+//
+// TenantDB scopes queries in the application. RLS is a second, optional layer
+// enforced by Postgres itself:
+//
+//   - every tenant table gets a "tenant_isolation" policy that only exposes
+//     rows whose tenant_id equals the transaction-local app.tenant_id setting
+//   - request transactions switch to RLSTenantRole (SET LOCAL ROLE), which is
+//     subject to the policy. Without app.tenant_id it sees nothing
+//   - RLSBypassRole (BYPASSRLS) is for super-admin work and background
+//     workers that must read across tenants, via WithRLSBypass
+//
+// RLS is enabled but not forced, so the table owner (the application's login
+// role) keeps its current behaviour and rollout can be done per code path.
+
+const (
+	// TenantRLSSetting is the session variable the policies read
+	TenantRLSSetting = "app.tenant_id"
+
+	// RLSTenantRole is assumed by tenant-scoped transactions
+	RLSTenantRole = "afftok_tenant"
+
+	// RLSBypassRole can read and write across tenants
+	RLSBypassRole = "afftok_rls_bypass"
+
+	rlsPolicyName = "tenant_isolation"
+)
+
+var tenantRLSEnabled atomic.Bool
+
+// SetTenantRLSEnabled turns RLS scoping of tenant transactions on or off
+func SetTenantRLSEnabled(enabled bool) {
+	tenantRLSEnabled.Store(enabled)
+}
+
+// TenantRLSEnabled reports whether tenant transactions run under RLS
+func TenantRLSEnabled() bool {
+	return tenantRLSEnabled.Load()
+}
+
+// ============================================
+// MIGRATION
+// ============================================
+
+// MigrateTenantRLS creates the RLS roles and a tenant_isolation policy on
+// every tenant-scoped table. It is idempotent.
+func MigrateTenantRLS(db *gorm.DB) error {
+	if err := createRLSRoles(db); err != nil {
+		return err
+	}
+
+	predicate := fmt.Sprintf("tenant_id = NULLIF(current_setting('%s', true), '')::uuid", TenantRLSSetting)
+
+	for _, table := range TenantTables {
+		if !tableHasTenantColumn(db, table) {
+			continue
+		}
+
+		statements := []string{
+			fmt.Sprintf(`ALTER TABLE %s ENABLE ROW LEVEL SECURITY`, table),
+			fmt.Sprintf(`DROP POLICY IF EXISTS %s ON %s`, rlsPolicyName, table),
+			fmt.Sprintf(`CREATE POLICY %s ON %s USING (%s) WITH CHECK (%s)`, rlsPolicyName, table, predicate, predicate),
+		}
+		for _, sql := range statements {
+			if err := db.Exec(sql).Error; err != nil {
+				return fmt.Errorf("rls on %s: %w", table, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// DropTenantRLS removes the policies and disables RLS (rollback of MigrateTenantRLS)
+func DropTenantRLS(db *gorm.DB) error {
+	for _, table := range TenantTables {
+		if !tableHasTenantColumn(db, table) {
+			continue
+		}
+		if err := db.Exec(fmt.Sprintf(`DROP POLICY IF EXISTS %s ON %s`, rlsPolicyName, table)).Error; err != nil {
+			return err
+		}
+		if err := db.Exec(fmt.Sprintf(`ALTER TABLE %s DISABLE ROW LEVEL SECURITY`, table)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createRLSRoles creates the tenant and bypass roles and grants them to the
+// application's login role so it can SET ROLE into them
+func createRLSRoles(db *gorm.DB) error {
+	roles := []struct {
+		name    string
+		options string
+	}{
+		{RLSTenantRole, "NOLOGIN"},
+		{RLSBypassRole, "NOLOGIN BYPASSRLS"},
+	}
+
+	for _, role := range roles {
+		create := fmt.Sprintf(`
+			DO $$
+			BEGIN
+				IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = '%s') THEN
+					CREATE ROLE %s %s;
+				END IF;
+			END
+			$$`, role.name, role.name, role.options)
+		if err := db.Exec(create).Error; err != nil {
+			// BYPASSRLS needs a superuser: the bypass role can be created by a DBA instead
+			if role.name == RLSBypassRole {
+				log.Printf("⚠️ Could not create %s (needs superuser): %v", RLSBypassRole, err)
+				continue
+			}
+			return fmt.Errorf("create role %s: %w", role.name, err)
+		}
+
+		grants := []string{
+			fmt.Sprintf(`GRANT %s TO CURRENT_USER`, role.name),
+			fmt.Sprintf(`GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO %s`, role.name),
+			fmt.Sprintf(`GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO %s`, role.name),
+			fmt.Sprintf(`ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO %s`, role.name),
+		}
+		for _, sql := range grants {
+			if err := db.Exec(sql).Error; err != nil {
+				return fmt.Errorf("grant %s: %w", role.name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func tableHasTenantColumn(db *gorm.DB, table string) bool {
+	var count int64
+	db.Raw(`
+		SELECT COUNT(*) FROM information_schema.columns
+		WHERE table_name = ? AND column_name = 'tenant_id'
+	`, table).Scan(&count)
+	return count > 0
+}
+
+// ============================================
+// TRANSACTION SCOPING
+// ============================================
+
+// SetLocalTenant binds an open transaction to a tenant: it sets app.tenant_id
+// and assumes RLSTenantRole until the transaction ends
+func SetLocalTenant(tx *gorm.DB, tenantID uuid.UUID) error {
+	if err := tx.Exec("SELECT set_config(?, ?, true)", TenantRLSSetting, tenantID.String()).Error; err != nil {
+		return err
+	}
+	return tx.Exec(fmt.Sprintf("SET LOCAL ROLE %s", RLSTenantRole)).Error
+}
+
+// SetLocalBypass lets an open transaction read and write across tenants
+func SetLocalBypass(tx *gorm.DB) error {
+	return tx.Exec(fmt.Sprintf("SET LOCAL ROLE %s", RLSBypassRole)).Error
+}
+
+// WithTenantRLS runs fn in a transaction that Postgres restricts to one tenant
+func WithTenantRLS(db *gorm.DB, tenantID uuid.UUID, fn func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := SetLocalTenant(tx, tenantID); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// WithRLSBypass runs fn in a transaction that bypasses tenant policies.
+// Use it for super-admin operations and workers that span tenants.
+func WithRLSBypass(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := SetLocalBypass(tx); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
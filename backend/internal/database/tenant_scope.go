@@ -1,13 +1,71 @@
 package database
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
 
 	"github.com/aljapah/afftok-backend-prod/internal/models"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
+// TenantTables lists the business tables that carry a tenant_id column
+var TenantTables = []string{
+	"afftok_users",
+	"offers",
+	"networks",
+	"user_offers",
+	"clicks",
+	"conversions",
+	"teams",
+	"team_members",
+	"contests",
+	"contest_participants",
+	"promoter_ratings",
+	"user_badges",
+	"tracking_events",
+	"payouts",
+	"payout_batches",
+	"payout_events",
+	"invoices",
+	"invoice_adjustments",
+	"tax_rules",
+	"promoter_tax_profiles",
+	"promoter_network_accounts",
+	"advertiser_api_keys",
+	"geo_rules",
+	"kyc_verifications",
+	"conversion_anomalies",
+	"landing_beacons",
+	"fraud_cases",
+	"earnings_adjustments",
+	"ledger_accounts",
+	"ledger_transactions",
+	"ledger_entries",
+	"earnings_counters",
+	"fee_rules",
+	"promoter_referrals",
+	"advertiser_wallets",
+	"wallet_transactions",
+	"wallet_pending_charges",
+}
+
+// IsTenantTable reports whether a table is tenant-scoped
+func IsTenantTable(table string) bool {
+	for _, t := range TenantTables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
+
 // ============================================
 // TENANT-SCOPED DATABASE WRAPPER
 // ============================================
@@ -47,22 +105,33 @@ func (t *TenantDB) SkipScoping() *TenantDB {
 
 // Find finds records with tenant scope
 func (t *TenantDB) Find(dest interface{}, conds ...interface{}) *gorm.DB {
-	return t.scope().Find(dest, conds...)
+	return t.scope(dest).Find(dest, conds...)
 }
 
 // First finds first record with tenant scope
 func (t *TenantDB) First(dest interface{}, conds ...interface{}) *gorm.DB {
-	return t.scope().First(dest, conds...)
+	return t.scope(dest).First(dest, conds...)
 }
 
 // Last finds last record with tenant scope
 func (t *TenantDB) Last(dest interface{}, conds ...interface{}) *gorm.DB {
-	return t.scope().Last(dest, conds...)
+	return t.scope(dest).Last(dest, conds...)
 }
 
 // Take finds one record with tenant scope
 func (t *TenantDB) Take(dest interface{}, conds ...interface{}) *gorm.DB {
-	return t.scope().Take(dest, conds...)
+	return t.scope(dest).Take(dest, conds...)
+}
+
+// Scan scans query results with tenant scope
+func (t *TenantDB) Scan(dest interface{}) *gorm.DB {
+	return t.scope().Scan(dest)
+}
+
+// FirstOrCreate finds the first matching record in the tenant or creates it
+func (t *TenantDB) FirstOrCreate(dest interface{}, conds ...interface{}) *gorm.DB {
+	t.setTenantID(dest)
+	return t.scope(dest).FirstOrCreate(dest, conds...)
 }
 
 // Create creates a record with tenant ID
@@ -87,9 +156,19 @@ func (t *TenantDB) Update(column string, value interface{}) *gorm.DB {
 	return t.scope().Update(column, value)
 }
 
+// UpdateColumn updates a single column (without hooks) with tenant scope
+func (t *TenantDB) UpdateColumn(column string, value interface{}) *gorm.DB {
+	return t.scope().UpdateColumn(column, value)
+}
+
+// UpdateColumns updates columns (without hooks) with tenant scope
+func (t *TenantDB) UpdateColumns(values interface{}) *gorm.DB {
+	return t.scope().UpdateColumns(values)
+}
+
 // Delete deletes records with tenant scope
 func (t *TenantDB) Delete(value interface{}, conds ...interface{}) *gorm.DB {
-	return t.scope().Delete(value, conds...)
+	return t.scope(value).Delete(value, conds...)
 }
 
 // Count counts records with tenant scope
@@ -106,6 +185,15 @@ func (t *TenantDB) Pluck(column string, dest interface{}) *gorm.DB {
 // CHAIN METHODS
 // ============================================
 
+// Table specifies the table (aliases such as "clicks c" are supported)
+func (t *TenantDB) Table(name string, args ...interface{}) *TenantDB {
+	return &TenantDB{
+		DB:          t.DB.Table(name, args...),
+		tenantID:    t.tenantID,
+		skipScoping: t.skipScoping,
+	}
+}
+
 // Model specifies the model
 func (t *TenantDB) Model(value interface{}) *TenantDB {
 	return &TenantDB{
@@ -248,6 +336,12 @@ func (t *TenantDB) Unscoped() *TenantDB {
 // Transaction executes a function within a transaction
 func (t *TenantDB) Transaction(fc func(tx *TenantDB) error) error {
 	return t.DB.Transaction(func(tx *gorm.DB) error {
+		// With RLS on, Postgres enforces the same tenant for the whole transaction
+		if TenantRLSEnabled() && !t.skipScoping && t.tenantID != uuid.Nil {
+			if err := SetLocalTenant(tx, t.tenantID); err != nil {
+				return err
+			}
+		}
 		tenantTx := &TenantDB{
 			DB:          tx,
 			tenantID:    t.tenantID,
@@ -259,8 +353,14 @@ func (t *TenantDB) Transaction(fc func(tx *TenantDB) error) error {
 
 // Begin begins a transaction
 func (t *TenantDB) Begin() *TenantDB {
+	tx := t.DB.Begin()
+	if TenantRLSEnabled() && !t.skipScoping && t.tenantID != uuid.Nil && tx.Error == nil {
+		if err := SetLocalTenant(tx, t.tenantID); err != nil {
+			tx.AddError(err)
+		}
+	}
 	return &TenantDB{
-		DB:          t.DB.Begin(),
+		DB:          tx,
 		tenantID:    t.tenantID,
 		skipScoping: t.skipScoping,
 	}
@@ -280,47 +380,141 @@ func (t *TenantDB) Rollback() *gorm.DB {
 // RAW QUERIES
 // ============================================
 
-// Raw executes raw SQL with tenant scope
+// ErrUnscopedRawSQL is returned for raw SQL that reads or writes a tenant
+// table without filtering on tenant_id
+var ErrUnscopedRawSQL = errors.New("raw SQL on a tenant table must filter on tenant_id")
+
+var rawTablePattern = regexp.MustCompile(`(?i)\b(?:FROM|JOIN|UPDATE|INTO)\s+"?(\w+)"?`)
+
+// UnscopedTables returns the tenant tables a raw statement touches when it
+// never mentions tenant_id
+func UnscopedTables(sql string) []string {
+	if strings.Contains(strings.ToLower(sql), "tenant_id") {
+		return nil
+	}
+	var tables []string
+	for _, m := range rawTablePattern.FindAllStringSubmatch(sql, -1) {
+		if IsTenantTable(strings.ToLower(m[1])) {
+			tables = append(tables, m[1])
+		}
+	}
+	return tables
+}
+
+// Raw executes raw SQL. Raw SQL is not rewritten: it must filter on
+// tenant_id itself (use GetTenantID for the value), or it fails with
+// ErrUnscopedRawSQL.
 func (t *TenantDB) Raw(sql string, values ...interface{}) *TenantDB {
-	// Inject tenant_id into raw queries if needed
+	db := t.DB.Raw(sql, values...)
+	if err := t.checkRaw(sql); err != nil {
+		db.AddError(err)
+	}
 	return &TenantDB{
-		DB:          t.DB.Raw(sql, values...),
+		DB:          db,
 		tenantID:    t.tenantID,
 		skipScoping: t.skipScoping,
 	}
 }
 
-// Exec executes raw SQL
+// Exec executes raw SQL, under the same tenant_id rule as Raw
 func (t *TenantDB) Exec(sql string, values ...interface{}) *gorm.DB {
+	if err := t.checkRaw(sql); err != nil {
+		db := t.DB.Session(&gorm.Session{NewDB: true})
+		db.AddError(err)
+		return db
+	}
 	return t.DB.Exec(sql, values...)
 }
 
+func (t *TenantDB) checkRaw(sql string) error {
+	if t.skipScoping {
+		return nil
+	}
+	if tables := UnscopedTables(sql); len(tables) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnscopedRawSQL, strings.Join(tables, ", "))
+	}
+	return nil
+}
+
 // ============================================
 // INTERNAL HELPERS
 // ============================================
 
-// scope adds tenant scope to the query
-func (t *TenantDB) scope() *gorm.DB {
-	if t.skipScoping || t.tenantID == uuid.Nil {
+// scope adds tenant scope to the query. The condition is qualified with the
+// current table (or its alias) so it stays unambiguous in joins, and is only
+// added when the target table carries a tenant_id column.
+func (t *TenantDB) scope(values ...interface{}) *gorm.DB {
+	if t.skipScoping || t.tenantID == uuid.Nil || !t.hasTenantColumn(values...) {
 		return t.DB
 	}
-	return t.DB.Where("tenant_id = ?", t.tenantID)
+	return t.DB.Where(clause.Eq{
+		Column: clause.Column{Table: clause.CurrentTable, Name: "tenant_id"},
+		Value:  t.tenantID,
+	})
+}
+
+var tenantSchemaCache sync.Map
+
+// hasTenantColumn checks the statement's table, model or destination for tenant_id
+func (t *TenantDB) hasTenantColumn(values ...interface{}) bool {
+	stmt := t.DB.Statement
+	if stmt.TableExpr != nil {
+		return IsTenantTable(baseTableName(stmt.TableExpr.SQL))
+	}
+
+	for _, value := range append([]interface{}{stmt.Model}, values...) {
+		if value == nil {
+			continue
+		}
+		s, err := schema.Parse(value, &tenantSchemaCache, t.DB.NamingStrategy)
+		if err != nil {
+			continue
+		}
+		_, ok := s.FieldsByDBName["tenant_id"]
+		return ok
+	}
+
+	return stmt.Table != "" && IsTenantTable(stmt.Table)
+}
+
+// baseTableName extracts the table from a table expression ("clicks c", "\"offers\"")
+func baseTableName(expr string) string {
+	fields := strings.Fields(expr)
+	if len(fields) == 0 {
+		return ""
+	}
+	name := strings.Trim(fields[0], "\"`")
+	if idx := strings.LastIndex(name, "."); idx != -1 {
+		name = strings.Trim(name[idx+1:], "\"`")
+	}
+	return name
 }
 
-// setTenantID sets tenant ID on the value if it implements TenantScoped
+// setTenantID sets tenant ID on the value (or each element of a slice) if it
+// implements TenantScoped
 func (t *TenantDB) setTenantID(value interface{}) {
 	if t.skipScoping || t.tenantID == uuid.Nil {
 		return
 	}
 
-	// Try to set tenant_id via interface
 	if scoped, ok := value.(models.TenantScoped); ok {
 		scoped.SetTenantID(t.tenantID)
 		return
 	}
 
-	// Try reflection for struct with TenantID field
-	// This is handled by GORM hooks instead
+	rv := reflect.Indirect(reflect.ValueOf(value))
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return
+	}
+	for i := 0; i < rv.Len(); i++ {
+		elem := rv.Index(i)
+		if elem.Kind() != reflect.Ptr && elem.CanAddr() {
+			elem = elem.Addr()
+		}
+		if scoped, ok := elem.Interface().(models.TenantScoped); ok {
+			scoped.SetTenantID(t.tenantID)
+		}
+	}
 }
 
 // GetTenantID returns the tenant ID
@@ -414,24 +608,15 @@ func BackfillTenantID(db *gorm.DB, tableName string, defaultTenantID uuid.UUID)
 
 // MigrateTenantColumns migrates all tenant-scoped tables
 func MigrateTenantColumns(db *gorm.DB) error {
-	tables := []string{
-		"afftok_users",
-		"offers",
-		"networks",
-		"user_offers",
-		"clicks",
-		"conversions",
-		"teams",
-		"team_members",
-		"advertiser_api_keys",
+	tables := append([]string{}, TenantTables...)
+	tables = append(tables,
 		"api_key_usage_logs",
-		"geo_rules",
 		"webhook_pipelines",
 		"webhook_steps",
 		"webhook_executions",
 		"webhook_step_results",
 		"webhook_dlq_items",
-	}
+	)
 
 	for _, table := range tables {
 		if err := AddTenantIDColumn(db, table); err != nil {
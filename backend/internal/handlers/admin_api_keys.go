@@ -175,6 +175,11 @@ func (h *AdminAPIKeysHandler) CreateAPIKey(c *gin.Context) {
 	}
 
 	response, err := h.apiKeyService.GenerateAPIKey(advertiserID, &req)
+	if body, ok := planLimitResponse(err); ok {
+		body["correlation_id"] = correlationID
+		c.JSON(http.StatusForbidden, body)
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success":        false,
@@ -3,17 +3,22 @@ package handlers
 import (
 	"context"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/aljapah/afftok-backend-prod/internal/cache"
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
 	"github.com/aljapah/afftok-backend-prod/internal/services"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 // AdminFraudHandler handles admin fraud API endpoints
 type AdminFraudHandler struct {
 	observability   *services.ObservabilityService
 	securityService *services.SecurityService
+	anomalyService  *services.ConversionAnomalyService
 }
 
 // NewAdminFraudHandler creates a new admin fraud handler
@@ -24,6 +29,11 @@ func NewAdminFraudHandler() *AdminFraudHandler {
 	}
 }
 
+// SetConversionAnomalyService sets the conversion anomaly service (for dependency injection)
+func (h *AdminFraudHandler) SetConversionAnomalyService(service *services.ConversionAnomalyService) {
+	h.anomalyService = service
+}
+
 // FraudInsightsResponse represents fraud insights response
 type FraudInsightsResponse struct {
 	CorrelationID    string              `json:"correlation_id"`
@@ -33,6 +43,7 @@ type FraudInsightsResponse struct {
 	RecentAttempts   []services.LogEvent `json:"recent_attempts"`
 	HourlyHistogram  map[string]int64    `json:"hourly_histogram"`
 	RiskIndicators   []RiskIndicator     `json:"risk_indicators"`
+	ConversionAnomalies []models.ConversionAnomaly `json:"conversion_anomalies"`
 }
 
 // FraudSummary represents fraud summary
@@ -65,11 +76,31 @@ type RiskIndicator struct {
 	Severity    string `json:"severity"`
 }
 
-// GetFraudInsights returns comprehensive fraud intelligence
+// GetFraudInsights returns comprehensive fraud intelligence. Traffic metrics
+// are platform-wide and only shown to super admins; tenant admins get their
+// tenant's conversion anomalies.
 // GET /api/admin/fraud/insights
 func (h *AdminFraudHandler) GetFraudInsights(c *gin.Context) {
 	correlationID := generateCorrelationID()
 
+	if !middleware.IsSuperAdmin(c) {
+		anomalies, indicators := h.conversionAnomalyInsights(c)
+		c.JSON(http.StatusOK, gin.H{
+			"success":        true,
+			"correlation_id": correlationID,
+			"data": FraudInsightsResponse{
+				CorrelationID:       correlationID,
+				Timestamp:           time.Now().UTC().Format(time.RFC3339),
+				TopRiskyIPs:         []RiskyIPInfo{},
+				RecentAttempts:      []services.LogEvent{},
+				HourlyHistogram:     map[string]int64{},
+				RiskIndicators:      indicators,
+				ConversionAnomalies: anomalies,
+			},
+		})
+		return
+	}
+
 	metrics := h.observability.GetMetrics()
 	fraudInsights := h.observability.GetFraudInsights()
 	fraudLogs := h.observability.GetFraudLogs(50)
@@ -148,14 +179,18 @@ func (h *AdminFraudHandler) GetFraudInsights(c *gin.Context) {
 		},
 	}
 
+	anomalies, anomalyIndicators := h.conversionAnomalyInsights(c)
+	indicators = append(indicators, anomalyIndicators...)
+
 	response := FraudInsightsResponse{
-		CorrelationID:   correlationID,
-		Timestamp:       time.Now().UTC().Format(time.RFC3339),
-		Summary:         summary,
-		TopRiskyIPs:     riskyIPs,
-		RecentAttempts:  fraudLogs,
-		HourlyHistogram: hourlyHistogram,
-		RiskIndicators:  indicators,
+		CorrelationID:       correlationID,
+		Timestamp:           time.Now().UTC().Format(time.RFC3339),
+		Summary:             summary,
+		TopRiskyIPs:         riskyIPs,
+		RecentAttempts:      fraudLogs,
+		HourlyHistogram:     hourlyHistogram,
+		RiskIndicators:      indicators,
+		ConversionAnomalies: anomalies,
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -165,6 +200,28 @@ func (h *AdminFraudHandler) GetFraudInsights(c *gin.Context) {
 	})
 }
 
+// conversionAnomalyInsights returns the tenant's open promoter×offer
+// conversion anomalies and the held conversions indicator
+func (h *AdminFraudHandler) conversionAnomalyInsights(c *gin.Context) ([]models.ConversionAnomaly, []RiskIndicator) {
+	anomalies := make([]models.ConversionAnomaly, 0)
+	indicators := make([]RiskIndicator, 0)
+	if h.anomalyService == nil {
+		return anomalies, indicators
+	}
+	tenantID := middleware.GetTenantID(c)
+	if open, err := h.anomalyService.ListAnomalies(tenantID, models.AnomalyStatusOpen, 50); err == nil {
+		anomalies = open
+	}
+	held := h.anomalyService.CountHeldConversions(tenantID)
+	indicators = append(indicators, RiskIndicator{
+		Name:        "conversion_anomalies",
+		Description: "Conversions held for review after a promoter deviated from baseline",
+		Count:       held,
+		Severity:    getSeverity(held, 10, 100),
+	})
+	return anomalies, indicators
+}
+
 // getHourlyFraudHistogram returns fraud attempts by hour
 func (h *AdminFraudHandler) getHourlyFraudHistogram() map[string]int64 {
 	histogram := make(map[string]int64)
@@ -341,3 +398,103 @@ func (h *AdminFraudHandler) GetBlockedIPs(c *gin.Context) {
 	})
 }
 
+
+// GetConversionAnomalies lists promoter×offer conversion anomalies
+// GET /api/admin/fraud/anomalies?status=open
+func (h *AdminFraudHandler) GetConversionAnomalies(c *gin.Context) {
+	correlationID := generateCorrelationID()
+
+	if h.anomalyService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Anomaly detection not configured",
+		})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	anomalies, err := h.anomalyService.ListAnomalies(middleware.GetTenantID(c), c.Query("status"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Failed to load anomalies",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data": map[string]interface{}{
+			"anomalies":       anomalies,
+			"count":           len(anomalies),
+			"held_conversions": h.anomalyService.CountHeldConversions(middleware.GetTenantID(c)),
+		},
+	})
+}
+
+// ResolveConversionAnomaly releases or rejects the conversions held by an anomaly
+// POST /api/admin/fraud/anomalies/:id/resolve
+func (h *AdminFraudHandler) ResolveConversionAnomaly(c *gin.Context) {
+	correlationID := generateCorrelationID()
+
+	if h.anomalyService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Anomaly detection not configured",
+		})
+		return
+	}
+
+	anomalyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid anomaly ID",
+		})
+		return
+	}
+
+	var req struct {
+		Decision string `json:"decision" binding:"required"` // released | rejected
+		Notes    string `json:"notes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid request",
+		})
+		return
+	}
+
+	var resolvedBy *uuid.UUID
+	if id, ok := c.Get("userID"); ok {
+		if adminID, ok := id.(uuid.UUID); ok {
+			resolvedBy = &adminID
+		}
+	}
+
+	anomaly, affected, err := h.anomalyService.ResolveAnomaly(middleware.GetTenantID(c), anomalyID, req.Decision, resolvedBy, req.Notes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data": map[string]interface{}{
+			"anomaly":              anomaly,
+			"conversions_affected": affected,
+		},
+	})
+}
@@ -0,0 +1,428 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ============================================
+// ADMIN FRAUD CASES HANDLER
+// ============================================
+
+// AdminFraudCasesHandler handles fraud case management endpoints
+type AdminFraudCasesHandler struct {
+	caseService *services.FraudCaseService
+}
+
+// NewAdminFraudCasesHandler creates a new admin fraud cases handler
+func NewAdminFraudCasesHandler(caseService *services.FraudCaseService) *AdminFraudCasesHandler {
+	return &AdminFraudCasesHandler{
+		caseService: caseService,
+	}
+}
+
+// adminIDFromContext returns the authenticated admin's ID, if any
+func adminIDFromContext(c *gin.Context) *uuid.UUID {
+	if id, ok := c.Get("userID"); ok {
+		if adminID, ok := id.(uuid.UUID); ok {
+			return &adminID
+		}
+	}
+	return nil
+}
+
+// fraudCaseErrorStatus maps fraud case errors to an HTTP status
+func fraudCaseErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrFraudCaseNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrFraudCaseClosed):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// parseFraudCaseID parses the :id param, writing the error response if invalid
+func parseFraudCaseID(c *gin.Context, correlationID string) (uuid.UUID, bool) {
+	caseID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid case ID",
+		})
+		return uuid.Nil, false
+	}
+	return caseID, true
+}
+
+// ============================================
+// LIST / GET
+// ============================================
+
+// ListCases returns fraud cases
+// GET /api/admin/fraud/cases?status=open&source=threat&assignee_id=...&user_id=...&limit=50&offset=0
+func (h *AdminFraudCasesHandler) ListCases(c *gin.Context) {
+	correlationID := generateCorrelationID()
+
+	filter := services.FraudCaseFilter{
+		TenantID: middleware.GetTenantID(c),
+		Status:   c.Query("status"),
+		Source:   c.Query("source"),
+	}
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	filter.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if id, err := uuid.Parse(c.Query("assignee_id")); err == nil {
+		filter.AssigneeID = &id
+	}
+	if id, err := uuid.Parse(c.Query("user_id")); err == nil {
+		filter.UserID = &id
+	}
+
+	cases, total, err := h.caseService.ListCases(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Failed to fetch fraud cases",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data": gin.H{
+			"cases":        cases,
+			"total":        total,
+			"active_count": h.caseService.CountActiveCases(filter.TenantID),
+		},
+		"timestamp": time.Now().UTC(),
+	})
+}
+
+// GetCase returns a fraud case with its links, notes and adjustments
+// GET /api/admin/fraud/cases/:id
+func (h *AdminFraudCasesHandler) GetCase(c *gin.Context) {
+	correlationID := generateCorrelationID()
+
+	caseID, ok := parseFraudCaseID(c, correlationID)
+	if !ok {
+		return
+	}
+
+	fraudCase, err := h.caseService.GetCase(middleware.GetTenantID(c), caseID)
+	if err != nil {
+		c.JSON(fraudCaseErrorStatus(err), gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
+	adjustments, _ := h.caseService.GetCaseAdjustments(middleware.GetTenantID(c), caseID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data": gin.H{
+			"case":        fraudCase,
+			"adjustments": adjustments,
+		},
+		"timestamp": time.Now().UTC(),
+	})
+}
+
+// ============================================
+// CASE WORK
+// ============================================
+
+// CreateCase opens a case manually
+// POST /api/admin/fraud/cases
+func (h *AdminFraudCasesHandler) CreateCase(c *gin.Context) {
+	correlationID := generateCorrelationID()
+
+	var req struct {
+		UserID   string                  `json:"user_id"`
+		Title    string                  `json:"title" binding:"required"`
+		Summary  string                  `json:"summary"`
+		Severity string                  `json:"severity"`
+		Links    services.FraudCaseLinks `json:"links"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid request",
+		})
+		return
+	}
+
+	openReq := &services.OpenFraudCaseRequest{
+		Source:    models.FraudCaseSourceManual,
+		Severity:  req.Severity,
+		Title:     req.Title,
+		Summary:   req.Summary,
+		Links:     req.Links,
+		CreatedBy: adminIDFromContext(c),
+	}
+	if req.UserID != "" {
+		userID, err := uuid.Parse(req.UserID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success":        false,
+				"correlation_id": correlationID,
+				"error":          "Invalid user ID",
+			})
+			return
+		}
+		openReq.UserID = &userID
+	}
+	if openReq.UserID == nil && req.Links.IsEmpty() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "A case needs a user or at least one linked click, conversion or IP",
+		})
+		return
+	}
+
+	fraudCase, created, err := h.caseService.OpenCase(openReq)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Failed to open case: " + err.Error(),
+		})
+		return
+	}
+
+	status := http.StatusCreated
+	if !created {
+		status = http.StatusOK
+	}
+	c.JSON(status, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data": gin.H{
+			"case":   fraudCase,
+			"merged": !created, // the signal was added to an existing active case
+		},
+	})
+}
+
+// UpdateCase changes the status (open/investigating) or assignee of a case
+// PUT /api/admin/fraud/cases/:id
+func (h *AdminFraudCasesHandler) UpdateCase(c *gin.Context) {
+	correlationID := generateCorrelationID()
+
+	caseID, ok := parseFraudCaseID(c, correlationID)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Status     string  `json:"status"`
+		AssigneeID *string `json:"assignee_id"` // "" to unassign
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid request",
+		})
+		return
+	}
+
+	if req.AssigneeID != nil {
+		var assignee *uuid.UUID
+		if *req.AssigneeID != "" {
+			id, err := uuid.Parse(*req.AssigneeID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"success":        false,
+					"correlation_id": correlationID,
+					"error":          "Invalid assignee ID",
+				})
+				return
+			}
+			assignee = &id
+		}
+		if _, err := h.caseService.Assign(middleware.GetTenantID(c), caseID, assignee, adminIDFromContext(c)); err != nil {
+			c.JSON(fraudCaseErrorStatus(err), gin.H{
+				"success":        false,
+				"correlation_id": correlationID,
+				"error":          err.Error(),
+			})
+			return
+		}
+	}
+
+	if req.Status != "" {
+		if err := h.caseService.SetStatus(middleware.GetTenantID(c), caseID, req.Status); err != nil {
+			c.JSON(fraudCaseErrorStatus(err), gin.H{
+				"success":        false,
+				"correlation_id": correlationID,
+				"error":          err.Error(),
+			})
+			return
+		}
+	}
+
+	fraudCase, err := h.caseService.GetCase(middleware.GetTenantID(c), caseID)
+	if err != nil {
+		c.JSON(fraudCaseErrorStatus(err), gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           fraudCase,
+	})
+}
+
+// AddNote adds an investigation note
+// POST /api/admin/fraud/cases/:id/notes
+func (h *AdminFraudCasesHandler) AddNote(c *gin.Context) {
+	correlationID := generateCorrelationID()
+
+	caseID, ok := parseFraudCaseID(c, correlationID)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Body string `json:"body" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid request",
+		})
+		return
+	}
+
+	note, err := h.caseService.AddNote(middleware.GetTenantID(c), caseID, adminIDFromContext(c), req.Body)
+	if err != nil {
+		c.JSON(fraudCaseErrorStatus(err), gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           note,
+	})
+}
+
+// AddLinks links clicks, conversions and IPs to a case
+// POST /api/admin/fraud/cases/:id/links
+func (h *AdminFraudCasesHandler) AddLinks(c *gin.Context) {
+	correlationID := generateCorrelationID()
+
+	caseID, ok := parseFraudCaseID(c, correlationID)
+	if !ok {
+		return
+	}
+
+	var links services.FraudCaseLinks
+	if err := c.ShouldBindJSON(&links); err != nil || links.IsEmpty() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Provide click_ids, conversion_ids or ips",
+		})
+		return
+	}
+
+	added, err := h.caseService.AddLinks(middleware.GetTenantID(c), caseID, links)
+	if err != nil {
+		c.JSON(fraudCaseErrorStatus(err), gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data": gin.H{
+			"added": added,
+		},
+	})
+}
+
+// ============================================
+// DECISION
+// ============================================
+
+// DecideCase closes a case and applies the decision's actions
+// POST /api/admin/fraud/cases/:id/decision
+func (h *AdminFraudCasesHandler) DecideCase(c *gin.Context) {
+	correlationID := generateCorrelationID()
+
+	caseID, ok := parseFraudCaseID(c, correlationID)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Decision      string `json:"decision" binding:"required"` // clear | clawback | ban
+		Notes         string `json:"notes"`
+		BlockDuration int    `json:"block_duration_hours"` // 0 = permanent
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid request",
+		})
+		return
+	}
+
+	fraudCase, summary, err := h.caseService.Decide(middleware.GetTenantID(c), caseID, req.Decision, adminIDFromContext(c), req.Notes,
+		time.Duration(req.BlockDuration)*time.Hour)
+	if err != nil {
+		status := fraudCaseErrorStatus(err)
+		if !errors.Is(err, services.ErrFraudCaseDecision) && status == http.StatusBadRequest {
+			status = http.StatusInternalServerError
+		}
+		c.JSON(status, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data": gin.H{
+			"case":    fraudCase,
+			"actions": summary,
+		},
+	})
+}
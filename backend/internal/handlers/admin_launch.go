@@ -721,7 +721,7 @@ func (h *AdminLaunchHandler) checkDBLatency() int {
 func (h *AdminLaunchHandler) checkDBReadLatency() int {
 	start := time.Now()
 	var count int64
-	if err := h.db.Raw("SELECT COUNT(*) FROM offers LIMIT 1").Scan(&count).Error; err != nil {
+	if err := h.db.Raw("SELECT COUNT(*) FROM tenants").Scan(&count).Error; err != nil {
 		return -1
 	}
 	return int(time.Since(start).Milliseconds())
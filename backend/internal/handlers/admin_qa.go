@@ -210,6 +210,11 @@ func (h *AdminQAHandler) FixConsistencyIssues(c *gin.Context) {
 	correlationID := uuid.New().String()[:8]
 
 	fixed, err := h.consistencyService.FixUserOfferStats()
+	if err == nil {
+		var ledgerFixed int64
+		ledgerFixed, err = h.consistencyService.FixLedger()
+		fixed += ledgerFixed
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success":        false,
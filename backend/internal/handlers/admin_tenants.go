@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -19,8 +21,10 @@ import (
 
 // AdminTenantsHandler handles tenant management endpoints
 type AdminTenantsHandler struct {
-	db            *gorm.DB
-	tenantService *services.TenantService
+	db                 *gorm.DB
+	tenantService      *services.TenantService
+	certificateService *services.CertificateService
+	billingService     *services.BillingService
 }
 
 // NewAdminTenantsHandler creates a new admin tenants handler
@@ -31,6 +35,16 @@ func NewAdminTenantsHandler(db *gorm.DB) *AdminTenantsHandler {
 	}
 }
 
+// SetBillingService routes plan changes through billing (proration)
+func (h *AdminTenantsHandler) SetBillingService(service *services.BillingService) {
+	h.billingService = service
+}
+
+// SetCertificateService sets the ACME certificate service (custom domain TLS)
+func (h *AdminTenantsHandler) SetCertificateService(service *services.CertificateService) {
+	h.certificateService = service
+}
+
 // ============================================
 // TENANT CRUD
 // ============================================
@@ -165,6 +179,7 @@ func (h *AdminTenantsHandler) CreateTenant(c *gin.Context) {
 		})
 		return
 	}
+	h.registerCustomDomain(tenant.ID, req.CustomDomain)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"success":        true,
@@ -239,6 +254,7 @@ func (h *AdminTenantsHandler) UpdateTenant(c *gin.Context) {
 	if req.SecondaryColor != nil {
 		tenant.SecondaryColor = *req.SecondaryColor
 	}
+	customDomainChanged := req.CustomDomain != nil && *req.CustomDomain != tenant.CustomDomain
 	if req.CustomDomain != nil {
 		tenant.CustomDomain = *req.CustomDomain
 	}
@@ -254,6 +270,9 @@ func (h *AdminTenantsHandler) UpdateTenant(c *gin.Context) {
 		})
 		return
 	}
+	if customDomainChanged {
+		h.registerCustomDomain(tenant.ID, tenant.CustomDomain)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":        true,
@@ -430,6 +449,46 @@ func (h *AdminTenantsHandler) GetTenantStats(c *gin.Context) {
 // TENANT DOMAINS
 // ============================================
 
+// registerCustomDomain adds the tenant's custom_domain as an unverified
+// primary domain. It only routes to the tenant once verified.
+func (h *AdminTenantsHandler) registerCustomDomain(tenantID uuid.UUID, domain string) {
+	if domain == "" {
+		return
+	}
+	if _, err := h.tenantService.AddDomain(tenantID, domain, true); err != nil {
+		log.Printf("[Tenants] custom domain %q not registered for %s: %v", domain, tenantID, err)
+	}
+}
+
+// domainErrorStatus maps domain errors to an HTTP status
+func domainErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrDomainInvalid), errors.Is(err, services.ErrDomainReserved):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrDomainNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrDomainVerificationFailed):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, services.ErrDomainTaken):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// tenantDomainView is a domain with the DNS records that verify it
+type tenantDomainView struct {
+	models.TenantDomain
+	Instructions services.DomainVerificationInstructions `json:"instructions"`
+}
+
+func (h *AdminTenantsHandler) domainView(d *models.TenantDomain) tenantDomainView {
+	return tenantDomainView{
+		TenantDomain: *d,
+		Instructions: services.GetDomainVerificationService(h.db).Instructions(d),
+	}
+}
+
 // GetTenantDomains returns domains for a tenant
 // GET /api/admin/tenants/:id/domains
 func (h *AdminTenantsHandler) GetTenantDomains(c *gin.Context) {
@@ -455,15 +514,20 @@ func (h *AdminTenantsHandler) GetTenantDomains(c *gin.Context) {
 		return
 	}
 
+	views := make([]tenantDomainView, 0, len(domains))
+	for i := range domains {
+		views = append(views, h.domainView(&domains[i]))
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success":        true,
 		"correlation_id": correlationID,
-		"data":           domains,
+		"data":           views,
 		"timestamp":      time.Now().UTC(),
 	})
 }
 
-// AddTenantDomain adds a domain to a tenant
+// AddTenantDomain adds a domain to a tenant and returns its verification records
 // POST /api/admin/tenants/:id/domains
 func (h *AdminTenantsHandler) AddTenantDomain(c *gin.Context) {
 	correlationID := uuid.New().String()[:8]
@@ -492,8 +556,9 @@ func (h *AdminTenantsHandler) AddTenantDomain(c *gin.Context) {
 		return
 	}
 
-	if err := h.tenantService.AddDomain(tenantID, req.Domain, req.IsPrimary); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	domain, err := h.tenantService.AddDomain(tenantID, req.Domain, req.IsPrimary)
+	if err != nil {
+		c.JSON(domainErrorStatus(err), gin.H{
 			"success":        false,
 			"correlation_id": correlationID,
 			"error":          "Failed to add domain: " + err.Error(),
@@ -504,7 +569,111 @@ func (h *AdminTenantsHandler) AddTenantDomain(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{
 		"success":        true,
 		"correlation_id": correlationID,
-		"message":        "Domain added successfully",
+		"data":           h.domainView(domain),
+		"message":        "Domain added. Create one of the DNS records in instructions, then verify it.",
+		"timestamp":      time.Now().UTC(),
+	})
+}
+
+// VerifyTenantDomain checks the domain's DNS records for proof of ownership
+// POST /api/admin/tenants/:id/domains/:domain/verify
+func (h *AdminTenantsHandler) VerifyTenantDomain(c *gin.Context) {
+	correlationID := uuid.New().String()[:8]
+
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid tenant ID",
+		})
+		return
+	}
+
+	domain, err := h.tenantService.VerifyDomain(tenantID, c.Param("domain"))
+	if err != nil {
+		response := gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		}
+		if domain != nil {
+			response["data"] = h.domainView(domain)
+		}
+		c.JSON(domainErrorStatus(err), response)
+		return
+	}
+
+	// Request the certificate in the background: issuance can take a while
+	if h.certificateService != nil {
+		go h.certificateService.EnsureCertificate(domain.Domain)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           h.domainView(domain),
+		"message":        "Domain verified",
+		"timestamp":      time.Now().UTC(),
+	})
+}
+
+// RequestTenantDomainCertificate obtains (or renews) the TLS certificate of a verified domain
+// POST /api/admin/tenants/:id/domains/:domain/certificate
+func (h *AdminTenantsHandler) RequestTenantDomainCertificate(c *gin.Context) {
+	correlationID := uuid.New().String()[:8]
+
+	if h.certificateService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Automatic TLS is not enabled (ACME_ENABLED)",
+		})
+		return
+	}
+
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid tenant ID",
+		})
+		return
+	}
+
+	var domain models.TenantDomain
+	if err := h.db.Where("tenant_id = ? AND domain = ?", tenantID, c.Param("domain")).First(&domain).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Domain not found",
+		})
+		return
+	}
+	if !domain.IsVerified {
+		c.JSON(http.StatusConflict, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Domain must be verified before a certificate can be issued",
+		})
+		return
+	}
+
+	if err := h.certificateService.EnsureCertificate(domain.Domain); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Certificate request failed: " + err.Error(),
+		})
+		return
+	}
+
+	h.db.First(&domain, "id = ?", domain.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           h.domainView(&domain),
 		"timestamp":      time.Now().UTC(),
 	})
 }
@@ -684,7 +853,8 @@ func (h *AdminTenantsHandler) ChangeTenantPlan(c *gin.Context) {
 	}
 
 	var req struct {
-		Plan models.TenantPlan `json:"plan" binding:"required"`
+		Plan  models.TenantPlan   `json:"plan" binding:"required"`
+		Cycle models.BillingCycle `json:"cycle"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -706,7 +876,25 @@ func (h *AdminTenantsHandler) ChangeTenantPlan(c *gin.Context) {
 		return
 	}
 
-	if err := h.tenantService.ChangePlan(tenantID, req.Plan); err != nil {
+	// Billing prorates the change and invoices the difference
+	var invoice *models.TenantInvoice
+	if h.billingService != nil {
+		_, invoice, err = h.billingService.ChangePlan(tenantID, req.Plan, req.Cycle)
+		if errors.Is(err, services.ErrBillingInvalidCycle) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success":        false,
+				"correlation_id": correlationID,
+				"error":          "Invalid cycle. Must be: monthly or annual",
+			})
+			return
+		}
+		if errors.Is(err, services.ErrBillingNoChange) {
+			err = nil
+		}
+	} else {
+		err = h.tenantService.ChangePlan(tenantID, req.Plan)
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success":        false,
 			"correlation_id": correlationID,
@@ -722,6 +910,7 @@ func (h *AdminTenantsHandler) ChangeTenantPlan(c *gin.Context) {
 		"success":        true,
 		"correlation_id": correlationID,
 		"data":           tenant,
+		"invoice":        invoice,
 		"message":        "Plan changed successfully",
 		"timestamp":      time.Now().UTC(),
 	})
@@ -779,7 +968,17 @@ func (h *AdminTenantsHandler) UpdateTenantSettings(c *gin.Context) {
 		return
 	}
 
-	var settings models.TenantSettings
+	// Fields missing from the body keep their current value
+	current, err := h.tenantService.GetSettings(tenantID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Tenant not found",
+		})
+		return
+	}
+	settings := *current
 	if err := c.ShouldBindJSON(&settings); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success":        false,
@@ -790,7 +989,11 @@ func (h *AdminTenantsHandler) UpdateTenantSettings(c *gin.Context) {
 	}
 
 	if err := h.tenantService.UpdateSettings(tenantID, &settings); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidTenantSettings) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
 			"success":        false,
 			"correlation_id": correlationID,
 			"error":          "Failed to update settings: " + err.Error(),
@@ -952,6 +1155,7 @@ func (h *AdminTenantsHandler) GetPlans(c *gin.Context) {
 			"name":     "Free",
 			"value":    string(models.TenantPlanFree),
 			"features": models.GetFeaturesForPlan(models.TenantPlanFree),
+			"limit_grace_percent": models.GetLimitGracePercentForPlan(models.TenantPlanFree),
 			"limits": func() map[string]int {
 				u, o, c, a, w := models.GetLimitsForPlan(models.TenantPlanFree)
 				return map[string]int{"users": u, "offers": o, "clicks_per_day": c, "api_keys": a, "webhooks": w}
@@ -961,6 +1165,7 @@ func (h *AdminTenantsHandler) GetPlans(c *gin.Context) {
 			"name":     "Pro",
 			"value":    string(models.TenantPlanPro),
 			"features": models.GetFeaturesForPlan(models.TenantPlanPro),
+			"limit_grace_percent": models.GetLimitGracePercentForPlan(models.TenantPlanPro),
 			"limits": func() map[string]int {
 				u, o, c, a, w := models.GetLimitsForPlan(models.TenantPlanPro)
 				return map[string]int{"users": u, "offers": o, "clicks_per_day": c, "api_keys": a, "webhooks": w}
@@ -970,6 +1175,7 @@ func (h *AdminTenantsHandler) GetPlans(c *gin.Context) {
 			"name":     "Enterprise",
 			"value":    string(models.TenantPlanEnterprise),
 			"features": models.GetFeaturesForPlan(models.TenantPlanEnterprise),
+			"limit_grace_percent": models.GetLimitGracePercentForPlan(models.TenantPlanEnterprise),
 			"limits": func() map[string]int {
 				u, o, c, a, w := models.GetLimitsForPlan(models.TenantPlanEnterprise)
 				return map[string]int{"users": u, "offers": o, "clicks_per_day": c, "api_keys": a, "webhooks": w}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
 	"github.com/aljapah/afftok-backend-prod/internal/models"
 	"github.com/aljapah/afftok-backend-prod/internal/services"
 	"github.com/gin-gonic/gin"
@@ -165,6 +166,7 @@ func (h *AdminWebhooksHandler) CreatePipeline(c *gin.Context) {
 	}
 
 	pipeline := &models.WebhookPipeline{
+		TenantModel:  models.TenantModel{TenantID: middleware.GetTenantID(c)},
 		ID:           uuid.New(),
 		Name:         req.Name,
 		Description:  req.Description,
@@ -180,6 +182,11 @@ func (h *AdminWebhooksHandler) CreatePipeline(c *gin.Context) {
 	}
 
 	if err := h.webhookService.CreatePipeline(pipeline); err != nil {
+		if body, ok := planLimitResponse(err); ok {
+			body["correlation_id"] = correlationID
+			c.JSON(http.StatusForbidden, body)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success":        false,
 			"correlation_id": correlationID,
@@ -225,6 +232,11 @@ func (h *AdminWebhooksHandler) UpdatePipeline(c *gin.Context) {
 	pipeline.ID = pipelineID
 
 	if err := h.webhookService.UpdatePipeline(&pipeline); err != nil {
+		if body, ok := planLimitResponse(err); ok {
+			body["correlation_id"] = correlationID
+			c.JSON(http.StatusForbidden, body)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success":        false,
 			"correlation_id": correlationID,
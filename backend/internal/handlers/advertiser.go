@@ -4,7 +4,9 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/aljapah/afftok-backend-prod/internal/database"
 	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
 	"github.com/aljapah/afftok-backend-prod/pkg/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -79,13 +81,19 @@ func (h *AdvertiserHandler) RegisterAdvertiser(c *gin.Context) {
 		UpdatedAt:    time.Now(),
 	}
 
-	if err := h.db.Create(&user).Error; err != nil {
+	created, err := createWithinPlanLimit(c, h.db, models.LimitResourceUsers, func(tx *database.TenantDB) error {
+		return tx.Create(&user).Error
+	})
+	if !created {
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create advertiser account"})
 		return
 	}
 
 	// Generate JWT token
-	token, err := utils.GenerateToken(user.ID, user.Username, user.Email, user.Role)
+	token, err := utils.GenerateTenantToken(user.ID, user.TenantID, user.Username, user.Email, user.Role)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -129,7 +137,7 @@ func (h *AdvertiserHandler) PauseOffer(c *gin.Context) {
 
 	// Find offer and verify ownership
 	var offer models.Offer
-	if err := h.db.First(&offer, "id = ? AND advertiser_id = ?", offerID, advertiserID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&offer, "id = ? AND advertiser_id = ?", offerID, advertiserID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found or not owned by you"})
 		return
 	}
@@ -141,7 +149,7 @@ func (h *AdvertiserHandler) PauseOffer(c *gin.Context) {
 
 	offer.Status = "paused"
 	offer.UpdatedAt = time.Now()
-	h.db.Save(&offer)
+	tenantDB(c, h.db).Save(&offer)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Offer paused successfully",
@@ -153,7 +161,7 @@ func (h *AdvertiserHandler) PauseOffer(c *gin.Context) {
 // GET /api/admin/offers/pending
 func (h *AdvertiserHandler) GetPendingOffers(c *gin.Context) {
 	var offers []models.Offer
-	if err := h.db.Preload("Advertiser").
+	if err := tenantDB(c, h.db).Preload("Advertiser").
 		Where("status = ?", "pending").
 		Order("created_at DESC").
 		Find(&offers).Error; err != nil {
@@ -178,7 +186,7 @@ func (h *AdvertiserHandler) ApproveOffer(c *gin.Context) {
 	}
 
 	var offer models.Offer
-	if err := h.db.First(&offer, "id = ?", offerID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&offer, "id = ?", offerID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
 		return
 	}
@@ -191,7 +199,7 @@ func (h *AdvertiserHandler) ApproveOffer(c *gin.Context) {
 	offer.Status = "active"
 	offer.RejectionReason = ""
 	offer.UpdatedAt = time.Now()
-	h.db.Save(&offer)
+	tenantDB(c, h.db).Save(&offer)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Offer approved successfully",
@@ -218,7 +226,7 @@ func (h *AdvertiserHandler) RejectOffer(c *gin.Context) {
 	c.ShouldBindJSON(&req)
 
 	var offer models.Offer
-	if err := h.db.First(&offer, "id = ?", offerID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&offer, "id = ?", offerID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
 		return
 	}
@@ -231,7 +239,7 @@ func (h *AdvertiserHandler) RejectOffer(c *gin.Context) {
 	offer.Status = "rejected"
 	offer.RejectionReason = req.Reason
 	offer.UpdatedAt = time.Now()
-	h.db.Save(&offer)
+	tenantDB(c, h.db).Save(&offer)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Offer rejected",
@@ -275,14 +283,14 @@ func (h *AdvertiserHandler) CreateOffer(c *gin.Context) {
 
 	// Verify user is an advertiser
 	var user models.AfftokUser
-	if err := h.db.First(&user, "id = ?", advertiserID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&user, "id = ?", advertiserID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
 	// Auto-fix: If user has company_name but role is not advertiser, update it
 	if user.CompanyName != "" && user.Role != "advertiser" {
-		h.db.Model(&user).Update("role", "advertiser")
+		tenantDB(c, h.db).Model(&user).Update("role", "advertiser")
 		user.Role = "advertiser"
 	}
 
@@ -335,7 +343,13 @@ func (h *AdvertiserHandler) CreateOffer(c *gin.Context) {
 		UpdatedAt:       time.Now(),
 	}
 
-	if err := h.db.Create(&offer).Error; err != nil {
+	created, err := createWithinPlanLimit(c, h.db, models.LimitResourceOffers, func(tx *database.TenantDB) error {
+		return tx.Create(&offer).Error
+	})
+	if !created {
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create offer"})
 		return
 	}
@@ -362,7 +376,7 @@ func (h *AdvertiserHandler) GetMyOffers(c *gin.Context) {
 	}
 
 	var offers []models.Offer
-	if err := h.db.Where("advertiser_id = ?", advertiserID).
+	if err := tenantDB(c, h.db).Where("advertiser_id = ?", advertiserID).
 		Order("created_at DESC").
 		Find(&offers).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch offers"})
@@ -378,7 +392,7 @@ func (h *AdvertiserHandler) GetMyOffers(c *gin.Context) {
 	var offersWithStats []OfferWithStats
 	for _, offer := range offers {
 		var promotersCount int64
-		h.db.Model(&models.UserOffer{}).Where("offer_id = ?", offer.ID).Count(&promotersCount)
+		tenantDB(c, h.db).Model(&models.UserOffer{}).Where("offer_id = ?", offer.ID).Count(&promotersCount)
 
 		offersWithStats = append(offersWithStats, OfferWithStats{
 			Offer:          offer,
@@ -416,26 +430,26 @@ func (h *AdvertiserHandler) GetOfferStats(c *gin.Context) {
 
 	// Verify ownership
 	var offer models.Offer
-	if err := h.db.First(&offer, "id = ? AND advertiser_id = ?", offerID, advertiserID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&offer, "id = ? AND advertiser_id = ?", offerID, advertiserID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found or not owned by you"})
 		return
 	}
 
 	// Get promoters count
 	var promotersCount int64
-	h.db.Model(&models.UserOffer{}).Where("offer_id = ?", offerID).Count(&promotersCount)
+	tenantDB(c, h.db).Model(&models.UserOffer{}).Where("offer_id = ?", offerID).Count(&promotersCount)
 
-	// Get today's stats
-	today := time.Now().Truncate(24 * time.Hour)
+	// Get today's stats (today in the tenant's timezone)
+	today := services.StartOfDayIn(time.Now(), tenantLocation(c, h.db))
 	var todayClicks int64
 	var todayConversions int64
 
-	h.db.Model(&models.Click{}).
+	tenantDB(c, h.db).Model(&models.Click{}).
 		Joins("JOIN user_offers ON clicks.user_offer_id = user_offers.id").
 		Where("user_offers.offer_id = ? AND clicks.clicked_at >= ?", offerID, today).
 		Count(&todayClicks)
 
-	h.db.Model(&models.Conversion{}).
+	tenantDB(c, h.db).Model(&models.Conversion{}).
 		Joins("JOIN user_offers ON conversions.user_offer_id = user_offers.id").
 		Where("user_offers.offer_id = ? AND conversions.converted_at >= ?", offerID, today).
 		Count(&todayConversions)
@@ -445,12 +459,12 @@ func (h *AdvertiserHandler) GetOfferStats(c *gin.Context) {
 	var weeklyClicks int64
 	var weeklyConversions int64
 
-	h.db.Model(&models.Click{}).
+	tenantDB(c, h.db).Model(&models.Click{}).
 		Joins("JOIN user_offers ON clicks.user_offer_id = user_offers.id").
 		Where("user_offers.offer_id = ? AND clicks.clicked_at >= ?", offerID, weekAgo).
 		Count(&weeklyClicks)
 
-	h.db.Model(&models.Conversion{}).
+	tenantDB(c, h.db).Model(&models.Conversion{}).
 		Joins("JOIN user_offers ON conversions.user_offer_id = user_offers.id").
 		Where("user_offers.offer_id = ? AND conversions.converted_at >= ?", offerID, weekAgo).
 		Count(&weeklyConversions)
@@ -492,7 +506,7 @@ func (h *AdvertiserHandler) UpdateOffer(c *gin.Context) {
 
 	// Find offer and verify ownership
 	var offer models.Offer
-	if err := h.db.First(&offer, "id = ? AND advertiser_id = ?", offerID, advertiserID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&offer, "id = ? AND advertiser_id = ?", offerID, advertiserID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found or not owned by you"})
 		return
 	}
@@ -543,13 +557,13 @@ func (h *AdvertiserHandler) UpdateOffer(c *gin.Context) {
 		updates["exclusive_team_id"] = nil
 	}
 
-	if err := h.db.Model(&offer).Updates(updates).Error; err != nil {
+	if err := tenantDB(c, h.db).Model(&offer).Updates(updates).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update offer"})
 		return
 	}
 
 	// Reload offer
-	h.db.First(&offer, offerID)
+	tenantDB(c, h.db).First(&offer, offerID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Offer updated and resubmitted for approval",
@@ -581,7 +595,7 @@ func (h *AdvertiserHandler) DeleteOffer(c *gin.Context) {
 
 	// Find offer and verify ownership
 	var offer models.Offer
-	if err := h.db.First(&offer, "id = ? AND advertiser_id = ?", offerID, advertiserID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&offer, "id = ? AND advertiser_id = ?", offerID, advertiserID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found or not owned by you"})
 		return
 	}
@@ -592,7 +606,7 @@ func (h *AdvertiserHandler) DeleteOffer(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Delete(&offer).Error; err != nil {
+	if err := tenantDB(c, h.db).Delete(&offer).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete offer"})
 		return
 	}
@@ -619,14 +633,14 @@ func (h *AdvertiserHandler) GetDashboard(c *gin.Context) {
 
 	// Verify user is an advertiser
 	var user models.AfftokUser
-	if err := h.db.First(&user, "id = ?", advertiserID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&user, "id = ?", advertiserID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
 	// Auto-fix: If user has company_name but role is not advertiser, update it
 	if user.CompanyName != "" && user.Role != "advertiser" {
-		h.db.Model(&user).Update("role", "advertiser")
+		tenantDB(c, h.db).Model(&user).Update("role", "advertiser")
 		user.Role = "advertiser"
 	}
 
@@ -636,21 +650,21 @@ func (h *AdvertiserHandler) GetDashboard(c *gin.Context) {
 	var activeOffers int64
 	var rejectedOffers int64
 
-	h.db.Model(&models.Offer{}).Where("advertiser_id = ?", advertiserID).Count(&totalOffers)
-	h.db.Model(&models.Offer{}).Where("advertiser_id = ? AND status = ?", advertiserID, "pending").Count(&pendingOffers)
-	h.db.Model(&models.Offer{}).Where("advertiser_id = ? AND status = ?", advertiserID, "active").Count(&activeOffers)
-	h.db.Model(&models.Offer{}).Where("advertiser_id = ? AND status = ?", advertiserID, "rejected").Count(&rejectedOffers)
+	tenantDB(c, h.db).Model(&models.Offer{}).Where("advertiser_id = ?", advertiserID).Count(&totalOffers)
+	tenantDB(c, h.db).Model(&models.Offer{}).Where("advertiser_id = ? AND status = ?", advertiserID, "pending").Count(&pendingOffers)
+	tenantDB(c, h.db).Model(&models.Offer{}).Where("advertiser_id = ? AND status = ?", advertiserID, "active").Count(&activeOffers)
+	tenantDB(c, h.db).Model(&models.Offer{}).Where("advertiser_id = ? AND status = ?", advertiserID, "rejected").Count(&rejectedOffers)
 
 	// Get total promoters across all offers
 	var totalPromoters int64
-	h.db.Model(&models.UserOffer{}).
+	tenantDB(c, h.db).Model(&models.UserOffer{}).
 		Joins("JOIN offers ON user_offers.offer_id = offers.id").
 		Where("offers.advertiser_id = ?", advertiserID).
 		Count(&totalPromoters)
 
 	// Get total clicks and conversions across all offers
 	var offers []models.Offer
-	h.db.Where("advertiser_id = ?", advertiserID).Find(&offers)
+	tenantDB(c, h.db).Where("advertiser_id = ?", advertiserID).Find(&offers)
 
 	var totalClicks, totalConversions int
 	for _, offer := range offers {
@@ -658,18 +672,18 @@ func (h *AdvertiserHandler) GetDashboard(c *gin.Context) {
 		totalConversions += offer.TotalConversions
 	}
 
-	// Get today's stats
-	today := time.Now().Truncate(24 * time.Hour)
+	// Get today's stats (today in the tenant's timezone)
+	today := services.StartOfDayIn(time.Now(), tenantLocation(c, h.db))
 	var todayClicks int64
 	var todayConversions int64
 
-	h.db.Model(&models.Click{}).
+	tenantDB(c, h.db).Model(&models.Click{}).
 		Joins("JOIN user_offers ON clicks.user_offer_id = user_offers.id").
 		Joins("JOIN offers ON user_offers.offer_id = offers.id").
 		Where("offers.advertiser_id = ? AND clicks.clicked_at >= ?", advertiserID, today).
 		Count(&todayClicks)
 
-	h.db.Model(&models.Conversion{}).
+	tenantDB(c, h.db).Model(&models.Conversion{}).
 		Joins("JOIN user_offers ON conversions.user_offer_id = user_offers.id").
 		Joins("JOIN offers ON user_offers.offer_id = offers.id").
 		Where("offers.advertiser_id = ? AND conversions.converted_at >= ?", advertiserID, today).
@@ -720,7 +734,7 @@ func (h *AdvertiserHandler) GetConversions(c *gin.Context) {
 	endDate := c.Query("end_date")
 
 	// Build query
-	query := h.db.Table("conversions").
+	query := tenantDB(c, h.db).Table("conversions").
 		Select(`
 			conversions.id,
 			conversions.status,
@@ -801,7 +815,7 @@ func (h *AdvertiserHandler) GetPromoters(c *gin.Context) {
 
 	// Get all offers by this advertiser
 	var offers []models.Offer
-	if err := h.db.Where("advertiser_id = ?", userID).Find(&offers).Error; err != nil {
+	if err := tenantDB(c, h.db).Where("advertiser_id = ?", userID).Find(&offers).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch offers"})
 		return
 	}
@@ -824,7 +838,7 @@ func (h *AdvertiserHandler) GetPromoters(c *gin.Context) {
 
 	// Get all user_offers for these offers
 	var userOffers []models.UserOffer
-	if err := h.db.Where("offer_id IN ?", offerIDs).Preload("User").Find(&userOffers).Error; err != nil {
+	if err := tenantDB(c, h.db).Where("offer_id IN ?", offerIDs).Preload("User").Find(&userOffers).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch promoters"})
 		return
 	}
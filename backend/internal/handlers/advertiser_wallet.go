@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// ADVERTISER WALLET HANDLER
+// ============================================
+
+// AdvertiserWalletHandler manages advertiser prepaid balances
+type AdvertiserWalletHandler struct {
+	db            *gorm.DB
+	walletService *services.AdvertiserWalletService
+}
+
+// NewAdvertiserWalletHandler creates a new wallet handler
+func NewAdvertiserWalletHandler(db *gorm.DB) *AdvertiserWalletHandler {
+	return &AdvertiserWalletHandler{
+		db:            db,
+		walletService: services.GetAdvertiserWalletService(db),
+	}
+}
+
+func (h *AdvertiserWalletHandler) fail(c *gin.Context, correlationID string, status int, err error) {
+	c.JSON(status, gin.H{
+		"success":        false,
+		"correlation_id": correlationID,
+		"error":          err.Error(),
+	})
+}
+
+func (h *AdvertiserWalletHandler) ok(c *gin.Context, correlationID string, data interface{}) {
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           data,
+	})
+}
+
+// walletErrorStatus maps wallet errors to HTTP statuses
+func walletErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrWalletNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrWalletCurrency), errors.Is(err, services.ErrWalletInvalidAmount):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrWalletDisabled):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// walletResponse returns a wallet with its recent movements
+func (h *AdvertiserWalletHandler) walletResponse(c *gin.Context, correlationID string, advertiserID uuid.UUID) {
+	tenantID := middleware.GetTenantID(c)
+	wallet, err := h.walletService.Get(tenantID, advertiserID)
+	if err != nil {
+		h.fail(c, correlationID, walletErrorStatus(err), err)
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	txns, total, err := h.walletService.Transactions(tenantID, advertiserID, limit, offset)
+	if err != nil {
+		h.fail(c, correlationID, http.StatusInternalServerError, err)
+		return
+	}
+	h.ok(c, correlationID, gin.H{
+		"wallet":       wallet,
+		"available":    wallet.Available(),
+		"transactions": txns,
+		"total":        total,
+	})
+}
+
+// GetMyWallet returns the advertiser's prepaid balance and movements
+// GET /api/advertiser/wallet
+func (h *AdvertiserWalletHandler) GetMyWallet(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	userID := requestActor(c)
+	if userID == nil {
+		h.fail(c, correlationID, http.StatusUnauthorized, errors.New("User not authenticated"))
+		return
+	}
+	h.walletResponse(c, correlationID, *userID)
+}
+
+// ListWallets lists the tenant's advertiser wallets, lowest funds first
+// GET /api/admin/wallets?status=exhausted
+func (h *AdvertiserWalletHandler) ListWallets(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	wallets, err := h.walletService.List(middleware.GetTenantID(c), c.Query("status"))
+	if err != nil {
+		h.fail(c, correlationID, http.StatusInternalServerError, err)
+		return
+	}
+	h.ok(c, correlationID, gin.H{"wallets": wallets, "total": len(wallets)})
+}
+
+// GetAdvertiserWallet returns an advertiser's wallet and movements
+// GET /api/admin/advertisers/:id/wallet
+func (h *AdvertiserWalletHandler) GetAdvertiserWallet(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	advertiserID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.fail(c, correlationID, http.StatusBadRequest, errors.New("Invalid advertiser ID"))
+		return
+	}
+	h.walletResponse(c, correlationID, advertiserID)
+}
+
+// ConfigureWallet enables, disables or changes an advertiser's wallet.
+// Amounts are minor units of the wallet currency. A wallet without funds
+// pauses the advertiser's offers right away.
+// PUT /api/admin/advertisers/:id/wallet
+func (h *AdvertiserWalletHandler) ConfigureWallet(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	advertiserID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.fail(c, correlationID, http.StatusBadRequest, errors.New("Invalid advertiser ID"))
+		return
+	}
+	var req services.WalletSettings
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.fail(c, correlationID, http.StatusBadRequest, err)
+		return
+	}
+
+	wallet, err := h.walletService.Configure(middleware.GetTenantID(c), advertiserID, req)
+	if err != nil {
+		h.fail(c, correlationID, walletErrorStatus(err), err)
+		return
+	}
+	h.ok(c, correlationID, gin.H{"wallet": wallet, "available": wallet.Available()})
+}
+
+// TopUpWallet records a payment received from an advertiser (bank transfer,
+// cash). Reusing a reference returns the original top-up.
+// POST /api/admin/advertisers/:id/wallet/top-ups
+func (h *AdvertiserWalletHandler) TopUpWallet(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	advertiserID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.fail(c, correlationID, http.StatusBadRequest, errors.New("Invalid advertiser ID"))
+		return
+	}
+	var req struct {
+		Amount    int64  `json:"amount" binding:"required,gt=0"` // minor units
+		Currency  string `json:"currency" binding:"omitempty,len=3"`
+		Reference string `json:"reference"`
+		Note      string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.fail(c, correlationID, http.StatusBadRequest, err)
+		return
+	}
+
+	tenantID := middleware.GetTenantID(c)
+	txn, created, err := h.walletService.TopUp(tenantID, advertiserID, services.WalletTopUp{
+		Amount:      req.Amount,
+		Currency:    req.Currency,
+		Source:      "admin",
+		ExternalRef: req.Reference,
+		Note:        req.Note,
+		CreatedBy:   requestActor(c),
+	})
+	if err != nil {
+		h.fail(c, correlationID, walletErrorStatus(err), err)
+		return
+	}
+	wallet, _ := h.walletService.Get(tenantID, advertiserID)
+	h.ok(c, correlationID, gin.H{"transaction": txn, "created": created, "wallet": wallet})
+}
+
+// TopUpWebhook receives signed top-up notifications from the payment provider
+// POST /api/wallet/webhook
+func (h *AdvertiserWalletHandler) TopUpWebhook(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read body"})
+		return
+	}
+
+	event, duplicate, err := h.walletService.HandleTopUpWebhook(c.Request.Header, body)
+	switch {
+	case errors.Is(err, services.ErrWalletInvalidSignature):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+		return
+	case errors.Is(err, services.ErrWalletEventIgnored):
+		// Acknowledge so the provider stops retrying
+		c.JSON(http.StatusOK, gin.H{"ignored": true})
+		return
+	case errors.Is(err, services.ErrWalletNotFound), errors.Is(err, services.ErrWalletDisabled),
+		errors.Is(err, services.ErrWalletCurrency), errors.Is(err, services.ErrWalletInvalidAmount):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("[Wallet] top-up webhook processing failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process event"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"event_id": event.ID, "duplicate": duplicate})
+}
@@ -4,10 +4,12 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 
+	"github.com/aljapah/afftok-backend-prod/internal/database"
 	"github.com/aljapah/afftok-backend-prod/internal/models"
 	"github.com/aljapah/afftok-backend-prod/internal/services"
 	"github.com/aljapah/afftok-backend-prod/pkg/utils"
@@ -41,6 +43,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		Password    string `json:"password" binding:"required,min=6"`
 		FullName    string `json:"full_name"`
 		Role        string `json:"role"`         // "promoter" or "advertiser"
+		ReferralCode string `json:"referral_code"` // unique code of the promoter who referred them
 		// Advertiser-specific fields
 		CompanyName string `json:"company_name"`
 		Phone       string `json:"phone"`
@@ -121,12 +124,29 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		Country:      req.Country,
 	}
 
-	if err := h.db.Create(&user).Error; err != nil {
+	// A referral code links the new promoter to their referrer
+	created, err := createWithinPlanLimit(c, h.db, models.LimitResourceUsers, func(tx *database.TenantDB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if req.ReferralCode == "" {
+			return nil
+		}
+		_, err := services.GetReferralService(h.db).Register(tx.DB, &user, req.ReferralCode)
+		return err
+	})
+	if !created {
+		return
+	}
+	if errors.Is(err, services.ErrReferralInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
 
-	accessToken, err := utils.GenerateToken(user.ID, user.Username, user.Email, user.Role)
+	accessToken, err := utils.GenerateTenantToken(user.ID, user.TenantID, user.Username, user.Email, user.Role)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -164,7 +184,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	var user models.AfftokUser
-	if err := h.db.Where("username = ? OR email = ?", req.Username, req.Username).First(&user).Error; err != nil {
+	if err := tenantDB(c, h.db).Where("username = ? OR email = ?", req.Username, req.Username).First(&user).Error; err != nil {
 		h.observabilityService.LogAuth("", req.Username, c.ClientIP(), "login", false, "user_not_found")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
@@ -182,7 +202,11 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	accessToken, err := utils.GenerateToken(user.ID, user.Username, user.Email, user.Role)
+	if !h.allowPasswordLogin(c, &user) {
+		return
+	}
+
+	accessToken, err := utils.GenerateTenantToken(user.ID, user.TenantID, user.Username, user.Email, user.Role)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -225,13 +249,17 @@ func (h *AuthHandler) GoogleSignIn(c *gin.Context) {
 	}
 
 	var user models.AfftokUser
-	if err := h.db.Where("email = ?", googleClaims.Email).First(&user).Error; err == nil {
+	if err := tenantDB(c, h.db).Where("email = ?", googleClaims.Email).First(&user).Error; err == nil {
 		if user.Status == "suspended" {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Account is suspended"})
 			return
 		}
 
-		accessToken, err := utils.GenerateToken(user.ID, user.Username, user.Email, user.Role)
+		if !h.allowPasswordLogin(c, &user) {
+			return
+		}
+
+		accessToken, err := utils.GenerateTenantToken(user.ID, user.TenantID, user.Username, user.Email, user.Role)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 			return
@@ -275,12 +303,18 @@ func (h *AuthHandler) GoogleSignIn(c *gin.Context) {
 		Level:        1,
 	}
 
-	if err := h.db.Create(&newUser).Error; err != nil {
+	created, err := createWithinPlanLimit(c, h.db, models.LimitResourceUsers, func(tx *database.TenantDB) error {
+		return tx.Create(&newUser).Error
+	})
+	if !created {
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
 
-	accessToken, err := utils.GenerateToken(newUser.ID, newUser.Username, newUser.Email, newUser.Role)
+	accessToken, err := utils.GenerateTenantToken(newUser.ID, newUser.TenantID, newUser.Username, newUser.Email, newUser.Role)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -302,6 +336,22 @@ func (h *AuthHandler) GoogleSignIn(c *gin.Context) {
 	})
 }
 
+// allowPasswordLogin rejects password and Google sign-in for users whose
+// tenant enforces SSO, pointing them to the SSO login instead
+func (h *AuthHandler) allowPasswordLogin(c *gin.Context, user *models.AfftokUser) bool {
+	blocked, loginURL := services.GetSSOService(h.db).PasswordLoginBlocked(user)
+	if !blocked {
+		return true
+	}
+	h.observabilityService.LogAuth(user.ID.String(), user.Username, c.ClientIP(), "login", false, "sso_required")
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":     "Your organization requires single sign-on",
+		"code":      "SSO_REQUIRED",
+		"login_url": loginURL,
+	})
+	return false
+}
+
 func (h *AuthHandler) verifyGoogleToken(idToken string) (*GoogleClaims, error) {
 	parts := strings.Split(idToken, ".")
 	if len(parts) != 3 {
@@ -344,13 +394,13 @@ func (h *AuthHandler) GetMe(c *gin.Context) {
 	}
 
 	var user models.AfftokUser
-	if err := h.db.Preload("UserBadges.Badge").First(&user, "id = ?", userID).Error; err != nil {
+	if err := tenantDB(c, h.db).Preload("UserBadges.Badge").Preload("Earnings").First(&user, "id = ?", userID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
 	var totalOffers int64
-	h.db.Model(&models.UserOffer{}).
+	tenantDB(c, h.db).Model(&models.UserOffer{}).
 		Where("user_id = ? AND status = ?", user.ID, "active").
 		Count(&totalOffers)
 
@@ -363,7 +413,7 @@ func (h *AuthHandler) GetMe(c *gin.Context) {
 	}
 
 	var globalRank int64 = 1
-	h.db.Model(&models.AfftokUser{}).
+	tenantDB(c, h.db).Model(&models.AfftokUser{}).
 		Where("total_conversions > ?", user.TotalConversions).
 		Count(&globalRank)
 	globalRank += 1
@@ -378,7 +428,7 @@ func (h *AuthHandler) GetMe(c *gin.Context) {
 	stats := gin.H{
 		"total_clicks":            totalClicks,
 		"total_conversions":       totalConversions,
-		"total_earnings":          user.TotalEarnings,
+		"earnings":                user.Earnings,
 		"total_registered_offers": totalOffers,
 		"monthly_clicks":          monthlyClicks,
 		"monthly_conversions":     monthlyConversions,
@@ -416,7 +466,7 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	accessToken, err := utils.GenerateToken(user.ID, user.Username, user.Email, user.Role)
+	accessToken, err := utils.GenerateTenantToken(user.ID, user.TenantID, user.Username, user.Email, user.Role)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -20,7 +21,7 @@ func NewBadgeHandler(db *gorm.DB) *BadgeHandler {
 func (h *BadgeHandler) GetAllBadges(c *gin.Context) {
 	var badges []models.Badge
 
-	if err := h.db.Order("required_value ASC").Find(&badges).Error; err != nil {
+	if err := tenantDB(c, h.db).Order("required_value ASC").Find(&badges).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch badges"})
 		return
 	}
@@ -34,7 +35,7 @@ func (h *BadgeHandler) GetMyBadges(c *gin.Context) {
 	userID, _ := c.Get("userID")
 
 	var userBadges []models.UserBadge
-	if err := h.db.Preload("Badge").Where("user_id = ?", userID).Order("earned_at DESC").Find(&userBadges).Error; err != nil {
+	if err := tenantDB(c, h.db).Preload("Badge").Where("user_id = ?", userID).Order("earned_at DESC").Find(&userBadges).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch your badges"})
 		return
 	}
@@ -46,7 +47,7 @@ func (h *BadgeHandler) GetMyBadges(c *gin.Context) {
 
 func (h *BadgeHandler) CheckAndAwardBadges(userID uuid.UUID) error {
 	var user models.AfftokUser
-	if err := h.db.First(&user, "id = ?", userID).Error; err != nil {
+	if err := h.db.Preload("Earnings").First(&user, "id = ?", userID).Error; err != nil {
 		return err
 	}
 
@@ -70,8 +71,11 @@ func (h *BadgeHandler) CheckAndAwardBadges(userID uuid.UUID) error {
 				earned = true
 			}
 		case "earnings":
-			if user.TotalEarnings >= badge.RequiredValue {
-				earned = true
+			// Reached in any one currency
+			for _, counter := range user.Earnings {
+				if counter.Amount >= services.WholeToMinor(int64(badge.RequiredValue), counter.Currency) {
+					earned = true
+				}
 			}
 		case "points":
 			if user.Points >= badge.RequiredValue {
@@ -120,7 +124,7 @@ func (h *BadgeHandler) CreateBadge(c *gin.Context) {
 		Points:        req.Points,
 	}
 
-	if err := h.db.Create(&badge).Error; err != nil {
+	if err := tenantDB(c, h.db).Create(&badge).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create badge"})
 		return
 	}
@@ -169,13 +173,13 @@ func (h *BadgeHandler) UpdateBadge(c *gin.Context) {
 		updates["points"] = req.Points
 	}
 
-	if err := h.db.Model(&models.Badge{}).Where("id = ?", badgeID).Updates(updates).Error; err != nil {
+	if err := tenantDB(c, h.db).Model(&models.Badge{}).Where("id = ?", badgeID).Updates(updates).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update badge"})
 		return
 	}
 
 	var badge models.Badge
-	h.db.First(&badge, "id = ?", badgeID)
+	tenantDB(c, h.db).First(&badge, "id = ?", badgeID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Badge updated successfully",
@@ -192,7 +196,7 @@ func (h *BadgeHandler) DeleteBadge(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Delete(&models.Badge{}, "id = ?", id).Error; err != nil {
+	if err := tenantDB(c, h.db).Delete(&models.Badge{}, "id = ?", id).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete badge"})
 		return
 	}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// BotChallengeHandler handles JS challenge submissions for suspicious clicks
+type BotChallengeHandler struct {
+	challengeService *services.BotChallengeService
+	observability    *services.ObservabilityService
+}
+
+// NewBotChallengeHandler creates a new bot challenge handler
+func NewBotChallengeHandler() *BotChallengeHandler {
+	return &BotChallengeHandler{
+		challengeService: services.NewBotChallengeService(),
+		observability:    services.NewObservabilityService(),
+	}
+}
+
+// VerifyChallenge verifies the browser signals posted by the challenge page,
+// sets the clearance cookie and returns the URL to continue to
+// POST /api/c/challenge
+func (h *BotChallengeHandler) VerifyChallenge(c *gin.Context) {
+	var signals services.BotChallengeSignals
+	if err := c.ShouldBindJSON(&signals); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid challenge response"})
+		return
+	}
+
+	ip := c.ClientIP()
+	ua := c.Request.UserAgent()
+
+	result, err := h.challengeService.VerifyChallenge(&signals, ip, ua)
+	if err != nil {
+		h.observability.LogFraud(
+			ip,
+			ua,
+			"bot_challenge_invalid",
+			80,
+			0.8,
+			[]string{"bot_challenge", "invalid_token"},
+			map[string]interface{}{
+				"error": err.Error(),
+			},
+		)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Challenge failed"})
+		return
+	}
+
+	if !result.Passed {
+		h.observability.LogFraud(
+			ip,
+			ua,
+			"bot_challenge_failed",
+			int(result.FraudScore),
+			float64(result.SignalScore)/100,
+			append([]string{"bot_challenge"}, result.Flags...),
+			map[string]interface{}{
+				"initial_risk": result.InitialRisk,
+				"signal_score": result.SignalScore,
+			},
+		)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(
+		services.BotChallengeCookieName,
+		result.ClearanceValue,
+		int(h.challengeService.ClearanceTTL()/time.Second),
+		"/",
+		"",
+		c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https",
+		true,
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"passed":   true,
+		"redirect": result.ReturnTo,
+	})
+}
+
+// GetChallengeStats returns challenge outcome counters
+// GET /api/admin/fraud/challenges
+func (h *BotChallengeHandler) GetChallengeStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"stats":     services.GetBotChallengeMetrics(),
+		"timestamp": time.Now().UTC(),
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
 	"github.com/aljapah/afftok-backend-prod/internal/models"
 	"github.com/aljapah/afftok-backend-prod/internal/services"
 	"github.com/gin-gonic/gin"
@@ -23,6 +24,9 @@ type ClickHandler struct {
 	geoRuleService       *services.GeoRuleService
 	linkSigningService   *services.LinkSigningService
 	geoIPService         *services.GeoIPService
+	beaconService        *services.LandingBeaconService
+	usageService         *services.UsageService
+	settingsResolver     *services.TenantSettingsResolver
 	badgeHandler         *BadgeHandler
 }
 
@@ -36,6 +40,9 @@ func NewClickHandler(db *gorm.DB) *ClickHandler {
 		geoRuleService:       services.NewGeoRuleService(db),
 		linkSigningService:   services.NewLinkSigningService(),
 		geoIPService:         services.NewGeoIPService(),
+		beaconService:        services.GetLandingBeaconService(db),
+		usageService:         services.GetUsageService(db),
+		settingsResolver:     services.GetTenantSettingsResolver(db),
 		badgeHandler:         NewBadgeHandler(db),
 	}
 }
@@ -50,6 +57,11 @@ func (h *ClickHandler) SetLinkSigningService(service *services.LinkSigningServic
 	h.linkSigningService = service
 }
 
+// SetLandingBeaconService sets the landing beacon service (for dependency injection)
+func (h *ClickHandler) SetLandingBeaconService(service *services.LandingBeaconService) {
+	h.beaconService = service
+}
+
 // TrackClick handles click tracking and redirect
 // Supports multiple URL formats:
 // - /api/c/{offerID}?promoter={promoterID} (legacy)
@@ -64,8 +76,13 @@ func (h *ClickHandler) TrackClick(c *gin.Context) {
 
 	startTime := time.Now()
 
+	// Settings of the tenant that owns the link (TTL, bot, geo and fraud checks)
+	settings := h.settingsResolver.Get(middleware.ResolveClickTenant(c))
+
 	// Security Check 0: Link Signature Validation
-	signResult := h.linkSigningService.ValidateSignedLink(rawCode)
+	signResult := h.linkSigningService.ValidateSignedLinkTTL(rawCode, func(string) int64 {
+		return int64(settings.DefaultLinkTTL)
+	})
 	if !signResult.Valid && !signResult.IsLegacy {
 		// Log fraud event for invalid signature
 		h.observabilityService.LogFraud(
@@ -102,7 +119,7 @@ func (h *ClickHandler) TrackClick(c *gin.Context) {
 
 	// Security Check 1: Bot Detection
 	botResult := h.securityService.DetectBot(c)
-	if botResult.IsBot && botResult.Confidence > 0.85 {
+	if settings.EnableBotDetection && botResult.IsBot && botResult.Confidence > 0.85 {
 		// Log fraud detection
 		h.observabilityService.LogFraud(
 			ip,
@@ -136,6 +153,7 @@ func (h *ClickHandler) TrackClick(c *gin.Context) {
 
 	var userOffer models.UserOffer
 	var offer models.Offer
+	var beaconClickID uuid.UUID // set when the landing page should confirm the visit
 
 	// Try to resolve as tracking code first
 	if strings.Contains(idOrCode, "-") {
@@ -217,39 +235,56 @@ func (h *ClickHandler) TrackClick(c *gin.Context) {
 	}
 
 trackAndRedirect:
+	// A tenant's custom domain only serves that tenant's links
+	if userOffer.ID != uuid.Nil && !brandedDomainAllows(c, userOffer.TenantID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
+		return
+	}
+
+	// Paused offers (the advertiser's prepaid balance is used up or an invoice
+	// is overdue) send their traffic to the fallback without recording a click
+	// until the advertiser pays
+	if offer.Status == "paused" {
+		fmt.Printf("[Click] Offer %s paused (%s), using fallback\n", offer.ID.String(), offer.PausedReason)
+		h.redirectToFallback(c, http.StatusServiceUnavailable, "Offer is temporarily unavailable")
+		return
+	}
+
 	// Track the click if we have a valid user offer
 	if userOffer.ID != uuid.Nil {
-		// Security Check 4: Geo Rule Check
-		// Get country from IP (using existing click service or header)
-		countryCode := h.getCountryFromRequest(c)
+		// Security Check 4: Geo Rule Check (unless the tenant disabled geo rules)
+		if settings.EnableGeoRules {
+			// Get country from IP (using existing click service or header)
+			countryCode := h.getCountryFromRequest(c)
 		
-		// Check geo rules
-		geoResult := h.geoRuleService.GetEffectiveGeoRule(&offer.ID, &userOffer.UserID, countryCode)
-		if !geoResult.Allowed {
-			// Log geo block event
-			h.observabilityService.LogFraud(
-				ip,
-				c.Request.UserAgent(),
-				"geo_block",
-				80, // High risk score for geo violations
-				0.9,
-				[]string{"geo_block", "geo_rule_violation"},
-				map[string]interface{}{
-					"country":       countryCode,
-					"offer_id":      offer.ID.String(),
-					"advertiser_id": userOffer.UserID.String(),
-					"rule_id":       getRuleID(geoResult.Rule),
-					"mode":          getRuleMode(geoResult.Rule),
-					"reason":        geoResult.Reason,
-				},
-			)
+			// Check geo rules
+			geoResult := h.geoRuleService.GetEffectiveGeoRule(&offer.ID, &userOffer.UserID, countryCode)
+			if !geoResult.Allowed {
+				// Log geo block event
+				h.observabilityService.LogFraud(
+					ip,
+					c.Request.UserAgent(),
+					"geo_block",
+					80, // High risk score for geo violations
+					0.9,
+					[]string{"geo_block", "geo_rule_violation"},
+					map[string]interface{}{
+						"country":       countryCode,
+						"offer_id":      offer.ID.String(),
+						"advertiser_id": userOffer.UserID.String(),
+						"rule_id":       getRuleID(geoResult.Rule),
+						"mode":          getRuleMode(geoResult.Rule),
+						"reason":        geoResult.Reason,
+					},
+				)
 			
-			fmt.Printf("[Click] Geo blocked: country=%s, offer=%s, reason=%s\n", 
-				countryCode, offer.ID.String(), geoResult.Reason)
+				fmt.Printf("[Click] Geo blocked: country=%s, offer=%s, reason=%s\n", 
+					countryCode, offer.ID.String(), geoResult.Reason)
 			
-			// Return safe response (no click recorded)
-			// Still redirect to avoid revealing the block
-			goto redirectOnly
+				// Return safe response (no click recorded)
+				// Still redirect to avoid revealing the block
+				goto redirectOnly
+			}
 		}
 		
 		// Security Check 5: Click Fingerprinting & Deduplication
@@ -260,6 +295,13 @@ trackAndRedirect:
 			goto redirectOnly
 		}
 
+		// Security Check 6: Tenant daily click quota (hard limit)
+		if !h.usageService.AllowClick(userOffer.TenantID) {
+			fmt.Printf("[Click] Daily click limit reached for tenant %s\n", userOffer.TenantID.String())
+			// Still redirect, but don't record the click
+			goto redirectOnly
+		}
+
 		click, err := h.clickService.TrackClick(c, userOffer.ID)
 		durationMs := time.Since(startTime).Milliseconds()
 		
@@ -278,6 +320,23 @@ trackAndRedirect:
 		} else {
 			fmt.Printf("[Click] Click tracked: %s for user offer %s\n", click.ID.String(), userOffer.ID.String())
 			
+			// Feed a solved JS challenge back into the click's fraud score
+			if value, ok := c.Get(services.BotChallengeContextKey); ok {
+				if clearance, ok := value.(*services.BotChallengeClearance); ok {
+					h.applyBotChallenge(click, clearance)
+				}
+			}
+			
+			// Referrer vs declared sources and verified visit rate (async - not on the redirect path)
+			if h.beaconService != nil {
+				if settings.EnableFraudDetection {
+					go h.applyBeaconChecks(click.ID, userOffer.UserID, click.Referrer, ip, c.Request.UserAgent())
+				}
+				if offer.BeaconEnabled {
+					beaconClickID = click.ID
+				}
+			}
+			
 			// Log successful click with full observability
 			h.observabilityService.LogClick(
 				userOffer.ID.String(),
@@ -312,6 +371,11 @@ redirectOnly:
 		return
 	}
 
+	// Carry the beacon token so the landing page SDK can confirm a real view
+	if beaconClickID != uuid.Nil {
+		destinationURL = h.beaconService.AppendToken(destinationURL, beaconClickID)
+	}
+
 	fmt.Printf("[Click] Redirecting to: %s\n", destinationURL)
 	c.Redirect(http.StatusFound, destinationURL)
 }
@@ -326,7 +390,7 @@ func (h *ClickHandler) handleInvalidLink(c *gin.Context, trackingCode string) {
 		if err == nil {
 			var uo models.UserOffer
 			if h.db.Preload("Offer").First(&uo, "id = ?", userOfferID).Error == nil && uo.Offer != nil {
-				if uo.Offer.DestinationURL != "" {
+				if uo.Offer.DestinationURL != "" && uo.Offer.Status != "paused" {
 					fmt.Printf("[Click] Invalid link, redirecting anyway: %s\n", uo.Offer.DestinationURL)
 					c.Redirect(http.StatusFound, uo.Offer.DestinationURL)
 					return
@@ -336,13 +400,17 @@ func (h *ClickHandler) handleInvalidLink(c *gin.Context, trackingCode string) {
 	}
 	
 	// Fallback: return error or redirect to homepage
-	fallbackURL := os.Getenv("FALLBACK_REDIRECT_URL")
-	if fallbackURL != "" {
+	h.redirectToFallback(c, http.StatusBadRequest, "Invalid tracking link")
+}
+
+// redirectToFallback sends traffic that cannot reach its offer to
+// FALLBACK_REDIRECT_URL, or answers with status and message when none is set
+func (h *ClickHandler) redirectToFallback(c *gin.Context, status int, message string) {
+	if fallbackURL := os.Getenv("FALLBACK_REDIRECT_URL"); fallbackURL != "" {
 		c.Redirect(http.StatusFound, fallbackURL)
 		return
 	}
-	
-	c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tracking link"})
+	c.JSON(status, gin.H{"error": message})
 }
 
 // GetClickStats returns click statistics for a specific user offer
@@ -352,7 +420,7 @@ func (h *ClickHandler) GetClickStats(c *gin.Context) {
 
 	// Verify ownership
 	var userOffer models.UserOffer
-	if err := h.db.Where("id = ? AND user_id = ?", userOfferID, userID).First(&userOffer).Error; err != nil {
+	if err := tenantDB(c, h.db).Where("id = ? AND user_id = ?", userOfferID, userID).First(&userOffer).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User offer not found or access denied"})
 		return
 	}
@@ -373,7 +441,7 @@ func (h *ClickHandler) GetMyClicks(c *gin.Context) {
 
 	// Get all user offers
 	var userOffers []models.UserOffer
-	if err := h.db.Where("user_id = ?", userID).Find(&userOffers).Error; err != nil {
+	if err := tenantDB(c, h.db).Where("user_id = ?", userID).Find(&userOffers).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user offers"})
 		return
 	}
@@ -394,7 +462,7 @@ func (h *ClickHandler) GetMyClicks(c *gin.Context) {
 
 	// Get recent clicks
 	var clicks []models.Click
-	if err := h.db.Where("user_offer_id IN ?", offerIDs).
+	if err := tenantDB(c, h.db).Where("user_offer_id IN ?", offerIDs).
 		Order("clicked_at DESC").
 		Limit(100).
 		Find(&clicks).Error; err != nil {
@@ -404,7 +472,7 @@ func (h *ClickHandler) GetMyClicks(c *gin.Context) {
 
 	// Get total count
 	var total int64
-	h.db.Model(&models.Click{}).Where("user_offer_id IN ?", offerIDs).Count(&total)
+	tenantDB(c, h.db).Model(&models.Click{}).Where("user_offer_id IN ?", offerIDs).Count(&total)
 
 	c.JSON(http.StatusOK, gin.H{
 		"clicks": clicks,
@@ -428,7 +496,7 @@ func (h *ClickHandler) GetClicksByOffer(c *gin.Context) {
 
 	// Get all user offers with stats
 	var userOffers []models.UserOffer
-	if err := h.db.Preload("Offer").Where("user_id = ?", userID).Find(&userOffers).Error; err != nil {
+	if err := tenantDB(c, h.db).Preload("Offer").Where("user_id = ?", userID).Find(&userOffers).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user offers"})
 		return
 	}
@@ -437,16 +505,16 @@ func (h *ClickHandler) GetClicksByOffer(c *gin.Context) {
 		var totalClicks, uniqueClicks, conversions int64
 
 		// Total clicks
-		h.db.Model(&models.Click{}).Where("user_offer_id = ?", uo.ID).Count(&totalClicks)
+		tenantDB(c, h.db).Model(&models.Click{}).Where("user_offer_id = ?", uo.ID).Count(&totalClicks)
 
 		// Unique clicks (by IP)
-		h.db.Model(&models.Click{}).
+		tenantDB(c, h.db).Model(&models.Click{}).
 			Where("user_offer_id = ?", uo.ID).
 			Distinct("ip_address").
 			Count(&uniqueClicks)
 
 		// Conversions
-		h.db.Model(&models.Conversion{}).Where("user_offer_id = ?", uo.ID).Count(&conversions)
+		tenantDB(c, h.db).Model(&models.Conversion{}).Where("user_offer_id = ?", uo.ID).Count(&conversions)
 
 		offerTitle := ""
 		if uo.Offer != nil {
@@ -499,6 +567,43 @@ func (h *ClickHandler) getCountryFromRequest(c *gin.Context) string {
 	return ""
 }
 
+// applyBotChallenge adds the challenge outcome to the click's fraud flags and score
+func (h *ClickHandler) applyBotChallenge(click *models.Click, clearance *services.BotChallengeClearance) {
+	flags, score := services.MergeChallengeFraud(click.FraudFlags, click.FraudScore, clearance)
+
+	if err := h.db.Model(&models.Click{}).
+		Where("id = ?", click.ID).
+		Updates(map[string]interface{}{
+			"fraud_score": score,
+			"fraud_flags": flags,
+		}).Error; err != nil {
+		fmt.Printf("[Click] Failed to store challenge result for click %s: %v\n", click.ID.String(), err)
+		return
+	}
+
+	click.FraudScore = score
+	click.FraudFlags = flags
+}
+
+// applyBeaconChecks flags referrers outside the promoter's declared traffic
+// sources and adds the low verified-visit-rate penalty to the click
+func (h *ClickHandler) applyBeaconChecks(clickID, promoterID uuid.UUID, referrer, ip, ua string) {
+	if mismatch, host := h.beaconService.CheckReferrer(promoterID, referrer); mismatch {
+		h.beaconService.AddClickFlags(clickID, []string{services.BeaconFlagReferrerMismatch}, 20)
+		h.observabilityService.LogFraud(ip, ua, "referrer_mismatch", 40, 0.6,
+			[]string{services.BeaconFlagReferrerMismatch},
+			map[string]interface{}{
+				"click_id":      clickID.String(),
+				"promoter_id":   promoterID.String(),
+				"referrer_host": host,
+			})
+	}
+
+	if penalty := h.beaconService.FraudPenalty(promoterID); penalty > 0 {
+		h.beaconService.AddClickFlags(clickID, []string{services.BeaconFlagLowVisitRate}, penalty)
+	}
+}
+
 // getRuleID safely gets rule ID
 func getRuleID(rule *models.GeoRule) string {
 	if rule == nil {
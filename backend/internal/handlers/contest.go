@@ -30,7 +30,7 @@ func (h *ContestHandler) GetActiveContests(c *gin.Context) {
 	// Debug: Log the query parameters
 	fmt.Printf("[Contest] Fetching active contests at: %v\n", now)
 	
-	if err := h.db.Where("status = ? AND start_date <= ? AND end_date >= ?", 
+	if err := tenantDB(c, h.db).Where("status = ? AND start_date <= ? AND end_date >= ?", 
 		models.ContestStatusActive, now, now).
 		Order("end_date ASC").
 		Find(&contests).Error; err != nil {
@@ -42,7 +42,7 @@ func (h *ContestHandler) GetActiveContests(c *gin.Context) {
 
 	// If no active contests, also return upcoming contests for debugging
 	var allContests []models.Contest
-	h.db.Order("created_at DESC").Limit(10).Find(&allContests)
+	tenantDB(c, h.db).Order("created_at DESC").Limit(10).Find(&allContests)
 	
 	// Log all contests status for debugging
 	for _, ct := range allContests {
@@ -65,7 +65,7 @@ func (h *ContestHandler) GetContest(c *gin.Context) {
 	contestID := c.Param("id")
 
 	var contest models.Contest
-	if err := h.db.Preload("Participants.Team").Preload("Participants.User").
+	if err := tenantDB(c, h.db).Preload("Participants.Team").Preload("Participants.User").
 		First(&contest, "id = ?", contestID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Contest not found"})
 		return
@@ -81,7 +81,7 @@ func (h *ContestHandler) GetContestLeaderboard(c *gin.Context) {
 	contestID := c.Param("id")
 
 	var participants []models.ContestParticipant
-	if err := h.db.Preload("Team").Preload("User").
+	if err := tenantDB(c, h.db).Preload("Team").Preload("User").
 		Where("contest_id = ?", contestID).
 		Order("progress DESC, current_clicks DESC").
 		Limit(50).
@@ -106,7 +106,7 @@ func (h *ContestHandler) JoinContest(c *gin.Context) {
 	userID, _ := c.Get("userID")
 
 	var contest models.Contest
-	if err := h.db.First(&contest, "id = ?", contestID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&contest, "id = ?", contestID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Contest not found"})
 		return
 	}
@@ -125,7 +125,7 @@ func (h *ContestHandler) JoinContest(c *gin.Context) {
 	// Check if already participating
 	var existingParticipant models.ContestParticipant
 	if contest.ContestType == models.ContestTypeIndividual {
-		if err := h.db.Where("contest_id = ? AND user_id = ?", contestID, userID).
+		if err := tenantDB(c, h.db).Where("contest_id = ? AND user_id = ?", contestID, userID).
 			First(&existingParticipant).Error; err == nil {
 			c.JSON(http.StatusConflict, gin.H{"error": "You are already participating in this contest"})
 			return
@@ -133,7 +133,7 @@ func (h *ContestHandler) JoinContest(c *gin.Context) {
 	} else {
 		// Team contest - only team owner can join on behalf of the team
 		var member models.TeamMember
-		if err := h.db.Where("user_id = ? AND status = ?", userID, "active").
+		if err := tenantDB(c, h.db).Where("user_id = ? AND status = ?", userID, "active").
 			Preload("Team").
 			First(&member).Error; err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "You must be in a team to join this contest"})
@@ -148,7 +148,7 @@ func (h *ContestHandler) JoinContest(c *gin.Context) {
 			return
 		}
 
-		if err := h.db.Where("contest_id = ? AND team_id = ?", contestID, member.TeamID).
+		if err := tenantDB(c, h.db).Where("contest_id = ? AND team_id = ?", contestID, member.TeamID).
 			First(&existingParticipant).Error; err == nil {
 			c.JSON(http.StatusConflict, gin.H{"error": "Your team is already participating in this contest"})
 			return
@@ -167,17 +167,17 @@ func (h *ContestHandler) JoinContest(c *gin.Context) {
 		participant.UserID = &uid
 	} else {
 		var member models.TeamMember
-		h.db.Where("user_id = ? AND status = ?", userID, "active").First(&member)
+		tenantDB(c, h.db).Where("user_id = ? AND status = ?", userID, "active").First(&member)
 		participant.TeamID = &member.TeamID
 	}
 
-	if err := h.db.Create(&participant).Error; err != nil {
+	if err := tenantDB(c, h.db).Create(&participant).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join contest"})
 		return
 	}
 
 	// Increment participants count
-	h.db.Model(&contest).UpdateColumn("participants_count", gorm.Expr("participants_count + 1"))
+	tenantDB(c, h.db).Model(&contest).UpdateColumn("participants_count", gorm.Expr("participants_count + 1"))
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -190,7 +190,7 @@ func (h *ContestHandler) GetMyContests(c *gin.Context) {
 	userID, _ := c.Get("userID")
 
 	var participants []models.ContestParticipant
-	if err := h.db.Preload("Contest").
+	if err := tenantDB(c, h.db).Preload("Contest").
 		Where("user_id = ?", userID).
 		Find(&participants).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch contests"})
@@ -199,9 +199,9 @@ func (h *ContestHandler) GetMyContests(c *gin.Context) {
 
 	// Also check team contests
 	var member models.TeamMember
-	if err := h.db.Where("user_id = ? AND status = ?", userID, "active").First(&member).Error; err == nil {
+	if err := tenantDB(c, h.db).Where("user_id = ? AND status = ?", userID, "active").First(&member).Error; err == nil {
 		var teamParticipants []models.ContestParticipant
-		h.db.Preload("Contest").Where("team_id = ?", member.TeamID).Find(&teamParticipants)
+		tenantDB(c, h.db).Preload("Contest").Where("team_id = ?", member.TeamID).Find(&teamParticipants)
 		participants = append(participants, teamParticipants...)
 	}
 
@@ -216,7 +216,7 @@ func (h *ContestHandler) GetMyContests(c *gin.Context) {
 func (h *ContestHandler) AdminGetAllContests(c *gin.Context) {
 	var contests []models.Contest
 	
-	if err := h.db.Order("created_at DESC").Find(&contests).Error; err != nil {
+	if err := tenantDB(c, h.db).Order("created_at DESC").Find(&contests).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch contests"})
 		return
 	}
@@ -312,7 +312,7 @@ func (h *ContestHandler) AdminCreateContest(c *gin.Context) {
 		Status:           req.Status,
 	}
 
-	if err := h.db.Create(&contest).Error; err != nil {
+	if err := tenantDB(c, h.db).Create(&contest).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create contest"})
 		return
 	}
@@ -329,7 +329,7 @@ func (h *ContestHandler) AdminUpdateContest(c *gin.Context) {
 	contestID := c.Param("id")
 
 	var contest models.Contest
-	if err := h.db.First(&contest, "id = ?", contestID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&contest, "id = ?", contestID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Contest not found"})
 		return
 	}
@@ -435,7 +435,7 @@ func (h *ContestHandler) AdminUpdateContest(c *gin.Context) {
 
 	contest.UpdatedAt = time.Now()
 
-	if err := h.db.Save(&contest).Error; err != nil {
+	if err := tenantDB(c, h.db).Save(&contest).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update contest"})
 		return
 	}
@@ -452,16 +452,16 @@ func (h *ContestHandler) AdminDeleteContest(c *gin.Context) {
 	contestID := c.Param("id")
 
 	var contest models.Contest
-	if err := h.db.First(&contest, "id = ?", contestID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&contest, "id = ?", contestID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Contest not found"})
 		return
 	}
 
 	// Delete participants first
-	h.db.Where("contest_id = ?", contestID).Delete(&models.ContestParticipant{})
+	tenantDB(c, h.db).Where("contest_id = ?", contestID).Delete(&models.ContestParticipant{})
 
 	// Delete contest
-	if err := h.db.Delete(&contest).Error; err != nil {
+	if err := tenantDB(c, h.db).Delete(&contest).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete contest"})
 		return
 	}
@@ -477,7 +477,7 @@ func (h *ContestHandler) AdminGetContestParticipants(c *gin.Context) {
 	contestID := c.Param("id")
 
 	var participants []models.ContestParticipant
-	if err := h.db.Preload("Team").Preload("User").
+	if err := tenantDB(c, h.db).Preload("Team").Preload("User").
 		Where("contest_id = ?", contestID).
 		Order("progress DESC").
 		Find(&participants).Error; err != nil {
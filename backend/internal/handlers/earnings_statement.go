@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// EARNINGS STATEMENTS & TAX PROFILES HANDLER
+// ============================================
+
+// EarningsStatementHandler serves promoter statements, year-end summaries
+// and tax profiles
+type EarningsStatementHandler struct {
+	db                *gorm.DB
+	statementService  *services.EarningsStatementService
+	taxProfileService *services.TaxProfileService
+}
+
+// NewEarningsStatementHandler creates a new statement handler
+func NewEarningsStatementHandler(db *gorm.DB) *EarningsStatementHandler {
+	return &EarningsStatementHandler{
+		db:                db,
+		statementService:  services.GetEarningsStatementService(db),
+		taxProfileService: services.GetTaxProfileService(db),
+	}
+}
+
+func (h *EarningsStatementHandler) fail(c *gin.Context, correlationID string, status int, err error) {
+	c.JSON(status, gin.H{
+		"success":        false,
+		"correlation_id": correlationID,
+		"error":          err.Error(),
+	})
+}
+
+func (h *EarningsStatementHandler) ok(c *gin.Context, correlationID string, data interface{}) {
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           data,
+	})
+}
+
+// statementErrorStatus maps statement and tax profile errors to HTTP statuses
+func statementErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrStatementPeriod), errors.Is(err, services.ErrTaxProfileInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrStatementPromoter), errors.Is(err, services.ErrTaxProfileNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// sendStatement writes a statement as JSON, CSV or PDF (?format=)
+func (h *EarningsStatementHandler) sendStatement(c *gin.Context, correlationID string, st *services.EarningsStatement) {
+	switch c.DefaultQuery("format", "json") {
+	case "csv":
+		var buf bytes.Buffer
+		if err := services.WriteEarningsStatementCSV(&buf, st); err != nil {
+			h.fail(c, correlationID, http.StatusInternalServerError, err)
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, st.Reference()))
+		c.Data(http.StatusOK, "text/csv", buf.Bytes())
+	case "pdf":
+		pdf, err := services.RenderEarningsStatementPDF(st)
+		if err != nil {
+			h.fail(c, correlationID, http.StatusInternalServerError, err)
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, st.Reference()))
+		c.Data(http.StatusOK, "application/pdf", pdf)
+	case "json":
+		h.ok(c, correlationID, st)
+	default:
+		h.fail(c, correlationID, http.StatusBadRequest, errors.New("format must be json, csv or pdf"))
+	}
+}
+
+// statement builds the monthly statement (month set) or year-end summary
+func (h *EarningsStatementHandler) statement(c *gin.Context, promoterID uuid.UUID, annual bool) {
+	correlationID := generateCorrelationID()
+	year, err := strconv.Atoi(c.Param("year"))
+	if err != nil {
+		h.fail(c, correlationID, http.StatusBadRequest, errors.New("Invalid year"))
+		return
+	}
+
+	tenantID := middleware.GetTenantID(c)
+	loc := tenantLocation(c, h.db)
+	var st *services.EarningsStatement
+	if annual {
+		st, err = h.statementService.YearEnd(tenantID, promoterID, year, loc)
+	} else {
+		month, convErr := strconv.Atoi(c.Param("month"))
+		if convErr != nil {
+			h.fail(c, correlationID, http.StatusBadRequest, errors.New("Invalid month"))
+			return
+		}
+		st, err = h.statementService.Monthly(tenantID, promoterID, year, month, loc)
+	}
+	if err != nil {
+		h.fail(c, correlationID, statementErrorStatus(err), err)
+		return
+	}
+	h.sendStatement(c, correlationID, st)
+}
+
+// GetMyMonthlyStatement returns the caller's earnings statement of a month
+// GET /api/statements/monthly/:year/:month?format=json|csv|pdf
+func (h *EarningsStatementHandler) GetMyMonthlyStatement(c *gin.Context) {
+	userID := requestActor(c)
+	if userID == nil {
+		h.fail(c, generateCorrelationID(), http.StatusUnauthorized, errors.New("User not authenticated"))
+		return
+	}
+	h.statement(c, *userID, false)
+}
+
+// GetMyYearEndSummary returns the caller's year-end earnings summary
+// GET /api/statements/annual/:year?format=json|csv|pdf
+func (h *EarningsStatementHandler) GetMyYearEndSummary(c *gin.Context) {
+	userID := requestActor(c)
+	if userID == nil {
+		h.fail(c, generateCorrelationID(), http.StatusUnauthorized, errors.New("User not authenticated"))
+		return
+	}
+	h.statement(c, *userID, true)
+}
+
+// GetPromoterMonthlyStatement returns a promoter's statement of a month
+// GET /api/admin/promoters/:id/statements/monthly/:year/:month
+func (h *EarningsStatementHandler) GetPromoterMonthlyStatement(c *gin.Context) {
+	promoterID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.fail(c, generateCorrelationID(), http.StatusBadRequest, errors.New("Invalid promoter ID"))
+		return
+	}
+	h.statement(c, promoterID, false)
+}
+
+// GetPromoterYearEndSummary returns a promoter's year-end summary
+// GET /api/admin/promoters/:id/statements/annual/:year
+func (h *EarningsStatementHandler) GetPromoterYearEndSummary(c *gin.Context) {
+	promoterID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.fail(c, generateCorrelationID(), http.StatusBadRequest, errors.New("Invalid promoter ID"))
+		return
+	}
+	h.statement(c, promoterID, true)
+}
+
+// taxProfileResponse returns a profile (tax ID masked) and whether one is
+// missing that the promoter's country requires before payouts
+func (h *EarningsStatementHandler) taxProfileResponse(c *gin.Context, correlationID string, userID uuid.UUID) {
+	tenantID := middleware.GetTenantID(c)
+	profile, err := h.taxProfileService.Get(tenantID, userID)
+	if err != nil && !errors.Is(err, services.ErrTaxProfileNotFound) {
+		h.fail(c, correlationID, http.StatusInternalServerError, err)
+		return
+	}
+
+	countries := services.GetTenantSettingsResolver(h.db).Get(tenantID).TaxProfileCountries
+	missing, err := h.taxProfileService.Missing(tenantID, countries, []uuid.UUID{userID})
+	if err != nil {
+		h.fail(c, correlationID, http.StatusInternalServerError, err)
+		return
+	}
+
+	data := gin.H{
+		"tax_profile":   profile,
+		"tax_id_masked": "",
+		"missing":       missing[userID], // payouts are held until it is saved
+		"entity_types":  models.TaxEntityTypes,
+	}
+	if profile != nil {
+		data["tax_id_masked"] = profile.MaskedTaxID()
+	}
+	h.ok(c, correlationID, data)
+}
+
+// GetMyTaxProfile returns the caller's tax profile
+// GET /api/tax-profile
+func (h *EarningsStatementHandler) GetMyTaxProfile(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	userID := requestActor(c)
+	if userID == nil {
+		h.fail(c, correlationID, http.StatusUnauthorized, errors.New("User not authenticated"))
+		return
+	}
+	h.taxProfileResponse(c, correlationID, *userID)
+}
+
+// SaveMyTaxProfile stores the caller's tax details. The promoter certifies
+// they are correct; payouts held for a missing profile are released.
+// PUT /api/tax-profile
+func (h *EarningsStatementHandler) SaveMyTaxProfile(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	userID := requestActor(c)
+	if userID == nil {
+		h.fail(c, correlationID, http.StatusUnauthorized, errors.New("User not authenticated"))
+		return
+	}
+	var req struct {
+		Country    string `json:"country" binding:"required"`
+		EntityType string `json:"entity_type" binding:"required"`
+		LegalName  string `json:"legal_name" binding:"required"`
+		TaxID      string `json:"tax_id" binding:"required"`
+		VATNumber  string `json:"vat_number"`
+		Address    string `json:"address"`
+		Certify    bool   `json:"certify"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.fail(c, correlationID, http.StatusBadRequest, err)
+		return
+	}
+	if !req.Certify {
+		h.fail(c, correlationID, http.StatusBadRequest, errors.New("certify must be true to confirm the details are correct"))
+		return
+	}
+
+	profile := models.PromoterTaxProfile{
+		Country:    req.Country,
+		EntityType: req.EntityType,
+		LegalName:  req.LegalName,
+		TaxID:      req.TaxID,
+		VATNumber:  req.VATNumber,
+		Address:    req.Address,
+	}
+	if err := h.taxProfileService.Save(middleware.GetTenantID(c), *userID, &profile); err != nil {
+		h.fail(c, correlationID, statementErrorStatus(err), err)
+		return
+	}
+	h.taxProfileResponse(c, correlationID, *userID)
+}
+
+// GetPromoterTaxProfile returns a promoter's tax profile (tax ID masked)
+// GET /api/admin/promoters/:id/tax-profile
+func (h *EarningsStatementHandler) GetPromoterTaxProfile(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	promoterID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.fail(c, correlationID, http.StatusBadRequest, errors.New("Invalid promoter ID"))
+		return
+	}
+	h.taxProfileResponse(c, correlationID, promoterID)
+}
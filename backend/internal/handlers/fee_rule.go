@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// PLATFORM FEE RULES HANDLER
+// ============================================
+
+// FeeRuleHandler manages the platform fee rules of a tenant
+type FeeRuleHandler struct {
+	db         *gorm.DB
+	feeService *services.FeeRuleService
+}
+
+// NewFeeRuleHandler creates a new fee rule handler
+func NewFeeRuleHandler(db *gorm.DB) *FeeRuleHandler {
+	return &FeeRuleHandler{
+		db:         db,
+		feeService: services.GetFeeRuleService(db),
+	}
+}
+
+func (h *FeeRuleHandler) fail(c *gin.Context, correlationID string, status int, err error) {
+	c.JSON(status, gin.H{
+		"success":        false,
+		"correlation_id": correlationID,
+		"error":          err.Error(),
+	})
+}
+
+func (h *FeeRuleHandler) ok(c *gin.Context, correlationID string, data interface{}) {
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           data,
+	})
+}
+
+// feeRuleErrorStatus maps fee rule errors to HTTP statuses
+func feeRuleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrFeeRuleInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrFeeRuleNotFound), errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// ListFeeRules lists the tenant's fee rules and the platform rules. Closed
+// versions are included with ?history=true.
+// GET /api/admin/fee-rules
+func (h *FeeRuleHandler) ListFeeRules(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	rules, err := h.feeService.List(middleware.GetTenantID(c), c.Query("history") == "true")
+	if err != nil {
+		h.fail(c, correlationID, http.StatusInternalServerError, err)
+		return
+	}
+	h.ok(c, correlationID, gin.H{
+		"fee_rules":       rules,
+		"default_fee_bps": services.DefaultPlatformFeeBps,
+		"scopes":          models.FeeRuleScopes,
+		"promoter_tiers":  services.PromoterTiers,
+	})
+}
+
+// SaveFeeRule adds a new version of the tenant's rule for a scope. Rates are
+// in basis points (1000 = 10%); tiers switch the rate by the advertiser's
+// monthly volume. The current version ends when the new one takes effect.
+// PUT /api/admin/fee-rules
+func (h *FeeRuleHandler) SaveFeeRule(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	var req struct {
+		Scope         string           `json:"scope" binding:"required"`
+		ScopeValue    string           `json:"scope_value"`
+		Name          string           `json:"name"`
+		Mode          string           `json:"mode"`
+		RateBps       int64            `json:"rate_bps"`
+		VolumeBasis   string           `json:"volume_basis"`
+		Tiers         []models.FeeTier `json:"tiers"`
+		MinFee        float64          `json:"min_fee"`
+		EffectiveFrom *time.Time       `json:"effective_from"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.fail(c, correlationID, http.StatusBadRequest, err)
+		return
+	}
+
+	rule := models.FeeRule{
+		Scope:       models.FeeRuleScope(req.Scope),
+		ScopeValue:  req.ScopeValue,
+		Name:        req.Name,
+		Mode:        models.FeeMode(req.Mode),
+		RateBps:     req.RateBps,
+		VolumeBasis: models.FeeVolumeBasis(req.VolumeBasis),
+		MinFee:      req.MinFee,
+	}
+	if req.EffectiveFrom != nil {
+		rule.EffectiveFrom = req.EffectiveFrom.UTC()
+	}
+	if err := h.feeService.Save(middleware.GetTenantID(c), &rule, req.Tiers, requestActor(c)); err != nil {
+		h.fail(c, correlationID, feeRuleErrorStatus(err), err)
+		return
+	}
+	h.ok(c, correlationID, rule)
+}
+
+// RetireFeeRule ends the current version of a rule; conversions fall back
+// to the next matching rule
+// DELETE /api/admin/fee-rules/:id
+func (h *FeeRuleHandler) RetireFeeRule(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	ruleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.fail(c, correlationID, http.StatusBadRequest, errors.New("Invalid fee rule ID"))
+		return
+	}
+	rule, err := h.feeService.Retire(middleware.GetTenantID(c), ruleID)
+	if err != nil {
+		h.fail(c, correlationID, feeRuleErrorStatus(err), err)
+		return
+	}
+	h.ok(c, correlationID, rule)
+}
+
+// PreviewFee quotes the fee of a conversion of an offer by a promoter, as
+// the ledger would book it
+// POST /api/admin/fee-rules/preview
+func (h *FeeRuleHandler) PreviewFee(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	var req struct {
+		OfferID    string     `json:"offer_id"`
+		PromoterID string     `json:"promoter_id"`
+		Currency   string     `json:"currency" binding:"required"`
+		Commission float64    `json:"commission" binding:"required,gt=0"`
+		At         *time.Time `json:"at"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.fail(c, correlationID, http.StatusBadRequest, err)
+		return
+	}
+	currency := services.NormalizeCurrency(req.Currency)
+	if !services.ValidCurrency(currency) {
+		h.fail(c, correlationID, http.StatusBadRequest, errors.New("Invalid currency"))
+		return
+	}
+	var offerID, promoterID uuid.UUID
+	var err error
+	if req.OfferID != "" {
+		if offerID, err = uuid.Parse(req.OfferID); err != nil {
+			h.fail(c, correlationID, http.StatusBadRequest, errors.New("Invalid offer ID"))
+			return
+		}
+	}
+	if req.PromoterID != "" {
+		if promoterID, err = uuid.Parse(req.PromoterID); err != nil {
+			h.fail(c, correlationID, http.StatusBadRequest, errors.New("Invalid promoter ID"))
+			return
+		}
+	}
+	at := time.Now().UTC()
+	if req.At != nil {
+		at = req.At.UTC()
+	}
+
+	commission := services.MajorToMinor(req.Commission, currency)
+	quote, err := h.feeService.Preview(middleware.GetTenantID(c), offerID, promoterID, currency, commission, at)
+	if err != nil {
+		h.fail(c, correlationID, feeRuleErrorStatus(err), err)
+		return
+	}
+	h.ok(c, correlationID, gin.H{
+		"quote":      quote,
+		"currency":   currency,
+		"fee":        services.MinorToMajor(quote.Fee, currency),
+		"commission": services.MinorToMajor(quote.Commission, currency),
+		"charge":     services.MinorToMajor(quote.Charge, currency),
+	})
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// FX RATES HANDLER
+// ============================================
+
+// maxFXImportSize bounds uploaded rate files
+const maxFXImportSize = 5 << 20
+
+// FXHandler manages stored exchange rates
+type FXHandler struct {
+	db        *gorm.DB
+	fxService *services.FXService
+}
+
+// NewFXHandler creates a new FX handler
+func NewFXHandler(db *gorm.DB) *FXHandler {
+	return &FXHandler{
+		db:        db,
+		fxService: services.GetFXService(db),
+	}
+}
+
+func (h *FXHandler) fail(c *gin.Context, correlationID string, status int, err error) {
+	c.JSON(status, gin.H{
+		"success":        false,
+		"correlation_id": correlationID,
+		"error":          err.Error(),
+	})
+}
+
+func (h *FXHandler) ok(c *gin.Context, correlationID string, data interface{}) {
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           data,
+	})
+}
+
+// fxDate parses an optional YYYY-MM-DD query parameter, defaulting to today (UTC)
+func fxDate(c *gin.Context) (time.Time, error) {
+	date, err := optionalDate(c, "date", time.UTC)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if date == nil {
+		return time.Now().UTC(), nil
+	}
+	return *date, nil
+}
+
+// GetRates lists the stored rates of a day (the latest imported day by default)
+// GET /api/admin/fx/rates?date=2025-01-31&base=USD
+func (h *FXHandler) GetRates(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	date, err := optionalDate(c, "date", time.UTC)
+	if err != nil {
+		h.fail(c, correlationID, http.StatusBadRequest, err)
+		return
+	}
+	var day time.Time
+	if date != nil {
+		day = *date
+	}
+
+	rates, err := h.fxService.ListRates(day, c.Query("base"))
+	if err != nil {
+		h.fail(c, correlationID, http.StatusInternalServerError, err)
+		return
+	}
+	h.ok(c, correlationID, gin.H{
+		"rates":  rates,
+		"source": h.fxService.SourceName(),
+	})
+}
+
+// Convert converts an amount with the stored rates
+// GET /api/admin/fx/convert?amount=12.5&from=KWD&to=USD&date=2025-01-31
+func (h *FXHandler) Convert(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	from, to := c.Query("from"), c.Query("to")
+	if !services.ValidCurrency(from) || !services.ValidCurrency(to) {
+		h.fail(c, correlationID, http.StatusBadRequest, errors.New("from and to must be ISO 4217 codes"))
+		return
+	}
+	amount, err := strconv.ParseFloat(c.Query("amount"), 64)
+	if err != nil {
+		h.fail(c, correlationID, http.StatusBadRequest, errors.New("invalid amount"))
+		return
+	}
+	date, err := fxDate(c)
+	if err != nil {
+		h.fail(c, correlationID, http.StatusBadRequest, err)
+		return
+	}
+
+	converted, conversion, err := h.fxService.Convert(services.MoneyFromMajor(amount, from), to, date)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrFXRateNotFound) {
+			status = http.StatusNotFound
+		}
+		h.fail(c, correlationID, status, err)
+		return
+	}
+	h.ok(c, correlationID, gin.H{
+		"amount":     services.MoneyFromMajor(amount, from).String(),
+		"converted":  converted.String(),
+		"value":      converted.Major(),
+		"conversion": conversion,
+	})
+}
+
+// ImportRates imports rates from a CSV file (date,base,quote,rate), sent as a
+// multipart "file" field or as the raw request body
+// POST /api/admin/fx/rates/import
+func (h *FXHandler) ImportRates(c *gin.Context) {
+	correlationID := generateCorrelationID()
+
+	var reader io.Reader
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			h.fail(c, correlationID, http.StatusBadRequest, err)
+			return
+		}
+		defer f.Close()
+		reader = f
+	} else {
+		reader = c.Request.Body
+	}
+
+	imported, err := h.fxService.ImportCSV(io.LimitReader(reader, maxFXImportSize))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrFXInvalidRate) {
+			status = http.StatusBadRequest
+		}
+		h.fail(c, correlationID, status, err)
+		return
+	}
+	h.ok(c, correlationID, gin.H{"imported": imported})
+}
+
+// RefreshRates fetches a day's rates from the configured source
+// POST /api/admin/fx/rates/refresh?date=2025-01-31
+func (h *FXHandler) RefreshRates(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	date, err := fxDate(c)
+	if err != nil {
+		h.fail(c, correlationID, http.StatusBadRequest, err)
+		return
+	}
+
+	imported, err := h.fxService.Refresh(c.Request.Context(), date)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, services.ErrFXNoSource) {
+			status = http.StatusServiceUnavailable
+		}
+		h.fail(c, correlationID, status, err)
+		return
+	}
+	h.ok(c, correlationID, gin.H{"imported": imported, "source": h.fxService.SourceName()})
+}
@@ -1,11 +1,18 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"path/filepath"
 	"strconv"
 	"time"
 
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
 	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -28,7 +35,7 @@ func (h *InvoiceHandler) GetMyInvoices(c *gin.Context) {
 	}
 
 	var invoices []models.Invoice
-	if err := h.db.Where("advertiser_id = ?", userID).
+	if err := tenantDB(c, h.db).Where("advertiser_id = ?", userID).
 		Order("year DESC, month DESC").
 		Find(&invoices).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invoices"})
@@ -39,15 +46,15 @@ func (h *InvoiceHandler) GetMyInvoices(c *gin.Context) {
 	var summary models.InvoiceSummary
 	for _, inv := range invoices {
 		summary.TotalInvoices++
-		summary.TotalAmount += inv.PlatformAmount
+		summary.TotalAmount += inv.AmountDue()
 		switch inv.Status {
 		case "paid":
-			summary.PaidAmount += inv.PlatformAmount
+			summary.PaidAmount += inv.AmountDue()
 		case "pending":
-			summary.PendingAmount += inv.PlatformAmount
+			summary.PendingAmount += inv.AmountDue()
 			summary.PendingCount++
 		case "overdue":
-			summary.OverdueAmount += inv.PlatformAmount
+			summary.OverdueAmount += inv.AmountDue()
 			summary.OverdueCount++
 		}
 	}
@@ -64,7 +71,7 @@ func (h *InvoiceHandler) GetInvoice(c *gin.Context) {
 	userID, _ := c.Get("userID")
 
 	var invoice models.Invoice
-	if err := h.db.Where("id = ? AND advertiser_id = ?", invoiceID, userID).
+	if err := tenantDB(c, h.db).Where("id = ? AND advertiser_id = ?", invoiceID, userID).
 		First(&invoice).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
 		return
@@ -72,7 +79,7 @@ func (h *InvoiceHandler) GetInvoice(c *gin.Context) {
 
 	// Get invoice items
 	var items []models.InvoiceItem
-	h.db.Where("invoice_id = ?", invoiceID).Find(&items)
+	tenantDB(c, h.db).Where("invoice_id = ?", invoiceID).Order("position, created_at").Find(&items)
 
 	c.JSON(http.StatusOK, gin.H{
 		"invoice": invoice,
@@ -80,43 +87,88 @@ func (h *InvoiceHandler) GetInvoice(c *gin.Context) {
 	})
 }
 
-// ConfirmPayment allows advertiser to confirm payment and upload proof
-func (h *InvoiceHandler) ConfirmPayment(c *gin.Context) {
-	invoiceID := c.Param("id")
-	userID, _ := c.Get("userID")
-
-	var req struct {
-		PaymentProof  string `json:"payment_proof"`
-		PaymentMethod string `json:"payment_method"`
-		PaymentNote   string `json:"payment_note"`
+// invoicePaymentErrorStatus maps invoice payment and receipt errors to HTTP statuses
+func invoicePaymentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvoiceNotFound), errors.Is(err, services.ErrReceiptNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvoiceNotPayable), errors.Is(err, services.ErrReceiptEmpty),
+		errors.Is(err, services.ErrReceiptType):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrInvoiceNotAwaitingReview):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrReceiptTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, services.ErrReceiptStorageUnavailable):
+		return http.StatusServiceUnavailable
 	}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+	return http.StatusInternalServerError
+}
+
+// invoicePaymentError responds with a payment error; internal errors are not exposed
+func invoicePaymentError(c *gin.Context, err error, fallback string) {
+	status := invoicePaymentErrorStatus(err)
+	if status == http.StatusInternalServerError {
+		c.JSON(status, gin.H{"error": fallback})
 		return
 	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
 
-	var invoice models.Invoice
-	if err := h.db.Where("id = ? AND advertiser_id = ?", invoiceID, userID).
-		First(&invoice).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+// ConfirmPayment submits the advertiser's payment of an invoice for review,
+// either as a multipart upload of the receipt ("file": PDF, PNG or JPEG) with
+// payment_method and payment_note form fields, or as JSON with a
+// payment_proof URL. The invoice waits in pending_confirmation for an admin.
+// POST /api/advertiser/invoices/:id/confirm-payment
+func (h *InvoiceHandler) ConfirmPayment(c *gin.Context) {
+	invoiceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
 		return
 	}
-
-	if invoice.Status == "paid" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invoice already paid"})
+	userID := requestActor(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	// Update invoice with payment info (status remains pending until admin confirms)
-	now := time.Now()
-	invoice.PaymentProof = req.PaymentProof
-	invoice.PaymentMethod = req.PaymentMethod
-	invoice.PaymentNote = req.PaymentNote
-	invoice.PaidAt = &now
-	invoice.Status = "pending_confirmation" // Waiting for admin to confirm
+	collections := services.GetInvoiceCollectionsService(h.db)
+	var payment services.InvoicePayment
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+			return
+		}
+		defer f.Close()
+		// One byte over the limit is enough to reject the upload
+		data, err := io.ReadAll(io.LimitReader(f, collections.MaxReceiptBytes()+1))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+			return
+		}
+		payment = services.InvoicePayment{
+			Method:      c.PostForm("payment_method"),
+			Note:        c.PostForm("payment_note"),
+			Receipt:     data,
+			ReceiptName: filepath.Base(file.Filename),
+		}
+	} else {
+		var req struct {
+			PaymentProof  string `json:"payment_proof"`
+			PaymentMethod string `json:"payment_method"`
+			PaymentNote   string `json:"payment_note"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+		payment = services.InvoicePayment{Method: req.PaymentMethod, Note: req.PaymentNote, ProofURL: req.PaymentProof}
+	}
 
-	if err := h.db.Save(&invoice).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update invoice"})
+	invoice, err := collections.SubmitPayment(c.Request.Context(), middleware.GetTenantID(c), *userID, invoiceID, payment)
+	if err != nil {
+		invoicePaymentError(c, err, "Failed to update invoice")
 		return
 	}
 
@@ -126,6 +178,40 @@ func (h *InvoiceHandler) ConfirmPayment(c *gin.Context) {
 	})
 }
 
+// sendReceipt returns the receipt uploaded for an invoice
+func (h *InvoiceHandler) sendReceipt(c *gin.Context, advertiserID *uuid.UUID) {
+	invoiceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	invoice, data, err := services.GetInvoiceCollectionsService(h.db).Receipt(
+		c.Request.Context(), middleware.GetTenantID(c), invoiceID, advertiserID)
+	if err != nil {
+		invoicePaymentError(c, err, "Failed to load receipt")
+		return
+	}
+
+	name := invoice.ReceiptName
+	if name == "" {
+		name = "receipt"
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	c.Data(http.StatusOK, invoice.ReceiptType, data)
+}
+
+// DownloadReceipt returns the receipt the advertiser uploaded for an invoice
+// GET /api/advertiser/invoices/:id/receipt
+func (h *InvoiceHandler) DownloadReceipt(c *gin.Context) {
+	userID := requestActor(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	h.sendReceipt(c, userID)
+}
+
 // ============ ADMIN ENDPOINTS ============
 
 // AdminGetAllInvoices returns all invoices for admin
@@ -134,7 +220,7 @@ func (h *InvoiceHandler) AdminGetAllInvoices(c *gin.Context) {
 	month := c.Query("month")
 	year := c.Query("year")
 
-	query := h.db.Preload("Advertiser").Order("created_at DESC")
+	query := tenantDB(c, h.db).Preload("Advertiser").Order("created_at DESC")
 
 	if status != "" {
 		query = query.Where("status = ?", status)
@@ -160,15 +246,15 @@ func (h *InvoiceHandler) AdminGetAllInvoices(c *gin.Context) {
 	var summary models.InvoiceSummary
 	for _, inv := range invoices {
 		summary.TotalInvoices++
-		summary.TotalAmount += inv.PlatformAmount
+		summary.TotalAmount += inv.AmountDue()
 		switch inv.Status {
 		case "paid":
-			summary.PaidAmount += inv.PlatformAmount
+			summary.PaidAmount += inv.AmountDue()
 		case "pending", "pending_confirmation":
-			summary.PendingAmount += inv.PlatformAmount
+			summary.PendingAmount += inv.AmountDue()
 			summary.PendingCount++
 		case "overdue":
-			summary.OverdueAmount += inv.PlatformAmount
+			summary.OverdueAmount += inv.AmountDue()
 			summary.OverdueCount++
 		}
 	}
@@ -179,31 +265,31 @@ func (h *InvoiceHandler) AdminGetAllInvoices(c *gin.Context) {
 	})
 }
 
-// AdminConfirmPayment confirms an invoice payment
+// AdminDownloadReceipt returns the receipt uploaded for any invoice of the tenant
+// GET /api/admin/invoices/:id/receipt
+func (h *InvoiceHandler) AdminDownloadReceipt(c *gin.Context) {
+	h.sendReceipt(c, nil)
+}
+
+// AdminConfirmPayment confirms an invoice payment. Offers paused because the
+// invoice was overdue resume.
 func (h *InvoiceHandler) AdminConfirmPayment(c *gin.Context) {
-	invoiceID := c.Param("id")
-	adminID, _ := c.Get("userID")
+	invoiceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
 
 	var req struct {
 		ReviewNote string `json:"review_note"`
 	}
 	c.ShouldBindJSON(&req)
 
-	var invoice models.Invoice
-	if err := h.db.First(&invoice, "id = ?", invoiceID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
-		return
-	}
-
-	now := time.Now()
-	adminUUID := adminID.(uuid.UUID)
-	invoice.Status = "paid"
-	invoice.ReviewedBy = &adminUUID
-	invoice.ReviewedAt = &now
-	invoice.ReviewNote = req.ReviewNote
-
-	if err := h.db.Save(&invoice).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update invoice"})
+	// The paid invoice settles the advertiser's receivable in the ledger
+	invoice, err := services.GetInvoiceCollectionsService(h.db).ConfirmPayment(
+		middleware.GetTenantID(c), invoiceID, requestActor(c), req.ReviewNote)
+	if err != nil {
+		invoicePaymentError(c, err, "Failed to update invoice")
 		return
 	}
 
@@ -213,10 +299,14 @@ func (h *InvoiceHandler) AdminConfirmPayment(c *gin.Context) {
 	})
 }
 
-// AdminRejectPayment rejects a payment (e.g., invalid proof)
+// AdminRejectPayment rejects a submitted payment (e.g., invalid proof). The
+// invoice is open again and the advertiser is told why.
 func (h *InvoiceHandler) AdminRejectPayment(c *gin.Context) {
-	invoiceID := c.Param("id")
-	adminID, _ := c.Get("userID")
+	invoiceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
 
 	var req struct {
 		ReviewNote string `json:"review_note" binding:"required"`
@@ -226,23 +316,10 @@ func (h *InvoiceHandler) AdminRejectPayment(c *gin.Context) {
 		return
 	}
 
-	var invoice models.Invoice
-	if err := h.db.First(&invoice, "id = ?", invoiceID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
-		return
-	}
-
-	now := time.Now()
-	adminUUID := adminID.(uuid.UUID)
-	invoice.Status = "pending" // Back to pending
-	invoice.PaymentProof = ""
-	invoice.PaidAt = nil
-	invoice.ReviewedBy = &adminUUID
-	invoice.ReviewedAt = &now
-	invoice.ReviewNote = req.ReviewNote
-
-	if err := h.db.Save(&invoice).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update invoice"})
+	invoice, err := services.GetInvoiceCollectionsService(h.db).RejectPayment(
+		middleware.GetTenantID(c), invoiceID, requestActor(c), req.ReviewNote)
+	if err != nil {
+		invoicePaymentError(c, err, "Failed to update invoice")
 		return
 	}
 
@@ -252,7 +329,8 @@ func (h *InvoiceHandler) AdminRejectPayment(c *gin.Context) {
 	})
 }
 
-// AdminGenerateMonthlyInvoices generates invoices for all advertisers
+// AdminGenerateMonthlyInvoices issues the month's itemised invoices for all
+// advertisers. Running it again only adds invoices that are still missing.
 func (h *InvoiceHandler) AdminGenerateMonthlyInvoices(c *gin.Context) {
 	var req struct {
 		Month int `json:"month" binding:"required,min=1,max=12"`
@@ -263,76 +341,21 @@ func (h *InvoiceHandler) AdminGenerateMonthlyInvoices(c *gin.Context) {
 		return
 	}
 
-	// Get all advertisers
-	var advertisers []models.AfftokUser
-	if err := h.db.Where("role = ? AND status = ?", "advertiser", "active").
-		Find(&advertisers).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch advertisers"})
+	// Billing months follow the tenant's timezone
+	report, err := services.GetInvoiceService(h.db).GenerateMonthly(
+		middleware.GetTenantID(c), req.Year, req.Month, tenantLocation(c, h.db))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invoices"})
 		return
 	}
 
-	platformRate := 0.10 // 10%
-	createdCount := 0
-	skippedCount := 0
-
-	for _, advertiser := range advertisers {
-		// Check if invoice already exists
-		var existing models.Invoice
-		if err := h.db.Where("advertiser_id = ? AND month = ? AND year = ?",
-			advertiser.ID, req.Month, req.Year).First(&existing).Error; err == nil {
-			skippedCount++
-			continue
-		}
-
-		// Calculate total conversions and payouts for this advertiser's offers
-		var result struct {
-			TotalConversions int
-			TotalPayout      float64
-		}
-		
-		// Get conversions for advertiser's offers in this period
-		periodStart := time.Date(req.Year, time.Month(req.Month), 1, 0, 0, 0, 0, time.UTC)
-		periodEnd := periodStart.AddDate(0, 1, 0).Add(-time.Second)
-		
-		h.db.Table("conversions").
-			Select("COUNT(*) as total_conversions, COALESCE(SUM(payout), 0) as total_payout").
-			Joins("JOIN offers ON conversions.offer_id = offers.id").
-			Where("offers.advertiser_id = ? AND conversions.created_at BETWEEN ? AND ?",
-				advertiser.ID, periodStart, periodEnd).
-			Scan(&result)
-
-		// Skip if no conversions
-		if result.TotalConversions == 0 {
-			skippedCount++
-			continue
-		}
-
-		// Create invoice
-		invoice := models.Invoice{
-			AdvertiserID:        advertiser.ID,
-			Month:               req.Month,
-			Year:                req.Year,
-			PeriodStart:         periodStart,
-			PeriodEnd:           periodEnd,
-			TotalConversions:    result.TotalConversions,
-			TotalPromoterPayout: result.TotalPayout,
-			PlatformRate:        platformRate,
-			PlatformAmount:      result.TotalPayout * platformRate,
-			Currency:            "KWD",
-			Status:              "pending",
-			DueDate:             periodEnd.AddDate(0, 0, 7), // Due 7 days after period end
-		}
-
-		if err := h.db.Create(&invoice).Error; err != nil {
-			continue
-		}
-		createdCount++
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"message":       "Invoice generation completed",
-		"created_count": createdCount,
-		"skipped_count": skippedCount,
+		"created_count": report.Created,
+		"skipped_count": report.Skipped,
+		"failed_count":  report.Failed,
+		"invoices":      report.Invoices,
+		"errors":        report.Errors,
 	})
 }
 
@@ -348,24 +371,196 @@ func (h *InvoiceHandler) AdminGetInvoiceSummary(c *gin.Context) {
 		ThisMonthPending int64   `json:"this_month_pending"`
 	}
 
-	h.db.Model(&models.Invoice{}).Count(&summary.TotalInvoices)
-	h.db.Model(&models.Invoice{}).Select("COALESCE(SUM(platform_amount), 0)").Scan(&summary.TotalAmount)
-	h.db.Model(&models.Invoice{}).Where("status = ?", "paid").
-		Select("COALESCE(SUM(platform_amount), 0)").Scan(&summary.PaidAmount)
-	h.db.Model(&models.Invoice{}).Where("status IN ?", []string{"pending", "pending_confirmation"}).
-		Select("COALESCE(SUM(platform_amount), 0)").Scan(&summary.PendingAmount)
-	h.db.Model(&models.Invoice{}).Where("status = ?", "overdue").
-		Select("COALESCE(SUM(platform_amount), 0)").Scan(&summary.OverdueAmount)
-
-	// This month
-	now := time.Now()
-	h.db.Model(&models.Invoice{}).
+	tenantDB(c, h.db).Model(&models.Invoice{}).Count(&summary.TotalInvoices)
+	tenantDB(c, h.db).Model(&models.Invoice{}).Select("COALESCE(SUM(total_amount), 0)").Scan(&summary.TotalAmount)
+	tenantDB(c, h.db).Model(&models.Invoice{}).Where("status = ?", "paid").
+		Select("COALESCE(SUM(total_amount), 0)").Scan(&summary.PaidAmount)
+	tenantDB(c, h.db).Model(&models.Invoice{}).Where("status IN ?", []string{"pending", "pending_confirmation"}).
+		Select("COALESCE(SUM(total_amount), 0)").Scan(&summary.PendingAmount)
+	tenantDB(c, h.db).Model(&models.Invoice{}).Where("status = ?", "overdue").
+		Select("COALESCE(SUM(total_amount), 0)").Scan(&summary.OverdueAmount)
+
+	// This month (in the tenant's timezone)
+	now := time.Now().In(tenantLocation(c, h.db))
+	tenantDB(c, h.db).Model(&models.Invoice{}).
 		Where("month = ? AND year = ?", int(now.Month()), now.Year()).
-		Select("COALESCE(SUM(platform_amount), 0)").Scan(&summary.ThisMonthAmount)
-	h.db.Model(&models.Invoice{}).
+		Select("COALESCE(SUM(total_amount), 0)").Scan(&summary.ThisMonthAmount)
+	tenantDB(c, h.db).Model(&models.Invoice{}).
 		Where("month = ? AND year = ? AND status IN ?", int(now.Month()), now.Year(), []string{"pending", "pending_confirmation"}).
 		Count(&summary.ThisMonthPending)
 
 	c.JSON(http.StatusOK, summary)
 }
 
+// ============ PDF ============
+
+// sendInvoicePDF renders an invoice as a bilingual PDF download
+func (h *InvoiceHandler) sendInvoicePDF(c *gin.Context, advertiserID *uuid.UUID) {
+	invoiceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	pdf, invoice, err := services.GetInvoiceService(h.db).RenderPDF(middleware.GetTenantID(c), invoiceID, advertiserID)
+	if errors.Is(err, services.ErrInvoiceNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render invoice"})
+		return
+	}
+
+	name := invoice.Number
+	if name == "" {
+		name = fmt.Sprintf("invoice-%d-%02d", invoice.Year, invoice.Month)
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, name))
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+// DownloadInvoicePDF returns one of the advertiser's invoices as a PDF
+// GET /api/advertiser/invoices/:id/pdf
+func (h *InvoiceHandler) DownloadInvoicePDF(c *gin.Context) {
+	userID := requestActor(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	h.sendInvoicePDF(c, userID)
+}
+
+// AdminDownloadInvoicePDF returns any invoice of the tenant as a PDF
+// GET /api/admin/invoices/:id/pdf
+func (h *InvoiceHandler) AdminDownloadInvoicePDF(c *gin.Context) {
+	h.sendInvoicePDF(c, nil)
+}
+
+// ============ ADJUSTMENTS ============
+
+// AdminListAdjustments lists manual invoice adjustments
+// GET /api/admin/invoices/adjustments?advertiser_id=&unbilled=true
+func (h *InvoiceHandler) AdminListAdjustments(c *gin.Context) {
+	var advertiserID *uuid.UUID
+	if raw := c.Query("advertiser_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid advertiser ID"})
+			return
+		}
+		advertiserID = &id
+	}
+
+	adjustments, err := services.GetInvoiceService(h.db).ListAdjustments(
+		middleware.GetTenantID(c), advertiserID, c.Query("unbilled") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch adjustments"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"adjustments": adjustments, "total": len(adjustments)})
+}
+
+// AdminCreateAdjustment adds a charge (positive) or credit (negative) to an
+// advertiser's next invoice. Amounts are minor units of the currency.
+// POST /api/admin/invoices/adjustments
+func (h *InvoiceHandler) AdminCreateAdjustment(c *gin.Context) {
+	var req struct {
+		AdvertiserID uuid.UUID `json:"advertiser_id" binding:"required"`
+		Currency     string    `json:"currency" binding:"required,len=3"`
+		Amount       int64     `json:"amount" binding:"required"`
+		Description  string    `json:"description" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	adjustment := models.InvoiceAdjustment{
+		AdvertiserID: req.AdvertiserID,
+		Currency:     req.Currency,
+		Amount:       req.Amount,
+		Description:  req.Description,
+		CreatedBy:    requestActor(c),
+	}
+	err := services.GetInvoiceService(h.db).CreateAdjustment(middleware.GetTenantID(c), &adjustment)
+	if errors.Is(err, services.ErrInvoiceAdjustmentInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create adjustment"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"adjustment": adjustment})
+}
+
+// ============ TAX RULES ============
+
+// AdminListTaxRules lists the tenant's tax rules and the platform defaults
+// GET /api/admin/invoices/tax-rules
+func (h *InvoiceHandler) AdminListTaxRules(c *gin.Context) {
+	rules, err := services.GetInvoiceService(h.db).ListTaxRules(middleware.GetTenantID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tax rules"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tax_rules": rules})
+}
+
+// AdminSaveTaxRule sets the tax applied to advertisers of a country
+// ("*" for everyone else). The rate is in basis points: 1500 = 15%.
+// PUT /api/admin/invoices/tax-rules
+func (h *InvoiceHandler) AdminSaveTaxRule(c *gin.Context) {
+	var req struct {
+		Country       string `json:"country" binding:"required"`
+		Name          string `json:"name" binding:"required"`
+		NameAr        string `json:"name_ar"`
+		RateBps       int64  `json:"rate_bps" binding:"min=0,max=10000"`
+		ReverseCharge bool   `json:"reverse_charge"`
+		Active        *bool  `json:"active"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := models.TaxRule{
+		Country:       req.Country,
+		Name:          req.Name,
+		NameAr:        req.NameAr,
+		RateBps:       req.RateBps,
+		ReverseCharge: req.ReverseCharge,
+		Active:        req.Active == nil || *req.Active,
+	}
+	err := services.GetInvoiceService(h.db).SaveTaxRule(middleware.GetTenantID(c), &rule)
+	if errors.Is(err, services.ErrTaxRuleInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save tax rule"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tax_rule": rule})
+}
+
+// AdminDeleteTaxRule removes one of the tenant's tax rules
+// DELETE /api/admin/invoices/tax-rules/:id
+func (h *InvoiceHandler) AdminDeleteTaxRule(c *gin.Context) {
+	ruleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tax rule ID"})
+		return
+	}
+	err = services.GetInvoiceService(h.db).DeleteTaxRule(middleware.GetTenantID(c), ruleID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tax rule not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tax rule"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Tax rule deleted"})
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"io"
 	"net/http"
 
 	"github.com/aljapah/afftok-backend-prod/internal/models"
@@ -12,18 +13,44 @@ import (
 
 // KYCSimpleHandler handles simplified KYC operations
 type KYCSimpleHandler struct {
-	db         *gorm.DB
-	kycService *services.KYCAutoService
+	db                  *gorm.DB
+	kycService          *services.KYCAutoService
+	verificationService *services.KYCVerificationService
+	payoutService       *services.PayoutService
 }
 
 // NewKYCSimpleHandler creates a new KYC handler
 func NewKYCSimpleHandler(db *gorm.DB) *KYCSimpleHandler {
 	return &KYCSimpleHandler{
-		db:         db,
-		kycService: services.NewKYCAutoService(db),
+		db:                  db,
+		kycService:          services.NewKYCAutoService(db),
+		verificationService: services.NewKYCVerificationService(db),
+		payoutService:       services.NewPayoutService(db),
 	}
 }
 
+// requireTenantUser reports whether the user belongs to the request's tenant,
+// writing a 404 when not
+func (h *KYCSimpleHandler) requireTenantUser(c *gin.Context, uid uuid.UUID) bool {
+	var count int64
+	tenantDB(c, h.db).Model(&models.AfftokUser{}).Where("id = ?", uid).Count(&count)
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return false
+	}
+	return true
+}
+
+// SetVerificationService sets the KYC verification service (for dependency injection)
+func (h *KYCSimpleHandler) SetVerificationService(service *services.KYCVerificationService) {
+	h.verificationService = service
+}
+
+// SetPayoutService sets the payout service (for dependency injection)
+func (h *KYCSimpleHandler) SetPayoutService(service *services.PayoutService) {
+	h.payoutService = service
+}
+
 // GetMyKYCStatus returns current user's KYC status
 // GET /api/kyc/status
 func (h *KYCSimpleHandler) GetMyKYCStatus(c *gin.Context) {
@@ -33,8 +60,8 @@ func (h *KYCSimpleHandler) GetMyKYCStatus(c *gin.Context) {
 		return
 	}
 
-	uid, err := uuid.Parse(userID.(string))
-	if err != nil {
+	uid, ok := userID.(uuid.UUID)
+	if !ok {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
@@ -60,9 +87,14 @@ func (h *KYCSimpleHandler) GetMyKYCStatus(c *gin.Context) {
 		response["kyc_verified_at"] = user.KYCVerifiedAt
 	}
 
-	// If KYC is required, include verification URL
+	// Include the latest provider verification (steps, reasons, attempts left)
+	if verification, err := h.verificationService.GetLatestVerification(uid); err == nil {
+		response["verification"] = verification
+		response["attempts_remaining"] = verification.AttemptsRemaining()
+	}
+
 	if user.RequiresKYC() {
-		response["verification_url"] = "https://verify.sumsub.com/idensic/l/#/afftok" // مثال - يتم تغييره لمزود حقيقي
+		response["providers"] = h.verificationService.Providers()
 		response["message"] = "Identity verification required to continue promoting"
 		response["message_ar"] = "مطلوب تأكيد الهوية لاستمرار الترويج"
 	}
@@ -70,45 +102,69 @@ func (h *KYCSimpleHandler) GetMyKYCStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// ProviderWebhook receives verification result from external KYC provider
-// POST /api/kyc/webhook
-// This is called by SumSub/Veriff/Persona when verification completes
-func (h *KYCSimpleHandler) ProviderWebhook(c *gin.Context) {
-	// Verify webhook signature (implement based on provider)
-	// For now, we accept a simple JSON payload
-	
-	var req struct {
-		UserID       string `json:"user_id" binding:"required"`
-		ExternalID   string `json:"external_id"`
-		ReviewStatus string `json:"review_status" binding:"required"` // "completed", "rejected"
-		ReviewResult string `json:"review_result"`                    // "GREEN", "RED"
-		ProviderName string `json:"provider"`                         // "sumsub", "veriff", "persona"
+// StartVerification creates or resumes the user's applicant with a KYC
+// provider and returns the SDK access token / verification URL
+// POST /api/kyc/start
+func (h *KYCSimpleHandler) StartVerification(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	uid, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
 		return
 	}
 
-	uid, err := uuid.Parse(req.UserID)
+	var req struct {
+		Provider string `json:"provider"` // "sumsub", "veriff" (default from KYC_DEFAULT_PROVIDER)
+	}
+	c.ShouldBindJSON(&req)
+
+	result, err := h.verificationService.StartVerification(c.Request.Context(), uid, req.Provider)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		c.JSON(services.KYCErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	// Determine if verified based on provider response
-	verified := req.ReviewStatus == "completed" && req.ReviewResult == "GREEN"
-	providerRef := req.ProviderName + ":" + req.ExternalID
+	c.JSON(http.StatusOK, gin.H{
+		"success":            true,
+		"verification":       result.Verification,
+		"access":             result.AccessToken,
+		"attempts_remaining": result.Verification.AttemptsRemaining(),
+	})
+}
+
+// ProviderWebhook receives verification events from an external KYC provider.
+// The raw body is checked against the provider's signature before use.
+// POST /api/kyc/webhook/:provider
+// POST /api/kyc/webhook?provider=sumsub
+func (h *KYCSimpleHandler) ProviderWebhook(c *gin.Context) {
+	providerName := c.Param("provider")
+	if providerName == "" {
+		providerName = c.Query("provider")
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read body"})
+		return
+	}
 
-	if err := h.kycService.UpdateKYCFromProvider(uid, verified, providerRef); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update KYC status"})
+	verification, result, err := h.verificationService.HandleWebhook(providerName, c.Request.Header, body)
+	if err != nil {
+		c.JSON(services.KYCErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":  true,
-		"user_id":  req.UserID,
-		"verified": verified,
+		"user_id":  verification.UserID,
+		"status":   verification.Status,
+		"decision": result.Decision,
+		"verified": verification.Status == models.KYCVerificationApproved,
 	})
 }
 
@@ -123,13 +179,16 @@ func (h *KYCSimpleHandler) AdminGetUserKYC(c *gin.Context) {
 	}
 
 	var user models.AfftokUser
-	if err := h.db.Select("id, username, email, kyc_status, kyc_required_at, kyc_verified_at, kyc_provider_ref").
+	if err := tenantDB(c, h.db).Select("id, username, email, kyc_status, kyc_required_at, kyc_verified_at, kyc_provider_ref").
 		First(&user, "id = ?", uid).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
 
+	verifications, _ := h.verificationService.GetVerifications(uid)
+
 	c.JSON(http.StatusOK, gin.H{
+		"verifications":    verifications,
 		"user_id":          user.ID,
 		"username":         user.Username,
 		"email":            user.Email,
@@ -144,7 +203,7 @@ func (h *KYCSimpleHandler) AdminGetUserKYC(c *gin.Context) {
 // GET /api/admin/kyc/pending
 func (h *KYCSimpleHandler) AdminGetUsersRequiringKYC(c *gin.Context) {
 	var users []models.AfftokUser
-	if err := h.db.Where("kyc_status = ?", models.KYCStatusRequired).
+	if err := tenantDB(c, h.db).Where("kyc_status = ?", models.KYCStatusRequired).
 		Select("id, username, email, kyc_status, kyc_required_at").
 		Order("kyc_required_at DESC").
 		Find(&users).Error; err != nil {
@@ -168,6 +227,10 @@ func (h *KYCSimpleHandler) AdminResetKYC(c *gin.Context) {
 		return
 	}
 
+	if !h.requireTenantUser(c, uid) {
+		return
+	}
+
 	if err := h.kycService.ResetKYCStatus(uid); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset KYC status"})
 		return
@@ -189,11 +252,18 @@ func (h *KYCSimpleHandler) AdminManualVerify(c *gin.Context) {
 		return
 	}
 
+	if !h.requireTenantUser(c, uid) {
+		return
+	}
+
 	if err := h.kycService.UpdateKYCFromProvider(uid, true, "admin:manual"); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify user"})
 		return
 	}
 
+	// الإفراج عن المستحقات المحجوزة بسبب KYC
+	h.payoutService.ReleaseKYCHolds(uid)
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "User manually verified",
@@ -210,6 +280,10 @@ func (h *KYCSimpleHandler) AdminTriggerKYC(c *gin.Context) {
 		return
 	}
 
+	if !h.requireTenantUser(c, uid) {
+		return
+	}
+
 	triggered, reason, err := h.kycService.CheckAndTriggerKYC(uid)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check KYC"})
@@ -222,3 +296,27 @@ func (h *KYCSimpleHandler) AdminTriggerKYC(c *gin.Context) {
 	})
 }
 
+
+// AdminResetKYCAttempts gives a rejected user a fresh set of verification attempts
+// POST /api/admin/kyc/:id/reset-attempts
+func (h *KYCSimpleHandler) AdminResetKYCAttempts(c *gin.Context) {
+	uid, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if !h.requireTenantUser(c, uid) {
+		return
+	}
+
+	if err := h.verificationService.ResetAttempts(uid); err != nil {
+		c.JSON(services.KYCErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "KYC attempts reset",
+	})
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LandingBeaconHandler receives landing-page beacons from the web SDK
+type LandingBeaconHandler struct {
+	db            *gorm.DB
+	beaconService *services.LandingBeaconService
+}
+
+// NewLandingBeaconHandler creates a new landing beacon handler
+func NewLandingBeaconHandler(db *gorm.DB) *LandingBeaconHandler {
+	return &LandingBeaconHandler{
+		db:            db,
+		beaconService: services.GetLandingBeaconService(db),
+	}
+}
+
+// SetBeaconService sets the landing beacon service (for dependency injection)
+func (h *LandingBeaconHandler) SetBeaconService(service *services.LandingBeaconService) {
+	h.beaconService = service
+}
+
+// RecordBeacon confirms a landing page view for a click.
+// The SDK sends it with navigator.sendBeacon (text/plain), so the body is
+// parsed as JSON regardless of content type.
+// POST /api/beacon
+func (h *LandingBeaconHandler) RecordBeacon(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil || len(body) == 0 || len(body) > 8192 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid beacon"})
+		return
+	}
+
+	var req services.BeaconRequest
+	if err := json.Unmarshal(body, &req); err != nil || req.Token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid beacon"})
+		return
+	}
+
+	beacon, err := h.beaconService.RecordBeacon(&req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrBeaconInvalidToken) || errors.Is(err, services.ErrBeaconExpired) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"verified": beacon.Verified,
+	})
+}
+
+// GetMyVerifiedVisits returns the current promoter's verified visit rate
+// GET /api/users/me/verified-visits
+func (h *LandingBeaconHandler) GetMyVerifiedVisits(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	uid, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	h.respondStats(c, uid)
+}
+
+// GetPromoterVerifiedVisits returns a promoter's verified visit rate
+// GET /api/admin/fraud/verified-visits/:id
+func (h *LandingBeaconHandler) GetPromoterVerifiedVisits(c *gin.Context) {
+	uid, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var count int64
+	tenantDB(c, h.db).Model(&models.AfftokUser{}).Where("id = ?", uid).Count(&count)
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Promoter not found"})
+		return
+	}
+
+	h.respondStats(c, uid)
+}
+
+func (h *LandingBeaconHandler) respondStats(c *gin.Context, userID uuid.UUID) {
+	stats, err := h.beaconService.GetVerifiedVisitStats(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute verified visits"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"stats":     stats,
+		"min_rate":  services.VerifiedVisitMinRate,
+		"timestamp": time.Now().UTC(),
+	})
+}
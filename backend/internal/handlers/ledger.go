@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// EARNINGS LEDGER HANDLER
+// ============================================
+
+// LedgerHandler exposes balances and postings of the earnings ledger
+type LedgerHandler struct {
+	db            *gorm.DB
+	ledgerService *services.LedgerService
+}
+
+// NewLedgerHandler creates a new ledger handler
+func NewLedgerHandler(db *gorm.DB) *LedgerHandler {
+	return &LedgerHandler{
+		db:            db,
+		ledgerService: services.GetLedgerService(db),
+	}
+}
+
+func (h *LedgerHandler) fail(c *gin.Context, correlationID string, status int, err error) {
+	c.JSON(status, gin.H{
+		"success":        false,
+		"correlation_id": correlationID,
+		"error":          err.Error(),
+	})
+}
+
+func (h *LedgerHandler) ok(c *gin.Context, correlationID string, data interface{}) {
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           data,
+	})
+}
+
+// optionalUUID parses an optional UUID query parameter
+func optionalUUID(c *gin.Context, name string) (*uuid.UUID, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return nil, errors.New("invalid " + name)
+	}
+	return &id, nil
+}
+
+// optionalDate parses an optional YYYY-MM-DD query parameter in the tenant's timezone
+func optionalDate(c *gin.Context, name string, loc *time.Location) (*time.Time, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", raw, loc)
+	if err != nil {
+		return nil, errors.New("invalid " + name + " (expected YYYY-MM-DD)")
+	}
+	return &t, nil
+}
+
+// ledgerSummaryResponse adds the combined balance in the reporting currency
+// when every currency could be converted
+func ledgerSummaryResponse(summary []services.PromoterLedgerSummary) gin.H {
+	resp := gin.H{"balances": summary}
+	if len(summary) == 0 || summary[0].ReportingCurrency == "" {
+		return resp
+	}
+	total := services.NewMoney(0, summary[0].ReportingCurrency)
+	for _, line := range summary {
+		if line.ReportingCurrency != total.Currency {
+			return resp
+		}
+		total.Amount += services.MajorToMinor(line.ReportingBalance, total.Currency)
+	}
+	resp["reporting_currency"] = total.Currency
+	resp["reporting_total"] = total.Major()
+	return resp
+}
+
+// GetMyLedger returns the caller's earnings balance per currency
+// GET /api/ledger/me
+func (h *LedgerHandler) GetMyLedger(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	userID := requestActor(c)
+	if userID == nil {
+		h.fail(c, correlationID, http.StatusUnauthorized, errors.New("User not authenticated"))
+		return
+	}
+
+	summary, err := h.ledgerService.PromoterSummary(middleware.GetTenantID(c), *userID)
+	if err != nil {
+		h.fail(c, correlationID, http.StatusInternalServerError, err)
+		return
+	}
+	h.ok(c, correlationID, ledgerSummaryResponse(summary))
+}
+
+// GetPromoterLedger returns a promoter's earnings balance per currency
+// GET /api/admin/ledger/promoters/:id
+func (h *LedgerHandler) GetPromoterLedger(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	promoterID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.fail(c, correlationID, http.StatusBadRequest, errors.New("Invalid promoter ID"))
+		return
+	}
+
+	summary, err := h.ledgerService.PromoterSummary(middleware.GetTenantID(c), promoterID)
+	if err != nil {
+		h.fail(c, correlationID, http.StatusInternalServerError, err)
+		return
+	}
+	resp := ledgerSummaryResponse(summary)
+	resp["promoter_id"] = promoterID
+	h.ok(c, correlationID, resp)
+}
+
+// GetLedgerAccounts lists account balances
+// GET /api/admin/ledger/accounts?type=promoter_payable&owner_id=...
+func (h *LedgerHandler) GetLedgerAccounts(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	ownerID, err := optionalUUID(c, "owner_id")
+	if err != nil {
+		h.fail(c, correlationID, http.StatusBadRequest, err)
+		return
+	}
+
+	balances, err := h.ledgerService.AccountBalances(middleware.GetTenantID(c), models.LedgerAccountType(c.Query("type")), ownerID)
+	if err != nil {
+		h.fail(c, correlationID, http.StatusInternalServerError, err)
+		return
+	}
+	h.ok(c, correlationID, gin.H{"accounts": balances})
+}
+
+// GetTrialBalance returns per-currency debit and credit totals
+// GET /api/admin/ledger/trial-balance
+func (h *LedgerHandler) GetTrialBalance(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	lines, err := h.ledgerService.TrialBalance(middleware.GetTenantID(c))
+	if err != nil {
+		h.fail(c, correlationID, http.StatusInternalServerError, err)
+		return
+	}
+
+	balanced := true
+	for _, line := range lines {
+		balanced = balanced && line.Balanced
+	}
+	h.ok(c, correlationID, gin.H{"currencies": lines, "balanced": balanced})
+}
+
+// GetLedgerTransactions lists postings, newest first
+// GET /api/admin/ledger/transactions?kind=&promoter_id=&advertiser_id=&from=&to=&limit=&offset=
+func (h *LedgerHandler) GetLedgerTransactions(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	loc := tenantLocation(c, h.db)
+
+	filter := services.LedgerTransactionFilter{
+		Kind:          models.LedgerTransactionKind(c.Query("kind")),
+		ReferenceType: c.Query("reference_type"),
+	}
+	var err error
+	for name, dst := range map[string]**uuid.UUID{
+		"promoter_id":   &filter.PromoterID,
+		"advertiser_id": &filter.AdvertiserID,
+		"reference_id":  &filter.ReferenceID,
+	} {
+		if *dst, err = optionalUUID(c, name); err != nil {
+			h.fail(c, correlationID, http.StatusBadRequest, err)
+			return
+		}
+	}
+	for name, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if *dst, err = optionalDate(c, name, loc); err != nil {
+			h.fail(c, correlationID, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if filter.To != nil {
+		end := filter.To.AddDate(0, 0, 1) // inclusive end date
+		filter.To = &end
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	txns, total, err := h.ledgerService.ListTransactions(middleware.GetTenantID(c), filter, limit, offset)
+	if err != nil {
+		h.fail(c, correlationID, http.StatusInternalServerError, err)
+		return
+	}
+	h.ok(c, correlationID, gin.H{
+		"transactions": txns,
+		"total":        total,
+		"limit":        limit,
+		"offset":       offset,
+	})
+}
+
+// CreateLedgerAdjustment credits or debits a promoter's balance
+// POST /api/admin/ledger/adjustments {"promoter_id", "amount" (minor units), "currency", "reason", "idempotency_key"}
+func (h *LedgerHandler) CreateLedgerAdjustment(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	var req struct {
+		PromoterID     uuid.UUID `json:"promoter_id" binding:"required"`
+		Amount         int64     `json:"amount" binding:"required"`
+		Currency       string    `json:"currency" binding:"required,len=3"`
+		Reason         string    `json:"reason" binding:"required"`
+		IdempotencyKey string    `json:"idempotency_key"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.fail(c, correlationID, http.StatusBadRequest, err)
+		return
+	}
+
+	tenantID := middleware.GetTenantID(c)
+	var promoter models.AfftokUser
+	if err := tenantDB(c, h.db).Select("id").First(&promoter, "id = ?", req.PromoterID).Error; err != nil {
+		h.fail(c, correlationID, http.StatusNotFound, errors.New("Promoter not found"))
+		return
+	}
+
+	key := ""
+	if req.IdempotencyKey != "" {
+		key = "adjustment:" + tenantID.String() + ":" + req.IdempotencyKey
+	}
+
+	var txn *models.LedgerTransaction
+	created := false
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		txn, created, err = h.ledgerService.PostAdjustment(tx, tenantID, promoter.ID, req.Amount, req.Currency, req.Reason, key, requestActor(c))
+		return err
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrLedgerInvalidPosting) || errors.Is(err, services.ErrLedgerUnbalanced) {
+			status = http.StatusBadRequest
+		}
+		h.fail(c, correlationID, status, err)
+		return
+	}
+	h.ok(c, correlationID, gin.H{"transaction": txn, "created": created})
+}
+
+// BackfillLedger books approved conversions that predate the ledger
+// POST /api/admin/ledger/backfill
+func (h *LedgerHandler) BackfillLedger(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	tenantID := middleware.GetTenantID(c)
+	posted, err := h.ledgerService.Backfill(&tenantID, 1000)
+	if err != nil {
+		h.fail(c, correlationID, http.StatusInternalServerError, err)
+		return
+	}
+	h.ok(c, correlationID, gin.H{"posted": posted})
+}
+
+// ReconcileLedger compares the ledger with conversions and earnings counters
+// GET /api/admin/ledger/reconcile
+func (h *LedgerHandler) ReconcileLedger(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	tenantID := middleware.GetTenantID(c)
+	report, err := h.ledgerService.Reconcile(&tenantID, 100)
+	if err != nil {
+		h.fail(c, correlationID, http.StatusInternalServerError, err)
+		return
+	}
+	h.ok(c, correlationID, gin.H{"report": report, "clean": report.Clean()})
+}
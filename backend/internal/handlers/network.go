@@ -20,7 +20,7 @@ func NewNetworkHandler(db *gorm.DB) *NetworkHandler {
 func (h *NetworkHandler) GetAllNetworks(c *gin.Context) {
     var networks []models.Network
 
-    query := h.db.Select("id, name, description, logo_url, status, created_at")
+    query := tenantDB(c, h.db).Select("id, name, description, logo_url, status, created_at")
 
     status := c.Query("status")
     if status != "" {
@@ -41,7 +41,7 @@ func (h *NetworkHandler) GetNetwork(c *gin.Context) {
     networkID := c.Param("id")
 
     var network models.Network
-    if err := h.db.Select("id, name, description, logo_url, status, created_at").First(&network, "id = ?", networkID).Error; err != nil {
+    if err := tenantDB(c, h.db).Select("id, name, description, logo_url, status, created_at").First(&network, "id = ?", networkID).Error; err != nil {
         c.JSON(http.StatusNotFound, gin.H{"error": "Network not found"})
         return
     }
@@ -72,7 +72,7 @@ func (h *NetworkHandler) CreateNetwork(c *gin.Context) {
         Status:      "active",
     }
 
-    if err := h.db.Create(&network).Error; err != nil {
+    if err := tenantDB(c, h.db).Create(&network).Error; err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create network"})
         return
     }
@@ -113,13 +113,13 @@ func (h *NetworkHandler) UpdateNetwork(c *gin.Context) {
         updates["status"] = req.Status
     }
 
-    if err := h.db.Model(&models.Network{}).Where("id = ?", networkID).Updates(updates).Error; err != nil {
+    if err := tenantDB(c, h.db).Model(&models.Network{}).Where("id = ?", networkID).Updates(updates).Error; err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update network"})
         return
     }
 
     var network models.Network
-    h.db.First(&network, "id = ?", networkID)
+    tenantDB(c, h.db).First(&network, "id = ?", networkID)
 
     c.JSON(http.StatusOK, gin.H{
         "message": "Network updated successfully",
@@ -136,7 +136,7 @@ func (h *NetworkHandler) DeleteNetwork(c *gin.Context) {
         return
     }
 
-    if err := h.db.Delete(&models.Network{}, "id = ?", id).Error; err != nil {
+    if err := tenantDB(c, h.db).Delete(&models.Network{}, "id = ?", id).Error; err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete network"})
         return
     }
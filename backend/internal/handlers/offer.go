@@ -3,9 +3,9 @@ package handlers
 import (
     "fmt"
     "net/http"
-    "os"
     "strings"
 
+    "github.com/aljapah/afftok-backend-prod/internal/database"
     "github.com/aljapah/afftok-backend-prod/internal/models"
     "github.com/aljapah/afftok-backend-prod/internal/services"
     "github.com/gin-gonic/gin"
@@ -39,7 +39,7 @@ func (h *OfferHandler) GetAllOffers(c *gin.Context) {
     limit := 20
     offset := (page - 1) * limit
 
-    query := h.db
+    query := tenantDB(c, h.db)
 
     status := c.Query("status")
     if status != "" {
@@ -66,11 +66,11 @@ func (h *OfferHandler) GetAllOffers(c *gin.Context) {
     // Supports multiple countries as comma-separated values
     userCountries := c.Query("country")
     if userCountries != "" {
-        offers = h.filterOffersByGeo(offers, userCountries)
+        offers = h.filterOffersByGeo(tenantDB(c, h.db), offers, userCountries)
     }
 
     var total int64
-    h.db.Model(&models.Offer{}).Where("status = ?", "active").Count(&total)
+    tenantDB(c, h.db).Model(&models.Offer{}).Where("status = ?", "active").Count(&total)
 
     c.JSON(http.StatusOK, gin.H{
         "offers": offers,
@@ -84,7 +84,7 @@ func (h *OfferHandler) GetAllOffers(c *gin.Context) {
 
 // filterOffersByGeo filters offers based on geo targeting rules
 // Supports multiple countries as comma-separated string
-func (h *OfferHandler) filterOffersByGeo(offers []models.Offer, userCountries string) []models.Offer {
+func (h *OfferHandler) filterOffersByGeo(db *database.TenantDB, offers []models.Offer, userCountries string) []models.Offer {
     if userCountries == "" {
         return offers
     }
@@ -100,11 +100,11 @@ func (h *OfferHandler) filterOffersByGeo(offers []models.Offer, userCountries st
     for _, offer := range offers {
         // Check if offer has geo rules
         var geoRules []models.GeoRule
-        h.db.Where("scope_type = ? AND scope_id = ? AND status = ?", "offer", offer.ID, "active").Find(&geoRules)
+        db.Where("scope_type = ? AND scope_id = ? AND status = ?", "offer", offer.ID, "active").Find(&geoRules)
 
         // If no offer-specific rules, check advertiser rules
         if len(geoRules) == 0 {
-            h.db.Where("scope_type = ? AND scope_id = ? AND status = ?", "advertiser", offer.AdvertiserID, "active").Find(&geoRules)
+            db.Where("scope_type = ? AND scope_id = ? AND status = ?", "advertiser", offer.AdvertiserID, "active").Find(&geoRules)
         }
 
         // If no rules at all, include the offer (no restrictions)
@@ -150,7 +150,7 @@ func (h *OfferHandler) GetOffer(c *gin.Context) {
     offerID := c.Param("id")
 
     var offer models.Offer
-    if err := h.db.First(&offer, "id = ?", offerID).Error; err != nil {
+    if err := tenantDB(c, h.db).First(&offer, "id = ?", offerID).Error; err != nil {
         c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
         return
     }
@@ -193,7 +193,13 @@ func (h *OfferHandler) CreateOffer(c *gin.Context) {
         Status:         "active",
     }
 
-    if err := h.db.Create(&offer).Error; err != nil {
+    created, err := createWithinPlanLimit(c, h.db, models.LimitResourceOffers, func(tx *database.TenantDB) error {
+        return tx.Create(&offer).Error
+    })
+    if !created {
+        return
+    }
+    if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create offer"})
         return
     }
@@ -217,6 +223,7 @@ func (h *OfferHandler) UpdateOffer(c *gin.Context) {
         Payout         int    `json:"payout"`
         Commission     int    `json:"commission"`
         Status         string `json:"status"`
+        BeaconEnabled  *bool  `json:"beacon_enabled"`
     }
 
     var req UpdateOfferRequest
@@ -253,14 +260,17 @@ func (h *OfferHandler) UpdateOffer(c *gin.Context) {
     if req.Status != "" {
         updates["status"] = req.Status
     }
+    if req.BeaconEnabled != nil {
+        updates["beacon_enabled"] = *req.BeaconEnabled
+    }
 
-    if err := h.db.Model(&models.Offer{}).Where("id = ?", offerID).Updates(updates).Error; err != nil {
+    if err := tenantDB(c, h.db).Model(&models.Offer{}).Where("id = ?", offerID).Updates(updates).Error; err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update offer"})
         return
     }
 
     var offer models.Offer
-    h.db.First(&offer, "id = ?", offerID)
+    tenantDB(c, h.db).First(&offer, "id = ?", offerID)
 
     c.JSON(http.StatusOK, gin.H{
         "message": "Offer updated successfully",
@@ -277,7 +287,7 @@ func (h *OfferHandler) DeleteOffer(c *gin.Context) {
         return
     }
 
-    if err := h.db.Delete(&models.Offer{}, "id = ?", id).Error; err != nil {
+    if err := tenantDB(c, h.db).Delete(&models.Offer{}, "id = ?", id).Error; err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete offer"})
         return
     }
@@ -293,7 +303,7 @@ func (h *OfferHandler) JoinOffer(c *gin.Context) {
     userUUID := userID.(uuid.UUID)
 
     var offer models.Offer
-    if err := h.db.First(&offer, "id = ?", offerID).Error; err != nil {
+    if err := tenantDB(c, h.db).First(&offer, "id = ?", offerID).Error; err != nil {
         c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
         return
     }
@@ -301,7 +311,7 @@ func (h *OfferHandler) JoinOffer(c *gin.Context) {
 	// If this offer is exclusive to a specific team, ensure user is an active member of that team
 	if offer.ExclusiveTeamID != nil {
 		var membership models.TeamMember
-		if err := h.db.
+		if err := tenantDB(c, h.db).
 			Where("team_id = ? AND user_id = ? AND status = ?", offer.ExclusiveTeamID, userUUID, models.TeamMemberStatusActive).
 			First(&membership).Error; err != nil {
 			c.JSON(http.StatusForbidden, gin.H{
@@ -314,7 +324,7 @@ func (h *OfferHandler) JoinOffer(c *gin.Context) {
 
     // Check for existing user offer
     var existingUserOffer models.UserOffer
-    if err := h.db.Where("user_id = ? AND offer_id = ?", userUUID, offerID).First(&existingUserOffer).Error; err == nil {
+    if err := tenantDB(c, h.db).Where("user_id = ? AND offer_id = ?", userUUID, offerID).First(&existingUserOffer).Error; err == nil {
         c.JSON(http.StatusConflict, gin.H{
             "error":          "You already joined this offer",
             "user_offer":     existingUserOffer,
@@ -359,7 +369,7 @@ func (h *OfferHandler) JoinOffer(c *gin.Context) {
     }
 
     // Use transaction for atomic operation
-    err = h.db.Transaction(func(tx *gorm.DB) error {
+    err = tenantDB(c, h.db).Transaction(func(tx *database.TenantDB) error {
         // Create user offer
         if err := tx.Create(&userOffer).Error; err != nil {
             return err
@@ -385,11 +395,8 @@ func (h *OfferHandler) JoinOffer(c *gin.Context) {
     var trackingURL string
     var signedLink string
     
-    // Get base URL from environment or use production default
-    baseURL := os.Getenv("BASE_URL")
-    if baseURL == "" {
-        baseURL = "https://go.afftokapp.com"
-    }
+    // Tenant's verified custom domain, else BASE_URL / production default
+    baseURL := tenantBaseURL(c, h.db)
     
     if trackingCode != "" && h.linkSigningService != nil {
         // Generate signed tracking link
@@ -420,7 +427,7 @@ func (h *OfferHandler) JoinOffer(c *gin.Context) {
 // GET /api/admin/offers/pending
 func (h *OfferHandler) GetPendingOffers(c *gin.Context) {
     var offers []models.Offer
-    if err := h.db.Preload("Advertiser").Where("status = ?", "pending").Order("created_at DESC").Find(&offers).Error; err != nil {
+    if err := tenantDB(c, h.db).Preload("Advertiser").Where("status = ?", "pending").Order("created_at DESC").Find(&offers).Error; err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pending offers"})
         return
     }
@@ -443,7 +450,7 @@ func (h *OfferHandler) ApproveOffer(c *gin.Context) {
     }
 
     var offer models.Offer
-    if err := h.db.First(&offer, "id = ?", id).Error; err != nil {
+    if err := tenantDB(c, h.db).First(&offer, "id = ?", id).Error; err != nil {
         c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
         return
     }
@@ -454,7 +461,7 @@ func (h *OfferHandler) ApproveOffer(c *gin.Context) {
     }
 
     // Update status to active
-    if err := h.db.Model(&offer).Updates(map[string]interface{}{
+    if err := tenantDB(c, h.db).Model(&offer).Updates(map[string]interface{}{
         "status":           "active",
         "rejection_reason": "",
     }).Error; err != nil {
@@ -463,7 +470,7 @@ func (h *OfferHandler) ApproveOffer(c *gin.Context) {
     }
 
     // Reload offer
-    h.db.First(&offer, id)
+    tenantDB(c, h.db).First(&offer, id)
 
     c.JSON(http.StatusOK, gin.H{
         "message": "Offer approved successfully",
@@ -493,7 +500,7 @@ func (h *OfferHandler) RejectOffer(c *gin.Context) {
     }
 
     var offer models.Offer
-    if err := h.db.First(&offer, "id = ?", id).Error; err != nil {
+    if err := tenantDB(c, h.db).First(&offer, "id = ?", id).Error; err != nil {
         c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
         return
     }
@@ -504,7 +511,7 @@ func (h *OfferHandler) RejectOffer(c *gin.Context) {
     }
 
     // Update status to rejected
-    if err := h.db.Model(&offer).Updates(map[string]interface{}{
+    if err := tenantDB(c, h.db).Model(&offer).Updates(map[string]interface{}{
         "status":           "rejected",
         "rejection_reason": req.Reason,
     }).Error; err != nil {
@@ -513,7 +520,7 @@ func (h *OfferHandler) RejectOffer(c *gin.Context) {
     }
 
     // Reload offer
-    h.db.First(&offer, id)
+    tenantDB(c, h.db).First(&offer, id)
 
     c.JSON(http.StatusOK, gin.H{
         "message": "Offer rejected successfully",
@@ -525,7 +532,7 @@ func (h *OfferHandler) GetMyOffers(c *gin.Context) {
     userID, _ := c.Get("userID")
 
     var userOffers []models.UserOffer
-    if err := h.db.Preload("Offer").Where("user_id = ?", userID).Order("joined_at DESC").Find(&userOffers).Error; err != nil {
+    if err := tenantDB(c, h.db).Preload("Offer").Preload("Earnings").Where("user_id = ?", userID).Order("joined_at DESC").Find(&userOffers).Error; err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch your offers"})
         return
     }
@@ -540,7 +547,7 @@ func (h *OfferHandler) GetMyOffers(c *gin.Context) {
         TrackingCode  string         `json:"tracking_code,omitempty"`
         TrackingURL   string         `json:"tracking_url,omitempty"`
         Status        string         `json:"status"`
-        Earnings      int            `json:"earnings"`
+        Earnings      []models.EarningsCounter `json:"earnings"`
         JoinedAt      string         `json:"joined_at"`
         Offer         *models.Offer  `json:"offer,omitempty"`
         Stats         map[string]int `json:"stats"`
@@ -556,20 +563,17 @@ func (h *OfferHandler) GetMyOffers(c *gin.Context) {
         // If cached values are 0, query DB (for existing data before migration)
         if clickCount == 0 {
             var count int64
-            h.db.Model(&models.Click{}).Where("user_offer_id = ?", uo.ID).Count(&count)
+            tenantDB(c, h.db).Model(&models.Click{}).Where("user_offer_id = ?", uo.ID).Count(&count)
             clickCount = int(count)
         }
         if conversionCount == 0 {
             var count int64
-            h.db.Model(&models.Conversion{}).Where("user_offer_id = ?", uo.ID).Count(&count)
+            tenantDB(c, h.db).Model(&models.Conversion{}).Where("user_offer_id = ?", uo.ID).Count(&count)
             conversionCount = int(count)
         }
 
-        // Build tracking URL with full base URL
-        baseURL := os.Getenv("BASE_URL")
-        if baseURL == "" {
-            baseURL = "https://go.afftokapp.com"
-        }
+        // Build tracking URL on the tenant's domain
+        baseURL := tenantBaseURL(c, h.db)
         
         trackingURL := ""
         if uo.ShortLink != "" {
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// PAGE TEMPLATES HANDLER
+// ============================================
+
+// PageTemplateHandler manages tenant overrides of the public page templates
+type PageTemplateHandler struct {
+	pageTemplateService *services.PageTemplateService
+}
+
+// NewPageTemplateHandler creates a new page template handler
+func NewPageTemplateHandler(db *gorm.DB) *PageTemplateHandler {
+	return &PageTemplateHandler{
+		pageTemplateService: services.GetPageTemplateService(db),
+	}
+}
+
+// ============================================
+// PUBLIC PAGE HELPERS
+// ============================================
+
+// pageLocale picks the page locale from ?lang or Accept-Language
+func pageLocale(c *gin.Context) string {
+	return services.ResolvePageLocale(c.Query("lang"), c.GetHeader("Accept-Language"))
+}
+
+// writePage sends a rendered page with its Content-Security-Policy
+func writePage(c *gin.Context, page *services.RenderedPage) {
+	c.Header("Content-Security-Policy", services.PageCSP(page.Nonce))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(page.Status, "text/html; charset=utf-8", page.HTML)
+}
+
+// servePageError renders a branded error page. Without a known tenant the
+// branded domain's tenant (if any) is used.
+func servePageError(c *gin.Context, db *gorm.DB, tenantID uuid.UUID, status int, kind string) {
+	if tenantID == uuid.Nil {
+		tenantID, _ = middleware.GetBrandedTenantID(c)
+	}
+	page, err := services.GetPageTemplateService(db).RenderErrorPage(tenantID, pageLocale(c), status, kind)
+	if err != nil {
+		log.Printf("[PageTemplates] error page failed: %v", err)
+		c.String(status, http.StatusText(status))
+		return
+	}
+	writePage(c, page)
+}
+
+// ============================================
+// ADMIN ENDPOINTS
+// ============================================
+
+func pageTemplateErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidPageTemplate):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrUnknownPageTemplate),
+		errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (h *PageTemplateHandler) fail(c *gin.Context, correlationID string, err error) {
+	c.JSON(pageTemplateErrorStatus(err), gin.H{
+		"success":        false,
+		"correlation_id": correlationID,
+		"error":          err.Error(),
+	})
+}
+
+func (h *PageTemplateHandler) ok(c *gin.Context, correlationID string, data interface{}) {
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           data,
+	})
+}
+
+// adminTenantID parses the :id tenant of super admin routes
+func (h *PageTemplateHandler) adminTenantID(c *gin.Context, correlationID string) (uuid.UUID, bool) {
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid tenant ID",
+		})
+		return uuid.Nil, false
+	}
+	return tenantID, true
+}
+
+func (h *PageTemplateHandler) list(c *gin.Context, correlationID string, tenantID uuid.UUID) {
+	templates, err := h.pageTemplateService.ListTemplates(tenantID)
+	if err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+	brand, applied := h.pageTemplateService.Branding(tenantID)
+	h.ok(c, correlationID, gin.H{
+		"templates":         templates,
+		"branding":          brand,
+		"overrides_applied": applied,
+		"max_size":          services.MaxPageTemplateSize,
+	})
+}
+
+func (h *PageTemplateHandler) get(c *gin.Context, correlationID string, tenantID uuid.UUID) {
+	info, err := h.pageTemplateService.GetTemplate(tenantID, c.Param("page"), c.Param("locale"))
+	if err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+	h.ok(c, correlationID, info)
+}
+
+func (h *PageTemplateHandler) save(c *gin.Context, correlationID string, tenantID uuid.UUID) {
+	var req struct {
+		Content string `json:"content" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
+	info, err := h.pageTemplateService.SaveTemplate(tenantID, c.Param("page"), c.Param("locale"), req.Content, requestActor(c))
+	if err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+	h.ok(c, correlationID, info)
+}
+
+func (h *PageTemplateHandler) remove(c *gin.Context, correlationID string, tenantID uuid.UUID) {
+	if err := h.pageTemplateService.DeleteTemplate(tenantID, c.Param("page"), c.Param("locale"), requestActor(c)); err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+	h.ok(c, correlationID, nil)
+}
+
+// preview renders the page with sample data. The body may carry an unsaved
+// draft; without one the tenant's current templates are used.
+func (h *PageTemplateHandler) preview(c *gin.Context, correlationID string, tenantID uuid.UUID) {
+	var req struct {
+		Content *string `json:"content"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success":        false,
+				"correlation_id": correlationID,
+				"error":          err.Error(),
+			})
+			return
+		}
+	}
+
+	page, err := h.pageTemplateService.Preview(tenantID, c.Param("page"), c.Param("locale"), req.Content)
+	if err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+	_, applied := h.pageTemplateService.Branding(tenantID)
+	h.ok(c, correlationID, gin.H{
+		"html":                    string(page.HTML),
+		"content_security_policy": services.PageCSP(page.Nonce),
+		"overrides_applied":       applied,
+	})
+}
+
+// ListPageTemplates lists the current tenant's page templates
+// GET /api/page-templates
+func (h *PageTemplateHandler) ListPageTemplates(c *gin.Context) {
+	h.list(c, generateCorrelationID(), middleware.GetTenantID(c))
+}
+
+// GetPageTemplate returns the current tenant's template of a page
+// GET /api/page-templates/:page/:locale
+func (h *PageTemplateHandler) GetPageTemplate(c *gin.Context) {
+	h.get(c, generateCorrelationID(), middleware.GetTenantID(c))
+}
+
+// UpdatePageTemplate overrides a page template for the current tenant
+// PUT /api/page-templates/:page/:locale {"content": "..."}
+func (h *PageTemplateHandler) UpdatePageTemplate(c *gin.Context) {
+	h.save(c, generateCorrelationID(), middleware.GetTenantID(c))
+}
+
+// DeletePageTemplate reverts a page to the built-in template
+// DELETE /api/page-templates/:page/:locale
+func (h *PageTemplateHandler) DeletePageTemplate(c *gin.Context) {
+	h.remove(c, generateCorrelationID(), middleware.GetTenantID(c))
+}
+
+// PreviewPageTemplate renders a page with sample data
+// POST /api/page-templates/:page/:locale/preview {"content": "..."}
+func (h *PageTemplateHandler) PreviewPageTemplate(c *gin.Context) {
+	h.preview(c, generateCorrelationID(), middleware.GetTenantID(c))
+}
+
+// ListTenantPageTemplates lists any tenant's page templates
+// GET /api/admin/tenants/:id/templates
+func (h *PageTemplateHandler) ListTenantPageTemplates(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	if tenantID, ok := h.adminTenantID(c, correlationID); ok {
+		h.list(c, correlationID, tenantID)
+	}
+}
+
+// GetTenantPageTemplate returns any tenant's template of a page
+// GET /api/admin/tenants/:id/templates/:page/:locale
+func (h *PageTemplateHandler) GetTenantPageTemplate(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	if tenantID, ok := h.adminTenantID(c, correlationID); ok {
+		h.get(c, correlationID, tenantID)
+	}
+}
+
+// UpdateTenantPageTemplate overrides a page template for any tenant
+// PUT /api/admin/tenants/:id/templates/:page/:locale
+func (h *PageTemplateHandler) UpdateTenantPageTemplate(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	if tenantID, ok := h.adminTenantID(c, correlationID); ok {
+		h.save(c, correlationID, tenantID)
+	}
+}
+
+// DeleteTenantPageTemplate reverts any tenant's page to the built-in template
+// DELETE /api/admin/tenants/:id/templates/:page/:locale
+func (h *PageTemplateHandler) DeleteTenantPageTemplate(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	if tenantID, ok := h.adminTenantID(c, correlationID); ok {
+		h.remove(c, correlationID, tenantID)
+	}
+}
+
+// PreviewTenantPageTemplate renders any tenant's page with sample data
+// POST /api/admin/tenants/:id/templates/:page/:locale/preview
+func (h *PageTemplateHandler) PreviewTenantPageTemplate(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	if tenantID, ok := h.adminTenantID(c, correlationID); ok {
+		h.preview(c, correlationID, tenantID)
+	}
+}
@@ -2,26 +2,40 @@ package handlers
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
 	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
 )
 
 // PayoutHandler handles payout-related API endpoints
-// نظام Payoneer للدفعات - معطل حالياً وجاهز للتفعيل
+// دورة الدفعات: إنشاء ← مراجعة ← موافقة ← إرسال ← تسوية
 type PayoutHandler struct {
-	db *gorm.DB
+	db            *gorm.DB
+	payoutService *services.PayoutService
 }
 
 // NewPayoutHandler creates a new payout handler
 func NewPayoutHandler(db *gorm.DB) *PayoutHandler {
-	return &PayoutHandler{db: db}
+	return &PayoutHandler{
+		db:            db,
+		payoutService: services.NewPayoutService(db),
+	}
+}
+
+// SetPayoutService sets the payout service (for dependency injection)
+func (h *PayoutHandler) SetPayoutService(service *services.PayoutService) {
+	h.payoutService = service
 }
 
 // ============================================================
@@ -33,7 +47,7 @@ func NewPayoutHandler(db *gorm.DB) *PayoutHandler {
 func (h *PayoutHandler) GetPayoutBatches(c *gin.Context) {
 	var batches []models.PayoutBatch
 	
-	result := h.db.Order("period DESC").Find(&batches)
+	result := tenantDB(c, h.db).Order("period DESC").Find(&batches)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch batches"})
 		return
@@ -42,8 +56,7 @@ func (h *PayoutHandler) GetPayoutBatches(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"batches": batches,
 		"total":   len(batches),
-		"system_status": "disabled", // النظام معطل حالياً
-		"message": "Payoneer system is ready but not activated. Waiting for Payoneer partnership.",
+		"rails":   h.payoutService.Rails().Status(), // قنوات الدفع المفعّلة - الأسطر بدون وسيلة دفع تُسوّى يدوياً
 	})
 }
 
@@ -53,7 +66,7 @@ func (h *PayoutHandler) GetPayoutBatch(c *gin.Context) {
 	batchID := c.Param("id")
 	
 	var batch models.PayoutBatch
-	result := h.db.Preload("Payouts").Preload("Payouts.Advertiser").Preload("Payouts.Publisher").
+	result := tenantDB(c, h.db).Preload("Payouts").Preload("Payouts.Advertiser").Preload("Payouts.Publisher").
 		Where("id = ?", batchID).First(&batch)
 	
 	if result.Error != nil {
@@ -69,7 +82,7 @@ func (h *PayoutHandler) GetPayoutBatch(c *gin.Context) {
 func (h *PayoutHandler) GetAllPayouts(c *gin.Context) {
 	var payouts []models.Payout
 	
-	query := h.db.Preload("Advertiser").Preload("Publisher").Order("created_at DESC")
+	query := tenantDB(c, h.db).Preload("Advertiser").Preload("Publisher").Order("created_at DESC")
 	
 	// فلتر بالفترة
 	if period := c.Query("period"); period != "" {
@@ -99,20 +112,20 @@ func (h *PayoutHandler) GetAllPayouts(c *gin.Context) {
 	
 	// حساب الملخص
 	var summary models.PayoutSummary
-	h.db.Model(&models.Payout{}).Select("COUNT(*) as total_payouts, COALESCE(SUM(amount), 0) as total_amount, COALESCE(SUM(platform_fee), 0) as total_platform_fee").Scan(&summary)
-	h.db.Model(&models.Payout{}).Where("status = ?", "pending").Select("COUNT(*) as pending_count, COALESCE(SUM(amount), 0) as pending_amount").Scan(&summary)
-	h.db.Model(&models.Payout{}).Where("status = ?", "paid").Select("COUNT(*) as paid_count, COALESCE(SUM(amount), 0) as paid_amount").Scan(&summary)
+	tenantDB(c, h.db).Model(&models.Payout{}).Select("COUNT(*) as total_payouts, COALESCE(SUM(amount), 0) as total_amount, COALESCE(SUM(platform_fee), 0) as total_platform_fee").Scan(&summary)
+	tenantDB(c, h.db).Model(&models.Payout{}).Where("status = ?", "pending").Select("COUNT(*) as pending_count, COALESCE(SUM(amount), 0) as pending_amount").Scan(&summary)
+	tenantDB(c, h.db).Model(&models.Payout{}).Where("status = ?", "paid").Select("COUNT(*) as paid_count, COALESCE(SUM(amount), 0) as paid_amount").Scan(&summary)
 	
 	c.JSON(http.StatusOK, gin.H{
 		"payouts":       payouts,
 		"summary":       summary,
 		"total":         len(payouts),
-		"system_status": "disabled",
-		"message":       "Payoneer system is ready but not activated.",
+		"rails":         h.payoutService.Rails().Status(),
 	})
 }
 
-// GeneratePayoutBatch generates a new payout batch for a specific period
+// GeneratePayoutBatch generates the payout batch of a period from the
+// earnings ledger. Generation is idempotent: an existing batch is returned.
 // POST /api/admin/payouts/generate
 func (h *PayoutHandler) GeneratePayoutBatch(c *gin.Context) {
 	var req models.GeneratePayoutRequest
@@ -122,141 +135,263 @@ func (h *PayoutHandler) GeneratePayoutBatch(c *gin.Context) {
 	}
 	
 	// تحديد الفترة
-	if req.Period == "" {
-		req.Period = fmt.Sprintf("%d-%02d", req.Year, req.Month)
+	if req.Period != "" {
+		period, err := time.Parse("2006-01", req.Period)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid period (expected YYYY-MM)"})
+			return
+		}
+		req.Year, req.Month = period.Year(), int(period.Month())
 	}
-	
-	// التحقق من عدم وجود دفعة لنفس الفترة
-	var existingBatch models.PayoutBatch
-	if h.db.Where("period = ?", req.Period).First(&existingBatch).Error == nil {
-		c.JSON(http.StatusConflict, gin.H{
-			"error":    "Batch already exists for this period",
-			"batch_id": existingBatch.ID,
-		})
+	if req.Month < 1 || req.Month > 12 || req.Year < 2024 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid month/year"})
 		return
 	}
 	
-	// حساب تواريخ الفترة
-	year := req.Year
-	month := req.Month
-	periodStart := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
-	periodEnd := periodStart.AddDate(0, 1, 0).Add(-time.Second)
-	
-	// جلب جميع التحويلات المؤكدة للفترة
-	var conversions []struct {
-		AdvertiserID uuid.UUID
-		PublisherID  uuid.UUID
-		TotalAmount  float64
-		Count        int
-	}
-	
-	// Query: جلب التحويلات المجمعة حسب المعلن والمروج
-	result := h.db.Table("conversions c").
-		Select(`
-			o.advertiser_id,
-			uo.user_id as publisher_id,
-			COALESCE(SUM(c.amount), 0) as total_amount,
-			COUNT(*) as count
-		`).
-		Joins("JOIN user_offers uo ON c.user_offer_id = uo.id").
-		Joins("JOIN offers o ON uo.offer_id = o.id").
-		Where("c.status = ?", "approved").
-		Where("c.converted_at >= ? AND c.converted_at <= ?", periodStart, periodEnd).
-		Where("o.advertiser_id IS NOT NULL").
-		Group("o.advertiser_id, uo.user_id").
-		Scan(&conversions)
-	
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate payouts", "details": result.Error.Error()})
+	batch, created, err := h.payoutService.GenerateBatch(middleware.GetTenantID(c), req.Year, req.Month, requestActor(c))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrPayoutPeriodOpen) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 	
-	if len(conversions) == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"message":       "No approved conversions found for this period",
-			"period":        req.Period,
-			"payouts_count": 0,
-		})
+	message := "Payout batch generated successfully"
+	if !created {
+		message = "Batch already exists for this period"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":       message,
+		"created":       created,
+		"batch":         batch,
+		"payouts_count": batch.TotalPayouts,
+		"held":          batch.HeldPayouts,
+		"carried_over":  batch.CarriedPayouts,
+		"total_amount":  batch.TotalAmount,
+		"platform_fee":  batch.TotalPlatformFee,
+		"net_amount":    batch.TotalNetAmount,
+	})
+}
+
+// ApprovePayoutBatch approves a reviewed batch
+// POST /api/admin/payouts/batches/:id/approve
+func (h *PayoutHandler) ApprovePayoutBatch(c *gin.Context) {
+	h.transitionBatch(c, h.payoutService.ApproveBatch)
+}
+
+// ReopenPayoutBatch sends an approved batch back to review
+// POST /api/admin/payouts/batches/:id/reopen
+func (h *PayoutHandler) ReopenPayoutBatch(c *gin.Context) {
+	h.transitionBatch(c, h.payoutService.ReopenBatch)
+}
+
+// CancelPayoutBatch cancels a batch (a submitted one at its payout rails); its lines move to the next period
+// POST /api/admin/payouts/batches/:id/cancel
+func (h *PayoutHandler) CancelPayoutBatch(c *gin.Context) {
+	h.transitionBatch(c, h.payoutService.CancelBatch)
+}
+
+// SubmitPayoutBatch submits an approved batch for payment
+// POST /api/admin/payouts/batches/:id/submit
+func (h *PayoutHandler) SubmitPayoutBatch(c *gin.Context) {
+	h.transitionBatch(c, h.payoutService.SubmitBatch)
+}
+
+func (h *PayoutHandler) transitionBatch(c *gin.Context, transition func(tenantID, batchID uuid.UUID, actor *uuid.UUID) (*models.PayoutBatch, error)) {
+	batchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch ID"})
 		return
 	}
 	
-	// إنشاء الدفعة
-	batch := models.PayoutBatch{
-		ID:          uuid.New(),
-		Period:      req.Period,
-		PeriodStart: periodStart,
-		PeriodEnd:   periodEnd,
-		Status:      models.BatchStatusDraft,
-		Currency:    "USD",
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+	batch, err := transition(middleware.GetTenantID(c), batchID, requestActor(c))
+	if err != nil {
+		c.JSON(payoutErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"batch": batch})
+}
+
+// ReconcilePayoutBatch records payment results of a submitted batch, as JSON
+// {"settlements": [{"payout_id", "status": "paid|failed", "reference", "reason"}]}
+// or as an uploaded CSV file with the same columns
+// POST /api/admin/payouts/batches/:id/reconcile
+func (h *PayoutHandler) ReconcilePayoutBatch(c *gin.Context) {
+	batchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch ID"})
+		return
 	}
 	
-	// حساب الإحصائيات وإنشاء المستحقات
-	var payouts []models.Payout
-	advertisersMap := make(map[uuid.UUID]bool)
-	publishersMap := make(map[uuid.UUID]bool)
-	
-	for _, conv := range conversions {
-		payout := models.Payout{
-			ID:               uuid.New(),
-			BatchID:          &batch.ID,
-			AdvertiserID:     conv.AdvertiserID,
-			PublisherID:      conv.PublisherID,
-			Amount:           conv.TotalAmount,
-			PlatformFee:      conv.TotalAmount * 0.10, // 10% عمولة
-			NetAmount:        conv.TotalAmount * 0.90,
-			Currency:         "USD",
-			Period:           req.Period,
-			PeriodStart:      periodStart,
-			PeriodEnd:        periodEnd,
-			ConversionsCount: conv.Count,
-			Status:           models.PayoutStatusPending,
-			CreatedAt:        time.Now(),
-			UpdatedAt:        time.Now(),
+	var settlements []services.PayoutSettlement
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+			return
 		}
-		payouts = append(payouts, payout)
-		
-		batch.TotalAmount += payout.Amount
-		batch.TotalPlatformFee += payout.PlatformFee
-		batch.TotalNetAmount += payout.NetAmount
-		batch.TotalConversions += conv.Count
-		
-		advertisersMap[conv.AdvertiserID] = true
-		publishersMap[conv.PublisherID] = true
+		defer f.Close()
+		if settlements, err = parseSettlementsCSV(f); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		var req struct {
+			Settlements []services.PayoutSettlement `json:"settlements" binding:"required,min=1,dive"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		settlements = req.Settlements
 	}
 	
-	batch.TotalPayouts = len(payouts)
-	batch.TotalAdvertisers = len(advertisersMap)
-	batch.TotalPublishers = len(publishersMap)
-	
-	// حفظ في قاعدة البيانات
-	tx := h.db.Begin()
-	
-	if err := tx.Create(&batch).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create batch"})
+	report, err := h.payoutService.ReconcileBatch(middleware.GetTenantID(c), batchID, settlements, requestActor(c))
+	if err != nil {
+		c.JSON(payoutErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"reconciliation": report})
+}
+
+// parseSettlementsCSV reads payout_id,status,reference,reason rows
+func parseSettlementsCSV(r io.Reader) ([]services.PayoutSettlement, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("missing CSV header")
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["payout_id"]; !ok {
+		return nil, errors.New("missing payout_id column")
+	}
+	if _, ok := columns["status"]; !ok {
+		return nil, errors.New("missing status column")
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
 	
-	if err := tx.Create(&payouts).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payouts"})
+	var settlements []services.PayoutSettlement
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		id, err := uuid.Parse(field(record, "payout_id"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid payout_id", line)
+		}
+		status := strings.ToLower(field(record, "status"))
+		if status != models.PayoutStatusPaid && status != models.PayoutStatusFailed {
+			return nil, fmt.Errorf("line %d: status must be paid or failed", line)
+		}
+		settlements = append(settlements, services.PayoutSettlement{
+			PayoutID:  id,
+			Status:    status,
+			Reference: field(record, "reference"),
+			Reason:    field(record, "reason"),
+		})
+	}
+	if len(settlements) == 0 {
+		return nil, errors.New("no settlements in file")
+	}
+	return settlements, nil
+}
+
+// HoldPayout holds a payout line during review
+// POST /api/admin/payouts/:id/hold {"reason"}
+func (h *PayoutHandler) HoldPayout(c *gin.Context) {
+	payoutID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payout ID"})
 		return
 	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	c.ShouldBindJSON(&req)
 	
-	tx.Commit()
+	payout, err := h.payoutService.HoldPayout(middleware.GetTenantID(c), payoutID, req.Reason, requestActor(c))
+	if err != nil {
+		c.JSON(payoutErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"payout": payout})
+}
+
+// ReleasePayout releases a held payout line
+// POST /api/admin/payouts/:id/release
+func (h *PayoutHandler) ReleasePayout(c *gin.Context) {
+	payoutID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payout ID"})
+		return
+	}
 	
-	c.JSON(http.StatusOK, gin.H{
-		"message":          "Payout batch generated successfully",
-		"batch":            batch,
-		"payouts_count":    len(payouts),
-		"total_amount":     batch.TotalAmount,
-		"platform_fee":     batch.TotalPlatformFee,
-		"net_amount":       batch.TotalNetAmount,
-		"system_status":    "disabled",
-		"activation_note":  "This batch is saved but NOT submitted to Payoneer. System will be activated after Payoneer partnership.",
-	})
+	payout, err := h.payoutService.ReleasePayout(middleware.GetTenantID(c), payoutID, requestActor(c))
+	if err != nil {
+		c.JSON(payoutErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"payout": payout})
+}
+
+// GetPayoutBatchEvents returns the audit trail of a batch
+// GET /api/admin/payouts/batches/:id/events
+func (h *PayoutHandler) GetPayoutBatchEvents(c *gin.Context) {
+	batchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch ID"})
+		return
+	}
+	events, err := h.payoutService.ListEvents(middleware.GetTenantID(c), &batchID, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch events"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events, "total": len(events)})
+}
+
+// GetPayoutEvents returns the audit trail of a payout line
+// GET /api/admin/payouts/:id/events
+func (h *PayoutHandler) GetPayoutEvents(c *gin.Context) {
+	payoutID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payout ID"})
+		return
+	}
+	events, err := h.payoutService.ListEvents(middleware.GetTenantID(c), nil, &payoutID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch events"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events, "total": len(events)})
+}
+
+// payoutErrorStatus maps lifecycle errors to HTTP statuses
+func payoutErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrPayoutBatchNotFound), errors.Is(err, services.ErrPayoutNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrPayoutTransition), errors.Is(err, services.ErrPayoutHoldActive),
+		errors.Is(err, services.ErrPayoutRailCannotCancel):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrPayoutRailDisabled), errors.Is(err, services.ErrPayoutRailUnknown):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
 }
 
 // ExportPayoutBatchCSV exports a batch to CSV file
@@ -265,7 +400,7 @@ func (h *PayoutHandler) ExportPayoutBatchCSV(c *gin.Context) {
 	batchID := c.Param("id")
 	
 	var payouts []models.Payout
-	result := h.db.Preload("Advertiser").Preload("Publisher").
+	result := tenantDB(c, h.db).Preload("Advertiser").Preload("Publisher").
 		Where("batch_id = ?", batchID).Find(&payouts)
 	
 	if result.Error != nil || len(payouts) == 0 {
@@ -336,19 +471,18 @@ func (h *PayoutHandler) GetPayoutsSummary(c *gin.Context) {
 		TotalAdvertisers  int64   `json:"total_advertisers"`
 	}
 	
-	h.db.Model(&models.PayoutBatch{}).Count(&summary.TotalBatches)
-	h.db.Model(&models.Payout{}).Count(&summary.TotalPayouts)
-	h.db.Model(&models.Payout{}).Select("COALESCE(SUM(amount), 0)").Scan(&summary.TotalAmount)
-	h.db.Model(&models.Payout{}).Select("COALESCE(SUM(platform_fee), 0)").Scan(&summary.TotalPlatformFee)
-	h.db.Model(&models.PayoutBatch{}).Where("status IN ?", []string{"draft", "submitted", "processing"}).Count(&summary.PendingBatches)
-	h.db.Model(&models.PayoutBatch{}).Where("status = ?", "completed").Count(&summary.CompletedBatches)
-	h.db.Model(&models.Payout{}).Distinct("publisher_id").Count(&summary.TotalPublishers)
-	h.db.Model(&models.Payout{}).Distinct("advertiser_id").Count(&summary.TotalAdvertisers)
+	tenantDB(c, h.db).Model(&models.PayoutBatch{}).Count(&summary.TotalBatches)
+	tenantDB(c, h.db).Model(&models.Payout{}).Count(&summary.TotalPayouts)
+	tenantDB(c, h.db).Model(&models.Payout{}).Select("COALESCE(SUM(amount), 0)").Scan(&summary.TotalAmount)
+	tenantDB(c, h.db).Model(&models.Payout{}).Select("COALESCE(SUM(platform_fee), 0)").Scan(&summary.TotalPlatformFee)
+	tenantDB(c, h.db).Model(&models.PayoutBatch{}).Where("status IN ?", []string{"draft", "submitted", "processing"}).Count(&summary.PendingBatches)
+	tenantDB(c, h.db).Model(&models.PayoutBatch{}).Where("status = ?", "completed").Count(&summary.CompletedBatches)
+	tenantDB(c, h.db).Model(&models.Payout{}).Distinct("publisher_id").Count(&summary.TotalPublishers)
+	tenantDB(c, h.db).Model(&models.Payout{}).Distinct("advertiser_id").Count(&summary.TotalAdvertisers)
 	
 	c.JSON(http.StatusOK, gin.H{
-		"summary":       summary,
-		"system_status": "disabled",
-		"message":       "Payoneer integration ready but not activated",
+		"summary": summary,
+		"rails":   h.payoutService.Rails().Status(),
 	})
 }
 
@@ -359,14 +493,14 @@ func (h *PayoutHandler) GetPayoutsSummary(c *gin.Context) {
 // GetPromoterPayouts returns payouts for a specific promoter
 // GET /api/promoter/payouts
 func (h *PayoutHandler) GetPromoterPayouts(c *gin.Context) {
-	userID := c.GetString("user_id") // من الـ middleware
-	if userID == "" {
+	userID := requestActor(c) // من الـ middleware
+	if userID == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 	
 	var payouts []models.Payout
-	h.db.Preload("Advertiser").Where("publisher_id = ?", userID).
+	tenantDB(c, h.db).Preload("Advertiser").Where("publisher_id = ?", *userID).
 		Order("created_at DESC").Limit(100).Find(&payouts)
 	
 	// حساب الإجماليات
@@ -383,16 +517,16 @@ func (h *PayoutHandler) GetPromoterPayouts(c *gin.Context) {
 		"payouts":       payouts,
 		"total_pending": totalPending,
 		"total_paid":    totalPaid,
-		"system_status": "coming_soon",
-		"message":       "نظام الدفعات التلقائية عبر Payoneer قادم قريباً! حالياً يتم الدفع مباشرة من المعلن.",
+		"rails":         h.payoutService.Rails().Status(),
+		"message":       "تُدفع أرباحك عبر وسيلة الدفع الافتراضية (Payoneer أو PayPal أو تحويل بنكي أو Wise). بدون وسيلة دفع يتم الدفع مباشرة من المعلن.",
 	})
 }
 
 // UpdatePromoterPayoneerEmail updates the promoter's Payoneer email
 // PUT /api/promoter/payoneer-email
 func (h *PayoutHandler) UpdatePromoterPayoneerEmail(c *gin.Context) {
-	userID := c.GetString("user_id")
-	if userID == "" {
+	userID := requestActor(c)
+	if userID == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
@@ -405,7 +539,7 @@ func (h *PayoutHandler) UpdatePromoterPayoneerEmail(c *gin.Context) {
 		return
 	}
 	
-	result := h.db.Model(&models.AfftokUser{}).Where("id = ?", userID).Updates(map[string]interface{}{
+	result := tenantDB(c, h.db).Model(&models.AfftokUser{}).Where("id = ?", *userID).Updates(map[string]interface{}{
 		"payoneer_email":  req.PayoneerEmail,
 		"payoneer_status": "pending",
 		"updated_at":      time.Now(),
@@ -419,8 +553,7 @@ func (h *PayoutHandler) UpdatePromoterPayoneerEmail(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"message":       "Payoneer email updated successfully",
 		"status":        "pending",
-		"system_status": "coming_soon",
-		"note":          "سيتم تفعيل الدفعات التلقائية قريباً. حالياً استمر باستخدام طريقة الدفع المحددة في ملفك الشخصي.",
+		"note":          "أضف Payoneer كوسيلة دفع من /api/promoter/payout-methods لاستلام أرباحك تلقائياً.",
 	})
 }
 
@@ -431,14 +564,14 @@ func (h *PayoutHandler) UpdatePromoterPayoneerEmail(c *gin.Context) {
 // GetAdvertiserPayouts returns payouts that an advertiser needs to pay
 // GET /api/advertiser/payouts
 func (h *PayoutHandler) GetAdvertiserPayouts(c *gin.Context) {
-	userID := c.GetString("user_id")
-	if userID == "" {
+	userID := requestActor(c)
+	if userID == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 	
 	var payouts []models.Payout
-	h.db.Preload("Publisher").Where("advertiser_id = ?", userID).
+	tenantDB(c, h.db).Preload("Publisher").Where("advertiser_id = ?", *userID).
 		Order("created_at DESC").Limit(100).Find(&payouts)
 	
 	// حساب الإجماليات
@@ -463,8 +596,8 @@ func (h *PayoutHandler) GetAdvertiserPayouts(c *gin.Context) {
 // UpdateAdvertiserPayoneerEmail updates the advertiser's Payoneer email
 // PUT /api/advertiser/payoneer-email
 func (h *PayoutHandler) UpdateAdvertiserPayoneerEmail(c *gin.Context) {
-	userID := c.GetString("user_id")
-	if userID == "" {
+	userID := requestActor(c)
+	if userID == nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
@@ -477,7 +610,7 @@ func (h *PayoutHandler) UpdateAdvertiserPayoneerEmail(c *gin.Context) {
 		return
 	}
 	
-	result := h.db.Model(&models.AfftokUser{}).Where("id = ?", userID).Updates(map[string]interface{}{
+	result := tenantDB(c, h.db).Model(&models.AfftokUser{}).Where("id = ?", *userID).Updates(map[string]interface{}{
 		"payoneer_email":  req.PayoneerEmail,
 		"payoneer_status": "pending",
 		"updated_at":      time.Now(),
@@ -497,8 +630,7 @@ func (h *PayoutHandler) UpdateAdvertiserPayoneerEmail(c *gin.Context) {
 }
 
 // ============================================================
-// ملاحظة: هذه الـ endpoints معطلة عملياً
-// تُرجع البيانات مع رسالة "coming_soon"
-// سيتم تفعيلها بعد التعاقد مع Payoneer
+// ملاحظة: نقاط المعلن ما زالت تُرجع "coming_soon" - المعلن يدفع
+// للمروجين مباشرة حتى تفعيل الخصم التلقائي من حسابه
 // ============================================================
 
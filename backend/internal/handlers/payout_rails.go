@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+)
+
+// ============================================================
+// Payout Rails - قنوات الدفع (Payoneer, PayPal, ملفات التحويل)
+// ============================================================
+
+// GetPayoutRails lists the payout rails and whether each is configured
+// GET /api/admin/payouts/rails
+func (h *PayoutHandler) GetPayoutRails(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"rails": h.payoutService.Rails().Status()})
+}
+
+// GetPayoutBatchRails lists what a batch sent to each rail
+// GET /api/admin/payouts/batches/:id/rails
+func (h *PayoutHandler) GetPayoutBatchRails(c *gin.Context) {
+	batchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch ID"})
+		return
+	}
+
+	submissions, err := h.payoutService.RailSubmissions(middleware.GetTenantID(c), batchID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rail submissions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"submissions": submissions, "total": len(submissions)})
+}
+
+// DispatchPayoutBatch retries sending a submitted batch's lines that no rail accepted yet
+// POST /api/admin/payouts/batches/:id/dispatch
+func (h *PayoutHandler) DispatchPayoutBatch(c *gin.Context) {
+	batchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Minute)
+	defer cancel()
+	report, err := h.payoutService.DispatchBatch(ctx, middleware.GetTenantID(c), batchID, requestActor(c))
+	if err != nil {
+		c.JSON(payoutErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"dispatch": report})
+}
+
+// PollPayoutBatch asks the rails for results now instead of waiting for the poller
+// POST /api/admin/payouts/batches/:id/poll
+func (h *PayoutHandler) PollPayoutBatch(c *gin.Context) {
+	batchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Minute)
+	defer cancel()
+	report, err := h.payoutService.PollBatch(ctx, middleware.GetTenantID(c), batchID, requestActor(c))
+	if err != nil {
+		c.JSON(payoutErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reconciliation": report})
+}
+
+// DownloadPayoutRailFile downloads the transfer file of a file rail (bank_transfer, wise)
+// GET /api/admin/payouts/batches/:id/rails/:rail/file
+func (h *PayoutHandler) DownloadPayoutRailFile(c *gin.Context) {
+	batchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid batch ID"})
+		return
+	}
+
+	submission, err := h.payoutService.RailFile(middleware.GetTenantID(c), batchID, c.Param("rail"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No transfer file for this batch and rail"})
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename="+submission.FileName)
+	c.Data(http.StatusOK, submission.FileType, submission.FileContent)
+}
+
+// RailWebhook receives signed payout status notifications from a rail
+// POST /api/payouts/webhook/:rail
+func (h *PayoutHandler) RailWebhook(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read body"})
+		return
+	}
+
+	event, duplicate, err := h.payoutService.HandleRailWebhook(c.Request.Context(), c.Param("rail"), c.Request.Header, body)
+	switch {
+	case errors.Is(err, services.ErrPayoutRailInvalidSignature):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+		return
+	case errors.Is(err, services.ErrPayoutRailDisabled), errors.Is(err, services.ErrPayoutRailUnknown),
+		errors.Is(err, services.ErrPayoutRailUnsupported):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Payout rail not configured"})
+		return
+	case errors.Is(err, services.ErrPayoutRailEventIgnored):
+		// Acknowledge so the rail stops retrying
+		c.JSON(http.StatusOK, gin.H{"ignored": true})
+		return
+	case err != nil:
+		log.Printf("[Payouts] %s webhook processing failed: %v", c.Param("rail"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process event"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"event_id": event.ID, "duplicate": duplicate})
+}
+
+// ============================================================
+// Promoter payout methods - وسائل استلام الأرباح
+// ============================================================
+
+// GetMyPayoutMethods lists the promoter's payout methods
+// GET /api/promoter/payout-methods
+func (h *PayoutHandler) GetMyPayoutMethods(c *gin.Context) {
+	userID := requestActor(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	methods, err := h.payoutService.ListPayoutMethods(*userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payout methods"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"payout_methods": methods, "rails": h.payoutService.Rails().Status()})
+}
+
+// AddPayoutMethod adds a payout method; it is validated with its rail right away
+// POST /api/promoter/payout-methods
+func (h *PayoutHandler) AddPayoutMethod(c *gin.Context) {
+	userID := requestActor(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		Rail        string `json:"rail" binding:"required,oneof=payoneer paypal bank_transfer wise"`
+		IsDefault   bool   `json:"is_default"`
+		Email       string `json:"email"`
+		PayeeID     string `json:"payee_id"`
+		AccountName string `json:"account_name"`
+		IBAN        string `json:"iban"`
+		BIC         string `json:"bic"`
+		BankName    string `json:"bank_name"`
+		Country     string `json:"country" binding:"omitempty,len=2"`
+		Currency    string `json:"currency" binding:"omitempty,len=3"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	method := &models.PayoutMethod{
+		UserID:      *userID,
+		Rail:        req.Rail,
+		IsDefault:   req.IsDefault,
+		Email:       req.Email,
+		PayeeID:     req.PayeeID,
+		AccountName: req.AccountName,
+		IBAN:        req.IBAN,
+		BIC:         req.BIC,
+		BankName:    req.BankName,
+		Country:     req.Country,
+		Currency:    req.Currency,
+	}
+	if err := h.payoutService.AddPayoutMethod(c.Request.Context(), middleware.GetTenantID(c), method); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrPayoutMethodRail) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := http.StatusCreated
+	if method.Status == models.PayoutMethodInvalid {
+		status = http.StatusUnprocessableEntity
+	}
+	c.JSON(status, gin.H{"payout_method": method})
+}
+
+// SetDefaultPayoutMethod makes a verified payout method the default
+// PUT /api/promoter/payout-methods/:id/default
+func (h *PayoutHandler) SetDefaultPayoutMethod(c *gin.Context) {
+	userID := requestActor(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	methodID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payout method ID"})
+		return
+	}
+
+	method, err := h.payoutService.SetDefaultPayoutMethod(*userID, methodID)
+	switch {
+	case errors.Is(err, services.ErrPayoutMethodNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrPayoutPayeeInvalid):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payout method"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"payout_method": method})
+}
+
+// DeletePayoutMethod removes a payout method
+// DELETE /api/promoter/payout-methods/:id
+func (h *PayoutHandler) DeletePayoutMethod(c *gin.Context) {
+	userID := requestActor(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	methodID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payout method ID"})
+		return
+	}
+
+	if err := h.payoutService.DeletePayoutMethod(*userID, methodID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrPayoutMethodNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Payout method removed"})
+}
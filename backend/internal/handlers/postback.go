@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/aljapah/afftok-backend-prod/internal/database"
 	"github.com/aljapah/afftok-backend-prod/internal/models"
 	"github.com/aljapah/afftok-backend-prod/internal/services"
 	"github.com/gin-gonic/gin"
@@ -19,6 +20,8 @@ type PostbackHandler struct {
 	observabilityService *services.ObservabilityService
 	apiKeyService        *services.APIKeyService
 	geoRuleService       *services.GeoRuleService
+	anomalyService       *services.ConversionAnomalyService
+	settingsResolver     *services.TenantSettingsResolver
 	badgeHandler         *BadgeHandler
 }
 
@@ -29,6 +32,8 @@ func NewPostbackHandler(db *gorm.DB) *PostbackHandler {
 		observabilityService: services.NewObservabilityService(),
 		apiKeyService:        services.NewAPIKeyService(db),
 		geoRuleService:       services.NewGeoRuleService(db),
+		anomalyService:       services.GetConversionAnomalyService(db),
+		settingsResolver:     services.GetTenantSettingsResolver(db),
 		badgeHandler:         NewBadgeHandler(db),
 	}
 }
@@ -43,6 +48,11 @@ func (h *PostbackHandler) SetGeoRuleService(service *services.GeoRuleService) {
 	h.geoRuleService = service
 }
 
+// SetConversionAnomalyService sets the conversion anomaly service (for dependency injection)
+func (h *PostbackHandler) SetConversionAnomalyService(service *services.ConversionAnomalyService) {
+	h.anomalyService = service
+}
+
 // PostbackRequest represents incoming postback data from advertisers
 type PostbackRequest struct {
 	// Required fields
@@ -58,6 +68,7 @@ type PostbackRequest struct {
 	Commission   int    `json:"commission" form:"commission" query:"commission"`
 	Currency     string `json:"currency" form:"currency" query:"currency"`
 	Status       string `json:"status" form:"status" query:"status"`
+	Goal         string `json:"goal" form:"goal" query:"goal"` // e.g. sale, lead, deposit
 	
 	// External identifiers
 	ExternalID   string `json:"external_id" form:"external_id" query:"external_id"`
@@ -173,6 +184,10 @@ func (h *PostbackHandler) HandlePostback(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parameter length"})
 		return
 	}
+	if req.Currency != "" && !services.ValidCurrency(req.Currency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency (expected ISO 4217 code)"})
+		return
+	}
 	
 	// ============================================
 	// TIMESTAMP & NONCE VALIDATION (Replay Protection)
@@ -270,6 +285,14 @@ func (h *PostbackHandler) HandlePostback(c *gin.Context) {
 		return
 	}
 
+	// An advertiser API key can only post conversions for its own tenant
+	if value, ok := c.Get("api_key_tenant_id"); ok {
+		if keyTenantID, ok := value.(uuid.UUID); ok && keyTenantID != uuid.Nil && keyTenantID != userOffer.TenantID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User offer not found"})
+			return
+		}
+	}
+
 	// Generate unique external ID if not provided
 	externalID := req.ExternalID
 	if externalID == "" {
@@ -424,6 +447,10 @@ func (h *PostbackHandler) HandlePostback(c *gin.Context) {
 		autoRejectFraud = userOffer.Offer.AutoRejectFraud
 	}
 
+	// Tenants that turned fraud detection off get neither auto-rejects nor anomaly holds
+	fraudDetection := h.settingsResolver.Get(userOffer.TenantID).EnableFraudDetection
+	autoRejectFraud = autoRejectFraud && fraudDetection
+
 	// Resolve network ID
 	var networkID *uuid.UUID
 	if req.NetworkID != "" {
@@ -456,12 +483,19 @@ func (h *PostbackHandler) HandlePostback(c *gin.Context) {
 		})
 	}
 
-	// Determine currency
-	currency := req.Currency
-	if currency == "" {
-		currency = "USD"
+	// 6. Anomaly hold: promoter×offer pairs with an open anomaly go to review;
+	// the reported status is kept and restored on release
+	heldStatus := ""
+	if status != models.ConversionStatusRejected && h.anomalyService != nil && fraudDetection &&
+		h.anomalyService.IsHeld(userOffer.UserID, userOffer.OfferID) {
+		heldStatus = status
+		status = models.ConversionStatusReview
+		h.anomalyService.RecordHeld(userOffer.UserID, userOffer.OfferID)
 	}
 
+	// Determine currency
+	currency := services.NormalizeCurrency(req.Currency)
+
 	// Calculate commission if not provided
 	commission := req.Commission
 	if commission == 0 && userOffer.Offer != nil {
@@ -491,8 +525,10 @@ func (h *PostbackHandler) HandlePostback(c *gin.Context) {
 		Amount:               req.Amount,
 		Commission:           commission,
 		Currency:             currency,
+		Goal:                 models.NormalizeConversionGoal(req.Goal),
 		Status:               status,
 		RejectionReason:      rejectionReason,
+		HeldStatus:           heldStatus,
 		FraudScore:           fraudScore,
 		FraudFlags:           string(fraudFlagsJSON),
 		AutoRejected:         autoRejected,
@@ -500,6 +536,9 @@ func (h *PostbackHandler) HandlePostback(c *gin.Context) {
 		PostbackData:         string(postbackData),
 		PostbackReceivedAt:   &now,
 	}
+	if status == models.ConversionStatusApproved {
+		conversion.ApprovedAt = &now
+	}
 
 	// Use transaction for atomic updates
 	err = h.db.Transaction(func(tx *gorm.DB) error {
@@ -508,17 +547,13 @@ func (h *PostbackHandler) HandlePostback(c *gin.Context) {
 			return fmt.Errorf("failed to create conversion: %w", err)
 		}
 
-		// 2. Update UserOffer stats (conversion counter + earnings + updated_at)
-		userOfferUpdates := map[string]interface{}{
-			"total_conversions": gorm.Expr("total_conversions + 1"),
-			"updated_at":        now,
-		}
-		if status == models.ConversionStatusApproved {
-			userOfferUpdates["earnings"] = gorm.Expr("earnings + ?", commission)
-		}
+		// 2. Update UserOffer stats (conversion counter + updated_at)
 		if err := tx.Model(&models.UserOffer{}).
 			Where("id = ?", userOfferID).
-			UpdateColumns(userOfferUpdates).Error; err != nil {
+			UpdateColumns(map[string]interface{}{
+				"total_conversions": gorm.Expr("total_conversions + 1"),
+				"updated_at":        now,
+			}).Error; err != nil {
 			return fmt.Errorf("failed to update user offer: %w", err)
 		}
 
@@ -536,12 +571,10 @@ func (h *PostbackHandler) HandlePostback(c *gin.Context) {
 			return fmt.Errorf("failed to update user conversions: %w", err)
 		}
 
-		// 5. If approved, update earnings
+		// 5. If approved, book it in the ledger (also credits the earnings counters)
 		if status == models.ConversionStatusApproved {
-			if err := tx.Model(&models.AfftokUser{}).
-				Where("id = ?", userOffer.UserID).
-				UpdateColumn("total_earnings", gorm.Expr("total_earnings + ?", commission)).Error; err != nil {
-				return fmt.Errorf("failed to update user earnings: %w", err)
+			if _, err := services.GetLedgerService(h.db).PostConversionApproved(tx, &conversion); err != nil {
+				return err
 			}
 		}
 
@@ -555,6 +588,9 @@ func (h *PostbackHandler) HandlePostback(c *gin.Context) {
 
 	fmt.Printf("[Postback] Conversion created: %s for user offer %s\n", conversion.ID.String(), userOfferID.String())
 
+	// Meter the conversion for the tenant's plan usage
+	services.GetUsageService(h.db).Record(conversion.TenantID, models.UsageMetricConversions, 1)
+
 	// Check and award badges for the user (gamification)
 	go func() {
 		if err := h.badgeHandler.CheckAndAwardBadges(userOffer.UserID); err != nil {
@@ -562,6 +598,11 @@ func (h *PostbackHandler) HandlePostback(c *gin.Context) {
 		}
 	}()
 
+	// Re-evaluate the pair against its baseline (throttled)
+	if h.anomalyService != nil && fraudDetection {
+		h.anomalyService.ScheduleEvaluation(userOffer.UserID, userOffer.OfferID)
+	}
+
 	// Log conversion with full observability
 	durationMs := time.Since(startTime).Milliseconds()
 	h.observabilityService.LogConversion(
@@ -618,7 +659,7 @@ func (h *PostbackHandler) ApproveConversion(c *gin.Context) {
 	conversionID := c.Param("id")
 
 	var conversion models.Conversion
-	if err := h.db.First(&conversion, "id = ?", conversionID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&conversion, "id = ?", conversionID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Conversion not found"})
 		return
 	}
@@ -631,13 +672,7 @@ func (h *PostbackHandler) ApproveConversion(c *gin.Context) {
 	now := time.Now().UTC()
 	
 	// Use transaction for atomic update
-	err := h.db.Transaction(func(tx *gorm.DB) error {
-		// Get user offer for user ID
-		var userOffer models.UserOffer
-		if err := tx.First(&userOffer, "id = ?", conversion.UserOfferID).Error; err != nil {
-			return err
-		}
-
+	err := tenantDB(c, h.db).Transaction(func(tx *database.TenantDB) error {
 		// Update conversion status
 		conversion.Status = models.ConversionStatusApproved
 		conversion.ApprovedAt = &now
@@ -645,14 +680,9 @@ func (h *PostbackHandler) ApproveConversion(c *gin.Context) {
 			return err
 		}
 
-		// Update user earnings
-		if err := tx.Model(&models.AfftokUser{}).
-			Where("id = ?", userOffer.UserID).
-			UpdateColumn("total_earnings", gorm.Expr("total_earnings + ?", conversion.Commission)).Error; err != nil {
-			return err
-		}
-
-		return nil
+		// Book the approval (credits the earnings counters)
+		_, err := services.GetLedgerService(h.db).PostConversionApproved(tx.DB, &conversion)
+		return err
 	})
 
 	if err != nil {
@@ -679,7 +709,7 @@ func (h *PostbackHandler) RejectConversion(c *gin.Context) {
 	c.ShouldBindJSON(&req)
 
 	var conversion models.Conversion
-	if err := h.db.First(&conversion, "id = ?", conversionID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&conversion, "id = ?", conversionID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Conversion not found"})
 		return
 	}
@@ -692,7 +722,7 @@ func (h *PostbackHandler) RejectConversion(c *gin.Context) {
 	conversion.Status = models.ConversionStatusRejected
 	conversion.RejectionReason = req.Reason
 
-	if err := h.db.Save(&conversion).Error; err != nil {
+	if err := tenantDB(c, h.db).Save(&conversion).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reject conversion"})
 		return
 	}
@@ -710,7 +740,7 @@ func (h *PostbackHandler) GetConversions(c *gin.Context) {
 	userOfferID := c.Query("user_offer_id")
 	networkID := c.Query("network_id")
 
-	query := h.db.Model(&models.Conversion{}).Order("converted_at DESC")
+	query := tenantDB(c, h.db).Model(&models.Conversion{}).Order("converted_at DESC")
 
 	if status != "" {
 		query = query.Where("status = ?", status)
@@ -2,11 +2,13 @@ package handlers
 
 import (
 	"fmt"
-	"io/ioutil"
+	"log"
 	"net/http"
 	"strings"
 
+	"github.com/aljapah/afftok-backend-prod/internal/database"
 	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -25,13 +27,13 @@ func (h *PromoterHandler) GetPromoterPage(c *gin.Context) {
 
 	id, err := uuid.Parse(userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		servePageError(c, h.db, uuid.Nil, http.StatusBadRequest, services.PageErrorPromoterNotFound)
 		return
 	}
 
 	var user models.AfftokUser
-	if err := h.db.Where("id = ?", id).First(&user).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if err := h.db.Where("id = ?", id).First(&user).Error; err != nil || !brandedDomainAllows(c, user.TenantID) {
+		servePageError(c, h.db, uuid.Nil, http.StatusNotFound, services.PageErrorPromoterNotFound)
 		return
 	}
 
@@ -42,8 +44,8 @@ func (h *PromoterHandler) GetPromoterPageByUsername(c *gin.Context) {
 	username := c.Param("username")
 
 	var user models.AfftokUser
-	if err := h.db.Where("username = ?", username).First(&user).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	if err := h.db.Where("username = ?", username).First(&user).Error; err != nil || !brandedDomainAllows(c, user.TenantID) {
+		servePageError(c, h.db, uuid.Nil, http.StatusNotFound, services.PageErrorPromoterNotFound)
 		return
 	}
 
@@ -55,8 +57,8 @@ func (h *PromoterHandler) GetPromoterPageByCode(c *gin.Context) {
 	code := c.Param("code")
 
 	var user models.AfftokUser
-	if err := h.db.Where("unique_code = ?", code).First(&user).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Invalid referral code"})
+	if err := h.db.Where("unique_code = ?", code).First(&user).Error; err != nil || !brandedDomainAllows(c, user.TenantID) {
+		servePageError(c, h.db, uuid.Nil, http.StatusNotFound, services.PageErrorPromoterNotFound)
 		return
 	}
 
@@ -68,7 +70,7 @@ func (h *PromoterHandler) GetPromoterByCode(c *gin.Context) {
 	code := c.Param("code")
 
 	var user models.AfftokUser
-	if err := h.db.Where("unique_code = ?", code).First(&user).Error; err != nil {
+	if err := h.db.Where("unique_code = ?", code).First(&user).Error; err != nil || !brandedDomainAllows(c, user.TenantID) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Invalid referral code"})
 		return
 	}
@@ -84,112 +86,73 @@ func (h *PromoterHandler) GetPromoterByCode(c *gin.Context) {
 }
 
 func (h *PromoterHandler) servePromoterPage(c *gin.Context, user models.AfftokUser) {
+	// Public page: scope to the promoter's own tenant
+	db := database.Tenant(h.db, user.TenantID)
+
 	var offers []models.Offer
-	if err := h.db.Where("status = ?", "active").Order("created_at DESC").Find(&offers).Error; err != nil {
+	if err := db.Where("status = ?", "active").Order("created_at DESC").Find(&offers).Error; err != nil {
 		offers = []models.Offer{}
 	}
 
 	var totalClicks int64
 	var totalOffers int64
 
-	h.db.Model(&models.Click{}).
+	db.Model(&models.Click{}).
 		Joins("JOIN user_offers ON clicks.user_offer_id = user_offers.id").
 		Where("user_offers.user_id = ? AND user_offers.status = ?", user.ID, "active").
 		Count(&totalClicks)
 
-	h.db.Model(&models.UserOffer{}).
+	db.Model(&models.UserOffer{}).
 		Where("user_id = ? AND status = ?", user.ID, "active").
 		Count(&totalOffers)
 
-	html := h.generateHTML(user, offers, int(totalOffers), int(totalClicks))
-	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(html))
+	page, err := services.GetPageTemplateService(h.db).RenderPromoterPage(user.TenantID, pageLocale(c),
+		h.promoterPageData(user, offers, totalOffers, totalClicks))
+	if err != nil {
+		log.Printf("[Promoter] render page for %s failed: %v", user.ID, err)
+		servePageError(c, h.db, user.TenantID, http.StatusInternalServerError, "")
+		return
+	}
+	writePage(c, page)
 }
 
-func (h *PromoterHandler) generateHTML(user models.AfftokUser, offers []models.Offer, totalOffers int, totalClicks int) string {
-	// Read the template file from public folder
-	filePath := "public/promoter_landing.html"
-	data, err := ioutil.ReadFile(filePath)
-	if err != nil {
-		// Try alternate path
-		filePath = "./public/promoter_landing.html"
-		data, err = ioutil.ReadFile(filePath)
-		if err != nil {
-			// Return error page if template not found
-			return fmt.Sprintf(`<!DOCTYPE html>
-<html><head><title>Error</title></head>
-<body style="background:#0a0a0a;color:#fff;font-family:sans-serif;text-align:center;padding:50px;">
-<h1>Template not found</h1>
-<p>Path: %s</p>
-<p>Error: %s</p>
-</body></html>`, filePath, err.Error())
-		}
+// promoterPageData builds the landing page view of a promoter
+func (h *PromoterHandler) promoterPageData(user models.AfftokUser, offers []models.Offer, totalOffers, totalClicks int64) *services.PromoterPageData {
+	name := user.FullName
+	if name == "" {
+		name = user.Username
 	}
-	
-	html := string(data)
-	
-	// Build offers JSON
-	offersJSON := "["
-	for i, offer := range offers {
-		if i > 0 {
-			offersJSON += ","
-		}
-		// Escape special characters in strings
-		title := strings.ReplaceAll(offer.Title, `"`, `\"`)
-		title = strings.ReplaceAll(title, "\n", " ")
-		desc := strings.ReplaceAll(offer.Description, `"`, `\"`)
-		desc = strings.ReplaceAll(desc, "\n", " ")
-		
-		offersJSON += fmt.Sprintf(`{"id":"%s","title":"%s","description":"%s","image_url":"%s","category":"%s","payout":%d,"payout_type":"%s"}`,
-			offer.ID.String(),
-			title,
-			desc,
-			offer.ImageURL,
-			offer.Category,
-			offer.Payout,
-			offer.PayoutType)
-	}
-	offersJSON += "]"
-	
-	// Get promoter rating
-	promoterRating := h.GetPromoterRating(user.ID)
-	
-	// Escape user data
-	fullName := strings.ReplaceAll(user.FullName, `"`, `\"`)
-	if fullName == "" {
-		fullName = user.Username
-	}
-	bio := strings.ReplaceAll(user.Bio, `"`, `\"`)
-	bio = strings.ReplaceAll(bio, "\n", " ")
-	
-	// Inject promoter data into the page
-	dataScript := fmt.Sprintf(`<script>
-		window.promoterId = "%s";
-		window.promoterData = {
-			id: "%s",
-			name: "%s",
-			username: "%s",
-			bio: "%s",
-			avatar: "%s",
-			rating: %.1f,
-			totalClicks: %d,
-			totalOffers: %d
-		};
-		window.offersData = %s;
-	</script>`,
-		user.ID.String(),
-		user.ID.String(),
-		fullName,
-		user.Username,
-		bio,
-		user.AvatarURL,
-		promoterRating,
-		totalClicks,
-		totalOffers,
-		offersJSON)
-	
-	// Insert the data script before </head>
-	html = strings.Replace(html, "</head>", dataScript+"</head>", 1)
-	return html
+
+	data := &services.PromoterPageData{
+		ID:          user.ID.String(),
+		Name:        name,
+		Username:    user.Username,
+		Bio:         user.Bio,
+		AvatarURL:   user.AvatarURL,
+		Rating:      h.GetPromoterRating(user.ID),
+		TotalClicks: totalClicks,
+		TotalOffers: totalOffers,
+		Offers:      make([]services.PageOffer, 0, len(offers)),
+	}
+	for _, offer := range offers {
+		data.Offers = append(data.Offers, services.PageOffer{
+			Title:       offer.Title,
+			Description: offer.Description,
+			ImageURL:    offer.ImageURL,
+			Category:    offer.Category,
+			Payout:      formatOfferPayout(offer),
+			Link:        fmt.Sprintf("/api/c/%s?promoter=%s", offer.ID, user.ID),
+		})
+	}
+	return data
+}
+
+// formatOfferPayout renders an offer's payout the way the landing page shows it
+func formatOfferPayout(offer models.Offer) string {
+	if offer.PayoutType == "percentage" {
+		return fmt.Sprintf("%d%%", offer.Payout)
+	}
+	return strings.TrimSpace(fmt.Sprintf("$%d %s", offer.Payout, strings.ToUpper(offer.PayoutType)))
 }
 
 func (h *PromoterHandler) RatePromoter(c *gin.Context) {
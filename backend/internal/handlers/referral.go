@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// REFERRALS & OVERRIDE COMMISSIONS HANDLER
+// ============================================
+
+// ReferralHandler serves promoters' referrals and override earnings
+type ReferralHandler struct {
+	db              *gorm.DB
+	referralService *services.ReferralService
+}
+
+// NewReferralHandler creates a new referral handler
+func NewReferralHandler(db *gorm.DB) *ReferralHandler {
+	return &ReferralHandler{
+		db:              db,
+		referralService: services.GetReferralService(db),
+	}
+}
+
+func (h *ReferralHandler) fail(c *gin.Context, correlationID string, status int, err error) {
+	c.JSON(status, gin.H{
+		"success":        false,
+		"correlation_id": correlationID,
+		"error":          err.Error(),
+	})
+}
+
+func (h *ReferralHandler) ok(c *gin.Context, correlationID string, data interface{}) {
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           data,
+	})
+}
+
+// referralErrorStatus maps referral errors to HTTP statuses
+func referralErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrReferralInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrReferralNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// pageParams reads ?limit= and ?offset=
+func (h *ReferralHandler) pageParams(c *gin.Context) (int, int) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+// overrideSettings is the override configuration shown to promoters
+func overrideSettings(settings *models.TenantSettings) gin.H {
+	return gin.H{
+		"referral_override_bps":    settings.ReferralOverrideBps,
+		"referral_override_months": settings.ReferralOverrideMonths,
+		"team_override_bps":        settings.TeamOverrideBps,
+		"override_cap_bps":         settings.OverrideCapBps,
+	}
+}
+
+// GetMyReferrals lists the promoters the caller referred and the override
+// rates of the tenant
+// GET /api/referrals/me
+func (h *ReferralHandler) GetMyReferrals(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	userID := requestActor(c)
+	if userID == nil {
+		h.fail(c, correlationID, http.StatusUnauthorized, errors.New("User not authenticated"))
+		return
+	}
+
+	tenantID := middleware.GetTenantID(c)
+	limit, offset := h.pageParams(c)
+	referrals, total, err := h.referralService.List(tenantID, services.ReferralFilter{ReferrerID: userID}, limit, offset)
+	if err != nil {
+		h.fail(c, correlationID, http.StatusInternalServerError, err)
+		return
+	}
+	h.ok(c, correlationID, gin.H{
+		"referrals": referrals,
+		"total":     total,
+		"limit":     limit,
+		"offset":    offset,
+		"settings":  overrideSettings(services.GetTenantSettingsResolver(h.db).Get(tenantID)),
+	})
+}
+
+// GetMyOverrides lists the caller's override earnings, each linked to the
+// conversion it was earned on
+// GET /api/referrals/overrides
+func (h *ReferralHandler) GetMyOverrides(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	userID := requestActor(c)
+	if userID == nil {
+		h.fail(c, correlationID, http.StatusUnauthorized, errors.New("User not authenticated"))
+		return
+	}
+
+	limit, offset := h.pageParams(c)
+	earnings, total, err := h.referralService.ListOverrideEarnings(middleware.GetTenantID(c), *userID, limit, offset)
+	if err != nil {
+		h.fail(c, correlationID, http.StatusInternalServerError, err)
+		return
+	}
+	h.ok(c, correlationID, gin.H{
+		"overrides": earnings,
+		"total":     total,
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
+
+// ListReferrals lists the tenant's referrals
+// GET /api/admin/referrals?referrer_id=&referee_id=&status=&limit=&offset=
+func (h *ReferralHandler) ListReferrals(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	filter := services.ReferralFilter{Status: c.Query("status")}
+	for param, dest := range map[string]**uuid.UUID{"referrer_id": &filter.ReferrerID, "referee_id": &filter.RefereeID} {
+		if v := c.Query(param); v != "" {
+			id, err := uuid.Parse(v)
+			if err != nil {
+				h.fail(c, correlationID, http.StatusBadRequest, errors.New("Invalid "+param))
+				return
+			}
+			*dest = &id
+		}
+	}
+
+	limit, offset := h.pageParams(c)
+	referrals, total, err := h.referralService.List(middleware.GetTenantID(c), filter, limit, offset)
+	if err != nil {
+		h.fail(c, correlationID, http.StatusInternalServerError, err)
+		return
+	}
+	h.ok(c, correlationID, gin.H{
+		"referrals": referrals,
+		"total":     total,
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
+
+// GetPromoterOverrides lists a promoter's override earnings
+// GET /api/admin/promoters/:id/overrides
+func (h *ReferralHandler) GetPromoterOverrides(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	promoterID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.fail(c, correlationID, http.StatusBadRequest, errors.New("Invalid promoter ID"))
+		return
+	}
+
+	limit, offset := h.pageParams(c)
+	earnings, total, err := h.referralService.ListOverrideEarnings(middleware.GetTenantID(c), promoterID, limit, offset)
+	if err != nil {
+		h.fail(c, correlationID, http.StatusInternalServerError, err)
+		return
+	}
+	h.ok(c, correlationID, gin.H{
+		"overrides": earnings,
+		"total":     total,
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
+
+// SetReferralExcluded excludes a referral from override commissions (e.g.
+// a self-referral ring found in a fraud review) or reinstates it. Overrides
+// already posted stay; they are reversed with their source conversions.
+// PUT /api/admin/referrals/:id/exclusion {"excluded": true, "reason": "..."}
+func (h *ReferralHandler) SetReferralExcluded(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	referralID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.fail(c, correlationID, http.StatusBadRequest, errors.New("Invalid referral ID"))
+		return
+	}
+	var req struct {
+		Excluded bool   `json:"excluded"`
+		Reason   string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.fail(c, correlationID, http.StatusBadRequest, err)
+		return
+	}
+
+	referral, err := h.referralService.SetExcluded(middleware.GetTenantID(c), referralID, req.Excluded, req.Reason, requestActor(c))
+	if err != nil {
+		h.fail(c, correlationID, referralErrorStatus(err), err)
+		return
+	}
+	h.ok(c, correlationID, referral)
+}
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/aljapah/afftok-backend-prod/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// TENANT SSO HANDLER
+// ============================================
+
+// SSOHandler serves tenant SSO login flows and configuration
+type SSOHandler struct {
+	ssoService           *services.SSOService
+	observabilityService *services.ObservabilityService
+}
+
+// NewSSOHandler creates a new SSO handler
+func NewSSOHandler(db *gorm.DB) *SSOHandler {
+	return &SSOHandler{
+		ssoService:           services.GetSSOService(db),
+		observabilityService: services.NewObservabilityService(),
+	}
+}
+
+// ssoServiceErrorStatus maps SSO errors to HTTP status codes
+func ssoServiceErrorStatus(err error) int {
+	var limitErr *services.UsageLimitError
+	switch {
+	case errors.Is(err, services.ErrSSOInvalidConfig),
+		errors.Is(err, services.ErrSSOInvalidCode):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrSSONotAvailable),
+		errors.Is(err, services.ErrSSOTenantInactive),
+		errors.Is(err, services.ErrSSOUserSuspended),
+		errors.As(err, &limitErr):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrSSONotConfigured),
+		errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ssoFailureReason is the message shown to a user whose IdP login failed.
+// Unexpected errors (IdP unreachable, database) are logged, not shown.
+func ssoFailureReason(err error) string {
+	var limitErr *services.UsageLimitError
+	for _, known := range []error{
+		services.ErrSSONotConfigured, services.ErrSSOTenantInactive, services.ErrSSOInvalidState,
+		services.ErrSSOAssertionReplayed, services.ErrSSONoEmail, services.ErrSSOEmailDomain,
+		services.ErrSSOEmailTaken, services.ErrSSONotProvisioned, services.ErrSSOUserSuspended,
+		services.ErrSAMLResponseInvalid, services.ErrOIDCTokenInvalid,
+	} {
+		if errors.Is(err, known) {
+			return known.Error()
+		}
+	}
+	if errors.As(err, &limitErr) {
+		return "the organization has reached its user limit"
+	}
+	return "sso login failed"
+}
+
+func (h *SSOHandler) fail(c *gin.Context, correlationID string, err error) {
+	c.JSON(ssoServiceErrorStatus(err), gin.H{
+		"success":        false,
+		"correlation_id": correlationID,
+		"error":          err.Error(),
+	})
+}
+
+func (h *SSOHandler) ok(c *gin.Context, correlationID string, data interface{}) {
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           data,
+	})
+}
+
+// ============================================
+// LOGIN FLOW (public)
+// ============================================
+
+// GetSSOInfo tells a login page whether a tenant offers or requires SSO
+// GET /api/auth/sso/:tenant
+func (h *SSOHandler) GetSSOInfo(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	info, err := h.ssoService.PublicInfo(c.Param("tenant"))
+	if err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+	h.ok(c, correlationID, info)
+}
+
+// BeginLogin redirects the browser to the tenant's IdP
+// GET /api/auth/sso/:tenant/login
+func (h *SSOHandler) BeginLogin(c *gin.Context) {
+	slug := c.Param("tenant")
+	redirect, err := h.ssoService.BeginLogin(c.Request.Context(), slug)
+	if err != nil {
+		h.fail(c, generateCorrelationID(), err)
+		return
+	}
+	c.Redirect(http.StatusFound, redirect)
+}
+
+// OIDCCallback completes an OIDC login
+// GET /api/auth/sso/:tenant/oidc/callback?code=...&state=...
+func (h *SSOHandler) OIDCCallback(c *gin.Context) {
+	slug := c.Param("tenant")
+	if idpError := c.Query("error"); idpError != "" {
+		h.callbackFailed(c, slug, errors.New("identity provider error: "+idpError))
+		return
+	}
+
+	result, err := h.ssoService.CompleteOIDC(c.Request.Context(), slug, c.Query("state"), c.Query("code"))
+	if err != nil {
+		h.callbackFailed(c, slug, err)
+		return
+	}
+	h.callbackSucceeded(c, result)
+}
+
+// SAMLACS is the SAML assertion consumer service (HTTP-POST binding)
+// POST /api/auth/sso/:tenant/saml/acs
+func (h *SSOHandler) SAMLACS(c *gin.Context) {
+	slug := c.Param("tenant")
+	result, err := h.ssoService.CompleteSAML(c.Request.Context(), slug, c.PostForm("SAMLResponse"), c.PostForm("RelayState"))
+	if err != nil {
+		h.callbackFailed(c, slug, err)
+		return
+	}
+	h.callbackSucceeded(c, result)
+}
+
+// SAMLMetadata serves the SP metadata to import into the IdP
+// GET /api/auth/sso/:tenant/saml/metadata
+func (h *SSOHandler) SAMLMetadata(c *gin.Context) {
+	metadata, err := h.ssoService.SAMLMetadata(c.Param("tenant"))
+	if err != nil {
+		h.fail(c, generateCorrelationID(), err)
+		return
+	}
+	c.Data(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+func (h *SSOHandler) callbackSucceeded(c *gin.Context, result *services.SSOLoginResult) {
+	reason := ""
+	if result.Provisioned {
+		reason = "provisioned"
+	}
+	h.observabilityService.LogAuth(result.User.ID.String(), result.User.Username, c.ClientIP(), "sso_login", true, reason)
+	c.Redirect(http.StatusFound, result.RedirectURL)
+}
+
+func (h *SSOHandler) callbackFailed(c *gin.Context, slug string, err error) {
+	log.Printf("[SSO] login for tenant %s failed: %v", slug, err)
+	h.observabilityService.LogAuth("", "", c.ClientIP(), "sso_login", false, ssoFailureReason(err))
+	c.Redirect(http.StatusFound, h.ssoService.FailureRedirectURL(slug, errors.New(ssoFailureReason(err))))
+}
+
+// ExchangeCode trades the one-time sso_code from the callback redirect for
+// access and refresh tokens
+// POST /api/auth/sso-exchange {"code": "..."}
+func (h *SSOHandler) ExchangeCode(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.ssoService.Exchange(c.Request.Context(), req.Code)
+	if err != nil {
+		c.JSON(ssoServiceErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	accessToken, err := utils.GenerateTenantToken(user.ID, user.TenantID, user.Username, user.Email, user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	refreshToken, err := utils.GenerateRefreshToken(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate refresh token"})
+		return
+	}
+
+	user.PasswordHash = ""
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Login successful",
+		"user":          user,
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+	})
+}
+
+// ============================================
+// CONFIGURATION
+// ============================================
+
+func (h *SSOHandler) getConfig(c *gin.Context, correlationID string, tenantID uuid.UUID) {
+	tenant, cfg, err := h.ssoService.TenantConfig(tenantID)
+	if err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+
+	h.ok(c, correlationID, gin.H{
+		"config":            cfg,
+		"client_secret_set": cfg != nil && cfg.ClientSecret != "",
+		"endpoints":         h.ssoService.Endpoints(tenant, cfg),
+	})
+}
+
+func (h *SSOHandler) saveConfig(c *gin.Context, correlationID string, tenantID uuid.UUID) {
+	var req services.SSOConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
+	cfg, err := h.ssoService.SaveConfig(tenantID, &req, requestActor(c))
+	if err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+	h.ok(c, correlationID, cfg)
+}
+
+// GetSSOConfig returns the current tenant's SSO configuration and the URLs
+// to register at the IdP
+// GET /api/sso
+func (h *SSOHandler) GetSSOConfig(c *gin.Context) {
+	h.getConfig(c, generateCorrelationID(), middleware.GetTenantID(c))
+}
+
+// UpdateSSOConfig configures SSO for the current tenant
+// PUT /api/sso
+func (h *SSOHandler) UpdateSSOConfig(c *gin.Context) {
+	h.saveConfig(c, generateCorrelationID(), middleware.GetTenantID(c))
+}
+
+// DeleteSSOConfig removes SSO for the current tenant
+// DELETE /api/sso
+func (h *SSOHandler) DeleteSSOConfig(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	if err := h.ssoService.DeleteConfig(middleware.GetTenantID(c), requestActor(c)); err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+	h.ok(c, correlationID, nil)
+}
+
+// GetTenantSSOConfig returns any tenant's SSO configuration
+// GET /api/admin/tenants/:id/sso
+func (h *SSOHandler) GetTenantSSOConfig(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid tenant ID",
+		})
+		return
+	}
+	h.getConfig(c, correlationID, tenantID)
+}
+
+// UpdateTenantSSOConfig configures SSO for any tenant
+// PUT /api/admin/tenants/:id/sso
+func (h *SSOHandler) UpdateTenantSSOConfig(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid tenant ID",
+		})
+		return
+	}
+	h.saveConfig(c, correlationID, tenantID)
+}
@@ -1,14 +1,12 @@
 package handlers
 
 import (
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
+	"log"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -25,7 +23,7 @@ func NewTeamHandler(db *gorm.DB) *TeamHandler {
 func (h *TeamHandler) GetAllTeams(c *gin.Context) {
 	var teams []models.Team
 
-	query := h.db.Preload("Owner").Preload("Members.User")
+	query := tenantDB(c, h.db).Preload("Owner").Preload("Members.User")
 
 	status := c.Query("status")
 	if status != "" {
@@ -65,7 +63,7 @@ func (h *TeamHandler) GetTeam(c *gin.Context) {
 	teamID := c.Param("id")
 
 	var team models.Team
-	if err := h.db.Preload("Owner").Preload("Members.User").First(&team, "id = ?", teamID).Error; err != nil {
+	if err := tenantDB(c, h.db).Preload("Owner").Preload("Members.User").First(&team, "id = ?", teamID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
 		return
 	}
@@ -109,7 +107,7 @@ func (h *TeamHandler) CreateTeam(c *gin.Context) {
 	}
 
 	var existingMember models.TeamMember
-	if err := h.db.Where("user_id = ?", userID).First(&existingMember).Error; err == nil {
+	if err := tenantDB(c, h.db).Where("user_id = ?", userID).First(&existingMember).Error; err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "You are already in a team"})
 		return
 	}
@@ -126,10 +124,10 @@ func (h *TeamHandler) CreateTeam(c *gin.Context) {
 		MemberCount: 1,
 		Status:      "active",
 		InviteCode:  inviteCode,
-		InviteURL:   "https://go.afftokapp.com/api/invite/" + inviteCode,
+		InviteURL:   tenantBaseURL(c, h.db) + "/api/invite/" + inviteCode,
 	}
 
-	if err := h.db.Create(&team).Error; err != nil {
+	if err := tenantDB(c, h.db).Create(&team).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create team"})
 		return
 	}
@@ -143,7 +141,7 @@ func (h *TeamHandler) CreateTeam(c *gin.Context) {
 		Points: 0,
 	}
 
-	h.db.Create(&member)
+	tenantDB(c, h.db).Create(&member)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message":     "Team created successfully",
@@ -158,7 +156,7 @@ func (h *TeamHandler) JoinTeam(c *gin.Context) {
 	userID, _ := c.Get("userID")
 
 	var team models.Team
-	if err := h.db.First(&team, "id = ?", teamID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&team, "id = ?", teamID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
 		return
 	}
@@ -169,7 +167,7 @@ func (h *TeamHandler) JoinTeam(c *gin.Context) {
 	}
 
 	var existingMember models.TeamMember
-	if err := h.db.Where("user_id = ?", userID).First(&existingMember).Error; err == nil {
+	if err := tenantDB(c, h.db).Where("user_id = ?", userID).First(&existingMember).Error; err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "You are already in a team"})
 		return
 	}
@@ -182,12 +180,12 @@ func (h *TeamHandler) JoinTeam(c *gin.Context) {
 		Points: 0,
 	}
 
-	if err := h.db.Create(&member).Error; err != nil {
+	if err := tenantDB(c, h.db).Create(&member).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join team"})
 		return
 	}
 
-	h.db.Model(&team).UpdateColumn("member_count", h.db.Raw("member_count + 1"))
+	tenantDB(c, h.db).Model(&team).UpdateColumn("member_count", h.db.Raw("member_count + 1"))
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Joined team successfully",
@@ -200,7 +198,7 @@ func (h *TeamHandler) LeaveTeam(c *gin.Context) {
 	userID, _ := c.Get("userID")
 
 	var member models.TeamMember
-	if err := h.db.Where("team_id = ? AND user_id = ?", teamID, userID).First(&member).Error; err != nil {
+	if err := tenantDB(c, h.db).Where("team_id = ? AND user_id = ?", teamID, userID).First(&member).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "You are not in this team"})
 		return
 	}
@@ -210,14 +208,14 @@ func (h *TeamHandler) LeaveTeam(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Delete(&member).Error; err != nil {
+	if err := tenantDB(c, h.db).Delete(&member).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave team"})
 		return
 	}
 
 	var team models.Team
-	if err := h.db.First(&team, "id = ?", teamID).Error; err == nil {
-		h.db.Model(&team).UpdateColumn("member_count", h.db.Raw("member_count - 1"))
+	if err := tenantDB(c, h.db).First(&team, "id = ?", teamID).Error; err == nil {
+		tenantDB(c, h.db).Model(&team).UpdateColumn("member_count", h.db.Raw("member_count - 1"))
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -231,14 +229,14 @@ func (h *TeamHandler) GetMyTeam(c *gin.Context) {
 
 	// Find the user's team membership
 	var member models.TeamMember
-	if err := h.db.Where("user_id = ?", userID).First(&member).Error; err != nil {
+	if err := tenantDB(c, h.db).Where("user_id = ?", userID).First(&member).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "You are not in any team"})
 		return
 	}
 
 	// Load the team with all details
 	var team models.Team
-	if err := h.db.Preload("Owner").Preload("Members.User").First(&team, "id = ?", member.TeamID).Error; err != nil {
+	if err := tenantDB(c, h.db).Preload("Owner").Preload("Members.User").First(&team, "id = ?", member.TeamID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
 		return
 	}
@@ -262,7 +260,7 @@ func (h *TeamHandler) GetMyTeam(c *gin.Context) {
 	// Get pending members if owner
 	var pendingMembers []models.TeamMember
 	if isOwner {
-		h.db.Preload("User").Where("team_id = ? AND status = ?", team.ID, "pending").Find(&pendingMembers)
+		tenantDB(c, h.db).Preload("User").Where("team_id = ? AND status = ?", team.ID, "pending").Find(&pendingMembers)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -281,14 +279,14 @@ func (h *TeamHandler) JoinTeamByInviteCode(c *gin.Context) {
 
 	// Check if user is already in a team
 	var existingMember models.TeamMember
-	if err := h.db.Where("user_id = ?", userID).First(&existingMember).Error; err == nil {
+	if err := tenantDB(c, h.db).Where("user_id = ?", userID).First(&existingMember).Error; err == nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "You are already in a team"})
 		return
 	}
 
 	// Find team by invite code
 	var team models.Team
-	if err := h.db.Where("invite_code = ?", code).First(&team).Error; err != nil {
+	if err := tenantDB(c, h.db).Where("invite_code = ?", code).First(&team).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Invalid invite code"})
 		return
 	}
@@ -309,7 +307,7 @@ func (h *TeamHandler) JoinTeamByInviteCode(c *gin.Context) {
 		Points: 0,
 	}
 
-	if err := h.db.Create(&member).Error; err != nil {
+	if err := tenantDB(c, h.db).Create(&member).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send join request"})
 		return
 	}
@@ -329,7 +327,7 @@ func (h *TeamHandler) ApproveMember(c *gin.Context) {
 
 	// Verify owner
 	var team models.Team
-	if err := h.db.First(&team, "id = ?", teamID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&team, "id = ?", teamID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
 		return
 	}
@@ -341,14 +339,14 @@ func (h *TeamHandler) ApproveMember(c *gin.Context) {
 
 	// Find and update member
 	var member models.TeamMember
-	if err := h.db.Where("id = ? AND team_id = ? AND status = ?", memberID, teamID, "pending").First(&member).Error; err != nil {
+	if err := tenantDB(c, h.db).Where("id = ? AND team_id = ? AND status = ?", memberID, teamID, "pending").First(&member).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Pending member not found"})
 		return
 	}
 
 	member.Status = "active"
-	h.db.Save(&member)
-	h.db.Model(&team).UpdateColumn("member_count", gorm.Expr("member_count + 1"))
+	tenantDB(c, h.db).Save(&member)
+	tenantDB(c, h.db).Model(&team).UpdateColumn("member_count", gorm.Expr("member_count + 1"))
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Member approved successfully",
@@ -363,7 +361,7 @@ func (h *TeamHandler) RejectMember(c *gin.Context) {
 
 	// Verify owner
 	var team models.Team
-	if err := h.db.First(&team, "id = ?", teamID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&team, "id = ?", teamID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
 		return
 	}
@@ -374,7 +372,7 @@ func (h *TeamHandler) RejectMember(c *gin.Context) {
 	}
 
 	// Delete pending member
-	result := h.db.Where("id = ? AND team_id = ? AND status = ?", memberID, teamID, "pending").Delete(&models.TeamMember{})
+	result := tenantDB(c, h.db).Where("id = ? AND team_id = ? AND status = ?", memberID, teamID, "pending").Delete(&models.TeamMember{})
 	if result.RowsAffected == 0 {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Pending member not found"})
 		return
@@ -393,7 +391,7 @@ func (h *TeamHandler) RemoveMember(c *gin.Context) {
 
 	// Verify owner
 	var team models.Team
-	if err := h.db.First(&team, "id = ?", teamID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&team, "id = ?", teamID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
 		return
 	}
@@ -405,7 +403,7 @@ func (h *TeamHandler) RemoveMember(c *gin.Context) {
 
 	// Find member
 	var member models.TeamMember
-	if err := h.db.Where("id = ? AND team_id = ?", memberID, teamID).First(&member).Error; err != nil {
+	if err := tenantDB(c, h.db).Where("id = ? AND team_id = ?", memberID, teamID).First(&member).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
 		return
 	}
@@ -416,8 +414,8 @@ func (h *TeamHandler) RemoveMember(c *gin.Context) {
 		return
 	}
 
-	h.db.Delete(&member)
-	h.db.Model(&team).UpdateColumn("member_count", gorm.Expr("member_count - 1"))
+	tenantDB(c, h.db).Delete(&member)
+	tenantDB(c, h.db).Model(&team).UpdateColumn("member_count", gorm.Expr("member_count - 1"))
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Member removed successfully",
@@ -431,7 +429,7 @@ func (h *TeamHandler) GetPendingRequests(c *gin.Context) {
 
 	// Verify owner
 	var team models.Team
-	if err := h.db.First(&team, "id = ?", teamID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&team, "id = ?", teamID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
 		return
 	}
@@ -442,7 +440,7 @@ func (h *TeamHandler) GetPendingRequests(c *gin.Context) {
 	}
 
 	var pendingMembers []models.TeamMember
-	h.db.Preload("User").Where("team_id = ? AND status = ?", teamID, "pending").Find(&pendingMembers)
+	tenantDB(c, h.db).Preload("User").Where("team_id = ? AND status = ?", teamID, "pending").Find(&pendingMembers)
 
 	c.JSON(http.StatusOK, gin.H{
 		"pending_members": pendingMembers,
@@ -456,7 +454,7 @@ func (h *TeamHandler) RegenerateInviteCode(c *gin.Context) {
 
 	// Verify owner
 	var team models.Team
-	if err := h.db.First(&team, "id = ?", teamID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&team, "id = ?", teamID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
 		return
 	}
@@ -469,8 +467,8 @@ func (h *TeamHandler) RegenerateInviteCode(c *gin.Context) {
 	// Generate new invite code
 	newCode := generateInviteCode()
 	team.InviteCode = newCode
-	team.InviteURL = "https://go.afftokapp.com/api/invite/" + newCode
-	h.db.Save(&team)
+	team.InviteURL = tenantBaseURL(c, h.db) + "/api/invite/" + newCode
+	tenantDB(c, h.db).Save(&team)
 
 	c.JSON(http.StatusOK, gin.H{
 		"invite_code": team.InviteCode,
@@ -484,14 +482,14 @@ func (h *TeamHandler) GetExclusiveOffersForOwner(c *gin.Context) {
 
 	// Find team where this user is owner
 	var team models.Team
-	if err := h.db.Where("owner_id = ?", userID).First(&team).Error; err != nil {
+	if err := tenantDB(c, h.db).Where("owner_id = ?", userID).First(&team).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "You are not an owner of any team"})
 		return
 	}
 
 	// Load offers that are exclusive to this team
 	var offers []models.Offer
-	if err := h.db.
+	if err := tenantDB(c, h.db).
 		Where("exclusive_team_id = ?", team.ID).
 		Order("created_at DESC").
 		Find(&offers).Error; err != nil {
@@ -512,7 +510,7 @@ func (h *TeamHandler) ApproveExclusiveOffer(c *gin.Context) {
 
 	// Load offer
 	var offer models.Offer
-	if err := h.db.First(&offer, "id = ?", offerID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&offer, "id = ?", offerID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
 		return
 	}
@@ -524,7 +522,7 @@ func (h *TeamHandler) ApproveExclusiveOffer(c *gin.Context) {
 
 	// Verify that current user is owner of that team
 	var team models.Team
-	if err := h.db.First(&team, "id = ?", offer.ExclusiveTeamID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&team, "id = ?", offer.ExclusiveTeamID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
 		return
 	}
@@ -542,7 +540,7 @@ func (h *TeamHandler) ApproveExclusiveOffer(c *gin.Context) {
 		"team_rejection_reason": "",
 	}
 
-	if err := h.db.Model(&offer).Updates(updates).Error; err != nil {
+	if err := tenantDB(c, h.db).Model(&offer).Updates(updates).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve offer"})
 		return
 	}
@@ -564,7 +562,7 @@ func (h *TeamHandler) RejectExclusiveOffer(c *gin.Context) {
 
 	// Load offer
 	var offer models.Offer
-	if err := h.db.First(&offer, "id = ?", offerID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&offer, "id = ?", offerID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
 		return
 	}
@@ -576,7 +574,7 @@ func (h *TeamHandler) RejectExclusiveOffer(c *gin.Context) {
 
 	// Verify that current user is owner of that team
 	var team models.Team
-	if err := h.db.First(&team, "id = ?", offer.ExclusiveTeamID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&team, "id = ?", offer.ExclusiveTeamID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
 		return
 	}
@@ -594,7 +592,7 @@ func (h *TeamHandler) RejectExclusiveOffer(c *gin.Context) {
 		"team_rejection_reason": body.Reason,
 	}
 
-	if err := h.db.Model(&offer).Updates(updates).Error; err != nil {
+	if err := tenantDB(c, h.db).Model(&offer).Updates(updates).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reject offer"})
 		return
 	}
@@ -611,7 +609,7 @@ func (h *TeamHandler) DeleteTeam(c *gin.Context) {
 
 	// Verify owner
 	var team models.Team
-	if err := h.db.First(&team, "id = ?", teamID).Error; err != nil {
+	if err := tenantDB(c, h.db).First(&team, "id = ?", teamID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
 		return
 	}
@@ -622,10 +620,10 @@ func (h *TeamHandler) DeleteTeam(c *gin.Context) {
 	}
 
 	// Delete all members first
-	h.db.Where("team_id = ?", teamID).Delete(&models.TeamMember{})
+	tenantDB(c, h.db).Where("team_id = ?", teamID).Delete(&models.TeamMember{})
 
 	// Delete team
-	h.db.Delete(&team)
+	tenantDB(c, h.db).Delete(&team)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Team deleted successfully",
@@ -643,169 +641,49 @@ func (h *TeamHandler) GetTeamLandingPage(c *gin.Context) {
 
 	// Find team by invite code
 	var team models.Team
-	if err := h.db.Preload("Owner").Preload("Members.User").Where("invite_code = ?", code).First(&team).Error; err != nil {
-		h.serveTeamErrorPage(c)
+	if err := h.db.Preload("Owner").Preload("Members.User").Where("invite_code = ?", code).First(&team).Error; err != nil || !brandedDomainAllows(c, team.TenantID) {
+		servePageError(c, h.db, uuid.Nil, http.StatusNotFound, services.PageErrorInviteInvalid)
 		return
 	}
 
+	serveTeamInvitePage(c, h.db, team, code)
+}
+
+// serveTeamInvitePage renders a team's invite landing page in its tenant's branding
+func serveTeamInvitePage(c *gin.Context, db *gorm.DB, team models.Team, code string) {
 	// Calculate team stats and build members list
-	var totalClicks, totalConversions int
-	activeMembers := 0
-	members := []map[string]interface{}{}
+	data := &services.TeamPageData{
+		Name:        team.Name,
+		Description: team.Description,
+		LogoURL:     team.LogoURL,
+		InviteCode:  code,
+		Members:     []services.TeamPageMember{},
+	}
 
 	for _, member := range team.Members {
 		if member.Status == "active" {
-			totalClicks += member.User.TotalClicks
-			totalConversions += member.User.TotalConversions
-			activeMembers++
+			data.Clicks += member.User.TotalClicks
+			data.Conversions += member.User.TotalConversions
+			data.MembersCount++
 
 			name := member.User.FullName
 			if name == "" {
 				name = member.User.Username
 			}
 
-			members = append(members, map[string]interface{}{
-				"name":     name,
-				"username": member.User.Username,
-				"isOwner":  member.Role == "owner",
+			data.Members = append(data.Members, services.TeamPageMember{
+				Name:     name,
+				Username: member.User.Username,
+				IsOwner:  member.Role == "owner",
 			})
 		}
 	}
 
-	// Build team data for JavaScript
-	teamData := map[string]interface{}{
-		"name":         team.Name,
-		"description":  team.Description,
-		"logoUrl":      team.LogoURL,
-		"membersCount": activeMembers,
-		"conversions":  totalConversions,
-		"clicks":       totalClicks,
-		"members":      members,
-	}
-
-	teamDataJSON, _ := json.Marshal(teamData)
-
-	// Read the template file
-	html := h.readTeamTemplate()
-	if html == "" {
-		h.serveTeamErrorPage(c)
+	page, err := services.GetPageTemplateService(db).RenderTeamInvitePage(team.TenantID, pageLocale(c), data)
+	if err != nil {
+		log.Printf("[Team] render invite page for %s failed: %v", team.ID, err)
+		servePageError(c, db, team.TenantID, http.StatusInternalServerError, "")
 		return
 	}
-
-	// Inject data script before </head>
-	dataScript := fmt.Sprintf(`<script>
-		window.teamData = %s;
-		window.inviteCode = "%s";
-	</script>`, string(teamDataJSON), code)
-
-	html = strings.Replace(html, "</head>", dataScript+"</head>", 1)
-
-	// Update page title
-	html = strings.Replace(html, "<title>انضم للفريق - AffTok</title>",
-		fmt.Sprintf("<title>انضم لفريق %s - AffTok</title>", team.Name), 1)
-
-	// Add Open Graph meta tags
-	ogTags := fmt.Sprintf(`
-	<meta property="og:title" content="انضم لفريق %s على AffTok">
-	<meta property="og:description" content="%s">
-	<meta property="og:image" content="%s">
-	<meta property="og:type" content="website">
-	`, team.Name, team.Description, team.LogoURL)
-
-	html = strings.Replace(html, "<meta charset=\"UTF-8\">",
-		"<meta charset=\"UTF-8\">"+ogTags, 1)
-
-	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(html))
-}
-
-func (h *TeamHandler) readTeamTemplate() string {
-	// Try multiple paths
-	paths := []string{
-		"public/team_invite_landing.html",
-		"./public/team_invite_landing.html",
-		"../public/team_invite_landing.html",
-	}
-
-	for _, path := range paths {
-		data, err := ioutil.ReadFile(path)
-		if err == nil {
-			return string(data)
-		}
-	}
-
-	return ""
-}
-
-func (h *TeamHandler) serveTeamErrorPage(c *gin.Context) {
-	errorHTML := `
-<!DOCTYPE html>
-<html lang="ar" dir="rtl">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>رابط غير صالح - AffTok</title>
-    <link rel="stylesheet" href="https://cdnjs.cloudflare.com/ajax/libs/font-awesome/6.4.0/css/all.min.css">
-    <style>
-        * { margin: 0; padding: 0; box-sizing: border-box; }
-        body { 
-            font-family: 'Segoe UI', Roboto, sans-serif;
-            background: linear-gradient(135deg, #0a0a0a 0%, #1a1a1a 100%);
-            min-height: 100vh;
-            display: flex;
-            align-items: center;
-            justify-content: center;
-            color: white;
-        }
-        .container { 
-            text-align: center; 
-            padding: 60px 40px;
-            background: rgba(255,255,255,0.03);
-            border: 1px solid #333;
-            border-radius: 24px;
-            max-width: 400px;
-        }
-        .icon {
-            font-size: 80px;
-            margin-bottom: 30px;
-            background: linear-gradient(135deg, #FF006E, #FF4D00);
-            -webkit-background-clip: text;
-            -webkit-text-fill-color: transparent;
-        }
-        h1 { 
-            font-size: 28px; 
-            margin-bottom: 16px;
-            font-weight: 700;
-        }
-        p { 
-            font-size: 16px; 
-            color: #888;
-            margin-bottom: 30px;
-            line-height: 1.6;
-        }
-        .btn {
-            background: linear-gradient(135deg, #FF006E, #FF4D00);
-            color: white;
-            padding: 14px 32px;
-            border-radius: 50px;
-            text-decoration: none;
-            font-weight: 600;
-            display: inline-block;
-            transition: all 0.3s ease;
-        }
-        .btn:hover {
-            transform: translateY(-3px);
-            box-shadow: 0 10px 30px rgba(255,0,110,0.4);
-        }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="icon"><i class="fas fa-link-slash"></i></div>
-        <h1>رابط الدعوة غير صالح</h1>
-        <p>هذا الرابط غير موجود أو منتهي الصلاحية. تأكد من صحة الرابط أو تواصل مع صاحب الدعوة.</p>
-        <a href="https://afftokapp.com" class="btn">زيارة الموقع</a>
-    </div>
-</body>
-</html>`
-	c.Data(http.StatusNotFound, "text/html; charset=utf-8", []byte(errorHTML))
+	writePage(c, page)
 }
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"os"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/database"
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// tenantDB returns a database handle scoped to the request's tenant.
+// Every read and write on tenant tables made through it is limited to the
+// tenant resolved by TenantResolverMiddleware / TenantMembershipMiddleware.
+// When RLS is enabled it runs on the request's RLS-scoped transaction.
+func tenantDB(c *gin.Context, db *gorm.DB) *database.TenantDB {
+	if tx, ok := middleware.GetTenantTx(c); ok {
+		db = tx
+	}
+	return database.TenantFromContext(db, middleware.GetTenantID(c))
+}
+
+// tenantBaseURL returns the base URL for tracking, landing and invite links:
+// the tenant's verified custom domain when it has one, else BASE_URL
+func tenantBaseURL(c *gin.Context, db *gorm.DB) string {
+	if baseURL, ok := services.GetDomainVerificationService(db).TenantBaseURL(middleware.GetTenantID(c)); ok {
+		return baseURL
+	}
+	if baseURL := os.Getenv("BASE_URL"); baseURL != "" {
+		return baseURL
+	}
+	return "https://go.afftokapp.com"
+}
+
+// tenantLocation returns the timezone of the request's tenant, used for
+// "today" and monthly report and invoice boundaries
+func tenantLocation(c *gin.Context, db *gorm.DB) *time.Location {
+	return services.GetTenantSettingsResolver(db).Location(middleware.GetTenantID(c))
+}
+
+// brandedDomainAllows reports whether a record of tenantID may be served on
+// the request's host. A tenant's custom domain only serves that tenant's
+// links and landing pages; platform hosts serve every tenant.
+func brandedDomainAllows(c *gin.Context, tenantID uuid.UUID) bool {
+	branded, ok := middleware.GetBrandedTenantID(c)
+	return !ok || branded == tenantID
+}
@@ -0,0 +1,381 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// TENANT BILLING HANDLER
+// ============================================
+
+// TenantBillingHandler serves tenant subscriptions and platform invoices
+type TenantBillingHandler struct {
+	billingService *services.BillingService
+}
+
+// NewTenantBillingHandler creates a new tenant billing handler
+func NewTenantBillingHandler(db *gorm.DB) *TenantBillingHandler {
+	return &TenantBillingHandler{
+		billingService: services.GetBillingService(db),
+	}
+}
+
+// billingErrorStatus maps billing errors to HTTP status codes
+func billingErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrBillingInvalidPlan),
+		errors.Is(err, services.ErrBillingInvalidCycle),
+		errors.Is(err, services.ErrBillingNoChange):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrBillingInvoiceNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrBillingInvoiceNotOpen):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (h *TenantBillingHandler) fail(c *gin.Context, correlationID string, err error) {
+	c.JSON(billingErrorStatus(err), gin.H{
+		"success":        false,
+		"correlation_id": correlationID,
+		"error":          err.Error(),
+	})
+}
+
+// respondOverview writes the billing overview of a tenant
+func (h *TenantBillingHandler) respondOverview(c *gin.Context, correlationID string, tenantID uuid.UUID) {
+	overview, err := h.billingService.GetOverview(tenantID)
+	if err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+
+	plans := make([]models.PlanBilling, 0, 3)
+	for _, plan := range []models.TenantPlan{models.TenantPlanFree, models.TenantPlanPro, models.TenantPlanEnterprise} {
+		plans = append(plans, models.GetPlanBilling(plan))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data": gin.H{
+			"overview": overview,
+			"plans":    plans,
+		},
+	})
+}
+
+// respondInvoices writes a page of a tenant's invoices
+func (h *TenantBillingHandler) respondInvoices(c *gin.Context, correlationID string, tenantID uuid.UUID) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+
+	invoices, total, err := h.billingService.ListInvoices(tenantID, c.Query("status"), limit, (page-1)*limit)
+	if err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           invoices,
+		"pagination": gin.H{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// ============================================
+// TENANT ADMIN ENDPOINTS
+// ============================================
+
+// GetBilling returns the current tenant's subscription and price list
+// GET /api/billing
+func (h *TenantBillingHandler) GetBilling(c *gin.Context) {
+	h.respondOverview(c, generateCorrelationID(), middleware.GetTenantID(c))
+}
+
+// GetInvoices lists the current tenant's invoices
+// GET /api/billing/invoices?status=open&page=1&limit=20
+func (h *TenantBillingHandler) GetInvoices(c *gin.Context) {
+	h.respondInvoices(c, generateCorrelationID(), middleware.GetTenantID(c))
+}
+
+// GetInvoice returns one of the current tenant's invoices with its lines
+// GET /api/billing/invoices/:id
+func (h *TenantBillingHandler) GetInvoice(c *gin.Context) {
+	correlationID := generateCorrelationID()
+
+	invoiceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid invoice ID",
+		})
+		return
+	}
+
+	invoice, err := h.billingService.GetInvoice(middleware.GetTenantID(c), invoiceID)
+	if err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           invoice,
+	})
+}
+
+// ChangeSubscription moves the current tenant to a plan/cycle with proration
+// POST /api/billing/subscription {"plan": "pro", "cycle": "annual"}
+func (h *TenantBillingHandler) ChangeSubscription(c *gin.Context) {
+	correlationID := generateCorrelationID()
+
+	var req struct {
+		Plan  models.TenantPlan   `json:"plan" binding:"required"`
+		Cycle models.BillingCycle `json:"cycle"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	sub, invoice, err := h.billingService.ChangePlan(middleware.GetTenantID(c), req.Plan, req.Cycle)
+	if err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data": gin.H{
+			"subscription": sub,
+			"invoice":      invoice,
+		},
+	})
+}
+
+// CancelSubscription schedules (or undoes) a downgrade to free at period end
+// POST /api/billing/subscription/cancel {"cancel": true}
+func (h *TenantBillingHandler) CancelSubscription(c *gin.Context) {
+	correlationID := generateCorrelationID()
+
+	req := struct {
+		Cancel *bool `json:"cancel"`
+	}{}
+	c.ShouldBindJSON(&req)
+	cancel := req.Cancel == nil || *req.Cancel
+
+	sub, err := h.billingService.SetCancelAtPeriodEnd(middleware.GetTenantID(c), cancel)
+	if err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           sub,
+	})
+}
+
+// PayInvoice retries collection of an open invoice now (after updating the card)
+// POST /api/billing/invoices/:id/pay
+func (h *TenantBillingHandler) PayInvoice(c *gin.Context) {
+	correlationID := generateCorrelationID()
+
+	invoiceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid invoice ID",
+		})
+		return
+	}
+
+	tenantID := middleware.GetTenantID(c)
+	if _, err := h.billingService.GetInvoice(tenantID, invoiceID); err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+	if err := h.billingService.ChargeInvoice(invoiceID); err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+
+	invoice, _ := h.billingService.GetInvoice(tenantID, invoiceID)
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           invoice,
+	})
+}
+
+// ============================================
+// PROVIDER WEBHOOK
+// ============================================
+
+// Webhook receives signed payment provider events
+// POST /api/billing/webhook
+func (h *TenantBillingHandler) Webhook(c *gin.Context) {
+	correlationID := generateCorrelationID()
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Failed to read body",
+		})
+		return
+	}
+
+	event, duplicate, err := h.billingService.HandleWebhook(c.Request.Header, body)
+	switch {
+	case errors.Is(err, services.ErrPaymentInvalidSignature):
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid signature",
+		})
+		return
+	case errors.Is(err, services.ErrPaymentProviderDisabled):
+		c.JSON(http.StatusNotFound, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Billing provider not configured",
+		})
+		return
+	case errors.Is(err, services.ErrPaymentEventIgnored), errors.Is(err, services.ErrBillingInvoiceNotFound):
+		// Acknowledge so the provider stops retrying
+		c.JSON(http.StatusOK, gin.H{
+			"success":        true,
+			"correlation_id": correlationID,
+			"ignored":        true,
+		})
+		return
+	case err != nil:
+		log.Printf("[Billing] webhook processing failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Failed to process event",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"event_id":       event.ID,
+		"duplicate":      duplicate,
+	})
+}
+
+// ============================================
+// SUPER ADMIN ENDPOINTS
+// ============================================
+
+// GetTenantBilling returns any tenant's billing overview
+// GET /api/admin/tenants/:id/billing
+func (h *TenantBillingHandler) GetTenantBilling(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	tenantID, ok := h.tenantParam(c, correlationID)
+	if !ok {
+		return
+	}
+	h.respondOverview(c, correlationID, tenantID)
+}
+
+// GetTenantInvoices lists any tenant's invoices
+// GET /api/admin/tenants/:id/billing/invoices
+func (h *TenantBillingHandler) GetTenantInvoices(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	tenantID, ok := h.tenantParam(c, correlationID)
+	if !ok {
+		return
+	}
+	h.respondInvoices(c, correlationID, tenantID)
+}
+
+// MarkTenantInvoicePaid settles an invoice paid outside the provider
+// POST /api/admin/tenants/:id/billing/invoices/:invoiceId/mark-paid {"reference": "wire-123"}
+func (h *TenantBillingHandler) MarkTenantInvoicePaid(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	tenantID, ok := h.tenantParam(c, correlationID)
+	if !ok {
+		return
+	}
+
+	invoiceID, err := uuid.Parse(c.Param("invoiceId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid invoice ID",
+		})
+		return
+	}
+	if _, err := h.billingService.GetInvoice(tenantID, invoiceID); err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+
+	var req struct {
+		Reference string `json:"reference"`
+	}
+	c.ShouldBindJSON(&req)
+
+	invoice, err := h.billingService.MarkInvoicePaid(invoiceID, req.Reference)
+	if err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           invoice,
+	})
+}
+
+func (h *TenantBillingHandler) tenantParam(c *gin.Context, correlationID string) (uuid.UUID, bool) {
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid tenant ID",
+		})
+		return uuid.Nil, false
+	}
+	return tenantID, true
+}
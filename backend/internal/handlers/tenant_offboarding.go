@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// TENANT OFFBOARDING HANDLER
+// ============================================
+
+// TenantOffboardingHandler serves tenant data exports and hard deletes
+type TenantOffboardingHandler struct {
+	offboardingService *services.TenantOffboardingService
+}
+
+// NewTenantOffboardingHandler creates a new tenant offboarding handler
+func NewTenantOffboardingHandler(db *gorm.DB) *TenantOffboardingHandler {
+	return &TenantOffboardingHandler{
+		offboardingService: services.GetTenantOffboardingService(db),
+	}
+}
+
+// offboardingServiceErrorStatus maps offboarding errors to HTTP status codes
+func offboardingServiceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrExportInvalidFormat),
+		errors.Is(err, services.ErrDeletionCoolingOffTooLow):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrOffboardingDefaultTenant):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrExportNotFound),
+		errors.Is(err, services.ErrDeletionNotFound),
+		errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrExportNotReady),
+		errors.Is(err, services.ErrDeletionAlreadyScheduled):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (h *TenantOffboardingHandler) fail(c *gin.Context, correlationID string, err error) {
+	c.JSON(offboardingServiceErrorStatus(err), gin.H{
+		"success":        false,
+		"correlation_id": correlationID,
+		"error":          err.Error(),
+	})
+}
+
+func (h *TenantOffboardingHandler) ok(c *gin.Context, status int, correlationID string, data interface{}) {
+	c.JSON(status, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           data,
+	})
+}
+
+// requestActor returns the authenticated user, if any
+func requestActor(c *gin.Context) *uuid.UUID {
+	if value, exists := c.Get("userID"); exists {
+		if id, ok := value.(uuid.UUID); ok {
+			return &id
+		}
+	}
+	return nil
+}
+
+// uuidParam parses a path parameter, writing 400 on failure
+func (h *TenantOffboardingHandler) uuidParam(c *gin.Context, correlationID, name, label string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param(name))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid " + label + " ID",
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// ============================================
+// EXPORTS
+// ============================================
+
+func (h *TenantOffboardingHandler) requestExport(c *gin.Context, correlationID string, tenantID uuid.UUID) {
+	var req struct {
+		Format models.TenantExportFormat `json:"format"`
+	}
+	c.ShouldBindJSON(&req)
+
+	job, err := h.offboardingService.RequestExport(tenantID, req.Format, requestActor(c))
+	if err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+	h.ok(c, http.StatusAccepted, correlationID, job)
+}
+
+func (h *TenantOffboardingHandler) listExports(c *gin.Context, correlationID string, tenantID uuid.UUID) {
+	jobs, err := h.offboardingService.ListExports(tenantID)
+	if err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+	h.ok(c, http.StatusOK, correlationID, jobs)
+}
+
+func (h *TenantOffboardingHandler) downloadExport(c *gin.Context, correlationID string, tenantID, exportID uuid.UUID) {
+	job, path, err := h.offboardingService.ExportFile(tenantID, exportID)
+	if err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+
+	c.Header("X-Export-SHA256", job.SHA256)
+	c.FileAttachment(path, fmt.Sprintf("tenant-%s-export-%s.zip",
+		tenantID.String()[:8], job.CreatedAt.UTC().Format("20060102")))
+}
+
+// RequestExport queues an export of the current tenant's data
+// POST /api/exports {"format": "ndjson" | "csv"}
+func (h *TenantOffboardingHandler) RequestExport(c *gin.Context) {
+	h.requestExport(c, generateCorrelationID(), middleware.GetTenantID(c))
+}
+
+// GetExports lists the current tenant's exports
+// GET /api/exports
+func (h *TenantOffboardingHandler) GetExports(c *gin.Context) {
+	h.listExports(c, generateCorrelationID(), middleware.GetTenantID(c))
+}
+
+// GetExport returns one of the current tenant's exports
+// GET /api/exports/:id
+func (h *TenantOffboardingHandler) GetExport(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	exportID, ok := h.uuidParam(c, correlationID, "id", "export")
+	if !ok {
+		return
+	}
+
+	job, err := h.offboardingService.GetExport(middleware.GetTenantID(c), exportID)
+	if err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+	h.ok(c, http.StatusOK, correlationID, job)
+}
+
+// DownloadExport streams a completed export archive
+// GET /api/exports/:id/download
+func (h *TenantOffboardingHandler) DownloadExport(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	exportID, ok := h.uuidParam(c, correlationID, "id", "export")
+	if !ok {
+		return
+	}
+	h.downloadExport(c, correlationID, middleware.GetTenantID(c), exportID)
+}
+
+// RequestTenantExport queues an export of any tenant's data
+// POST /api/admin/tenants/:id/exports {"format": "ndjson" | "csv"}
+func (h *TenantOffboardingHandler) RequestTenantExport(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	tenantID, ok := h.uuidParam(c, correlationID, "id", "tenant")
+	if !ok {
+		return
+	}
+	h.requestExport(c, correlationID, tenantID)
+}
+
+// GetTenantExports lists any tenant's exports
+// GET /api/admin/tenants/:id/exports
+func (h *TenantOffboardingHandler) GetTenantExports(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	tenantID, ok := h.uuidParam(c, correlationID, "id", "tenant")
+	if !ok {
+		return
+	}
+	h.listExports(c, correlationID, tenantID)
+}
+
+// DownloadTenantExport streams any tenant's export archive
+// GET /api/admin/tenants/:id/exports/:exportId/download
+func (h *TenantOffboardingHandler) DownloadTenantExport(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	tenantID, ok := h.uuidParam(c, correlationID, "id", "tenant")
+	if !ok {
+		return
+	}
+	exportID, ok := h.uuidParam(c, correlationID, "exportId", "export")
+	if !ok {
+		return
+	}
+	h.downloadExport(c, correlationID, tenantID, exportID)
+}
+
+// ============================================
+// HARD DELETE
+// ============================================
+
+// ScheduleTenantDeletion disables a tenant and schedules its hard delete
+// POST /api/admin/tenants/:id/deletion {"reason": "...", "cooling_off_days": 30, "export": true}
+func (h *TenantOffboardingHandler) ScheduleTenantDeletion(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	tenantID, ok := h.uuidParam(c, correlationID, "id", "tenant")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Reason         string `json:"reason"`
+		CoolingOffDays int    `json:"cooling_off_days"`
+		Export         bool   `json:"export"` // final export the customer can download meanwhile
+	}
+	c.ShouldBindJSON(&req)
+
+	actor := requestActor(c)
+	var export *models.TenantExportJob
+	if req.Export && tenantID != models.DefaultTenantID {
+		var err error
+		if export, err = h.offboardingService.RequestExport(tenantID, models.TenantExportNDJSON, actor); err != nil {
+			h.fail(c, correlationID, err)
+			return
+		}
+	}
+
+	coolingOff := time.Duration(req.CoolingOffDays) * 24 * time.Hour
+	job, err := h.offboardingService.ScheduleDeletion(tenantID, req.Reason, coolingOff, actor)
+	if err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+
+	h.ok(c, http.StatusAccepted, correlationID, gin.H{
+		"deletion": job,
+		"export":   export,
+	})
+}
+
+// GetTenantDeletion returns the latest deletion job of a tenant
+// GET /api/admin/tenants/:id/deletion
+func (h *TenantOffboardingHandler) GetTenantDeletion(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	tenantID, ok := h.uuidParam(c, correlationID, "id", "tenant")
+	if !ok {
+		return
+	}
+
+	job, err := h.offboardingService.GetDeletion(tenantID)
+	if err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+	h.ok(c, http.StatusOK, correlationID, job)
+}
+
+// CancelTenantDeletion cancels a scheduled deletion during the cooling-off period
+// DELETE /api/admin/tenants/:id/deletion
+func (h *TenantOffboardingHandler) CancelTenantDeletion(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	tenantID, ok := h.uuidParam(c, correlationID, "id", "tenant")
+	if !ok {
+		return
+	}
+
+	job, err := h.offboardingService.CancelDeletion(tenantID, requestActor(c))
+	if err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+	h.ok(c, http.StatusOK, correlationID, job)
+}
+
+// RetryTenantDeletion re-runs a failed hard delete
+// POST /api/admin/tenants/:id/deletion/retry
+func (h *TenantOffboardingHandler) RetryTenantDeletion(c *gin.Context) {
+	correlationID := generateCorrelationID()
+	tenantID, ok := h.uuidParam(c, correlationID, "id", "tenant")
+	if !ok {
+		return
+	}
+
+	job, err := h.offboardingService.RetryDeletion(tenantID)
+	if err != nil {
+		h.fail(c, correlationID, err)
+		return
+	}
+	h.ok(c, http.StatusAccepted, correlationID, job)
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/aljapah/afftok-backend-prod/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// TENANT SIGNUP & ONBOARDING HANDLER
+// ============================================
+
+// TenantOnboardingHandler handles self-service tenant signup and the onboarding wizard
+type TenantOnboardingHandler struct {
+	onboardingService *services.TenantOnboardingService
+}
+
+// NewTenantOnboardingHandler creates a new tenant onboarding handler
+func NewTenantOnboardingHandler(db *gorm.DB) *TenantOnboardingHandler {
+	return &TenantOnboardingHandler{
+		onboardingService: services.NewTenantOnboardingService(db),
+	}
+}
+
+// onboardingErrorStatus maps signup/onboarding errors to an HTTP status
+func onboardingErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrSignupUsernameTaken), errors.Is(err, services.ErrSignupEmailTaken):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrOnboardingNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrOnboardingStepBlocked):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// ============================================
+// PUBLIC SIGNUP
+// ============================================
+
+// Signup creates a new tenant with its admin user
+// POST /api/signup
+func (h *TenantOnboardingHandler) Signup(c *gin.Context) {
+	correlationID := generateCorrelationID()
+
+	var req services.TenantSignupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
+	result, err := h.onboardingService.Signup(&req)
+	if err != nil {
+		c.JSON(onboardingErrorStatus(err), gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
+	admin := result.Admin
+	accessToken, err := utils.GenerateTenantToken(admin.ID, result.Tenant.ID, admin.Username, admin.Email, admin.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Failed to generate token",
+		})
+		return
+	}
+	refreshToken, err := utils.GenerateRefreshToken(admin.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Failed to generate refresh token",
+		})
+		return
+	}
+
+	progress, _ := h.onboardingService.GetProgress(result.Tenant.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"message":        "Tenant created. Check your email to verify your address.",
+		"data": gin.H{
+			"tenant":        result.Tenant,
+			"user":          admin,
+			"access_token":  accessToken,
+			"refresh_token": refreshToken,
+			"onboarding":    progress,
+		},
+	})
+}
+
+// VerifyEmail confirms the admin email with the token sent at signup
+// POST /api/signup/verify-email
+func (h *TenantOnboardingHandler) VerifyEmail(c *gin.Context) {
+	correlationID := generateCorrelationID()
+
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
+	progress, err := h.onboardingService.VerifyEmail(req.Token)
+	if err != nil {
+		c.JSON(onboardingErrorStatus(err), gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"message":        "Email verified",
+		"data":           progress,
+	})
+}
+
+// ============================================
+// ONBOARDING WIZARD (tenant admin)
+// ============================================
+
+// GetOnboarding returns the current tenant's onboarding progress
+// GET /api/onboarding
+func (h *TenantOnboardingHandler) GetOnboarding(c *gin.Context) {
+	correlationID := generateCorrelationID()
+
+	progress, err := h.onboardingService.GetProgress(middleware.GetTenantID(c))
+	if err != nil {
+		c.JSON(onboardingErrorStatus(err), gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           progress,
+	})
+}
+
+// CompleteStep completes one wizard step
+// POST /api/onboarding/steps/:step
+func (h *TenantOnboardingHandler) CompleteStep(c *gin.Context) {
+	correlationID := generateCorrelationID()
+
+	var input services.OnboardingStepInput
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success":        false,
+				"correlation_id": correlationID,
+				"error":          err.Error(),
+			})
+			return
+		}
+	}
+
+	progress, err := h.onboardingService.CompleteStep(middleware.GetTenantID(c), c.Param("step"), &input)
+	if err != nil {
+		c.JSON(onboardingErrorStatus(err), gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           progress,
+	})
+}
+
+// ResendVerification sends a fresh email verification link
+// POST /api/onboarding/resend-verification
+func (h *TenantOnboardingHandler) ResendVerification(c *gin.Context) {
+	correlationID := generateCorrelationID()
+
+	if err := h.onboardingService.ResendVerification(middleware.GetTenantID(c)); err != nil {
+		c.JSON(onboardingErrorStatus(err), gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"message":        "Verification email sent",
+	})
+}
+
+// ============================================
+// ADMIN
+// ============================================
+
+// GetTenantOnboarding returns any tenant's onboarding progress
+// GET /api/admin/tenants/:id/onboarding
+func (h *TenantOnboardingHandler) GetTenantOnboarding(c *gin.Context) {
+	correlationID := generateCorrelationID()
+
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid tenant ID",
+		})
+		return
+	}
+
+	progress, err := h.onboardingService.GetProgress(tenantID)
+	if err != nil {
+		c.JSON(onboardingErrorStatus(err), gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data":           progress,
+	})
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/database"
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// TENANT USAGE HANDLER
+// ============================================
+
+// TenantUsageHandler serves metered usage and plan limits
+type TenantUsageHandler struct {
+	usageService *services.UsageService
+}
+
+// NewTenantUsageHandler creates a new tenant usage handler
+func NewTenantUsageHandler(db *gorm.DB) *TenantUsageHandler {
+	return &TenantUsageHandler{
+		usageService: services.GetUsageService(db),
+	}
+}
+
+// planLimitResponse builds the 403 body for a creation blocked by a plan
+// limit. ok is false when err isn't a limit error.
+func planLimitResponse(err error) (body gin.H, ok bool) {
+	var limitErr *services.UsageLimitError
+	if !errors.As(err, &limitErr) {
+		return nil, false
+	}
+	return gin.H{
+		"success": false,
+		"error":   limitErr.Error(),
+		"code":    "PLAN_LIMIT_EXCEEDED",
+		"limit":   limitErr.Check,
+	}, true
+}
+
+// createWithinPlanLimit runs create in a tenant transaction that first
+// checks the current tenant's hard limit for one more of a resource. The
+// tenant row stays locked until commit, so two requests cannot both take the
+// last free slot. It responds 403 and returns false when the limit is
+// reached; any other error is returned for the caller to report.
+func createWithinPlanLimit(c *gin.Context, db *gorm.DB, resource models.LimitResource, create func(tx *database.TenantDB) error) (bool, error) {
+	err := tenantDB(c, db).Transaction(func(tx *database.TenantDB) error {
+		if err := services.GetUsageService(db).EnforceTx(tx.DB, middleware.GetTenantID(c), resource); err != nil {
+			return err
+		}
+		return create(tx)
+	})
+	if body, ok := planLimitResponse(err); ok {
+		c.JSON(http.StatusForbidden, body)
+		return false, nil
+	}
+	return true, err
+}
+
+// usageRange parses ?from=YYYY-MM-DD&to=YYYY-MM-DD, falling back to ?days (default 30)
+func usageRange(c *gin.Context) (time.Time, time.Time, error) {
+	to := time.Now().UTC()
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid to date, expected YYYY-MM-DD")
+		}
+		to = t
+	}
+
+	if v := c.Query("from"); v != "" {
+		from, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid from date, expected YYYY-MM-DD")
+		}
+		if from.After(to) {
+			return time.Time{}, time.Time{}, errors.New("from must not be after to")
+		}
+		return from, to, nil
+	}
+
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if days < 1 || days > 366 {
+		days = 30
+	}
+	return to.AddDate(0, 0, -(days - 1)), to, nil
+}
+
+// respondUsage writes limits and history for a tenant
+func (h *TenantUsageHandler) respondUsage(c *gin.Context, correlationID string, tenantID uuid.UUID) {
+	from, to, err := usageRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          err.Error(),
+		})
+		return
+	}
+
+	limits, err := h.usageService.GetLimits(tenantID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Tenant not found",
+		})
+		return
+	}
+
+	history, err := h.usageService.GetHistory(tenantID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Failed to fetch usage: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"correlation_id": correlationID,
+		"data": gin.H{
+			"limits":  limits,
+			"history": history,
+		},
+	})
+}
+
+// GetMyUsage returns the current tenant's limits and usage history
+// GET /api/usage?days=30 or ?from=2026-01-01&to=2026-01-31
+func (h *TenantUsageHandler) GetMyUsage(c *gin.Context) {
+	h.respondUsage(c, generateCorrelationID(), middleware.GetTenantID(c))
+}
+
+// GetTenantUsage returns any tenant's limits and usage history (billing)
+// GET /api/admin/tenants/:id/usage?from=2026-01-01&to=2026-01-31
+func (h *TenantUsageHandler) GetTenantUsage(c *gin.Context) {
+	correlationID := generateCorrelationID()
+
+	tenantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":        false,
+			"correlation_id": correlationID,
+			"error":          "Invalid tenant ID",
+		})
+		return
+	}
+
+	h.respondUsage(c, correlationID, tenantID)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/aljapah/afftok-backend-prod/internal/models"
 	"github.com/aljapah/afftok-backend-prod/internal/services"
@@ -15,6 +16,7 @@ import (
 type UserHandler struct {
 	db               *gorm.DB
 	analyticsService *services.AnalyticsService
+	beaconService    *services.LandingBeaconService
 }
 
 func NewUserHandler(db *gorm.DB) *UserHandler {
@@ -24,6 +26,11 @@ func NewUserHandler(db *gorm.DB) *UserHandler {
 	}
 }
 
+// SetLandingBeaconService sets the landing beacon service (for dependency injection)
+func (h *UserHandler) SetLandingBeaconService(service *services.LandingBeaconService) {
+	h.beaconService = service
+}
+
 func (h *UserHandler) GetAllUsers(c *gin.Context) {
 	var users []models.AfftokUser
 
@@ -31,7 +38,7 @@ func (h *UserHandler) GetAllUsers(c *gin.Context) {
 	limit := 20
 	offset := (page - 1) * limit
 
-	query := h.db.Select("id, username, email, full_name, avatar_url, role, status, points, level, total_clicks, total_conversions, total_earnings, created_at")
+	query := tenantDB(c, h.db).Preload("Earnings").Select("id, username, email, full_name, avatar_url, role, status, points, level, total_clicks, total_conversions, created_at")
 
 	sortBy := c.DefaultQuery("sort", "created_at")
 	order := c.DefaultQuery("order", "desc")
@@ -43,7 +50,7 @@ func (h *UserHandler) GetAllUsers(c *gin.Context) {
 	}
 
 	var total int64
-	h.db.Model(&models.AfftokUser{}).Count(&total)
+	tenantDB(c, h.db).Model(&models.AfftokUser{}).Count(&total)
 
 	c.JSON(http.StatusOK, gin.H{
 		"users": users,
@@ -59,9 +66,10 @@ func (h *UserHandler) GetUser(c *gin.Context) {
 	userID := c.Param("id")
 
 	var user models.AfftokUser
-	if err := h.db.
+	if err := tenantDB(c, h.db).
 		Preload("UserBadges.Badge").
-		Select("id, username, email, full_name, avatar_url, bio, role, status, points, level, total_clicks, total_conversions, total_earnings, created_at").
+		Preload("Earnings").
+		Select("id, username, email, full_name, avatar_url, bio, role, status, points, level, total_clicks, total_conversions, created_at").
 		First(&user, "id = ?", userID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
@@ -106,13 +114,13 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 		updates["payment_method"] = req.PaymentMethod
 	}
 
-	if err := h.db.Model(&models.AfftokUser{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
+	if err := tenantDB(c, h.db).Model(&models.AfftokUser{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
 		return
 	}
 
 	var user models.AfftokUser
-	h.db.First(&user, "id = ?", userID)
+	tenantDB(c, h.db).First(&user, "id = ?", userID)
 	user.PasswordHash = ""
 
 	c.JSON(http.StatusOK, gin.H{
@@ -143,7 +151,7 @@ func (h *UserHandler) UpdateAudienceCountries(c *gin.Context) {
 	// Convert to JSON for storage
 	countriesJSON, _ := json.Marshal(req.AudienceCountries)
 
-	if err := h.db.Model(&models.AfftokUser{}).Where("id = ?", userID).Update("audience_countries", string(countriesJSON)).Error; err != nil {
+	if err := tenantDB(c, h.db).Model(&models.AfftokUser{}).Where("id = ?", userID).Update("audience_countries", string(countriesJSON)).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update audience countries"})
 		return
 	}
@@ -154,6 +162,78 @@ func (h *UserHandler) UpdateAudienceCountries(c *gin.Context) {
 	})
 }
 
+// UpdateTrafficSources updates the domains the promoter declares as traffic sources.
+// Click referrers outside this list are flagged as referrer mismatches.
+func (h *UserHandler) UpdateTrafficSources(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	type UpdateTrafficSourcesRequest struct {
+		TrafficSources []string `json:"traffic_sources"`
+	}
+
+	var req UpdateTrafficSourcesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sources := services.NormalizeTrafficSources(req.TrafficSources)
+	if len(sources) > 50 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many traffic sources (max 50)"})
+		return
+	}
+
+	sourcesJSON, _ := json.Marshal(sources)
+
+	if err := tenantDB(c, h.db).Model(&models.AfftokUser{}).Where("id = ?", userID).Update("traffic_sources", string(sourcesJSON)).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update traffic sources"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "Traffic sources updated successfully",
+		"traffic_sources": sources,
+	})
+}
+
+// UpdateReportingCurrency sets the currency the user's balances, payouts and
+// invoices are also reported in. An empty currency falls back to the tenant default.
+func (h *UserHandler) UpdateReportingCurrency(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		ReportingCurrency string `json:"reporting_currency"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	currency := strings.ToUpper(strings.TrimSpace(req.ReportingCurrency))
+	if currency != "" && !services.ValidCurrency(currency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency (expected ISO 4217 code)"})
+		return
+	}
+
+	if err := tenantDB(c, h.db).Model(&models.AfftokUser{}).Where("id = ?", userID).Update("reporting_currency", currency).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update reporting currency"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":            "Reporting currency updated successfully",
+		"reporting_currency": currency,
+	})
+}
+
 func (h *UserHandler) UpdateUser(c *gin.Context) {
 	userID := c.Param("id")
 
@@ -184,13 +264,13 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		updates["level"] = req.Level
 	}
 
-	if err := h.db.Model(&models.AfftokUser{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
+	if err := tenantDB(c, h.db).Model(&models.AfftokUser{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
 		return
 	}
 
 	var user models.AfftokUser
-	h.db.First(&user, "id = ?", userID)
+	tenantDB(c, h.db).First(&user, "id = ?", userID)
 	user.PasswordHash = ""
 
 	c.JSON(http.StatusOK, gin.H{
@@ -208,7 +288,7 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Delete(&models.AfftokUser{}, "id = ?", id).Error; err != nil {
+	if err := tenantDB(c, h.db).Delete(&models.AfftokUser{}, "id = ?", id).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
@@ -222,7 +302,7 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 func (h *UserHandler) GetMyStats(c *gin.Context) {
 	userID, _ := c.Get("userID")
 
-	stats, err := h.analyticsService.GetUserStats(userID.(uuid.UUID))
+	stats, err := h.analyticsService.GetUserStats(userID.(uuid.UUID), tenantLocation(c, h.db))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stats"})
 		return
@@ -245,7 +325,7 @@ func (h *UserHandler) GetDailyStats(c *gin.Context) {
 		}
 	}
 
-	stats, err := h.analyticsService.GetDailyStats(userID.(uuid.UUID), days)
+	stats, err := h.analyticsService.GetDailyStats(userID.(uuid.UUID), days, tenantLocation(c, h.db))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch daily stats"})
 		return
@@ -283,8 +363,18 @@ func (h *UserHandler) GetLeaderboard(c *gin.Context) {
 		Rank             int       `json:"rank"`
 	}
 
+	// Promoters with a low verified visit rate are not eligible for the leaderboard
+	var ineligible []uuid.UUID
+	if h.beaconService != nil {
+		ineligible = h.beaconService.IneligiblePromoterIDs()
+	}
+
 	// Query top users ordered by calculated points
-	err := h.db.Model(&models.AfftokUser{}).
+	query := tenantDB(c, h.db).Model(&models.AfftokUser{})
+	if len(ineligible) > 0 {
+		query = query.Where("id NOT IN ?", ineligible)
+	}
+	err := query.
 		Select(`
 			id, 
 			username, 
@@ -321,11 +411,19 @@ func (h *UserHandler) GetLeaderboard(c *gin.Context) {
 		TotalConversions int    `json:"total_conversions"`
 		Points           int    `json:"points"`
 		Country          string `json:"country"`
+		Eligible         bool   `json:"eligible"`
+	}
+	myRank.Eligible = true
+	for _, id := range ineligible {
+		if id == currentUserID {
+			myRank.Eligible = false
+			break
+		}
 	}
 
 	// Get current user's stats
 	var currentUser models.AfftokUser
-	if err := h.db.First(&currentUser, "id = ?", currentUserID).Error; err == nil {
+	if err := tenantDB(c, h.db).First(&currentUser, "id = ?", currentUserID).Error; err == nil {
 		myRank.TotalClicks = currentUser.TotalClicks
 		myRank.TotalConversions = currentUser.TotalConversions
 		myRank.Points = currentUser.TotalClicks*2 + currentUser.TotalConversions*20
@@ -333,7 +431,11 @@ func (h *UserHandler) GetLeaderboard(c *gin.Context) {
 
 		// Calculate rank
 		var usersAbove int64
-		h.db.Model(&models.AfftokUser{}).
+		rankQuery := tenantDB(c, h.db).Model(&models.AfftokUser{})
+		if len(ineligible) > 0 {
+			rankQuery = rankQuery.Where("id NOT IN ?", ineligible)
+		}
+		rankQuery.
 			Where("(total_clicks * 2 + total_conversions * 20) > ?", myRank.Points).
 			Where("role = ? OR role IS NULL OR role = ''", "user").
 			Where("status = ?", "active").
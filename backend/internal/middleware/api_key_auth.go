@@ -161,7 +161,7 @@ func APIKeyAuthMiddleware() gin.HandlerFunc {
 		}
 
 		// Check rate limits
-		if !checkAPIKeyRateLimit(keyInfo.ID.String(), ip, keyInfo.RateLimitPerMinute) {
+		if !checkAPIKeyRateLimit(keyInfo.ID.String(), ip, apiKeyRateLimit(keyInfo.TenantID, keyInfo.RateLimitPerMinute)) {
 			atomic.AddInt64(&apiKeyMetrics.RateLimitBlocks, 1)
 			logAPIKeyFraud(ip, keyInfo.KeyHint, FraudIndicatorAPIKeyRateLimit, "Rate limit exceeded")
 			logAPIKeyUsage(keyInfo.ID, keyInfo.AdvertiserID, ip, endpoint, method, c.GetHeader("User-Agent"), false, http.StatusTooManyRequests, "Rate limit exceeded", time.Since(startTime).Milliseconds())
@@ -183,6 +183,7 @@ func APIKeyAuthMiddleware() gin.HandlerFunc {
 		c.Set(ContextAdvertiserID, keyInfo.AdvertiserID.String())
 		c.Set(ContextAPIKeyName, keyInfo.Name)
 		c.Set(ContextAuthMethod, AuthMethodAPIKey)
+		c.Set("api_key_tenant_id", keyInfo.TenantID)
 
 		// Parse and set permissions
 		var permissions []string
@@ -195,6 +196,9 @@ func APIKeyAuthMiddleware() gin.HandlerFunc {
 
 		// Increment usage (async)
 		go service.IncrementUsage(keyInfo.ID, ip)
+		if usageService != nil {
+			usageService.Record(keyInfo.TenantID, models.UsageMetricAPICalls, 1)
+		}
 
 		// Log usage (async)
 		go logAPIKeyUsage(keyInfo.ID, keyInfo.AdvertiserID, ip, endpoint, method, c.GetHeader("User-Agent"), true, http.StatusOK, "", time.Since(startTime).Milliseconds())
@@ -259,7 +263,7 @@ func APIKeyRequiredMiddleware() gin.HandlerFunc {
 		}
 
 		// Check rate limits
-		if !checkAPIKeyRateLimit(keyInfo.ID.String(), ip, keyInfo.RateLimitPerMinute) {
+		if !checkAPIKeyRateLimit(keyInfo.ID.String(), ip, apiKeyRateLimit(keyInfo.TenantID, keyInfo.RateLimitPerMinute)) {
 			atomic.AddInt64(&apiKeyMetrics.RateLimitBlocks, 1)
 			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
 				"success": false,
@@ -276,8 +280,12 @@ func APIKeyRequiredMiddleware() gin.HandlerFunc {
 		c.Set(ContextAdvertiserID, keyInfo.AdvertiserID.String())
 		c.Set(ContextAPIKeyName, keyInfo.Name)
 		c.Set(ContextAuthMethod, AuthMethodAPIKey)
+		c.Set("api_key_tenant_id", keyInfo.TenantID)
 
 		go service.IncrementUsage(keyInfo.ID, ip)
+		if usageService != nil {
+			usageService.Record(keyInfo.TenantID, models.UsageMetricAPICalls, 1)
+		}
 		go logAPIKeyUsage(keyInfo.ID, keyInfo.AdvertiserID, ip, c.Request.URL.Path, c.Request.Method, c.GetHeader("User-Agent"), true, http.StatusOK, "", time.Since(startTime).Milliseconds())
 
 		c.Next()
@@ -334,6 +342,19 @@ func extractAPIKey(c *gin.Context) string {
 // RATE LIMITING
 // ============================================
 
+// apiKeyRateLimit returns a key's per-minute limit: its own limit capped by
+// the tenant's APIRateLimitPerMin, which also applies when the key has none
+func apiKeyRateLimit(tenantID uuid.UUID, keyLimit int) int {
+	limit := apiKeyRateLimitPerMinute
+	if tenantDB != nil {
+		limit = services.GetTenantSettingsResolver(tenantDB).Get(tenantID).APIRateLimitPerMin
+	}
+	if keyLimit > 0 && keyLimit < limit {
+		return keyLimit
+	}
+	return limit
+}
+
 // checkAPIKeyRateLimit checks if the request is within rate limits
 func checkAPIKeyRateLimit(keyID, ip string, customLimit int) bool {
 	ctx := context.Background()
@@ -5,9 +5,11 @@ import (
 	"strings"
 	"time"
 
+	"github.com/aljapah/afftok-backend-prod/internal/models"
 	"github.com/aljapah/afftok-backend-prod/internal/services"
 	"github.com/aljapah/afftok-backend-prod/pkg/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 // AuthMiddleware validates JWT token with enhanced security
@@ -83,6 +85,13 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Set("email", claims.Email)
 		c.Set("role", claims.Role)
 
+		// Tokens issued before multi-tenancy carry no tenant: they belong to the default tenant
+		jwtTenantID := claims.TenantID
+		if jwtTenantID == uuid.Nil {
+			jwtTenantID = models.DefaultTenantID
+		}
+		c.Set("jwt_tenant_id", jwtTenantID)
+
 		c.Next()
 	}
 }
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// BrandedTenantIDKey holds the tenant whose verified custom domain served the request
+const BrandedTenantIDKey = "branded_tenant_id"
+
+// BrandedDomainMiddleware marks requests that arrive on a tenant's verified
+// custom domain, so public links and landing pages can refuse to serve other
+// tenants' records there. Hosts that aren't custom domains are left alone.
+func BrandedDomainMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tenantService == nil {
+			c.Next()
+			return
+		}
+
+		host := c.Request.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		if tenant, err := tenantService.GetTenantByDomain(host); err == nil {
+			c.Set(BrandedTenantIDKey, tenant.ID)
+		}
+
+		c.Next()
+	}
+}
+
+// GetBrandedTenantID returns the custom-domain tenant of the request, if any
+func GetBrandedTenantID(c *gin.Context) (uuid.UUID, bool) {
+	if value, exists := c.Get(BrandedTenantIDKey); exists {
+		if id, ok := value.(uuid.UUID); ok {
+			return id, true
+		}
+	}
+	return uuid.Nil, false
+}
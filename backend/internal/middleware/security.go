@@ -5,8 +5,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/aljapah/afftok-backend-prod/internal/models"
 	"github.com/aljapah/afftok-backend-prod/internal/services"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 var securityService = services.NewSecurityService()
@@ -61,7 +63,12 @@ func ClickRateLimitMiddleware() gin.HandlerFunc {
 	}
 }
 
-// BotDetectionMiddleware detects and blocks bot traffic
+var botChallengeService = services.NewBotChallengeService()
+
+// BotDetectionMiddleware detects and blocks bot traffic.
+// Clicks in the tenant's grey zone get a JS challenge page instead of a 403;
+// clients holding a valid clearance cookie skip the challenge but are still
+// blocked above the tenant's hard-block threshold.
 func BotDetectionMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		result := securityService.DetectBot(c)
@@ -69,39 +76,145 @@ func BotDetectionMiddleware() gin.HandlerFunc {
 		// Store detection result in context
 		c.Set("botDetection", result)
 		
-		// Block high-confidence bots on click endpoints
-		if result.IsBot && result.Confidence > 0.8 {
-			path := c.Request.URL.Path
-			if strings.HasPrefix(path, "/api/c/") {
-				// Log the blocked request
-				securityService.LogAuditEvent(services.AuditEvent{
-					Timestamp: time.Now(),
-					EventType: "bot_blocked",
-					IP:        c.ClientIP(),
-					UserAgent: c.Request.UserAgent(),
-					Resource:  path,
-					Action:    "click",
-					Success:   false,
-					Details: map[string]interface{}{
-						"reason":     result.Reason,
-						"confidence": result.Confidence,
-						"risk_score": result.RiskScore,
-					},
-				})
-				
-				// Return 403 for obvious bots
-				c.JSON(http.StatusForbidden, gin.H{
-					"error": "Access denied",
-				})
-				c.Abort()
+		path := c.Request.URL.Path
+		if !isClickPath(path) {
+			c.Next()
+			return
+		}
+		
+		settings := resolveTenantSettings(c)
+		if !settings.EnableBotDetection {
+			c.Next()
+			return
+		}
+		
+		minRisk, maxRisk := services.ChallengeThresholds(settings.BotChallengeMinRisk, settings.BotChallengeMaxRisk)
+		
+		// Block high-confidence bots on click endpoints - a clearance cookie
+		// only skips the challenge, never the hard block
+		if result.RiskScore >= maxRisk {
+			// Log the blocked request
+			securityService.LogAuditEvent(services.AuditEvent{
+				Timestamp: time.Now(),
+				EventType: "bot_blocked",
+				IP:        c.ClientIP(),
+				UserAgent: c.Request.UserAgent(),
+				Resource:  path,
+				Action:    "click",
+				Success:   false,
+				Details: map[string]interface{}{
+					"reason":     result.Reason,
+					"confidence": result.Confidence,
+					"risk_score": result.RiskScore,
+				},
+			})
+			
+			// Return 403 for obvious bots
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Access denied",
+			})
+			c.Abort()
+			return
+		}
+		
+		// Already solved a challenge - carry the result to the click handler
+		if cookie, err := c.Cookie(services.BotChallengeCookieName); err == nil && cookie != "" {
+			if clearance, ok := botChallengeService.ValidateClearance(cookie, c.ClientIP(), c.Request.UserAgent()); ok {
+				c.Set(services.BotChallengeContextKey, clearance)
+				c.Next()
 				return
 			}
 		}
 		
+		// Grey zone - serve the JS challenge interstitial
+		if result.RiskScore >= minRisk {
+			serveBotChallenge(c, result)
+			return
+		}
+		
 		c.Next()
 	}
 }
 
+// serveBotChallenge renders the challenge page for a suspicious click
+func serveBotChallenge(c *gin.Context, result services.BotDetectionResult) {
+	token := botChallengeService.IssueChallenge(
+		c.ClientIP(),
+		c.Request.UserAgent(),
+		services.SafeReturnPath(c.Request.URL.RequestURI()),
+		result.RiskScore,
+	)
+	
+	nonce := services.GenerateScriptNonce()
+	page, err := botChallengeService.RenderChallengePage(token, nonce)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render challenge"})
+		c.Abort()
+		return
+	}
+	
+	securityService.LogAuditEvent(services.AuditEvent{
+		Timestamp: time.Now(),
+		EventType: "bot_challenge_issued",
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Resource:  c.Request.URL.Path,
+		Action:    "click",
+		Success:   true,
+		Details: map[string]interface{}{
+			"risk_score": result.RiskScore,
+		},
+	})
+	
+	c.Header("Content-Security-Policy", services.ChallengePageCSP(nonce))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+	c.Abort()
+}
+
+// isClickPath reports whether the path is a click tracking endpoint
+func isClickPath(path string) bool {
+	return strings.HasPrefix(path, "/api/c/") || strings.HasPrefix(path, "/c/")
+}
+
+// resolveTenantSettings returns the settings of the request's tenant,
+// falling back to defaults when the tenant cannot be loaded. Click endpoints
+// are public, so their tenant is the owner of the clicked link.
+func resolveTenantSettings(c *gin.Context) *models.TenantSettings {
+	tenantID := GetTenantID(c)
+	if isClickPath(c.Request.URL.Path) {
+		tenantID = ResolveClickTenant(c)
+	}
+	if tenantDB != nil {
+		return services.GetTenantSettingsResolver(tenantDB).Get(tenantID)
+	}
+	defaults := models.DefaultTenantSettings()
+	return &defaults
+}
+
+// ClickTenantIDKey holds the tenant that owns the link of a click request
+const ClickTenantIDKey = "click_tenant_id"
+
+// ResolveClickTenant returns the tenant owning the link in the click URL
+// (the default tenant when unknown) and keeps it on the context
+func ResolveClickTenant(c *gin.Context) uuid.UUID {
+	if value, exists := c.Get(ClickTenantIDKey); exists {
+		if id, ok := value.(uuid.UUID); ok {
+			return id
+		}
+	}
+
+	tenantID := uuid.Nil
+	if tenantDB != nil {
+		tenantID = services.GetTenantSettingsResolver(tenantDB).TenantForTrackingCode(c.Param("id"))
+	}
+	if tenantID == uuid.Nil {
+		tenantID = models.DefaultTenantID
+	}
+	c.Set(ClickTenantIDKey, tenantID)
+	return tenantID
+}
+
 // SecurityHeadersMiddleware adds security headers to responses
 func SecurityHeadersMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
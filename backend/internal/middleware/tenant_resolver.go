@@ -26,6 +26,16 @@ const (
 	TenantKey        = "tenant"
 	TenantSlugKey    = "tenant_slug"
 	IsSuperAdminKey  = "is_super_admin"
+	TenantSourceKey  = "tenant_source"
+)
+
+// Tenant resolution sources
+const (
+	TenantSourceHeader  = "header"
+	TenantSourceDomain  = "domain"
+	TenantSourceAPIKey  = "api_key"
+	TenantSourceJWT     = "jwt"
+	TenantSourceDefault = "default"
 )
 
 // ============================================
@@ -34,6 +44,7 @@ const (
 
 var (
 	tenantService *services.TenantService
+	usageService  *services.UsageService
 	tenantDB      *gorm.DB
 	tenantOnce    sync.Once
 )
@@ -43,6 +54,7 @@ func InitTenantMiddleware(db *gorm.DB) {
 	tenantOnce.Do(func() {
 		tenantDB = db
 		tenantService = services.NewTenantService(db)
+		usageService = services.GetUsageService(db)
 	})
 }
 
@@ -56,7 +68,7 @@ func TenantResolverMiddleware() gin.HandlerFunc {
 		}
 
 		// Try to resolve tenant
-		tenant, err := resolveTenant(c)
+		tenant, source, err := resolveTenant(c)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error":   "Tenant resolution failed",
@@ -66,7 +78,7 @@ func TenantResolverMiddleware() gin.HandlerFunc {
 		}
 
 		// Check if tenant is active
-		if tenant.Status == models.TenantStatusSuspended {
+		if tenant.Status == models.TenantStatusSuspended && !isSuspendedAllowedEndpoint(c.Request.URL.Path) {
 			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
 				"error": "Tenant is suspended",
 				"code":  "TENANT_SUSPENDED",
@@ -86,6 +98,7 @@ func TenantResolverMiddleware() gin.HandlerFunc {
 		c.Set(TenantIDKey, tenant.ID)
 		c.Set(TenantKey, tenant)
 		c.Set(TenantSlugKey, tenant.Slug)
+		c.Set(TenantSourceKey, source)
 
 		// Track tenant activity
 		go trackTenantActivity(tenant.ID)
@@ -94,20 +107,22 @@ func TenantResolverMiddleware() gin.HandlerFunc {
 	}
 }
 
-// resolveTenant resolves tenant from various sources
-func resolveTenant(c *gin.Context) (*models.Tenant, error) {
+// resolveTenant resolves tenant from various sources and reports which one matched
+func resolveTenant(c *gin.Context) (*models.Tenant, string, error) {
 	// 1. Try X-Tenant-ID header
 	if tenantID := c.GetHeader("X-Tenant-ID"); tenantID != "" {
 		id, err := uuid.Parse(tenantID)
 		if err != nil {
-			return nil, fmt.Errorf("invalid tenant ID format")
+			return nil, "", fmt.Errorf("invalid tenant ID format")
 		}
-		return tenantService.GetTenant(id)
+		tenant, err := tenantService.GetTenant(id)
+		return tenant, TenantSourceHeader, err
 	}
 
 	// 2. Try X-Tenant-Slug header
 	if slug := c.GetHeader("X-Tenant-Slug"); slug != "" {
-		return tenantService.GetTenantBySlug(slug)
+		tenant, err := tenantService.GetTenantBySlug(slug)
+		return tenant, TenantSourceHeader, err
 	}
 
 	// 3. Try domain/subdomain resolution
@@ -124,44 +139,47 @@ func resolveTenant(c *gin.Context) (*models.Tenant, error) {
 			subdomain := parts[0]
 			tenant, err := tenantService.GetTenantBySlug(subdomain)
 			if err == nil {
-				return tenant, nil
+				return tenant, TenantSourceDomain, nil
 			}
 		}
 
 		// Try custom domain resolution
 		tenant, err := tenantService.GetTenantByDomain(host)
 		if err == nil {
-			return tenant, nil
+			return tenant, TenantSourceDomain, nil
 		}
 	}
 
 	// 4. Try from API Key (if already resolved by API Key middleware)
 	if tenantID, exists := c.Get("api_key_tenant_id"); exists {
 		if id, ok := tenantID.(uuid.UUID); ok {
-			return tenantService.GetTenant(id)
+			tenant, err := tenantService.GetTenant(id)
+			return tenant, TenantSourceAPIKey, err
 		}
 	}
 
 	// 5. Try from JWT claims (if already authenticated)
 	if tenantID, exists := c.Get("jwt_tenant_id"); exists {
 		if id, ok := tenantID.(uuid.UUID); ok {
-			return tenantService.GetTenant(id)
+			tenant, err := tenantService.GetTenant(id)
+			return tenant, TenantSourceJWT, err
 		}
 	}
 
 	// 6. Default tenant (for backward compatibility during migration)
-	return tenantService.GetTenant(models.DefaultTenantID)
+	tenant, err := tenantService.GetTenant(models.DefaultTenantID)
+	return tenant, TenantSourceDefault, err
 }
 
 // isPublicEndpoint checks if the endpoint is public
 func isPublicEndpoint(path string) bool {
 	publicPaths := []string{
 		"/health",
-		"/api/auth/login",
-		"/api/auth/register",
 		"/api/c/", // Click tracking (tenant resolved from tracking code)
 		"/api/postback",
 		"/api/internal/",
+		"/api/billing/webhook", // Payment provider (signed)
+		"/api/auth/sso",        // SSO flows (tenant resolved from the path or one-time code)
 	}
 
 	for _, p := range publicPaths {
@@ -173,11 +191,19 @@ func isPublicEndpoint(path string) bool {
 	return false
 }
 
+// isSuspendedAllowedEndpoint checks if a suspended tenant may still use the
+// endpoint: signing in and paying the invoices that got it suspended
+func isSuspendedAllowedEndpoint(path string) bool {
+	return strings.HasPrefix(path, "/api/auth/") || strings.HasPrefix(path, "/api/billing")
+}
+
 // ============================================
 // TENANT RATE LIMITING
 // ============================================
 
-// TenantRateLimitMiddleware applies rate limits per tenant
+// TenantRateLimitMiddleware applies rate limits per tenant using the
+// tenant's APIRateLimitPerMin setting; requestsPerMinute is the fallback
+// when settings are unavailable
 func TenantRateLimitMiddleware(requestsPerMinute int) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tenantID, exists := c.Get(TenantIDKey)
@@ -187,6 +213,10 @@ func TenantRateLimitMiddleware(requestsPerMinute int) gin.HandlerFunc {
 		}
 
 		tid := tenantID.(uuid.UUID)
+		limit := requestsPerMinute
+		if tenantDB != nil {
+			limit = services.GetTenantSettingsResolver(tenantDB).Get(tid).APIRateLimitPerMin
+		}
 		key := fmt.Sprintf("tenant_ratelimit:%s:%d", tid.String(), time.Now().Minute())
 
 		ctx := context.Background()
@@ -196,7 +226,7 @@ func TenantRateLimitMiddleware(requestsPerMinute int) gin.HandlerFunc {
 			cache.Expire(ctx, key, 2*time.Minute)
 		}
 
-		if count > int64(requestsPerMinute) {
+		if count > int64(limit) {
 			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
 				"error": "Tenant rate limit exceeded",
 				"code":  "TENANT_RATE_LIMIT",
@@ -241,6 +271,75 @@ func TenantClickLimitMiddleware() gin.HandlerFunc {
 	}
 }
 
+// ============================================
+// TENANT MEMBERSHIP
+// ============================================
+
+// TenantMembershipMiddleware binds authenticated requests to the user's own
+// tenant. It must run after AuthMiddleware. The resolver runs before auth, so
+// when nothing explicit selected a tenant the JWT tenant wins; an explicit
+// header or domain pointing at another tenant is rejected. Admins of the
+// default (platform) tenant are super admins and may act on any tenant.
+func TenantMembershipMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get("jwt_tenant_id")
+		if !exists {
+			c.Next()
+			return
+		}
+		jwtTenantID, ok := value.(uuid.UUID)
+		if !ok || jwtTenantID == uuid.Nil {
+			c.Next()
+			return
+		}
+
+		role, _ := c.Get("role")
+		superAdmin := role == "admin" && jwtTenantID == models.DefaultTenantID
+		c.Set(IsSuperAdminKey, superAdmin)
+
+		resolvedID, resolved := c.Get(TenantIDKey)
+		if resolved && resolvedID == jwtTenantID {
+			c.Next()
+			return
+		}
+
+		source := c.GetString(TenantSourceKey)
+		if resolved && superAdmin && (source == TenantSourceHeader || source == TenantSourceDomain) {
+			c.Next()
+			return
+		}
+		if resolved && (source == TenantSourceHeader || source == TenantSourceDomain) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Access to this tenant is not allowed",
+				"code":  "TENANT_MISMATCH",
+			})
+			return
+		}
+
+		tenant, err := tenantService.GetTenant(jwtTenantID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "Tenant resolution failed",
+			})
+			return
+		}
+		if tenant.Status == models.TenantStatusSuspended && !isSuspendedAllowedEndpoint(c.Request.URL.Path) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Tenant is suspended",
+				"code":  "TENANT_SUSPENDED",
+			})
+			return
+		}
+
+		c.Set(TenantIDKey, tenant.ID)
+		c.Set(TenantKey, tenant)
+		c.Set(TenantSlugKey, tenant.Slug)
+		c.Set(TenantSourceKey, TenantSourceJWT)
+
+		c.Next()
+	}
+}
+
 // ============================================
 // SUPER ADMIN BYPASS
 // ============================================
@@ -302,10 +401,27 @@ func MustGetTenantID(c *gin.Context) uuid.UUID {
 	return tenantID
 }
 
+// SuperAdminMiddleware restricts platform-level routes (tenant management)
+// to super admins. Tenant admins also have the "admin" role but only within
+// their own tenant.
+func SuperAdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !IsSuperAdmin(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Super admin access required",
+				"code":  "SUPER_ADMIN_REQUIRED",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
 // IsSuperAdmin checks if current user is super admin
 func IsSuperAdmin(c *gin.Context) bool {
 	if isSuperAdmin, exists := c.Get(IsSuperAdminKey); exists {
-		return isSuperAdmin.(bool)
+		b, _ := isSuperAdmin.(bool)
+		return b
 	}
 	return false
 }
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+
+	"github.com/aljapah/afftok-backend-prod/internal/database"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TenantTxKey holds the request's RLS-scoped transaction
+const TenantTxKey = "tenant_tx"
+
+// TenantRLSMiddleware wraps the request in a transaction that Postgres
+// restricts to the resolved tenant (SET LOCAL app.tenant_id). Super admins get
+// the bypass role instead. It must run after TenantMembershipMiddleware and is
+// a no-op unless RLS is enabled. Handlers pick the transaction up through
+// GetTenantTx; their response is held back until it has been committed.
+func TenantRLSMiddleware(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !database.TenantRLSEnabled() {
+			c.Next()
+			return
+		}
+
+		tx := db.Begin()
+		if tx.Error != nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Database unavailable"})
+			return
+		}
+
+		var err error
+		if IsSuperAdmin(c) {
+			err = database.SetLocalBypass(tx)
+		} else {
+			err = database.SetLocalTenant(tx, GetTenantID(c))
+		}
+		if err != nil {
+			tx.Rollback()
+			log.Printf("[TenantRLS] failed to scope transaction: %v", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Database unavailable"})
+			return
+		}
+
+		// Hold the response back until the transaction is committed, so a
+		// client never sees success for writes that were rolled back
+		writer := &txResponseWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = writer
+		c.Set(TenantTxKey, tx)
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		if writer.status >= 500 || len(c.Errors) > 0 {
+			tx.Rollback()
+			writer.flush()
+			return
+		}
+		if err := tx.Commit().Error; err != nil {
+			log.Printf("[TenantRLS] commit failed for %s: %v", c.Request.URL.Path, err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to save changes"})
+			return
+		}
+		writer.flush()
+	}
+}
+
+// txResponseWriter buffers the status and body of a request that runs in a
+// tenant transaction until the transaction has been committed. Headers go to
+// the underlying writer directly; they are only sent on flush.
+type txResponseWriter struct {
+	gin.ResponseWriter
+	status  int
+	written bool
+	body    bytes.Buffer
+}
+
+func (w *txResponseWriter) WriteHeader(code int) {
+	if code > 0 && !w.written {
+		w.status = code
+	}
+}
+
+func (w *txResponseWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *txResponseWriter) Write(data []byte) (int, error) {
+	w.written = true
+	return w.body.Write(data)
+}
+
+func (w *txResponseWriter) WriteString(s string) (int, error) {
+	w.written = true
+	return w.body.WriteString(s)
+}
+
+func (w *txResponseWriter) Status() int {
+	return w.status
+}
+
+func (w *txResponseWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *txResponseWriter) Written() bool {
+	return w.written
+}
+
+// Flush is a no-op: nothing is sent before the commit
+func (w *txResponseWriter) Flush() {}
+
+// flush sends the buffered response to the underlying writer
+func (w *txResponseWriter) flush() {
+	w.ResponseWriter.WriteHeader(w.status)
+	if !w.written {
+		return
+	}
+	w.ResponseWriter.WriteHeaderNow()
+	if w.body.Len() > 0 {
+		w.ResponseWriter.Write(w.body.Bytes())
+	}
+}
+
+// GetTenantTx returns the request's RLS-scoped transaction, if any
+func GetTenantTx(c *gin.Context) (*gorm.DB, bool) {
+	if value, exists := c.Get(TenantTxKey); exists {
+		if tx, ok := value.(*gorm.DB); ok {
+			return tx, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ============================================
+// ADVERTISER PREPAID WALLETS
+// ============================================
+// An advertiser with a wallet pays in advance: top-ups credit the wallet and
+// every approved conversion (commission + platform fee) is charged to it.
+// Advertisers without a wallet keep being invoiced monthly in arrears.
+
+// AdvertiserWallet Status Constants
+const (
+	WalletStatusActive    = "active"
+	WalletStatusExhausted = "exhausted" // balance + credit limit used up - offers paused
+	WalletStatusDisabled  = "disabled"  // back to monthly invoicing
+)
+
+// WalletTransaction Type Constants
+const (
+	WalletTxTopUp            = "top_up"
+	WalletTxConversionCharge = "conversion_charge"
+	WalletTxConversionRefund = "conversion_refund"
+	WalletTxAdjustment       = "adjustment"
+)
+
+// OfferPausedWalletExhausted is Offer.PausedReason for offers paused because
+// their advertiser ran out of prepaid funds; they resume on the next top-up
+const OfferPausedWalletExhausted = "wallet_exhausted"
+
+// AdvertiserWallet is an advertiser's prepaid balance. Amounts are minor
+// units of Currency; Balance may go below zero down to -CreditLimit.
+// محفظة المعلن المدفوعة مسبقاً
+type AdvertiserWallet struct {
+	TenantModel
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AdvertiserID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex" json:"advertiser_id"`
+	Currency     string    `gorm:"type:varchar(3);not null" json:"currency"`
+	Status       string    `gorm:"type:varchar(20);not null;default:'active';index" json:"status"`
+
+	Balance             int64 `gorm:"not null;default:0" json:"balance"`
+	CreditLimit         int64 `gorm:"not null;default:0" json:"credit_limit"`          // how far the balance may go negative
+	LowBalanceThreshold int64 `gorm:"not null;default:0" json:"low_balance_threshold"` // notify when available funds drop below
+
+	LowBalanceNotifiedAt *time.Time `json:"low_balance_notified_at,omitempty"`
+	ExhaustedAt          *time.Time `json:"exhausted_at,omitempty"`
+	CreatedAt            time.Time  `json:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at"`
+
+	Advertiser *AfftokUser `gorm:"foreignKey:AdvertiserID" json:"advertiser,omitempty"`
+}
+
+// TableName specifies the table name
+func (AdvertiserWallet) TableName() string {
+	return "advertiser_wallets"
+}
+
+// Available returns the funds left before the wallet is exhausted
+func (w *AdvertiserWallet) Available() int64 {
+	return w.Balance + w.CreditLimit
+}
+
+// WalletTransaction is one movement of a wallet balance. IdempotencyKey makes
+// recording the same top-up or conversion charge twice a no-op.
+type WalletTransaction struct {
+	TenantModel
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	WalletID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"wallet_id"`
+	AdvertiserID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"advertiser_id"`
+	Type           string     `gorm:"type:varchar(30);not null" json:"type"`
+	Amount         int64      `gorm:"not null" json:"amount"` // minor units, + credit / - debit
+	BalanceAfter   int64      `gorm:"not null" json:"balance_after"`
+	Currency       string     `gorm:"type:varchar(3);not null" json:"currency"`
+	IdempotencyKey string     `gorm:"type:varchar(150);not null;uniqueIndex" json:"-"`
+	Source         string     `gorm:"type:varchar(30)" json:"source,omitempty"` // admin or the payment provider
+	ExternalRef    string     `gorm:"type:varchar(100)" json:"external_ref,omitempty"`
+	ConversionID   *uuid.UUID `gorm:"type:uuid;index" json:"conversion_id,omitempty"`
+	Note           string     `gorm:"type:text" json:"note,omitempty"`
+	CreatedBy      *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// TableName specifies the table name
+func (WalletTransaction) TableName() string {
+	return "wallet_transactions"
+}
+
+// WalletPendingCharge is a conversion charge that could not be converted into
+// the wallet's currency (no exchange rate yet). The conversion is kept and the
+// charge is settled once the rate is imported.
+type WalletPendingCharge struct {
+	TenantModel
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	WalletID     uuid.UUID `gorm:"type:uuid;not null;index" json:"wallet_id"`
+	AdvertiserID uuid.UUID `gorm:"type:uuid;not null;index" json:"advertiser_id"`
+	ConversionID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex" json:"conversion_id"`
+	Amount       int64     `gorm:"not null" json:"amount"` // minor units of Currency (the conversion's)
+	Currency     string    `gorm:"type:varchar(3);not null" json:"currency"`
+	Attempts     int       `gorm:"not null;default:0" json:"attempts"`
+	LastError    string    `gorm:"type:text" json:"last_error,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name
+func (WalletPendingCharge) TableName() string {
+	return "wallet_pending_charges"
+}
@@ -48,6 +48,7 @@ func (AffiliateNetwork) TableName() string {
 
 // PromoterNetworkAccount represents a promoter's account in an external network
 type PromoterNetworkAccount struct {
+	TenantModel
 	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
 	NetworkID uuid.UUID `gorm:"type:uuid;not null;index" json:"network_id"`
@@ -22,6 +22,7 @@ const (
 
 // AdvertiserAPIKey represents an API key for advertiser/network authentication
 type AdvertiserAPIKey struct {
+	TenantModel
 	ID           uuid.UUID          `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
 	AdvertiserID uuid.UUID          `gorm:"type:uuid;not null;index:idx_api_keys_advertiser" json:"advertiser_id"`
 	NetworkID    *uuid.UUID         `gorm:"type:uuid;index:idx_api_keys_network" json:"network_id,omitempty"`
@@ -8,6 +8,7 @@ import (
 
 // Contest represents a competition/challenge for teams or individuals
 type Contest struct {
+	TenantModel
 	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	Title       string     `gorm:"type:varchar(255);not null" json:"title"`
 	TitleAr     string     `gorm:"type:varchar(255)" json:"title_ar,omitempty"`
@@ -73,6 +74,7 @@ func (c *Contest) TimeLeft() time.Duration {
 
 // ContestParticipant represents a team or user participating in a contest
 type ContestParticipant struct {
+	TenantModel
 	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	ContestID   uuid.UUID  `gorm:"type:uuid;not null" json:"contest_id"`
 	TeamID      *uuid.UUID `gorm:"type:uuid" json:"team_id,omitempty"` // For team contests
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Conversion anomaly metrics
+const (
+	AnomalyMetricConversionRate = "conversion_rate" // conversions / clicks
+	AnomalyMetricApprovalRate   = "approval_rate"   // approved / (approved + rejected)
+	AnomalyMetricAvgOrderValue  = "avg_order_value" // average conversion amount
+	AnomalyMetricHourOfDay      = "hour_of_day"     // distance from the usual hour distribution
+)
+
+// Conversion anomaly status constants
+const (
+	AnomalyStatusOpen     = "open"     // الاستثناء نشط والتحويلات الجديدة محجوزة للمراجعة
+	AnomalyStatusReleased = "released" // تم الإفراج عن التحويلات المحجوزة
+	AnomalyStatusRejected = "rejected" // تم رفض التحويلات المحجوزة
+)
+
+// ConversionAnomaly records a promoter×offer pair whose conversion behaviour
+// deviates from its own baseline. While open, new conversions for the pair
+// are held in review status.
+type ConversionAnomaly struct {
+	TenantModel
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index:idx_conv_anomaly_pair" json:"user_id"`
+	OfferID    uuid.UUID  `gorm:"type:uuid;not null;index:idx_conv_anomaly_pair" json:"offer_id"`
+	Metric     string     `gorm:"type:varchar(30);not null" json:"metric"`
+	Observed   float64    `gorm:"type:decimal(14,4)" json:"observed"`
+	Baseline   float64    `gorm:"type:decimal(14,4)" json:"baseline"`
+	StdDev     float64    `gorm:"type:decimal(14,4)" json:"std_dev"`
+	ZScore     float64    `gorm:"type:decimal(8,2)" json:"z_score"`
+	SampleSize int        `gorm:"default:0" json:"sample_size"`
+	Status     string     `gorm:"type:varchar(20);default:'open';index:idx_conv_anomaly_status" json:"status"`
+	HeldCount  int        `gorm:"default:0" json:"held_count"`
+	DetectedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP;index" json:"detected_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	ResolvedBy *uuid.UUID `gorm:"type:uuid" json:"resolved_by,omitempty"`
+	Notes      string     `gorm:"type:text" json:"notes,omitempty"`
+
+	// Relationships
+	User  *AfftokUser `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Offer *Offer      `gorm:"foreignKey:OfferID" json:"offer,omitempty"`
+}
+
+func (ConversionAnomaly) TableName() string {
+	return "conversion_anomalies"
+}
+
+// BeforeCreate assigns the anomaly to its promoter's tenant
+func (a *ConversionAnomaly) BeforeCreate(tx *gorm.DB) error {
+	if a.TenantID == uuid.Nil {
+		a.TenantID = inheritTenantID(tx, "afftok_users", a.UserID)
+	}
+	return nil
+}
+
+// IsOpen checks if the anomaly still holds new conversions
+func (a *ConversionAnomaly) IsOpen() bool {
+	return a.Status == AnomalyStatusOpen
+}
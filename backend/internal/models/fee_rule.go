@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// ============================================
+// PLATFORM FEE RULES
+// ============================================
+// A fee rule sets the platform's cut of a conversion for a scope: the whole
+// tenant, a tenant plan (platform rules only), a promoter tier, an
+// advertiser or an offer. Rules are versioned: a change closes the current
+// version and opens a new one, so the version a ledger posting records
+// (LedgerTransaction.FeeRuleID) always describes how its fee was computed.
+
+// FeeRuleScope is what a fee rule applies to
+type FeeRuleScope string
+
+const (
+	FeeScopeTenant       FeeRuleScope = "tenant"        // every conversion of the tenant
+	FeeScopePlan         FeeRuleScope = "plan"          // tenants on a plan (platform rules)
+	FeeScopePromoterTier FeeRuleScope = "promoter_tier" // promoters of a level (rookie ... legend)
+	FeeScopeAdvertiser   FeeRuleScope = "advertiser"
+	FeeScopeOffer        FeeRuleScope = "offer"
+)
+
+// FeeRuleScopes lists the scopes from the most to the least specific
+var FeeRuleScopes = []FeeRuleScope{
+	FeeScopeOffer, FeeScopeAdvertiser, FeeScopePromoterTier, FeeScopePlan, FeeScopeTenant,
+}
+
+// FeeMode is how the fee relates to the commission
+type FeeMode string
+
+const (
+	// FeeModeMarkup charges the fee to the advertiser on top of the commission
+	FeeModeMarkup FeeMode = "markup"
+	// FeeModeRevenueShare keeps the fee out of the commission: the advertiser
+	// pays the commission and the promoter receives the rest
+	FeeModeRevenueShare FeeMode = "revenue_share"
+)
+
+// FeeVolumeBasis is the monthly advertiser volume tiered rates are based on
+type FeeVolumeBasis string
+
+const (
+	FeeVolumeNone        FeeVolumeBasis = ""
+	FeeVolumeConversions FeeVolumeBasis = "conversions" // approved conversions this month
+	FeeVolumeSpend       FeeVolumeBasis = "spend"       // spend on approved conversions this month, in the conversion's currency
+)
+
+// FeeTier is the rate from a monthly volume on
+type FeeTier struct {
+	From    float64 `json:"from"` // conversions, or major currency units
+	RateBps int64   `json:"rate_bps"`
+}
+
+// FeeRule is one version of a tenant's fee rule. Platform rules belong to
+// the default tenant and apply where a tenant has no rule of its own.
+type FeeRule struct {
+	TenantModel
+	ID         uuid.UUID    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Scope      FeeRuleScope `gorm:"type:varchar(20);not null;index:idx_fee_rules_scope" json:"scope"`
+	ScopeValue string       `gorm:"type:varchar(64);not null;default:'';index:idx_fee_rules_scope" json:"scope_value"` // plan, tier, advertiser or offer ID; empty for tenant
+	Version    int          `gorm:"not null;default:1" json:"version"`
+	Name       string       `gorm:"type:varchar(100)" json:"name,omitempty"`
+
+	Mode        FeeMode        `gorm:"type:varchar(20);not null;default:'markup'" json:"mode"`
+	RateBps     int64          `gorm:"not null;default:0" json:"rate_bps"` // 1000 = 10%, below the first tier
+	VolumeBasis FeeVolumeBasis `gorm:"type:varchar(20);not null;default:''" json:"volume_basis,omitempty"`
+	Tiers       datatypes.JSON `gorm:"type:jsonb" json:"tiers,omitempty"`           // []FeeTier
+	MinFee      float64        `gorm:"type:decimal(12,3);default:0" json:"min_fee"` // per conversion, in its currency
+
+	EffectiveFrom time.Time  `gorm:"not null;index" json:"effective_from"`
+	EffectiveTo   *time.Time `gorm:"index" json:"effective_to,omitempty"` // exclusive; nil = current
+	CreatedBy     *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (FeeRule) TableName() string {
+	return "fee_rules"
+}
+
+// EffectiveAt reports whether the version applies at t
+func (r *FeeRule) EffectiveAt(t time.Time) bool {
+	return !t.Before(r.EffectiveFrom) && (r.EffectiveTo == nil || t.Before(*r.EffectiveTo))
+}
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Fraud case sources
+const (
+	FraudCaseSourceThreat  = "threat"  // high-severity event from the threat detector
+	FraudCaseSourceKYC     = "kyc"     // automatic KYC trigger
+	FraudCaseSourceAnomaly = "anomaly" // conversion anomaly hold
+	FraudCaseSourceManual  = "manual"  // opened by an admin
+)
+
+// Fraud case status constants
+const (
+	FraudCaseStatusOpen          = "open"          // مفتوحة - لم يبدأ العمل عليها
+	FraudCaseStatusInvestigating = "investigating" // قيد التحقيق
+	FraudCaseStatusClosed        = "closed"        // مغلقة بقرار
+)
+
+// Fraud case decisions
+const (
+	FraudDecisionClear    = "clear"    // لا يوجد احتيال - إغلاق بدون إجراء
+	FraudDecisionClawback = "clawback" // رفض التحويلات المعلقة واسترداد العمولات
+	FraudDecisionBan      = "ban"      // استرداد + إيقاف الحساب + حظر عناوين IP
+)
+
+// Fraud case severities
+const (
+	FraudCaseSeverityLow      = "low"
+	FraudCaseSeverityMedium   = "medium"
+	FraudCaseSeverityHigh     = "high"
+	FraudCaseSeverityCritical = "critical"
+)
+
+// Fraud case link entity types
+const (
+	FraudCaseLinkClick      = "click"
+	FraudCaseLinkConversion = "conversion"
+	FraudCaseLinkIP         = "ip"
+)
+
+// FraudCase groups fraud signals about a promoter (or an IP) so an admin can
+// investigate them and apply a single decision.
+type FraudCase struct {
+	TenantModel
+	ID            uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID        *uuid.UUID     `gorm:"type:uuid;index:idx_fraud_case_user" json:"user_id,omitempty"`
+	Source        string         `gorm:"type:varchar(20);not null;index" json:"source"`
+	SourceRef     string         `gorm:"type:varchar(100)" json:"source_ref,omitempty"` // threat/anomaly ID, KYC reason
+	Severity      string         `gorm:"type:varchar(20);default:'medium'" json:"severity"`
+	Title         string         `gorm:"type:varchar(255);not null" json:"title"`
+	Summary       string         `gorm:"type:text" json:"summary,omitempty"`
+	Status        string         `gorm:"type:varchar(20);default:'open';index:idx_fraud_case_status" json:"status"`
+	AssigneeID    *uuid.UUID     `gorm:"type:uuid;index" json:"assignee_id,omitempty"`
+	SignalCount   int            `gorm:"default:1" json:"signal_count"` // عدد الإشارات المدمجة في الحالة
+	Decision      string         `gorm:"type:varchar(20)" json:"decision,omitempty"`
+	DecisionNotes string         `gorm:"type:text" json:"decision_notes,omitempty"`
+	DecidedBy     *uuid.UUID     `gorm:"type:uuid" json:"decided_by,omitempty"`
+	DecidedAt     *time.Time     `json:"decided_at,omitempty"`
+	ActionSummary datatypes.JSON `gorm:"type:jsonb" json:"action_summary,omitempty"`
+	CreatedBy     *uuid.UUID     `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt     time.Time      `gorm:"default:CURRENT_TIMESTAMP;index" json:"created_at"`
+	UpdatedAt     time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+
+	// Relationships
+	User  *AfftokUser     `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Links []FraudCaseLink `gorm:"foreignKey:CaseID" json:"links,omitempty"`
+	Notes []FraudCaseNote `gorm:"foreignKey:CaseID" json:"notes,omitempty"`
+}
+
+func (FraudCase) TableName() string {
+	return "fraud_cases"
+}
+
+// BeforeCreate assigns a user case to the user's tenant
+func (fc *FraudCase) BeforeCreate(tx *gorm.DB) error {
+	if fc.TenantID == uuid.Nil && fc.UserID != nil {
+		fc.TenantID = inheritTenantID(tx, "afftok_users", *fc.UserID)
+	}
+	return nil
+}
+
+// IsActive checks if the case is still awaiting a decision
+func (c *FraudCase) IsActive() bool {
+	return c.Status == FraudCaseStatusOpen || c.Status == FraudCaseStatusInvestigating
+}
+
+// FraudCaseLink attaches a click, conversion or IP address to a case
+type FraudCaseLink struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	CaseID     uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_fraud_case_link" json:"case_id"`
+	EntityType string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_fraud_case_link;index:idx_fraud_case_entity" json:"entity_type"`
+	EntityID   string    `gorm:"type:varchar(64);not null;uniqueIndex:idx_fraud_case_link;index:idx_fraud_case_entity" json:"entity_id"`
+	CreatedAt  time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+func (FraudCaseLink) TableName() string {
+	return "fraud_case_links"
+}
+
+// FraudCaseNote is an investigation note. System notes have no author.
+type FraudCaseNote struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	CaseID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"case_id"`
+	AuthorID  *uuid.UUID `gorm:"type:uuid" json:"author_id,omitempty"`
+	Body      string     `gorm:"type:text;not null" json:"body"`
+	CreatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+func (FraudCaseNote) TableName() string {
+	return "fraud_case_notes"
+}
+
+// ============================================
+// EARNINGS ADJUSTMENTS
+// ============================================
+
+// Earnings adjustment status constants
+const (
+	AdjustmentStatusPending = "pending" // لم يُخصم بعد من دفعة
+	AdjustmentStatusApplied = "applied" // تم خصمه
+)
+
+// EarningsAdjustment is a correction to a promoter's earnings. Reversals of
+// fraudulent conversions are recorded as negative adjustments; the ledger
+// transaction that moved the money is linked once it is posted.
+type EarningsAdjustment struct {
+	TenantModel
+	ID                  uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID              uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	ConversionID        *uuid.UUID `gorm:"type:uuid;index" json:"conversion_id,omitempty"`
+	CaseID              *uuid.UUID `gorm:"type:uuid;index" json:"case_id,omitempty"`
+	LedgerTransactionID *uuid.UUID `gorm:"type:uuid;index" json:"ledger_transaction_id,omitempty"`
+	Amount              int        `gorm:"not null" json:"amount"` // negative for reversals
+	Currency            string     `gorm:"type:varchar(3);default:'USD'" json:"currency"`
+	Reason              string     `gorm:"type:varchar(255)" json:"reason"`
+	Status              string     `gorm:"type:varchar(20);default:'pending';index" json:"status"`
+	CreatedBy           *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt           time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+func (EarningsAdjustment) TableName() string {
+	return "earnings_adjustments"
+}
+
+// BeforeCreate assigns the adjustment to its promoter's tenant
+func (a *EarningsAdjustment) BeforeCreate(tx *gorm.DB) error {
+	if a.TenantID == uuid.Nil {
+		a.TenantID = inheritTenantID(tx, "afftok_users", a.UserID)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ============================================
+// FX RATES
+// ============================================
+
+// FXRate is one daily exchange rate: Rate units of Quote buy one unit of
+// Base. Rates are platform-wide (not tenant scoped) and are imported from a
+// CSV file or a configured rate source.
+type FXRate struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	Date      time.Time `json:"date" gorm:"type:date;not null;uniqueIndex:idx_fx_rate"`
+	Base      string    `json:"base" gorm:"size:3;not null;uniqueIndex:idx_fx_rate"`
+	Quote     string    `json:"quote" gorm:"size:3;not null;uniqueIndex:idx_fx_rate"`
+	Rate      float64   `json:"rate" gorm:"type:decimal(24,12);not null"`
+	Source    string    `json:"source" gorm:"size:30"` // csv, http, manual
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (FXRate) TableName() string {
+	return "fx_rates"
+}
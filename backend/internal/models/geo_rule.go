@@ -38,6 +38,7 @@ const (
 
 // GeoRule represents a geographic rule for blocking/allowing countries
 type GeoRule struct {
+	TenantModel
 	ID        uuid.UUID        `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
 	
 	// Scope
@@ -7,12 +7,31 @@ import (
 	"gorm.io/gorm"
 )
 
+// Invoice Status Constants
+const (
+	InvoiceStatusPending             = "pending"
+	InvoiceStatusPendingConfirmation = "pending_confirmation" // payment submitted, waiting for an admin
+	InvoiceStatusPaid                = "paid"
+	InvoiceStatusOverdue             = "overdue"
+	InvoiceStatusCancelled           = "cancelled"
+)
+
+// OfferPausedInvoiceOverdue is Offer.PausedReason for offers paused because
+// their advertiser let an invoice go unpaid too long; they resume once no
+// such invoice is left
+const OfferPausedInvoiceOverdue = "invoice_overdue"
+
 // Invoice represents a monthly invoice for an advertiser
 type Invoice struct {
+	TenantModel
 	ID              uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
 	AdvertiserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"advertiser_id"`
 	Advertiser      *AfftokUser `gorm:"foreignKey:AdvertiserID" json:"advertiser,omitempty"`
 	
+	// Sequential per tenant (INV-000042); empty on invoices generated before numbering
+	Number          string     `gorm:"type:varchar(32);index" json:"number,omitempty"`
+	IssuedAt        *time.Time `json:"issued_at,omitempty"`
+	
 	// Invoice period
 	Month           int        `json:"month"` // 1-12
 	Year            int        `json:"year"`
@@ -26,14 +45,41 @@ type Invoice struct {
 	PlatformAmount      float64 `json:"platform_amount"`        // Amount owed to platform
 	Currency            string  `gorm:"default:'KWD'" json:"currency"`
 	
+	// Amount due: platform fee + adjustments + tax
+	AdjustmentAmount float64 `json:"adjustment_amount"`
+	Subtotal         float64 `json:"subtotal"`
+	TaxName          string  `gorm:"type:varchar(30)" json:"tax_name,omitempty"`
+	TaxRate          float64 `json:"tax_rate"` // 0.15 = 15%
+	TaxAmount        float64 `json:"tax_amount"`
+	TaxReverseCharge bool    `gorm:"default:false" json:"tax_reverse_charge,omitempty"` // advertiser self-accounts for VAT
+	TotalAmount      float64 `json:"total_amount"`
+	TaxCountry       string  `gorm:"type:varchar(50)" json:"tax_country,omitempty"`
+	
+	// Same totals in the advertiser's reporting currency (converted at the posting-time FX snapshot)
+	ReportingCurrency       string  `gorm:"type:varchar(3)" json:"reporting_currency,omitempty"`
+	ReportingPromoterPayout float64 `json:"reporting_promoter_payout,omitempty"`
+	ReportingPlatformAmount float64 `json:"reporting_platform_amount,omitempty"`
+	
 	// Status
-	Status          string     `gorm:"default:'pending'" json:"status"` // pending, paid, overdue, cancelled
+	Status          string     `gorm:"default:'pending'" json:"status"` // pending, pending_confirmation, paid, overdue, cancelled
 	DueDate         time.Time  `json:"due_date"`
 	PaidAt          *time.Time `json:"paid_at,omitempty"`
-	PaymentProof    string     `json:"payment_proof,omitempty"` // URL to uploaded receipt
+	PaymentProof    string     `json:"payment_proof,omitempty"` // URL to a receipt hosted elsewhere
 	PaymentMethod   string     `json:"payment_method,omitempty"` // bank_transfer, etc.
 	PaymentNote     string     `json:"payment_note,omitempty"`
 	
+	// Uploaded receipt, kept in receipt storage
+	ReceiptKey      string     `gorm:"type:varchar(255)" json:"-"`
+	ReceiptName     string     `gorm:"type:varchar(255)" json:"receipt_name,omitempty"`
+	ReceiptType     string     `gorm:"type:varchar(50)" json:"receipt_type,omitempty"`
+	ReceiptSize     int64      `json:"receipt_size,omitempty"`
+	
+	// Collections: last reminder sent (days relative to the due date) and
+	// when the advertiser's offers were paused for this invoice
+	LastReminderOffset *int       `json:"last_reminder_offset,omitempty"`
+	LastReminderAt     *time.Time `json:"last_reminder_at,omitempty"`
+	OffersPausedAt     *time.Time `json:"offers_paused_at,omitempty"`
+	
 	// Admin review
 	ReviewedBy      *uuid.UUID `gorm:"type:uuid" json:"reviewed_by,omitempty"`
 	ReviewedAt      *time.Time `json:"reviewed_at,omitempty"`
@@ -51,16 +97,37 @@ func (i *Invoice) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
-// InvoiceItem represents a line item in an invoice (optional detail)
+// AmountDue is what the advertiser has to pay. Invoices from before itemised
+// billing only owe their platform fee.
+func (i *Invoice) AmountDue() float64 {
+	if i.Number == "" && i.TotalAmount == 0 {
+		return i.PlatformAmount
+	}
+	return i.TotalAmount
+}
+
+// InvoiceItem Kind Constants
+const (
+	InvoiceItemConversions = "conversions" // one offer × goal of the period
+	InvoiceItemAdjustment  = "adjustment"
+	InvoiceItemCarryOver   = "carry_over" // credit moved to the next invoice
+)
+
+// InvoiceItem represents a line item in an invoice
 type InvoiceItem struct {
-	ID           uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
-	InvoiceID    uuid.UUID `gorm:"type:uuid;not null;index" json:"invoice_id"`
-	OfferID      uuid.UUID `gorm:"type:uuid" json:"offer_id"`
-	OfferTitle   string    `json:"offer_title"`
-	Conversions  int       `json:"conversions"`
-	PromoterPayout float64 `json:"promoter_payout"`
-	PlatformAmount float64 `json:"platform_amount"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID           uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	InvoiceID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"invoice_id"`
+	Kind         string     `gorm:"type:varchar(20);default:'conversions'" json:"kind"`
+	OfferID      *uuid.UUID `gorm:"type:uuid" json:"offer_id,omitempty"`
+	OfferTitle   string     `json:"offer_title,omitempty"`
+	Goal         string     `gorm:"type:varchar(50)" json:"goal,omitempty"`
+	AdjustmentID *uuid.UUID `gorm:"type:uuid" json:"adjustment_id,omitempty"`
+	Description  string     `gorm:"type:varchar(255)" json:"description,omitempty"`
+	Conversions  int        `json:"conversions"`
+	PromoterPayout float64  `json:"promoter_payout"`
+	PlatformAmount float64  `json:"platform_amount"` // fee or adjustment billed on this line
+	Position     int        `json:"position"`
+	CreatedAt    time.Time  `json:"created_at"`
 }
 
 func (ii *InvoiceItem) BeforeCreate(tx *gorm.DB) error {
@@ -70,6 +137,55 @@ func (ii *InvoiceItem) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// InvoiceAdjustment is a manual charge (amount > 0) or credit (amount < 0)
+// billed on an advertiser's next invoice in its currency
+type InvoiceAdjustment struct {
+	TenantModel
+	ID           uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AdvertiserID uuid.UUID  `gorm:"type:uuid;not null;index" json:"advertiser_id"`
+	Currency     string     `gorm:"type:varchar(3);not null" json:"currency"`
+	Amount       int64      `gorm:"not null" json:"amount"` // minor units
+	Description  string     `gorm:"type:varchar(255);not null" json:"description"`
+	InvoiceID    *uuid.UUID `gorm:"type:uuid;index" json:"invoice_id,omitempty"` // set once billed
+	CarriedFromInvoiceID *uuid.UUID `gorm:"type:uuid" json:"carried_from_invoice_id,omitempty"`
+	CreatedBy    *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func (InvoiceAdjustment) TableName() string {
+	return "invoice_adjustments"
+}
+
+// TaxRule is the tax charged on platform fees to advertisers of a country.
+// Country "*" is the fallback for countries without a rule.
+type TaxRule struct {
+	TenantModel
+	ID            uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Country       string    `gorm:"type:varchar(50);not null;index" json:"country"` // ISO code as stored on the advertiser, or "*"
+	Name          string    `gorm:"type:varchar(30);not null" json:"name"`          // VAT, GST
+	NameAr        string    `gorm:"type:varchar(50)" json:"name_ar,omitempty"`
+	RateBps       int64     `gorm:"not null;default:0" json:"rate_bps"` // 1500 = 15%
+	ReverseCharge bool      `gorm:"default:false" json:"reverse_charge"` // invoice without tax, advertiser self-accounts
+	Active        bool      `gorm:"default:true" json:"active"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (TaxRule) TableName() string {
+	return "tax_rules"
+}
+
+// InvoiceSequence hands out a tenant's invoice numbers without gaps
+type InvoiceSequence struct {
+	TenantID   uuid.UUID `gorm:"type:uuid;primaryKey" json:"tenant_id"`
+	LastNumber int64     `gorm:"not null;default:0" json:"last_number"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (InvoiceSequence) TableName() string {
+	return "invoice_sequences"
+}
+
 // InvoiceSummary for dashboard display
 type InvoiceSummary struct {
 	TotalInvoices     int     `json:"total_invoices"`
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// KYC provider names
+const (
+	KYCProviderSumSub = "sumsub"
+	KYCProviderVeriff = "veriff"
+)
+
+// KYC verification status constants
+const (
+	KYCVerificationPending      = "pending"      // تم إنشاء المتقدم ولم يرسل المستندات
+	KYCVerificationInReview     = "in_review"    // المستندات قيد المراجعة لدى المزود
+	KYCVerificationResubmission = "resubmission" // مطلوب إعادة إرسال المستندات
+	KYCVerificationApproved     = "approved"     // تم التحقق بنجاح
+	KYCVerificationRejected     = "rejected"     // رفض نهائي
+)
+
+// KYCStepStatus is the state of one step of a provider workflow
+// (e.g. identity document, selfie, proof of address)
+type KYCStepStatus struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Reasons   []string  `json:"reasons,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// KYCVerification tracks a user's verification attempt with an external
+// KYC provider: the provider applicant, per-step status, rejection reasons
+// and how many attempts the user has used.
+type KYCVerification struct {
+	TenantModel
+	ID               uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID           uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id"`
+	Provider         string         `gorm:"type:varchar(20);not null;index:idx_kyc_provider_applicant" json:"provider"`
+	ApplicantID      string         `gorm:"type:varchar(100);index:idx_kyc_provider_applicant" json:"applicant_id"`
+	Status           string         `gorm:"type:varchar(20);default:'pending';index" json:"status"`
+	CurrentStep      string         `gorm:"type:varchar(50)" json:"current_step,omitempty"`
+	Steps            datatypes.JSON `gorm:"type:jsonb" json:"steps,omitempty"`             // []KYCStepStatus
+	RejectionReasons datatypes.JSON `gorm:"type:jsonb" json:"rejection_reasons,omitempty"` // ["DOCUMENT_PAGE_MISSING", ...]
+	ProviderComment  string         `gorm:"type:text" json:"provider_comment,omitempty"`
+	Attempts         int            `gorm:"default:0" json:"attempts"`
+	MaxAttempts      int            `gorm:"default:3" json:"max_attempts"`
+	LastEventType    string         `gorm:"type:varchar(50)" json:"last_event_type,omitempty"`
+	LastEventAt      *time.Time     `json:"last_event_at,omitempty"`
+	CompletedAt      *time.Time     `json:"completed_at,omitempty"`
+	CreatedAt        time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt        time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+
+	// Relationships
+	User *AfftokUser `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+func (KYCVerification) TableName() string {
+	return "kyc_verifications"
+}
+
+// BeforeCreate assigns the verification to its user's tenant
+func (v *KYCVerification) BeforeCreate(tx *gorm.DB) error {
+	if v.TenantID == uuid.Nil {
+		v.TenantID = inheritTenantID(tx, "afftok_users", v.UserID)
+	}
+	return nil
+}
+
+// IsFinal checks if the verification reached a terminal state
+func (v *KYCVerification) IsFinal() bool {
+	return v.Status == KYCVerificationApproved || v.Status == KYCVerificationRejected
+}
+
+// AttemptsRemaining returns how many more submissions the user may make
+func (v *KYCVerification) AttemptsRemaining() int {
+	remaining := v.MaxAttempts - v.Attempts
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LandingBeacon is a page-view confirmation sent by the web SDK from the
+// advertiser's landing page. It proves that a click produced a real,
+// visible visit rather than a hidden iframe or pixel load.
+type LandingBeacon struct {
+	TenantModel
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	ClickID     uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_beacon_click" json:"click_id"`
+	UserOfferID uuid.UUID `gorm:"type:uuid;not null;index" json:"user_offer_id"`
+	UserID      uuid.UUID `gorm:"type:uuid;not null;index:idx_beacon_user_time" json:"user_id"` // المروج
+	IPAddress   string    `gorm:"type:varchar(45)" json:"ip_address,omitempty"`
+	UserAgent   string    `gorm:"type:text" json:"user_agent,omitempty"`
+	PageURL     string    `gorm:"type:text" json:"page_url,omitempty"`
+	Referrer    string    `gorm:"type:text" json:"referrer,omitempty"`
+
+	// Page view signals - إشارات المشاهدة الفعلية
+	Visible      bool `gorm:"default:false" json:"visible"`
+	VisibleMs    int  `gorm:"default:0" json:"visible_ms"`
+	TimeOnPageMs int  `gorm:"default:0" json:"time_on_page_ms"`
+	ViewportW    int  `gorm:"default:0" json:"viewport_w"`
+	ViewportH    int  `gorm:"default:0" json:"viewport_h"`
+	InIframe     bool `gorm:"default:false" json:"in_iframe"`
+
+	// Result
+	Verified   bool      `gorm:"default:false;index" json:"verified"`
+	Flags      string    `gorm:"type:jsonb" json:"flags,omitempty"` // ["hidden", "tiny_viewport", "iframe"]
+	ReceivedAt time.Time `gorm:"default:CURRENT_TIMESTAMP;index:idx_beacon_user_time" json:"received_at"`
+	UpdatedAt  time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+func (LandingBeacon) TableName() string {
+	return "landing_beacons"
+}
+
+// BeforeCreate assigns the beacon to its click's tenant
+func (b *LandingBeacon) BeforeCreate(tx *gorm.DB) error {
+	if b.TenantID == uuid.Nil {
+		b.TenantID = inheritTenantID(tx, "clicks", b.ClickID)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// EARNINGS LEDGER (DOUBLE ENTRY)
+// ============================================
+// Every money movement is a LedgerTransaction with two or more LedgerEntry
+// postings whose amounts sum to zero per currency. Amounts are integer minor
+// units (cents, fils, ...) in the entry's currency: positive is a debit,
+// negative a credit. Postings are never updated or deleted; corrections are
+// new transactions (e.g. a reversal that negates the original postings).
+
+// ErrLedgerImmutable is returned when code tries to change a posted ledger row
+var ErrLedgerImmutable = errors.New("ledger postings are immutable")
+
+// LedgerAccountType identifies the role of a ledger account
+type LedgerAccountType string
+
+const (
+	// LedgerAccountAdvertiserReceivable is what an advertiser owes (asset, debit balance)
+	LedgerAccountAdvertiserReceivable LedgerAccountType = "advertiser_receivable"
+	// LedgerAccountPromoterPayable is what the platform owes a promoter (liability, credit balance)
+	LedgerAccountPromoterPayable LedgerAccountType = "promoter_payable"
+	// LedgerAccountPlatformRevenue is the platform's fee income (credit balance)
+	LedgerAccountPlatformRevenue LedgerAccountType = "platform_revenue"
+	// LedgerAccountAdjustments absorbs manual corrections and write-offs (debit balance)
+	LedgerAccountAdjustments LedgerAccountType = "adjustments"
+	// LedgerAccountCash is money actually received from advertisers or paid to promoters
+	LedgerAccountCash LedgerAccountType = "cash"
+	// LedgerAccountTaxPayable is tax collected on invoices, owed to the tax authority (credit balance)
+	LedgerAccountTaxPayable LedgerAccountType = "tax_payable"
+)
+
+// LedgerTransactionKind is the business event a transaction records
+type LedgerTransactionKind string
+
+const (
+	LedgerKindConversionApproved LedgerTransactionKind = "conversion_approved"
+	LedgerKindConversionReversed LedgerTransactionKind = "conversion_reversed"
+	LedgerKindAdjustment         LedgerTransactionKind = "adjustment"
+	LedgerKindPayoutPaid         LedgerTransactionKind = "payout_paid"
+	LedgerKindInvoiceIssued      LedgerTransactionKind = "invoice_issued" // tax charged on an invoice
+	LedgerKindInvoicePaid        LedgerTransactionKind = "invoice_paid"
+	LedgerKindWalletTopUp        LedgerTransactionKind = "wallet_top_up"
+	LedgerKindOverrideEarned     LedgerTransactionKind = "override_earned" // referral or team override on a conversion
+	LedgerKindOverrideReversed   LedgerTransactionKind = "override_reversed"
+)
+
+// LedgerAccount is one account of a tenant's ledger. Platform accounts have
+// OwnerID uuid.Nil; advertiser and promoter accounts are owned by a user.
+type LedgerAccount struct {
+	ID        uuid.UUID         `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	TenantID  uuid.UUID         `json:"tenant_id" gorm:"type:uuid;not null;uniqueIndex:idx_ledger_account"`
+	Type      LedgerAccountType `json:"type" gorm:"size:30;not null;uniqueIndex:idx_ledger_account"`
+	OwnerID   uuid.UUID         `json:"owner_id" gorm:"type:uuid;not null;uniqueIndex:idx_ledger_account;index"`
+	Currency  string            `json:"currency" gorm:"size:3;not null;uniqueIndex:idx_ledger_account"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+func (LedgerAccount) TableName() string {
+	return "ledger_accounts"
+}
+
+// LedgerTransaction is one balanced journal entry
+type LedgerTransaction struct {
+	ID       uuid.UUID             `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	TenantID uuid.UUID             `json:"tenant_id" gorm:"type:uuid;not null;index"`
+	Kind     LedgerTransactionKind `json:"kind" gorm:"size:30;not null;index"`
+
+	// IdempotencyKey makes posting the same event twice a no-op
+	// (e.g. "conversion_approved:<conversion id>")
+	IdempotencyKey string `json:"idempotency_key" gorm:"size:150;not null;uniqueIndex"`
+
+	// What the transaction is about
+	ReferenceType string     `json:"reference_type" gorm:"size:30;index:idx_ledger_tx_reference"`
+	ReferenceID   uuid.UUID  `json:"reference_id" gorm:"type:uuid;index:idx_ledger_tx_reference"`
+	ReversesID    *uuid.UUID `json:"reverses_id,omitempty" gorm:"type:uuid;index"`
+
+	// Reporting dimensions
+	AdvertiserID *uuid.UUID `json:"advertiser_id,omitempty" gorm:"type:uuid;index"`
+	PromoterID   *uuid.UUID `json:"promoter_id,omitempty" gorm:"type:uuid;index"`
+	OfferID      *uuid.UUID `json:"offer_id,omitempty" gorm:"type:uuid;index"`
+	UserOfferID  *uuid.UUID `json:"user_offer_id,omitempty" gorm:"type:uuid"`
+
+	Currency string `json:"currency" gorm:"size:3;not null"`
+
+	// FeeRuleID is the fee rule version that set a conversion's platform fee
+	// (nil: the built-in default rate)
+	FeeRuleID *uuid.UUID `json:"fee_rule_id,omitempty" gorm:"type:uuid;index"`
+
+	// FX snapshot taken at posting time: units of each reporting currency
+	// per one unit of Currency, e.g. {"USD": 3.25, "SAR": 12.19} for KWD
+	FXRates    datatypes.JSON `json:"fx_rates,omitempty" gorm:"type:jsonb"`
+	FXRateDate *time.Time     `json:"fx_rate_date,omitempty" gorm:"type:date"`
+
+	Description string     `json:"description,omitempty" gorm:"size:255"`
+	OccurredAt  time.Time  `json:"occurred_at" gorm:"not null;index"` // when the event happened (approval time, payment time)
+	CreatedBy   *uuid.UUID `json:"created_by,omitempty" gorm:"type:uuid"`
+	CreatedAt   time.Time  `json:"created_at"`
+
+	Entries []LedgerEntry `json:"entries,omitempty" gorm:"foreignKey:TransactionID"`
+}
+
+func (LedgerTransaction) TableName() string {
+	return "ledger_transactions"
+}
+
+// LedgerEntry is one posting of a transaction to an account
+type LedgerEntry struct {
+	ID            uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	TenantID      uuid.UUID `json:"tenant_id" gorm:"type:uuid;not null;index"`
+	TransactionID uuid.UUID `json:"transaction_id" gorm:"type:uuid;not null;index"`
+	AccountID     uuid.UUID `json:"account_id" gorm:"type:uuid;not null;index"`
+	Amount        int64     `json:"amount" gorm:"not null"` // minor units, + debit / - credit
+	Currency      string    `json:"currency" gorm:"size:3;not null"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (LedgerEntry) TableName() string {
+	return "ledger_entries"
+}
+
+// Postings are append-only
+func (*LedgerTransaction) BeforeUpdate(*gorm.DB) error { return ErrLedgerImmutable }
+func (*LedgerTransaction) BeforeDelete(*gorm.DB) error { return ErrLedgerImmutable }
+func (*LedgerEntry) BeforeUpdate(*gorm.DB) error       { return ErrLedgerImmutable }
+func (*LedgerEntry) BeforeDelete(*gorm.DB) error       { return ErrLedgerImmutable }
+
+// Earnings counter owners
+const (
+	EarningsOwnerUser      = "afftok_user"
+	EarningsOwnerUserOffer = "user_offer"
+)
+
+// EarningsCounter is a promoter's (or user offer's) conversion and override
+// earnings in one currency, in minor units. It moves in the same database
+// transaction as the ledger posting and can be rebuilt from the ledger.
+type EarningsCounter struct {
+	ID        uuid.UUID `json:"-" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	TenantID  uuid.UUID `json:"-" gorm:"type:uuid;not null;index"`
+	OwnerType string    `json:"-" gorm:"size:20;not null;uniqueIndex:idx_earnings_counter"`
+	OwnerID   uuid.UUID `json:"-" gorm:"type:uuid;not null;uniqueIndex:idx_earnings_counter"`
+	Currency  string    `json:"currency" gorm:"size:3;not null;uniqueIndex:idx_earnings_counter"`
+	Amount    int64     `json:"amount" gorm:"not null;default:0"` // minor units
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (EarningsCounter) TableName() string {
+	return "earnings_counters"
+}
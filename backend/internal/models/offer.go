@@ -4,9 +4,11 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type Network struct {
+	TenantModel
 	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	Name        string    `gorm:"type:varchar(100);not null" json:"name"`
 	Description string    `gorm:"type:text" json:"description,omitempty"`
@@ -26,6 +28,7 @@ func (Network) TableName() string {
 }
 
 type Offer struct {
+	TenantModel
 	ID                 uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	NetworkID          *uuid.UUID `gorm:"type:uuid" json:"network_id,omitempty"`
 	AffiliateNetworkID *uuid.UUID `gorm:"type:uuid;index" json:"affiliate_network_id,omitempty"` // الشبكة الخارجية (Noon, Amazon, Payoneer)
@@ -53,7 +56,7 @@ type Offer struct {
 	DestinationURL   string     `gorm:"type:text;not null" json:"destination_url"`
 	Category         string     `gorm:"type:varchar(50)" json:"category,omitempty"`
 	Payout           int        `gorm:"default:0" json:"payout"`
-	Commission       int        `gorm:"default:0" json:"commission"`
+	Commission       int        `gorm:"default:0" json:"commission"` // whole currency units per conversion
 	PayoutType       string     `gorm:"type:varchar(20);default:'cpa'" json:"payout_type"`
 	
 	// Geo Targeting - استهداف الدول
@@ -70,6 +73,7 @@ type Offer struct {
 	// Fraud Protection - حماية من الاحتيال
 	MaxFraudScore     int       `gorm:"default:70" json:"max_fraud_score"`                       // الحد الأقصى لنقاط الاحتيال (0-100)
 	AutoRejectFraud   bool      `gorm:"default:true" json:"auto_reject_fraud"`                   // رفض تلقائي للتحويلات المشبوهة
+	BeaconEnabled     bool      `gorm:"default:false" json:"beacon_enabled"`                     // صفحة الهبوط ترسل beacon لتأكيد الزيارة
 	
 	// Additional Notes - ملاحظات إضافية
 	AdditionalNotes  string     `gorm:"type:text" json:"additional_notes,omitempty"`
@@ -84,6 +88,7 @@ type Offer struct {
 	UsersCount       int        `gorm:"default:0" json:"users_count"`
 	Status           string     `gorm:"type:varchar(20);default:'pending'" json:"status"` // pending, active, rejected, paused
 	RejectionReason  string     `gorm:"type:text" json:"rejection_reason,omitempty"`      // NEW: Reason if rejected
+	PausedReason     string     `gorm:"type:varchar(30)" json:"paused_reason,omitempty"`  // set when the platform paused it (wallet_exhausted, invoice_overdue)
 	TotalClicks      int        `gorm:"default:0" json:"total_clicks"`
 	TotalConversions int        `gorm:"default:0" json:"total_conversions"`
 	CreatedAt        time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
@@ -122,6 +127,7 @@ func (o *Offer) ConversionRate() float64 {
 }
 
 type UserOffer struct {
+	TenantModel
 	ID            uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	UserID        uuid.UUID `gorm:"type:uuid;not null;index:idx_user_offers_user" json:"user_id"`
 	OfferID       uuid.UUID `gorm:"type:uuid;not null;index:idx_user_offers_offer" json:"offer_id"`
@@ -129,7 +135,6 @@ type UserOffer struct {
 	ShortLink     string    `gorm:"type:text;index:idx_user_offers_short_link" json:"short_link,omitempty"`
 	TrackingCode  string    `gorm:"type:varchar(32);index:idx_user_offers_tracking" json:"tracking_code,omitempty"`
 	Status        string    `gorm:"type:varchar(20);default:'active';index:idx_user_offers_status" json:"status"`
-	Earnings      int       `gorm:"default:0" json:"earnings"`
 	TotalClicks   int       `gorm:"default:0" json:"total_clicks"`
 	TotalConversions int    `gorm:"default:0" json:"total_conversions"`
 	JoinedAt      time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"joined_at"`
@@ -138,12 +143,21 @@ type UserOffer struct {
 	Offer         *Offer       `gorm:"foreignKey:OfferID" json:"offer,omitempty"`
 	Clicks        []Click      `gorm:"foreignKey:UserOfferID" json:"clicks,omitempty"`
 	Conversions   []Conversion `gorm:"foreignKey:UserOfferID" json:"conversions,omitempty"`
+	Earnings      []EarningsCounter `gorm:"polymorphic:Owner;polymorphicValue:user_offer;constraint:-" json:"earnings,omitempty"`
 }
 
 func (UserOffer) TableName() string {
 	return "user_offers"
 }
 
+// BeforeCreate assigns the user offer to its offer's tenant
+func (uo *UserOffer) BeforeCreate(tx *gorm.DB) error {
+	if uo.TenantID == uuid.Nil {
+		uo.TenantID = inheritTenantID(tx, "offers", uo.OfferID)
+	}
+	return nil
+}
+
 func (uo *UserOffer) ConversionRate() float64 {
 	totalClicks := len(uo.Clicks)
 	totalConversions := len(uo.Conversions)
@@ -4,70 +4,99 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
 // Payout Status Constants
 const (
-	PayoutStatusPending   = "pending"   // في انتظار الموافقة
-	PayoutStatusApproved  = "approved"  // تمت الموافقة
-	PayoutStatusPaid      = "paid"      // تم الدفع
-	PayoutStatusFailed    = "failed"    // فشل الدفع
-	PayoutStatusCancelled = "cancelled" // ملغي
+	PayoutStatusPending     = "pending"      // في انتظار الموافقة
+	PayoutStatusApproved    = "approved"     // تمت الموافقة
+	PayoutStatusPaid        = "paid"         // تم الدفع
+	PayoutStatusFailed      = "failed"       // فشل الدفع
+	PayoutStatusCancelled   = "cancelled"    // ملغي
+	PayoutStatusOnHold      = "on_hold"      // محجوز - بانتظار التحقق من الهوية (KYC) أو حالة احتيال
+	PayoutStatusProcessing  = "processing"   // أُرسلت للدفع - بانتظار التسوية
+	PayoutStatusCarriedOver = "carried_over" // أقل من الحد الأدنى - يُرحّل للفترة التالية
 )
 
 // PayoutBatch Status Constants
 const (
-	BatchStatusDraft     = "draft"     // مسودة - لم ترسل بعد
-	BatchStatusSubmitted = "submitted" // تم الإرسال لـ Payoneer
-	BatchStatusProcessing = "processing" // قيد المعالجة
-	BatchStatusCompleted = "completed" // مكتمل
-	BatchStatusFailed    = "failed"    // فشل
+	BatchStatusDraft           = "draft"            // مسودة - قيد المراجعة
+	BatchStatusApproved        = "approved"         // تمت الموافقة - جاهزة للإرسال
+	BatchStatusSubmitted       = "submitted"        // تم الإرسال لـ Payoneer
+	BatchStatusProcessing      = "processing"       // قيد المعالجة
+	BatchStatusCompleted       = "completed"        // مكتمل
+	BatchStatusPartiallyFailed = "partially_failed" // مكتمل مع بعض الدفعات الفاشلة
+	BatchStatusFailed          = "failed"           // فشل
+	BatchStatusCancelled       = "cancelled"        // ملغاة قبل الإرسال
 )
 
 // Payout represents a single payout from advertiser to promoter
 // المستحقات - كل سجل يمثل مبلغ من معلن لمروج لفترة معينة
 type Payout struct {
-	ID               uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
-	BatchID          *uuid.UUID `gorm:"type:uuid;index" json:"batch_id,omitempty"` // رقم الدفعة الشهرية
-	
+	TenantModel
+	ID      uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	BatchID *uuid.UUID `gorm:"type:uuid;index" json:"batch_id,omitempty"` // رقم الدفعة الشهرية
+
 	// الأطراف
-	AdvertiserID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"advertiser_id"` // المعلن (الدافع)
-	PublisherID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"publisher_id"`  // المروج (المستلم)
-	
-	// تفاصيل المبلغ
-	Amount           float64    `gorm:"type:decimal(12,2);not null" json:"amount"`     // المبلغ الإجمالي
-	PlatformFee      float64    `gorm:"type:decimal(12,2);default:0" json:"platform_fee"` // عمولة المنصة (10%)
-	NetAmount        float64    `gorm:"type:decimal(12,2);default:0" json:"net_amount"`   // صافي المبلغ للمروج
-	Currency         string     `gorm:"type:varchar(3);default:'USD'" json:"currency"`
-	
+	AdvertiserID uuid.UUID `gorm:"type:uuid;not null;index" json:"advertiser_id"` // المعلن (الدافع)
+	PublisherID  uuid.UUID `gorm:"type:uuid;not null;index" json:"publisher_id"`  // المروج (المستلم)
+
+	// تفاصيل المبلغ - three decimals so 3-digit currencies (KWD, BHD) keep their fils
+	Amount      float64 `gorm:"type:decimal(14,3);not null" json:"amount"`        // المبلغ الإجمالي
+	PlatformFee float64 `gorm:"type:decimal(14,3);default:0" json:"platform_fee"` // عمولة المنصة (من دفتر الأستاذ)
+	NetAmount   float64 `gorm:"type:decimal(14,3);default:0" json:"net_amount"`   // صافي المبلغ للمروج
+	Currency    string  `gorm:"type:varchar(3);default:'USD'" json:"currency"`
+
+	// المبالغ بعملة التقارير الخاصة بالمروج (محولة بسعر الصرف المحفوظ)
+	ReportingCurrency    string  `gorm:"type:varchar(3)" json:"reporting_currency,omitempty"`
+	ReportingAmount      float64 `gorm:"type:decimal(14,3);default:0" json:"reporting_amount,omitempty"`
+	ReportingPlatformFee float64 `gorm:"type:decimal(14,3);default:0" json:"reporting_platform_fee,omitempty"`
+	ReportingNetAmount   float64 `gorm:"type:decimal(14,3);default:0" json:"reporting_net_amount,omitempty"`
+	FXRate               float64 `gorm:"type:decimal(24,12);default:0" json:"fx_rate,omitempty"` // وحدات عملة التقارير لكل وحدة من Currency
+
 	// الفترة
-	Period           string     `gorm:"type:varchar(7);not null;index" json:"period"` // "2025-01"
-	PeriodStart      time.Time  `json:"period_start"`
-	PeriodEnd        time.Time  `json:"period_end"`
-	
+	Period      string    `gorm:"type:varchar(7);not null;index" json:"period"` // "2025-01"
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+
 	// الإحصائيات
-	ConversionsCount int        `gorm:"default:0" json:"conversions_count"`
-	ClicksCount      int        `gorm:"default:0" json:"clicks_count"`
-	
+	ConversionsCount int `gorm:"default:0" json:"conversions_count"`
+	ClicksCount      int `gorm:"default:0" json:"clicks_count"`
+
 	// الحالة
-	Status           string     `gorm:"type:varchar(20);default:'pending';index" json:"status"`
-	
+	Status     string `gorm:"type:varchar(20);default:'pending';index" json:"status"`
+	HoldReason string `gorm:"type:varchar(100)" json:"hold_reason,omitempty"` // سبب الحجز (مثل kyc_required)
+
+	// الترحيل - carry-over of sub-threshold or unpaid lines into a later period
+	CarriedOverAmount float64    `gorm:"type:decimal(14,3);default:0" json:"carried_over_amount,omitempty"` // صافي المبلغ المرحّل من فترات سابقة
+	CarriedIntoID     *uuid.UUID `gorm:"type:uuid;index" json:"carried_into_id,omitempty"`                  // الدفعة التي رُحّل إليها هذا السطر
+
+	// التسوية - settlement result of a submitted line
+	ExternalRef   string `gorm:"type:varchar(100)" json:"external_ref,omitempty"`
+	FailureReason string `gorm:"type:text" json:"failure_reason,omitempty"`
+
+	// قناة الدفع - payment rail the line was sent through (payoneer, paypal, bank_transfer, wise, manual)
+	Rail           string     `gorm:"type:varchar(20);index" json:"rail,omitempty"`
+	PayoutMethodID *uuid.UUID `gorm:"type:uuid" json:"payout_method_id,omitempty"`
+
 	// Payoneer Integration
-	PayoneerPaymentID string    `gorm:"type:varchar(100)" json:"payoneer_payment_id,omitempty"`
-	PayoneerStatus    string    `gorm:"type:varchar(50)" json:"payoneer_status,omitempty"`
-	PayoneerError     string    `gorm:"type:text" json:"payoneer_error,omitempty"`
-	
+	PayoneerPaymentID string `gorm:"type:varchar(100)" json:"payoneer_payment_id,omitempty"`
+	PayoneerStatus    string `gorm:"type:varchar(50)" json:"payoneer_status,omitempty"`
+	PayoneerError     string `gorm:"type:text" json:"payoneer_error,omitempty"`
+
 	// التواريخ
-	CreatedAt        time.Time  `json:"created_at"`
-	UpdatedAt        time.Time  `json:"updated_at"`
-	ApprovedAt       *time.Time `json:"approved_at,omitempty"`
-	PaidAt           *time.Time `json:"paid_at,omitempty"`
-	
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	ApprovedAt  *time.Time `json:"approved_at,omitempty"`
+	SubmittedAt *time.Time `json:"submitted_at,omitempty"`
+	PaidAt      *time.Time `json:"paid_at,omitempty"`
+
 	// العلاقات
-	Advertiser       *AfftokUser `gorm:"foreignKey:AdvertiserID" json:"advertiser,omitempty"`
-	Publisher        *AfftokUser `gorm:"foreignKey:PublisherID" json:"publisher,omitempty"`
-	Batch            *PayoutBatch `gorm:"foreignKey:BatchID" json:"batch,omitempty"`
+	Advertiser *AfftokUser  `gorm:"foreignKey:AdvertiserID" json:"advertiser,omitempty"`
+	Publisher  *AfftokUser  `gorm:"foreignKey:PublisherID" json:"publisher,omitempty"`
+	Batch      *PayoutBatch `gorm:"foreignKey:BatchID" json:"batch,omitempty"`
 }
 
 // TableName specifies the table name
@@ -80,10 +109,10 @@ func (p *Payout) BeforeCreate(tx *gorm.DB) error {
 	if p.ID == uuid.Nil {
 		p.ID = uuid.New()
 	}
-	// حساب عمولة المنصة 10%
-	if p.PlatformFee == 0 && p.Amount > 0 {
-		p.PlatformFee = p.Amount * 0.10
-		p.NetAmount = p.Amount - p.PlatformFee
+	// Fee and net amount come from the earnings ledger; without a fee the
+	// promoter receives the full amount
+	if p.NetAmount == 0 && p.PlatformFee == 0 {
+		p.NetAmount = p.Amount
 	}
 	return nil
 }
@@ -91,46 +120,53 @@ func (p *Payout) BeforeCreate(tx *gorm.DB) error {
 // PayoutBatch represents a monthly batch of payouts
 // دفعة الشهر - تجمع كل المستحقات لفترة معينة
 type PayoutBatch struct {
-	ID                uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
-	
+	TenantModel
+	ID uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+
 	// معلومات الدفعة
-	Period            string     `gorm:"type:varchar(7);not null;uniqueIndex" json:"period"` // "2025-01"
-	PeriodStart       time.Time  `json:"period_start"`
-	PeriodEnd         time.Time  `json:"period_end"`
-	
+	Period      string    `gorm:"type:varchar(7);not null;index" json:"period"` // "2025-01" - فريد لكل مستأجر
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+
 	// الإحصائيات
-	TotalAmount       float64    `gorm:"type:decimal(14,2);default:0" json:"total_amount"`
-	TotalPlatformFee  float64    `gorm:"type:decimal(14,2);default:0" json:"total_platform_fee"`
-	TotalNetAmount    float64    `gorm:"type:decimal(14,2);default:0" json:"total_net_amount"`
-	TotalPayouts      int        `gorm:"default:0" json:"total_payouts"`
-	TotalPublishers   int        `gorm:"default:0" json:"total_publishers"`
-	TotalAdvertisers  int        `gorm:"default:0" json:"total_advertisers"`
-	TotalConversions  int        `gorm:"default:0" json:"total_conversions"`
-	Currency          string     `gorm:"type:varchar(3);default:'USD'" json:"currency"`
-	
+	TotalAmount      float64 `gorm:"type:decimal(16,3);default:0" json:"total_amount"`
+	TotalPlatformFee float64 `gorm:"type:decimal(16,3);default:0" json:"total_platform_fee"`
+	TotalNetAmount   float64 `gorm:"type:decimal(16,3);default:0" json:"total_net_amount"`
+	TotalPayouts     int     `gorm:"default:0" json:"total_payouts"`
+	TotalPublishers  int     `gorm:"default:0" json:"total_publishers"`
+	TotalAdvertisers int     `gorm:"default:0" json:"total_advertisers"`
+	TotalConversions int     `gorm:"default:0" json:"total_conversions"`
+	Currency         string  `gorm:"type:varchar(3);default:'USD'" json:"currency"`
+
 	// الحالة
-	Status            string     `gorm:"type:varchar(20);default:'draft'" json:"status"`
-	
+	Status string `gorm:"type:varchar(20);default:'draft'" json:"status"`
+
 	// Payoneer Integration
-	PayoneerBatchID   string     `gorm:"type:varchar(100)" json:"payoneer_batch_id,omitempty"`
-	PayoneerStatus    string     `gorm:"type:varchar(50)" json:"payoneer_status,omitempty"`
-	PayoneerError     string     `gorm:"type:text" json:"payoneer_error,omitempty"`
-	PayoneerResponse  string     `gorm:"type:jsonb" json:"payoneer_response,omitempty"`
-	
+	PayoneerBatchID  string `gorm:"type:varchar(100)" json:"payoneer_batch_id,omitempty"`
+	PayoneerStatus   string `gorm:"type:varchar(50)" json:"payoneer_status,omitempty"`
+	PayoneerError    string `gorm:"type:text" json:"payoneer_error,omitempty"`
+	PayoneerResponse string `gorm:"type:jsonb" json:"payoneer_response,omitempty"`
+
 	// التواريخ
-	CreatedAt         time.Time  `json:"created_at"`
-	UpdatedAt         time.Time  `json:"updated_at"`
-	SubmittedAt       *time.Time `json:"submitted_at,omitempty"`
-	CompletedAt       *time.Time `json:"completed_at,omitempty"`
-	
-	// من أنشأ الدفعة
-	CreatedByID       *uuid.UUID `gorm:"type:uuid" json:"created_by_id,omitempty"`
-	
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	ApprovedAt  *time.Time `json:"approved_at,omitempty"`
+	SubmittedAt *time.Time `json:"submitted_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+
+	// من أنشأ الدفعة ومن وافق عليها
+	CreatedByID  *uuid.UUID `gorm:"type:uuid" json:"created_by_id,omitempty"`
+	ApprovedByID *uuid.UUID `gorm:"type:uuid" json:"approved_by_id,omitempty"`
+
+	// الإحصائيات حسب الحالة
+	HeldPayouts    int `gorm:"default:0" json:"held_payouts"`
+	CarriedPayouts int `gorm:"default:0" json:"carried_payouts"`
+
 	// ملاحظات
-	Notes             string     `gorm:"type:text" json:"notes,omitempty"`
-	
+	Notes string `gorm:"type:text" json:"notes,omitempty"`
+
 	// العلاقات
-	Payouts           []Payout   `gorm:"foreignKey:BatchID" json:"payouts,omitempty"`
+	Payouts []Payout `gorm:"foreignKey:BatchID" json:"payouts,omitempty"`
 }
 
 // TableName specifies the table name
@@ -146,17 +182,88 @@ func (pb *PayoutBatch) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// PayoutEvent Action Constants
+const (
+	PayoutEventGenerated = "generated"
+	PayoutEventHeld      = "held"
+	PayoutEventReleased  = "released"
+	PayoutEventCarried   = "carried_over"
+	PayoutEventApproved  = "approved"
+	PayoutEventSubmitted = "submitted"
+	PayoutEventPaid      = "paid"
+	PayoutEventFailed    = "failed"
+	PayoutEventCompleted = "completed"
+	PayoutEventCancelled = "cancelled"
+)
+
+// PayoutEvent is one entry of the payout audit trail: every state change of a
+// batch or of a single payout line is recorded here
+// سجل التدقيق - كل تغيير في حالة دفعة أو سطر دفع
+type PayoutEvent struct {
+	TenantModel
+	ID         uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BatchID    *uuid.UUID     `gorm:"type:uuid;index" json:"batch_id,omitempty"`
+	PayoutID   *uuid.UUID     `gorm:"type:uuid;index" json:"payout_id,omitempty"` // فارغ لأحداث الدفعة
+	Action     string         `gorm:"type:varchar(30);not null;index" json:"action"`
+	FromStatus string         `gorm:"type:varchar(20)" json:"from_status,omitempty"`
+	ToStatus   string         `gorm:"type:varchar(20)" json:"to_status,omitempty"`
+	ActorID    *uuid.UUID     `gorm:"type:uuid" json:"actor_id,omitempty"` // فارغ للنظام
+	Note       string         `gorm:"type:text" json:"note,omitempty"`
+	Data       datatypes.JSON `gorm:"type:jsonb" json:"data,omitempty"`
+	CreatedAt  time.Time      `gorm:"index" json:"created_at"`
+}
+
+// TableName specifies the table name
+func (PayoutEvent) TableName() string {
+	return "payout_events"
+}
+
+// PayoutRailSubmission Status Constants
+const (
+	RailSubmissionSubmitted = "submitted" // أُرسلت للقناة - بانتظار النتائج
+	RailSubmissionCompleted = "completed" // كل الأسطر تمت تسويتها
+	RailSubmissionCancelled = "cancelled" // ألغيت لدى القناة
+	RailSubmissionError     = "error"     // فشل الإرسال - يمكن إعادة المحاولة
+)
+
+// PayoutRailSubmission is the part of a batch sent through one payment rail.
+// Reference is our idempotency key for the rail; ExternalRef is the rail's
+// own batch ID. File rails keep the exported transfer file.
+// الجزء من الدفعة المرسل عبر قناة دفع واحدة
+type PayoutRailSubmission struct {
+	TenantModel
+	ID           uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BatchID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"batch_id"`
+	Rail         string     `gorm:"type:varchar(20);not null" json:"rail"`
+	Reference    string     `gorm:"type:varchar(100);not null" json:"reference"`
+	ExternalRef  string     `gorm:"type:varchar(100);index" json:"external_ref,omitempty"`
+	Status       string     `gorm:"type:varchar(20);not null;default:'submitted'" json:"status"`
+	Items        int        `gorm:"default:0" json:"items"`
+	FileName     string     `gorm:"type:varchar(100)" json:"file_name,omitempty"`
+	FileType     string     `gorm:"type:varchar(50)" json:"file_type,omitempty"`
+	FileContent  []byte     `gorm:"type:bytea" json:"-"`
+	Error        string     `gorm:"type:text" json:"error,omitempty"`
+	LastPolledAt *time.Time `json:"last_polled_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name
+func (PayoutRailSubmission) TableName() string {
+	return "payout_rail_submissions"
+}
+
 // PayoutSummary for dashboard display
 type PayoutSummary struct {
-	TotalPayouts      int     `json:"total_payouts"`
-	TotalAmount       float64 `json:"total_amount"`
-	TotalPlatformFee  float64 `json:"total_platform_fee"`
-	PendingAmount     float64 `json:"pending_amount"`
-	PaidAmount        float64 `json:"paid_amount"`
-	PendingCount      int     `json:"pending_count"`
-	PaidCount         int     `json:"paid_count"`
-	TotalPublishers   int     `json:"total_publishers"`
-	TotalAdvertisers  int     `json:"total_advertisers"`
+	TotalPayouts     int     `json:"total_payouts"`
+	TotalAmount      float64 `json:"total_amount"`
+	TotalPlatformFee float64 `json:"total_platform_fee"`
+	PendingAmount    float64 `json:"pending_amount"`
+	PaidAmount       float64 `json:"paid_amount"`
+	PendingCount     int     `json:"pending_count"`
+	PaidCount        int     `json:"paid_count"`
+	TotalPublishers  int     `json:"total_publishers"`
+	TotalAdvertisers int     `json:"total_advertisers"`
 }
 
 // PayoutRequest for generating payouts
@@ -181,4 +288,3 @@ type ExportPayoutCSV struct {
 	Period             string  `json:"period"`
 	ConversionsCount   int     `json:"conversions_count"`
 }
-
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Payout rails - قنوات الدفع
+const (
+	PayoutRailPayoneer     = "payoneer"
+	PayoutRailPayPal       = "paypal"
+	PayoutRailBankTransfer = "bank_transfer" // ملف تحويل بنكي يُرفع للبنك
+	PayoutRailWise         = "wise"          // ملف دفعات Wise
+	PayoutRailManual       = "manual"        // يدفع المعلن مباشرة ويسجل المسؤول النتيجة
+)
+
+// PayoutMethod Status Constants
+const (
+	PayoutMethodPending  = "pending"
+	PayoutMethodVerified = "verified"
+	PayoutMethodInvalid  = "invalid"
+)
+
+// PayoutMethod is a promoter's destination on one payout rail
+// وسيلة استلام الأرباح الخاصة بالمروج (بايونير، باي بال، تحويل بنكي، وايز)
+type PayoutMethod struct {
+	TenantModel
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	Rail      string    `gorm:"type:varchar(20);not null" json:"rail"`
+	IsDefault bool      `gorm:"default:false" json:"is_default"`
+	Status    string    `gorm:"type:varchar(20);default:'pending'" json:"status"`
+
+	// Wallet rails (payoneer, paypal, wise)
+	Email   string `gorm:"type:varchar(255)" json:"email,omitempty"`
+	PayeeID string `gorm:"type:varchar(100)" json:"payee_id,omitempty"` // معرف المستلم لدى Payoneer
+
+	// Bank rails (bank_transfer, wise)
+	AccountName string `gorm:"type:varchar(150)" json:"account_name,omitempty"`
+	IBAN        string `gorm:"type:varchar(40)" json:"iban,omitempty"`
+	BIC         string `gorm:"type:varchar(11)" json:"bic,omitempty"`
+	BankName    string `gorm:"type:varchar(150)" json:"bank_name,omitempty"`
+	Country     string `gorm:"type:varchar(2)" json:"country,omitempty"`
+	Currency    string `gorm:"type:varchar(3)" json:"currency,omitempty"`
+
+	ValidationError string     `gorm:"type:text" json:"validation_error,omitempty"`
+	VerifiedAt      *time.Time `json:"verified_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name
+func (PayoutMethod) TableName() string {
+	return "payout_methods"
+}
@@ -4,9 +4,11 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type PromoterRating struct {
+	TenantModel
 	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	PromoterID uuid.UUID `gorm:"type:uuid;not null" json:"promoter_id"`
 	VisitorIP  string    `gorm:"type:varchar(45)" json:"visitor_ip"`
@@ -17,3 +19,11 @@ type PromoterRating struct {
 func (PromoterRating) TableName() string {
 	return "promoter_ratings"
 }
+
+// BeforeCreate assigns the rating to the promoter's tenant
+func (r *PromoterRating) BeforeCreate(tx *gorm.DB) error {
+	if r.TenantID == uuid.Nil {
+		r.TenantID = inheritTenantID(tx, "afftok_users", r.PromoterID)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ============================================
+// REFERRALS & OVERRIDE COMMISSIONS
+// ============================================
+// A promoter who signs up with another promoter's code is that promoter's
+// referee. Referrers (up to the tenant's configured depth) and team owners
+// earn override commissions on their referees' and members' approved
+// conversions. Overrides are funded from the platform fee and posted as
+// separate ledger transactions that reference the source conversion.
+
+// Referral status constants
+const (
+	ReferralStatusActive   = "active"
+	ReferralStatusExcluded = "excluded" // no overrides are paid on this referral
+)
+
+// OverrideType is why a promoter earns an override
+type OverrideType string
+
+const (
+	OverrideTypeReferral OverrideType = "referral"
+	OverrideTypeTeam     OverrideType = "team"
+)
+
+// Referral links a referee to the promoter whose code they signed up with
+type Referral struct {
+	TenantModel
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ReferrerID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"referrer_id"`
+	RefereeID      uuid.UUID  `gorm:"type:uuid;not null" json:"referee_id"` // unique per tenant
+	Code           string     `gorm:"type:varchar(20)" json:"code"`
+	Status         string     `gorm:"type:varchar(20);not null;default:'active'" json:"status"`
+	ExcludedReason string     `gorm:"type:varchar(255)" json:"excluded_reason,omitempty"`
+	ExcludedBy     *uuid.UUID `gorm:"type:uuid" json:"excluded_by,omitempty"`
+	ExcludedAt     *time.Time `json:"excluded_at,omitempty"`
+	CreatedAt      time.Time  `gorm:"index" json:"created_at"`
+
+	// Relationships
+	Referrer *AfftokUser `gorm:"foreignKey:ReferrerID" json:"referrer,omitempty"`
+	Referee  *AfftokUser `gorm:"foreignKey:RefereeID" json:"referee,omitempty"`
+}
+
+func (Referral) TableName() string {
+	return "promoter_referrals"
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Tax entity types a promoter can declare
+const (
+	TaxEntityIndividual     = "individual"
+	TaxEntitySoleProprietor = "sole_proprietor"
+	TaxEntityCompany        = "company"
+	TaxEntityPartnership    = "partnership"
+	TaxEntityNonProfit      = "non_profit"
+)
+
+// TaxEntityTypes lists the valid entity types
+var TaxEntityTypes = []string{
+	TaxEntityIndividual, TaxEntitySoleProprietor, TaxEntityCompany, TaxEntityPartnership, TaxEntityNonProfit,
+}
+
+// PromoterTaxProfile holds the tax details a promoter declares for their
+// earnings statements and year-end documents. One profile per promoter
+// and tenant.
+type PromoterTaxProfile struct {
+	TenantModel
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Country     string     `gorm:"type:varchar(2);not null;index" json:"country"` // ISO 3166-1 alpha-2
+	EntityType  string     `gorm:"type:varchar(20);not null" json:"entity_type"`
+	LegalName   string     `gorm:"type:varchar(200);not null" json:"legal_name"`
+	TaxID       string     `gorm:"type:varchar(50);not null" json:"-"` // never returned in full
+	VATNumber   string     `gorm:"type:varchar(50)" json:"vat_number,omitempty"`
+	Address     string     `gorm:"type:text" json:"address,omitempty"`
+	CertifiedAt *time.Time `json:"certified_at,omitempty"` // promoter confirmed the details are correct
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (PromoterTaxProfile) TableName() string {
+	return "promoter_tax_profiles"
+}
+
+// MaskedTaxID returns the tax ID with all but the last four characters hidden
+func (p *PromoterTaxProfile) MaskedTaxID() string {
+	id := []rune(p.TaxID)
+	if len(id) <= 4 {
+		return strings.Repeat("•", len(id))
+	}
+	return strings.Repeat("•", len(id)-4) + string(id[len(id)-4:])
+}
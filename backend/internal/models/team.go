@@ -8,6 +8,7 @@ import (
 
 // Team represents a group of affiliate marketers
 type Team struct {
+	TenantModel
 	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	Name        string    `gorm:"type:varchar(100);not null" json:"name"`
 	Description string    `gorm:"type:text" json:"description,omitempty"`
@@ -41,6 +42,7 @@ func (Team) TableName() string {
 
 // TeamMember represents a user's membership in a team
 type TeamMember struct {
+	TenantModel
 	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	TeamID    uuid.UUID `gorm:"type:uuid;not null" json:"team_id"`
 	UserID    uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
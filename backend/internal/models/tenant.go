@@ -5,6 +5,7 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/datatypes"
+	"gorm.io/gorm"
 )
 
 // ============================================
@@ -64,6 +65,7 @@ type Tenant struct {
 	MaxClicksPerDay  int            `json:"max_clicks_per_day" gorm:"default:10000"`
 	MaxAPIKeys       int            `json:"max_api_keys" gorm:"default:5"`
 	MaxWebhooks      int            `json:"max_webhooks" gorm:"default:10"`
+	LimitGracePercent int           `json:"limit_grace_percent" gorm:"default:10"` // hard limit = limit + grace%
 	
 	// Feature Flags
 	Features         datatypes.JSON `json:"features,omitempty" gorm:"type:jsonb"`
@@ -96,6 +98,10 @@ type TenantSettings struct {
 	EnableGeoRules       bool   `json:"enable_geo_rules"`
 	EnableFraudDetection bool   `json:"enable_fraud_detection"`
 	
+	// Bot Challenge Settings (risk range that gets a JS challenge instead of a block)
+	BotChallengeMinRisk  int    `json:"bot_challenge_min_risk"`
+	BotChallengeMaxRisk  int    `json:"bot_challenge_max_risk"`
+	
 	// Webhook Settings
 	WebhookRetryCount    int    `json:"webhook_retry_count"`
 	WebhookTimeoutMs     int    `json:"webhook_timeout_ms"`
@@ -110,6 +116,29 @@ type TenantSettings struct {
 	
 	// Timezone
 	Timezone             string `json:"timezone"`
+
+	// Currency money is reported in when an account has none of its own
+	ReportingCurrency    string `json:"reporting_currency"`
+
+	// Promoter countries that need a tax profile before payouts ("*" = all)
+	TaxProfileCountries  []string `json:"tax_profile_countries"`
+
+	// Override commissions, in basis points of the source commission.
+	// ReferralOverrideBps has one rate per level: the referee's referrer,
+	// that referrer's referrer, ... Overrides are paid for
+	// ReferralOverrideMonths after the referee signed up. All overrides of a
+	// conversion together never exceed OverrideCapBps nor its platform fee.
+	ReferralOverrideBps    []int64 `json:"referral_override_bps"`
+	ReferralOverrideMonths int     `json:"referral_override_months"`
+	TeamOverrideBps        int64   `json:"team_override_bps"` // team owner's share of members' commissions
+	OverrideCapBps         int64   `json:"override_cap_bps"`
+
+	// Advertiser invoice collections. Reminders are emailed on each of
+	// InvoiceReminderDays relative to the due date (-3 = three days before,
+	// 0 = on the day, 7 = a week late). Offers of an advertiser with an
+	// invoice InvoicePauseAfterDays overdue are paused (0 = never).
+	InvoiceReminderDays   []int `json:"invoice_reminder_days"`
+	InvoicePauseAfterDays int   `json:"invoice_pause_after_days"`
 }
 
 // DefaultTenantSettings returns default settings for a new tenant
@@ -120,12 +149,19 @@ func DefaultTenantSettings() TenantSettings {
 		EnableBotDetection:   true,
 		EnableGeoRules:       true,
 		EnableFraudDetection: true,
+		BotChallengeMinRisk:  40,
+		BotChallengeMaxRisk:  85,
 		WebhookRetryCount:    5,
 		WebhookTimeoutMs:     30000,
 		APIRateLimitPerMin:   60,
 		NotifyOnConversion:   true,
 		NotifyOnFraud:        true,
 		Timezone:             "UTC",
+		ReportingCurrency:    "USD",
+		TaxProfileCountries:  []string{"US"},
+		ReferralOverrideMonths: 12,
+		OverrideCapBps:         2000,
+		InvoiceReminderDays:    []int{-3, 0, 3, 7},
 	}
 }
 
@@ -208,28 +244,79 @@ func GetLimitsForPlan(plan TenantPlan) (maxUsers, maxOffers, maxClicks, maxAPIKe
 	}
 }
 
+// GetLimitGracePercentForPlan returns how far past its soft limits a plan may
+// go before creation is blocked (hard limit = limit + grace%)
+func GetLimitGracePercentForPlan(plan TenantPlan) int {
+	switch plan {
+	case TenantPlanFree:
+		return 0
+	case TenantPlanPro:
+		return 10
+	case TenantPlanEnterprise:
+		return 25
+	default:
+		return GetLimitGracePercentForPlan(TenantPlanFree)
+	}
+}
+
 // ============================================
 // TENANT DOMAIN MAPPING
 // ============================================
 
-// TenantDomain represents a domain mapping for a tenant
+// Domain verification methods
+const (
+	DomainVerifyTXT   = "txt"   // _afftok-verify.<domain> TXT record carries the token
+	DomainVerifyCNAME = "cname" // domain is a CNAME to the token-bound platform host
+)
+
+// Certificate status for custom domains
+const (
+	DomainCertNone    = "none"    // لم يتم طلب شهادة بعد
+	DomainCertPending = "pending" // قيد الإصدار عبر ACME
+	DomainCertIssued  = "issued"  // شهادة صالحة
+	DomainCertFailed  = "failed"  // فشل الإصدار أو التجديد
+)
+
+// TenantDomain represents a domain mapping for a tenant.
+// A domain only routes to its tenant once ownership has been proven through
+// DNS (VerificationToken in a TXT record, or a CNAME to <token>.<platform host>).
 type TenantDomain struct {
-	ID         uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
-	TenantID   uuid.UUID  `json:"tenant_id" gorm:"type:uuid;not null;index"`
-	Domain     string     `json:"domain" gorm:"size:255;uniqueIndex;not null"`
-	IsPrimary  bool       `json:"is_primary" gorm:"default:false"`
-	IsVerified bool       `json:"is_verified" gorm:"default:false"`
-	VerifiedAt *time.Time `json:"verified_at,omitempty"`
-	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
-	
+	ID                 uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	TenantID           uuid.UUID  `json:"tenant_id" gorm:"type:uuid;not null;index"`
+	Domain             string     `json:"domain" gorm:"size:255;uniqueIndex;not null"`
+	IsPrimary          bool       `json:"is_primary" gorm:"default:false"`
+	IsVerified         bool       `json:"is_verified" gorm:"default:false;index"`
+	VerifiedAt         *time.Time `json:"verified_at,omitempty"`
+	VerificationToken  string     `json:"verification_token" gorm:"size:100"`
+	VerificationMethod string     `json:"verification_method,omitempty" gorm:"size:10"`
+	LastCheckedAt      *time.Time `json:"last_checked_at,omitempty"`
+	LastCheckError     string     `json:"last_check_error,omitempty" gorm:"size:500"`
+	CheckFailures      int        `json:"check_failures" gorm:"default:0"` // consecutive failed re-checks
+	CertStatus         string     `json:"cert_status" gorm:"size:20;default:'none'"`
+	CertExpiresAt      *time.Time `json:"cert_expires_at,omitempty"`
+	CertError          string     `json:"cert_error,omitempty" gorm:"size:500"`
+	CreatedAt          time.Time  `json:"created_at" gorm:"autoCreateTime"`
+
 	// Relations
-	Tenant     *Tenant    `json:"tenant,omitempty" gorm:"foreignKey:TenantID"`
+	Tenant *Tenant `json:"tenant,omitempty" gorm:"foreignKey:TenantID"`
 }
 
 func (TenantDomain) TableName() string {
 	return "tenant_domains"
 }
 
+// TenantCertificate stores ACME account keys and TLS certificates for custom
+// domains (the autocert cache), so every instance serves the same certificates
+type TenantCertificate struct {
+	Key       string    `json:"key" gorm:"primaryKey;size:255"`
+	Data      []byte    `json:"-" gorm:"type:bytea;not null"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (TenantCertificate) TableName() string {
+	return "tenant_certificates"
+}
+
 // ============================================
 // TENANT STATS
 // ============================================
@@ -266,7 +353,11 @@ const (
 	TenantAuditPlanChanged   TenantAuditAction = "plan_changed"
 	TenantAuditDomainAdded   TenantAuditAction = "domain_added"
 	TenantAuditDomainRemoved TenantAuditAction = "domain_removed"
+	TenantAuditDomainVerified   TenantAuditAction = "domain_verified"
+	TenantAuditDomainUnverified TenantAuditAction = "domain_unverified"
 	TenantAuditSettingsChanged TenantAuditAction = "settings_changed"
+	TenantAuditUsageWarning    TenantAuditAction = "usage_limit_warning"
+	TenantAuditUsageExceeded   TenantAuditAction = "usage_limit_exceeded"
 )
 
 // TenantAuditLog represents an audit log entry for tenant changes
@@ -300,6 +391,39 @@ type TenantScoped interface {
 	SetTenantID(tenantID uuid.UUID)
 }
 
+// TenantModel is embedded by business models that belong to a tenant.
+// Rows created before multi-tenancy are backfilled with DefaultTenantID.
+type TenantModel struct {
+	TenantID uuid.UUID `gorm:"type:uuid;index;default:'00000000-0000-0000-0000-000000000001'" json:"tenant_id"`
+}
+
+// GetTenantID returns the owning tenant
+func (m *TenantModel) GetTenantID() uuid.UUID {
+	return m.TenantID
+}
+
+// SetTenantID sets the owning tenant
+func (m *TenantModel) SetTenantID(tenantID uuid.UUID) {
+	m.TenantID = tenantID
+}
+
+// inheritTenantID looks up the tenant of a parent row. Rows created outside a
+// tenant-scoped handle (click tracking, postbacks, workers) use it to land in
+// the same tenant as the record they hang off.
+func inheritTenantID(tx *gorm.DB, table string, id uuid.UUID) uuid.UUID {
+	var tenantID uuid.UUID
+	if id == uuid.Nil {
+		return tenantID
+	}
+	tx.Session(&gorm.Session{NewDB: true}).
+		Table(table).
+		Select("tenant_id").
+		Where("id = ?", id).
+		Limit(1).
+		Scan(&tenantID)
+	return tenantID
+}
+
 // ============================================
 // DEFAULT TENANT (for migration)
 // ============================================
@@ -0,0 +1,227 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// TENANT BILLING
+// ============================================
+// Platform billing of tenants (plan subscriptions + usage overage). This is
+// separate from advertiser Invoice, which bills advertisers inside a tenant.
+
+// BillingCycle is how often a subscription renews
+type BillingCycle string
+
+const (
+	BillingCycleMonthly BillingCycle = "monthly"
+	BillingCycleAnnual  BillingCycle = "annual"
+)
+
+// BillingCurrency is the currency of all tenant invoices
+const BillingCurrency = "USD"
+
+// PlanBilling is the price list of a plan. Amounts are in cents.
+type PlanBilling struct {
+	Plan                       TenantPlan `json:"plan"`
+	Currency                   string     `json:"currency"`
+	MonthlyCents               int64      `json:"monthly_cents"`
+	AnnualCents                int64      `json:"annual_cents"`
+	IncludedAPICalls           int64      `json:"included_api_calls"`          // per month
+	IncludedWebhookDeliveries  int64      `json:"included_webhook_deliveries"` // per month
+	IncludedStorageBytes       int64      `json:"included_storage_bytes"`
+	ClickOveragePer1000Cents   int64      `json:"click_overage_per_1000_cents"` // clicks above the daily limit
+	APICallOveragePer1000Cents int64      `json:"api_call_overage_per_1000_cents"`
+	WebhookOveragePer1000Cents int64      `json:"webhook_overage_per_1000_cents"`
+	StorageOveragePerGBCents   int64      `json:"storage_overage_per_gb_cents"`
+}
+
+// PriceFor returns the plan price for a billing cycle
+func (p PlanBilling) PriceFor(cycle BillingCycle) int64 {
+	if cycle == BillingCycleAnnual {
+		return p.AnnualCents
+	}
+	return p.MonthlyCents
+}
+
+// GetPlanBilling returns the price list for a plan
+func GetPlanBilling(plan TenantPlan) PlanBilling {
+	const gb = int64(1 << 30)
+	switch plan {
+	case TenantPlanFree:
+		return PlanBilling{
+			Plan:                      TenantPlanFree,
+			Currency:                  BillingCurrency,
+			IncludedAPICalls:          10000,
+			IncludedWebhookDeliveries: 0,
+			IncludedStorageBytes:      1 * gb,
+			// Free tenants are hard-limited (no grace), so nothing to bill
+		}
+	case TenantPlanPro:
+		return PlanBilling{
+			Plan:                       TenantPlanPro,
+			Currency:                   BillingCurrency,
+			MonthlyCents:               9900,
+			AnnualCents:                99000,
+			IncludedAPICalls:           500000,
+			IncludedWebhookDeliveries:  200000,
+			IncludedStorageBytes:       20 * gb,
+			ClickOveragePer1000Cents:   50,
+			APICallOveragePer1000Cents: 20,
+			WebhookOveragePer1000Cents: 30,
+			StorageOveragePerGBCents:   25,
+		}
+	case TenantPlanEnterprise:
+		return PlanBilling{
+			Plan:                       TenantPlanEnterprise,
+			Currency:                   BillingCurrency,
+			MonthlyCents:               49900,
+			AnnualCents:                499000,
+			IncludedAPICalls:           10000000,
+			IncludedWebhookDeliveries:  5000000,
+			IncludedStorageBytes:       500 * gb,
+			ClickOveragePer1000Cents:   25,
+			APICallOveragePer1000Cents: 10,
+			WebhookOveragePer1000Cents: 15,
+			StorageOveragePerGBCents:   15,
+		}
+	default:
+		return GetPlanBilling(TenantPlanFree)
+	}
+}
+
+// ============================================
+// SUBSCRIPTION
+// ============================================
+
+// SubscriptionStatus is the billing state of a tenant
+type SubscriptionStatus string
+
+const (
+	SubscriptionActive    SubscriptionStatus = "active"
+	SubscriptionPastDue   SubscriptionStatus = "past_due"  // فاتورة غير مدفوعة - قيد المطالبة
+	SubscriptionSuspended SubscriptionStatus = "suspended" // تم إيقاف المستأجر بعد انتهاء المطالبة
+	SubscriptionCanceled  SubscriptionStatus = "canceled"
+)
+
+// TenantSubscription is a tenant's current plan subscription
+type TenantSubscription struct {
+	TenantID             uuid.UUID          `json:"tenant_id" gorm:"type:uuid;primaryKey"`
+	Plan                 TenantPlan         `json:"plan" gorm:"size:20;not null"`
+	Cycle                BillingCycle       `json:"cycle" gorm:"size:20;not null;default:'monthly'"`
+	Status               SubscriptionStatus `json:"status" gorm:"size:20;not null;default:'active';index"`
+	Provider             string             `json:"provider,omitempty" gorm:"size:20"`
+	CurrentPeriodStart   time.Time          `json:"current_period_start"`
+	CurrentPeriodEnd     time.Time          `json:"current_period_end" gorm:"index"`
+	OverageBilledThrough time.Time          `json:"overage_billed_through"`
+	CancelAtPeriodEnd    bool               `json:"cancel_at_period_end" gorm:"default:false"`
+	CreditCents          int64              `json:"credit_cents" gorm:"default:0"` // unused proration credit
+	CanceledAt           *time.Time         `json:"canceled_at,omitempty"`
+	CreatedAt            time.Time          `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt            time.Time          `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (TenantSubscription) TableName() string {
+	return "tenant_subscriptions"
+}
+
+// ============================================
+// TENANT INVOICES
+// ============================================
+
+// TenantInvoiceStatus is the state of a tenant invoice
+type TenantInvoiceStatus string
+
+const (
+	TenantInvoiceOpen          TenantInvoiceStatus = "open"
+	TenantInvoicePaid          TenantInvoiceStatus = "paid"
+	TenantInvoiceVoid          TenantInvoiceStatus = "void"
+	TenantInvoiceUncollectible TenantInvoiceStatus = "uncollectible" // انتهت محاولات التحصيل
+)
+
+// Tenant invoice line kinds
+const (
+	TenantInvoiceLineSubscription    = "subscription"
+	TenantInvoiceLineProrationCredit = "proration_credit"
+	TenantInvoiceLineProrationCharge = "proration_charge"
+	TenantInvoiceLineOverage         = "overage"
+	TenantInvoiceLineCredit          = "credit" // carried proration credit
+)
+
+// TenantInvoice is a platform invoice to a tenant
+type TenantInvoice struct {
+	TenantModel
+	ID                uuid.UUID           `json:"id" gorm:"type:uuid;primaryKey"`
+	Number            string              `json:"number" gorm:"size:32;uniqueIndex"`
+	Status            TenantInvoiceStatus `json:"status" gorm:"size:20;not null;default:'open';index"`
+	Reason            string              `json:"reason" gorm:"size:30"` // subscription_create, subscription_cycle, plan_change, overage
+	Currency          string              `json:"currency" gorm:"size:3;not null"`
+	PeriodStart       time.Time           `json:"period_start"`
+	PeriodEnd         time.Time           `json:"period_end"`
+	SubtotalCents     int64               `json:"subtotal_cents"`
+	TotalCents        int64               `json:"total_cents"`
+	DueAt             time.Time           `json:"due_at"`
+	PaidAt            *time.Time          `json:"paid_at,omitempty"`
+	Attempts          int                 `json:"attempts" gorm:"default:0"`
+	NextAttemptAt     *time.Time          `json:"next_attempt_at,omitempty" gorm:"index"`
+	Provider          string              `json:"provider,omitempty" gorm:"size:20"`
+	ProviderPaymentID string              `json:"provider_payment_id,omitempty" gorm:"size:100;index"`
+	LastError         string              `json:"last_error,omitempty" gorm:"size:500"`
+	CreatedAt         time.Time           `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt         time.Time           `json:"updated_at" gorm:"autoUpdateTime"`
+
+	Lines []TenantInvoiceLine `json:"lines,omitempty" gorm:"foreignKey:InvoiceID"`
+}
+
+func (TenantInvoice) TableName() string {
+	return "tenant_invoices"
+}
+
+// BeforeCreate generates UUID before creating
+func (i *TenantInvoice) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
+
+// TenantInvoiceLine is one charge or credit on a tenant invoice
+type TenantInvoiceLine struct {
+	ID              uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	InvoiceID       uuid.UUID `json:"invoice_id" gorm:"type:uuid;not null;index"`
+	Kind            string    `json:"kind" gorm:"size:30;not null"`
+	Description     string    `json:"description" gorm:"size:255"`
+	Metric          string    `json:"metric,omitempty" gorm:"size:30"`
+	Quantity        int64     `json:"quantity"`
+	UnitAmountCents int64     `json:"unit_amount_cents"`
+	AmountCents     int64     `json:"amount_cents"`
+	CreatedAt       time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (TenantInvoiceLine) TableName() string {
+	return "tenant_invoice_lines"
+}
+
+// BeforeCreate generates UUID before creating
+func (l *TenantInvoiceLine) BeforeCreate(tx *gorm.DB) error {
+	if l.ID == uuid.Nil {
+		l.ID = uuid.New()
+	}
+	return nil
+}
+
+// BillingWebhookEvent records processed payment provider events so
+// redelivered webhooks are ignored
+type BillingWebhookEvent struct {
+	ID          string    `json:"id" gorm:"size:100;primaryKey"` // provider event ID
+	Provider    string    `json:"provider" gorm:"size:20;primaryKey"`
+	Type        string    `json:"type" gorm:"size:60"`
+	ProcessedAt time.Time `json:"processed_at" gorm:"autoCreateTime"`
+}
+
+func (BillingWebhookEvent) TableName() string {
+	return "billing_webhook_events"
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// TENANT OFFBOARDING
+// ============================================
+// Data export (hand a leaving customer their data) and hard delete (GDPR
+// erasure) of a tenant. Both run as background jobs.
+
+// TenantJobStatus is the state of an offboarding job
+type TenantJobStatus string
+
+const (
+	TenantJobPending   TenantJobStatus = "pending"
+	TenantJobScheduled TenantJobStatus = "scheduled" // حذف بانتظار انتهاء فترة التراجع
+	TenantJobRunning   TenantJobStatus = "running"
+	TenantJobCompleted TenantJobStatus = "completed"
+	TenantJobFailed    TenantJobStatus = "failed"
+	TenantJobCanceled  TenantJobStatus = "canceled"
+	TenantJobExpired   TenantJobStatus = "expired" // export file removed
+)
+
+// TenantExportFormat is the file format of exported tables
+type TenantExportFormat string
+
+const (
+	TenantExportNDJSON TenantExportFormat = "ndjson"
+	TenantExportCSV    TenantExportFormat = "csv"
+)
+
+// Offboarding audit actions
+const (
+	TenantAuditExportRequested   TenantAuditAction = "export_requested"
+	TenantAuditExportCompleted   TenantAuditAction = "export_completed"
+	TenantAuditDeletionScheduled TenantAuditAction = "deletion_scheduled"
+	TenantAuditDeletionCanceled  TenantAuditAction = "deletion_canceled"
+	TenantAuditHardDeleted       TenantAuditAction = "hard_deleted"
+)
+
+// TenantExportJob is an asynchronous export of all tenant data to a zip
+type TenantExportJob struct {
+	ID          uuid.UUID          `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID    uuid.UUID          `json:"tenant_id" gorm:"type:uuid;not null;index"`
+	Status      TenantJobStatus    `json:"status" gorm:"size:20;not null;default:'pending';index"`
+	Format      TenantExportFormat `json:"format" gorm:"size:10;not null;default:'ndjson'"`
+	RequestedBy *uuid.UUID         `json:"requested_by,omitempty" gorm:"type:uuid"`
+	FilePath    string             `json:"-" gorm:"size:500"`
+	SizeBytes   int64              `json:"size_bytes"`
+	SHA256      string             `json:"sha256,omitempty" gorm:"size:64"`
+	Tables      int                `json:"tables"`
+	Rows        int64              `json:"rows"`
+	Error       string             `json:"error,omitempty" gorm:"size:500"`
+	StartedAt   *time.Time         `json:"started_at,omitempty"`
+	CompletedAt *time.Time         `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time         `json:"expires_at,omitempty" gorm:"index"`
+	CreatedAt   time.Time          `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time          `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (TenantExportJob) TableName() string {
+	return "tenant_export_jobs"
+}
+
+// BeforeCreate generates UUID before creating
+func (j *TenantExportJob) BeforeCreate(tx *gorm.DB) error {
+	if j.ID == uuid.Nil {
+		j.ID = uuid.New()
+	}
+	return nil
+}
+
+// TenantDeletionJob is a scheduled hard delete of a tenant. Until
+// ScheduledFor passes it can be canceled and the tenant restored.
+type TenantDeletionJob struct {
+	ID                uuid.UUID       `json:"id" gorm:"type:uuid;primaryKey"`
+	TenantID          uuid.UUID       `json:"tenant_id" gorm:"type:uuid;not null;index"`
+	Status            TenantJobStatus `json:"status" gorm:"size:20;not null;default:'scheduled';index"`
+	Reason            string          `json:"reason,omitempty" gorm:"size:500"`
+	RequestedBy       *uuid.UUID      `json:"requested_by,omitempty" gorm:"type:uuid"`
+	PreviousStatus    TenantStatus    `json:"previous_status" gorm:"size:20"` // restored on cancel
+	ScheduledFor      time.Time       `json:"scheduled_for" gorm:"index"`
+	RowsDeleted       int64           `json:"rows_deleted"`
+	Summary           datatypes.JSON  `json:"summary,omitempty" gorm:"type:jsonb"` // table -> rows deleted
+	WALEntriesRemoved int             `json:"wal_entries_removed"`
+	CacheCleared      bool            `json:"cache_cleared"`
+	Error             string          `json:"error,omitempty" gorm:"size:500"`
+	StartedAt         *time.Time      `json:"started_at,omitempty"`
+	CompletedAt       *time.Time      `json:"completed_at,omitempty"`
+	CanceledAt        *time.Time      `json:"canceled_at,omitempty"`
+	CreatedAt         time.Time       `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt         time.Time       `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (TenantDeletionJob) TableName() string {
+	return "tenant_deletion_jobs"
+}
+
+// BeforeCreate generates UUID before creating
+func (j *TenantDeletionJob) BeforeCreate(tx *gorm.DB) error {
+	if j.ID == uuid.Nil {
+		j.ID = uuid.New()
+	}
+	return nil
+}
+
+// TenantExportManifest is manifest.json inside an export archive
+type TenantExportManifest struct {
+	TenantID    uuid.UUID                  `json:"tenant_id"`
+	TenantSlug  string                     `json:"tenant_slug"`
+	ExportID    uuid.UUID                  `json:"export_id"`
+	Format      TenantExportFormat         `json:"format"`
+	GeneratedAt time.Time                  `json:"generated_at"`
+	Files       []TenantExportManifestFile `json:"files"`
+	Redacted    []string                   `json:"redacted_columns"`
+}
+
+// TenantExportManifestFile describes one file in an export archive
+type TenantExportManifestFile struct {
+	Name   string `json:"name"`
+	Table  string `json:"table"`
+	Rows   int64  `json:"rows"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// ============================================
+// TENANT ONBOARDING
+// ============================================
+
+// Onboarding steps, in the order the wizard shows them
+const (
+	OnboardingStepVerifyEmail    = "verify_email"    // تأكيد البريد الإلكتروني للمسؤول
+	OnboardingStepCompanyProfile = "company_profile" // بيانات الشركة والفوترة
+	OnboardingStepBranding       = "branding"        // الشعار والألوان
+	OnboardingStepFirstOffer     = "first_offer"     // تفعيل أول عرض
+)
+
+// OnboardingSteps lists every step; the tenant activates when all are done
+var OnboardingSteps = []string{
+	OnboardingStepVerifyEmail,
+	OnboardingStepCompanyProfile,
+	OnboardingStepBranding,
+	OnboardingStepFirstOffer,
+}
+
+// TenantOnboarding tracks a self-service tenant through the signup wizard
+type TenantOnboarding struct {
+	TenantID            uuid.UUID      `json:"tenant_id" gorm:"type:uuid;primaryKey"`
+	AdminUserID         uuid.UUID      `json:"admin_user_id" gorm:"type:uuid;not null"`
+	Steps               datatypes.JSON `json:"steps" gorm:"type:jsonb"` // step -> completed_at
+	EmailTokenHash      string         `json:"-" gorm:"size:64;index"`
+	EmailTokenExpiresAt *time.Time     `json:"-"`
+	EmailVerifiedAt     *time.Time     `json:"email_verified_at,omitempty"`
+	CompletedAt         *time.Time     `json:"completed_at,omitempty"`
+	CreatedAt           time.Time      `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt           time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (TenantOnboarding) TableName() string {
+	return "tenant_onboardings"
+}
+
+// CompletedSteps returns the completion time of each finished step
+func (o *TenantOnboarding) CompletedSteps() map[string]time.Time {
+	steps := map[string]time.Time{}
+	if len(o.Steps) > 0 {
+		json.Unmarshal(o.Steps, &steps)
+	}
+	return steps
+}
+
+// MarkStep records a step as completed (first completion wins)
+func (o *TenantOnboarding) MarkStep(step string, at time.Time) {
+	steps := o.CompletedSteps()
+	if _, done := steps[step]; !done {
+		steps[step] = at
+	}
+	o.Steps, _ = json.Marshal(steps)
+}
+
+// IsComplete reports whether every onboarding step is done
+func (o *TenantOnboarding) IsComplete() bool {
+	steps := o.CompletedSteps()
+	for _, step := range OnboardingSteps {
+		if _, done := steps[step]; !done {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ============================================
+// TENANT PAGE TEMPLATES
+// ============================================
+// Public pages (promoter landing, team invite, error pages) are rendered from
+// built-in templates that a tenant can override per page and locale.
+
+// Page keys
+const (
+	PageTemplateLayout          = "layout"
+	PageTemplatePromoterLanding = "promoter_landing"
+	PageTemplateTeamInvite      = "team_invite"
+	PageTemplateError           = "error"
+)
+
+// PageTemplatePages lists the overridable pages
+var PageTemplatePages = []string{
+	PageTemplateLayout,
+	PageTemplatePromoterLanding,
+	PageTemplateTeamInvite,
+	PageTemplateError,
+}
+
+// Locales the pages are rendered in
+const (
+	PageLocaleArabic  = "ar"
+	PageLocaleEnglish = "en"
+)
+
+// PageTemplateLocales lists the supported locales, the first being the default
+var PageTemplateLocales = []string{PageLocaleArabic, PageLocaleEnglish}
+
+// Page template audit actions
+const (
+	TenantAuditPageTemplateUpdated TenantAuditAction = "page_template_updated"
+	TenantAuditPageTemplateDeleted TenantAuditAction = "page_template_deleted"
+)
+
+// TenantPageTemplate is a tenant's override of one page in one locale
+type TenantPageTemplate struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	TenantID  uuid.UUID  `json:"tenant_id" gorm:"type:uuid;not null;uniqueIndex:idx_tenant_page_template"`
+	Page      string     `json:"page" gorm:"size:50;not null;uniqueIndex:idx_tenant_page_template"`
+	Locale    string     `json:"locale" gorm:"size:10;not null;uniqueIndex:idx_tenant_page_template"`
+	Content   string     `json:"content" gorm:"type:text;not null"`
+	UpdatedBy *uuid.UUID `json:"updated_by,omitempty" gorm:"type:uuid"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+func (TenantPageTemplate) TableName() string {
+	return "tenant_page_templates"
+}
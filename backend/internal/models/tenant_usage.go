@@ -0,0 +1,149 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ============================================
+// TENANT USAGE METERING
+// ============================================
+
+// UsageMetric is a metered quantity recorded per tenant per day
+type UsageMetric string
+
+const (
+	UsageMetricClicks            UsageMetric = "clicks"
+	UsageMetricConversions       UsageMetric = "conversions"
+	UsageMetricAPICalls          UsageMetric = "api_calls"
+	UsageMetricWebhookDeliveries UsageMetric = "webhook_deliveries"
+	UsageMetricStorageBytes      UsageMetric = "storage_bytes" // snapshot, not a counter
+)
+
+// TenantUsageDaily is one tenant's metered usage for one UTC day
+type TenantUsageDaily struct {
+	ID                uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	TenantID          uuid.UUID `json:"tenant_id" gorm:"type:uuid;not null;uniqueIndex:idx_tenant_usage_day"`
+	Date              time.Time `json:"date" gorm:"type:date;not null;uniqueIndex:idx_tenant_usage_day;index"`
+	Clicks            int64     `json:"clicks" gorm:"default:0"`
+	Conversions       int64     `json:"conversions" gorm:"default:0"`
+	APICalls          int64     `json:"api_calls" gorm:"default:0"`
+	WebhookDeliveries int64     `json:"webhook_deliveries" gorm:"default:0"`
+	StorageBytes      int64     `json:"storage_bytes" gorm:"default:0"`
+	UpdatedAt         time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (TenantUsageDaily) TableName() string {
+	return "tenant_usage_daily"
+}
+
+// Get returns the value of a metric
+func (u *TenantUsageDaily) Get(metric UsageMetric) int64 {
+	switch metric {
+	case UsageMetricClicks:
+		return u.Clicks
+	case UsageMetricConversions:
+		return u.Conversions
+	case UsageMetricAPICalls:
+		return u.APICalls
+	case UsageMetricWebhookDeliveries:
+		return u.WebhookDeliveries
+	case UsageMetricStorageBytes:
+		return u.StorageBytes
+	}
+	return 0
+}
+
+// Add adds n to a counter metric (storage is set, not added)
+func (u *TenantUsageDaily) Add(metric UsageMetric, n int64) {
+	switch metric {
+	case UsageMetricClicks:
+		u.Clicks += n
+	case UsageMetricConversions:
+		u.Conversions += n
+	case UsageMetricAPICalls:
+		u.APICalls += n
+	case UsageMetricWebhookDeliveries:
+		u.WebhookDeliveries += n
+	case UsageMetricStorageBytes:
+		u.StorageBytes = n
+	}
+}
+
+// ============================================
+// PLAN LIMITS
+// ============================================
+
+// LimitResource is a plan limit enforced at creation time
+type LimitResource string
+
+const (
+	LimitResourceUsers        LimitResource = "users"
+	LimitResourceOffers       LimitResource = "offers"
+	LimitResourceAPIKeys      LimitResource = "api_keys"
+	LimitResourceWebhooks     LimitResource = "webhooks"
+	LimitResourceClicksPerDay LimitResource = "clicks_per_day"
+)
+
+// LimitResources lists every enforced limit
+var LimitResources = []LimitResource{
+	LimitResourceUsers,
+	LimitResourceOffers,
+	LimitResourceAPIKeys,
+	LimitResourceWebhooks,
+	LimitResourceClicksPerDay,
+}
+
+// LimitStatus is where usage sits relative to the soft and hard limits
+type LimitStatus string
+
+const (
+	LimitStatusOK       LimitStatus = "ok"       // ضمن الحد
+	LimitStatusWarning  LimitStatus = "warning"  // تجاوز الحد المرن وضمن هامش السماح
+	LimitStatusExceeded LimitStatus = "exceeded" // تجاوز الحد الصارم - يتم الرفض
+)
+
+// LimitCheck is the result of checking one limit. Limit is the soft limit
+// (the plan value); HardLimit adds the tenant's grace percentage.
+type LimitCheck struct {
+	Resource     LimitResource `json:"resource"`
+	Used         int64         `json:"used"`
+	Limit        int64         `json:"limit"`
+	HardLimit    int64         `json:"hard_limit"`
+	GracePercent int           `json:"grace_percent"`
+	Status       LimitStatus   `json:"status"`
+	Allowed      bool          `json:"allowed"`
+}
+
+// HardLimitFor returns limit plus grace percent, rounded up
+func HardLimitFor(limit int64, gracePercent int) int64 {
+	if gracePercent <= 0 || limit <= 0 {
+		return limit
+	}
+	return limit + (limit*int64(gracePercent)+99)/100
+}
+
+// EvaluateLimit checks whether used+adding fits under the soft/hard limits
+func EvaluateLimit(resource LimitResource, used, adding, limit int64, gracePercent int) *LimitCheck {
+	check := &LimitCheck{
+		Resource:     resource,
+		Used:         used,
+		Limit:        limit,
+		HardLimit:    HardLimitFor(limit, gracePercent),
+		GracePercent: gracePercent,
+	}
+
+	projected := used + adding
+	switch {
+	case projected <= check.Limit:
+		check.Status = LimitStatusOK
+		check.Allowed = true
+	case projected <= check.HardLimit:
+		check.Status = LimitStatusWarning
+		check.Allowed = true
+	default:
+		check.Status = LimitStatusExceeded
+	}
+	return check
+}
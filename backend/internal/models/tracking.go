@@ -1,13 +1,16 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // Click represents a single click on an affiliate link
 type Click struct {
+	TenantModel
 	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	UserOfferID uuid.UUID  `gorm:"type:uuid;not null;index:idx_clicks_user_offer" json:"user_offer_id"`
 	IPAddress   string     `gorm:"type:varchar(45);index:idx_clicks_ip" json:"ip_address,omitempty"`
@@ -39,8 +42,17 @@ func (Click) TableName() string {
 	return "clicks"
 }
 
+// BeforeCreate assigns the click to its user offer's tenant
+func (c *Click) BeforeCreate(tx *gorm.DB) error {
+	if c.TenantID == uuid.Nil {
+		c.TenantID = inheritTenantID(tx, "user_offers", c.UserOfferID)
+	}
+	return nil
+}
+
 // Conversion represents a successful conversion from a click
 type Conversion struct {
+	TenantModel
 	ID                   uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	UserOfferID          uuid.UUID  `gorm:"type:uuid;not null;index:idx_conv_user_offer" json:"user_offer_id"`
 	ClickID              *uuid.UUID `gorm:"type:uuid;index:idx_conv_click" json:"click_id,omitempty"`
@@ -49,14 +61,17 @@ type Conversion struct {
 	ExternalConversionID string     `gorm:"type:varchar(100);uniqueIndex:idx_conv_external_unique" json:"external_conversion_id,omitempty"`
 	NetworkID            *uuid.UUID `gorm:"type:uuid;index:idx_conv_network" json:"network_id,omitempty"`
 	
-	// Financial data
+	// Financial data - whole units of Currency, as posted by the advertiser
+	// (the ledger books them in minor units)
 	Amount               int        `gorm:"default:0" json:"amount"`
 	Commission           int        `gorm:"default:0" json:"commission"`
 	Currency             string     `gorm:"type:varchar(3);default:'USD'" json:"currency"`
+	Goal                 string     `gorm:"type:varchar(50)" json:"goal,omitempty"` // advertiser event (sale, lead, deposit); empty = default goal
 	
 	// Status tracking
 	Status               string     `gorm:"type:varchar(20);default:'pending';index:idx_conv_status" json:"status"`
 	RejectionReason      string     `gorm:"type:text" json:"rejection_reason,omitempty"`
+	HeldStatus           string     `gorm:"type:varchar(20)" json:"held_status,omitempty"` // status reported while the conversion was held for anomaly review
 	
 	// Fraud Detection - كشف الاحتيال
 	FraudScore           float64    `gorm:"type:decimal(5,2);default:0" json:"fraud_score"`
@@ -81,14 +96,33 @@ func (Conversion) TableName() string {
 	return "conversions"
 }
 
+// BeforeCreate assigns the conversion to its user offer's tenant
+func (c *Conversion) BeforeCreate(tx *gorm.DB) error {
+	if c.TenantID == uuid.Nil {
+		c.TenantID = inheritTenantID(tx, "user_offers", c.UserOfferID)
+	}
+	return nil
+}
+
 // ConversionStatus constants
 const (
 	ConversionStatusPending  = "pending"
 	ConversionStatusApproved = "approved"
 	ConversionStatusRejected = "rejected"
 	ConversionStatusPaid     = "paid"
+	ConversionStatusReview   = "review" // محجوز للمراجعة بسبب سلوك غير طبيعي
 )
 
+// NormalizeConversionGoal lower-cases a postback goal and caps its length;
+// an empty goal is the offer's default goal
+func NormalizeConversionGoal(goal string) string {
+	goal = strings.ToLower(strings.TrimSpace(goal))
+	if len(goal) > 50 {
+		goal = goal[:50]
+	}
+	return goal
+}
+
 // IsValid checks if conversion status is valid
 func (c *Conversion) IsValid() bool {
 	validStatuses := map[string]bool{
@@ -96,18 +130,19 @@ func (c *Conversion) IsValid() bool {
 		ConversionStatusApproved: true,
 		ConversionStatusRejected: true,
 		ConversionStatusPaid:     true,
+		ConversionStatusReview:   true,
 	}
 	return validStatuses[c.Status]
 }
 
 // CanApprove checks if conversion can be approved
 func (c *Conversion) CanApprove() bool {
-	return c.Status == ConversionStatusPending
+	return c.Status == ConversionStatusPending || c.Status == ConversionStatusReview
 }
 
 // CanReject checks if conversion can be rejected
 func (c *Conversion) CanReject() bool {
-	return c.Status == ConversionStatusPending
+	return c.Status == ConversionStatusPending || c.Status == ConversionStatusReview
 }
 
 // Note: Team and TeamMember are defined in team.go
@@ -132,6 +167,7 @@ func (Badge) TableName() string {
 
 // UserBadge represents a badge earned by a user
 type UserBadge struct {
+	TenantModel
 	ID       uuid.UUID   `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	UserID   uuid.UUID   `gorm:"type:uuid;not null;column:user_id;index:idx_user_badge_user" json:"user_id"`
 	BadgeID  uuid.UUID   `gorm:"type:uuid;not null;column:badge_id;index:idx_user_badge_badge" json:"badge_id"`
@@ -146,6 +182,7 @@ func (UserBadge) TableName() string {
 
 // TrackingEvent represents a generic tracking event for analytics
 type TrackingEvent struct {
+	TenantModel
 	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	EventType   string     `gorm:"type:varchar(50);not null;index:idx_event_type" json:"event_type"`
 	UserID      *uuid.UUID `gorm:"type:uuid;index:idx_event_user" json:"user_id,omitempty"`
@@ -10,6 +10,7 @@ import (
 
 // AfftokUser represents an affiliate marketer or advertiser
 type AfftokUser struct {
+	TenantModel
 	ID               uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
 	Username         string    `gorm:"type:varchar(50);uniqueIndex;not null" json:"username"`
 	Email            string    `gorm:"type:varchar(255);uniqueIndex;not null" json:"email"`
@@ -23,7 +24,6 @@ type AfftokUser struct {
 	Level            int       `gorm:"default:1" json:"level"`
 	TotalClicks      int       `gorm:"default:0" json:"total_clicks"`
 	TotalConversions int       `gorm:"default:0" json:"total_conversions"`
-	TotalEarnings    int       `gorm:"default:0" json:"total_earnings"`
 	CreatedAt        time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
 	UpdatedAt        time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
 	
@@ -33,6 +33,15 @@ type AfftokUser struct {
 	// Payment method for receiving earnings
 	PaymentMethod    string    `gorm:"type:text" json:"payment_method,omitempty"`
 
+	// Conversion and override earnings per currency (preload "Earnings")
+	Earnings         []EarningsCounter `gorm:"polymorphic:Owner;polymorphicValue:afftok_user;constraint:-" json:"earnings,omitempty"`
+
+	// Currency balances and statements are reported in (empty = tenant default)
+	ReportingCurrency string   `gorm:"type:varchar(3)" json:"reporting_currency,omitempty"`
+
+	// Declared traffic sources - المصادر المعلنة للزيارات (JSON array: ["tiktok.com", "myblog.com"])
+	TrafficSources   string    `gorm:"type:text" json:"traffic_sources,omitempty"`
+
 	// KYC Status - يتحول لـ required تلقائياً عند كشف سلوك مشبوه
 	// none = عادي | required = مطلوب تحقق | verified = تم التحقق | rejected = مرفوض
 	KYCStatus        string    `gorm:"type:varchar(20);default:'none'" json:"kyc_status"`
@@ -33,6 +33,7 @@ const (
 
 // WebhookPipeline represents a multi-step webhook pipeline
 type WebhookPipeline struct {
+	TenantModel
 	ID           uuid.UUID             `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
 	Name         string                `json:"name" gorm:"size:255;not null"`
 	Description  string                `json:"description" gorm:"size:1000"`
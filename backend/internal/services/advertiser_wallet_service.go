@@ -0,0 +1,727 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ============================================
+// ADVERTISER PREPAID WALLETS
+// ============================================
+// Top-ups (admin or payment provider) credit the wallet and are booked in the
+// ledger as cash received against the advertiser's receivable. Approved
+// conversions are charged in the same database transaction as their ledger
+// posting, with the wallet row locked, so concurrent conversions cannot
+// overspend. When the available funds (balance + credit limit) run out the
+// advertiser's active offers are paused and their clicks go to the fallback
+// URL; the next top-up resumes them. A charge that cannot be converted into the
+// wallet's currency yet is kept as pending and settled by a background job.
+
+// Wallet errors
+var (
+	ErrWalletNotFound         = errors.New("advertiser wallet not found")
+	ErrWalletDisabled         = errors.New("advertiser wallet is disabled")
+	ErrWalletCurrency         = errors.New("top-up currency does not match the wallet")
+	ErrWalletInvalidAmount    = errors.New("top-up amount must be positive")
+	ErrWalletInvalidSignature = errors.New("invalid wallet top-up signature")
+	ErrWalletEventIgnored     = errors.New("wallet top-up event is not handled")
+)
+
+// WalletPendingChargesInterval is how often pending conversion charges are retried
+const WalletPendingChargesInterval = 15 * time.Minute
+
+// WalletTopUpSignatureHeader carries the hex HMAC-SHA256 of a provider
+// top-up callback body, keyed with WALLET_TOPUP_WEBHOOK_SECRET
+const WalletTopUpSignatureHeader = "X-Wallet-Signature"
+
+// AdvertiserWalletService manages prepaid balances
+type AdvertiserWalletService struct {
+	db            *gorm.DB
+	fx            *FXService
+	ledger        *LedgerService
+	email         EmailSender
+	webhookSecret string
+
+	mu      sync.Mutex
+	running bool
+	stop    chan struct{}
+}
+
+var (
+	walletServiceInstance *AdvertiserWalletService
+	walletServiceOnce     sync.Once
+)
+
+// GetAdvertiserWalletService returns the singleton wallet service
+func GetAdvertiserWalletService(db *gorm.DB) *AdvertiserWalletService {
+	walletServiceOnce.Do(func() {
+		walletServiceInstance = NewAdvertiserWalletService(db)
+		walletServiceInstance.fx = GetFXService(db)
+		walletServiceInstance.email = GetEmailSender()
+	})
+	return walletServiceInstance
+}
+
+// NewAdvertiserWalletService creates a wallet service
+func NewAdvertiserWalletService(db *gorm.DB) *AdvertiserWalletService {
+	return &AdvertiserWalletService{db: db, webhookSecret: os.Getenv("WALLET_TOPUP_WEBHOOK_SECRET")}
+}
+
+// SetLedgerService sets the ledger top-ups are booked in (defaults to the singleton)
+func (s *AdvertiserWalletService) SetLedgerService(ledger *LedgerService) {
+	s.ledger = ledger
+}
+
+// SetFXService sets the exchange rates charges are converted with
+func (s *AdvertiserWalletService) SetFXService(fx *FXService) {
+	s.fx = fx
+}
+
+// SetEmailSender sets the sender of low-balance notifications
+func (s *AdvertiserWalletService) SetEmailSender(sender EmailSender) {
+	s.email = sender
+}
+
+// SetWebhookSecret sets the secret provider top-up callbacks are signed with
+func (s *AdvertiserWalletService) SetWebhookSecret(secret string) {
+	s.webhookSecret = secret
+}
+
+func (s *AdvertiserWalletService) ledgerService() *LedgerService {
+	if s.ledger == nil {
+		s.ledger = GetLedgerService(s.db)
+	}
+	return s.ledger
+}
+
+// ============================================
+// BALANCE MATH
+// ============================================
+
+// WalletTransition is what a balance movement did to a wallet
+type WalletTransition struct {
+	Exhausted   bool `json:"exhausted"`   // funds ran out: pause the advertiser's offers
+	Replenished bool `json:"replenished"` // an exhausted wallet has funds again: resume them
+	LowBalance  bool `json:"low_balance"` // dropped below the threshold (notify once)
+}
+
+// ApplyWalletMovement moves a wallet's balance by amount (+ credit, - debit)
+// and updates its status and notification markers
+func ApplyWalletMovement(w *models.AdvertiserWallet, amount int64, now time.Time) WalletTransition {
+	var t WalletTransition
+	w.Balance += amount
+	available := w.Available()
+
+	switch {
+	case available <= 0 && w.Status == models.WalletStatusActive:
+		w.Status, w.ExhaustedAt = models.WalletStatusExhausted, &now
+		t.Exhausted = true
+	case available > 0 && w.Status == models.WalletStatusExhausted:
+		w.Status, w.ExhaustedAt = models.WalletStatusActive, nil
+		t.Replenished = true
+	}
+
+	if available < w.LowBalanceThreshold {
+		if w.LowBalanceNotifiedAt == nil && w.Status != models.WalletStatusDisabled {
+			w.LowBalanceNotifiedAt = &now
+			t.LowBalance = true
+		}
+	} else {
+		w.LowBalanceNotifiedAt = nil
+	}
+	return t
+}
+
+// ============================================
+// CONFIGURATION & QUERIES
+// ============================================
+
+// WalletSettings configures an advertiser's wallet. Nil fields keep their value.
+type WalletSettings struct {
+	Currency            string `json:"currency"`
+	CreditLimit         *int64 `json:"credit_limit"`
+	LowBalanceThreshold *int64 `json:"low_balance_threshold"`
+	Enabled             *bool  `json:"enabled"`
+}
+
+// Configure creates or updates an advertiser's wallet. The currency can only
+// change while the balance is zero. Disabling a wallet returns the advertiser
+// to monthly invoicing and resumes offers paused for lack of funds.
+func (s *AdvertiserWalletService) Configure(tenantID, advertiserID uuid.UUID, settings WalletSettings) (*models.AdvertiserWallet, error) {
+	var wallet models.AdvertiserWallet
+	var transition WalletTransition
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var advertiser models.AfftokUser
+		if err := tx.Select("id, role").Where("tenant_id = ?", tenantID).
+			First(&advertiser, "id = ?", advertiserID).Error; err != nil || advertiser.Role != "advertiser" {
+			return ErrWalletNotFound
+		}
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ? AND advertiser_id = ?", tenantID, advertiserID).First(&wallet).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			wallet = models.AdvertiserWallet{AdvertiserID: advertiserID, Status: models.WalletStatusActive, Currency: NormalizeCurrency("")}
+			wallet.TenantID = tenantID
+			if s.fx != nil {
+				wallet.Currency = s.fx.ReportingCurrency(tenantID, advertiserID)
+			}
+		} else if err != nil {
+			return err
+		}
+
+		if settings.Currency != "" {
+			currency := NormalizeCurrency(settings.Currency)
+			if !ValidCurrency(currency) {
+				return fmt.Errorf("%w: invalid currency %q", ErrWalletCurrency, settings.Currency)
+			}
+			if currency != wallet.Currency && wallet.Balance != 0 {
+				return fmt.Errorf("%w: balance must be zero to change currency", ErrWalletCurrency)
+			}
+			wallet.Currency = currency
+		}
+		if settings.CreditLimit != nil && *settings.CreditLimit >= 0 {
+			wallet.CreditLimit = *settings.CreditLimit
+		}
+		if settings.LowBalanceThreshold != nil && *settings.LowBalanceThreshold >= 0 {
+			wallet.LowBalanceThreshold = *settings.LowBalanceThreshold
+		}
+		wasExhausted := wallet.Status == models.WalletStatusExhausted
+		if settings.Enabled != nil {
+			if !*settings.Enabled {
+				wallet.Status = models.WalletStatusDisabled
+			} else if wallet.Status == models.WalletStatusDisabled {
+				wallet.Status = models.WalletStatusActive
+			}
+		}
+		// Re-evaluate against the new limits without moving the balance
+		if wallet.Status != models.WalletStatusDisabled {
+			transition = ApplyWalletMovement(&wallet, 0, time.Now())
+		} else if wasExhausted {
+			transition.Replenished = true
+		}
+
+		if err := tx.Save(&wallet).Error; err != nil {
+			return err
+		}
+		return s.applyOfferState(tx, &wallet, transition)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.notify(&wallet, transition)
+	return &wallet, nil
+}
+
+// Get returns an advertiser's wallet
+func (s *AdvertiserWalletService) Get(tenantID, advertiserID uuid.UUID) (*models.AdvertiserWallet, error) {
+	var wallet models.AdvertiserWallet
+	if err := s.db.Where("tenant_id = ? AND advertiser_id = ?", tenantID, advertiserID).First(&wallet).Error; err != nil {
+		return nil, ErrWalletNotFound
+	}
+	return &wallet, nil
+}
+
+// List returns a tenant's wallets, optionally filtered by status, lowest funds first
+func (s *AdvertiserWalletService) List(tenantID uuid.UUID, status string) ([]models.AdvertiserWallet, error) {
+	query := s.db.Preload("Advertiser").Where("tenant_id = ?", tenantID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var wallets []models.AdvertiserWallet
+	err := query.Order("balance + credit_limit ASC").Find(&wallets).Error
+	return wallets, err
+}
+
+// Transactions returns a wallet's movements, newest first
+func (s *AdvertiserWalletService) Transactions(tenantID, advertiserID uuid.UUID, limit, offset int) ([]models.WalletTransaction, int64, error) {
+	query := s.db.Model(&models.WalletTransaction{}).Where("tenant_id = ? AND advertiser_id = ?", tenantID, advertiserID)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var txns []models.WalletTransaction
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&txns).Error
+	return txns, total, err
+}
+
+// ============================================
+// TOP-UPS
+// ============================================
+
+// WalletTopUp is a payment received into a wallet
+type WalletTopUp struct {
+	Amount         int64  // minor units of Currency
+	Currency       string // empty = the wallet's currency
+	Source         string // "admin" or the provider name
+	ExternalRef    string // provider payment ID or bank reference
+	IdempotencyKey string // empty = derived from Source and ExternalRef
+	Note           string
+	CreatedBy      *uuid.UUID
+}
+
+// TopUp credits a wallet and books the payment in the ledger. Recording the
+// same top-up twice returns the first transaction with created=false.
+func (s *AdvertiserWalletService) TopUp(tenantID, advertiserID uuid.UUID, req WalletTopUp) (txn *models.WalletTransaction, created bool, err error) {
+	if req.Amount <= 0 {
+		return nil, false, ErrWalletInvalidAmount
+	}
+	key := req.IdempotencyKey
+	if key == "" && req.ExternalRef != "" {
+		key = "top_up:" + req.Source + ":" + req.ExternalRef
+	}
+	if key == "" {
+		key = "top_up:" + uuid.NewString()
+	}
+
+	var wallet models.AdvertiserWallet
+	var transition WalletTransition
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var existing models.WalletTransaction
+		if err := tx.Where("idempotency_key = ?", key).First(&existing).Error; err == nil {
+			txn = &existing
+			return nil
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ? AND advertiser_id = ?", tenantID, advertiserID).First(&wallet).Error; err != nil {
+			return ErrWalletNotFound
+		}
+		if wallet.Status == models.WalletStatusDisabled {
+			return ErrWalletDisabled
+		}
+		if req.Currency != "" && NormalizeCurrency(req.Currency) != wallet.Currency {
+			return fmt.Errorf("%w: wallet is in %s", ErrWalletCurrency, wallet.Currency)
+		}
+
+		now := time.Now()
+		transition = ApplyWalletMovement(&wallet, req.Amount, now)
+		txn = &models.WalletTransaction{
+			WalletID:       wallet.ID,
+			AdvertiserID:   advertiserID,
+			Type:           models.WalletTxTopUp,
+			Amount:         req.Amount,
+			BalanceAfter:   wallet.Balance,
+			Currency:       wallet.Currency,
+			IdempotencyKey: key,
+			Source:         req.Source,
+			ExternalRef:    req.ExternalRef,
+			Note:           req.Note,
+			CreatedBy:      req.CreatedBy,
+		}
+		txn.TenantID = tenantID
+		if err := tx.Create(txn).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(&wallet).Error; err != nil {
+			return err
+		}
+		if err := s.postTopUp(tx, &wallet, txn); err != nil {
+			return err
+		}
+		created = true
+		return s.applyOfferState(tx, &wallet, transition)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if created {
+		s.notify(&wallet, transition)
+	}
+	return txn, created, nil
+}
+
+// postTopUp books a top-up as cash received against the advertiser's
+// receivable; conversion charges then draw the receivable back down
+func (s *AdvertiserWalletService) postTopUp(tx *gorm.DB, wallet *models.AdvertiserWallet, txn *models.WalletTransaction) error {
+	ledger := s.ledgerService()
+	advertiserID := wallet.AdvertiserID
+	_, _, err := ledger.Post(tx, LedgerTransactionRequest{
+		TenantID:       wallet.TenantID,
+		Kind:           models.LedgerKindWalletTopUp,
+		IdempotencyKey: "wallet_" + txn.IdempotencyKey,
+		ReferenceType:  LedgerReferenceWallet,
+		ReferenceID:    txn.ID,
+		AdvertiserID:   &advertiserID,
+		Currency:       wallet.Currency,
+		Description:    "Wallet top-up " + txn.ExternalRef,
+		CreatedBy:      txn.CreatedBy,
+		ReportIn:       ledger.reportingCurrencies(wallet.TenantID, advertiserID),
+		Postings: []LedgerPosting{
+			{AccountType: models.LedgerAccountCash, OwnerID: uuid.Nil, Amount: txn.Amount},
+			{AccountType: models.LedgerAccountAdvertiserReceivable, OwnerID: advertiserID, Amount: -txn.Amount},
+		},
+	})
+	return err
+}
+
+// WalletTopUpEvent is the body of a provider top-up callback
+type WalletTopUpEvent struct {
+	ID           string    `json:"id"` // provider event ID
+	Type         string    `json:"type"`
+	Provider     string    `json:"provider"`
+	TenantID     uuid.UUID `json:"tenant_id"`
+	AdvertiserID uuid.UUID `json:"advertiser_id"`
+	PaymentID    string    `json:"payment_id"`
+	Amount       int64     `json:"amount"` // minor units
+	Currency     string    `json:"currency"`
+}
+
+// HandleTopUpWebhook verifies a signed provider callback and credits the
+// wallet. Only payment.succeeded events top up; redelivered events are
+// recognised by payment ID (duplicate=true).
+func (s *AdvertiserWalletService) HandleTopUpWebhook(headers http.Header, body []byte) (event *WalletTopUpEvent, duplicate bool, err error) {
+	if s.webhookSecret == "" ||
+		!NewWebhookSigningService().VerifyHMAC(body, headers.Get(WalletTopUpSignatureHeader), s.webhookSecret) {
+		return nil, false, ErrWalletInvalidSignature
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, false, fmt.Errorf("invalid top-up body: %w", err)
+	}
+	if event.Type != PaymentEventSucceeded || event.PaymentID == "" || event.AdvertiserID == uuid.Nil {
+		return event, false, ErrWalletEventIgnored
+	}
+	if event.TenantID == uuid.Nil {
+		event.TenantID = models.DefaultTenantID
+	}
+	if event.Provider == "" {
+		event.Provider = "provider"
+	}
+
+	_, created, err := s.TopUp(event.TenantID, event.AdvertiserID, WalletTopUp{
+		Amount:      event.Amount,
+		Currency:    event.Currency,
+		Source:      event.Provider,
+		ExternalRef: event.PaymentID,
+		Note:        event.ID,
+	})
+	return event, err == nil && !created, err
+}
+
+// ============================================
+// CONVERSION CHARGES
+// ============================================
+
+func walletChargeKey(conversionID uuid.UUID) string {
+	return "conversion_charge:" + conversionID.String()
+}
+
+// ChargeConversion debits an approved conversion (commission + fee, in the
+// conversion's currency) from the advertiser's wallet inside tx. Advertisers
+// without an active wallet are invoiced monthly instead. The conversion is
+// charged even past the limit - the traffic was already delivered - and the
+// wallet is exhausted so no more is sent. Without an exchange rate into the
+// wallet's currency the charge is recorded as pending rather than failing the
+// conversion; SettlePendingCharges books it once the rate exists.
+func (s *AdvertiserWalletService) ChargeConversion(tx *gorm.DB, conversion *models.Conversion, advertiserID uuid.UUID, amount Money) error {
+	if advertiserID == uuid.Nil || amount.Amount <= 0 {
+		return nil
+	}
+	var wallet models.AdvertiserWallet
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("tenant_id = ? AND advertiser_id = ? AND status <> ?", conversion.TenantID, advertiserID, models.WalletStatusDisabled).
+		First(&wallet).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	charge, err := s.inWalletCurrency(amount, wallet.Currency)
+	if errors.Is(err, ErrFXRateNotFound) {
+		return s.deferCharge(tx, &wallet, conversion.ID, amount, err)
+	}
+	if err != nil {
+		return err
+	}
+	conversionID := conversion.ID
+	return s.move(tx, &wallet, &models.WalletTransaction{
+		Type:           models.WalletTxConversionCharge,
+		Amount:         -charge.Amount,
+		IdempotencyKey: walletChargeKey(conversionID),
+		Source:         "conversion",
+		ConversionID:   &conversionID,
+	})
+}
+
+// deferCharge records a conversion charge that cannot be converted yet
+func (s *AdvertiserWalletService) deferCharge(tx *gorm.DB, wallet *models.AdvertiserWallet, conversionID uuid.UUID, amount Money, cause error) error {
+	pending := &models.WalletPendingCharge{
+		WalletID:     wallet.ID,
+		AdvertiserID: wallet.AdvertiserID,
+		ConversionID: conversionID,
+		Amount:       amount.Amount,
+		Currency:     NormalizeCurrency(amount.Currency),
+		LastError:    cause.Error(),
+	}
+	pending.TenantID = wallet.TenantID
+	if err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "conversion_id"}}, DoNothing: true}).
+		Create(pending).Error; err != nil {
+		return fmt.Errorf("failed to record pending wallet charge: %w", err)
+	}
+	log.Printf("[Wallet] conversion %s charge pending: %v", conversionID, cause)
+	return nil
+}
+
+// SettlePendingCharges charges the pending conversion charges whose exchange
+// rate is now available and returns how many were settled
+func (s *AdvertiserWalletService) SettlePendingCharges() (int, error) {
+	var pending []models.WalletPendingCharge
+	if err := s.db.Order("created_at ASC").Find(&pending).Error; err != nil {
+		return 0, err
+	}
+	settled := 0
+	for i := range pending {
+		ok, err := s.settlePendingCharge(&pending[i])
+		if err != nil {
+			log.Printf("[Wallet] failed to settle conversion %s charge: %v", pending[i].ConversionID, err)
+			continue
+		}
+		if ok {
+			settled++
+		}
+	}
+	return settled, nil
+}
+
+func (s *AdvertiserWalletService) settlePendingCharge(pending *models.WalletPendingCharge) (bool, error) {
+	settled := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var wallet models.AdvertiserWallet
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&wallet, "id = ?", pending.WalletID).Error; err != nil {
+			return err
+		}
+		if wallet.Status != models.WalletStatusDisabled {
+			charge, err := s.inWalletCurrency(NewMoney(pending.Amount, pending.Currency), wallet.Currency)
+			if errors.Is(err, ErrFXRateNotFound) {
+				return tx.Model(pending).Updates(map[string]interface{}{
+					"attempts":   pending.Attempts + 1,
+					"last_error": err.Error(),
+					"updated_at": time.Now(),
+				}).Error
+			}
+			if err != nil {
+				return err
+			}
+			conversionID := pending.ConversionID
+			if err := s.move(tx, &wallet, &models.WalletTransaction{
+				Type:           models.WalletTxConversionCharge,
+				Amount:         -charge.Amount,
+				IdempotencyKey: walletChargeKey(conversionID),
+				Source:         "conversion",
+				ConversionID:   &conversionID,
+			}); err != nil {
+				return err
+			}
+		}
+		// A wallet disabled meanwhile leaves the conversion to monthly invoicing
+		settled = true
+		return tx.Delete(&models.WalletPendingCharge{}, "id = ?", pending.ID).Error
+	})
+	return settled, err
+}
+
+// RefundConversion credits back a reversed conversion's charge inside tx
+func (s *AdvertiserWalletService) RefundConversion(tx *gorm.DB, conversionID uuid.UUID, reason string) error {
+	if err := tx.Delete(&models.WalletPendingCharge{}, "conversion_id = ?", conversionID).Error; err != nil {
+		return fmt.Errorf("failed to drop pending wallet charge: %w", err)
+	}
+	var charge models.WalletTransaction
+	if err := tx.Where("idempotency_key = ?", walletChargeKey(conversionID)).First(&charge).Error; err != nil {
+		return nil // never charged to a wallet
+	}
+	var wallet models.AdvertiserWallet
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&wallet, "id = ?", charge.WalletID).Error; err != nil {
+		return err
+	}
+	return s.move(tx, &wallet, &models.WalletTransaction{
+		Type:           models.WalletTxConversionRefund,
+		Amount:         -charge.Amount,
+		IdempotencyKey: "conversion_refund:" + conversionID.String(),
+		Source:         "conversion",
+		ConversionID:   &conversionID,
+		Note:           reason,
+	})
+}
+
+// ChargeInvoice charges a prepaid advertiser's wallet inside tx for what an
+// invoice bills beyond the per-conversion charges (adjustments and tax); a
+// negative amount credits the wallet. Reports whether a wallet paid it.
+func (s *AdvertiserWalletService) ChargeInvoice(tx *gorm.DB, invoice *models.Invoice, amount Money) (bool, error) {
+	var wallet models.AdvertiserWallet
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("tenant_id = ? AND advertiser_id = ? AND status <> ?", invoice.TenantID, invoice.AdvertiserID, models.WalletStatusDisabled).
+		First(&wallet).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if amount.Amount == 0 {
+		return true, nil
+	}
+
+	charge, err := s.inWalletCurrency(amount, wallet.Currency)
+	if err != nil {
+		return false, err
+	}
+	return true, s.move(tx, &wallet, &models.WalletTransaction{
+		Type:           models.WalletTxAdjustment,
+		Amount:         -charge.Amount,
+		IdempotencyKey: "invoice_charge:" + invoice.ID.String(),
+		Source:         "invoice",
+		ExternalRef:    invoice.Number,
+	})
+}
+
+// move applies a movement to a locked wallet; a key already recorded is a no-op
+func (s *AdvertiserWalletService) move(tx *gorm.DB, wallet *models.AdvertiserWallet, txn *models.WalletTransaction) error {
+	txn.TenantID = wallet.TenantID
+	txn.WalletID = wallet.ID
+	txn.AdvertiserID = wallet.AdvertiserID
+	txn.Currency = wallet.Currency
+
+	transition := ApplyWalletMovement(wallet, txn.Amount, time.Now())
+	txn.BalanceAfter = wallet.Balance
+	result := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "idempotency_key"}}, DoNothing: true}).Create(txn)
+	if result.Error != nil {
+		return fmt.Errorf("failed to record wallet movement: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+	if err := tx.Model(wallet).Updates(map[string]interface{}{
+		"balance":                 wallet.Balance,
+		"status":                  wallet.Status,
+		"exhausted_at":            wallet.ExhaustedAt,
+		"low_balance_notified_at": wallet.LowBalanceNotifiedAt,
+		"updated_at":              time.Now(),
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update wallet: %w", err)
+	}
+	if err := s.applyOfferState(tx, wallet, transition); err != nil {
+		return err
+	}
+	s.notify(wallet, transition)
+	return nil
+}
+
+// inWalletCurrency converts a charge into the wallet's currency at today's rate
+func (s *AdvertiserWalletService) inWalletCurrency(amount Money, currency string) (Money, error) {
+	if NormalizeCurrency(amount.Currency) == currency {
+		return amount, nil
+	}
+	if s.fx == nil {
+		return Money{}, fmt.Errorf("%w: %s to %s", ErrFXRateNotFound, amount.Currency, currency)
+	}
+	converted, _, err := s.fx.Convert(amount, currency, time.Now())
+	return converted, err
+}
+
+// Start retries pending conversion charges in the background
+func (s *AdvertiserWalletService) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return
+	}
+	s.running = true
+	s.stop = make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(WalletPendingChargesInterval)
+		defer ticker.Stop()
+		for {
+			if settled, err := s.SettlePendingCharges(); err != nil {
+				log.Printf("[Wallet] pending charges run failed: %v", err)
+			} else if settled > 0 {
+				log.Printf("[Wallet] settled %d pending conversion charges", settled)
+			}
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the pending charges job
+func (s *AdvertiserWalletService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		close(s.stop)
+		s.running = false
+	}
+}
+
+// ============================================
+// OFFERS & NOTIFICATIONS
+// ============================================
+
+// applyOfferState pauses an exhausted advertiser's active offers and resumes
+// the ones it paused once funds are back
+func (s *AdvertiserWalletService) applyOfferState(tx *gorm.DB, wallet *models.AdvertiserWallet, t WalletTransition) error {
+	now := time.Now()
+	switch {
+	case t.Exhausted:
+		result := tx.Model(&models.Offer{}).
+			Where("tenant_id = ? AND advertiser_id = ? AND status = ?", wallet.TenantID, wallet.AdvertiserID, "active").
+			Updates(map[string]interface{}{"status": "paused", "paused_reason": models.OfferPausedWalletExhausted, "updated_at": now})
+		if result.Error != nil {
+			return fmt.Errorf("failed to pause offers: %w", result.Error)
+		}
+		log.Printf("[Wallet] advertiser %s exhausted (available %d %s): %d offers paused",
+			wallet.AdvertiserID, wallet.Available(), wallet.Currency, result.RowsAffected)
+	case t.Replenished:
+		result := tx.Model(&models.Offer{}).
+			Where("tenant_id = ? AND advertiser_id = ? AND status = ? AND paused_reason = ?",
+				wallet.TenantID, wallet.AdvertiserID, "paused", models.OfferPausedWalletExhausted).
+			Updates(map[string]interface{}{"status": "active", "paused_reason": "", "updated_at": now})
+		if result.Error != nil {
+			return fmt.Errorf("failed to resume offers: %w", result.Error)
+		}
+		log.Printf("[Wallet] advertiser %s funded again: %d offers resumed", wallet.AdvertiserID, result.RowsAffected)
+	}
+	return nil
+}
+
+// notify emails the advertiser about a low or exhausted balance (async - never
+// blocks the conversion that caused it)
+func (s *AdvertiserWalletService) notify(wallet *models.AdvertiserWallet, t WalletTransition) {
+	if s.email == nil || (!t.LowBalance && !t.Exhausted) {
+		return
+	}
+	available := NewMoney(wallet.Available(), wallet.Currency)
+	subject := "Your AffTok balance is running low"
+	text := fmt.Sprintf("Your prepaid balance is %s (available including credit: %s). Top up to keep your offers running.",
+		NewMoney(wallet.Balance, wallet.Currency), available)
+	if t.Exhausted {
+		subject = "Your AffTok offers were paused"
+		text = fmt.Sprintf("Your prepaid balance is used up (available: %s), so your offers were paused. They resume automatically after a top-up.", available)
+	}
+
+	sender, advertiserID := s.email, wallet.AdvertiserID
+	go func() {
+		var advertiser models.AfftokUser
+		if err := s.db.Select("id, email").First(&advertiser, "id = ?", advertiserID).Error; err != nil || advertiser.Email == "" {
+			return
+		}
+		if err := sender.Send(EmailMessage{To: advertiser.Email, Subject: subject, Text: text}); err != nil {
+			log.Printf("[Wallet] failed to email %s: %v", advertiser.Email, err)
+		}
+	}()
+}
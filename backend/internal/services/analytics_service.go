@@ -26,6 +26,11 @@ type UserStats struct {
 	TotalEarnings    int64   `json:"total_earnings"`
 	ConversionRate   float64 `json:"conversion_rate"`
 	ActiveOffers     int64   `json:"active_offers"`
+
+	// Referral and team overrides earned on other promoters' conversions
+	// (minor units, net of reversals) and promoters referred
+	OverrideEarnings int64 `json:"override_earnings"`
+	Referrals        int64 `json:"referrals"`
 	
 	// Time-based stats
 	ClicksToday      int64 `json:"clicks_today"`
@@ -47,8 +52,9 @@ type OfferStats struct {
 	TotalEarnings    int64   `json:"total_earnings"`
 }
 
-// GetUserStats returns aggregated stats for a user
-func (s *AnalyticsService) GetUserStats(userID uuid.UUID) (*UserStats, error) {
+// GetUserStats returns aggregated stats for a user; "today", "this week" and
+// "this month" are calendar periods in loc
+func (s *AnalyticsService) GetUserStats(userID uuid.UUID, loc *time.Location) (*UserStats, error) {
 	ctx := context.Background()
 	cacheKey := fmt.Sprintf("user_stats:%s", userID.String())
 
@@ -65,6 +71,18 @@ func (s *AnalyticsService) GetUserStats(userID uuid.UUID) (*UserStats, error) {
 	// Calculate from database
 	stats := &UserStats{}
 
+	// Overrides and referrals
+	database.DB.Table("ledger_entries e").
+		Select("COALESCE(SUM(-e.amount), 0)").
+		Joins("JOIN ledger_accounts a ON a.id = e.account_id").
+		Joins("JOIN ledger_transactions t ON t.id = e.transaction_id").
+		Where("a.type = ? AND a.owner_id = ? AND t.kind IN ?", models.LedgerAccountPromoterPayable, userID,
+			[]models.LedgerTransactionKind{models.LedgerKindOverrideEarned, models.LedgerKindOverrideReversed}).
+		Scan(&stats.OverrideEarnings)
+	database.DB.Model(&models.Referral{}).
+		Where("referrer_id = ?", userID).
+		Count(&stats.Referrals)
+
 	// Get user offer IDs
 	var userOfferIDs []uuid.UUID
 	if err := database.DB.Model(&models.UserOffer{}).
@@ -105,11 +123,11 @@ func (s *AnalyticsService) GetUserStats(userID uuid.UUID) (*UserStats, error) {
 		stats.ConversionRate = float64(stats.TotalConversions) / float64(stats.TotalClicks) * 100
 	}
 
-	// Time-based stats
-	now := time.Now().UTC()
-	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	// Time-based stats (day, week and month boundaries in loc)
+	now := time.Now().In(loc)
+	startOfDay := StartOfDayIn(now, loc)
 	startOfWeek := startOfDay.AddDate(0, 0, -int(now.Weekday()))
-	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	startOfMonth := StartOfMonthIn(now, loc)
 
 	// Clicks today
 	database.DB.Model(&models.Click{}).
@@ -264,8 +282,9 @@ func (s *AnalyticsService) RecordEvent(eventType string, userID, offerID, userOf
 	return database.DB.Create(&event).Error
 }
 
-// GetDailyStats returns click and conversion counts for the last N days
-func (s *AnalyticsService) GetDailyStats(userID uuid.UUID, days int) ([]map[string]interface{}, error) {
+// GetDailyStats returns click and conversion counts for the last N days,
+// bucketed by calendar day in loc
+func (s *AnalyticsService) GetDailyStats(userID uuid.UUID, days int, loc *time.Location) ([]map[string]interface{}, error) {
 	var results []map[string]interface{}
 
 	// Get user offer IDs
@@ -280,12 +299,11 @@ func (s *AnalyticsService) GetDailyStats(userID uuid.UUID, days int) ([]map[stri
 		return results, nil
 	}
 
-	now := time.Now().UTC()
+	today := StartOfDayIn(time.Now(), loc)
 
 	for i := 0; i < days; i++ {
-		date := now.AddDate(0, 0, -i)
-		startOfDay := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
-		endOfDay := startOfDay.Add(24 * time.Hour)
+		startOfDay := today.AddDate(0, 0, -i)
+		endOfDay := startOfDay.AddDate(0, 0, 1)
 
 		var clicks, conversions int64
 
@@ -72,6 +72,11 @@ func (s *APIKeyService) GenerateAPIKey(advertiserID uuid.UUID, req *models.Creat
 		return nil, fmt.Errorf("advertiser not found: %w", err)
 	}
 
+	// Active keys count against the advertiser's tenant plan
+	if err := GetUsageService(s.db).Enforce(advertiser.TenantID, models.LimitResourceAPIKeys); err != nil {
+		return nil, err
+	}
+
 	// Generate random key
 	randomBytes := make([]byte, keyLength)
 	if _, err := rand.Read(randomBytes); err != nil {
@@ -121,14 +126,15 @@ func (s *APIKeyService) GenerateAPIKey(advertiserID uuid.UUID, req *models.Creat
 		expiresAt = &exp
 	}
 
-	// Rate limit
-	rateLimit := 60
+	// Rate limit (defaults to the tenant's API rate limit)
+	rateLimit := GetTenantSettingsResolver(s.db).Get(advertiser.TenantID).APIRateLimitPerMin
 	if req.RateLimitPerMinute > 0 {
 		rateLimit = req.RateLimitPerMinute
 	}
 
 	// Create API key record
 	apiKey := &models.AdvertiserAPIKey{
+		TenantModel:        models.TenantModel{TenantID: advertiser.TenantID},
 		AdvertiserID:       advertiserID,
 		NetworkID:          networkID,
 		Name:               req.Name,
@@ -290,6 +296,7 @@ func (s *APIKeyService) RotateAPIKey(apiKeyID uuid.UUID) (*models.CreateAPIKeyRe
 
 	// Create new key
 	newKey := &models.AdvertiserAPIKey{
+		TenantModel:        oldKey.TenantModel,
 		AdvertiserID:       oldKey.AdvertiserID,
 		NetworkID:          oldKey.NetworkID,
 		Name:               oldKey.Name + " (rotated)",
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// ============================================
+// PAYMENT PROVIDER ABSTRACTION
+// ============================================
+
+// Payment outcomes reported by providers
+const (
+	PaymentStatusSucceeded  = "succeeded"
+	PaymentStatusProcessing = "processing" // result arrives by webhook
+	PaymentStatusFailed     = "failed"
+)
+
+// Normalised payment webhook event types
+const (
+	PaymentEventSucceeded = "payment.succeeded"
+	PaymentEventFailed    = "payment.failed"
+)
+
+// Errors returned by payment providers
+var (
+	ErrPaymentInvalidSignature = errors.New("invalid payment webhook signature")
+	ErrPaymentProviderDisabled = errors.New("payment provider is not configured")
+	ErrPaymentEventIgnored     = errors.New("payment event type is not handled")
+)
+
+// PaymentProvider charges tenants for platform invoices
+type PaymentProvider interface {
+	// Name returns the provider identifier stored on invoices ("stripe", "fake")
+	Name() string
+
+	// CreateCustomer registers the tenant with the provider
+	CreateCustomer(ctx context.Context, req *PaymentCustomerRequest) (string, error)
+
+	// Charge collects an amount from the customer's default payment method.
+	// IdempotencyKey must make retries of the same attempt safe.
+	Charge(ctx context.Context, req *PaymentChargeRequest) (*PaymentChargeResult, error)
+
+	// ParseWebhook verifies the signature on a raw webhook body and normalises it
+	ParseWebhook(headers http.Header, body []byte) (*PaymentEvent, error)
+}
+
+// PaymentCustomerRequest is the tenant data sent when creating a customer
+type PaymentCustomerRequest struct {
+	TenantID uuid.UUID
+	Name     string
+	Email    string
+}
+
+// PaymentChargeRequest is one collection attempt for an invoice
+type PaymentChargeRequest struct {
+	CustomerID     string
+	InvoiceID      uuid.UUID
+	InvoiceNumber  string
+	AmountCents    int64
+	Currency       string
+	IdempotencyKey string
+}
+
+// PaymentChargeResult is the provider's answer to a charge
+type PaymentChargeResult struct {
+	PaymentID      string `json:"payment_id"`
+	Status         string `json:"status"`
+	FailureMessage string `json:"failure_message,omitempty"`
+}
+
+// PaymentEvent is a normalised provider webhook
+type PaymentEvent struct {
+	ID             string    `json:"id"` // provider event ID, used for idempotency
+	Type           string    `json:"type"`
+	PaymentID      string    `json:"payment_id"`
+	InvoiceID      uuid.UUID `json:"invoice_id"`
+	FailureMessage string    `json:"failure_message,omitempty"`
+}
+
+// ============================================
+// FAKE PROVIDER
+// ============================================
+
+// FakePaymentProvider is an in-memory provider for tests and local
+// development. Charges succeed unless the customer is marked to fail.
+type FakePaymentProvider struct {
+	WebhookSecret string
+
+	mu       sync.Mutex
+	failing  map[string]string // customer ID -> failure message
+	charges  map[string]*PaymentChargeResult
+	requests []PaymentChargeRequest
+}
+
+// NewFakePaymentProvider creates a fake provider; webhooks must carry
+// X-Fake-Signature equal to webhookSecret
+func NewFakePaymentProvider(webhookSecret string) *FakePaymentProvider {
+	return &FakePaymentProvider{
+		WebhookSecret: webhookSecret,
+		failing:       make(map[string]string),
+		charges:       make(map[string]*PaymentChargeResult),
+	}
+}
+
+// Name returns the provider identifier
+func (p *FakePaymentProvider) Name() string {
+	return "fake"
+}
+
+// CreateCustomer returns a customer ID derived from the tenant
+func (p *FakePaymentProvider) CreateCustomer(ctx context.Context, req *PaymentCustomerRequest) (string, error) {
+	return "cus_fake_" + req.TenantID.String()[:8], nil
+}
+
+// FailCustomer makes every charge to the customer fail with message
+// (empty message clears it)
+func (p *FakePaymentProvider) FailCustomer(customerID, message string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if message == "" {
+		delete(p.failing, customerID)
+		return
+	}
+	p.failing[customerID] = message
+}
+
+// Charge records the request; the same idempotency key returns the same result
+func (p *FakePaymentProvider) Charge(ctx context.Context, req *PaymentChargeRequest) (*PaymentChargeResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if result, ok := p.charges[req.IdempotencyKey]; ok {
+		return result, nil
+	}
+
+	p.requests = append(p.requests, *req)
+	result := &PaymentChargeResult{
+		PaymentID: fmt.Sprintf("pi_fake_%d", len(p.requests)),
+		Status:    PaymentStatusSucceeded,
+	}
+	if message, failing := p.failing[req.CustomerID]; failing {
+		result.Status = PaymentStatusFailed
+		result.FailureMessage = message
+	}
+	p.charges[req.IdempotencyKey] = result
+	return result, nil
+}
+
+// Charges returns the distinct charge requests received so far
+func (p *FakePaymentProvider) Charges() []PaymentChargeRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]PaymentChargeRequest(nil), p.requests...)
+}
+
+// ParseWebhook accepts a PaymentEvent as JSON
+func (p *FakePaymentProvider) ParseWebhook(headers http.Header, body []byte) (*PaymentEvent, error) {
+	if p.WebhookSecret == "" || headers.Get("X-Fake-Signature") != p.WebhookSecret {
+		return nil, ErrPaymentInvalidSignature
+	}
+	var event PaymentEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("invalid fake webhook body: %w", err)
+	}
+	if event.Type != PaymentEventSucceeded && event.Type != PaymentEventFailed {
+		return nil, ErrPaymentEventIgnored
+	}
+	return &event, nil
+}
@@ -0,0 +1,994 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ============================================
+// TENANT BILLING SERVICE
+// ============================================
+
+// Billing configuration
+const (
+	BillingJobInterval       = time.Hour
+	BillingProcessingRecheck = 24 * time.Hour // processing payments without a webhook are retried
+	billingChargeTimeout     = 30 * time.Second
+	billingRenewBatch        = 200
+)
+
+// DunningSchedule is the wait before each retry after a failed payment. Once
+// every retry has failed the invoice is uncollectible and the tenant is
+// suspended.
+var DunningSchedule = []time.Duration{
+	3 * 24 * time.Hour,
+	4 * 24 * time.Hour,
+	7 * 24 * time.Hour,
+}
+
+// Errors returned by billing
+var (
+	ErrBillingInvalidPlan     = errors.New("invalid plan")
+	ErrBillingInvalidCycle    = errors.New("invalid billing cycle")
+	ErrBillingNoChange        = errors.New("subscription already on this plan and cycle")
+	ErrBillingInvoiceNotFound = errors.New("invoice not found")
+	ErrBillingInvoiceNotOpen  = errors.New("invoice is not open")
+)
+
+// errBillingPeriodClaimed means another run already renewed the period
+var errBillingPeriodClaimed = errors.New("billing period already renewed")
+
+// BillingService runs tenant subscriptions, invoices and dunning
+type BillingService struct {
+	db       *gorm.DB
+	tenants  *TenantService
+	usage    *UsageService
+	provider PaymentProvider
+	email    EmailSender
+}
+
+var (
+	billingService     *BillingService
+	billingServiceOnce sync.Once
+)
+
+// GetBillingService returns the singleton billing service. Stripe is used when
+// STRIPE_SECRET_KEY is set; BILLING_PROVIDER=fake selects the fake provider
+// (development). Without a provider invoices must be marked paid manually.
+func GetBillingService(db *gorm.DB) *BillingService {
+	billingServiceOnce.Do(func() {
+		billingService = NewBillingService(db, defaultPaymentProvider())
+	})
+	return billingService
+}
+
+func defaultPaymentProvider() PaymentProvider {
+	if stripe := NewStripeProvider(StripeConfigFromEnv()); stripe.Enabled() {
+		return stripe
+	}
+	if os.Getenv("BILLING_PROVIDER") == "fake" {
+		return NewFakePaymentProvider(os.Getenv("BILLING_FAKE_WEBHOOK_SECRET"))
+	}
+	return nil
+}
+
+// NewBillingService creates a billing service; provider may be nil
+func NewBillingService(db *gorm.DB, provider PaymentProvider) *BillingService {
+	return &BillingService{
+		db:       db,
+		tenants:  NewTenantService(db),
+		usage:    GetUsageService(db),
+		provider: provider,
+		email:    GetEmailSender(),
+	}
+}
+
+// SetProvider sets the payment provider
+func (s *BillingService) SetProvider(provider PaymentProvider) {
+	s.provider = provider
+}
+
+// SetEmailSender sets the sender used for billing notices
+func (s *BillingService) SetEmailSender(sender EmailSender) {
+	s.email = sender
+}
+
+// ProviderName returns the configured provider, or "manual"
+func (s *BillingService) ProviderName() string {
+	if s.provider == nil {
+		return "manual"
+	}
+	return s.provider.Name()
+}
+
+// ============================================
+// PRICING HELPERS
+// ============================================
+
+// BillingPeriodEnd returns the end of a period starting at start
+func BillingPeriodEnd(start time.Time, cycle models.BillingCycle) time.Time {
+	if cycle == models.BillingCycleAnnual {
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// ProrateCents returns the unused part of amount for a period at a point in time
+func ProrateCents(amount int64, start, end, at time.Time) int64 {
+	if amount == 0 || !at.Before(end) {
+		return 0
+	}
+	if !at.After(start) {
+		return amount
+	}
+	total := end.Sub(start)
+	remaining := end.Sub(at)
+	return (amount*int64(remaining/time.Second) + int64(total/time.Second)/2) / int64(total/time.Second)
+}
+
+func per1000Cents(units, rate int64) int64 {
+	if units <= 0 || rate <= 0 {
+		return 0
+	}
+	return (units*rate + 999) / 1000
+}
+
+// ComputeOverageLines prices metered usage above the plan's allowances.
+// Clicks are billed per day above the daily limit (the grace band); API
+// calls and webhook deliveries above the included amount for the period;
+// storage above the included bytes at the highest snapshot.
+func ComputeOverageLines(pb models.PlanBilling, maxClicksPerDay int64, days []models.TenantUsageDaily) []models.TenantInvoiceLine {
+	var extraClicks, apiCalls, webhooks, storage int64
+	for _, day := range days {
+		if day.Clicks > maxClicksPerDay {
+			extraClicks += day.Clicks - maxClicksPerDay
+		}
+		apiCalls += day.APICalls
+		webhooks += day.WebhookDeliveries
+		if day.StorageBytes > storage {
+			storage = day.StorageBytes
+		}
+	}
+
+	var lines []models.TenantInvoiceLine
+	add := func(metric models.UsageMetric, description string, quantity, unit, amount int64) {
+		if amount <= 0 {
+			return
+		}
+		lines = append(lines, models.TenantInvoiceLine{
+			Kind:            models.TenantInvoiceLineOverage,
+			Description:     description,
+			Metric:          string(metric),
+			Quantity:        quantity,
+			UnitAmountCents: unit,
+			AmountCents:     amount,
+		})
+	}
+
+	add(models.UsageMetricClicks, "Clicks above daily limit (per 1,000)",
+		extraClicks, pb.ClickOveragePer1000Cents, per1000Cents(extraClicks, pb.ClickOveragePer1000Cents))
+
+	extraAPI := apiCalls - pb.IncludedAPICalls
+	add(models.UsageMetricAPICalls, "API calls above included (per 1,000)",
+		extraAPI, pb.APICallOveragePer1000Cents, per1000Cents(extraAPI, pb.APICallOveragePer1000Cents))
+
+	extraWebhooks := webhooks - pb.IncludedWebhookDeliveries
+	add(models.UsageMetricWebhookDeliveries, "Webhook deliveries above included (per 1,000)",
+		extraWebhooks, pb.WebhookOveragePer1000Cents, per1000Cents(extraWebhooks, pb.WebhookOveragePer1000Cents))
+
+	if extraStorage := storage - pb.IncludedStorageBytes; extraStorage > 0 {
+		gb := (extraStorage + (1 << 30) - 1) / (1 << 30)
+		add(models.UsageMetricStorageBytes, "Storage above included (per GB)",
+			gb, pb.StorageOveragePerGBCents, gb*pb.StorageOveragePerGBCents)
+	}
+
+	return lines
+}
+
+// ============================================
+// SUBSCRIPTIONS
+// ============================================
+
+// GetSubscription returns the tenant's subscription, creating a monthly one
+// on the tenant's current plan if none exists yet
+func (s *BillingService) GetSubscription(tenantID uuid.UUID) (*models.TenantSubscription, error) {
+	var sub models.TenantSubscription
+	err := s.db.First(&sub, "tenant_id = ?", tenantID).Error
+	if err == nil {
+		return &sub, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	tenant, err := s.tenants.GetTenant(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	sub = models.TenantSubscription{
+		TenantID:             tenantID,
+		Plan:                 tenant.Plan,
+		Cycle:                models.BillingCycleMonthly,
+		Status:               models.SubscriptionActive,
+		Provider:             s.ProviderName(),
+		CurrentPeriodStart:   now,
+		CurrentPeriodEnd:     BillingPeriodEnd(now, models.BillingCycleMonthly),
+		OverageBilledThrough: now,
+	}
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&sub).Error; err != nil {
+		return nil, err
+	}
+	// Another request may have created it first
+	if err := s.db.First(&sub, "tenant_id = ?", tenantID).Error; err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// ChangePlan moves the tenant to a plan and cycle. The unused part of the
+// current period is credited and the new price charged pro rata; a cycle
+// change starts a new period. Overage under the old plan is billed up to now.
+// An empty cycle keeps the current one.
+func (s *BillingService) ChangePlan(tenantID uuid.UUID, plan models.TenantPlan, cycle models.BillingCycle) (*models.TenantSubscription, *models.TenantInvoice, error) {
+	switch plan {
+	case models.TenantPlanFree, models.TenantPlanPro, models.TenantPlanEnterprise:
+	default:
+		return nil, nil, ErrBillingInvalidPlan
+	}
+
+	sub, err := s.GetSubscription(tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cycle == "" {
+		cycle = sub.Cycle
+	}
+	if cycle != models.BillingCycleMonthly && cycle != models.BillingCycleAnnual {
+		return nil, nil, ErrBillingInvalidCycle
+	}
+	if sub.Plan == plan && sub.Cycle == cycle && sub.Status != models.SubscriptionCanceled {
+		return nil, nil, ErrBillingNoChange
+	}
+
+	tenant, err := s.tenants.GetTenant(tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now().UTC()
+	oldBilling := models.GetPlanBilling(sub.Plan)
+	newBilling := models.GetPlanBilling(plan)
+
+	// Overage under the old plan and limits
+	lines, err := s.overageLines(tenant, oldBilling, sub.OverageBilledThrough, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	sub.OverageBilledThrough = now
+
+	if sub.Status != models.SubscriptionCanceled {
+		if credit := ProrateCents(oldBilling.PriceFor(sub.Cycle), sub.CurrentPeriodStart, sub.CurrentPeriodEnd, now); credit > 0 {
+			lines = append(lines, models.TenantInvoiceLine{
+				Kind:            models.TenantInvoiceLineProrationCredit,
+				Description:     fmt.Sprintf("Unused time on %s (%s)", sub.Plan, sub.Cycle),
+				Quantity:        1,
+				UnitAmountCents: -credit,
+				AmountCents:     -credit,
+			})
+		}
+	}
+
+	if cycle == sub.Cycle && sub.Status != models.SubscriptionCanceled {
+		if charge := ProrateCents(newBilling.PriceFor(cycle), sub.CurrentPeriodStart, sub.CurrentPeriodEnd, now); charge > 0 {
+			lines = append(lines, models.TenantInvoiceLine{
+				Kind:            models.TenantInvoiceLineProrationCharge,
+				Description:     fmt.Sprintf("Remaining time on %s (%s)", plan, cycle),
+				Quantity:        1,
+				UnitAmountCents: charge,
+				AmountCents:     charge,
+			})
+		}
+	} else {
+		sub.CurrentPeriodStart = now
+		sub.CurrentPeriodEnd = BillingPeriodEnd(now, cycle)
+		if price := newBilling.PriceFor(cycle); price > 0 {
+			lines = append(lines, models.TenantInvoiceLine{
+				Kind:            models.TenantInvoiceLineSubscription,
+				Description:     fmt.Sprintf("%s plan (%s)", plan, cycle),
+				Quantity:        1,
+				UnitAmountCents: price,
+				AmountCents:     price,
+			})
+		}
+	}
+
+	sub.Plan = plan
+	sub.Cycle = cycle
+	sub.CancelAtPeriodEnd = false
+	sub.CanceledAt = nil
+	if sub.Status == models.SubscriptionCanceled {
+		sub.Status = models.SubscriptionActive
+	}
+
+	var invoice *models.TenantInvoice
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		invoice, err = s.createInvoice(tx, sub, "plan_change", sub.CurrentPeriodStart, sub.CurrentPeriodEnd, lines)
+		if err != nil {
+			return err
+		}
+		return tx.Save(sub).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Limits and features follow the plan
+	if tenant.Plan != plan {
+		if err := s.tenants.ChangePlan(tenantID, plan); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if invoice != nil && invoice.Status == models.TenantInvoiceOpen {
+		if err := s.ChargeInvoice(invoice.ID); err != nil {
+			log.Printf("[Billing] charge for invoice %s failed: %v", invoice.Number, err)
+		}
+		s.db.Preload("Lines").First(invoice, "id = ?", invoice.ID)
+	}
+
+	return sub, invoice, nil
+}
+
+// SetCancelAtPeriodEnd schedules (or undoes) a downgrade to the free plan at
+// the end of the current period
+func (s *BillingService) SetCancelAtPeriodEnd(tenantID uuid.UUID, cancel bool) (*models.TenantSubscription, error) {
+	sub, err := s.GetSubscription(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	sub.CancelAtPeriodEnd = cancel
+	if err := s.db.Model(sub).Update("cancel_at_period_end", cancel).Error; err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// ============================================
+// INVOICES
+// ============================================
+
+// createInvoice builds an invoice from lines, applying and carrying the
+// subscription's credit balance. Nothing is created when there are no lines.
+func (s *BillingService) createInvoice(tx *gorm.DB, sub *models.TenantSubscription, reason string, periodStart, periodEnd time.Time, lines []models.TenantInvoiceLine) (*models.TenantInvoice, error) {
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	var subtotal int64
+	for _, line := range lines {
+		subtotal += line.AmountCents
+	}
+
+	total := subtotal
+	switch {
+	case total < 0:
+		// More credit than charges: carry the difference forward
+		sub.CreditCents += -total
+		lines = append(lines, models.TenantInvoiceLine{
+			Kind:            models.TenantInvoiceLineCredit,
+			Description:     "Credit carried to next invoice",
+			Quantity:        1,
+			UnitAmountCents: -total,
+			AmountCents:     -total,
+		})
+		total = 0
+	case total > 0 && sub.CreditCents > 0:
+		applied := sub.CreditCents
+		if applied > total {
+			applied = total
+		}
+		sub.CreditCents -= applied
+		lines = append(lines, models.TenantInvoiceLine{
+			Kind:            models.TenantInvoiceLineCredit,
+			Description:     "Credit applied",
+			Quantity:        1,
+			UnitAmountCents: -applied,
+			AmountCents:     -applied,
+		})
+		total -= applied
+	}
+
+	now := time.Now().UTC()
+	id := uuid.New()
+	invoice := &models.TenantInvoice{
+		TenantModel:   models.TenantModel{TenantID: sub.TenantID},
+		ID:            id,
+		Number:        fmt.Sprintf("TI-%s-%s", now.Format("200601"), strings.ToUpper(strings.ReplaceAll(id.String(), "-", "")[:10])),
+		Status:        models.TenantInvoiceOpen,
+		Reason:        reason,
+		Currency:      models.BillingCurrency,
+		PeriodStart:   periodStart,
+		PeriodEnd:     periodEnd,
+		SubtotalCents: subtotal,
+		TotalCents:    total,
+		DueAt:         now,
+		NextAttemptAt: &now,
+		Provider:      s.ProviderName(),
+		Lines:         lines,
+	}
+	if total == 0 {
+		invoice.Status = models.TenantInvoicePaid
+		invoice.PaidAt = &now
+		invoice.NextAttemptAt = nil
+	}
+
+	if err := tx.Create(invoice).Error; err != nil {
+		return nil, fmt.Errorf("failed to create invoice: %w", err)
+	}
+	return invoice, nil
+}
+
+// overageLines prices usage between from and to (days in the tenant's
+// timezone, matching the usage buckets; to exclusive)
+func (s *BillingService) overageLines(tenant *models.Tenant, pb models.PlanBilling, from, to time.Time) ([]models.TenantInvoiceLine, error) {
+	loc := GetTenantSettingsResolver(s.db).Location(tenant.ID)
+	fromDay := DayIn(from, loc)
+	toDay := DayIn(to, loc)
+	if !toDay.After(fromDay) {
+		return nil, nil
+	}
+
+	var days []models.TenantUsageDaily
+	if err := s.db.Where("tenant_id = ? AND date >= ? AND date < ?", tenant.ID, fromDay, toDay).
+		Find(&days).Error; err != nil {
+		return nil, err
+	}
+	return ComputeOverageLines(pb, int64(tenant.MaxClicksPerDay), days), nil
+}
+
+// GetInvoice returns an invoice with its lines. tenantID scopes the lookup
+// unless it is uuid.Nil (platform admins).
+func (s *BillingService) GetInvoice(tenantID, invoiceID uuid.UUID) (*models.TenantInvoice, error) {
+	query := s.db.Preload("Lines")
+	if tenantID != uuid.Nil {
+		query = query.Where("tenant_id = ?", tenantID)
+	}
+	var invoice models.TenantInvoice
+	if err := query.First(&invoice, "id = ?", invoiceID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBillingInvoiceNotFound
+		}
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// ListInvoices lists a tenant's invoices, newest first
+func (s *BillingService) ListInvoices(tenantID uuid.UUID, status string, limit, offset int) ([]models.TenantInvoice, int64, error) {
+	query := s.db.Model(&models.TenantInvoice{}).Where("tenant_id = ?", tenantID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	var invoices []models.TenantInvoice
+	err := query.Preload("Lines").Order("created_at DESC").Limit(limit).Offset(offset).Find(&invoices).Error
+	return invoices, total, err
+}
+
+// BillingOverview is the billing page of a tenant
+type BillingOverview struct {
+	Subscription     *models.TenantSubscription `json:"subscription"`
+	Pricing          models.PlanBilling         `json:"pricing"`
+	Provider         string                     `json:"provider"`
+	OpenBalance      int64                      `json:"open_balance_cents"`
+	OpenInvoices     int64                      `json:"open_invoices"`
+	NextRenewalCents int64                      `json:"next_renewal_cents"`
+}
+
+// GetOverview returns subscription, price list and outstanding balance
+func (s *BillingService) GetOverview(tenantID uuid.UUID) (*BillingOverview, error) {
+	sub, err := s.GetSubscription(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	overview := &BillingOverview{
+		Subscription: sub,
+		Pricing:      models.GetPlanBilling(sub.Plan),
+		Provider:     s.ProviderName(),
+	}
+	if !sub.CancelAtPeriodEnd {
+		overview.NextRenewalCents = overview.Pricing.PriceFor(sub.Cycle)
+	}
+
+	s.db.Model(&models.TenantInvoice{}).
+		Where("tenant_id = ? AND status IN ?", tenantID, []models.TenantInvoiceStatus{models.TenantInvoiceOpen, models.TenantInvoiceUncollectible}).
+		Select("COALESCE(SUM(total_cents), 0)").Scan(&overview.OpenBalance)
+	s.db.Model(&models.TenantInvoice{}).
+		Where("tenant_id = ? AND status IN ?", tenantID, []models.TenantInvoiceStatus{models.TenantInvoiceOpen, models.TenantInvoiceUncollectible}).
+		Count(&overview.OpenInvoices)
+
+	return overview, nil
+}
+
+// ============================================
+// COLLECTION & DUNNING
+// ============================================
+
+// ChargeInvoice attempts to collect an open invoice
+func (s *BillingService) ChargeInvoice(invoiceID uuid.UUID) error {
+	invoice, err := s.GetInvoice(uuid.Nil, invoiceID)
+	if err != nil {
+		return err
+	}
+	if invoice.Status != models.TenantInvoiceOpen {
+		return ErrBillingInvoiceNotOpen
+	}
+	if invoice.TotalCents <= 0 {
+		return s.markPaid(invoice, "")
+	}
+
+	invoice.Attempts++
+	if s.provider == nil {
+		return s.recordFailure(invoice, "no payment provider configured - awaiting manual payment")
+	}
+
+	tenant, err := s.tenants.GetTenant(invoice.TenantID)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), billingChargeTimeout)
+	defer cancel()
+
+	customerID, err := s.ensureCustomer(ctx, tenant)
+	if err != nil {
+		return s.recordFailure(invoice, err.Error())
+	}
+
+	result, err := s.provider.Charge(ctx, &PaymentChargeRequest{
+		CustomerID:     customerID,
+		InvoiceID:      invoice.ID,
+		InvoiceNumber:  invoice.Number,
+		AmountCents:    invoice.TotalCents,
+		Currency:       invoice.Currency,
+		IdempotencyKey: fmt.Sprintf("tenant-invoice-%s-%d", invoice.ID, invoice.Attempts),
+	})
+	if err != nil {
+		return s.recordFailure(invoice, err.Error())
+	}
+
+	invoice.ProviderPaymentID = result.PaymentID
+	switch result.Status {
+	case PaymentStatusSucceeded:
+		return s.markPaid(invoice, result.PaymentID)
+	case PaymentStatusProcessing:
+		// The webhook settles it; re-check later in case it never arrives
+		next := time.Now().UTC().Add(BillingProcessingRecheck)
+		return s.db.Model(invoice).Updates(map[string]interface{}{
+			"attempts":            invoice.Attempts,
+			"provider":            s.provider.Name(),
+			"provider_payment_id": result.PaymentID,
+			"last_error":          "",
+			"next_attempt_at":     &next,
+		}).Error
+	default:
+		return s.recordFailure(invoice, result.FailureMessage)
+	}
+}
+
+// ensureCustomer returns the tenant's provider customer, creating it if needed
+func (s *BillingService) ensureCustomer(ctx context.Context, tenant *models.Tenant) (string, error) {
+	if tenant.StripeCustomerID != "" {
+		return tenant.StripeCustomerID, nil
+	}
+
+	email := tenant.BillingEmail
+	if email == "" {
+		email = tenant.AdminEmail
+	}
+	customerID, err := s.provider.CreateCustomer(ctx, &PaymentCustomerRequest{
+		TenantID: tenant.ID,
+		Name:     tenant.Name,
+		Email:    email,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.db.Model(&models.Tenant{}).Where("id = ?", tenant.ID).
+		Update("stripe_customer_id", customerID).Error; err != nil {
+		return "", err
+	}
+	s.tenants.invalidateCache(tenant.ID)
+	return customerID, nil
+}
+
+// recordFailure schedules the next dunning attempt, or suspends the tenant
+// when the schedule is exhausted. invoice.Attempts already counts this attempt.
+func (s *BillingService) recordFailure(invoice *models.TenantInvoice, message string) error {
+	if message == "" {
+		message = "payment failed"
+	}
+	message = truncate(message, 500)
+
+	updates := map[string]interface{}{
+		"attempts":            invoice.Attempts,
+		"last_error":          message,
+		"provider_payment_id": invoice.ProviderPaymentID,
+	}
+
+	exhausted := invoice.Attempts > len(DunningSchedule)
+	var next *time.Time
+	if exhausted {
+		updates["status"] = models.TenantInvoiceUncollectible
+		updates["next_attempt_at"] = nil
+	} else {
+		at := time.Now().UTC().Add(DunningSchedule[invoice.Attempts-1])
+		next = &at
+		updates["next_attempt_at"] = next
+	}
+	if err := s.db.Model(invoice).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	log.Printf("[Billing] invoice %s attempt %d failed: %s", invoice.Number, invoice.Attempts, message)
+
+	tenant, err := s.tenants.GetTenant(invoice.TenantID)
+	if err != nil {
+		return err
+	}
+
+	if exhausted {
+		s.db.Model(&models.TenantSubscription{}).Where("tenant_id = ?", invoice.TenantID).
+			Update("status", models.SubscriptionSuspended)
+		if err := s.tenants.SuspendTenant(invoice.TenantID, "unpaid invoice "+invoice.Number); err != nil {
+			return err
+		}
+		s.notify(tenant, "Your AffTok account has been suspended",
+			fmt.Sprintf("We could not collect payment for invoice %s (%s %.2f) after %d attempts.\n"+
+				"Your account has been suspended. It is reactivated as soon as the invoice is paid.\n\nLast error: %s",
+				invoice.Number, invoice.Currency, float64(invoice.TotalCents)/100, invoice.Attempts, message))
+		return nil
+	}
+
+	s.db.Model(&models.TenantSubscription{}).
+		Where("tenant_id = ? AND status = ?", invoice.TenantID, models.SubscriptionActive).
+		Update("status", models.SubscriptionPastDue)
+	s.notify(tenant, "Payment failed for invoice "+invoice.Number,
+		fmt.Sprintf("We could not collect %s %.2f for invoice %s: %s\n\n"+
+			"We will try again on %s. Please update your payment method to avoid suspension.",
+			invoice.Currency, float64(invoice.TotalCents)/100, invoice.Number, message, next.Format("2006-01-02")))
+	return nil
+}
+
+// markPaid settles an invoice and reactivates a tenant suspended for billing
+// once nothing is left unpaid
+func (s *BillingService) markPaid(invoice *models.TenantInvoice, paymentID string) error {
+	now := time.Now().UTC()
+	updates := map[string]interface{}{
+		"status":          models.TenantInvoicePaid,
+		"paid_at":         &now,
+		"next_attempt_at": nil,
+		"attempts":        invoice.Attempts,
+	}
+	if paymentID != "" {
+		updates["provider_payment_id"] = paymentID
+	}
+	if err := s.db.Model(invoice).Updates(updates).Error; err != nil {
+		return err
+	}
+	invoice.Status = models.TenantInvoicePaid
+	invoice.PaidAt = &now
+
+	var unpaid int64
+	s.db.Model(&models.TenantInvoice{}).
+		Where("tenant_id = ? AND status IN ?", invoice.TenantID, []models.TenantInvoiceStatus{models.TenantInvoiceOpen, models.TenantInvoiceUncollectible}).
+		Where("attempts > 0").
+		Count(&unpaid)
+	if unpaid > 0 {
+		return nil
+	}
+
+	var sub models.TenantSubscription
+	if err := s.db.First(&sub, "tenant_id = ?", invoice.TenantID).Error; err != nil {
+		return nil
+	}
+	switch sub.Status {
+	case models.SubscriptionPastDue:
+		s.db.Model(&sub).Update("status", models.SubscriptionActive)
+	case models.SubscriptionSuspended:
+		s.db.Model(&sub).Update("status", models.SubscriptionActive)
+		if err := s.tenants.ActivateTenant(invoice.TenantID); err != nil {
+			return err
+		}
+		log.Printf("[Billing] tenant %s reactivated after paying %s", invoice.TenantID, invoice.Number)
+	}
+	return nil
+}
+
+// MarkInvoicePaid settles an invoice paid outside the provider (bank transfer)
+func (s *BillingService) MarkInvoicePaid(invoiceID uuid.UUID, reference string) (*models.TenantInvoice, error) {
+	invoice, err := s.GetInvoice(uuid.Nil, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if invoice.Status != models.TenantInvoiceOpen && invoice.Status != models.TenantInvoiceUncollectible {
+		return nil, ErrBillingInvoiceNotOpen
+	}
+	s.db.Model(invoice).Update("provider", "manual")
+	if err := s.markPaid(invoice, reference); err != nil {
+		return nil, err
+	}
+	return s.GetInvoice(uuid.Nil, invoiceID)
+}
+
+func (s *BillingService) notify(tenant *models.Tenant, subject, text string) {
+	to := tenant.BillingEmail
+	if to == "" {
+		to = tenant.AdminEmail
+	}
+	if to == "" || s.email == nil {
+		return
+	}
+	if err := s.email.Send(EmailMessage{To: to, Subject: subject, Text: text}); err != nil {
+		log.Printf("[Billing] failed to email %s: %v", to, err)
+	}
+}
+
+// ============================================
+// WEBHOOKS
+// ============================================
+
+// HandleWebhook verifies and applies a provider webhook. Redelivered events
+// are recognised by ID and ignored (duplicate=true); a failed event is
+// forgotten so the provider's retry is processed again.
+func (s *BillingService) HandleWebhook(headers map[string][]string, body []byte) (event *PaymentEvent, duplicate bool, err error) {
+	if s.provider == nil {
+		return nil, false, ErrPaymentProviderDisabled
+	}
+
+	event, err = s.provider.ParseWebhook(headers, body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	record := &models.BillingWebhookEvent{ID: event.ID, Provider: s.provider.Name(), Type: event.Type}
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return event, false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return event, true, nil
+	}
+
+	if err = s.applyWebhookEvent(event); err != nil {
+		s.db.Where("id = ? AND provider = ?", record.ID, record.Provider).Delete(&models.BillingWebhookEvent{})
+	}
+	return event, false, err
+}
+
+// applyWebhookEvent settles the invoice a webhook refers to
+func (s *BillingService) applyWebhookEvent(event *PaymentEvent) error {
+	invoice, err := s.GetInvoice(uuid.Nil, event.InvoiceID)
+	if err != nil {
+		return err
+	}
+
+	switch event.Type {
+	case PaymentEventSucceeded:
+		if invoice.Status != models.TenantInvoicePaid {
+			return s.markPaid(invoice, event.PaymentID)
+		}
+	case PaymentEventFailed:
+		// Only settle attempts still awaiting a result; synchronous
+		// failures were already recorded by ChargeInvoice
+		if invoice.Status == models.TenantInvoiceOpen && invoice.ProviderPaymentID == event.PaymentID && invoice.LastError == "" {
+			return s.recordFailure(invoice, event.FailureMessage)
+		}
+	}
+	return nil
+}
+
+// ============================================
+// JOBS
+// ============================================
+
+// RunDue renews subscriptions whose period ended (subscription + overage
+// invoice, or downgrade when cancelling) and bills monthly overage for
+// annual subscriptions. Safe to run on every replica: each period is claimed
+// by a conditional update, so only one run bills and charges it.
+func (s *BillingService) RunDue() (int, error) {
+	if err := s.usage.Flush(); err != nil {
+		log.Printf("[Billing] usage flush before billing failed: %v", err)
+	}
+
+	now := time.Now().UTC()
+	var subs []models.TenantSubscription
+	if err := s.db.Where("status IN ?", []models.SubscriptionStatus{models.SubscriptionActive, models.SubscriptionPastDue}).
+		Where("current_period_end <= ? OR (cycle = ? AND overage_billed_through <= ?)",
+			now, models.BillingCycleAnnual, now.AddDate(0, -1, 0)).
+		Where("tenant_id NOT IN (SELECT id FROM tenants WHERE status = ?)", models.TenantStatusDeleted).
+		Limit(billingRenewBatch).
+		Find(&subs).Error; err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for i := range subs {
+		if err := s.renew(&subs[i], now); errors.Is(err, errBillingPeriodClaimed) {
+			continue
+		} else if err != nil {
+			log.Printf("[Billing] renewal for tenant %s failed: %v", subs[i].TenantID, err)
+			continue
+		}
+		processed++
+	}
+	return processed, nil
+}
+
+func (s *BillingService) renew(sub *models.TenantSubscription, now time.Time) error {
+	tenant, err := s.tenants.GetTenant(sub.TenantID)
+	if err != nil {
+		return err
+	}
+	pb := models.GetPlanBilling(sub.Plan)
+	// The period this run read; another replica that renewed first has moved it
+	claimedEnd, claimedThrough := sub.CurrentPeriodEnd, sub.OverageBilledThrough
+
+	var lines []models.TenantInvoiceLine
+	reason := "overage"
+	periodStart, periodEnd := sub.OverageBilledThrough, sub.CurrentPeriodEnd
+	downgrade := false
+
+	if !sub.CurrentPeriodEnd.After(now) {
+		reason = "subscription_cycle"
+		overage, err := s.overageLines(tenant, pb, sub.OverageBilledThrough, sub.CurrentPeriodEnd)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, overage...)
+		sub.OverageBilledThrough = sub.CurrentPeriodEnd
+
+		// Catch up if several periods passed (e.g. the job was down)
+		start := sub.CurrentPeriodEnd
+		for !BillingPeriodEnd(start, sub.Cycle).After(now) {
+			start = BillingPeriodEnd(start, sub.Cycle)
+		}
+
+		if sub.CancelAtPeriodEnd {
+			downgrade = true
+			canceledAt := now
+			sub.Status = models.SubscriptionCanceled
+			sub.CanceledAt = &canceledAt
+			sub.CancelAtPeriodEnd = false
+			sub.Plan = models.TenantPlanFree
+			sub.Cycle = models.BillingCycleMonthly
+		} else if price := pb.PriceFor(sub.Cycle); price > 0 {
+			lines = append(lines, models.TenantInvoiceLine{
+				Kind:            models.TenantInvoiceLineSubscription,
+				Description:     fmt.Sprintf("%s plan (%s)", sub.Plan, sub.Cycle),
+				Quantity:        1,
+				UnitAmountCents: price,
+				AmountCents:     price,
+			})
+		}
+		sub.CurrentPeriodStart = start
+		sub.CurrentPeriodEnd = BillingPeriodEnd(start, sub.Cycle)
+		periodStart, periodEnd = sub.CurrentPeriodStart, sub.CurrentPeriodEnd
+	} else {
+		// Annual plans: overage is billed monthly
+		through := sub.OverageBilledThrough.AddDate(0, 1, 0)
+		overage, err := s.overageLines(tenant, pb, sub.OverageBilledThrough, through)
+		if err != nil {
+			return err
+		}
+		lines = overage
+		periodEnd = through
+		sub.OverageBilledThrough = through
+	}
+
+	var invoice *models.TenantInvoice
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Claim the period: the update only matches while it is still the
+		// one read, so concurrent runs bill it once
+		claim := tx.Model(&models.TenantSubscription{}).
+			Where("tenant_id = ? AND current_period_end = ? AND overage_billed_through = ?", sub.TenantID, claimedEnd, claimedThrough).
+			Updates(map[string]interface{}{
+				"plan":                   sub.Plan,
+				"cycle":                  sub.Cycle,
+				"status":                 sub.Status,
+				"canceled_at":            sub.CanceledAt,
+				"cancel_at_period_end":   sub.CancelAtPeriodEnd,
+				"current_period_start":   sub.CurrentPeriodStart,
+				"current_period_end":     sub.CurrentPeriodEnd,
+				"overage_billed_through": sub.OverageBilledThrough,
+				"updated_at":             now,
+			})
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			return errBillingPeriodClaimed
+		}
+
+		credit := sub.CreditCents
+		var err error
+		invoice, err = s.createInvoice(tx, sub, reason, periodStart, periodEnd, lines)
+		if err != nil || sub.CreditCents == credit {
+			return err
+		}
+		return tx.Model(&models.TenantSubscription{}).Where("tenant_id = ?", sub.TenantID).
+			Update("credit_cents", sub.CreditCents).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if downgrade {
+		if err := s.tenants.ChangePlan(sub.TenantID, models.TenantPlanFree); err != nil {
+			return err
+		}
+	}
+	if invoice != nil && invoice.Status == models.TenantInvoiceOpen {
+		return s.ChargeInvoice(invoice.ID)
+	}
+	return nil
+}
+
+// RunDunning retries open invoices whose next attempt is due
+func (s *BillingService) RunDunning() (int, error) {
+	var invoices []models.TenantInvoice
+	if err := s.db.Select("id").
+		Where("status = ? AND next_attempt_at <= ?", models.TenantInvoiceOpen, time.Now().UTC()).
+		Order("next_attempt_at ASC").
+		Limit(billingRenewBatch).
+		Find(&invoices).Error; err != nil {
+		return 0, err
+	}
+
+	for _, invoice := range invoices {
+		if err := s.ChargeInvoice(invoice.ID); err != nil && !errors.Is(err, ErrBillingInvoiceNotOpen) {
+			log.Printf("[Billing] dunning for invoice %s failed: %v", invoice.ID, err)
+		}
+	}
+	return len(invoices), nil
+}
+
+// StartJobs runs renewals and dunning in the background
+func (s *BillingService) StartJobs() {
+	go func() {
+		ticker := time.NewTicker(BillingJobInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if renewed, err := s.RunDue(); err != nil {
+				log.Printf("[Billing] renewal run failed: %v", err)
+			} else if renewed > 0 {
+				log.Printf("[Billing] renewed %d subscriptions", renewed)
+			}
+			if _, err := s.RunDunning(); err != nil {
+				log.Printf("[Billing] dunning run failed: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ============================================
+// STRIPE ADAPTER
+// ============================================
+
+// StripeConfig holds Stripe API configuration
+type StripeConfig struct {
+	SecretKey     string
+	WebhookSecret string // whsec_... signs webhooks (Stripe-Signature)
+	BaseURL       string
+	Tolerance     time.Duration // max age of a signed webhook
+	HTTPClient    *http.Client
+	Now           func() time.Time
+}
+
+// StripeConfigFromEnv loads Stripe configuration from the environment
+func StripeConfigFromEnv() StripeConfig {
+	cfg := StripeConfig{
+		SecretKey:     os.Getenv("STRIPE_SECRET_KEY"),
+		WebhookSecret: os.Getenv("STRIPE_WEBHOOK_SECRET"),
+		BaseURL:       os.Getenv("STRIPE_API_BASE"),
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.stripe.com"
+	}
+	return cfg
+}
+
+// StripeProvider implements PaymentProvider with Stripe PaymentIntents
+type StripeProvider struct {
+	cfg StripeConfig
+}
+
+// NewStripeProvider creates a Stripe adapter
+func NewStripeProvider(cfg StripeConfig) *StripeProvider {
+	if cfg.Tolerance == 0 {
+		cfg.Tolerance = 5 * time.Minute
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 20 * time.Second}
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	return &StripeProvider{cfg: cfg}
+}
+
+// Name returns the provider identifier
+func (p *StripeProvider) Name() string {
+	return "stripe"
+}
+
+// Enabled reports whether an API key is configured
+func (p *StripeProvider) Enabled() bool {
+	return p.cfg.SecretKey != ""
+}
+
+// CreateCustomer creates a Stripe customer for the tenant
+func (p *StripeProvider) CreateCustomer(ctx context.Context, req *PaymentCustomerRequest) (string, error) {
+	form := url.Values{}
+	form.Set("name", req.Name)
+	if req.Email != "" {
+		form.Set("email", req.Email)
+	}
+	form.Set("metadata[tenant_id]", req.TenantID.String())
+
+	var customer struct {
+		ID string `json:"id"`
+	}
+	if _, err := p.do(ctx, http.MethodPost, "/v1/customers", form, "customer-"+req.TenantID.String(), &customer); err != nil {
+		return "", err
+	}
+	return customer.ID, nil
+}
+
+// Charge confirms an off-session PaymentIntent against the customer's
+// default payment method
+func (p *StripeProvider) Charge(ctx context.Context, req *PaymentChargeRequest) (*PaymentChargeResult, error) {
+	var customer struct {
+		InvoiceSettings struct {
+			DefaultPaymentMethod string `json:"default_payment_method"`
+		} `json:"invoice_settings"`
+	}
+	if _, err := p.do(ctx, http.MethodGet, "/v1/customers/"+url.PathEscape(req.CustomerID), nil, "", &customer); err != nil {
+		return nil, err
+	}
+	if customer.InvoiceSettings.DefaultPaymentMethod == "" {
+		return &PaymentChargeResult{
+			Status:         PaymentStatusFailed,
+			FailureMessage: "no default payment method on file",
+		}, nil
+	}
+
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(req.AmountCents, 10))
+	form.Set("currency", strings.ToLower(req.Currency))
+	form.Set("customer", req.CustomerID)
+	form.Set("payment_method", customer.InvoiceSettings.DefaultPaymentMethod)
+	form.Set("confirm", "true")
+	form.Set("off_session", "true")
+	form.Set("description", "AffTok invoice "+req.InvoiceNumber)
+	form.Set("metadata[tenant_invoice_id]", req.InvoiceID.String())
+	form.Set("metadata[invoice_number]", req.InvoiceNumber)
+
+	var intent stripePaymentIntent
+	status, err := p.do(ctx, http.MethodPost, "/v1/payment_intents", form, req.IdempotencyKey, &intent)
+	if err != nil {
+		// Card declines come back as 402 with the failed intent attached
+		if apiErr, ok := err.(*stripeAPIError); ok && status == http.StatusPaymentRequired {
+			return &PaymentChargeResult{
+				PaymentID:      apiErr.PaymentIntentID,
+				Status:         PaymentStatusFailed,
+				FailureMessage: apiErr.Message,
+			}, nil
+		}
+		return nil, err
+	}
+
+	return intent.result(), nil
+}
+
+// ParseWebhook verifies the Stripe-Signature header and maps PaymentIntent events
+func (p *StripeProvider) ParseWebhook(headers http.Header, body []byte) (*PaymentEvent, error) {
+	if err := p.verifySignature(headers.Get("Stripe-Signature"), body); err != nil {
+		return nil, err
+	}
+
+	var event struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+		Data struct {
+			Object stripePaymentIntent `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("invalid stripe webhook body: %w", err)
+	}
+
+	result := &PaymentEvent{
+		ID:        event.ID,
+		PaymentID: event.Data.Object.ID,
+	}
+	switch event.Type {
+	case "payment_intent.succeeded":
+		result.Type = PaymentEventSucceeded
+	case "payment_intent.payment_failed":
+		result.Type = PaymentEventFailed
+		result.FailureMessage = event.Data.Object.LastPaymentError.Message
+	default:
+		return nil, ErrPaymentEventIgnored
+	}
+
+	invoiceID, err := uuid.Parse(event.Data.Object.Metadata["tenant_invoice_id"])
+	if err != nil {
+		return nil, ErrPaymentEventIgnored
+	}
+	result.InvoiceID = invoiceID
+	return result, nil
+}
+
+// verifySignature checks "t=<ts>,v1=<hmac>" against HMAC-SHA256(ts + "." + body)
+func (p *StripeProvider) verifySignature(header string, body []byte) error {
+	if p.cfg.WebhookSecret == "" || header == "" {
+		return ErrPaymentInvalidSignature
+	}
+
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case "v1":
+			signatures = append(signatures, kv[1])
+		}
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrPaymentInvalidSignature
+	}
+	if age := p.cfg.Now().Sub(time.Unix(ts, 0)); age > p.cfg.Tolerance || age < -p.cfg.Tolerance {
+		return ErrPaymentInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(p.cfg.WebhookSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	for _, sig := range signatures {
+		if hmac.Equal([]byte(expected), []byte(sig)) {
+			return nil
+		}
+	}
+	return ErrPaymentInvalidSignature
+}
+
+// stripePaymentIntent is the subset of a PaymentIntent we read
+type stripePaymentIntent struct {
+	ID               string            `json:"id"`
+	Status           string            `json:"status"`
+	Metadata         map[string]string `json:"metadata"`
+	LastPaymentError struct {
+		Message string `json:"message"`
+	} `json:"last_payment_error"`
+}
+
+func (pi *stripePaymentIntent) result() *PaymentChargeResult {
+	result := &PaymentChargeResult{PaymentID: pi.ID}
+	switch pi.Status {
+	case "succeeded":
+		result.Status = PaymentStatusSucceeded
+	case "processing":
+		result.Status = PaymentStatusProcessing
+	default:
+		// requires_payment_method, requires_action (off-session 3DS), canceled
+		result.Status = PaymentStatusFailed
+		result.FailureMessage = pi.LastPaymentError.Message
+		if result.FailureMessage == "" {
+			result.FailureMessage = "payment " + pi.Status
+		}
+	}
+	return result
+}
+
+// stripeAPIError is an error response from the Stripe API
+type stripeAPIError struct {
+	Status          int
+	Message         string
+	PaymentIntentID string
+}
+
+func (e *stripeAPIError) Error() string {
+	return fmt.Sprintf("stripe: status %d: %s", e.Status, e.Message)
+}
+
+// do sends a form-encoded request to the Stripe API
+func (p *StripeProvider) do(ctx context.Context, method, path string, form url.Values, idempotencyKey string, out interface{}) (int, error) {
+	if !p.Enabled() {
+		return 0, ErrPaymentProviderDisabled
+	}
+
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.cfg.BaseURL+path, body)
+	if err != nil {
+		return 0, err
+	}
+	req.SetBasicAuth(p.cfg.SecretKey, "")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := p.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("stripe request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error struct {
+				Message       string `json:"message"`
+				PaymentIntent struct {
+					ID string `json:"id"`
+				} `json:"payment_intent"`
+			} `json:"error"`
+		}
+		json.Unmarshal(data, &apiErr)
+		return resp.StatusCode, &stripeAPIError{
+			Status:          resp.StatusCode,
+			Message:         apiErr.Error.Message,
+			PaymentIntentID: apiErr.Error.PaymentIntent.ID,
+		}
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("invalid stripe response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
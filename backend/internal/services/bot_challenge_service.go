@@ -0,0 +1,516 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ============================================
+// BOT CHALLENGE SERVICE
+// ============================================
+
+// BotChallengeService serves a lightweight JS interstitial for grey-zone
+// clicks and issues a signed clearance cookie once the browser passes.
+// It does not depend on any third-party captcha provider.
+type BotChallengeService struct {
+	secret       []byte
+	challengeTTL time.Duration
+	clearanceTTL time.Duration
+}
+
+// Bot challenge configuration
+const (
+	BotChallengeCookieName  = "afftok_bc"
+	BotChallengeContextKey  = "botChallenge"
+	BotChallengeVerifyPath  = "/api/c/challenge"
+	DefaultChallengeMinRisk = 40
+	DefaultChallengeMaxRisk = 85
+	// challengeFailScore is the signal score at or above which a challenge fails
+	challengeFailScore = 60
+)
+
+var (
+	processChallengeSecret     []byte
+	processChallengeSecretOnce sync.Once
+)
+
+// botChallengeSecret returns BOT_CHALLENGE_SECRET, or a random secret shared
+// by every challenge service of this process when it is unset. Clearance
+// cookies then only hold on this instance and until it restarts - clients
+// are challenged again rather than accepted on a guessable key.
+func botChallengeSecret() []byte {
+	if secret := os.Getenv("BOT_CHALLENGE_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	processChallengeSecretOnce.Do(func() {
+		processChallengeSecret = make([]byte, 32)
+		if _, err := rand.Read(processChallengeSecret); err != nil {
+			panic(fmt.Sprintf("bot challenge: cannot generate secret: %v", err))
+		}
+		log.Println("⚠️ BOT_CHALLENGE_SECRET not set: using a random per-process secret")
+	})
+	return processChallengeSecret
+}
+
+// BotChallengeMetrics tracks challenge outcomes
+type BotChallengeMetrics struct {
+	Issued       int64 `json:"issued"`
+	Passed       int64 `json:"passed"`
+	Failed       int64 `json:"failed"`
+	InvalidToken int64 `json:"invalid_token"`
+}
+
+var botChallengeMetrics = &BotChallengeMetrics{}
+
+// GetBotChallengeMetrics returns challenge metrics
+func GetBotChallengeMetrics() *BotChallengeMetrics {
+	return &BotChallengeMetrics{
+		Issued:       atomic.LoadInt64(&botChallengeMetrics.Issued),
+		Passed:       atomic.LoadInt64(&botChallengeMetrics.Passed),
+		Failed:       atomic.LoadInt64(&botChallengeMetrics.Failed),
+		InvalidToken: atomic.LoadInt64(&botChallengeMetrics.InvalidToken),
+	}
+}
+
+// NewBotChallengeService creates a new bot challenge service
+func NewBotChallengeService() *BotChallengeService {
+	service := &BotChallengeService{
+		secret:       botChallengeSecret(),
+		challengeTTL: 2 * time.Minute,
+		clearanceTTL: 30 * time.Minute,
+	}
+
+	if ttl := os.Getenv("BOT_CHALLENGE_CLEARANCE_TTL"); ttl != "" {
+		if parsed, err := time.ParseDuration(ttl); err == nil && parsed > 0 {
+			service.clearanceTTL = parsed
+		}
+	}
+
+	return service
+}
+
+// ============================================
+// THRESHOLDS
+// ============================================
+
+// ChallengeThresholds returns the grey-zone risk range for a tenant's settings.
+// Zero values fall back to the platform defaults.
+func ChallengeThresholds(minRisk, maxRisk int) (int, int) {
+	if minRisk <= 0 {
+		minRisk = DefaultChallengeMinRisk
+	}
+	if maxRisk <= 0 || maxRisk > 100 {
+		maxRisk = DefaultChallengeMaxRisk
+	}
+	if minRisk >= maxRisk {
+		minRisk, maxRisk = DefaultChallengeMinRisk, DefaultChallengeMaxRisk
+	}
+	return minRisk, maxRisk
+}
+
+// ============================================
+// CHALLENGE TOKENS
+// ============================================
+
+// botChallengeToken is the signed payload embedded in the challenge page
+type botChallengeToken struct {
+	IPHash    string `json:"ip"`
+	UAHash    string `json:"ua"`
+	ReturnTo  string `json:"r"`
+	RiskScore int    `json:"s"`
+	IssuedAt  int64  `json:"t"`
+	Nonce     string `json:"n"`
+}
+
+// IssueChallenge creates a signed challenge token bound to the client
+func (s *BotChallengeService) IssueChallenge(ip, userAgent, returnTo string, riskScore int) string {
+	nonce := make([]byte, 8)
+	rand.Read(nonce)
+
+	payload, _ := json.Marshal(botChallengeToken{
+		IPHash:    s.hash(ip),
+		UAHash:    s.hash(userAgent),
+		ReturnTo:  returnTo,
+		RiskScore: riskScore,
+		IssuedAt:  time.Now().Unix(),
+		Nonce:     hex.EncodeToString(nonce),
+	})
+
+	atomic.AddInt64(&botChallengeMetrics.Issued, 1)
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.sign(encoded)
+}
+
+// parseChallenge validates a challenge token and returns its payload
+func (s *BotChallengeService) parseChallenge(token, ip, userAgent string) (*botChallengeToken, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("malformed challenge token")
+	}
+
+	if !hmac.Equal([]byte(parts[1]), []byte(s.sign(parts[0]))) {
+		return nil, fmt.Errorf("invalid challenge signature")
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed challenge payload")
+	}
+
+	var payload botChallengeToken
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("malformed challenge payload")
+	}
+
+	if time.Since(time.Unix(payload.IssuedAt, 0)) > s.challengeTTL {
+		return nil, fmt.Errorf("challenge expired")
+	}
+
+	if payload.IPHash != s.hash(ip) || payload.UAHash != s.hash(userAgent) {
+		return nil, fmt.Errorf("challenge client mismatch")
+	}
+
+	return &payload, nil
+}
+
+// ============================================
+// SIGNAL EVALUATION
+// ============================================
+
+// BotChallengeSignals are the browser signals posted back by the challenge page
+type BotChallengeSignals struct {
+	Token          string   `json:"token" binding:"required"`
+	Webdriver      bool     `json:"webdriver"`
+	ScreenWidth    int      `json:"screen_width"`
+	ScreenHeight   int      `json:"screen_height"`
+	ColorDepth     int      `json:"color_depth"`
+	Timezone       string   `json:"timezone"`
+	TimezoneOffset int      `json:"timezone_offset"`
+	CanvasHash     string   `json:"canvas_hash"`
+	Languages      []string `json:"languages"`
+	PluginCount    int      `json:"plugin_count"`
+	TouchPoints    int      `json:"touch_points"`
+	ElapsedMs      int      `json:"elapsed_ms"`
+}
+
+// BotChallengeResult represents the outcome of a verified challenge
+type BotChallengeResult struct {
+	Passed         bool     `json:"passed"`
+	SignalScore    int      `json:"signal_score"`    // 0-100, from browser signals only
+	FraudScore     float64  `json:"fraud_score"`     // 0-100, blended with the request risk
+	InitialRisk    int      `json:"initial_risk"`    // risk score that triggered the challenge
+	Flags          []string `json:"flags,omitempty"` // signals that contributed to the score
+	ReturnTo       string   `json:"return_to"`
+	ClearanceValue string   `json:"-"`
+}
+
+// VerifyChallenge validates a challenge submission and scores its signals
+func (s *BotChallengeService) VerifyChallenge(signals *BotChallengeSignals, ip, userAgent string) (*BotChallengeResult, error) {
+	payload, err := s.parseChallenge(signals.Token, ip, userAgent)
+	if err != nil {
+		atomic.AddInt64(&botChallengeMetrics.InvalidToken, 1)
+		return nil, err
+	}
+
+	score, flags := s.scoreSignals(signals)
+
+	result := &BotChallengeResult{
+		Passed:      score < challengeFailScore,
+		SignalScore: score,
+		FraudScore:  blendFraudScore(score, payload.RiskScore),
+		InitialRisk: payload.RiskScore,
+		Flags:       flags,
+		ReturnTo:    payload.ReturnTo,
+	}
+
+	if result.Passed {
+		atomic.AddInt64(&botChallengeMetrics.Passed, 1)
+		result.ClearanceValue = s.issueClearance(ip, userAgent, result)
+	} else {
+		atomic.AddInt64(&botChallengeMetrics.Failed, 1)
+	}
+
+	return result, nil
+}
+
+// scoreSignals converts browser signals into a 0-100 bot likelihood score
+func (s *BotChallengeService) scoreSignals(signals *BotChallengeSignals) (int, []string) {
+	score := 0
+	flags := make([]string, 0)
+
+	if signals.Webdriver {
+		score += 70
+		flags = append(flags, "webdriver")
+	}
+	if signals.ScreenWidth <= 0 || signals.ScreenHeight <= 0 {
+		score += 25
+		flags = append(flags, "no_screen")
+	}
+	if signals.ColorDepth > 0 && signals.ColorDepth < 15 {
+		score += 10
+		flags = append(flags, "low_color_depth")
+	}
+	if signals.CanvasHash == "" {
+		score += 20
+		flags = append(flags, "no_canvas")
+	}
+	if signals.Timezone == "" {
+		score += 10
+		flags = append(flags, "no_timezone")
+	}
+	if len(signals.Languages) == 0 {
+		score += 10
+		flags = append(flags, "no_languages")
+	}
+	if signals.ElapsedMs >= 0 && signals.ElapsedMs < 30 {
+		// Real browsers need a few frames to load and run the page
+		score += 15
+		flags = append(flags, "instant_submit")
+	}
+
+	if score > 100 {
+		score = 100
+	}
+	return score, flags
+}
+
+// blendFraudScore combines the challenge signal score with the request risk score
+func blendFraudScore(signalScore, initialRisk int) float64 {
+	blended := float64(signalScore)*0.7 + float64(initialRisk)*0.3
+	if blended > 100 {
+		blended = 100
+	}
+	return float64(int(blended*100)) / 100
+}
+
+// ============================================
+// CLEARANCE COOKIE
+// ============================================
+
+// BotChallengeClearance is the verified state carried by the clearance cookie
+type BotChallengeClearance struct {
+	FraudScore  float64
+	SignalScore int
+	Flags       []string
+	ExpiresAt   time.Time
+}
+
+// ClearanceTTL returns how long a clearance cookie stays valid
+func (s *BotChallengeService) ClearanceTTL() time.Duration {
+	return s.clearanceTTL
+}
+
+// issueClearance creates the signed cookie value for a passed challenge
+// Format: expires|fraudScore|signalScore|flags|ipHash|uaHash.signature
+func (s *BotChallengeService) issueClearance(ip, userAgent string, result *BotChallengeResult) string {
+	data := fmt.Sprintf("%d|%.2f|%d|%s|%s|%s",
+		time.Now().Add(s.clearanceTTL).Unix(),
+		result.FraudScore,
+		result.SignalScore,
+		strings.Join(result.Flags, ","),
+		s.hash(ip),
+		s.hash(userAgent),
+	)
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(data))
+	return encoded + "." + s.sign(encoded)
+}
+
+// ValidateClearance checks a clearance cookie for the current client
+func (s *BotChallengeService) ValidateClearance(value, ip, userAgent string) (*BotChallengeClearance, bool) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(s.sign(parts[0]))) {
+		return nil, false
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, false
+	}
+
+	fields := strings.Split(string(raw), "|")
+	if len(fields) != 6 {
+		return nil, false
+	}
+
+	expires, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return nil, false
+	}
+
+	if fields[4] != s.hash(ip) || fields[5] != s.hash(userAgent) {
+		return nil, false
+	}
+
+	fraudScore, _ := strconv.ParseFloat(fields[1], 64)
+	signalScore, _ := strconv.Atoi(fields[2])
+
+	clearance := &BotChallengeClearance{
+		FraudScore:  fraudScore,
+		SignalScore: signalScore,
+		ExpiresAt:   time.Unix(expires, 0),
+	}
+	if fields[3] != "" {
+		clearance.Flags = strings.Split(fields[3], ",")
+	}
+
+	return clearance, true
+}
+
+// MergeChallengeFraud adds a clearance's outcome to a click's existing fraud
+// flags (a JSON array) and score: flags are appended once each and the
+// higher score is kept, so signals recorded before the challenge survive
+func MergeChallengeFraud(flagsJSON string, score float64, clearance *BotChallengeClearance) (string, float64) {
+	var flags []string
+	if flagsJSON != "" {
+		json.Unmarshal([]byte(flagsJSON), &flags)
+	}
+	seen := make(map[string]bool, len(flags))
+	for _, flag := range flags {
+		seen[flag] = true
+	}
+	for _, flag := range append([]string{"js_challenge_passed"}, clearance.Flags...) {
+		if !seen[flag] {
+			seen[flag] = true
+			flags = append(flags, flag)
+		}
+	}
+	merged, _ := json.Marshal(flags)
+	if clearance.FraudScore > score {
+		score = clearance.FraudScore
+	}
+	return string(merged), score
+}
+
+// ============================================
+// CHALLENGE PAGE
+// ============================================
+
+// SafeReturnPath reduces a request URI to a same-origin relative path
+func SafeReturnPath(uri string) string {
+	if !strings.HasPrefix(uri, "/") || strings.HasPrefix(uri, "//") || strings.Contains(uri, "\\") {
+		return "/"
+	}
+	return uri
+}
+
+// GenerateScriptNonce creates a CSP nonce for the inline challenge script
+func GenerateScriptNonce() string {
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+	return base64.StdEncoding.EncodeToString(bytes)
+}
+
+// ChallengePageCSP returns the Content-Security-Policy for the challenge page
+func ChallengePageCSP(nonce string) string {
+	return fmt.Sprintf("default-src 'none'; script-src 'nonce-%s'; style-src 'unsafe-inline'; connect-src 'self'; frame-ancestors 'none'", nonce)
+}
+
+var challengePageTemplate = template.Must(template.New("challenge").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<meta name="robots" content="noindex, nofollow">
+<title>Redirecting…</title>
+<style>
+body{margin:0;min-height:100vh;display:flex;align-items:center;justify-content:center;font-family:-apple-system,BlinkMacSystemFont,'Segoe UI',sans-serif;background:#0f172a;color:#e2e8f0}
+.box{text-align:center}
+.spin{width:36px;height:36px;margin:0 auto 16px;border:3px solid #334155;border-top-color:#3b82f6;border-radius:50%;animation:s 1s linear infinite}
+@keyframes s{to{transform:rotate(360deg)}}
+</style>
+</head>
+<body>
+<div class="box"><div class="spin"></div><p>Checking your browser… / جاري التحقق من المتصفح…</p>
+<noscript><p>Please enable JavaScript to continue.</p></noscript></div>
+<script nonce="{{.Nonce}}">
+(function(){
+  var start = Date.now();
+  function canvasHash(){
+    try {
+      var c = document.createElement('canvas'); c.width = 200; c.height = 40;
+      var x = c.getContext('2d'); if (!x) return '';
+      x.textBaseline = 'top'; x.font = '14px Arial'; x.fillStyle = '#f60'; x.fillRect(100,1,62,20);
+      x.fillStyle = '#069'; x.fillText('AffTok ✓ عربي', 2, 15);
+      var d = c.toDataURL(), h = 0;
+      for (var i = 0; i < d.length; i++) { h = ((h << 5) - h + d.charCodeAt(i)) | 0; }
+      return (h >>> 0).toString(16);
+    } catch (e) { return ''; }
+  }
+  function send(){
+    var tz = '';
+    try { tz = Intl.DateTimeFormat().resolvedOptions().timeZone || ''; } catch (e) {}
+    var body = {
+      token: {{.Token}},
+      webdriver: !!navigator.webdriver,
+      screen_width: screen.width || 0,
+      screen_height: screen.height || 0,
+      color_depth: screen.colorDepth || 0,
+      timezone: tz,
+      timezone_offset: new Date().getTimezoneOffset(),
+      canvas_hash: canvasHash(),
+      languages: navigator.languages ? Array.prototype.slice.call(navigator.languages) : [],
+      plugin_count: navigator.plugins ? navigator.plugins.length : 0,
+      touch_points: navigator.maxTouchPoints || 0,
+      elapsed_ms: Date.now() - start
+    };
+    var r = new XMLHttpRequest();
+    r.open('POST', {{.VerifyPath}}, true);
+    r.setRequestHeader('Content-Type', 'application/json');
+    r.withCredentials = true;
+    r.onload = function(){
+      try { var res = JSON.parse(r.responseText); if (res.redirect) { location.replace(res.redirect); return; } } catch (e) {}
+      document.querySelector('.box p').textContent = 'Access denied';
+    };
+    r.send(JSON.stringify(body));
+  }
+  if (document.readyState === 'complete') { setTimeout(send, 50); } else { window.addEventListener('load', function(){ setTimeout(send, 50); }); }
+})();
+</script>
+</body>
+</html>`))
+
+// RenderChallengePage renders the interstitial page for a challenge token
+func (s *BotChallengeService) RenderChallengePage(token, nonce string) (string, error) {
+	var sb strings.Builder
+	err := challengePageTemplate.Execute(&sb, map[string]string{
+		"Token":      token,
+		"Nonce":      nonce,
+		"VerifyPath": BotChallengeVerifyPath,
+	})
+	if err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// ============================================
+// HELPERS
+// ============================================
+
+// sign creates an HMAC-SHA256 signature
+func (s *BotChallengeService) sign(data string) string {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(data))
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// hash creates a short keyed hash used to bind tokens to a client
+func (s *BotChallengeService) hash(value string) string {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte("client:" + value))
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
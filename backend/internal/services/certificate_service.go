@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ============================================
+// CUSTOM DOMAIN TLS (ACME)
+// ============================================
+//
+// Certificates for verified custom domains are obtained from an ACME CA
+// (Let's Encrypt by default) with the HTTP-01 challenge, answered by our own
+// router on /.well-known/acme-challenge/. Certificates and the ACME account
+// key live in tenant_certificates so every instance shares them.
+//
+// autocert renews certificates it has loaded RenewBefore their expiry; the
+// renewer below loads due certificates after restarts and records status.
+
+// Certificate configuration
+const (
+	CertRenewBefore   = 30 * 24 * time.Hour // renew certificates this long before they expire
+	CertCheckInterval = 12 * time.Hour      // how often certificate status is refreshed
+)
+
+// CertificateService provisions and renews TLS certificates for custom domains
+type CertificateService struct {
+	db      *gorm.DB
+	domains *DomainVerificationService
+	manager *autocert.Manager
+}
+
+// NewCertificateService creates the ACME certificate service.
+// ACME_EMAIL sets the account contact; ACME_DIRECTORY_URL points at another
+// CA directory (e.g. Let's Encrypt staging).
+func NewCertificateService(db *gorm.DB, domains *DomainVerificationService) *CertificateService {
+	s := &CertificateService{
+		db:      db,
+		domains: domains,
+	}
+
+	s.manager = &autocert.Manager{
+		Prompt:      autocert.AcceptTOS,
+		Cache:       &dbCertCache{db: db},
+		HostPolicy:  s.hostPolicy,
+		RenewBefore: CertRenewBefore,
+		Email:       os.Getenv("ACME_EMAIL"),
+	}
+	if directory := os.Getenv("ACME_DIRECTORY_URL"); directory != "" {
+		s.manager.Client = &acme.Client{DirectoryURL: directory}
+	}
+
+	return s
+}
+
+// hostPolicy only allows certificates for verified custom domains
+func (s *CertificateService) hostPolicy(ctx context.Context, host string) error {
+	if !s.domains.IsVerifiedDomain(host) {
+		return fmt.Errorf("acme: %q is not a verified custom domain", host)
+	}
+	return nil
+}
+
+// TLSConfig returns a TLS config that serves custom domain certificates
+func (s *CertificateService) TLSConfig() *tls.Config {
+	return s.manager.TLSConfig()
+}
+
+// HTTPHandler answers HTTP-01 challenges. Mount it on /.well-known/acme-challenge/.
+func (s *CertificateService) HTTPHandler() http.Handler {
+	return s.manager.HTTPHandler(http.NotFoundHandler())
+}
+
+// ============================================
+// ISSUANCE & RENEWAL
+// ============================================
+
+// EnsureCertificate loads the domain's certificate from storage, or obtains
+// one from the CA, and records its status and expiry on the domain
+func (s *CertificateService) EnsureCertificate(domain string) error {
+	s.db.Model(&models.TenantDomain{}).
+		Where("domain = ? AND cert_status IN ?", domain, []string{models.DomainCertNone, models.DomainCertFailed}).
+		Update("cert_status", models.DomainCertPending)
+
+	// autocert bounds issuance with its own timeout
+	cert, err := s.manager.GetCertificate(&tls.ClientHelloInfo{ServerName: domain})
+	if err != nil {
+		s.db.Model(&models.TenantDomain{}).Where("domain = ?", domain).Updates(map[string]interface{}{
+			"cert_status": models.DomainCertFailed,
+			"cert_error":  truncate(err.Error(), 500),
+		})
+		return err
+	}
+
+	leaf := cert.Leaf
+	if leaf == nil && len(cert.Certificate) > 0 {
+		leaf, _ = x509.ParseCertificate(cert.Certificate[0])
+	}
+	updates := map[string]interface{}{
+		"cert_status": models.DomainCertIssued,
+		"cert_error":  "",
+	}
+	if leaf != nil {
+		updates["cert_expires_at"] = leaf.NotAfter
+	}
+	return s.db.Model(&models.TenantDomain{}).Where("domain = ?", domain).Updates(updates).Error
+}
+
+// RenewDue ensures certificates for verified domains that have none yet,
+// failed last time, or expire within CertRenewBefore
+func (s *CertificateService) RenewDue() (renewed, failed int, err error) {
+	var domains []models.TenantDomain
+	err = s.db.Where("is_verified = ?", true).
+		Where("cert_status <> ? OR cert_expires_at IS NULL OR cert_expires_at < ?",
+			models.DomainCertIssued, time.Now().Add(CertRenewBefore)).
+		Find(&domains).Error
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, d := range domains {
+		if err := s.EnsureCertificate(d.Domain); err != nil {
+			log.Printf("[ACME] certificate for %s failed: %v", d.Domain, err)
+			failed++
+			continue
+		}
+		renewed++
+	}
+	return renewed, failed, nil
+}
+
+// StartRenewer runs RenewDue at startup and every CertCheckInterval
+func (s *CertificateService) StartRenewer() {
+	go func() {
+		ticker := time.NewTicker(CertCheckInterval)
+		defer ticker.Stop()
+		for {
+			if renewed, failed, err := s.RenewDue(); err != nil {
+				log.Printf("[ACME] renewal check failed: %v", err)
+			} else if renewed+failed > 0 {
+				log.Printf("[ACME] certificates checked: %d ok, %d failed", renewed, failed)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// ============================================
+// CERTIFICATE STORAGE
+// ============================================
+
+// dbCertCache implements autocert.Cache on the tenant_certificates table
+type dbCertCache struct {
+	db *gorm.DB
+}
+
+func (c *dbCertCache) Get(ctx context.Context, key string) ([]byte, error) {
+	var cert models.TenantCertificate
+	if err := c.db.WithContext(ctx).First(&cert, "key = ?", key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, autocert.ErrCacheMiss
+		}
+		return nil, err
+	}
+	return cert.Data, nil
+}
+
+func (c *dbCertCache) Put(ctx context.Context, key string, data []byte) error {
+	cert := models.TenantCertificate{Key: key, Data: data, UpdatedAt: time.Now()}
+	return c.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"data", "updated_at"}),
+	}).Create(&cert).Error
+}
+
+func (c *dbCertCache) Delete(ctx context.Context, key string) error {
+	return c.db.WithContext(ctx).Where("key = ?", key).Delete(&models.TenantCertificate{}).Error
+}
@@ -111,6 +111,9 @@ func (s *ClickService) TrackClick(c *gin.Context, userOfferID uuid.UUID) (*model
 	// Update Redis counters asynchronously (non-blocking)
 	go s.updateRedisCounters(userOfferID, click.ID)
 
+	// Meter the click for the tenant's plan usage
+	GetUsageService(database.DB).Record(click.TenantID, models.UsageMetricClicks, 1)
+
 	return &click, nil
 }
 
@@ -40,6 +40,10 @@ const (
 	IssueUnacknowledgedMessages  ConsistencyIssueType = "unacknowledged_messages"
 	IssueUserOfferStatsMismatch  ConsistencyIssueType = "user_offer_stats_mismatch"
 	IssueOfferStatsMismatch      ConsistencyIssueType = "offer_stats_mismatch"
+	IssueLedgerUnbalanced        ConsistencyIssueType = "ledger_unbalanced"
+	IssueLedgerMissingPosting    ConsistencyIssueType = "ledger_missing_posting"
+	IssueLedgerMissingReversal   ConsistencyIssueType = "ledger_missing_reversal"
+	IssueLedgerEarningsDrift     ConsistencyIssueType = "ledger_earnings_drift"
 )
 
 // ConsistencyIssueSeverity represents issue severity
@@ -166,6 +170,7 @@ func (s *ConsistencyService) RunFullCheck() (*ConsistencyReport, error) {
 	s.checkRedisDBConsistency(report)
 	s.checkStreamLag(report)
 	s.checkCacheStale(report)
+	s.checkLedger(report)
 
 	// Finalize report
 	report.EndTime = time.Now()
@@ -307,6 +312,84 @@ func (s *ConsistencyService) checkOfferStats(report *ConsistencyReport) {
 	}
 }
 
+// checkLedger reconciles the earnings ledger with conversions and the
+// denormalized earnings counters
+func (s *ConsistencyService) checkLedger(report *ConsistencyReport) {
+	report.ChecksRun = append(report.ChecksRun, "ledger")
+
+	recon, err := NewLedgerService(s.db).Reconcile(nil, 100)
+	if err != nil {
+		s.observability.Log(LogEvent{
+			Category: LogCategorySystemEvent,
+			Level:    LogLevelError,
+			Message:  "Ledger reconciliation failed",
+			Metadata: map[string]interface{}{"error": err.Error()},
+		})
+		return
+	}
+
+	for _, u := range recon.Unbalanced {
+		report.Issues = append(report.Issues, ConsistencyIssue{
+			ID:          uuid.New().String(),
+			Type:        IssueLedgerUnbalanced,
+			Severity:    ConsistencySeverityCritical,
+			Description: fmt.Sprintf("Ledger transaction does not balance in %s", u.Currency),
+			Expected:    0,
+			Actual:      u.Total,
+			Difference:  u.Total,
+			EntityID:    u.TransactionID.String(),
+			EntityType:  "ledger_transaction",
+			Timestamp:   time.Now(),
+			Fixable:     false,
+		})
+	}
+
+	for _, id := range recon.MissingApprovals {
+		report.Issues = append(report.Issues, ConsistencyIssue{
+			ID:          uuid.New().String(),
+			Type:        IssueLedgerMissingPosting,
+			Severity:    ConsistencySeverityError,
+			Description: "Approved conversion has no ledger posting",
+			EntityID:    id.String(),
+			EntityType:  "conversion",
+			Timestamp:   time.Now(),
+			Fixable:     true,
+			FixAction:   "Post conversion_approved (ledger backfill)",
+		})
+	}
+
+	for _, id := range recon.MissingReversals {
+		report.Issues = append(report.Issues, ConsistencyIssue{
+			ID:          uuid.New().String(),
+			Type:        IssueLedgerMissingReversal,
+			Severity:    ConsistencySeverityError,
+			Description: "Rejected conversion is still booked in the ledger",
+			EntityID:    id.String(),
+			EntityType:  "conversion",
+			Timestamp:   time.Now(),
+			Fixable:     true,
+			FixAction:   "Post conversion_reversed",
+		})
+	}
+
+	for _, d := range recon.EarningsDrift {
+		report.Issues = append(report.Issues, ConsistencyIssue{
+			ID:          uuid.New().String(),
+			Type:        IssueLedgerEarningsDrift,
+			Severity:    ConsistencySeverityWarning,
+			Description: fmt.Sprintf("%s %s earnings counter disagrees with the ledger", d.EntityType, d.Currency),
+			Expected:    d.Ledger,
+			Actual:      d.Counter,
+			Difference:  d.Counter - d.Ledger,
+			EntityID:    d.EntityID.String(),
+			EntityType:  d.EntityType,
+			Timestamp:   time.Now(),
+			Fixable:     true,
+			FixAction:   "Reset earnings counters from the ledger",
+		})
+	}
+}
+
 // checkOrphanedConversions checks for conversions without corresponding clicks
 func (s *ConsistencyService) checkOrphanedConversions(report *ConsistencyReport) {
 	report.ChecksRun = append(report.ChecksRun, "orphaned_conversions")
@@ -540,6 +623,30 @@ func (s *ConsistencyService) FixUserOfferStats() (int64, error) {
 	return clicksFixed + result.RowsAffected, result.Error
 }
 
+// FixLedger books approved conversions missing from the ledger, reverses
+// rejected ones still booked, then resets the earnings counters from the
+// ledger. Unbalanced transactions are never fixed automatically.
+func (s *ConsistencyService) FixLedger() (int64, error) {
+	ledger := NewLedgerService(s.db)
+
+	posted, err := ledger.Backfill(nil, 1000)
+	if err != nil {
+		return int64(posted), err
+	}
+
+	recon, err := ledger.Reconcile(nil, 1000)
+	if err != nil {
+		return int64(posted), err
+	}
+	reversed, err := ledger.ReverseMissing(recon.MissingReversals)
+	if err != nil {
+		return int64(posted + reversed), err
+	}
+
+	counters, err := ledger.RebuildEarningsCounters()
+	return int64(posted+reversed) + counters, err
+}
+
 // ============================================
 // GETTERS
 // ============================================
@@ -0,0 +1,516 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/alerting"
+	"github.com/aljapah/afftok-backend-prod/internal/cache"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// CONVERSION ANOMALY SERVICE
+// ============================================
+
+// ConversionAnomalyService monitors conversion behaviour per promoter×offer
+// against the pair's own history using z-scores over rolling windows, and
+// holds new conversions for review while a deviation is open.
+type ConversionAnomalyService struct {
+	db            *gorm.DB
+	observability *ObservabilityService
+	alertManager  *alerting.AlertManager
+}
+
+// Anomaly detection configuration
+const (
+	AnomalyZThreshold      = 3.0              // |z| at which a metric is flagged
+	AnomalyBaselineDays    = 30               // history used to build the baseline
+	AnomalyCurrentWindow   = 24 * time.Hour   // rolling window compared with the baseline
+	AnomalyMinBaselineDays = 7                // days with activity required before scoring
+	AnomalyMinSample       = 5                // conversions required in the current window
+	AnomalyMinHourSample   = 10               // conversions required for the hour-of-day test
+	AnomalyEvalThrottle    = 10 * time.Minute // minimum time between evaluations of a pair
+	anomalyHourDegrees     = 23.0             // chi-square degrees of freedom for 24 buckets
+)
+
+// NewConversionAnomalyService creates a new conversion anomaly service
+func NewConversionAnomalyService(db *gorm.DB) *ConversionAnomalyService {
+	return &ConversionAnomalyService{
+		db:            db,
+		observability: NewObservabilityService(),
+		alertManager:  alerting.GetAlertManager(),
+	}
+}
+
+// ============================================
+// HOLDS
+// ============================================
+
+// IsHeld reports whether new conversions for the pair should go to review
+func (s *ConversionAnomalyService) IsHeld(userID, offerID uuid.UUID) bool {
+	var count int64
+	s.db.Model(&models.ConversionAnomaly{}).
+		Where("user_id = ? AND offer_id = ? AND status = ?", userID, offerID, models.AnomalyStatusOpen).
+		Count(&count)
+	return count > 0
+}
+
+// RecordHeld increments the held counter on the pair's open anomalies
+func (s *ConversionAnomalyService) RecordHeld(userID, offerID uuid.UUID) {
+	s.db.Model(&models.ConversionAnomaly{}).
+		Where("user_id = ? AND offer_id = ? AND status = ?", userID, offerID, models.AnomalyStatusOpen).
+		UpdateColumn("held_count", gorm.Expr("held_count + 1"))
+}
+
+// ============================================
+// EVALUATION
+// ============================================
+
+// MetricDeviation represents the evaluation of one metric for a pair
+type MetricDeviation struct {
+	Metric     string  `json:"metric"`
+	Observed   float64 `json:"observed"`
+	Baseline   float64 `json:"baseline"`
+	StdDev     float64 `json:"std_dev"`
+	ZScore     float64 `json:"z_score"`
+	SampleSize int     `json:"sample_size"`
+	Flagged    bool    `json:"flagged"`
+}
+
+// dailyConversionRow is a per-day aggregate for a pair
+type dailyConversionRow struct {
+	Day         time.Time
+	Conversions int64
+	Approved    int64
+	Rejected    int64
+	AvgAmount   float64
+}
+
+// dailyClickRow is a per-day click count for a pair
+type dailyClickRow struct {
+	Day    time.Time
+	Clicks int64
+}
+
+// hourRow is a per-hour conversion count
+type hourRow struct {
+	Hour  int
+	Count int64
+}
+
+// ScheduleEvaluation evaluates a pair in the background, at most once per throttle window
+func (s *ConversionAnomalyService) ScheduleEvaluation(userID, offerID uuid.UUID) {
+	if cache.RedisClient != nil {
+		key := fmt.Sprintf("conv_anomaly_eval:%s:%s", userID.String(), offerID.String())
+		ok, err := cache.SetNX(context.Background(), key, "1", AnomalyEvalThrottle)
+		if err == nil && !ok {
+			return
+		}
+	}
+
+	go func() {
+		if _, err := s.EvaluatePair(userID, offerID); err != nil {
+			log.Printf("[ConvAnomaly] evaluation failed for %s/%s: %v", userID, offerID, err)
+		}
+	}()
+}
+
+// EvaluatePair scores all metrics for a promoter×offer and opens anomalies for deviations
+func (s *ConversionAnomalyService) EvaluatePair(userID, offerID uuid.UUID) ([]MetricDeviation, error) {
+	now := time.Now().UTC()
+	windowStart := now.Add(-AnomalyCurrentWindow)
+	baselineStart := windowStart.AddDate(0, 0, -AnomalyBaselineDays)
+
+	var convRows []dailyConversionRow
+	if err := s.db.Raw(`
+		SELECT DATE(c.converted_at) AS day,
+			COUNT(*) AS conversions,
+			SUM(CASE WHEN c.status IN ('approved', 'paid') THEN 1 ELSE 0 END) AS approved,
+			SUM(CASE WHEN c.status = 'rejected' THEN 1 ELSE 0 END) AS rejected,
+			COALESCE(AVG(c.amount), 0) AS avg_amount
+		FROM conversions c
+		JOIN user_offers uo ON uo.id = c.user_offer_id
+		WHERE uo.user_id = ? AND uo.offer_id = ? AND c.converted_at >= ? AND c.converted_at < ?
+		GROUP BY DATE(c.converted_at)
+	`, userID, offerID, baselineStart, windowStart).Scan(&convRows).Error; err != nil {
+		return nil, err
+	}
+
+	var clickRows []dailyClickRow
+	if err := s.db.Raw(`
+		SELECT DATE(cl.clicked_at) AS day, COUNT(*) AS clicks
+		FROM clicks cl
+		JOIN user_offers uo ON uo.id = cl.user_offer_id
+		WHERE uo.user_id = ? AND uo.offer_id = ? AND cl.clicked_at >= ? AND cl.clicked_at < ?
+		GROUP BY DATE(cl.clicked_at)
+	`, userID, offerID, baselineStart, windowStart).Scan(&clickRows).Error; err != nil {
+		return nil, err
+	}
+
+	var current dailyConversionRow
+	if err := s.db.Raw(`
+		SELECT COUNT(*) AS conversions,
+			SUM(CASE WHEN c.status IN ('approved', 'paid') THEN 1 ELSE 0 END) AS approved,
+			SUM(CASE WHEN c.status = 'rejected' THEN 1 ELSE 0 END) AS rejected,
+			COALESCE(AVG(c.amount), 0) AS avg_amount
+		FROM conversions c
+		JOIN user_offers uo ON uo.id = c.user_offer_id
+		WHERE uo.user_id = ? AND uo.offer_id = ? AND c.converted_at >= ?
+	`, userID, offerID, windowStart).Scan(&current).Error; err != nil {
+		return nil, err
+	}
+
+	var currentClicks int64
+	s.db.Raw(`
+		SELECT COUNT(*) FROM clicks cl
+		JOIN user_offers uo ON uo.id = cl.user_offer_id
+		WHERE uo.user_id = ? AND uo.offer_id = ? AND cl.clicked_at >= ?
+	`, userID, offerID, windowStart).Scan(&currentClicks)
+
+	deviations := make([]MetricDeviation, 0, 4)
+
+	if len(convRows) >= AnomalyMinBaselineDays && current.Conversions >= AnomalyMinSample {
+		clicksByDay := make(map[string]int64, len(clickRows))
+		for _, row := range clickRows {
+			clicksByDay[row.Day.Format("2006-01-02")] = row.Clicks
+		}
+
+		var convRates, approvalRates, avgValues []float64
+		for _, row := range convRows {
+			if clicks := clicksByDay[row.Day.Format("2006-01-02")]; clicks > 0 {
+				convRates = append(convRates, float64(row.Conversions)/float64(clicks))
+			}
+			if decided := row.Approved + row.Rejected; decided > 0 {
+				approvalRates = append(approvalRates, float64(row.Approved)/float64(decided))
+			}
+			avgValues = append(avgValues, row.AvgAmount)
+		}
+
+		// Conversion rate: only an unusual rise is suspicious
+		if currentClicks > 0 && len(convRates) >= AnomalyMinBaselineDays {
+			d := ScoreDeviation(models.AnomalyMetricConversionRate, float64(current.Conversions)/float64(currentClicks), convRates, 0.01)
+			d.SampleSize = int(currentClicks)
+			d.Flagged = d.ZScore >= AnomalyZThreshold
+			deviations = append(deviations, d)
+		}
+
+		// Approval rate: only an unusual drop is suspicious
+		if decided := current.Approved + current.Rejected; decided >= AnomalyMinSample && len(approvalRates) >= AnomalyMinBaselineDays {
+			d := ScoreDeviation(models.AnomalyMetricApprovalRate, float64(current.Approved)/float64(decided), approvalRates, 0.05)
+			d.SampleSize = int(decided)
+			d.Flagged = d.ZScore <= -AnomalyZThreshold
+			deviations = append(deviations, d)
+		}
+
+		// Average order value: deviation in either direction
+		d := ScoreDeviation(models.AnomalyMetricAvgOrderValue, current.AvgAmount, avgValues, 1)
+		d.SampleSize = int(current.Conversions)
+		d.Flagged = math.Abs(d.ZScore) >= AnomalyZThreshold
+		deviations = append(deviations, d)
+	}
+
+	if hourDeviation, ok := s.scoreHourDistribution(userID, offerID, baselineStart, windowStart); ok {
+		deviations = append(deviations, hourDeviation)
+	}
+
+	for _, d := range deviations {
+		if d.Flagged {
+			s.openAnomaly(userID, offerID, d)
+		}
+	}
+
+	return deviations, nil
+}
+
+// scoreHourDistribution compares the current hour-of-day distribution with the
+// baseline using a chi-square statistic normalised to a z-score
+func (s *ConversionAnomalyService) scoreHourDistribution(userID, offerID uuid.UUID, baselineStart, windowStart time.Time) (MetricDeviation, bool) {
+	query := `
+		SELECT EXTRACT(HOUR FROM c.converted_at)::int AS hour, COUNT(*) AS count
+		FROM conversions c
+		JOIN user_offers uo ON uo.id = c.user_offer_id
+		WHERE uo.user_id = ? AND uo.offer_id = ? AND c.converted_at >= ? AND c.converted_at < ?
+		GROUP BY hour
+	`
+
+	var baselineRows, currentRows []hourRow
+	if err := s.db.Raw(query, userID, offerID, baselineStart, windowStart).Scan(&baselineRows).Error; err != nil {
+		return MetricDeviation{}, false
+	}
+	if err := s.db.Raw(query, userID, offerID, windowStart, time.Now().UTC().Add(time.Minute)).Scan(&currentRows).Error; err != nil {
+		return MetricDeviation{}, false
+	}
+
+	var baseline, observed [24]float64
+	for _, row := range baselineRows {
+		if row.Hour >= 0 && row.Hour < 24 {
+			baseline[row.Hour] += float64(row.Count)
+		}
+	}
+	for _, row := range currentRows {
+		if row.Hour >= 0 && row.Hour < 24 {
+			observed[row.Hour] += float64(row.Count)
+		}
+	}
+
+	return ScoreHourDistribution(baseline, observed)
+}
+
+// ScoreHourDistribution scores hour-of-day conversion counts against the
+// baseline counts; false when either side is too small to compare
+func ScoreHourDistribution(baseline, observed [24]float64) (MetricDeviation, bool) {
+	var baselineTotal, observedTotal float64
+	for h := 0; h < 24; h++ {
+		baselineTotal += baseline[h]
+		observedTotal += observed[h]
+	}
+
+	if baselineTotal < AnomalyMinHourSample*5 || observedTotal < AnomalyMinHourSample {
+		return MetricDeviation{}, false
+	}
+
+	// Laplace smoothing keeps hours the promoter never converted in from dominating
+	chi2 := 0.0
+	for h := 0; h < 24; h++ {
+		p := (baseline[h] + 1) / (baselineTotal + 24)
+		expected := observedTotal * p
+		chi2 += (observed[h] - expected) * (observed[h] - expected) / expected
+	}
+
+	z := (chi2 - anomalyHourDegrees) / math.Sqrt(2*anomalyHourDegrees)
+
+	return MetricDeviation{
+		Metric:     models.AnomalyMetricHourOfDay,
+		Observed:   round2(chi2),
+		Baseline:   anomalyHourDegrees,
+		StdDev:     round2(math.Sqrt(2 * anomalyHourDegrees)),
+		ZScore:     round2(z),
+		SampleSize: int(observedTotal),
+		Flagged:    z >= AnomalyZThreshold,
+	}, true
+}
+
+// ScoreDeviation computes the z-score of an observation against a daily series
+func ScoreDeviation(metric string, observed float64, series []float64, minStdDev float64) MetricDeviation {
+	mean := 0.0
+	for _, v := range series {
+		mean += v
+	}
+	mean /= float64(len(series))
+
+	stdDev := calculateStdDev(series, mean)
+	// Floor the deviation so very stable histories don't flag tiny changes
+	floor := math.Max(minStdDev, math.Abs(mean)*0.05)
+	if stdDev < floor {
+		stdDev = floor
+	}
+
+	return MetricDeviation{
+		Metric:   metric,
+		Observed: round2(observed*10000) / 10000,
+		Baseline: round2(mean*10000) / 10000,
+		StdDev:   round2(stdDev*10000) / 10000,
+		ZScore:   round2((observed - mean) / stdDev),
+	}
+}
+
+// openAnomaly records a flagged deviation (once per open pair+metric) and raises an alert
+func (s *ConversionAnomalyService) openAnomaly(userID, offerID uuid.UUID, d MetricDeviation) {
+	var existing int64
+	s.db.Model(&models.ConversionAnomaly{}).
+		Where("user_id = ? AND offer_id = ? AND metric = ? AND status = ?", userID, offerID, d.Metric, models.AnomalyStatusOpen).
+		Count(&existing)
+	if existing > 0 {
+		return
+	}
+
+	anomaly := models.ConversionAnomaly{
+		ID:         uuid.New(),
+		UserID:     userID,
+		OfferID:    offerID,
+		Metric:     d.Metric,
+		Observed:   d.Observed,
+		Baseline:   d.Baseline,
+		StdDev:     d.StdDev,
+		ZScore:     d.ZScore,
+		SampleSize: d.SampleSize,
+		Status:     models.AnomalyStatusOpen,
+		DetectedAt: time.Now().UTC(),
+	}
+	if err := s.db.Create(&anomaly).Error; err != nil {
+		log.Printf("[ConvAnomaly] failed to record anomaly: %v", err)
+		return
+	}
+
+	metadata := map[string]interface{}{
+		"anomaly_id": anomaly.ID.String(),
+		"user_id":    userID.String(),
+		"offer_id":   offerID.String(),
+		"metric":     d.Metric,
+		"z_score":    d.ZScore,
+	}
+
+	s.observability.LogFraud("", "", "conversion_anomaly:"+d.Metric, int(math.Min(100, math.Abs(d.ZScore)*20)), 0.8,
+		[]string{"conversion_anomaly", d.Metric}, metadata)
+
+	s.alertManager.CreateAlert(
+		alerting.AlertConversionAnomaly,
+		alerting.AlertSeverityWarning,
+		"Conversion Anomaly Detected",
+		fmt.Sprintf("Promoter %s on offer %s deviates on %s (z=%.2f). New conversions are held for review.",
+			userID.String(), offerID.String(), d.Metric, d.ZScore),
+		d.Observed,
+		d.Baseline,
+		metadata,
+	)
+
+	GetFraudCaseService(s.db).OpenFromAnomaly(&anomaly)
+}
+
+// ============================================
+// RESOLUTION
+// ============================================
+
+// ResolveAnomaly closes a tenant's anomaly. Once the pair has no open anomalies left,
+// its held conversions are released to the status the advertiser reported
+// (approved ones are booked in the ledger) or rejected.
+func (s *ConversionAnomalyService) ResolveAnomaly(tenantID, anomalyID uuid.UUID, decision string, resolvedBy *uuid.UUID, notes string) (*models.ConversionAnomaly, int64, error) {
+	if decision != models.AnomalyStatusReleased && decision != models.AnomalyStatusRejected {
+		return nil, 0, fmt.Errorf("decision must be %q or %q", models.AnomalyStatusReleased, models.AnomalyStatusRejected)
+	}
+
+	var anomaly models.ConversionAnomaly
+	var affected int64
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&anomaly, "id = ? AND tenant_id = ?", anomalyID, tenantID).Error; err != nil {
+			return err
+		}
+		if !anomaly.IsOpen() {
+			return fmt.Errorf("anomaly already resolved")
+		}
+
+		now := time.Now().UTC()
+		anomaly.Status = decision
+		anomaly.ResolvedAt = &now
+		anomaly.ResolvedBy = resolvedBy
+		anomaly.Notes = notes
+		if err := tx.Save(&anomaly).Error; err != nil {
+			return err
+		}
+
+		var stillOpen int64
+		tx.Model(&models.ConversionAnomaly{}).
+			Where("user_id = ? AND offer_id = ? AND status = ?", anomaly.UserID, anomaly.OfferID, models.AnomalyStatusOpen).
+			Count(&stillOpen)
+		if stillOpen > 0 {
+			return nil
+		}
+
+		var held []models.Conversion
+		if err := tx.Where("status = ? AND user_offer_id IN (?)", models.ConversionStatusReview,
+			tx.Model(&models.UserOffer{}).Select("id").Where("user_id = ? AND offer_id = ?", anomaly.UserID, anomaly.OfferID)).
+			Find(&held).Error; err != nil {
+			return err
+		}
+
+		ledger := GetLedgerService(s.db)
+		for i := range held {
+			conv := &held[i]
+			updates := map[string]interface{}{
+				"status":           models.ConversionStatusRejected,
+				"rejection_reason": "Rejected after conversion anomaly review",
+				"held_status":      "",
+			}
+			if decision == models.AnomalyStatusReleased {
+				conv.Status = ReleasedConversionStatus(conv.HeldStatus)
+				updates = map[string]interface{}{"status": conv.Status, "held_status": ""}
+				if conv.Status == models.ConversionStatusApproved {
+					conv.ApprovedAt = &now
+					updates["approved_at"] = now
+				}
+			}
+			if err := tx.Model(&models.Conversion{}).Where("id = ?", conv.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+			// Released approvals are booked like any other approval
+			if conv.Status == models.ConversionStatusApproved {
+				if _, err := ledger.PostConversionApproved(tx, conv); err != nil {
+					return err
+				}
+			}
+			affected++
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, 0, err
+	}
+	return &anomaly, affected, nil
+}
+
+// ReleasedConversionStatus returns the status a held conversion goes back to
+// on release: approved if the advertiser approved it, pending otherwise
+func ReleasedConversionStatus(heldStatus string) string {
+	if heldStatus == models.ConversionStatusApproved {
+		return models.ConversionStatusApproved
+	}
+	return models.ConversionStatusPending
+}
+
+// ============================================
+// LISTING
+// ============================================
+
+// ListAnomalies returns a tenant's anomalies filtered by status (empty = all)
+func (s *ConversionAnomalyService) ListAnomalies(tenantID uuid.UUID, status string, limit int) ([]models.ConversionAnomaly, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	query := s.db.Preload("User").Preload("Offer").Where("tenant_id = ?", tenantID).Order("detected_at DESC").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var anomalies []models.ConversionAnomaly
+	err := query.Find(&anomalies).Error
+	return anomalies, err
+}
+
+// CountHeldConversions returns the number of a tenant's conversions currently in review
+func (s *ConversionAnomalyService) CountHeldConversions(tenantID uuid.UUID) int64 {
+	var count int64
+	s.db.Model(&models.Conversion{}).Where("tenant_id = ? AND status = ?", tenantID, models.ConversionStatusReview).Count(&count)
+	return count
+}
+
+// round2 rounds to two decimal places
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// ============================================
+// GLOBAL INSTANCE
+// ============================================
+
+var (
+	conversionAnomalyInstance *ConversionAnomalyService
+	conversionAnomalyOnce     sync.Once
+)
+
+// GetConversionAnomalyService returns the global conversion anomaly service
+func GetConversionAnomalyService(db *gorm.DB) *ConversionAnomalyService {
+	conversionAnomalyOnce.Do(func() {
+		conversionAnomalyInstance = NewConversionAnomalyService(db)
+	})
+	return conversionAnomalyInstance
+}
@@ -0,0 +1,404 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// CUSTOM DOMAIN VERIFICATION
+// ============================================
+//
+// A tenant proves it controls a custom domain in one of two ways:
+//
+//   - TXT:   _afftok-verify.<domain>  TXT  "afftok-verify=<token>"
+//   - CNAME: <domain>                 CNAME <token>.<platform host>
+//
+// The CNAME target carries the token so that pointing a domain at the shared
+// platform host is not by itself proof of ownership.
+//
+// Only verified domains resolve to a tenant, get TLS certificates and are used
+// for links. Verified domains are re-checked periodically and lose their
+// verification after DomainReverifyMaxFailures consecutive failed checks.
+
+// DomainResolver looks up the DNS records used as proof of ownership.
+// *net.Resolver satisfies it; tests plug in a stub.
+type DomainResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupCNAME(ctx context.Context, host string) (string, error)
+}
+
+// Domain verification configuration
+const (
+	DomainVerifyRecordPrefix  = "_afftok-verify." // TXT record name prefix
+	DomainVerifyTokenPrefix   = "afftok-verify="  // TXT record value prefix
+	DomainCheckInterval       = 15 * time.Minute  // how often the checker runs
+	DomainReverifyInterval    = 6 * time.Hour     // verified domains are re-checked this often
+	DomainPendingCheckWindow  = 72 * time.Hour    // new domains are auto-checked for this long
+	DomainReverifyMaxFailures = 3                 // consecutive failures before a domain is unverified
+	domainLookupTimeout       = 10 * time.Second
+	domainCacheTTL            = time.Minute
+)
+
+// Errors returned by domain verification
+var (
+	ErrDomainInvalid            = errors.New("invalid domain name")
+	ErrDomainReserved           = errors.New("domain is reserved by the platform")
+	ErrDomainNotFound           = errors.New("domain not found")
+	ErrDomainTaken              = errors.New("domain is already registered")
+	ErrDomainVerificationFailed = errors.New("domain ownership could not be verified")
+)
+
+var domainLabelRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// DomainVerificationService verifies custom domain ownership through DNS
+type DomainVerificationService struct {
+	db          *gorm.DB
+	tenants     *TenantService
+	resolver    DomainResolver
+	cnameTarget string
+	reserved    []string
+
+	verifiedHosts sync.Map // host -> domainCacheEntry (bool)
+	baseURLs      sync.Map // tenantID -> domainCacheEntry (string)
+}
+
+type domainCacheEntry struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+var (
+	domainVerificationService *DomainVerificationService
+	domainVerificationOnce    sync.Once
+)
+
+// GetDomainVerificationService returns the singleton domain verification service
+func GetDomainVerificationService(db *gorm.DB) *DomainVerificationService {
+	domainVerificationOnce.Do(func() {
+		domainVerificationService = NewDomainVerificationService(db, net.DefaultResolver)
+	})
+	return domainVerificationService
+}
+
+// NewDomainVerificationService creates a domain verification service
+func NewDomainVerificationService(db *gorm.DB, resolver DomainResolver) *DomainVerificationService {
+	target := strings.ToLower(strings.TrimSuffix(os.Getenv("CUSTOM_DOMAIN_CNAME_TARGET"), "."))
+	if target == "" {
+		target = "domains.afftokapp.com"
+	}
+
+	s := &DomainVerificationService{
+		db:          db,
+		resolver:    resolver,
+		cnameTarget: target,
+		reserved:    []string{"afftokapp.com", "afftok.com", target},
+	}
+	if db != nil {
+		s.tenants = NewTenantService(db)
+	}
+	return s
+}
+
+// SetResolver replaces the DNS resolver
+func (s *DomainVerificationService) SetResolver(resolver DomainResolver) {
+	s.resolver = resolver
+}
+
+// CNAMETarget returns the host custom domains may CNAME to
+func (s *DomainVerificationService) CNAMETarget() string {
+	return s.cnameTarget
+}
+
+// TokenCNAMETarget returns the token-bound CNAME target of a domain
+func (s *DomainVerificationService) TokenCNAMETarget(token string) string {
+	return token + "." + s.cnameTarget
+}
+
+// ============================================
+// HOSTNAMES & TOKENS
+// ============================================
+
+// NormalizeDomain lowercases and validates a custom hostname. IP addresses,
+// wildcards, single-label names and platform domains are rejected.
+func (s *DomainVerificationService) NormalizeDomain(domain string) (string, error) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	domain = strings.TrimSuffix(domain, ".")
+
+	if domain == "" || len(domain) > 253 || net.ParseIP(domain) != nil {
+		return "", ErrDomainInvalid
+	}
+
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return "", ErrDomainInvalid
+	}
+	for _, label := range labels {
+		if !domainLabelRegex.MatchString(label) {
+			return "", ErrDomainInvalid
+		}
+	}
+
+	for _, reserved := range s.reserved {
+		if domain == reserved || strings.HasSuffix(domain, "."+reserved) {
+			return "", ErrDomainReserved
+		}
+	}
+
+	return domain, nil
+}
+
+// NewDomainVerificationToken generates a random verification token
+func NewDomainVerificationToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// DomainVerificationInstructions tells the tenant which DNS record to create
+type DomainVerificationInstructions struct {
+	TXTName     string `json:"txt_name"`
+	TXTValue    string `json:"txt_value"`
+	CNAMEName   string `json:"cname_name"`
+	CNAMETarget string `json:"cname_target"`
+}
+
+// Instructions returns the DNS records that prove ownership of a domain
+func (s *DomainVerificationService) Instructions(d *models.TenantDomain) DomainVerificationInstructions {
+	return DomainVerificationInstructions{
+		TXTName:     DomainVerifyRecordPrefix + d.Domain,
+		TXTValue:    DomainVerifyTokenPrefix + d.VerificationToken,
+		CNAMEName:   d.Domain,
+		CNAMETarget: s.TokenCNAMETarget(d.VerificationToken),
+	}
+}
+
+// ============================================
+// OWNERSHIP CHECK
+// ============================================
+
+// CheckOwnership looks for the TXT token, then for a CNAME to the token-bound
+// platform host. It returns the method that matched.
+func (s *DomainVerificationService) CheckOwnership(ctx context.Context, domain, token string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, domainLookupTimeout)
+	defer cancel()
+
+	if token == "" {
+		return "", fmt.Errorf("%w: no verification token", ErrDomainVerificationFailed)
+	}
+
+	var reasons []string
+
+	records, err := s.resolver.LookupTXT(ctx, DomainVerifyRecordPrefix+domain)
+	if err == nil {
+		want := DomainVerifyTokenPrefix + token
+		for _, record := range records {
+			if strings.TrimSpace(record) == want {
+				return models.DomainVerifyTXT, nil
+			}
+		}
+		reasons = append(reasons, "TXT record does not contain the verification token")
+	} else {
+		reasons = append(reasons, "TXT lookup failed: "+err.Error())
+	}
+
+	cname, err := s.resolver.LookupCNAME(ctx, domain)
+	if err == nil {
+		want := s.TokenCNAMETarget(token)
+		if strings.ToLower(strings.TrimSuffix(cname, ".")) == want {
+			return models.DomainVerifyCNAME, nil
+		}
+		reasons = append(reasons, fmt.Sprintf("CNAME points to %s, expected %s", strings.TrimSuffix(cname, "."), want))
+	} else {
+		reasons = append(reasons, "CNAME lookup failed: "+err.Error())
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrDomainVerificationFailed, strings.Join(reasons, "; "))
+}
+
+// Verify checks ownership of a tenant's domain now and records the result
+func (s *DomainVerificationService) Verify(tenantID uuid.UUID, domain string) (*models.TenantDomain, error) {
+	var d models.TenantDomain
+	if err := s.db.Where("tenant_id = ? AND domain = ?", tenantID, strings.ToLower(domain)).First(&d).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDomainNotFound
+		}
+		return nil, err
+	}
+
+	if err := s.ensureToken(&d); err != nil {
+		return nil, err
+	}
+
+	method, checkErr := s.CheckOwnership(context.Background(), d.Domain, d.VerificationToken)
+	if err := s.recordCheck(&d, method, checkErr, true); err != nil {
+		return nil, err
+	}
+	return &d, checkErr
+}
+
+// ensureToken issues a token for domains added before verification existed
+func (s *DomainVerificationService) ensureToken(d *models.TenantDomain) error {
+	if d.VerificationToken != "" {
+		return nil
+	}
+	d.VerificationToken = NewDomainVerificationToken()
+	return s.db.Model(&models.TenantDomain{}).Where("id = ?", d.ID).
+		Update("verification_token", d.VerificationToken).Error
+}
+
+// recordCheck stores the outcome of an ownership check. A failed manual check
+// never unverifies a domain; failed periodic re-checks do after
+// DomainReverifyMaxFailures in a row.
+func (s *DomainVerificationService) recordCheck(d *models.TenantDomain, method string, checkErr error, manual bool) error {
+	now := time.Now()
+	updates := map[string]interface{}{"last_checked_at": &now}
+
+	wasVerified := d.IsVerified
+	if checkErr == nil {
+		updates["is_verified"] = true
+		updates["verification_method"] = method
+		updates["last_check_error"] = ""
+		updates["check_failures"] = 0
+		if d.VerifiedAt == nil {
+			updates["verified_at"] = &now
+		}
+	} else {
+		updates["last_check_error"] = truncate(checkErr.Error(), 500)
+		if d.IsVerified && !manual {
+			updates["check_failures"] = d.CheckFailures + 1
+			if d.CheckFailures+1 >= DomainReverifyMaxFailures {
+				updates["is_verified"] = false
+			}
+		}
+	}
+
+	if err := s.db.Model(&models.TenantDomain{}).Where("id = ?", d.ID).Updates(updates).Error; err != nil {
+		return err
+	}
+	s.db.First(d, "id = ?", d.ID)
+
+	if wasVerified != d.IsVerified {
+		action := models.TenantAuditDomainVerified
+		if !d.IsVerified {
+			action = models.TenantAuditDomainUnverified
+		}
+		s.tenants.logAudit(d.TenantID, action, nil, nil, map[string]string{
+			"domain": d.Domain,
+			"method": d.VerificationMethod,
+			"error":  d.LastCheckError,
+		})
+		s.Invalidate(d.TenantID, d.Domain)
+	}
+	return nil
+}
+
+// ============================================
+// PERIODIC RE-VERIFICATION
+// ============================================
+
+// CheckDue re-checks verified domains whose last check is older than
+// DomainReverifyInterval and auto-checks recently added pending domains
+func (s *DomainVerificationService) CheckDue() (checked, unverified int, err error) {
+	now := time.Now()
+	var domains []models.TenantDomain
+	err = s.db.Where(
+		"(is_verified = ? AND (last_checked_at IS NULL OR last_checked_at < ?)) OR (is_verified = ? AND created_at > ?)",
+		true, now.Add(-DomainReverifyInterval), false, now.Add(-DomainPendingCheckWindow),
+	).Find(&domains).Error
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for i := range domains {
+		d := &domains[i]
+		if err := s.ensureToken(d); err != nil {
+			continue
+		}
+		wasVerified := d.IsVerified
+		method, checkErr := s.CheckOwnership(context.Background(), d.Domain, d.VerificationToken)
+		if err := s.recordCheck(d, method, checkErr, false); err != nil {
+			log.Printf("[Domains] failed to record check for %s: %v", d.Domain, err)
+			continue
+		}
+		checked++
+		if wasVerified && !d.IsVerified {
+			unverified++
+		}
+	}
+	return checked, unverified, nil
+}
+
+// StartChecker runs CheckDue every DomainCheckInterval
+func (s *DomainVerificationService) StartChecker() {
+	go func() {
+		ticker := time.NewTicker(DomainCheckInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if checked, unverified, err := s.CheckDue(); err != nil {
+				log.Printf("[Domains] re-verification failed: %v", err)
+			} else if unverified > 0 {
+				log.Printf("[Domains] checked %d domains, %d lost verification", checked, unverified)
+			}
+		}
+	}()
+}
+
+// ============================================
+// LOOKUPS
+// ============================================
+
+// IsVerifiedDomain reports whether host is a verified custom domain
+func (s *DomainVerificationService) IsVerifiedDomain(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if entry, ok := s.verifiedHosts.Load(host); ok {
+		if e := entry.(domainCacheEntry); time.Now().Before(e.expiresAt) {
+			return e.value.(bool)
+		}
+	}
+
+	var count int64
+	s.db.Model(&models.TenantDomain{}).Where("domain = ? AND is_verified = ?", host, true).Count(&count)
+	s.verifiedHosts.Store(host, domainCacheEntry{value: count > 0, expiresAt: time.Now().Add(domainCacheTTL)})
+	return count > 0
+}
+
+// TenantBaseURL returns https://<domain> for the tenant's primary verified
+// domain (or any verified domain when none is primary)
+func (s *DomainVerificationService) TenantBaseURL(tenantID uuid.UUID) (string, bool) {
+	if entry, ok := s.baseURLs.Load(tenantID); ok {
+		if e := entry.(domainCacheEntry); time.Now().Before(e.expiresAt) {
+			url := e.value.(string)
+			return url, url != ""
+		}
+	}
+
+	var d models.TenantDomain
+	url := ""
+	if err := s.db.Where("tenant_id = ? AND is_verified = ?", tenantID, true).
+		Order("is_primary DESC, verified_at ASC").First(&d).Error; err == nil {
+		url = "https://" + d.Domain
+	}
+	s.baseURLs.Store(tenantID, domainCacheEntry{value: url, expiresAt: time.Now().Add(domainCacheTTL)})
+	return url, url != ""
+}
+
+// Invalidate drops cached lookups for a tenant's domain
+func (s *DomainVerificationService) Invalidate(tenantID uuid.UUID, domain string) {
+	s.baseURLs.Delete(tenantID)
+	s.verifiedHosts.Delete(strings.ToLower(domain))
+}
@@ -0,0 +1,387 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// PROMOTER EARNINGS STATEMENTS
+// ============================================
+// Statements are read from the promoter's payable account in the ledger:
+// the opening balance is everything posted before the period, the closing
+// balance adds the period's approvals, reversals, overrides, adjustments
+// and payouts.
+// Periods follow the tenant's timezone.
+
+// Statement errors
+var (
+	ErrStatementPeriod   = errors.New("invalid statement period") // invalid or not started yet
+	ErrStatementPromoter = errors.New("promoter not found")
+)
+
+// EarningsStatementRow is one group of a promoter's postings in a period.
+// Amount is minor units from the promoter's point of view (positive = owed to them).
+type EarningsStatementRow struct {
+	Currency     string
+	Month        int
+	Kind         models.LedgerTransactionKind
+	OfferID      *uuid.UUID
+	OfferTitle   string
+	OfferTitleAr string
+	Count        int
+	Amount       int64
+}
+
+// EarningsStatementOffer is a promoter's earnings from one offer
+type EarningsStatementOffer struct {
+	OfferID      *uuid.UUID `json:"offer_id,omitempty"`
+	OfferTitle   string     `json:"offer_title"`
+	OfferTitleAr string     `json:"offer_title_ar,omitempty"`
+	Conversions  int        `json:"conversions"`
+	Reversals    int        `json:"reversals"`
+	Approved     int64      `json:"approved"`
+	Reversed     int64      `json:"reversed"`
+	Net          int64      `json:"net"`
+}
+
+// EarningsStatementMonth is one month of a year-end summary
+type EarningsStatementMonth struct {
+	Month       int   `json:"month"`
+	Approved    int64 `json:"approved"`
+	Reversed    int64 `json:"reversed"`
+	Overrides   int64 `json:"overrides"`
+	Adjustments int64 `json:"adjustments"`
+	Paid        int64 `json:"paid"`
+}
+
+// EarningsStatementCurrency is the statement of one currency, in minor units
+type EarningsStatementCurrency struct {
+	Currency    string                   `json:"currency"`
+	MinorUnits  int                      `json:"minor_units"`
+	Opening     int64                    `json:"opening"`
+	Approved    int64                    `json:"approved"`
+	Reversed    int64                    `json:"reversed"`
+	Overrides   int64                    `json:"overrides"` // referral and team overrides, net of reversals
+	Adjustments int64                    `json:"adjustments"`
+	Paid        int64                    `json:"paid"`
+	Closing     int64                    `json:"closing"`
+	Conversions int                      `json:"conversions"`
+	Reversals   int                      `json:"reversals"`
+	Offers      []EarningsStatementOffer `json:"offers"`
+	Months      []EarningsStatementMonth `json:"months,omitempty"`
+}
+
+// EarningsStatement is a promoter's monthly statement (Month 1-12) or
+// year-end summary (Month 0)
+type EarningsStatement struct {
+	TenantID      uuid.UUID                   `json:"tenant_id"`
+	PromoterID    uuid.UUID                   `json:"promoter_id"`
+	PromoterName  string                      `json:"promoter_name"`
+	PromoterEmail string                      `json:"promoter_email"`
+	IssuerName    string                      `json:"issuer_name"`
+	Year          int                         `json:"year"`
+	Month         int                         `json:"month,omitempty"`
+	PeriodStart   time.Time                   `json:"period_start"`
+	PeriodEnd     time.Time                   `json:"period_end"` // exclusive
+	GeneratedAt   time.Time                   `json:"generated_at"`
+	Currencies    []EarningsStatementCurrency `json:"currencies"`
+
+	// Year-end summaries carry the promoter's tax details
+	TaxProfile  *models.PromoterTaxProfile `json:"tax_profile,omitempty"`
+	TaxIDMasked string                     `json:"tax_id_masked,omitempty"`
+}
+
+// YearEnd reports whether the statement covers a whole year
+func (st *EarningsStatement) YearEnd() bool {
+	return st.Month == 0
+}
+
+// Reference names the statement in file names and page footers
+func (st *EarningsStatement) Reference() string {
+	if st.YearEnd() {
+		return fmt.Sprintf("earnings-%d", st.Year)
+	}
+	return fmt.Sprintf("earnings-%d-%02d", st.Year, st.Month)
+}
+
+// BuildEarningsStatement turns opening balances and a period's postings
+// into one statement per currency
+func BuildEarningsStatement(opening map[string]int64, rows []EarningsStatementRow) []EarningsStatementCurrency {
+	byCurrency := make(map[string]*EarningsStatementCurrency)
+	get := func(currency string) *EarningsStatementCurrency {
+		sc, ok := byCurrency[currency]
+		if !ok {
+			sc = &EarningsStatementCurrency{Currency: currency, MinorUnits: CurrencyMinorUnits(currency), Offers: []EarningsStatementOffer{}}
+			byCurrency[currency] = sc
+		}
+		return sc
+	}
+	for currency, balance := range opening {
+		get(currency).Opening = balance
+	}
+
+	offers := make(map[string]map[uuid.UUID]int)
+	months := make(map[string]map[int]int)
+	for _, row := range rows {
+		sc := get(row.Currency)
+		if months[row.Currency] == nil {
+			months[row.Currency] = make(map[int]int)
+			offers[row.Currency] = make(map[uuid.UUID]int)
+		}
+		mi, ok := months[row.Currency][row.Month]
+		if !ok {
+			mi = len(sc.Months)
+			months[row.Currency][row.Month] = mi
+			sc.Months = append(sc.Months, EarningsStatementMonth{Month: row.Month})
+		}
+		month := &sc.Months[mi]
+
+		var offer *EarningsStatementOffer
+		if row.Kind == models.LedgerKindConversionApproved || row.Kind == models.LedgerKindConversionReversed {
+			oi, ok := offers[row.Currency][derefUUID(row.OfferID)]
+			if !ok {
+				oi = len(sc.Offers)
+				offers[row.Currency][derefUUID(row.OfferID)] = oi
+				sc.Offers = append(sc.Offers, EarningsStatementOffer{
+					OfferID: row.OfferID, OfferTitle: row.OfferTitle, OfferTitleAr: row.OfferTitleAr,
+				})
+			}
+			offer = &sc.Offers[oi]
+		}
+
+		switch row.Kind {
+		case models.LedgerKindConversionApproved:
+			sc.Approved += row.Amount
+			sc.Conversions += row.Count
+			month.Approved += row.Amount
+			offer.Approved += row.Amount
+			offer.Conversions += row.Count
+		case models.LedgerKindConversionReversed:
+			sc.Reversed -= row.Amount
+			sc.Reversals += row.Count
+			month.Reversed -= row.Amount
+			offer.Reversed -= row.Amount
+			offer.Reversals += row.Count
+		case models.LedgerKindOverrideEarned, models.LedgerKindOverrideReversed:
+			sc.Overrides += row.Amount
+			month.Overrides += row.Amount
+		case models.LedgerKindPayoutPaid:
+			sc.Paid -= row.Amount
+			month.Paid -= row.Amount
+		default:
+			sc.Adjustments += row.Amount
+			month.Adjustments += row.Amount
+		}
+	}
+
+	result := make([]EarningsStatementCurrency, 0, len(byCurrency))
+	for _, sc := range byCurrency {
+		sc.Closing = sc.Opening + sc.Approved - sc.Reversed + sc.Overrides + sc.Adjustments - sc.Paid
+		for i := range sc.Offers {
+			sc.Offers[i].Net = sc.Offers[i].Approved - sc.Offers[i].Reversed
+		}
+		sort.SliceStable(sc.Offers, func(i, j int) bool { return sc.Offers[i].OfferTitle < sc.Offers[j].OfferTitle })
+		sort.Slice(sc.Months, func(i, j int) bool { return sc.Months[i].Month < sc.Months[j].Month })
+		result = append(result, *sc)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Currency < result[j].Currency })
+	return result
+}
+
+// EarningsStatementService builds promoter statements from the ledger
+type EarningsStatementService struct {
+	db *gorm.DB
+}
+
+var (
+	earningsStatementService     *EarningsStatementService
+	earningsStatementServiceOnce sync.Once
+)
+
+// GetEarningsStatementService returns the singleton statement service
+func GetEarningsStatementService(db *gorm.DB) *EarningsStatementService {
+	earningsStatementServiceOnce.Do(func() {
+		earningsStatementService = NewEarningsStatementService(db)
+	})
+	return earningsStatementService
+}
+
+// NewEarningsStatementService creates a new statement service
+func NewEarningsStatementService(db *gorm.DB) *EarningsStatementService {
+	return &EarningsStatementService{db: db}
+}
+
+// Monthly returns a promoter's statement of one month in loc
+func (s *EarningsStatementService) Monthly(tenantID, promoterID uuid.UUID, year, month int, loc *time.Location) (*EarningsStatement, error) {
+	if month < 1 || month > 12 {
+		return nil, fmt.Errorf("%w: month must be 1-12", ErrStatementPeriod)
+	}
+	st, err := s.build(tenantID, promoterID, year, month, loc)
+	if err != nil {
+		return nil, err
+	}
+	for i := range st.Currencies {
+		st.Currencies[i].Months = nil
+	}
+	return st, nil
+}
+
+// YearEnd returns a promoter's year-end summary with their tax details
+func (s *EarningsStatementService) YearEnd(tenantID, promoterID uuid.UUID, year int, loc *time.Location) (*EarningsStatement, error) {
+	st, err := s.build(tenantID, promoterID, year, 0, loc)
+	if err != nil {
+		return nil, err
+	}
+	if profile, err := NewTaxProfileService(s.db).Get(tenantID, promoterID); err == nil {
+		st.TaxProfile = profile
+		st.TaxIDMasked = profile.MaskedTaxID()
+	}
+	return st, nil
+}
+
+func (s *EarningsStatementService) build(tenantID, promoterID uuid.UUID, year, month int, loc *time.Location) (*EarningsStatement, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	now := time.Now().In(loc)
+	if year < 2000 || year > now.Year() {
+		return nil, fmt.Errorf("%w: year must be 2000-%d", ErrStatementPeriod, now.Year())
+	}
+	start := time.Date(year, time.Month(max(month, 1)), 1, 0, 0, 0, 0, loc)
+	end := start.AddDate(1, 0, 0)
+	if month > 0 {
+		end = start.AddDate(0, 1, 0)
+	}
+	if start.After(now) {
+		return nil, fmt.Errorf("%w: the period has not started", ErrStatementPeriod)
+	}
+
+	var promoter models.AfftokUser
+	if err := s.db.Select("id, full_name, email").First(&promoter, "id = ?", promoterID).Error; err != nil {
+		return nil, ErrStatementPromoter
+	}
+
+	opening, err := s.openingBalances(tenantID, promoterID, start)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.periodRows(tenantID, promoterID, start, end, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	var tenant models.Tenant
+	s.db.Select("id, name").First(&tenant, "id = ?", tenantID)
+
+	return &EarningsStatement{
+		TenantID:      tenantID,
+		PromoterID:    promoterID,
+		PromoterName:  promoter.FullName,
+		PromoterEmail: promoter.Email,
+		IssuerName:    tenant.Name,
+		Year:          year,
+		Month:         month,
+		PeriodStart:   start,
+		PeriodEnd:     end,
+		GeneratedAt:   now,
+		Currencies:    BuildEarningsStatement(opening, rows),
+	}, nil
+}
+
+// payableEntries selects a promoter's postings on their payable account
+func (s *EarningsStatementService) payableEntries(tenantID, promoterID uuid.UUID) *gorm.DB {
+	return s.db.Table("ledger_entries e").
+		Joins("JOIN ledger_accounts a ON a.id = e.account_id").
+		Joins("JOIN ledger_transactions t ON t.id = e.transaction_id").
+		Where("a.tenant_id = ? AND a.type = ? AND a.owner_id = ?", tenantID, models.LedgerAccountPromoterPayable, promoterID)
+}
+
+func (s *EarningsStatementService) openingBalances(tenantID, promoterID uuid.UUID, before time.Time) (map[string]int64, error) {
+	var rows []struct {
+		Currency string
+		Balance  int64
+	}
+	if err := s.payableEntries(tenantID, promoterID).
+		Select("e.currency, COALESCE(SUM(-e.amount), 0) AS balance").
+		Where("t.occurred_at < ?", before).
+		Group("e.currency").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read ledger: %w", err)
+	}
+	opening := make(map[string]int64, len(rows))
+	for _, r := range rows {
+		if r.Balance != 0 {
+			opening[r.Currency] = r.Balance
+		}
+	}
+	return opening, nil
+}
+
+func (s *EarningsStatementService) periodRows(tenantID, promoterID uuid.UUID, from, to time.Time, loc *time.Location) ([]EarningsStatementRow, error) {
+	var rows []EarningsStatementRow
+	if err := s.payableEntries(tenantID, promoterID).
+		Select(`e.currency, CAST(EXTRACT(MONTH FROM t.occurred_at AT TIME ZONE ?) AS INTEGER) AS month, t.kind, t.offer_id,
+			COALESCE(o.title, '') AS offer_title, COALESCE(o.title_ar, '') AS offer_title_ar,
+			COUNT(DISTINCT t.id) AS count, COALESCE(SUM(-e.amount), 0) AS amount`, loc.String()).
+		Joins("LEFT JOIN offers o ON o.id = t.offer_id").
+		Where("t.occurred_at >= ? AND t.occurred_at < ?", from, to).
+		Group("1, 2, 3, 4, 5, 6").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read ledger: %w", err)
+	}
+	return rows, nil
+}
+
+// WriteEarningsStatementCSV writes a statement as one CSV table. Rows are
+// "summary" per currency, then "offer" and, for year-end summaries, "month"
+// rows. Amounts are in major units of the row's currency; overrides come
+// last so earlier columns keep their positions.
+func WriteEarningsStatementCSV(w io.Writer, st *EarningsStatement) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{
+		"type", "currency", "month", "offer_id", "offer",
+		"conversions", "reversals", "opening", "approved", "reversed", "adjustments", "paid", "closing", "overrides",
+	})
+	for _, sc := range st.Currencies {
+		amount := func(v int64) string {
+			return strconv.FormatFloat(MinorToMajor(v, sc.Currency), 'f', sc.MinorUnits, 64)
+		}
+		writer.Write([]string{
+			"summary", sc.Currency, "", "", "",
+			strconv.Itoa(sc.Conversions), strconv.Itoa(sc.Reversals),
+			amount(sc.Opening), amount(sc.Approved), amount(sc.Reversed), amount(sc.Adjustments), amount(sc.Paid), amount(sc.Closing),
+			amount(sc.Overrides),
+		})
+		for _, o := range sc.Offers {
+			offerID := ""
+			if o.OfferID != nil {
+				offerID = o.OfferID.String()
+			}
+			writer.Write([]string{
+				"offer", sc.Currency, "", offerID, o.OfferTitle,
+				strconv.Itoa(o.Conversions), strconv.Itoa(o.Reversals),
+				"", amount(o.Approved), amount(o.Reversed), "", "", "", "",
+			})
+		}
+		for _, m := range sc.Months {
+			writer.Write([]string{
+				"month", sc.Currency, fmt.Sprintf("%d-%02d", st.Year, m.Month), "", "",
+				"", "", "", amount(m.Approved), amount(m.Reversed), amount(m.Adjustments), amount(m.Paid), "",
+				amount(m.Overrides),
+			})
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+)
+
+// ============================================
+// EARNINGS STATEMENT PDF
+// ============================================
+
+// statementLabels are the English and Arabic captions of statement PDFs
+var statementLabels = map[string][2]string{
+	"title":       {"EARNINGS STATEMENT", "كشف الأرباح"},
+	"title_year":  {"ANNUAL EARNINGS SUMMARY", "ملخص الأرباح السنوي"},
+	"promoter":    {"Promoter", "المسوق"},
+	"period":      {"Period", "الفترة"},
+	"generated":   {"Generated", "تاريخ الإنشاء"},
+	"tax_details": {"Tax details", "البيانات الضريبية"},
+	"legal_name":  {"Legal name", "الاسم القانوني"},
+	"tax_country": {"Tax country", "بلد الإقامة الضريبية"},
+	"entity_type": {"Entity type", "نوع الكيان"},
+	"tax_id":      {"Tax ID", "الرقم الضريبي"},
+	"vat_number":  {"VAT number", "رقم ضريبة القيمة المضافة"},
+	"no_profile":  {"No tax profile on file", "لا يوجد ملف ضريبي"},
+	"summary":     {"Summary", "الملخص"},
+	"opening":     {"Opening balance", "الرصيد الافتتاحي"},
+	"approved":    {"Approved earnings", "الأرباح المعتمدة"},
+	"reversed":    {"Reversed", "المعكوسة"},
+	"overrides":   {"Referral and team overrides", "عمولات الإحالة والفريق"},
+	"adjustments": {"Adjustments", "التسويات"},
+	"paid":        {"Paid out", "المدفوع"},
+	"closing":     {"Closing balance", "الرصيد الختامي"},
+	"by_offer":    {"Earnings by offer", "الأرباح حسب العرض"},
+	"by_month":    {"Earnings by month", "الأرباح حسب الشهر"},
+	"offer":       {"Offer", "العرض"},
+	"month":       {"Month", "الشهر"},
+	"conversions": {"Approved", "المعتمدة"},
+	"reversals":   {"Reversed", "المعكوسة"},
+	"net":         {"Net earnings", "صافي الأرباح"},
+	"no_activity": {"No earnings in this period", "لا توجد أرباح في هذه الفترة"},
+	"remark":      {"Amounts in the currency earned, per the earnings ledger", "المبالغ بعملة الربح وفق دفتر الأرباح"},
+	"not_a_form":  {"This summary is provided for your records and is not a tax form.", "هذا الملخص لأغراض السجلات وليس نموذجاً ضريبياً"},
+}
+
+// taxEntityLabels translate tax entity types
+var taxEntityLabels = map[string][2]string{
+	models.TaxEntityIndividual:     {"Individual", "فرد"},
+	models.TaxEntitySoleProprietor: {"Sole proprietor", "مؤسسة فردية"},
+	models.TaxEntityCompany:        {"Company", "شركة"},
+	models.TaxEntityPartnership:    {"Partnership", "شراكة"},
+	models.TaxEntityNonProfit:      {"Non-profit", "منظمة غير ربحية"},
+}
+
+const (
+	colStatementCount = 330.0
+	colStatementRev   = 400.0
+)
+
+// statementOfferColumns are the columns of the per-offer table
+var statementOfferColumns = []pdfColumn{
+	{Label: statementLabels["offer"], X: pdfMarginLeft + 6, Align: pdfAlignLeft, ArabicX: colStatementCount - 70},
+	{Label: statementLabels["conversions"], X: colStatementCount, Align: pdfAlignRight},
+	{Label: statementLabels["reversals"], X: colStatementRev, Align: pdfAlignRight},
+	{Label: statementLabels["net"], X: pdfMarginRight - 6, Align: pdfAlignRight},
+}
+
+// statementMonthColumns are the columns of the per-month table
+var statementMonthColumns = []pdfColumn{
+	{Label: statementLabels["month"], X: pdfMarginLeft + 6, Align: pdfAlignLeft, ArabicX: 150},
+	{Label: statementLabels["approved"], X: 260, Align: pdfAlignRight},
+	{Label: statementLabels["reversed"], X: 340, Align: pdfAlignRight},
+	{Label: statementLabels["adjustments"], X: 420, Align: pdfAlignRight},
+	{Label: statementLabels["paid"], X: pdfMarginRight - 6, Align: pdfAlignRight},
+}
+
+// RenderEarningsStatementPDF renders a statement or year-end summary as a
+// bilingual A4 PDF
+func RenderEarningsStatementPDF(st *EarningsStatement) ([]byte, error) {
+	p := newBilingualPDF()
+	title := statementLabels["title"]
+	period := fmt.Sprintf("%d-%02d", st.Year, st.Month)
+	if st.YearEnd() {
+		title = statementLabels["title_year"]
+		period = strconv.Itoa(st.Year)
+	}
+	p.header(st.IssuerName, title)
+
+	promoter := strings.TrimSpace(st.PromoterName + "  " + st.PromoterEmail)
+	p.detail(statementLabels["promoter"], promoter)
+	p.detail(statementLabels["period"], fmt.Sprintf("%s  (%s - %s)", period,
+		st.PeriodStart.Format("2006-01-02"), st.PeriodEnd.AddDate(0, 0, -1).Format("2006-01-02")))
+	p.detail(statementLabels["generated"], st.GeneratedAt.Format("2006-01-02 15:04"))
+
+	if st.YearEnd() {
+		p.y -= 12
+		p.caption(statementLabels["tax_details"])
+		if profile := st.TaxProfile; profile != nil {
+			entity := taxEntityLabels[profile.EntityType]
+			p.detail(statementLabels["legal_name"], profile.LegalName)
+			p.detail(statementLabels["tax_country"], profile.Country)
+			p.detail(statementLabels["entity_type"], entity[0]+"  "+entity[1])
+			p.detail(statementLabels["tax_id"], st.TaxIDMasked)
+			if profile.VATNumber != "" {
+				p.detail(statementLabels["vat_number"], profile.VATNumber)
+			}
+		} else {
+			p.detail(statementLabels["no_profile"], "-")
+		}
+	}
+
+	if len(st.Currencies) == 0 {
+		p.y -= 12
+		p.caption(statementLabels["no_activity"])
+	}
+	for _, sc := range st.Currencies {
+		renderStatementCurrency(p, st, sc)
+	}
+
+	if st.YearEnd() {
+		p.y -= 6
+		p.note(statementLabels["not_a_form"])
+	}
+	return p.finish(statementLabels["remark"], st.Reference())
+}
+
+func renderStatementCurrency(p *bilingualPDF, st *EarningsStatement, sc EarningsStatementCurrency) {
+	amount := func(v int64) string {
+		return FormatInvoiceAmount(MinorToMajor(v, sc.Currency), sc.Currency)
+	}
+
+	// Summary
+	p.y -= 14
+	p.ensure(10 * 18)
+	summary := statementLabels["summary"]
+	p.caption([2]string{summary[0] + " - " + sc.Currency, summary[1] + " - " + sc.Currency})
+	for _, row := range []struct {
+		key   string
+		value int64
+	}{
+		{"opening", sc.Opening},
+		{"approved", sc.Approved},
+		{"reversed", -sc.Reversed},
+		{"overrides", sc.Overrides},
+		{"adjustments", sc.Adjustments},
+		{"paid", -sc.Paid},
+	} {
+		label := statementLabels[row.key]
+		p.amountRow(label[0], label[1], amount(row.value)+" "+sc.Currency, false)
+	}
+	closing := statementLabels["closing"]
+	p.amountRow(closing[0], closing[1], amount(sc.Closing)+" "+sc.Currency, true)
+
+	// Per offer
+	if len(sc.Offers) > 0 {
+		p.y -= 8
+		p.ensure(80)
+		p.caption(statementLabels["by_offer"])
+		p.tableHeader(statementOfferColumns)
+		for _, o := range sc.Offers {
+			height := 16.0
+			if o.OfferTitleAr != "" {
+				height = 27
+			}
+			if p.ensure(height + 4) {
+				p.tableHeader(statementOfferColumns)
+			}
+			p.page.Text(p.regular, 9, pdfMarginLeft+6, p.y, p.fit(o.OfferTitle, colStatementCount-80-pdfMarginLeft), pdfAlignLeft)
+			p.page.Text(p.regular, 9, colStatementCount, p.y, strconv.Itoa(o.Conversions), pdfAlignRight)
+			p.page.Text(p.regular, 9, colStatementRev, p.y, strconv.Itoa(o.Reversals), pdfAlignRight)
+			p.page.Text(p.regular, 9, pdfMarginRight-6, p.y, amount(o.Net), pdfAlignRight)
+			if o.OfferTitleAr != "" {
+				p.page.Gray(0.4)
+				p.page.Text(p.regular, 8, colStatementCount-70, p.y-11, o.OfferTitleAr, pdfAlignRight)
+				p.page.Gray(0)
+			}
+			p.page.Line(pdfMarginLeft, p.y-height+10, pdfMarginRight, p.y-height+10, 0.5, 0.85)
+			p.y -= height
+		}
+	}
+
+	// Per month (year-end summaries)
+	if st.YearEnd() && len(sc.Months) > 0 {
+		p.y -= 8
+		p.ensure(80)
+		p.caption(statementLabels["by_month"])
+		p.tableHeader(statementMonthColumns)
+		for _, m := range sc.Months {
+			if p.ensure(20) {
+				p.tableHeader(statementMonthColumns)
+			}
+			p.page.Text(p.regular, 9, pdfMarginLeft+6, p.y, fmt.Sprintf("%d-%02d", st.Year, m.Month), pdfAlignLeft)
+			p.page.Text(p.regular, 9, 260, p.y, amount(m.Approved), pdfAlignRight)
+			p.page.Text(p.regular, 9, 340, p.y, amount(m.Reversed), pdfAlignRight)
+			p.page.Text(p.regular, 9, 420, p.y, amount(m.Adjustments), pdfAlignRight)
+			p.page.Text(p.regular, 9, pdfMarginRight-6, p.y, amount(m.Paid), pdfAlignRight)
+			p.page.Line(pdfMarginLeft, p.y-6, pdfMarginRight, p.y-6, 0.5, 0.85)
+			p.y -= 16
+		}
+	}
+}
@@ -293,11 +293,6 @@ func (s *EdgeIngestService) processEvent(event EdgeClickEvent) error {
 			return fmt.Errorf("failed to resolve tracking code: %w", err)
 		}
 		userOfferID = uoID
-		// Get promoter ID from user offer
-		var userOffer models.UserOffer
-		if err := s.db.First(&userOffer, "id = ?", userOfferID).Error; err == nil {
-			promoterID = userOffer.UserID
-		}
 	} else {
 		// Try to parse as UUID directly
 		if event.UserOfferID != "" {
@@ -309,16 +304,33 @@ func (s *EdgeIngestService) processEvent(event EdgeClickEvent) error {
 		return fmt.Errorf("could not resolve user offer ID")
 	}
 	
-	// Parse tenant ID
-	tenantID := models.DefaultTenantID
+	// The user offer is authoritative for promoter and tenant
+	var userOffer models.UserOffer
+	if err := s.db.First(&userOffer, "id = ?", userOfferID).Error; err != nil {
+		return fmt.Errorf("user offer not found: %w", err)
+	}
+	promoterID = userOffer.UserID
+	tenantID := userOffer.TenantID
+	if tenantID == uuid.Nil {
+		tenantID = models.DefaultTenantID
+	}
+	
+	// An edge event claiming another tenant is rejected rather than re-homed
 	if event.TenantID != "" {
-		if parsed, err := uuid.Parse(event.TenantID); err == nil {
-			tenantID = parsed
+		if parsed, err := uuid.Parse(event.TenantID); err != nil || parsed != tenantID {
+			return fmt.Errorf("tenant mismatch for user offer %s", userOfferID)
 		}
 	}
 	
+	// Over the tenant's daily click hard limit the event is dropped
+	usage := GetUsageService(s.db)
+	if !usage.AllowClick(tenantID) {
+		return fmt.Errorf("daily click limit exceeded for tenant %s", tenantID)
+	}
+	
 	// Create click record
 	click := &models.Click{
+		TenantModel: models.TenantModel{TenantID: tenantID},
 		ID:          uuid.New(),
 		UserOfferID: userOfferID,
 		IPAddress:   event.IP,
@@ -380,6 +392,7 @@ func (s *EdgeIngestService) processEvent(event EdgeClickEvent) error {
 	cache.Increment(ctx, fmt.Sprintf("clicks:total:%s", userOfferID.String()))
 	cache.Increment(ctx, fmt.Sprintf("clicks:daily:%s:%s", userOfferID.String(), time.Now().Format("2006-01-02")))
 	cache.Increment(ctx, fmt.Sprintf("tenant:%s:clicks:total", tenantID.String()))
+	usage.Record(tenantID, models.UsageMetricClicks, 1)
 	
 	// Log event
 	s.observability.Log(LogEvent{
@@ -501,7 +514,7 @@ func (s *EdgeIngestService) GetOfferConfigForEdge(trackingCode string) (*EdgeOff
 	// Build config
 	config := &EdgeOfferConfig{
 		ID:            userOffer.OfferID.String(),
-		TenantID:      models.DefaultTenantID.String(), // TODO: Get from user offer
+		TenantID:      userOffer.TenantID.String(),
 		AdvertiserID:  userOffer.Offer.NetworkID.String(),
 		LandingURL:    userOffer.Offer.DestinationURL,
 		FallbackURL:   userOffer.Offer.DestinationURL,
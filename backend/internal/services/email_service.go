@@ -0,0 +1,150 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"mime"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ============================================
+// EMAIL SENDER
+// ============================================
+
+// EmailMessage is a transactional email. HTML is optional.
+type EmailMessage struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// EmailSender delivers transactional email
+type EmailSender interface {
+	Send(msg EmailMessage) error
+}
+
+var (
+	emailSender     EmailSender
+	emailSenderOnce sync.Once
+	emailSenderMu   sync.RWMutex
+)
+
+// GetEmailSender returns the configured sender: SMTP when SMTP_HOST is set,
+// otherwise a sender that only logs (development)
+func GetEmailSender() EmailSender {
+	emailSenderOnce.Do(func() {
+		emailSenderMu.Lock()
+		defer emailSenderMu.Unlock()
+		if emailSender != nil {
+			return
+		}
+		if os.Getenv("SMTP_HOST") != "" {
+			emailSender = NewSMTPEmailSender()
+		} else {
+			emailSender = &logEmailSender{}
+		}
+	})
+
+	emailSenderMu.RLock()
+	defer emailSenderMu.RUnlock()
+	return emailSender
+}
+
+// SetEmailSender replaces the sender (tests, other providers)
+func SetEmailSender(sender EmailSender) {
+	emailSenderOnce.Do(func() {})
+	emailSenderMu.Lock()
+	emailSender = sender
+	emailSenderMu.Unlock()
+}
+
+// ============================================
+// SMTP
+// ============================================
+
+// SMTPEmailSender sends email through an SMTP relay
+type SMTPEmailSender struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+// NewSMTPEmailSender creates an SMTP sender from SMTP_HOST, SMTP_PORT,
+// SMTP_USERNAME, SMTP_PASSWORD and EMAIL_FROM
+func NewSMTPEmailSender() *SMTPEmailSender {
+	s := &SMTPEmailSender{
+		host:     os.Getenv("SMTP_HOST"),
+		port:     os.Getenv("SMTP_PORT"),
+		username: os.Getenv("SMTP_USERNAME"),
+		password: os.Getenv("SMTP_PASSWORD"),
+		from:     os.Getenv("EMAIL_FROM"),
+	}
+	if s.port == "" {
+		s.port = "587"
+	}
+	if s.from == "" {
+		s.from = "AffTok <no-reply@afftokapp.com>"
+	}
+	return s
+}
+
+// Send sends a plain text or multipart (text + HTML) message
+func (s *SMTPEmailSender) Send(msg EmailMessage) error {
+	var auth smtp.Auth
+	if s.username != "" {
+		auth = smtp.PlainAuth("", s.username, s.password, s.host)
+	}
+
+	fromAddr := s.from
+	if i := strings.LastIndex(fromAddr, "<"); i != -1 {
+		fromAddr = strings.TrimSuffix(fromAddr[i+1:], ">")
+	}
+
+	body := buildEmailMIME(s.from, msg)
+	if err := smtp.SendMail(s.host+":"+s.port, auth, fromAddr, []string{msg.To}, body); err != nil {
+		return fmt.Errorf("smtp send to %s: %w", msg.To, err)
+	}
+	return nil
+}
+
+func buildEmailMIME(from string, msg EmailMessage) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("From: " + from + "\r\n")
+	buf.WriteString("To: " + msg.To + "\r\n")
+	buf.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	buf.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if msg.HTML == "" {
+		buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+		buf.WriteString(msg.Text)
+		return buf.Bytes()
+	}
+
+	boundary := "afftok-" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	buf.WriteString("Content-Type: multipart/alternative; boundary=" + boundary + "\r\n\r\n")
+	buf.WriteString("--" + boundary + "\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	buf.WriteString(msg.Text + "\r\n")
+	buf.WriteString("--" + boundary + "\r\nContent-Type: text/html; charset=utf-8\r\n\r\n")
+	buf.WriteString(msg.HTML + "\r\n")
+	buf.WriteString("--" + boundary + "--\r\n")
+	return buf.Bytes()
+}
+
+// logEmailSender only logs messages (no SMTP configured)
+type logEmailSender struct{}
+
+func (l *logEmailSender) Send(msg EmailMessage) error {
+	log.Printf("[Email] SMTP not configured, not sending %q to %s", msg.Subject, msg.To)
+	return nil
+}
@@ -0,0 +1,454 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ============================================
+// PLATFORM FEE RULES
+// ============================================
+// The fee of an approved conversion comes from the most specific rule in
+// effect when it was approved: offer, advertiser, promoter tier, plan, then
+// tenant. A tenant's own rules come before the platform's (default tenant)
+// rules; without any rule DefaultPlatformFeeBps is charged as a markup.
+
+// Fee rule errors
+var (
+	ErrFeeRuleInvalid  = errors.New("invalid fee rule")
+	ErrFeeRuleNotFound = errors.New("fee rule not found")
+)
+
+// MaxFeeBps is the highest fee rate (100%)
+const MaxFeeBps = 10000
+
+// PromoterTiers are the promoter levels fee rules can target
+var PromoterTiers = []string{"rookie", "pro", "expert", "master", "legend"}
+
+// FeeTarget is what a conversion's fee rule is chosen by
+type FeeTarget struct {
+	TenantID     uuid.UUID
+	Plan         models.TenantPlan
+	PromoterTier string
+	AdvertiserID uuid.UUID
+	OfferID      uuid.UUID
+}
+
+func (t FeeTarget) scopeValue(scope models.FeeRuleScope) string {
+	switch scope {
+	case models.FeeScopePlan:
+		return string(t.Plan)
+	case models.FeeScopePromoterTier:
+		return t.PromoterTier
+	case models.FeeScopeAdvertiser:
+		if t.AdvertiserID != uuid.Nil {
+			return t.AdvertiserID.String()
+		}
+	case models.FeeScopeOffer:
+		if t.OfferID != uuid.Nil {
+			return t.OfferID.String()
+		}
+	}
+	return ""
+}
+
+// FeeVolume is an advertiser's approved volume in the month of a
+// conversion, the conversion included
+type FeeVolume struct {
+	Conversions int64 `json:"conversions"`
+	Spend       int64 `json:"spend"` // minor units of the conversion's currency
+}
+
+// FeeQuote is the fee of one conversion, in minor units
+type FeeQuote struct {
+	RuleID     *uuid.UUID     `json:"rule_id,omitempty"`
+	Mode       models.FeeMode `json:"mode"`
+	RateBps    int64          `json:"rate_bps"`
+	Fee        int64          `json:"fee"`        // platform revenue
+	Commission int64          `json:"commission"` // credited to the promoter
+	Charge     int64          `json:"charge"`     // owed by the advertiser
+}
+
+// ResolveFeeRule picks the rule in effect at t: the tenant's rules before
+// the platform's, the most specific scope first, the latest version of a scope
+func ResolveFeeRule(rules []models.FeeRule, target FeeTarget, at time.Time) *models.FeeRule {
+	owners := []uuid.UUID{target.TenantID}
+	if target.TenantID != models.DefaultTenantID {
+		owners = append(owners, models.DefaultTenantID)
+	}
+	for _, owner := range owners {
+		for _, scope := range models.FeeRuleScopes {
+			value := target.scopeValue(scope)
+			if value == "" && scope != models.FeeScopeTenant {
+				continue
+			}
+			var match *models.FeeRule
+			for i := range rules {
+				r := &rules[i]
+				if r.TenantID != owner || r.Scope != scope || r.ScopeValue != value || !r.EffectiveAt(at) {
+					continue
+				}
+				if match == nil || r.EffectiveFrom.After(match.EffectiveFrom) {
+					match = r
+				}
+			}
+			if match != nil {
+				return match
+			}
+		}
+	}
+	return nil
+}
+
+// FeeRuleTiers decodes a rule's volume tiers, lowest first
+func FeeRuleTiers(rule *models.FeeRule) []models.FeeTier {
+	var tiers []models.FeeTier
+	if len(rule.Tiers) == 0 || json.Unmarshal(rule.Tiers, &tiers) != nil {
+		return nil
+	}
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].From < tiers[j].From })
+	return tiers
+}
+
+// FeeRate returns a rule's rate at a monthly volume: the rate of the highest
+// tier reached, else the base rate
+func FeeRate(rule *models.FeeRule, volume FeeVolume, currency string) int64 {
+	var reached float64
+	switch rule.VolumeBasis {
+	case models.FeeVolumeConversions:
+		reached = float64(volume.Conversions)
+	case models.FeeVolumeSpend:
+		reached = MinorToMajor(volume.Spend, currency)
+	default:
+		return rule.RateBps
+	}
+	rate := rule.RateBps
+	for _, tier := range FeeRuleTiers(rule) {
+		if reached >= tier.From {
+			rate = tier.RateBps
+		}
+	}
+	return rate
+}
+
+// ComputeFee applies a rule to a commission. A nil rule charges
+// DefaultPlatformFeeBps on top. The minimum fee applies per conversion; a
+// revenue share never takes more than the commission.
+func ComputeFee(rule *models.FeeRule, commission int64, volume FeeVolume, currency string) FeeQuote {
+	quote := FeeQuote{Mode: models.FeeModeMarkup, RateBps: DefaultPlatformFeeBps}
+	if rule != nil {
+		id := rule.ID
+		quote.RuleID = &id
+		quote.Mode = rule.Mode
+		quote.RateBps = FeeRate(rule, volume, currency)
+	}
+	quote.Fee = PlatformFee(commission, quote.RateBps)
+	if rule != nil && commission > 0 {
+		if minFee := MajorToMinor(rule.MinFee, currency); quote.Fee < minFee {
+			quote.Fee = minFee
+		}
+	}
+
+	if quote.Mode == models.FeeModeRevenueShare {
+		if quote.Fee > commission {
+			quote.Fee = commission
+		}
+		quote.Commission = commission - quote.Fee
+		quote.Charge = commission
+		return quote
+	}
+	quote.Commission = commission
+	quote.Charge = commission + quote.Fee
+	return quote
+}
+
+// ValidateFeeRule normalizes a rule version and checks its scope, rates and
+// tiers. Plan rules are platform rules.
+func ValidateFeeRule(tenantID uuid.UUID, rule *models.FeeRule, tiers []models.FeeTier) error {
+	rule.Scope = models.FeeRuleScope(strings.ToLower(strings.TrimSpace(string(rule.Scope))))
+	rule.ScopeValue = strings.TrimSpace(rule.ScopeValue)
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.VolumeBasis = models.FeeVolumeBasis(strings.ToLower(strings.TrimSpace(string(rule.VolumeBasis))))
+	rule.Mode = models.FeeMode(strings.ToLower(strings.TrimSpace(string(rule.Mode))))
+	if rule.Mode == "" {
+		rule.Mode = models.FeeModeMarkup
+	}
+
+	switch rule.Scope {
+	case models.FeeScopeTenant:
+		rule.ScopeValue = ""
+	case models.FeeScopePlan:
+		rule.ScopeValue = strings.ToLower(rule.ScopeValue)
+		switch models.TenantPlan(rule.ScopeValue) {
+		case models.TenantPlanFree, models.TenantPlanPro, models.TenantPlanEnterprise:
+		default:
+			return fmt.Errorf("%w: scope_value must be a plan (free, pro, enterprise)", ErrFeeRuleInvalid)
+		}
+		if tenantID != models.DefaultTenantID {
+			return fmt.Errorf("%w: plan rules can only be set by the platform", ErrFeeRuleInvalid)
+		}
+	case models.FeeScopePromoterTier:
+		rule.ScopeValue = strings.ToLower(rule.ScopeValue)
+		if !containsString(PromoterTiers, rule.ScopeValue) {
+			return fmt.Errorf("%w: scope_value must be a promoter tier (%s)", ErrFeeRuleInvalid, strings.Join(PromoterTiers, ", "))
+		}
+	case models.FeeScopeAdvertiser, models.FeeScopeOffer:
+		id, err := uuid.Parse(rule.ScopeValue)
+		if err != nil || id == uuid.Nil {
+			return fmt.Errorf("%w: scope_value must be the %s ID", ErrFeeRuleInvalid, rule.Scope)
+		}
+		rule.ScopeValue = id.String()
+	default:
+		return fmt.Errorf("%w: unknown scope %q", ErrFeeRuleInvalid, rule.Scope)
+	}
+
+	if rule.Mode != models.FeeModeMarkup && rule.Mode != models.FeeModeRevenueShare {
+		return fmt.Errorf("%w: mode must be markup or revenue_share", ErrFeeRuleInvalid)
+	}
+	if rule.RateBps < 0 || rule.RateBps > MaxFeeBps {
+		return fmt.Errorf("%w: rate_bps must be between 0 and %d", ErrFeeRuleInvalid, MaxFeeBps)
+	}
+	if rule.MinFee < 0 {
+		return fmt.Errorf("%w: min_fee cannot be negative", ErrFeeRuleInvalid)
+	}
+
+	switch rule.VolumeBasis {
+	case models.FeeVolumeNone:
+		if len(tiers) > 0 {
+			return fmt.Errorf("%w: tiers need a volume_basis (conversions or spend)", ErrFeeRuleInvalid)
+		}
+		rule.Tiers = nil
+		return nil
+	case models.FeeVolumeConversions, models.FeeVolumeSpend:
+	default:
+		return fmt.Errorf("%w: volume_basis must be conversions or spend", ErrFeeRuleInvalid)
+	}
+	if len(tiers) == 0 {
+		return fmt.Errorf("%w: volume_basis needs at least one tier", ErrFeeRuleInvalid)
+	}
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].From < tiers[j].From })
+	for i, tier := range tiers {
+		if tier.From <= 0 || (i > 0 && tier.From == tiers[i-1].From) {
+			return fmt.Errorf("%w: tier volumes must be positive and distinct", ErrFeeRuleInvalid)
+		}
+		if tier.RateBps < 0 || tier.RateBps > MaxFeeBps {
+			return fmt.Errorf("%w: tier rate_bps must be between 0 and %d", ErrFeeRuleInvalid, MaxFeeBps)
+		}
+	}
+	raw, err := json.Marshal(tiers)
+	if err != nil {
+		return err
+	}
+	rule.Tiers = datatypes.JSON(raw)
+	return nil
+}
+
+// FeeRuleService stores fee rules and quotes conversion fees
+type FeeRuleService struct {
+	db *gorm.DB
+}
+
+var (
+	feeRuleService     *FeeRuleService
+	feeRuleServiceOnce sync.Once
+)
+
+// GetFeeRuleService returns the singleton fee rule service
+func GetFeeRuleService(db *gorm.DB) *FeeRuleService {
+	feeRuleServiceOnce.Do(func() {
+		feeRuleService = NewFeeRuleService(db)
+	})
+	return feeRuleService
+}
+
+// NewFeeRuleService creates a new fee rule service
+func NewFeeRuleService(db *gorm.DB) *FeeRuleService {
+	return &FeeRuleService{db: db}
+}
+
+// List returns the tenant's rules followed by the platform rules. Without
+// history only current and scheduled versions are returned.
+func (s *FeeRuleService) List(tenantID uuid.UUID, history bool) ([]models.FeeRule, error) {
+	query := s.db.Where("tenant_id IN ?", []uuid.UUID{tenantID, models.DefaultTenantID})
+	if !history {
+		query = query.Where("effective_to IS NULL OR effective_to > ?", time.Now())
+	}
+	var rules []models.FeeRule
+	if err := query.Order("scope, scope_value, version").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].TenantID == tenantID && rules[j].TenantID != tenantID
+	})
+	return rules, nil
+}
+
+// Save adds a version of the tenant's rule for a scope. The version takes
+// effect at EffectiveFrom (now when zero, never in the past) and closes the
+// current version; earlier versions stay for the postings that used them.
+func (s *FeeRuleService) Save(tenantID uuid.UUID, rule *models.FeeRule, tiers []models.FeeTier, by *uuid.UUID) error {
+	if err := ValidateFeeRule(tenantID, rule, tiers); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	if rule.EffectiveFrom.IsZero() {
+		rule.EffectiveFrom = now
+	}
+	if rule.EffectiveFrom.Before(now.Add(-time.Minute)) {
+		return fmt.Errorf("%w: effective_from cannot be in the past", ErrFeeRuleInvalid)
+	}
+	rule.ID = uuid.New()
+	rule.TenantID = tenantID
+	rule.EffectiveTo = nil
+	rule.CreatedBy = by
+	rule.Version = 1
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var latest models.FeeRule
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ? AND scope = ? AND scope_value = ?", tenantID, rule.Scope, rule.ScopeValue).
+			Order("version DESC").First(&latest).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+		case err != nil:
+			return err
+		default:
+			rule.Version = latest.Version + 1
+			if !rule.EffectiveFrom.After(latest.EffectiveFrom) {
+				return fmt.Errorf("%w: effective_from must be after version %d (%s)",
+					ErrFeeRuleInvalid, latest.Version, latest.EffectiveFrom.Format(time.RFC3339))
+			}
+			if latest.EffectiveTo == nil {
+				if err := tx.Model(&latest).Update("effective_to", rule.EffectiveFrom).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Create(rule).Error
+	})
+}
+
+// Retire ends the current version of a rule now; the scope falls back to
+// the next matching rule. ruleID may be any version of the rule.
+func (s *FeeRuleService) Retire(tenantID, ruleID uuid.UUID) (*models.FeeRule, error) {
+	var rule models.FeeRule
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var version models.FeeRule
+		if err := tx.Where("tenant_id = ? AND id = ?", tenantID, ruleID).First(&version).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrFeeRuleNotFound
+			}
+			return err
+		}
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ? AND scope = ? AND scope_value = ? AND effective_to IS NULL", tenantID, version.Scope, version.ScopeValue).
+			First(&rule).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: the rule is already retired", ErrFeeRuleNotFound)
+		}
+		if err != nil {
+			return err
+		}
+		// A version scheduled for later never takes effect
+		end := time.Now().UTC()
+		if end.Before(rule.EffectiveFrom) {
+			end = rule.EffectiveFrom
+		}
+		rule.EffectiveTo = &end
+		return tx.Model(&rule).Update("effective_to", end).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// Quote returns the fee of a conversion approved at t, reading rules and
+// the advertiser's monthly volume inside tx
+func (s *FeeRuleService) Quote(tx *gorm.DB, target FeeTarget, currency string, commission int64, at time.Time) (FeeQuote, error) {
+	if target.TenantID == uuid.Nil {
+		target.TenantID = models.DefaultTenantID
+	}
+	var rules []models.FeeRule
+	if err := tx.Where("tenant_id IN ? AND effective_from <= ? AND (effective_to IS NULL OR effective_to > ?)",
+		[]uuid.UUID{target.TenantID, models.DefaultTenantID}, at, at).Find(&rules).Error; err != nil {
+		return FeeQuote{}, fmt.Errorf("failed to load fee rules: %w", err)
+	}
+
+	if target.Plan == "" {
+		for _, r := range rules {
+			if r.Scope == models.FeeScopePlan {
+				if err := tx.Table("tenants").Select("plan").Where("id = ?", target.TenantID).Scan(&target.Plan).Error; err != nil {
+					return FeeQuote{}, err
+				}
+				break
+			}
+		}
+	}
+
+	rule := ResolveFeeRule(rules, target, at)
+	volume := FeeVolume{Conversions: 1, Spend: commission}
+	if rule != nil && rule.VolumeBasis != models.FeeVolumeNone && target.AdvertiserID != uuid.Nil {
+		prior, err := s.monthlyVolume(tx, target, currency, at)
+		if err != nil {
+			return FeeQuote{}, err
+		}
+		volume.Conversions += prior.Conversions
+		volume.Spend += prior.Spend
+	}
+	return ComputeFee(rule, commission, volume, currency), nil
+}
+
+// monthlyVolume sums the advertiser's approved conversions posted earlier in
+// the calendar month of at (tenant timezone). Reversals are not deducted.
+func (s *FeeRuleService) monthlyVolume(tx *gorm.DB, target FeeTarget, currency string, at time.Time) (FeeVolume, error) {
+	start := StartOfMonthIn(at, GetTenantSettingsResolver(s.db).Location(target.TenantID))
+	var volume FeeVolume
+	err := tx.Table("ledger_transactions t").
+		Select(`COUNT(*) AS conversions,
+			COALESCE(SUM(CASE WHEN t.currency = ? THEN e.amount ELSE 0 END), 0) AS spend`, NormalizeCurrency(currency)).
+		Joins("JOIN ledger_entries e ON e.transaction_id = t.id").
+		Joins("JOIN ledger_accounts a ON a.id = e.account_id AND a.type = ?", models.LedgerAccountAdvertiserReceivable).
+		Where("t.tenant_id = ? AND t.kind = ? AND t.advertiser_id = ?", target.TenantID, models.LedgerKindConversionApproved, target.AdvertiserID).
+		Where("t.occurred_at >= ? AND t.occurred_at <= ?", start, at).
+		Scan(&volume).Error
+	if err != nil {
+		return FeeVolume{}, fmt.Errorf("failed to load advertiser volume: %w", err)
+	}
+	return volume, nil
+}
+
+// Preview quotes the fee of a conversion of an offer by a promoter at t,
+// the way the ledger would on approval
+func (s *FeeRuleService) Preview(tenantID, offerID, promoterID uuid.UUID, currency string, commission int64, at time.Time) (FeeQuote, error) {
+	if tenantID == uuid.Nil {
+		tenantID = models.DefaultTenantID
+	}
+	target := FeeTarget{TenantID: tenantID, OfferID: offerID}
+	if offerID != uuid.Nil {
+		var offer models.Offer
+		if err := s.db.Select("id, advertiser_id").Where("tenant_id = ? AND id = ?", tenantID, offerID).First(&offer).Error; err != nil {
+			return FeeQuote{}, err
+		}
+		target.AdvertiserID = derefUUID(offer.AdvertiserID)
+	}
+	if promoterID != uuid.Nil {
+		var promoter models.AfftokUser
+		if err := s.db.Select("id, total_conversions").Where("id = ?", promoterID).First(&promoter).Error; err != nil {
+			return FeeQuote{}, err
+		}
+		target.PromoterTier = promoter.UserLevel()
+	}
+	return s.Quote(s.db, target, currency, commission, at)
+}
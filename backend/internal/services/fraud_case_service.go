@@ -0,0 +1,906 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/cache"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ============================================
+// FRAUD CASE SERVICE
+// ============================================
+
+// Errors returned by the fraud case workflow
+var (
+	ErrFraudCaseNotFound = errors.New("fraud case not found")
+	ErrFraudCaseClosed   = errors.New("fraud case is already closed")
+	ErrFraudCaseDecision = errors.New("decision must be clear, clawback or ban")
+)
+
+// Fraud case configuration
+const (
+	FraudCaseThreatThrottle  = 10 * time.Minute     // one threat-sourced signal per IP/user per window
+	FraudCaseActivityWindow  = 7 * 24 * time.Hour   // look-back when linking a user's recent activity
+	FraudCaseActivityLimit   = 50                   // max clicks/conversions linked automatically
+	FraudCaseMinClickScore   = 50                   // click fraud score worth linking
+	FraudCaseDefaultBlockTTL = 365 * 24 * time.Hour // IP block duration for bans ("permanent")
+)
+
+// FraudCaseService turns fraud signals into cases that admins can work and
+// decide, applying the decision's actions in bulk
+type FraudCaseService struct {
+	db             *gorm.DB
+	observability  *ObservabilityService
+	threatDetector *ThreatDetector
+}
+
+// NewFraudCaseService creates a new fraud case service
+func NewFraudCaseService(db *gorm.DB) *FraudCaseService {
+	return &FraudCaseService{
+		db:             db,
+		observability:  NewObservabilityService(),
+		threatDetector: GetThreatDetector(),
+	}
+}
+
+// FraudCaseLinks lists entities to attach to a case
+type FraudCaseLinks struct {
+	ClickIDs      []string `json:"click_ids,omitempty"`
+	ConversionIDs []string `json:"conversion_ids,omitempty"`
+	IPs           []string `json:"ips,omitempty"`
+}
+
+// IsEmpty reports whether there is nothing to link
+func (l FraudCaseLinks) IsEmpty() bool {
+	return len(l.ClickIDs) == 0 && len(l.ConversionIDs) == 0 && len(l.IPs) == 0
+}
+
+// OpenFraudCaseRequest describes a new fraud signal. TenantID may be left
+// empty for user signals; the case then belongs to the user's tenant.
+type OpenFraudCaseRequest struct {
+	TenantID  uuid.UUID
+	UserID    *uuid.UUID
+	Source    string
+	SourceRef string
+	Severity  string
+	Title     string
+	Summary   string
+	Links     FraudCaseLinks
+	CreatedBy *uuid.UUID
+}
+
+// ============================================
+// INTAKE
+// ============================================
+
+// OpenCase opens a case for a fraud signal. If the user (or, for anonymous
+// signals, one of the IPs) already has an active case, the signal is merged
+// into it instead. The bool result is true when a new case was created.
+func (s *FraudCaseService) OpenCase(req *OpenFraudCaseRequest) (*models.FraudCase, bool, error) {
+	if req.Severity == "" {
+		req.Severity = models.FraudCaseSeverityMedium
+	}
+	if req.TenantID == uuid.Nil && req.UserID != nil {
+		s.db.Model(&models.AfftokUser{}).Select("tenant_id").Where("id = ?", *req.UserID).Scan(&req.TenantID)
+	}
+	if req.TenantID == uuid.Nil {
+		req.TenantID = models.DefaultTenantID
+	}
+
+	var fraudCase *models.FraudCase
+	created := false
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		existing, err := s.findActiveCase(tx, req.TenantID, req.UserID, req.Links.IPs)
+		if err != nil {
+			return err
+		}
+
+		if existing != nil {
+			fraudCase = existing
+			updates := map[string]interface{}{
+				"signal_count": gorm.Expr("signal_count + 1"),
+				"updated_at":   time.Now().UTC(),
+			}
+			if severity := EscalateFraudSeverity(existing.Severity, req.Severity); severity != existing.Severity {
+				updates["severity"] = severity
+				existing.Severity = severity
+			}
+			if err := tx.Model(existing).UpdateColumns(updates).Error; err != nil {
+				return err
+			}
+			existing.SignalCount++
+
+			note := fmt.Sprintf("Additional %s signal: %s", req.Source, req.Title)
+			if req.Summary != "" {
+				note += " - " + req.Summary
+			}
+			if err := tx.Create(&models.FraudCaseNote{ID: uuid.New(), CaseID: existing.ID, AuthorID: req.CreatedBy, Body: note}).Error; err != nil {
+				return err
+			}
+		} else {
+			fraudCase = &models.FraudCase{
+				TenantModel: models.TenantModel{TenantID: req.TenantID},
+				ID:          uuid.New(),
+				UserID:      req.UserID,
+				Source:      req.Source,
+				SourceRef:   req.SourceRef,
+				Severity:    req.Severity,
+				Title:       req.Title,
+				Summary:     req.Summary,
+				Status:      models.FraudCaseStatusOpen,
+				SignalCount: 1,
+				CreatedBy:   req.CreatedBy,
+			}
+			if err := tx.Create(fraudCase).Error; err != nil {
+				return err
+			}
+			created = true
+		}
+
+		_, err = s.addLinks(tx, fraudCase.ID, req.Links)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if created {
+		userID := ""
+		if req.UserID != nil {
+			userID = req.UserID.String()
+		}
+		s.observability.Log(LogEvent{
+			Category: LogCategoryFraudDetection,
+			Level:    LogLevelWarn,
+			Message:  "Fraud case opened",
+			UserID:   userID,
+			Metadata: map[string]interface{}{
+				"case_id":    fraudCase.ID.String(),
+				"source":     req.Source,
+				"source_ref": req.SourceRef,
+				"severity":   req.Severity,
+			},
+		})
+	}
+
+	return fraudCase, created, nil
+}
+
+// findActiveCase finds the tenant's open/investigating case for a user, or for
+// one of the IPs when the signal is not tied to a user
+func (s *FraudCaseService) findActiveCase(tx *gorm.DB, tenantID uuid.UUID, userID *uuid.UUID, ips []string) (*models.FraudCase, error) {
+	activeStatuses := []string{models.FraudCaseStatusOpen, models.FraudCaseStatusInvestigating}
+
+	var fraudCase models.FraudCase
+	var err error
+	tx = tx.Where("tenant_id = ?", tenantID)
+	if userID != nil {
+		err = tx.Where("user_id = ? AND status IN ?", *userID, activeStatuses).
+			Order("created_at DESC").First(&fraudCase).Error
+	} else {
+		ips = normalizeIPs(ips)
+		if len(ips) == 0 {
+			return nil, nil
+		}
+		err = tx.Where("status IN ?", activeStatuses).
+			Where("id IN (?)", tx.Model(&models.FraudCaseLink{}).Select("case_id").
+				Where("entity_type = ? AND entity_id IN ?", models.FraudCaseLinkIP, ips)).
+			Order("created_at DESC").First(&fraudCase).Error
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &fraudCase, nil
+}
+
+// AttachThreatDetector subscribes the service to threat detector events
+func (s *FraudCaseService) AttachThreatDetector(detector *ThreatDetector) {
+	s.threatDetector = detector
+	detector.OnThreat(s.HandleThreat)
+}
+
+// HandleThreat opens (or updates) a case for high-severity threats
+func (s *FraudCaseService) HandleThreat(threat *ThreatEvent) {
+	if threat == nil || (threat.Severity != SeverityHigh && threat.Severity != SeverityCritical) {
+		return
+	}
+
+	// Threats can fire on every request - keep one signal per IP/user per window
+	if cache.RedisClient != nil {
+		key := fmt.Sprintf("fraud_case:threat:%s:%s", threat.IP, threat.UserID)
+		if ok, err := cache.SetNX(context.Background(), key, threat.ID, FraudCaseThreatThrottle); err == nil && !ok {
+			return
+		}
+	}
+
+	req := &OpenFraudCaseRequest{
+		Source:    models.FraudCaseSourceThreat,
+		SourceRef: threat.ID,
+		Severity:  string(threat.Severity),
+		Title:     fmt.Sprintf("Threat detected: %s", threat.Type),
+		Summary:   threat.Description,
+		Links:     FraudCaseLinks{IPs: []string{threat.IP}},
+	}
+	if tenantID, err := uuid.Parse(threat.TenantID); err == nil {
+		req.TenantID = tenantID
+	}
+	if uid, err := uuid.Parse(threat.UserID); err == nil {
+		req.UserID = &uid
+		s.mergeLinks(&req.Links, s.collectRecentActivity(uid))
+	}
+
+	if _, _, err := s.OpenCase(req); err != nil {
+		log.Printf("[FraudCase] failed to open case for threat %s: %v", threat.ID, err)
+	}
+}
+
+// OpenFromKYCTrigger opens a case when fraud indicators force KYC on a user
+func (s *FraudCaseService) OpenFromKYCTrigger(userID uuid.UUID, reason string) {
+	req := &OpenFraudCaseRequest{
+		UserID:    &userID,
+		Source:    models.FraudCaseSourceKYC,
+		SourceRef: reason,
+		Severity:  models.FraudCaseSeverityHigh,
+		Title:     "KYC required by fraud indicators",
+		Summary:   reason,
+		Links:     s.collectRecentActivity(userID),
+	}
+	if _, _, err := s.OpenCase(req); err != nil {
+		log.Printf("[FraudCase] failed to open KYC case for user %s: %v", userID, err)
+	}
+}
+
+// OpenFromAnomaly opens a case when a conversion anomaly starts holding conversions
+func (s *FraudCaseService) OpenFromAnomaly(anomaly *models.ConversionAnomaly) {
+	userID := anomaly.UserID
+	req := &OpenFraudCaseRequest{
+		TenantID:  anomaly.TenantID,
+		UserID:    &userID,
+		Source:    models.FraudCaseSourceAnomaly,
+		SourceRef: anomaly.ID.String(),
+		Severity:  models.FraudCaseSeverityMedium,
+		Title:     fmt.Sprintf("Conversion anomaly: %s", anomaly.Metric),
+		Summary: fmt.Sprintf("Offer %s: observed %.2f vs baseline %.2f (z=%.2f)",
+			anomaly.OfferID, anomaly.Observed, anomaly.Baseline, anomaly.ZScore),
+		Links: s.collectRecentActivity(userID),
+	}
+	if _, _, err := s.OpenCase(req); err != nil {
+		log.Printf("[FraudCase] failed to open anomaly case for user %s: %v", userID, err)
+	}
+}
+
+// collectRecentActivity gathers a user's recent flagged clicks, their IPs and
+// conversions awaiting a decision
+func (s *FraudCaseService) collectRecentActivity(userID uuid.UUID) FraudCaseLinks {
+	since := time.Now().Add(-FraudCaseActivityWindow)
+	var links FraudCaseLinks
+
+	var clicks []struct {
+		ID        uuid.UUID
+		IPAddress string
+	}
+	s.db.Table("clicks c").
+		Select("c.id, c.ip_address").
+		Joins("JOIN user_offers uo ON c.user_offer_id = uo.id").
+		Where("uo.user_id = ? AND c.clicked_at >= ? AND c.fraud_score >= ?", userID, since, FraudCaseMinClickScore).
+		Order("c.fraud_score DESC").
+		Limit(FraudCaseActivityLimit).
+		Scan(&clicks)
+	for _, click := range clicks {
+		links.ClickIDs = append(links.ClickIDs, click.ID.String())
+		links.IPs = append(links.IPs, click.IPAddress)
+	}
+
+	var conversionIDs []uuid.UUID
+	s.db.Table("conversions c").
+		Joins("JOIN user_offers uo ON c.user_offer_id = uo.id").
+		Where("uo.user_id = ? AND c.converted_at >= ?", userID, since).
+		Where("c.status = ? OR c.fraud_score >= ?", models.ConversionStatusReview, FraudCaseMinClickScore).
+		Limit(FraudCaseActivityLimit).
+		Pluck("c.id", &conversionIDs)
+	for _, id := range conversionIDs {
+		links.ConversionIDs = append(links.ConversionIDs, id.String())
+	}
+
+	return links
+}
+
+func (s *FraudCaseService) mergeLinks(dst *FraudCaseLinks, src FraudCaseLinks) {
+	dst.ClickIDs = append(dst.ClickIDs, src.ClickIDs...)
+	dst.ConversionIDs = append(dst.ConversionIDs, src.ConversionIDs...)
+	dst.IPs = append(dst.IPs, src.IPs...)
+}
+
+// ============================================
+// CASE WORK
+// ============================================
+
+// FraudCaseFilter filters a tenant's case list
+type FraudCaseFilter struct {
+	TenantID   uuid.UUID
+	Status     string
+	Source     string
+	AssigneeID *uuid.UUID
+	UserID     *uuid.UUID
+	Limit      int
+	Offset     int
+}
+
+// ListCases returns cases matching the filter (newest first) and the total count
+func (s *FraudCaseService) ListCases(filter FraudCaseFilter) ([]models.FraudCase, int64, error) {
+	query := s.db.Model(&models.FraudCase{}).Where("tenant_id = ?", filter.TenantID)
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Source != "" {
+		query = query.Where("source = ?", filter.Source)
+	}
+	if filter.AssigneeID != nil {
+		query = query.Where("assignee_id = ?", *filter.AssigneeID)
+	}
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", *filter.UserID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+
+	var cases []models.FraudCase
+	err := query.Order("created_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&cases).Error
+	return cases, total, err
+}
+
+// GetCase returns a tenant's case with its links and notes
+func (s *FraudCaseService) GetCase(tenantID, caseID uuid.UUID) (*models.FraudCase, error) {
+	var fraudCase models.FraudCase
+	err := s.db.
+		Preload("Links", func(db *gorm.DB) *gorm.DB { return db.Order("entity_type, created_at") }).
+		Preload("Notes", func(db *gorm.DB) *gorm.DB { return db.Order("created_at") }).
+		First(&fraudCase, "id = ? AND tenant_id = ?", caseID, tenantID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrFraudCaseNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &fraudCase, nil
+}
+
+// GetCaseAdjustments returns the earnings adjustments created by a case
+func (s *FraudCaseService) GetCaseAdjustments(tenantID, caseID uuid.UUID) ([]models.EarningsAdjustment, error) {
+	var adjustments []models.EarningsAdjustment
+	err := s.db.Where("case_id = ? AND tenant_id = ?", caseID, tenantID).Order("created_at").Find(&adjustments).Error
+	return adjustments, err
+}
+
+// loadActiveCase loads a tenant's case that can still be worked
+func (s *FraudCaseService) loadActiveCase(tx *gorm.DB, tenantID, caseID uuid.UUID) (*models.FraudCase, error) {
+	var fraudCase models.FraudCase
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&fraudCase, "id = ? AND tenant_id = ?", caseID, tenantID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrFraudCaseNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !fraudCase.IsActive() {
+		return nil, ErrFraudCaseClosed
+	}
+	return &fraudCase, nil
+}
+
+// Assign sets (or clears) the case assignee. Assigning moves an open case to investigating.
+func (s *FraudCaseService) Assign(tenantID, caseID uuid.UUID, assigneeID *uuid.UUID, by *uuid.UUID) (*models.FraudCase, error) {
+	var fraudCase *models.FraudCase
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		fraudCase, err = s.loadActiveCase(tx, tenantID, caseID)
+		if err != nil {
+			return err
+		}
+
+		fraudCase.AssigneeID = assigneeID
+		if assigneeID != nil && fraudCase.Status == models.FraudCaseStatusOpen {
+			fraudCase.Status = models.FraudCaseStatusInvestigating
+		}
+		if err := tx.Model(fraudCase).Updates(map[string]interface{}{
+			"assignee_id": assigneeID,
+			"status":      fraudCase.Status,
+			"updated_at":  time.Now().UTC(),
+		}).Error; err != nil {
+			return err
+		}
+
+		body := "Case unassigned"
+		if assigneeID != nil {
+			body = "Case assigned to " + assigneeID.String()
+		}
+		return tx.Create(&models.FraudCaseNote{ID: uuid.New(), CaseID: caseID, AuthorID: by, Body: body}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fraudCase, nil
+}
+
+// SetStatus moves a case between open and investigating (closing requires a decision)
+func (s *FraudCaseService) SetStatus(tenantID, caseID uuid.UUID, status string) error {
+	if status != models.FraudCaseStatusOpen && status != models.FraudCaseStatusInvestigating {
+		return fmt.Errorf("status must be %q or %q", models.FraudCaseStatusOpen, models.FraudCaseStatusInvestigating)
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		fraudCase, err := s.loadActiveCase(tx, tenantID, caseID)
+		if err != nil {
+			return err
+		}
+		return tx.Model(fraudCase).Updates(map[string]interface{}{
+			"status":     status,
+			"updated_at": time.Now().UTC(),
+		}).Error
+	})
+}
+
+// AddNote appends an investigation note
+func (s *FraudCaseService) AddNote(tenantID, caseID uuid.UUID, authorID *uuid.UUID, body string) (*models.FraudCaseNote, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, errors.New("note body is required")
+	}
+
+	var fraudCase models.FraudCase
+	if err := s.db.Select("id").First(&fraudCase, "id = ? AND tenant_id = ?", caseID, tenantID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFraudCaseNotFound
+		}
+		return nil, err
+	}
+
+	note := &models.FraudCaseNote{ID: uuid.New(), CaseID: fraudCase.ID, AuthorID: authorID, Body: body}
+	if err := s.db.Create(note).Error; err != nil {
+		return nil, err
+	}
+	s.db.Model(&models.FraudCase{}).Where("id = ? AND tenant_id = ?", fraudCase.ID, tenantID).UpdateColumn("updated_at", time.Now().UTC())
+	return note, nil
+}
+
+// AddLinks attaches clicks, conversions and IPs to an active case
+func (s *FraudCaseService) AddLinks(tenantID, caseID uuid.UUID, links FraudCaseLinks) (int, error) {
+	added := 0
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := s.loadActiveCase(tx, tenantID, caseID); err != nil {
+			return err
+		}
+		var err error
+		added, err = s.addLinks(tx, caseID, links)
+		return err
+	})
+	return added, err
+}
+
+// addLinks inserts links, skipping invalid IDs and duplicates
+func (s *FraudCaseService) addLinks(tx *gorm.DB, caseID uuid.UUID, links FraudCaseLinks) (int, error) {
+	rows := FraudCaseLinkRows(caseID, links)
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows)
+	return int(result.RowsAffected), result.Error
+}
+
+// FraudCaseLinkRows builds the link rows for a case: IDs must be UUIDs and
+// IPs valid addresses, and each entity is linked once
+func FraudCaseLinkRows(caseID uuid.UUID, links FraudCaseLinks) []models.FraudCaseLink {
+	var rows []models.FraudCaseLink
+	seen := make(map[string]bool)
+	add := func(entityType, entityID string) {
+		key := entityType + ":" + entityID
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		rows = append(rows, models.FraudCaseLink{ID: uuid.New(), CaseID: caseID, EntityType: entityType, EntityID: entityID})
+	}
+
+	for _, id := range links.ClickIDs {
+		if parsed, err := uuid.Parse(strings.TrimSpace(id)); err == nil {
+			add(models.FraudCaseLinkClick, parsed.String())
+		}
+	}
+	for _, id := range links.ConversionIDs {
+		if parsed, err := uuid.Parse(strings.TrimSpace(id)); err == nil {
+			add(models.FraudCaseLinkConversion, parsed.String())
+		}
+	}
+	for _, ip := range normalizeIPs(links.IPs) {
+		add(models.FraudCaseLinkIP, ip)
+	}
+	return rows
+}
+
+// normalizeIPs trims, validates and de-duplicates IP addresses
+func normalizeIPs(ips []string) []string {
+	out := make([]string, 0, len(ips))
+	seen := make(map[string]bool, len(ips))
+	for _, ip := range ips {
+		parsed := net.ParseIP(strings.TrimSpace(ip))
+		if parsed == nil {
+			continue
+		}
+		normalized := parsed.String()
+		if !seen[normalized] {
+			seen[normalized] = true
+			out = append(out, normalized)
+		}
+	}
+	return out
+}
+
+// EscalateFraudSeverity returns the severity of a case after a new signal:
+// a case is raised to the signal's severity but never lowered
+func EscalateFraudSeverity(current, incoming string) string {
+	if severityRank(incoming) > severityRank(current) {
+		return incoming
+	}
+	return current
+}
+
+// severityRank orders severities for escalation
+func severityRank(severity string) int {
+	switch severity {
+	case models.FraudCaseSeverityCritical:
+		return 4
+	case models.FraudCaseSeverityHigh:
+		return 3
+	case models.FraudCaseSeverityMedium:
+		return 2
+	case models.FraudCaseSeverityLow:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// ============================================
+// DECISIONS
+// ============================================
+
+// FraudCaseActionSummary records what a decision changed
+type FraudCaseActionSummary struct {
+	Decision            string      `json:"decision"`
+	RejectedConversions int64       `json:"rejected_conversions"`
+	ReversedConversions int         `json:"reversed_conversions"`
+	ReversedAmount      int         `json:"reversed_amount"`
+	AdjustmentIDs       []uuid.UUID `json:"adjustment_ids,omitempty"`
+	UserSuspended       bool        `json:"user_suspended"`
+	BlockedIPs          []string    `json:"blocked_ips,omitempty"`
+}
+
+// FraudDecisionPlan lists the bulk actions a decision applies
+type FraudDecisionPlan struct {
+	RejectPending bool // reject pending/held conversions
+	Reverse       bool // reverse approved or paid linked conversions
+	Suspend       bool // suspend the user
+	BlockIPs      bool // block the linked IPs
+	ReleaseHolds  bool // release payouts held for fraud
+}
+
+// PlanFraudDecision returns the actions for a decision
+func PlanFraudDecision(decision string) (FraudDecisionPlan, error) {
+	switch decision {
+	case models.FraudDecisionClear:
+		return FraudDecisionPlan{ReleaseHolds: true}, nil
+	case models.FraudDecisionClawback:
+		return FraudDecisionPlan{RejectPending: true, Reverse: true}, nil
+	case models.FraudDecisionBan:
+		return FraudDecisionPlan{RejectPending: true, Reverse: true, Suspend: true, BlockIPs: true}, nil
+	}
+	return FraudDecisionPlan{}, ErrFraudCaseDecision
+}
+
+// Decide closes a case and bulk-applies the decision:
+//   - clear: close without action
+//   - clawback: reject pending/held conversions, reverse approved or paid linked conversions
+//   - ban: clawback + suspend the user + block the linked IPs
+func (s *FraudCaseService) Decide(tenantID, caseID uuid.UUID, decision string, decidedBy *uuid.UUID, notes string, blockDuration time.Duration) (*models.FraudCase, *FraudCaseActionSummary, error) {
+	plan, err := PlanFraudDecision(decision)
+	if err != nil {
+		return nil, nil, err
+	}
+	if blockDuration <= 0 {
+		blockDuration = FraudCaseDefaultBlockTTL
+	}
+
+	summary := &FraudCaseActionSummary{Decision: decision}
+	var fraudCase *models.FraudCase
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		fraudCase, err = s.loadActiveCase(tx, tenantID, caseID)
+		if err != nil {
+			return err
+		}
+
+		var links []models.FraudCaseLink
+		if err := tx.Where("case_id = ?", caseID).Find(&links).Error; err != nil {
+			return err
+		}
+		var conversionIDs []string
+		var ips []string
+		for _, link := range links {
+			switch link.EntityType {
+			case models.FraudCaseLinkConversion:
+				conversionIDs = append(conversionIDs, link.EntityID)
+			case models.FraudCaseLinkIP:
+				ips = append(ips, link.EntityID)
+			}
+		}
+
+		reason := fmt.Sprintf("fraud case %s: %s", caseID, decision)
+
+		if plan.RejectPending {
+			if summary.RejectedConversions, err = s.rejectPendingConversions(tx, tenantID, fraudCase.UserID, conversionIDs, reason); err != nil {
+				return err
+			}
+		}
+		if plan.Reverse {
+			if err := s.reverseConversions(tx, tenantID, caseID, conversionIDs, decidedBy, reason, summary); err != nil {
+				return err
+			}
+		}
+
+		if plan.Suspend && fraudCase.UserID != nil {
+			if err := tx.Model(&models.AfftokUser{}).Where("id = ?", *fraudCase.UserID).
+				Update("status", "suspended").Error; err != nil {
+				return err
+			}
+			summary.UserSuspended = true
+		}
+		if plan.BlockIPs {
+			summary.BlockedIPs = ips
+		}
+
+		// A cleared promoter's payouts held for fraud are released once no
+		// other case is open; after a clawback they stay held for review
+		if plan.ReleaseHolds && fraudCase.UserID != nil {
+			var others int64
+			tx.Model(&models.FraudCase{}).
+				Where("user_id = ? AND id <> ? AND status IN ?", *fraudCase.UserID, caseID,
+					[]string{models.FraudCaseStatusOpen, models.FraudCaseStatusInvestigating}).
+				Count(&others)
+			if others == 0 {
+				if _, err := NewPayoutService(tx).ReleaseHolds(*fraudCase.UserID, PayoutHoldReasonFraud, decidedBy); err != nil {
+					return err
+				}
+			}
+		}
+
+		summaryJSON, _ := json.Marshal(summary)
+		now := time.Now().UTC()
+		fraudCase.Status = models.FraudCaseStatusClosed
+		fraudCase.Decision = decision
+		fraudCase.DecisionNotes = notes
+		fraudCase.DecidedBy = decidedBy
+		fraudCase.DecidedAt = &now
+		fraudCase.ActionSummary = summaryJSON
+		if err := tx.Model(fraudCase).Updates(map[string]interface{}{
+			"status":         fraudCase.Status,
+			"decision":       decision,
+			"decision_notes": notes,
+			"decided_by":     decidedBy,
+			"decided_at":     now,
+			"action_summary": summaryJSON,
+			"updated_at":     now,
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.FraudCaseNote{
+			ID:       uuid.New(),
+			CaseID:   caseID,
+			AuthorID: decidedBy,
+			Body:     fmt.Sprintf("Decision: %s. %s", decision, notes),
+		}).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// IP blocks live in Redis/memory, so they are applied once the case is committed
+	blockedBy := "fraud_case"
+	if decidedBy != nil {
+		blockedBy = decidedBy.String()
+	}
+	for _, ip := range summary.BlockedIPs {
+		s.blockIP(ip, fmt.Sprintf("fraud case %s", caseID), blockDuration, blockedBy)
+	}
+
+	userID := ""
+	if fraudCase.UserID != nil {
+		userID = fraudCase.UserID.String()
+	}
+	s.observability.Log(LogEvent{
+		Category: LogCategoryFraudDetection,
+		Level:    LogLevelWarn,
+		Message:  "Fraud case decided",
+		UserID:   userID,
+		Metadata: map[string]interface{}{
+			"case_id":              caseID.String(),
+			"decision":             decision,
+			"rejected_conversions": summary.RejectedConversions,
+			"reversed_conversions": summary.ReversedConversions,
+			"reversed_amount":      summary.ReversedAmount,
+			"user_suspended":       summary.UserSuspended,
+			"blocked_ips":          len(summary.BlockedIPs),
+		},
+	})
+
+	return fraudCase, summary, nil
+}
+
+// rejectPendingConversions rejects the linked conversions and, for user cases,
+// all of the user's conversions still awaiting a decision
+func (s *FraudCaseService) rejectPendingConversions(tx *gorm.DB, tenantID uuid.UUID, userID *uuid.UUID, conversionIDs []string, reason string) (int64, error) {
+	if userID == nil && len(conversionIDs) == 0 {
+		return 0, nil
+	}
+
+	query := tx.Model(&models.Conversion{}).
+		Where("tenant_id = ? AND status IN ?", tenantID, []string{models.ConversionStatusPending, models.ConversionStatusReview})
+	switch {
+	case userID != nil && len(conversionIDs) > 0:
+		query = query.Where("id IN ? OR user_offer_id IN (?)", conversionIDs,
+			tx.Model(&models.UserOffer{}).Select("id").Where("user_id = ?", *userID))
+	case userID != nil:
+		query = query.Where("user_offer_id IN (?)",
+			tx.Model(&models.UserOffer{}).Select("id").Where("user_id = ?", *userID))
+	default:
+		query = query.Where("id IN ?", conversionIDs)
+	}
+
+	result := query.Updates(map[string]interface{}{
+		"status":           models.ConversionStatusRejected,
+		"rejection_reason": reason,
+	})
+	return result.RowsAffected, result.Error
+}
+
+// reverseConversions claws back commission already credited for linked
+// conversions through ledger reversals, each recorded as an applied earnings
+// adjustment on the case. Approved conversions are rejected; paid ones keep
+// their status and the negative balance is recovered from future payouts.
+func (s *FraudCaseService) reverseConversions(tx *gorm.DB, tenantID, caseID uuid.UUID, conversionIDs []string, by *uuid.UUID, reason string, summary *FraudCaseActionSummary) error {
+	if len(conversionIDs) == 0 {
+		return nil
+	}
+
+	var credited []struct {
+		ID          uuid.UUID
+		UserOfferID uuid.UUID
+		UserID      uuid.UUID
+		Commission  int
+		Currency    string
+		Status      string
+	}
+	if err := tx.Table("conversions c").
+		Select("c.id, c.user_offer_id, uo.user_id, c.commission, c.currency, c.status").
+		Joins("JOIN user_offers uo ON c.user_offer_id = uo.id").
+		Where("c.tenant_id = ? AND c.id IN ? AND c.status IN ?", tenantID, conversionIDs,
+			[]string{models.ConversionStatusApproved, models.ConversionStatusPaid}).
+		Scan(&credited).Error; err != nil {
+		return err
+	}
+
+	for _, conv := range credited {
+		// The ledger reversal debits the earnings counters
+		// and is what recovers the commission from the promoter's balance
+		reversal, err := GetLedgerService(s.db).PostConversionReversed(tx, conv.ID, reason, by)
+		if err != nil {
+			return err
+		}
+
+		convID := conv.ID
+		adjustment := models.EarningsAdjustment{
+			TenantModel:  models.TenantModel{TenantID: tenantID},
+			ID:           uuid.New(),
+			UserID:       conv.UserID,
+			ConversionID: &convID,
+			CaseID:       &caseID,
+			Amount:       -conv.Commission,
+			Currency:     conv.Currency,
+			Reason:       reason,
+			Status:       models.AdjustmentStatusApplied,
+			CreatedBy:    by,
+		}
+		if reversal != nil {
+			adjustment.LedgerTransactionID = &reversal.ID
+		}
+		if err := tx.Create(&adjustment).Error; err != nil {
+			return err
+		}
+
+		if conv.Status == models.ConversionStatusApproved {
+			if err := tx.Model(&models.Conversion{}).Where("id = ?", conv.ID).Updates(map[string]interface{}{
+				"status":           models.ConversionStatusRejected,
+				"rejection_reason": reason,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		summary.ReversedConversions++
+		summary.ReversedAmount += conv.Commission
+		summary.AdjustmentIDs = append(summary.AdjustmentIDs, adjustment.ID)
+	}
+	return nil
+}
+
+// blockIP blocks an IP in the threat detector and in the admin fraud block
+// list so it shows up alongside manual blocks
+func (s *FraudCaseService) blockIP(ip, reason string, duration time.Duration, blockedBy string) {
+	if s.threatDetector != nil {
+		s.threatDetector.BlockIP(ip, reason, duration, blockedBy)
+	}
+
+	if cache.RedisClient != nil {
+		ctx := context.Background()
+		key := NSFraud + "blocked_ip:" + ip
+		cache.RedisClient.HSet(ctx, key, map[string]interface{}{
+			"ip":         ip,
+			"reason":     reason,
+			"blocked_at": time.Now().UTC().Format(time.RFC3339),
+			"blocked_by": blockedBy,
+		})
+		cache.RedisClient.Expire(ctx, key, duration)
+	}
+
+	s.observability.LogFraud(ip, "", "case_block: "+reason, 100, 1.0, []string{"fraud_case_blocked"}, nil)
+}
+
+// CountActiveCases returns the number of a tenant's cases awaiting a decision
+func (s *FraudCaseService) CountActiveCases(tenantID uuid.UUID) int64 {
+	var count int64
+	s.db.Model(&models.FraudCase{}).
+		Where("tenant_id = ? AND status IN ?", tenantID, []string{models.FraudCaseStatusOpen, models.FraudCaseStatusInvestigating}).
+		Count(&count)
+	return count
+}
+
+// ============================================
+// GLOBAL INSTANCE
+// ============================================
+
+var (
+	fraudCaseInstance *FraudCaseService
+	fraudCaseOnce     sync.Once
+)
+
+// GetFraudCaseService returns the global fraud case service
+func GetFraudCaseService(db *gorm.DB) *FraudCaseService {
+	fraudCaseOnce.Do(func() {
+		fraudCaseInstance = NewFraudCaseService(db)
+	})
+	return fraudCaseInstance
+}
@@ -0,0 +1,518 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ============================================
+// FX RATES SERVICE
+// ============================================
+
+// FX configuration
+const (
+	// FXPivotCurrency is the currency cross rates are triangulated through
+	FXPivotCurrency = "USD"
+	// MaxFXRateAge is how far back a rate may be used when a day has none
+	// (weekends, holidays, a late import)
+	MaxFXRateAge = 7 * 24 * time.Hour
+	// fxRefreshInterval is how often the daily refresh job checks for today's rates
+	fxRefreshInterval = time.Hour
+	fxCacheTTL        = 10 * time.Minute
+)
+
+// FX errors
+var (
+	ErrFXRateNotFound = errors.New("no exchange rate available")
+	ErrFXInvalidRate  = errors.New("invalid exchange rate")
+	ErrFXNoSource     = errors.New("no exchange rate source configured")
+)
+
+// FXQuote is a rate as delivered by a source
+type FXQuote struct {
+	Date  time.Time
+	Base  string
+	Quote string
+	Rate  float64
+}
+
+// FXRateSource delivers daily exchange rates
+type FXRateSource interface {
+	// Name identifies the source on stored rates ("csv", "http")
+	Name() string
+
+	// Fetch returns the rates published for a day
+	Fetch(ctx context.Context, date time.Time) ([]FXQuote, error)
+}
+
+// FXConversion is the rate used to convert an amount, kept with the result
+// so the conversion can be explained later
+type FXConversion struct {
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Rate   float64   `json:"rate"` // units of To per one unit of From
+	Date   time.Time `json:"date"` // day the rate was published for
+	Source string    `json:"source,omitempty"`
+}
+
+type cachedFXRate struct {
+	conversion FXConversion
+	err        error
+	loadedAt   time.Time
+}
+
+// FXService stores exchange rates and converts money between currencies
+type FXService struct {
+	db     *gorm.DB
+	source FXRateSource
+	cache  sync.Map // "FROM:TO:YYYY-MM-DD" -> *cachedFXRate
+
+	mu      sync.Mutex
+	running bool
+	stop    chan struct{}
+}
+
+var (
+	fxService     *FXService
+	fxServiceOnce sync.Once
+)
+
+// GetFXService returns the singleton FX service. FX_RATES_URL selects the
+// HTTP source, FX_RATES_CSV a CSV file re-read daily; without either, rates
+// are only imported through the admin API.
+func GetFXService(db *gorm.DB) *FXService {
+	fxServiceOnce.Do(func() {
+		fxService = NewFXService(db, defaultFXRateSource())
+	})
+	return fxService
+}
+
+func defaultFXRateSource() FXRateSource {
+	if url := os.Getenv("FX_RATES_URL"); url != "" {
+		return NewHTTPFXRateSource(url, os.Getenv("FX_RATES_API_KEY"))
+	}
+	if path := os.Getenv("FX_RATES_CSV"); path != "" {
+		return NewCSVFXRateSource(path)
+	}
+	return nil
+}
+
+// NewFXService creates an FX service; source may be nil
+func NewFXService(db *gorm.DB, source FXRateSource) *FXService {
+	return &FXService{db: db, source: source}
+}
+
+// SetSource sets the rate source used by Refresh
+func (s *FXService) SetSource(source FXRateSource) {
+	s.source = source
+}
+
+// SourceName returns the configured source ("none" without one)
+func (s *FXService) SourceName() string {
+	if s.source == nil {
+		return "none"
+	}
+	return s.source.Name()
+}
+
+// ============================================
+// IMPORT
+// ============================================
+
+// Import upserts rates; a rate for the same day and pair is replaced
+func (s *FXService) Import(quotes []FXQuote, source string) (int, error) {
+	rates := make([]models.FXRate, 0, len(quotes))
+	for _, q := range quotes {
+		base, quote := NormalizeCurrency(q.Base), NormalizeCurrency(q.Quote)
+		if !ValidCurrency(base) || !ValidCurrency(quote) || base == quote || q.Rate <= 0 || q.Date.IsZero() {
+			return 0, fmt.Errorf("%w: %s/%s %v on %s", ErrFXInvalidRate, q.Base, q.Quote, q.Rate, q.Date.Format("2006-01-02"))
+		}
+		rates = append(rates, models.FXRate{
+			ID:     uuid.New(),
+			Date:   fxDay(q.Date),
+			Base:   base,
+			Quote:  quote,
+			Rate:   q.Rate,
+			Source: source,
+		})
+	}
+	if len(rates) == 0 {
+		return 0, nil
+	}
+
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "date"}, {Name: "base"}, {Name: "quote"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "updated_at"}),
+	}).CreateInBatches(&rates, 500).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to store exchange rates: %w", err)
+	}
+	s.cache.Range(func(key, _ interface{}) bool {
+		s.cache.Delete(key)
+		return true
+	})
+	return len(rates), nil
+}
+
+// ImportCSV imports rates from CSV with a date,base,quote,rate header
+func (s *FXService) ImportCSV(r io.Reader) (int, error) {
+	quotes, err := ParseFXRatesCSV(r)
+	if err != nil {
+		return 0, err
+	}
+	return s.Import(quotes, "csv")
+}
+
+// ParseFXRatesCSV reads "date,base,quote,rate" rows (dates as YYYY-MM-DD).
+// The header row is required; columns may appear in any order.
+func ParseFXRatesCSV(r io.Reader) ([]FXQuote, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing header", ErrFXInvalidRate)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\uFEFF")))] = i
+	}
+	for _, name := range []string{"date", "base", "quote", "rate"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%w: missing %q column", ErrFXInvalidRate, name)
+		}
+	}
+
+	var quotes []FXQuote
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrFXInvalidRate, line, err)
+		}
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(record[columns["date"]]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: bad date", ErrFXInvalidRate, line)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(record[columns["rate"]]), 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("%w: line %d: bad rate", ErrFXInvalidRate, line)
+		}
+		base := NormalizeCurrency(record[columns["base"]])
+		quote := NormalizeCurrency(record[columns["quote"]])
+		if !ValidCurrency(base) || !ValidCurrency(quote) {
+			return nil, fmt.Errorf("%w: line %d: bad currency", ErrFXInvalidRate, line)
+		}
+		quotes = append(quotes, FXQuote{Date: date, Base: base, Quote: quote, Rate: rate})
+	}
+	return quotes, nil
+}
+
+// Refresh pulls a day's rates from the configured source
+func (s *FXService) Refresh(ctx context.Context, date time.Time) (int, error) {
+	if s.source == nil {
+		return 0, ErrFXNoSource
+	}
+	quotes, err := s.source.Fetch(ctx, fxDay(date))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", s.source.Name(), err)
+	}
+	return s.Import(quotes, s.source.Name())
+}
+
+// StartDailyRefresh pulls today's rates from the source once they are
+// missing; it does nothing without a source
+func (s *FXService) StartDailyRefresh() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running || s.source == nil {
+		return
+	}
+	s.running = true
+	s.stop = make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(fxRefreshInterval)
+		defer ticker.Stop()
+		for {
+			today := fxDay(time.Now())
+			var count int64
+			s.db.Model(&models.FXRate{}).Where("date = ? AND source = ?", today, s.source.Name()).Count(&count)
+			if count == 0 {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+				if n, err := s.Refresh(ctx, today); err != nil {
+					log.Printf("[FX] refresh failed: %v", err)
+				} else {
+					log.Printf("[FX] imported %d rates for %s", n, today.Format("2006-01-02"))
+				}
+				cancel()
+			}
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops the daily refresh job
+func (s *FXService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		close(s.stop)
+		s.running = false
+	}
+}
+
+// ListRates returns the rates stored for a day (the latest day when zero)
+func (s *FXService) ListRates(date time.Time, base string) ([]models.FXRate, error) {
+	query := s.db.Model(&models.FXRate{})
+	if date.IsZero() {
+		query = query.Where("date = (SELECT MAX(date) FROM fx_rates)")
+	} else {
+		query = query.Where("date = ?", fxDay(date))
+	}
+	if base != "" {
+		query = query.Where("base = ?", NormalizeCurrency(base))
+	}
+	var rates []models.FXRate
+	err := query.Order("base, quote").Find(&rates).Error
+	return rates, err
+}
+
+// ============================================
+// CONVERSION
+// ============================================
+
+// Rate returns the rate from one currency to another on a day, using the
+// latest rate at most MaxFXRateAge old. Direct, inverse and pivot (USD)
+// cross rates are tried in that order.
+func (s *FXService) Rate(from, to string, on time.Time) (FXConversion, error) {
+	from, to = NormalizeCurrency(from), NormalizeCurrency(to)
+	day := fxDay(on)
+	if from == to {
+		return FXConversion{From: from, To: to, Rate: 1, Date: day}, nil
+	}
+
+	key := from + ":" + to + ":" + day.Format("2006-01-02")
+	if value, ok := s.cache.Load(key); ok {
+		entry := value.(*cachedFXRate)
+		if time.Since(entry.loadedAt) < fxCacheTTL {
+			return entry.conversion, entry.err
+		}
+	}
+
+	conversion, err := s.lookup(from, to, day)
+	if err != nil && from != FXPivotCurrency && to != FXPivotCurrency {
+		var first, second FXConversion
+		if first, err = s.lookup(from, FXPivotCurrency, day); err == nil {
+			if second, err = s.lookup(FXPivotCurrency, to, day); err == nil {
+				conversion = FXConversion{From: from, To: to, Rate: first.Rate * second.Rate, Date: first.Date, Source: first.Source}
+				if second.Date.Before(first.Date) {
+					conversion.Date = second.Date
+				}
+			}
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %s to %s on %s", ErrFXRateNotFound, from, to, day.Format("2006-01-02"))
+	}
+
+	s.cache.Store(key, &cachedFXRate{conversion: conversion, err: err, loadedAt: time.Now()})
+	return conversion, err
+}
+
+// lookup finds a direct or inverse stored rate
+func (s *FXService) lookup(from, to string, day time.Time) (FXConversion, error) {
+	var candidates []models.FXRate
+	err := s.db.Where("((base = ? AND quote = ?) OR (base = ? AND quote = ?)) AND date <= ? AND date >= ?",
+		from, to, to, from, day, day.Add(-MaxFXRateAge)).
+		Order("date DESC").Limit(2).Find(&candidates).Error
+	if err != nil {
+		return FXConversion{}, err
+	}
+	if len(candidates) == 0 {
+		return FXConversion{}, ErrFXRateNotFound
+	}
+	// Prefer the direct quote when both directions were published the same day
+	rate := candidates[0]
+	if len(candidates) == 2 && candidates[1].Date.Equal(rate.Date) && candidates[1].Base == from {
+		rate = candidates[1]
+	}
+
+	conversion := FXConversion{From: from, To: to, Rate: rate.Rate, Date: rate.Date, Source: rate.Source}
+	if rate.Base != from {
+		conversion.Rate = 1 / rate.Rate
+	}
+	return conversion, nil
+}
+
+// Convert converts money to another currency at the rate of a day
+func (s *FXService) Convert(amount Money, to string, on time.Time) (Money, FXConversion, error) {
+	conversion, err := s.Rate(amount.Currency, to, on)
+	if err != nil {
+		return Money{}, conversion, err
+	}
+	return amount.Convert(to, conversion.Rate), conversion, nil
+}
+
+// Snapshot returns the rates from one currency into each target currency
+// on a day. Targets without a rate are left out.
+func (s *FXService) Snapshot(from string, targets []string, on time.Time) map[string]float64 {
+	rates := make(map[string]float64, len(targets))
+	for _, to := range targets {
+		to = NormalizeCurrency(to)
+		if _, done := rates[to]; done {
+			continue
+		}
+		if conversion, err := s.Rate(from, to, on); err == nil {
+			rates[to] = conversion.Rate
+		}
+	}
+	return rates
+}
+
+// ReportingCurrency returns the currency an account reports in: its own
+// setting, else its tenant's
+func (s *FXService) ReportingCurrency(tenantID, userID uuid.UUID) string {
+	if userID != uuid.Nil && s.db != nil {
+		var currencies []string
+		s.db.Model(&models.AfftokUser{}).Where("id = ?", userID).Limit(1).Pluck("reporting_currency", &currencies)
+		if len(currencies) > 0 && ValidCurrency(currencies[0]) {
+			return strings.ToUpper(currencies[0])
+		}
+	}
+	return GetTenantSettingsResolver(s.db).Get(tenantID).ReportingCurrency
+}
+
+// fxDay truncates a time to its UTC calendar day
+func fxDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// ============================================
+// SOURCES
+// ============================================
+
+// CSVFXRateSource re-reads a CSV file (date,base,quote,rate) and returns the
+// rows of the requested day
+type CSVFXRateSource struct {
+	Path string
+}
+
+// NewCSVFXRateSource creates a CSV file source
+func NewCSVFXRateSource(path string) *CSVFXRateSource {
+	return &CSVFXRateSource{Path: path}
+}
+
+// Name returns the source identifier
+func (s *CSVFXRateSource) Name() string {
+	return "csv"
+}
+
+// Fetch returns the file's rows for date
+func (s *CSVFXRateSource) Fetch(ctx context.Context, date time.Time) ([]FXQuote, error) {
+	file, err := os.Open(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	quotes, err := ParseFXRatesCSV(file)
+	if err != nil {
+		return nil, err
+	}
+	day := fxDay(date)
+	var matching []FXQuote
+	for _, q := range quotes {
+		if fxDay(q.Date).Equal(day) {
+			matching = append(matching, q)
+		}
+	}
+	return matching, nil
+}
+
+// HTTPFXRateSource fetches {"base": "USD", "date": "2025-01-31", "rates": {"KWD": 0.308, ...}}
+// documents, the shape most rate APIs return. The URL may contain {date}
+// (YYYY-MM-DD); the API key, if any, is sent as a bearer token.
+type HTTPFXRateSource struct {
+	URL    string
+	APIKey string
+	client *http.Client
+}
+
+// NewHTTPFXRateSource creates an HTTP JSON source
+func NewHTTPFXRateSource(url, apiKey string) *HTTPFXRateSource {
+	return &HTTPFXRateSource{URL: url, APIKey: apiKey, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Name returns the source identifier
+func (s *HTTPFXRateSource) Name() string {
+	return "http"
+}
+
+// Fetch downloads the rates of a day
+func (s *HTTPFXRateSource) Fetch(ctx context.Context, date time.Time) ([]FXQuote, error) {
+	url := strings.ReplaceAll(s.URL, "{date}", date.Format("2006-01-02"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rate source returned %d", resp.StatusCode)
+	}
+
+	var doc struct {
+		Base  string             `json:"base"`
+		Date  string             `json:"date"`
+		Rates map[string]float64 `json:"rates"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid rate document: %w", err)
+	}
+
+	published := date
+	if parsed, err := time.Parse("2006-01-02", doc.Date); err == nil {
+		published = parsed
+	}
+	base := NormalizeCurrency(doc.Base)
+	quotes := make([]FXQuote, 0, len(doc.Rates))
+	for quote, rate := range doc.Rates {
+		quote = NormalizeCurrency(quote)
+		if quote == base || rate <= 0 || !ValidCurrency(quote) {
+			continue
+		}
+		quotes = append(quotes, FXQuote{Date: published, Base: base, Quote: quote, Rate: rate})
+	}
+	return quotes, nil
+}
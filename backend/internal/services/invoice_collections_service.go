@@ -0,0 +1,535 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ============================================
+// INVOICE PAYMENTS & COLLECTIONS
+// ============================================
+// Advertisers pay invoices by transfer and upload the receipt; an admin
+// confirms the payment (the ledger records it) or rejects it. A job moves
+// unpaid invoices past their due date to overdue, emails reminders at the
+// tenant's offsets and, when the tenant configured it, pauses the
+// advertiser's offers until the invoice is paid.
+
+// InvoiceCollectionsInterval is how often the collections job runs
+const InvoiceCollectionsInterval = time.Hour
+
+const invoiceCollectionsBatch = 200
+
+// Invoice payment errors
+var (
+	ErrInvoiceNotPayable         = errors.New("invoice is already paid or cancelled")
+	ErrInvoiceNotAwaitingReview  = errors.New("invoice has no payment waiting for review")
+	ErrReceiptStorageUnavailable = errors.New("receipt storage is not configured")
+)
+
+// unpaidInvoiceStatuses are the statuses the collections job works on
+var unpaidInvoiceStatuses = []string{models.InvoiceStatusPending, models.InvoiceStatusOverdue}
+
+// InvoiceCollectionsService handles invoice payment submissions, their review
+// and the overdue/reminder job
+type InvoiceCollectionsService struct {
+	db              *gorm.DB
+	ledger          *LedgerService
+	settings        *TenantSettingsResolver
+	storage         ReceiptStorage
+	maxReceiptBytes int64
+	email           EmailSender
+
+	mu      sync.Mutex
+	running bool
+	stop    chan struct{}
+}
+
+var (
+	invoiceCollectionsService     *InvoiceCollectionsService
+	invoiceCollectionsServiceOnce sync.Once
+)
+
+// GetInvoiceCollectionsService returns the singleton collections service with
+// the receipt storage configured from the environment
+func GetInvoiceCollectionsService(db *gorm.DB) *InvoiceCollectionsService {
+	invoiceCollectionsServiceOnce.Do(func() {
+		invoiceCollectionsService = NewInvoiceCollectionsService(db)
+		invoiceCollectionsService.SetLedgerService(GetLedgerService(db))
+		storage, err := GetReceiptStorage()
+		if err != nil {
+			log.Printf("[Invoices] receipt uploads disabled: %v", err)
+		}
+		invoiceCollectionsService.SetReceiptStorage(storage, ReceiptStorageConfigFromEnv().MaxBytes)
+	})
+	return invoiceCollectionsService
+}
+
+// NewInvoiceCollectionsService creates a collections service without receipt storage
+func NewInvoiceCollectionsService(db *gorm.DB) *InvoiceCollectionsService {
+	return &InvoiceCollectionsService{
+		db:              db,
+		settings:        GetTenantSettingsResolver(db),
+		maxReceiptBytes: DefaultMaxReceiptBytes,
+		email:           GetEmailSender(),
+	}
+}
+
+// SetLedgerService sets the ledger confirmed payments are posted to
+func (s *InvoiceCollectionsService) SetLedgerService(ledger *LedgerService) {
+	s.ledger = ledger
+}
+
+// SetReceiptStorage sets where uploaded receipts are kept; storage may be nil
+func (s *InvoiceCollectionsService) SetReceiptStorage(storage ReceiptStorage, maxBytes int64) {
+	s.storage = storage
+	if maxBytes > 0 {
+		s.maxReceiptBytes = maxBytes
+	}
+}
+
+// SetEmailSender sets the sender used for reminders and review notices
+func (s *InvoiceCollectionsService) SetEmailSender(sender EmailSender) {
+	s.email = sender
+}
+
+// MaxReceiptBytes is the largest receipt accepted
+func (s *InvoiceCollectionsService) MaxReceiptBytes() int64 {
+	return s.maxReceiptBytes
+}
+
+// ============================================
+// REMINDER & PAUSE RULES
+// ============================================
+
+// InvoiceReminderDue returns the reminder an unpaid invoice is owed at now,
+// as days relative to its due date: the latest offset reached that is later
+// than the last one sent. Offsets missed while the job was down collapse
+// into one reminder.
+func InvoiceReminderDue(offsets []int, due time.Time, last *int, now time.Time) (int, bool) {
+	offset, found := 0, false
+	for _, o := range offsets {
+		if last != nil && o <= *last {
+			continue
+		}
+		if now.Before(due.AddDate(0, 0, o)) {
+			continue
+		}
+		if !found || o > offset {
+			offset, found = o, true
+		}
+	}
+	return offset, found
+}
+
+// InvoicePauseDue reports whether an invoice is overdue long enough to pause
+// its advertiser's offers (pauseAfterDays 0 = never)
+func InvoicePauseDue(pauseAfterDays int, due, now time.Time) bool {
+	return pauseAfterDays > 0 && !now.Before(due.AddDate(0, 0, pauseAfterDays))
+}
+
+// InvoiceReminderSubject is the subject of a reminder sent offset days from
+// the due date
+func InvoiceReminderSubject(label string, offset int) string {
+	switch {
+	case offset < -1:
+		return fmt.Sprintf("Invoice %s is due in %d days", label, -offset)
+	case offset == -1:
+		return fmt.Sprintf("Invoice %s is due tomorrow", label)
+	case offset == 0:
+		return fmt.Sprintf("Invoice %s is due today", label)
+	case offset == 1:
+		return fmt.Sprintf("Invoice %s is 1 day overdue", label)
+	}
+	return fmt.Sprintf("Invoice %s is %d days overdue", label, offset)
+}
+
+// invoiceLabel names an invoice in messages
+func invoiceLabel(invoice *models.Invoice) string {
+	if invoice.Number != "" {
+		return invoice.Number
+	}
+	return fmt.Sprintf("%d-%02d", invoice.Year, invoice.Month)
+}
+
+// ============================================
+// PAYMENT SUBMISSION & REVIEW
+// ============================================
+
+// InvoicePayment is what an advertiser submits for an invoice: an uploaded
+// receipt, a link to one hosted elsewhere, or only a note
+type InvoicePayment struct {
+	Method      string
+	Note        string
+	ProofURL    string
+	Receipt     []byte
+	ReceiptName string
+}
+
+func (s *InvoiceCollectionsService) find(db *gorm.DB, tenantID, invoiceID uuid.UUID, advertiserID *uuid.UUID) (*models.Invoice, error) {
+	query := db.Where("tenant_id = ? AND id = ?", tenantID, invoiceID)
+	if advertiserID != nil {
+		query = query.Where("advertiser_id = ?", *advertiserID)
+	}
+	var invoice models.Invoice
+	if err := query.First(&invoice).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvoiceNotFound
+		}
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// SubmitPayment records an advertiser's payment of an invoice and puts it up
+// for review. A new submission replaces one still waiting for review.
+func (s *InvoiceCollectionsService) SubmitPayment(ctx context.Context, tenantID, advertiserID, invoiceID uuid.UUID, payment InvoicePayment) (*models.Invoice, error) {
+	invoice, err := s.find(s.db, tenantID, invoiceID, &advertiserID)
+	if err != nil {
+		return nil, err
+	}
+	if invoice.Status == models.InvoiceStatusPaid || invoice.Status == models.InvoiceStatusCancelled {
+		return nil, ErrInvoiceNotPayable
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":         models.InvoiceStatusPendingConfirmation,
+		"payment_proof":  payment.ProofURL,
+		"payment_method": payment.Method,
+		"payment_note":   payment.Note,
+		"paid_at":        now,
+		"receipt_key":    "",
+		"receipt_name":   "",
+		"receipt_type":   "",
+		"receipt_size":   0,
+		"updated_at":     now,
+	}
+	if len(payment.Receipt) > 0 {
+		if s.storage == nil {
+			return nil, ErrReceiptStorageUnavailable
+		}
+		contentType, ext, err := ValidateReceipt(payment.Receipt, s.maxReceiptBytes)
+		if err != nil {
+			return nil, err
+		}
+		key := ReceiptKey(tenantID, invoiceID, ext)
+		if err := s.storage.Put(ctx, key, contentType, payment.Receipt); err != nil {
+			return nil, fmt.Errorf("failed to store receipt: %w", err)
+		}
+		updates["receipt_key"] = key
+		updates["receipt_name"] = truncate(payment.ReceiptName, 255)
+		updates["receipt_type"] = contentType
+		updates["receipt_size"] = int64(len(payment.Receipt))
+	}
+
+	// The status guard keeps a payment confirmed meanwhile from being reopened
+	result := s.db.Model(&models.Invoice{}).
+		Where("id = ? AND status NOT IN ?", invoice.ID, []string{models.InvoiceStatusPaid, models.InvoiceStatusCancelled}).
+		Updates(updates)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to update invoice: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvoiceNotPayable
+	}
+	return s.find(s.db, tenantID, invoiceID, nil)
+}
+
+// Receipt returns an invoice's uploaded receipt. advertiserID limits the
+// lookup to that advertiser's invoices.
+func (s *InvoiceCollectionsService) Receipt(ctx context.Context, tenantID, invoiceID uuid.UUID, advertiserID *uuid.UUID) (*models.Invoice, []byte, error) {
+	invoice, err := s.find(s.db, tenantID, invoiceID, advertiserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if invoice.ReceiptKey == "" {
+		return nil, nil, ErrReceiptNotFound
+	}
+	if s.storage == nil {
+		return nil, nil, ErrReceiptStorageUnavailable
+	}
+	data, err := s.storage.Get(ctx, invoice.ReceiptKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return invoice, data, nil
+}
+
+// ConfirmPayment marks an invoice paid, settles the advertiser's receivable
+// in the ledger and resumes offers paused for it
+func (s *InvoiceCollectionsService) ConfirmPayment(tenantID, invoiceID uuid.UUID, reviewer *uuid.UUID, note string) (*models.Invoice, error) {
+	var invoice *models.Invoice
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		invoice, err = s.find(tx.Clauses(clause.Locking{Strength: "UPDATE"}), tenantID, invoiceID, nil)
+		if err != nil {
+			return err
+		}
+		if invoice.Status == models.InvoiceStatusPaid || invoice.Status == models.InvoiceStatusCancelled {
+			return ErrInvoiceNotPayable
+		}
+
+		now := time.Now()
+		invoice.Status = models.InvoiceStatusPaid
+		invoice.ReviewedBy = reviewer
+		invoice.ReviewedAt = &now
+		invoice.ReviewNote = note
+		if invoice.PaidAt == nil {
+			invoice.PaidAt = &now
+		}
+		if err := tx.Save(invoice).Error; err != nil {
+			return err
+		}
+		if s.ledger != nil {
+			if _, err := s.ledger.PostInvoicePaid(tx, invoice, reviewer); err != nil {
+				return err
+			}
+		}
+		return s.resumeOffers(tx, invoice)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// RejectPayment sends a submitted payment back to the advertiser. The invoice
+// is open again: pending, or overdue once past its due date.
+func (s *InvoiceCollectionsService) RejectPayment(tenantID, invoiceID uuid.UUID, reviewer *uuid.UUID, note string) (*models.Invoice, error) {
+	invoice, err := s.find(s.db, tenantID, invoiceID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if invoice.Status != models.InvoiceStatusPendingConfirmation {
+		return nil, ErrInvoiceNotAwaitingReview
+	}
+
+	now := time.Now()
+	status := models.InvoiceStatusPending
+	if now.After(invoice.DueDate) {
+		status = models.InvoiceStatusOverdue
+	}
+	result := s.db.Model(&models.Invoice{}).
+		Where("id = ? AND status = ?", invoice.ID, models.InvoiceStatusPendingConfirmation).
+		Updates(map[string]interface{}{
+			"status":        status,
+			"payment_proof": "",
+			"paid_at":       nil,
+			"receipt_key":   "",
+			"receipt_name":  "",
+			"receipt_type":  "",
+			"receipt_size":  0,
+			"reviewed_by":   reviewer,
+			"reviewed_at":   now,
+			"review_note":   note,
+			"updated_at":    now,
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to update invoice: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvoiceNotAwaitingReview
+	}
+
+	if invoice, err = s.find(s.db, tenantID, invoiceID, nil); err != nil {
+		return nil, err
+	}
+	s.notify(invoice, fmt.Sprintf("Payment for invoice %s was not accepted", invoiceLabel(invoice)),
+		fmt.Sprintf("We could not confirm your payment for invoice %s: %s\nPlease check the payment and submit it again.", invoiceLabel(invoice), note))
+	return invoice, nil
+}
+
+// ============================================
+// COLLECTIONS JOB
+// ============================================
+
+// InvoiceCollectionsReport summarises one run of the collections job
+type InvoiceCollectionsReport struct {
+	Overdue   int64 `json:"overdue"`   // invoices moved to overdue
+	Reminders int   `json:"reminders"` // reminder emails sent
+	Paused    int   `json:"paused"`    // invoices whose advertiser's offers were paused
+}
+
+// RunCollections moves unpaid invoices past their due date to overdue, sends
+// the reminders that are due and pauses offers of advertisers whose invoices
+// are overdue past the tenant's limit
+func (s *InvoiceCollectionsService) RunCollections(now time.Time) (*InvoiceCollectionsReport, error) {
+	report := &InvoiceCollectionsReport{}
+	result := s.db.Model(&models.Invoice{}).
+		Where("status = ? AND due_date < ?", models.InvoiceStatusPending, now).
+		Updates(map[string]interface{}{"status": models.InvoiceStatusOverdue, "updated_at": now})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to mark overdue invoices: %w", result.Error)
+	}
+	report.Overdue = result.RowsAffected
+
+	// Reminders may start before the due date
+	var invoices []models.Invoice
+	err := s.db.Preload("Advertiser").
+		Where("status IN ? AND due_date <= ?", unpaidInvoiceStatuses, now.AddDate(0, 0, MaxInvoiceReminderLeadDays)).
+		FindInBatches(&invoices, invoiceCollectionsBatch, func(tx *gorm.DB, batch int) error {
+			for i := range invoices {
+				s.collect(&invoices[i], now, report)
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return report, fmt.Errorf("failed to load unpaid invoices: %w", err)
+	}
+	return report, nil
+}
+
+// collect sends an invoice's due reminder and pauses its advertiser's offers
+// when due. Each step is recorded before its email goes out, so an email is
+// never sent twice.
+func (s *InvoiceCollectionsService) collect(invoice *models.Invoice, now time.Time, report *InvoiceCollectionsReport) {
+	settings := s.settings.Get(invoice.TenantID)
+	label := invoiceLabel(invoice)
+
+	if offset, ok := InvoiceReminderDue(settings.InvoiceReminderDays, invoice.DueDate, invoice.LastReminderOffset, now); ok {
+		// Only the run that moves the offset forward sends the email; the
+		// job runs on every replica
+		result := s.db.Model(&models.Invoice{}).
+			Where("id = ? AND status IN ?", invoice.ID, unpaidInvoiceStatuses).
+			Where("last_reminder_offset IS NULL OR last_reminder_offset < ?", offset).
+			Updates(map[string]interface{}{"last_reminder_offset": offset, "last_reminder_at": now})
+		if result.Error != nil {
+			log.Printf("[Invoices] failed to record reminder for %s: %v", invoice.ID, result.Error)
+		} else if result.RowsAffected > 0 {
+			due, verb := invoice.DueDate.In(TenantLocation(settings.Timezone)).Format("2006-01-02"), "is"
+			if offset > 0 {
+				verb = "was"
+			}
+			s.notify(invoice, InvoiceReminderSubject(label, offset),
+				fmt.Sprintf("Invoice %s (%s %s) %s due on %s. Please pay it and upload the receipt in your dashboard.",
+					label, FormatInvoiceAmount(invoice.AmountDue(), invoice.Currency), invoice.Currency, verb, due))
+			report.Reminders++
+		}
+	}
+
+	if invoice.Status != models.InvoiceStatusOverdue || invoice.OffersPausedAt != nil ||
+		!InvoicePauseDue(settings.InvoicePauseAfterDays, invoice.DueDate, now) {
+		return
+	}
+	var paused int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Invoice{}).
+			Where("id = ? AND status = ? AND offers_paused_at IS NULL", invoice.ID, models.InvoiceStatusOverdue).
+			Update("offers_paused_at", now)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		result = tx.Model(&models.Offer{}).
+			Where("tenant_id = ? AND advertiser_id = ? AND status = ?", invoice.TenantID, invoice.AdvertiserID, "active").
+			Updates(map[string]interface{}{"status": "paused", "paused_reason": models.OfferPausedInvoiceOverdue, "updated_at": now})
+		paused = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		log.Printf("[Invoices] failed to pause offers for invoice %s: %v", invoice.ID, err)
+		return
+	}
+	report.Paused++
+	log.Printf("[Invoices] invoice %s overdue: %d offers of advertiser %s paused", label, paused, invoice.AdvertiserID)
+	s.notify(invoice, fmt.Sprintf("Your offers were paused: invoice %s is overdue", label),
+		fmt.Sprintf("Invoice %s is %d days overdue, so your offers were paused. They resume as soon as the payment is confirmed.",
+			label, settings.InvoicePauseAfterDays))
+}
+
+// resumeOffers reactivates the offers paused for an overdue invoice once the
+// advertiser has no other invoice holding them paused
+func (s *InvoiceCollectionsService) resumeOffers(tx *gorm.DB, invoice *models.Invoice) error {
+	if invoice.OffersPausedAt == nil {
+		return nil
+	}
+	var holding int64
+	if err := tx.Model(&models.Invoice{}).
+		Where("tenant_id = ? AND advertiser_id = ? AND id <> ? AND status = ? AND offers_paused_at IS NOT NULL",
+			invoice.TenantID, invoice.AdvertiserID, invoice.ID, models.InvoiceStatusOverdue).
+		Count(&holding).Error; err != nil {
+		return err
+	}
+	if holding > 0 {
+		return nil
+	}
+	result := tx.Model(&models.Offer{}).
+		Where("tenant_id = ? AND advertiser_id = ? AND status = ? AND paused_reason = ?",
+			invoice.TenantID, invoice.AdvertiserID, "paused", models.OfferPausedInvoiceOverdue).
+		Updates(map[string]interface{}{"status": "active", "paused_reason": "", "updated_at": time.Now()})
+	if result.Error != nil {
+		return fmt.Errorf("failed to resume offers: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("[Invoices] invoice %s paid: %d offers of advertiser %s resumed", invoiceLabel(invoice), result.RowsAffected, invoice.AdvertiserID)
+	}
+	return nil
+}
+
+// notify emails an invoice's advertiser
+func (s *InvoiceCollectionsService) notify(invoice *models.Invoice, subject, text string) {
+	if s.email == nil {
+		return
+	}
+	to := ""
+	if invoice.Advertiser != nil {
+		to = invoice.Advertiser.Email
+	} else {
+		var advertiser models.AfftokUser
+		if err := s.db.Select("id, email").First(&advertiser, "id = ?", invoice.AdvertiserID).Error; err == nil {
+			to = advertiser.Email
+		}
+	}
+	if to == "" {
+		return
+	}
+	if err := s.email.Send(EmailMessage{To: to, Subject: subject, Text: text}); err != nil {
+		log.Printf("[Invoices] failed to email %s: %v", to, err)
+	}
+}
+
+// Start runs the collections job in the background
+func (s *InvoiceCollectionsService) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return
+	}
+	s.running = true
+	s.stop = make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(InvoiceCollectionsInterval)
+		defer ticker.Stop()
+		for {
+			if report, err := s.RunCollections(time.Now()); err != nil {
+				log.Printf("[Invoices] collections run failed: %v", err)
+			} else if report.Overdue > 0 || report.Reminders > 0 || report.Paused > 0 {
+				log.Printf("[Invoices] collections: %d overdue, %d reminders, %d paused", report.Overdue, report.Reminders, report.Paused)
+			}
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the collections job
+func (s *InvoiceCollectionsService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		close(s.stop)
+		s.running = false
+	}
+}
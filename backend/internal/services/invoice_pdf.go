@@ -0,0 +1,227 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+)
+
+// ============================================
+// INVOICE PDF
+// ============================================
+
+// invoiceLabels are the English and Arabic captions of the invoice PDF
+var invoiceLabels = map[string][2]string{
+	"title":          {"INVOICE", "فاتورة"},
+	"number":         {"Invoice No.", "رقم الفاتورة"},
+	"issued":         {"Issue date", "تاريخ الإصدار"},
+	"period":         {"Billing period", "فترة الفوترة"},
+	"due":            {"Due date", "تاريخ الاستحقاق"},
+	"status":         {"Status", "الحالة"},
+	"bill_to":        {"Bill to", "فاتورة إلى"},
+	"description":    {"Description", "البيان"},
+	"conversions":    {"Conversions", "التحويلات"},
+	"payout":         {"Promoter payout", "عمولات المسوقين"},
+	"fee":            {"Platform fee", "رسوم المنصة"},
+	"adjustments":    {"Adjustments", "التسويات"},
+	"subtotal":       {"Subtotal", "المجموع الفرعي"},
+	"tax":            {"Tax", "الضريبة"},
+	"vat":            {"VAT", "ضريبة القيمة المضافة"},
+	"total":          {"Total due", "المبلغ المستحق"},
+	"carry_over":     {"Credit carried over to the next invoice", "رصيد دائن مرحل إلى الفاتورة التالية"},
+	"adjustment":     {"Adjustment", "تسوية"},
+	"reverse_charge": {"Reverse charge: VAT to be accounted for by the recipient", "احتساب عكسي: الضريبة مستحقة على المستلم"},
+	"currency":       {"All amounts in", "جميع المبالغ بعملة"},
+}
+
+// invoiceStatusLabels translate invoice statuses
+var invoiceStatusLabels = map[string][2]string{
+	"pending":              {"Pending", "مستحقة"},
+	"pending_confirmation": {"Awaiting confirmation", "بانتظار التأكيد"},
+	"paid":                 {"Paid", "مدفوعة"},
+	"overdue":              {"Overdue", "متأخرة"},
+	"cancelled":            {"Cancelled", "ملغاة"},
+}
+
+// InvoiceDocument is what an invoice PDF shows
+type InvoiceDocument struct {
+	Invoice       *models.Invoice
+	Items         []models.InvoiceItem
+	OfferTitlesAr map[uuid.UUID]string
+	IssuerName    string
+}
+
+// FormatInvoiceAmount formats a major-unit amount with the currency's
+// decimals and thousands separators, e.g. "1,234.500"
+func FormatInvoiceAmount(amount float64, currency string) string {
+	s := strconv.FormatFloat(amount, 'f', CurrencyMinorUnits(currency), 64)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i:]
+	}
+	var b strings.Builder
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	return sign + b.String() + frac
+}
+
+// invoicePDF lays an invoice out on a bilingual document
+type invoicePDF struct {
+	*bilingualPDF
+}
+
+const (
+	colConv   = 330.0
+	colPayout = 440.0
+)
+
+// RenderInvoicePDF renders an invoice as a bilingual A4 PDF
+func RenderInvoicePDF(d InvoiceDocument) ([]byte, error) {
+	if d.Invoice == nil {
+		return nil, ErrInvoiceNotFound
+	}
+	p := invoicePDF{newBilingualPDF()}
+	inv := d.Invoice
+	currency := inv.Currency
+	p.header(d.IssuerName, invoiceLabels["title"])
+
+	// Invoice details
+	number := inv.Number
+	if number == "" {
+		number = fmt.Sprintf("%d-%02d", inv.Year, inv.Month)
+	}
+	issued := inv.CreatedAt
+	if inv.IssuedAt != nil {
+		issued = *inv.IssuedAt
+	}
+	status := invoiceStatusLabels[inv.Status]
+	if status[0] == "" {
+		status = [2]string{inv.Status, ""}
+	}
+	p.detail(invoiceLabels["number"], number)
+	p.detail(invoiceLabels["issued"], issued.Format("2006-01-02"))
+	p.detail(invoiceLabels["period"], inv.PeriodStart.Format("2006-01-02")+" - "+inv.PeriodEnd.Format("2006-01-02"))
+	p.detail(invoiceLabels["due"], inv.DueDate.Format("2006-01-02"))
+	p.detail(invoiceLabels["status"], status[0]+"  "+status[1])
+
+	// Bill to
+	p.y -= 12
+	p.caption(invoiceLabels["bill_to"])
+	if advertiser := inv.Advertiser; advertiser != nil {
+		for _, line := range []string{advertiser.CompanyName, advertiser.FullName, advertiser.Email, advertiser.Country} {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			p.page.Text(p.regular, 10, pdfMarginLeft, p.y, line, pdfAlignLeft)
+			p.y -= 14
+		}
+	}
+
+	// Lines
+	p.y -= 14
+	p.tableHeader(invoiceColumns)
+	for _, item := range d.Items {
+		p.item(item, d.OfferTitlesAr, currency)
+	}
+
+	// Totals
+	p.y -= 8
+	p.ensure(6 * 18)
+	p.total("fee", inv.PlatformAmount, currency, false)
+	if inv.AdjustmentAmount != 0 {
+		p.total("adjustments", inv.AdjustmentAmount, currency, false)
+	}
+	p.total("subtotal", inv.Subtotal, currency, false)
+	if inv.TaxName != "" || inv.TaxAmount != 0 {
+		rate := strconv.FormatFloat(inv.TaxRate*100, 'f', -1, 64) + "%"
+		label := invoiceLabels["tax"]
+		if strings.EqualFold(inv.TaxName, "VAT") {
+			label = invoiceLabels["vat"]
+		}
+		english := strings.TrimSpace(inv.TaxName + " (" + rate + ")")
+		if inv.TaxName == "" {
+			english = label[0] + " (" + rate + ")"
+		}
+		p.totalLabels(english, label[1]+" "+rate, inv.TaxAmount, currency, false)
+	}
+	p.total("total", inv.AmountDue(), currency, true)
+
+	if inv.TaxReverseCharge {
+		p.y -= 10
+		p.note(invoiceLabels["reverse_charge"])
+	}
+
+	currencyNote := invoiceLabels["currency"]
+	return p.finish([2]string{currencyNote[0] + " " + currency, currencyNote[1] + " " + currency}, number)
+}
+
+// invoiceColumns are the columns of the line items table
+var invoiceColumns = []pdfColumn{
+	{Label: invoiceLabels["description"], X: pdfMarginLeft + 6, Align: pdfAlignLeft, ArabicX: colConv - 70},
+	{Label: invoiceLabels["conversions"], X: colConv, Align: pdfAlignRight},
+	{Label: invoiceLabels["payout"], X: colPayout, Align: pdfAlignRight},
+	{Label: invoiceLabels["fee"], X: pdfMarginRight - 6, Align: pdfAlignRight},
+}
+
+func (p *invoicePDF) item(item models.InvoiceItem, titlesAr map[uuid.UUID]string, currency string) {
+	english, arabic := item.Description, ""
+	switch item.Kind {
+	case models.InvoiceItemCarryOver:
+		english, arabic = invoiceLabels["carry_over"][0], invoiceLabels["carry_over"][1]
+	case models.InvoiceItemAdjustment:
+		if containsArabic(english) {
+			english, arabic = invoiceLabels["adjustment"][0], english
+		} else {
+			arabic = invoiceLabels["adjustment"][1]
+		}
+	default:
+		if english == "" {
+			english = item.OfferTitle
+		}
+		if item.OfferID != nil {
+			arabic = titlesAr[*item.OfferID]
+		}
+	}
+	english = p.fit(english, colConv-80-pdfMarginLeft)
+
+	height := 16.0
+	if arabic != "" {
+		height = 27
+	}
+	if p.ensure(height + 4) {
+		p.tableHeader(invoiceColumns)
+	}
+	p.page.Text(p.regular, 9, pdfMarginLeft+6, p.y, english, pdfAlignLeft)
+	if item.Kind == models.InvoiceItemConversions {
+		p.page.Text(p.regular, 9, colConv, p.y, strconv.Itoa(item.Conversions), pdfAlignRight)
+		p.page.Text(p.regular, 9, colPayout, p.y, FormatInvoiceAmount(item.PromoterPayout, currency), pdfAlignRight)
+	}
+	p.page.Text(p.regular, 9, pdfMarginRight-6, p.y, FormatInvoiceAmount(item.PlatformAmount, currency), pdfAlignRight)
+	if arabic != "" {
+		p.page.Gray(0.4)
+		p.page.Text(p.regular, 8, colConv-70, p.y-11, arabic, pdfAlignRight)
+		p.page.Gray(0)
+	}
+	p.page.Line(pdfMarginLeft, p.y-height+10, pdfMarginRight, p.y-height+10, 0.5, 0.85)
+	p.y -= height
+}
+
+func (p *invoicePDF) total(key string, amount float64, currency string, strong bool) {
+	label := invoiceLabels[key]
+	p.totalLabels(label[0], label[1], amount, currency, strong)
+}
+
+func (p *invoicePDF) totalLabels(english, arabic string, amount float64, currency string, strong bool) {
+	p.amountRow(english, arabic, FormatInvoiceAmount(amount, currency)+" "+currency, strong)
+}
@@ -0,0 +1,779 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ============================================
+// ADVERTISER INVOICE ENGINE
+// ============================================
+// Monthly invoices are built from the ledger: every conversion approved or
+// reversed in the period (by approval/reversal time) becomes part of an
+// offer × goal line, unbilled adjustments are added, tax is applied by the
+// advertiser's country and the invoice gets the tenant's next number.
+// One invoice exists per advertiser, period and currency, so re-running a
+// period only creates the invoices still missing.
+
+// InvoiceDueDays is how long after the period end an invoice is due
+const InvoiceDueDays = 7
+
+// Invoice errors
+var (
+	ErrInvoiceNotFound          = errors.New("invoice not found")
+	ErrInvoiceAdjustmentInvalid = errors.New("invalid invoice adjustment")
+	ErrTaxRuleInvalid           = errors.New("invalid tax rule")
+)
+
+// InvoiceService generates advertiser invoices
+type InvoiceService struct {
+	db      *gorm.DB
+	ledger  *LedgerService
+	fx      *FXService
+	wallets *AdvertiserWalletService
+}
+
+var (
+	invoiceServiceInstance *InvoiceService
+	invoiceServiceOnce     sync.Once
+)
+
+// GetInvoiceService returns the singleton invoice service
+func GetInvoiceService(db *gorm.DB) *InvoiceService {
+	invoiceServiceOnce.Do(func() {
+		invoiceServiceInstance = NewInvoiceService(db)
+		invoiceServiceInstance.SetLedgerService(GetLedgerService(db))
+		invoiceServiceInstance.SetFXService(GetFXService(db))
+		invoiceServiceInstance.SetWalletService(GetAdvertiserWalletService(db))
+	})
+	return invoiceServiceInstance
+}
+
+// NewInvoiceService creates a new invoice service
+func NewInvoiceService(db *gorm.DB) *InvoiceService {
+	return &InvoiceService{db: db}
+}
+
+// SetLedgerService sets the ledger invoices are built from
+func (s *InvoiceService) SetLedgerService(ledger *LedgerService) {
+	s.ledger = ledger
+}
+
+// SetFXService sets the FX service used for reporting-currency totals
+func (s *InvoiceService) SetFXService(fx *FXService) {
+	s.fx = fx
+}
+
+// SetWalletService sets the prepaid wallets that pay invoices of prepaid advertisers
+func (s *InvoiceService) SetWalletService(wallets *AdvertiserWalletService) {
+	s.wallets = wallets
+}
+
+// ============================================
+// INVOICE MATH
+// ============================================
+
+// InvoiceLineRow is one conversion transaction of a period with its offer and goal
+type InvoiceLineRow struct {
+	AdvertiserID uuid.UUID
+	OfferID      *uuid.UUID
+	OfferTitle   string
+	OfferTitleAr string
+	Goal         string
+	Kind         models.LedgerTransactionKind
+	Currency     string
+	Commission   int64
+	PlatformFee  int64
+}
+
+// InvoiceLine is one offer × goal line of an invoice, in minor units
+type InvoiceLine struct {
+	OfferID      *uuid.UUID `json:"offer_id,omitempty"`
+	OfferTitle   string     `json:"offer_title"`
+	OfferTitleAr string     `json:"offer_title_ar,omitempty"`
+	Goal         string     `json:"goal,omitempty"`
+	Conversions  int        `json:"conversions"` // approvals minus reversals
+	Commission   int64      `json:"commission"`
+	PlatformFee  int64      `json:"platform_fee"`
+}
+
+// InvoiceDraft is an advertiser's invoice for one currency before it is
+// numbered. Amounts are minor units.
+type InvoiceDraft struct {
+	AdvertiserID uuid.UUID                  `json:"advertiser_id"`
+	Currency     string                     `json:"currency"`
+	Lines        []InvoiceLine              `json:"lines"`
+	Adjustments  []models.InvoiceAdjustment `json:"adjustments"`
+
+	Conversions int   `json:"conversions"`
+	Commission  int64 `json:"commission"`
+	PlatformFee int64 `json:"platform_fee"`
+	Adjustment  int64 `json:"adjustment"`
+	CarryOver   int64 `json:"carry_over"` // credit moved to the next invoice
+	Subtotal    int64 `json:"subtotal"`
+	Tax         int64 `json:"tax"`
+	Total       int64 `json:"total"`
+
+	TaxRule *models.TaxRule `json:"tax_rule,omitempty"`
+}
+
+// BuildInvoiceDrafts groups a period's conversion transactions into one
+// draft per advertiser and currency with a line per offer and goal
+func BuildInvoiceDrafts(rows []InvoiceLineRow) []*InvoiceDraft {
+	type lineKey struct {
+		offer uuid.UUID
+		goal  string
+	}
+	drafts := make(map[string]*InvoiceDraft)
+	lines := make(map[string]map[lineKey]int)
+	var order []string
+
+	for _, row := range rows {
+		dk := row.AdvertiserID.String() + "|" + row.Currency
+		draft, ok := drafts[dk]
+		if !ok {
+			draft = &InvoiceDraft{AdvertiserID: row.AdvertiserID, Currency: row.Currency}
+			drafts[dk] = draft
+			lines[dk] = make(map[lineKey]int)
+			order = append(order, dk)
+		}
+		lk := lineKey{derefUUID(row.OfferID), row.Goal}
+		i, ok := lines[dk][lk]
+		if !ok {
+			i = len(draft.Lines)
+			lines[dk][lk] = i
+			draft.Lines = append(draft.Lines, InvoiceLine{
+				OfferID:      row.OfferID,
+				OfferTitle:   row.OfferTitle,
+				OfferTitleAr: row.OfferTitleAr,
+				Goal:         row.Goal,
+			})
+		}
+		line := &draft.Lines[i]
+		line.Commission += row.Commission
+		line.PlatformFee += row.PlatformFee
+		switch row.Kind {
+		case models.LedgerKindConversionApproved:
+			line.Conversions++
+		case models.LedgerKindConversionReversed:
+			line.Conversions--
+		}
+	}
+
+	result := make([]*InvoiceDraft, 0, len(order))
+	for _, dk := range order {
+		draft := drafts[dk]
+		sort.SliceStable(draft.Lines, func(i, j int) bool {
+			a, b := draft.Lines[i], draft.Lines[j]
+			if a.OfferTitle != b.OfferTitle {
+				return a.OfferTitle < b.OfferTitle
+			}
+			return a.Goal < b.Goal
+		})
+		for _, line := range draft.Lines {
+			draft.Conversions += line.Conversions
+			draft.Commission += line.Commission
+			draft.PlatformFee += line.PlatformFee
+		}
+		result = append(result, draft)
+	}
+	return result
+}
+
+// Billable reports whether the draft has anything to invoice
+func (d *InvoiceDraft) Billable() bool {
+	return d.PlatformFee != 0 || len(d.Adjustments) > 0
+}
+
+// Finalize computes subtotal, tax and total. A period that nets to a credit
+// is invoiced at zero and the credit carries over to the next invoice.
+func (d *InvoiceDraft) Finalize(rule *models.TaxRule) {
+	d.Adjustment = 0
+	for _, a := range d.Adjustments {
+		d.Adjustment += a.Amount
+	}
+	d.CarryOver = 0
+	if net := d.PlatformFee + d.Adjustment; net < 0 {
+		d.CarryOver = -net
+	}
+	d.Subtotal = d.PlatformFee + d.Adjustment + d.CarryOver
+
+	d.TaxRule = rule
+	d.Tax = 0
+	if rule != nil && !rule.ReverseCharge {
+		d.Tax = TaxAmount(d.Subtotal, rule.RateBps)
+	}
+	d.Total = d.Subtotal + d.Tax
+}
+
+// TaxAmount returns rateBps of base, rounded half up
+func TaxAmount(base, rateBps int64) int64 {
+	if base <= 0 || rateBps <= 0 {
+		return 0
+	}
+	return (base*rateBps + 5000) / 10000
+}
+
+// NormalizeTaxCountry upper-cases a country as stored on an advertiser
+func NormalizeTaxCountry(country string) string {
+	return strings.ToUpper(strings.TrimSpace(country))
+}
+
+// MatchTaxRule picks the active rule for an advertiser's country: the
+// tenant's own rules win over platform rules, an exact country over "*"
+func MatchTaxRule(rules []models.TaxRule, tenantID uuid.UUID, country string) *models.TaxRule {
+	country = NormalizeTaxCountry(country)
+	for _, owner := range []uuid.UUID{tenantID, models.DefaultTenantID} {
+		for _, want := range []string{country, "*"} {
+			if want == "" {
+				continue
+			}
+			for i := range rules {
+				r := &rules[i]
+				if r.Active && r.TenantID == owner && NormalizeTaxCountry(r.Country) == want {
+					return r
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// FormatInvoiceNumber formats a tenant's invoice sequence number
+func FormatInvoiceNumber(n int64) string {
+	return fmt.Sprintf("INV-%06d", n)
+}
+
+// ============================================
+// GENERATION
+// ============================================
+
+// InvoiceRunReport summarizes a monthly invoice run
+type InvoiceRunReport struct {
+	Year     int              `json:"year"`
+	Month    int              `json:"month"`
+	Created  int              `json:"created_count"`
+	Skipped  int              `json:"skipped_count"`
+	Failed   int              `json:"failed_count"`
+	Invoices []models.Invoice `json:"invoices"`
+	Errors   []string         `json:"errors,omitempty"`
+}
+
+// GenerateMonthly creates the missing invoices of a tenant's billing month.
+// The month is taken in loc (the tenant's timezone).
+func (s *InvoiceService) GenerateMonthly(tenantID uuid.UUID, year, month int, loc *time.Location) (*InvoiceRunReport, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	periodStart := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, loc)
+	periodEnd := periodStart.AddDate(0, 1, 0)
+	report := &InvoiceRunReport{Year: year, Month: month, Invoices: []models.Invoice{}}
+
+	rows, err := s.periodLines(tenantID, periodStart, periodEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ledger: %w", err)
+	}
+	drafts := BuildInvoiceDrafts(rows)
+
+	// Adjustments created up to the period end that no invoice billed yet
+	var adjustments []models.InvoiceAdjustment
+	if err := s.db.Where("tenant_id = ? AND invoice_id IS NULL AND created_at < ?", tenantID, periodEnd).
+		Order("created_at").Find(&adjustments).Error; err != nil {
+		return nil, fmt.Errorf("failed to load adjustments: %w", err)
+	}
+	drafts = attachAdjustments(drafts, adjustments)
+
+	// Reporting-currency amounts use the posting-time FX snapshots
+	totals, err := s.ledger.ConversionTotals(&tenantID, periodStart, periodEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ledger: %w", err)
+	}
+	reporting := make(map[string]*LedgerPeriodTotal)
+	for _, total := range totals {
+		if total.AdvertiserID == nil {
+			continue
+		}
+		key := total.AdvertiserID.String() + "|" + total.Currency
+		agg, ok := reporting[key]
+		if !ok {
+			agg = &LedgerPeriodTotal{AdvertiserID: total.AdvertiserID, Currency: total.Currency}
+			reporting[key] = agg
+		}
+		agg.Merge(total)
+	}
+
+	var rules []models.TaxRule
+	if err := s.db.Where("tenant_id IN ? AND active = ?", []uuid.UUID{tenantID, models.DefaultTenantID}, true).
+		Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to load tax rules: %w", err)
+	}
+
+	advertisers := make(map[uuid.UUID]*models.AfftokUser)
+	for _, draft := range drafts {
+		if draft.AdvertiserID == uuid.Nil || advertisers[draft.AdvertiserID] != nil {
+			continue
+		}
+		var advertiser models.AfftokUser
+		if err := s.db.Where("tenant_id = ? AND id = ? AND role = ?", tenantID, draft.AdvertiserID, "advertiser").
+			First(&advertiser).Error; err == nil {
+			advertisers[advertiser.ID] = &advertiser
+		}
+	}
+
+	for _, draft := range drafts {
+		advertiser := advertisers[draft.AdvertiserID]
+		if advertiser == nil || !draft.Billable() {
+			report.Skipped++
+			continue
+		}
+		draft.Finalize(MatchTaxRule(rules, tenantID, advertiser.Country))
+
+		invoice, err := s.issue(tenantID, advertiser, draft, periodStart, periodEnd, reporting[draft.AdvertiserID.String()+"|"+draft.Currency])
+		if err != nil {
+			log.Printf("[Invoices] %d-%02d advertiser %s (%s) failed: %v", year, month, draft.AdvertiserID, draft.Currency, err)
+			report.Failed++
+			report.Errors = append(report.Errors, fmt.Sprintf("%s %s: %v", draft.AdvertiserID, draft.Currency, err))
+			continue
+		}
+		if invoice == nil {
+			report.Skipped++ // already invoiced
+			continue
+		}
+		report.Created++
+		report.Invoices = append(report.Invoices, *invoice)
+	}
+	return report, nil
+}
+
+// periodLines reads the period's conversion transactions with offer and goal
+func (s *InvoiceService) periodLines(tenantID uuid.UUID, from, to time.Time) ([]InvoiceLineRow, error) {
+	var rows []InvoiceLineRow
+	err := s.db.Table("ledger_transactions t").
+		Select(`t.advertiser_id, t.offer_id, COALESCE(o.title, '') AS offer_title, COALESCE(o.title_ar, '') AS offer_title_ar,
+			COALESCE(c.goal, '') AS goal, t.kind, e.currency,
+			COALESCE(SUM(CASE WHEN a.type = ? THEN -e.amount ELSE 0 END), 0) AS commission,
+			COALESCE(SUM(CASE WHEN a.type = ? THEN -e.amount ELSE 0 END), 0) AS platform_fee`,
+			models.LedgerAccountPromoterPayable, models.LedgerAccountPlatformRevenue).
+		Joins("JOIN ledger_entries e ON e.transaction_id = t.id").
+		Joins("JOIN ledger_accounts a ON a.id = e.account_id").
+		Joins("LEFT JOIN offers o ON o.id = t.offer_id").
+		Joins("LEFT JOIN conversions c ON c.id = t.reference_id AND t.reference_type = ?", LedgerReferenceConversion).
+		Where("t.tenant_id = ?", tenantID).
+		Where("t.kind IN ?", []models.LedgerTransactionKind{models.LedgerKindConversionApproved, models.LedgerKindConversionReversed}).
+		Where("t.advertiser_id IS NOT NULL").
+		Where("t.occurred_at >= ? AND t.occurred_at < ?", from, to).
+		Group("t.id, t.advertiser_id, t.offer_id, o.title, o.title_ar, c.goal, t.kind, e.currency").
+		Order("t.advertiser_id, e.currency, t.occurred_at").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// attachAdjustments adds adjustments to the matching drafts, creating drafts
+// for advertisers that only have adjustments
+func attachAdjustments(drafts []*InvoiceDraft, adjustments []models.InvoiceAdjustment) []*InvoiceDraft {
+	index := make(map[string]*InvoiceDraft, len(drafts))
+	for _, d := range drafts {
+		index[d.AdvertiserID.String()+"|"+d.Currency] = d
+	}
+	for _, a := range adjustments {
+		key := a.AdvertiserID.String() + "|" + a.Currency
+		d, ok := index[key]
+		if !ok {
+			d = &InvoiceDraft{AdvertiserID: a.AdvertiserID, Currency: a.Currency}
+			index[key] = d
+			drafts = append(drafts, d)
+		}
+		d.Adjustments = append(d.Adjustments, a)
+	}
+	return drafts
+}
+
+// issue numbers and stores one invoice. Returns nil when the advertiser is
+// already invoiced for the period and currency.
+func (s *InvoiceService) issue(tenantID uuid.UUID, advertiser *models.AfftokUser, draft *InvoiceDraft, periodStart, periodEnd time.Time, reporting *LedgerPeriodTotal) (*models.Invoice, error) {
+	var invoice *models.Invoice
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Runs of the same tenant serialize on its sequence row, so the
+		// existence check below cannot race
+		seq, err := s.lockSequence(tx, tenantID)
+		if err != nil {
+			return err
+		}
+		var existing int64
+		if err := tx.Model(&models.Invoice{}).
+			Where("tenant_id = ? AND advertiser_id = ? AND year = ? AND month = ? AND currency = ?",
+				tenantID, draft.AdvertiserID, periodStart.Year(), int(periodStart.Month()), draft.Currency).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return nil
+		}
+
+		// Only adjustments still unbilled once locked
+		if len(draft.Adjustments) > 0 {
+			ids := make([]uuid.UUID, len(draft.Adjustments))
+			for i, a := range draft.Adjustments {
+				ids[i] = a.ID
+			}
+			var locked []models.InvoiceAdjustment
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("id IN ? AND invoice_id IS NULL", ids).Order("created_at").Find(&locked).Error; err != nil {
+				return err
+			}
+			draft.Adjustments = locked
+			draft.Finalize(draft.TaxRule)
+			if !draft.Billable() {
+				return nil
+			}
+		}
+
+		seq.LastNumber++
+		if err := tx.Model(&models.InvoiceSequence{}).Where("tenant_id = ?", tenantID).
+			Updates(map[string]interface{}{"last_number": seq.LastNumber, "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+
+		invoice = s.buildInvoice(tenantID, advertiser, draft, periodStart, periodEnd, reporting)
+		invoice.Number = FormatInvoiceNumber(seq.LastNumber)
+		if err := tx.Create(invoice).Error; err != nil {
+			return fmt.Errorf("failed to create invoice: %w", err)
+		}
+		if err := s.createItems(tx, invoice, draft); err != nil {
+			return err
+		}
+		if _, err := s.ledger.PostInvoiceIssued(tx, invoice); err != nil {
+			return err
+		}
+
+		// Prepaid advertisers paid every conversion from their wallet; the
+		// wallet also pays adjustments and tax
+		if s.wallets != nil {
+			extra := NewMoney(draft.Total-draft.PlatformFee, draft.Currency)
+			paid, err := s.wallets.ChargeInvoice(tx, invoice, extra)
+			if err != nil {
+				return err
+			}
+			if paid {
+				paidAt := time.Now()
+				invoice.Status = "paid"
+				invoice.PaidAt = &paidAt
+				invoice.PaymentMethod = "wallet"
+				if err := tx.Model(invoice).Updates(map[string]interface{}{
+					"status": invoice.Status, "paid_at": invoice.PaidAt, "payment_method": invoice.PaymentMethod,
+				}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// lockSequence creates the tenant's invoice sequence if needed and locks it
+func (s *InvoiceService) lockSequence(tx *gorm.DB, tenantID uuid.UUID) (*models.InvoiceSequence, error) {
+	seq := models.InvoiceSequence{TenantID: tenantID}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&seq).Error; err != nil {
+		return nil, fmt.Errorf("failed to create invoice sequence: %w", err)
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&seq, "tenant_id = ?", tenantID).Error; err != nil {
+		return nil, fmt.Errorf("failed to lock invoice sequence: %w", err)
+	}
+	return &seq, nil
+}
+
+// buildInvoice maps a finalized draft onto an invoice row
+func (s *InvoiceService) buildInvoice(tenantID uuid.UUID, advertiser *models.AfftokUser, draft *InvoiceDraft, periodStart, periodEnd time.Time, reporting *LedgerPeriodTotal) *models.Invoice {
+	currency := draft.Currency
+	payout := MinorToMajor(draft.Commission, currency)
+	fee := MinorToMajor(draft.PlatformFee, currency)
+	platformRate := 0.0
+	if payout > 0 {
+		platformRate = math.Round(fee/payout*10000) / 10000
+	}
+	now := time.Now()
+	lastDay := periodEnd.Add(-time.Second)
+
+	invoice := &models.Invoice{
+		AdvertiserID:        draft.AdvertiserID,
+		IssuedAt:            &now,
+		Month:               int(periodStart.Month()),
+		Year:                periodStart.Year(),
+		PeriodStart:         periodStart,
+		PeriodEnd:           lastDay,
+		TotalConversions:    draft.Conversions,
+		TotalPromoterPayout: payout,
+		PlatformRate:        platformRate,
+		PlatformAmount:      fee,
+		Currency:            currency,
+		AdjustmentAmount:    MinorToMajor(draft.Adjustment+draft.CarryOver, currency),
+		Subtotal:            MinorToMajor(draft.Subtotal, currency),
+		TaxAmount:           MinorToMajor(draft.Tax, currency),
+		TotalAmount:         MinorToMajor(draft.Total, currency),
+		TaxCountry:          NormalizeTaxCountry(advertiser.Country),
+		Status:              "pending",
+		DueDate:             lastDay.AddDate(0, 0, InvoiceDueDays),
+	}
+	invoice.TenantID = tenantID
+	if rule := draft.TaxRule; rule != nil {
+		invoice.TaxName = rule.Name
+		invoice.TaxRate = float64(rule.RateBps) / 10000
+		invoice.TaxReverseCharge = rule.ReverseCharge
+	}
+	if draft.Total <= 0 {
+		invoice.Status = "paid" // credit carried over, nothing to pay
+		invoice.PaidAt = &now
+	}
+
+	// Also report in the advertiser's reporting currency
+	if reporting != nil && s.fx != nil {
+		reportIn := s.fx.ReportingCurrency(tenantID, draft.AdvertiserID)
+		if amount, _, err := s.ledger.ReportIn(*reporting, reportIn, lastDay); err == nil {
+			invoice.ReportingCurrency = reportIn
+			invoice.ReportingPromoterPayout = MinorToMajor(amount.Commission, reportIn)
+			invoice.ReportingPlatformAmount = MinorToMajor(amount.PlatformFee, reportIn)
+		}
+	}
+	return invoice
+}
+
+// createItems stores the invoice lines, bills the adjustments and carries a
+// net credit over to a new adjustment
+func (s *InvoiceService) createItems(tx *gorm.DB, invoice *models.Invoice, draft *InvoiceDraft) error {
+	currency := draft.Currency
+	var items []models.InvoiceItem
+	for _, line := range draft.Lines {
+		description := line.OfferTitle
+		if line.Goal != "" {
+			description += " - " + line.Goal
+		}
+		items = append(items, models.InvoiceItem{
+			Kind:           models.InvoiceItemConversions,
+			OfferID:        line.OfferID,
+			OfferTitle:     line.OfferTitle,
+			Goal:           line.Goal,
+			Description:    description,
+			Conversions:    line.Conversions,
+			PromoterPayout: MinorToMajor(line.Commission, currency),
+			PlatformAmount: MinorToMajor(line.PlatformFee, currency),
+		})
+	}
+	for _, a := range draft.Adjustments {
+		adjustmentID := a.ID
+		items = append(items, models.InvoiceItem{
+			Kind:           models.InvoiceItemAdjustment,
+			AdjustmentID:   &adjustmentID,
+			Description:    a.Description,
+			PlatformAmount: MinorToMajor(a.Amount, currency),
+		})
+	}
+	if draft.CarryOver > 0 {
+		items = append(items, models.InvoiceItem{
+			Kind:           models.InvoiceItemCarryOver,
+			Description:    "Credit carried over to the next invoice",
+			PlatformAmount: MinorToMajor(draft.CarryOver, currency),
+		})
+	}
+	for i := range items {
+		items[i].InvoiceID = invoice.ID
+		items[i].Position = i + 1
+	}
+	if len(items) > 0 {
+		if err := tx.Create(&items).Error; err != nil {
+			return fmt.Errorf("failed to create invoice items: %w", err)
+		}
+	}
+
+	if len(draft.Adjustments) > 0 {
+		ids := make([]uuid.UUID, len(draft.Adjustments))
+		for i, a := range draft.Adjustments {
+			ids[i] = a.ID
+		}
+		if err := tx.Model(&models.InvoiceAdjustment{}).Where("id IN ?", ids).
+			Update("invoice_id", invoice.ID).Error; err != nil {
+			return fmt.Errorf("failed to bill adjustments: %w", err)
+		}
+	}
+	if draft.CarryOver > 0 {
+		// Already on the receivable: the carried credit is not posted again
+		invoiceID := invoice.ID
+		carry := models.InvoiceAdjustment{
+			AdvertiserID:         invoice.AdvertiserID,
+			Currency:             currency,
+			Amount:               -draft.CarryOver,
+			Description:          "Credit carried over from " + invoice.Number,
+			CarriedFromInvoiceID: &invoiceID,
+		}
+		carry.TenantID = invoice.TenantID
+		if err := tx.Create(&carry).Error; err != nil {
+			return fmt.Errorf("failed to carry credit over: %w", err)
+		}
+	}
+	return nil
+}
+
+// ============================================
+// ADJUSTMENTS & TAX RULES
+// ============================================
+
+// CreateAdjustment records a charge or credit for an advertiser's next
+// invoice and books it on the advertiser's receivable
+func (s *InvoiceService) CreateAdjustment(tenantID uuid.UUID, adjustment *models.InvoiceAdjustment) error {
+	adjustment.Currency = NormalizeCurrency(adjustment.Currency)
+	if adjustment.Amount == 0 || strings.TrimSpace(adjustment.Description) == "" || !ValidCurrency(adjustment.Currency) {
+		return fmt.Errorf("%w: amount, description and a valid currency are required", ErrInvoiceAdjustmentInvalid)
+	}
+	adjustment.TenantID = tenantID
+	adjustment.InvoiceID = nil
+	adjustment.CarriedFromInvoiceID = nil
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.AfftokUser{}).
+			Where("tenant_id = ? AND id = ? AND role = ?", tenantID, adjustment.AdvertiserID, "advertiser").
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: advertiser not found", ErrInvoiceAdjustmentInvalid)
+		}
+		if err := tx.Create(adjustment).Error; err != nil {
+			return err
+		}
+		_, err := s.ledger.PostAdvertiserAdjustment(tx, adjustment)
+		return err
+	})
+}
+
+// ListAdjustments lists a tenant's adjustments; unbilledOnly keeps those not
+// on an invoice yet
+func (s *InvoiceService) ListAdjustments(tenantID uuid.UUID, advertiserID *uuid.UUID, unbilledOnly bool) ([]models.InvoiceAdjustment, error) {
+	query := s.db.Where("tenant_id = ?", tenantID).Order("created_at DESC")
+	if advertiserID != nil {
+		query = query.Where("advertiser_id = ?", *advertiserID)
+	}
+	if unbilledOnly {
+		query = query.Where("invoice_id IS NULL")
+	}
+	var adjustments []models.InvoiceAdjustment
+	err := query.Limit(500).Find(&adjustments).Error
+	return adjustments, err
+}
+
+// ListTaxRules lists the tenant's tax rules followed by the platform defaults
+func (s *InvoiceService) ListTaxRules(tenantID uuid.UUID) ([]models.TaxRule, error) {
+	var rules []models.TaxRule
+	err := s.db.Where("tenant_id IN ?", []uuid.UUID{tenantID, models.DefaultTenantID}).
+		Order("country").Find(&rules).Error
+	return rules, err
+}
+
+// SaveTaxRule creates or replaces the tenant's rule for a country
+func (s *InvoiceService) SaveTaxRule(tenantID uuid.UUID, rule *models.TaxRule) error {
+	rule.Country = NormalizeTaxCountry(rule.Country)
+	if rule.Country == "" || strings.TrimSpace(rule.Name) == "" || rule.RateBps < 0 || rule.RateBps > 10000 {
+		return fmt.Errorf("%w: country, name and a rate between 0 and 10000 bps are required", ErrTaxRuleInvalid)
+	}
+	rule.TenantID = tenantID
+
+	var existing models.TaxRule
+	err := s.db.Where("tenant_id = ? AND country = ?", tenantID, rule.Country).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		active := rule.Active
+		if err := s.db.Create(rule).Error; err != nil {
+			return err
+		}
+		// Create skips the false zero value in favour of the column default
+		if !active {
+			rule.Active = false
+			return s.db.Model(rule).Update("active", false).Error
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	rule.ID = existing.ID
+	rule.CreatedAt = existing.CreatedAt
+	return s.db.Save(rule).Error
+}
+
+// DeleteTaxRule removes one of the tenant's tax rules
+func (s *InvoiceService) DeleteTaxRule(tenantID, ruleID uuid.UUID) error {
+	result := s.db.Where("tenant_id = ? AND id = ?", tenantID, ruleID).Delete(&models.TaxRule{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ============================================
+// PDF
+// ============================================
+
+// RenderPDF renders an invoice of the tenant as a bilingual PDF. A non-nil
+// advertiserID restricts it to that advertiser's invoices.
+func (s *InvoiceService) RenderPDF(tenantID, invoiceID uuid.UUID, advertiserID *uuid.UUID) ([]byte, *models.Invoice, error) {
+	query := s.db.Preload("Advertiser").Where("tenant_id = ? AND id = ?", tenantID, invoiceID)
+	if advertiserID != nil {
+		query = query.Where("advertiser_id = ?", *advertiserID)
+	}
+	var invoice models.Invoice
+	if err := query.First(&invoice).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvoiceNotFound
+		}
+		return nil, nil, err
+	}
+
+	var items []models.InvoiceItem
+	if err := s.db.Where("invoice_id = ?", invoice.ID).Order("position, created_at").Find(&items).Error; err != nil {
+		return nil, nil, err
+	}
+	titlesAr := make(map[uuid.UUID]string)
+	var offerIDs []uuid.UUID
+	for _, item := range items {
+		if item.OfferID != nil {
+			offerIDs = append(offerIDs, *item.OfferID)
+		}
+	}
+	if len(offerIDs) > 0 {
+		var offers []models.Offer
+		s.db.Select("id, title_ar").Where("id IN ?", offerIDs).Find(&offers)
+		for _, o := range offers {
+			titlesAr[o.ID] = o.TitleAr
+		}
+	}
+
+	var tenant models.Tenant
+	s.db.Select("id, name").First(&tenant, "id = ?", tenantID)
+
+	pdf, err := RenderInvoicePDF(InvoiceDocument{
+		Invoice:       &invoice,
+		Items:         items,
+		OfferTitlesAr: titlesAr,
+		IssuerName:    tenant.Name,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return pdf, &invoice, nil
+}
@@ -56,6 +56,7 @@ func (s *KYCAutoService) CheckAndTriggerKYC(userID uuid.UUID) (bool, string, err
 			return false, "", err
 		}
 		log.Printf("[KYC-AUTO] 🚨 KYC triggered for user %s: %s", userID, reason)
+		GetFraudCaseService(s.db).OpenFromKYCTrigger(userID, reason)
 		return true, reason, nil
 	}
 
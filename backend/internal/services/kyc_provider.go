@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ============================================
+// KYC PROVIDER ABSTRACTION
+// ============================================
+
+// KYC decision values returned by provider adapters
+const (
+	KYCDecisionPending      = "pending"      // no decision yet (applicant created, documents uploaded)
+	KYCDecisionInReview     = "in_review"    // provider is reviewing
+	KYCDecisionApproved     = "approved"     // identity verified
+	KYCDecisionResubmission = "resubmission" // rejected but the user may try again
+	KYCDecisionRejected     = "rejected"     // final rejection
+)
+
+// Errors returned by KYC providers
+var (
+	ErrKYCInvalidSignature = errors.New("invalid KYC webhook signature")
+	ErrKYCProviderDisabled = errors.New("KYC provider is not configured")
+	ErrKYCUnknownProvider  = errors.New("unknown KYC provider")
+)
+
+// KYCProvider is implemented by each external identity verification vendor
+type KYCProvider interface {
+	// Name returns the provider identifier stored on verifications ("sumsub", "veriff")
+	Name() string
+
+	// Enabled reports whether the provider has credentials configured
+	Enabled() bool
+
+	// CreateApplicant registers the user with the provider
+	CreateApplicant(ctx context.Context, req *KYCApplicantRequest) (*KYCApplicant, error)
+
+	// GetAccessToken returns a token/URL the client SDK uses to run the flow
+	GetAccessToken(ctx context.Context, applicant *KYCApplicant) (*KYCAccessToken, error)
+
+	// VerifyWebhookSignature checks the provider signature on a raw webhook body
+	VerifyWebhookSignature(headers http.Header, body []byte) error
+
+	// MapResult converts a provider webhook body into a normalised result
+	MapResult(body []byte) (*KYCProviderResult, error)
+}
+
+// KYCApplicantRequest contains the user data sent when creating an applicant
+type KYCApplicantRequest struct {
+	ExternalUserID string // our user ID
+	Email          string
+	Phone          string
+	FirstName      string
+	LastName       string
+	Country        string
+}
+
+// KYCApplicant is the provider-side record for a user
+type KYCApplicant struct {
+	ApplicantID     string `json:"applicant_id"`
+	ExternalUserID  string `json:"external_user_id"`
+	VerificationURL string `json:"verification_url,omitempty"`
+	SessionToken    string `json:"-"`
+}
+
+// KYCAccessToken is handed to the client SDK
+type KYCAccessToken struct {
+	Token           string    `json:"token,omitempty"`
+	VerificationURL string    `json:"verification_url,omitempty"`
+	ApplicantID     string    `json:"applicant_id"`
+	ExpiresAt       time.Time `json:"expires_at"`
+}
+
+// KYCStepResult is the status of one provider workflow step
+type KYCStepResult struct {
+	Name    string   `json:"name"`
+	Status  string   `json:"status"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// KYCProviderResult is the normalised outcome of a provider webhook
+type KYCProviderResult struct {
+	Provider         string          `json:"provider"`
+	EventType        string          `json:"event_type"`
+	ApplicantID      string          `json:"applicant_id"`
+	ExternalUserID   string          `json:"external_user_id"`
+	Decision         string          `json:"decision"`
+	RejectionReasons []string        `json:"rejection_reasons,omitempty"`
+	Comment          string          `json:"comment,omitempty"`
+	Steps            []KYCStepResult `json:"steps,omitempty"`
+}
+
+// IsFinal reports whether the result ends the verification
+func (r *KYCProviderResult) IsFinal() bool {
+	return r.Decision == KYCDecisionApproved || r.Decision == KYCDecisionRejected
+}
+
+// ============================================
+// PROVIDER REGISTRY
+// ============================================
+
+// KYCProviderRegistry holds the configured KYC providers
+type KYCProviderRegistry struct {
+	mu        sync.RWMutex
+	providers map[string]KYCProvider
+}
+
+// NewKYCProviderRegistry creates an empty registry
+func NewKYCProviderRegistry() *KYCProviderRegistry {
+	return &KYCProviderRegistry{providers: make(map[string]KYCProvider)}
+}
+
+// Register adds or replaces a provider
+func (r *KYCProviderRegistry) Register(provider KYCProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[provider.Name()] = provider
+}
+
+// Get returns a provider by name
+func (r *KYCProviderRegistry) Get(name string) (KYCProvider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, ErrKYCUnknownProvider
+	}
+	return provider, nil
+}
+
+// Names returns the registered provider names
+func (r *KYCProviderRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultKYCProviderRegistry builds a registry with the SumSub and Veriff
+// adapters configured from the environment
+func DefaultKYCProviderRegistry() *KYCProviderRegistry {
+	registry := NewKYCProviderRegistry()
+	registry.Register(NewSumSubProvider(SumSubConfigFromEnv()))
+	registry.Register(NewVeriffProvider(VeriffConfigFromEnv()))
+	return registry
+}
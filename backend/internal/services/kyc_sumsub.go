@@ -0,0 +1,337 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+)
+
+// ============================================
+// SUMSUB ADAPTER
+// ============================================
+
+// SumSubConfig holds SumSub API configuration
+type SumSubConfig struct {
+	AppToken      string
+	SecretKey     string // signs API requests
+	WebhookSecret string // signs webhooks (X-Payload-Digest)
+	LevelName     string
+	BaseURL       string
+	TokenTTL      time.Duration
+	HTTPClient    *http.Client
+	Now           func() time.Time
+}
+
+// SumSubConfigFromEnv loads SumSub configuration from the environment
+func SumSubConfigFromEnv() SumSubConfig {
+	cfg := SumSubConfig{
+		AppToken:      os.Getenv("SUMSUB_APP_TOKEN"),
+		SecretKey:     os.Getenv("SUMSUB_SECRET_KEY"),
+		WebhookSecret: os.Getenv("SUMSUB_WEBHOOK_SECRET"),
+		LevelName:     os.Getenv("SUMSUB_LEVEL_NAME"),
+		BaseURL:       os.Getenv("SUMSUB_BASE_URL"),
+	}
+	if cfg.LevelName == "" {
+		cfg.LevelName = "basic-kyc-level"
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api.sumsub.com"
+	}
+	return cfg
+}
+
+// SumSubProvider implements KYCProvider for SumSub
+type SumSubProvider struct {
+	cfg SumSubConfig
+}
+
+// NewSumSubProvider creates a SumSub adapter
+func NewSumSubProvider(cfg SumSubConfig) *SumSubProvider {
+	if cfg.TokenTTL == 0 {
+		cfg.TokenTTL = 10 * time.Minute
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 15 * time.Second}
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	return &SumSubProvider{cfg: cfg}
+}
+
+// Name returns the provider identifier
+func (p *SumSubProvider) Name() string {
+	return models.KYCProviderSumSub
+}
+
+// Enabled reports whether API credentials are configured
+func (p *SumSubProvider) Enabled() bool {
+	return p.cfg.AppToken != "" && p.cfg.SecretKey != ""
+}
+
+// CreateApplicant creates a SumSub applicant for the user
+// POST /resources/applicants?levelName=...
+func (p *SumSubProvider) CreateApplicant(ctx context.Context, req *KYCApplicantRequest) (*KYCApplicant, error) {
+	if !p.Enabled() {
+		return nil, ErrKYCProviderDisabled
+	}
+
+	payload := map[string]interface{}{
+		"externalUserId": req.ExternalUserID,
+	}
+	if req.Email != "" {
+		payload["email"] = req.Email
+	}
+	if req.Phone != "" {
+		payload["phone"] = req.Phone
+	}
+	info := map[string]string{}
+	if req.FirstName != "" {
+		info["firstName"] = req.FirstName
+	}
+	if req.LastName != "" {
+		info["lastName"] = req.LastName
+	}
+	if req.Country != "" {
+		info["country"] = req.Country
+	}
+	if len(info) > 0 {
+		payload["fixedInfo"] = info
+	}
+
+	body, _ := json.Marshal(payload)
+	path := "/resources/applicants?levelName=" + url.QueryEscape(p.cfg.LevelName)
+
+	var resp struct {
+		ID             string `json:"id"`
+		ExternalUserID string `json:"externalUserId"`
+	}
+	if err := p.do(ctx, http.MethodPost, path, body, &resp); err != nil {
+		return nil, err
+	}
+
+	return &KYCApplicant{
+		ApplicantID:    resp.ID,
+		ExternalUserID: resp.ExternalUserID,
+	}, nil
+}
+
+// GetAccessToken issues a WebSDK access token for the applicant
+// POST /resources/accessTokens?userId=...&levelName=...&ttlInSecs=...
+func (p *SumSubProvider) GetAccessToken(ctx context.Context, applicant *KYCApplicant) (*KYCAccessToken, error) {
+	if !p.Enabled() {
+		return nil, ErrKYCProviderDisabled
+	}
+
+	query := url.Values{}
+	query.Set("userId", applicant.ExternalUserID)
+	query.Set("levelName", p.cfg.LevelName)
+	query.Set("ttlInSecs", strconv.Itoa(int(p.cfg.TokenTTL/time.Second)))
+
+	var resp struct {
+		Token  string `json:"token"`
+		UserID string `json:"userId"`
+	}
+	if err := p.do(ctx, http.MethodPost, "/resources/accessTokens?"+query.Encode(), nil, &resp); err != nil {
+		return nil, err
+	}
+
+	return &KYCAccessToken{
+		Token:       resp.Token,
+		ApplicantID: applicant.ApplicantID,
+		ExpiresAt:   p.cfg.Now().Add(p.cfg.TokenTTL).UTC(),
+	}, nil
+}
+
+// VerifyWebhookSignature checks X-Payload-Digest against the raw body.
+// The algorithm comes from X-Payload-Digest-Alg (SHA1 when absent).
+func (p *SumSubProvider) VerifyWebhookSignature(headers http.Header, body []byte) error {
+	if p.cfg.WebhookSecret == "" {
+		return ErrKYCProviderDisabled
+	}
+
+	digest := headers.Get("X-Payload-Digest")
+	if digest == "" {
+		return ErrKYCInvalidSignature
+	}
+
+	var newHash func() hash.Hash
+	switch headers.Get("X-Payload-Digest-Alg") {
+	case "", "HMAC_SHA1_HEX":
+		newHash = sha1.New
+	case "HMAC_SHA256_HEX":
+		newHash = sha256.New
+	case "HMAC_SHA512_HEX":
+		newHash = sha512.New
+	default:
+		return ErrKYCInvalidSignature
+	}
+
+	mac := hmac.New(newHash, []byte(p.cfg.WebhookSecret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(digest))) {
+		return ErrKYCInvalidSignature
+	}
+	return nil
+}
+
+// sumSubWebhook is the subset of the SumSub webhook payload we use
+type sumSubWebhook struct {
+	Type           string `json:"type"`
+	ApplicantID    string `json:"applicantId"`
+	ExternalUserID string `json:"externalUserId"`
+	ReviewStatus   string `json:"reviewStatus"`
+	ReviewResult   struct {
+		ReviewAnswer      string   `json:"reviewAnswer"`     // GREEN | RED
+		ReviewRejectType  string   `json:"reviewRejectType"` // RETRY | FINAL
+		RejectLabels      []string `json:"rejectLabels"`
+		ModerationComment string   `json:"moderationComment"`
+		ClientComment     string   `json:"clientComment"`
+	} `json:"reviewResult"`
+}
+
+// MapResult converts a SumSub webhook into a normalised result
+func (p *SumSubProvider) MapResult(body []byte) (*KYCProviderResult, error) {
+	var hook sumSubWebhook
+	if err := json.Unmarshal(body, &hook); err != nil {
+		return nil, fmt.Errorf("invalid sumsub payload: %w", err)
+	}
+	if hook.ApplicantID == "" {
+		return nil, fmt.Errorf("invalid sumsub payload: missing applicantId")
+	}
+
+	result := &KYCProviderResult{
+		Provider:       p.Name(),
+		EventType:      hook.Type,
+		ApplicantID:    hook.ApplicantID,
+		ExternalUserID: hook.ExternalUserID,
+		Decision:       KYCDecisionPending,
+	}
+
+	switch hook.Type {
+	case "applicantCreated":
+		result.Steps = []KYCStepResult{{Name: "applicant", Status: "created"}}
+	case "applicantPending":
+		result.Decision = KYCDecisionInReview
+		result.Steps = []KYCStepResult{{Name: "documents", Status: "submitted"}}
+	case "applicantOnHold":
+		result.Decision = KYCDecisionInReview
+		result.Steps = []KYCStepResult{{Name: "review", Status: "on_hold"}}
+	case "applicantReviewed":
+		result.Comment = hook.ReviewResult.ModerationComment
+		if result.Comment == "" {
+			result.Comment = hook.ReviewResult.ClientComment
+		}
+
+		if hook.ReviewResult.ReviewAnswer == "GREEN" {
+			result.Decision = KYCDecisionApproved
+			result.Steps = []KYCStepResult{{Name: "review", Status: "approved"}}
+			break
+		}
+
+		result.RejectionReasons = hook.ReviewResult.RejectLabels
+		if hook.ReviewResult.ReviewRejectType == "FINAL" {
+			result.Decision = KYCDecisionRejected
+		} else {
+			result.Decision = KYCDecisionResubmission
+		}
+		result.Steps = sumSubRejectedSteps(hook.ReviewResult.RejectLabels)
+	}
+
+	return result, nil
+}
+
+// sumSubRejectedSteps groups reject labels by the workflow step they belong to
+func sumSubRejectedSteps(labels []string) []KYCStepResult {
+	byStep := make(map[string][]string)
+	order := make([]string, 0, 3)
+	for _, label := range labels {
+		step := "review"
+		switch {
+		case strings.Contains(label, "SELFIE"), strings.Contains(label, "FACE"), strings.Contains(label, "LIVENESS"):
+			step = "selfie"
+		case strings.Contains(label, "ADDRESS"), strings.Contains(label, "PROOF_OF"):
+			step = "proof_of_address"
+		case strings.Contains(label, "DOCUMENT"), strings.Contains(label, "ID_"), strings.Contains(label, "FORGERY"),
+			strings.Contains(label, "EXPIRATION"), strings.Contains(label, "UNSATISFACTORY_PHOTOS"):
+			step = "identity"
+		}
+		if _, seen := byStep[step]; !seen {
+			order = append(order, step)
+		}
+		byStep[step] = append(byStep[step], label)
+	}
+
+	if len(order) == 0 {
+		return []KYCStepResult{{Name: "review", Status: "rejected"}}
+	}
+
+	steps := make([]KYCStepResult, 0, len(order))
+	for _, step := range order {
+		steps = append(steps, KYCStepResult{Name: step, Status: "rejected", Reasons: byStep[step]})
+	}
+	return steps
+}
+
+// do sends a signed request to the SumSub API
+func (p *SumSubProvider) do(ctx context.Context, method, path string, body []byte, out interface{}) error {
+	ts := strconv.FormatInt(p.cfg.Now().Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(p.cfg.SecretKey))
+	mac.Write([]byte(ts + method + path))
+	mac.Write(body)
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	req, err := http.NewRequestWithContext(ctx, method, p.cfg.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("X-App-Token", p.cfg.AppToken)
+	req.Header.Set("X-App-Access-Ts", ts)
+	req.Header.Set("X-App-Access-Sig", signature)
+
+	resp, err := p.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sumsub request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Description string `json:"description"`
+		}
+		json.Unmarshal(data, &apiErr)
+		return fmt.Errorf("sumsub %s %s: status %d: %s", method, path, resp.StatusCode, apiErr.Description)
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("invalid sumsub response: %w", err)
+		}
+	}
+	return nil
+}
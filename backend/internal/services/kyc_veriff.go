@@ -0,0 +1,298 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+)
+
+// ============================================
+// VERIFF ADAPTER
+// ============================================
+
+// VeriffConfig holds Veriff API configuration
+type VeriffConfig struct {
+	APIKey       string
+	SharedSecret string // signs webhooks (X-HMAC-SIGNATURE)
+	CallbackURL  string
+	BaseURL      string
+	SessionTTL   time.Duration
+	HTTPClient   *http.Client
+	Now          func() time.Time
+}
+
+// VeriffConfigFromEnv loads Veriff configuration from the environment
+func VeriffConfigFromEnv() VeriffConfig {
+	cfg := VeriffConfig{
+		APIKey:       os.Getenv("VERIFF_API_KEY"),
+		SharedSecret: os.Getenv("VERIFF_SHARED_SECRET"),
+		CallbackURL:  os.Getenv("VERIFF_CALLBACK_URL"),
+		BaseURL:      os.Getenv("VERIFF_BASE_URL"),
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://stationapi.veriff.com"
+	}
+	return cfg
+}
+
+// VeriffProvider implements KYCProvider for Veriff
+type VeriffProvider struct {
+	cfg VeriffConfig
+}
+
+// NewVeriffProvider creates a Veriff adapter
+func NewVeriffProvider(cfg VeriffConfig) *VeriffProvider {
+	if cfg.SessionTTL == 0 {
+		cfg.SessionTTL = 7 * 24 * time.Hour // Veriff session URLs stay valid for 7 days
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 15 * time.Second}
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	return &VeriffProvider{cfg: cfg}
+}
+
+// Name returns the provider identifier
+func (p *VeriffProvider) Name() string {
+	return models.KYCProviderVeriff
+}
+
+// Enabled reports whether API credentials are configured
+func (p *VeriffProvider) Enabled() bool {
+	return p.cfg.APIKey != "" && p.cfg.SharedSecret != ""
+}
+
+// CreateApplicant creates a Veriff verification session.
+// In Veriff the session is the applicant; its URL is what the SDK opens.
+// POST /v1/sessions
+func (p *VeriffProvider) CreateApplicant(ctx context.Context, req *KYCApplicantRequest) (*KYCApplicant, error) {
+	if !p.Enabled() {
+		return nil, ErrKYCProviderDisabled
+	}
+
+	verification := map[string]interface{}{
+		"vendorData": req.ExternalUserID,
+		"timestamp":  p.cfg.Now().UTC().Format(time.RFC3339),
+	}
+	if p.cfg.CallbackURL != "" {
+		verification["callback"] = p.cfg.CallbackURL
+	}
+	if req.FirstName != "" || req.LastName != "" {
+		verification["person"] = map[string]string{
+			"firstName": req.FirstName,
+			"lastName":  req.LastName,
+		}
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{"verification": verification})
+
+	var resp struct {
+		Status       string `json:"status"`
+		Verification struct {
+			ID           string `json:"id"`
+			URL          string `json:"url"`
+			VendorData   string `json:"vendorData"`
+			SessionToken string `json:"sessionToken"`
+		} `json:"verification"`
+	}
+	if err := p.do(ctx, http.MethodPost, "/v1/sessions", body, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Status != "success" || resp.Verification.ID == "" {
+		return nil, fmt.Errorf("veriff session creation failed: status %q", resp.Status)
+	}
+
+	return &KYCApplicant{
+		ApplicantID:     resp.Verification.ID,
+		ExternalUserID:  req.ExternalUserID,
+		VerificationURL: resp.Verification.URL,
+		SessionToken:    resp.Verification.SessionToken,
+	}, nil
+}
+
+// GetAccessToken returns the session URL for the SDK. Veriff has no separate
+// token endpoint, so a fresh session is created when the previous one is not
+// at hand; the returned ApplicantID then identifies the new session.
+func (p *VeriffProvider) GetAccessToken(ctx context.Context, applicant *KYCApplicant) (*KYCAccessToken, error) {
+	if applicant.VerificationURL == "" {
+		fresh, err := p.CreateApplicant(ctx, &KYCApplicantRequest{ExternalUserID: applicant.ExternalUserID})
+		if err != nil {
+			return nil, err
+		}
+		applicant = fresh
+	}
+
+	return &KYCAccessToken{
+		Token:           applicant.SessionToken,
+		VerificationURL: applicant.VerificationURL,
+		ApplicantID:     applicant.ApplicantID,
+		ExpiresAt:       p.cfg.Now().Add(p.cfg.SessionTTL).UTC(),
+	}, nil
+}
+
+// VerifyWebhookSignature checks X-HMAC-SIGNATURE (HMAC-SHA256 of the raw body
+// with the shared secret) and that X-AUTH-CLIENT matches our API key
+func (p *VeriffProvider) VerifyWebhookSignature(headers http.Header, body []byte) error {
+	if !p.Enabled() {
+		return ErrKYCProviderDisabled
+	}
+
+	if client := headers.Get("X-AUTH-CLIENT"); client != "" && client != p.cfg.APIKey {
+		return ErrKYCInvalidSignature
+	}
+
+	signature := headers.Get("X-HMAC-SIGNATURE")
+	if signature == "" {
+		return ErrKYCInvalidSignature
+	}
+
+	if !hmac.Equal([]byte(p.sign(body)), []byte(strings.ToLower(signature))) {
+		return ErrKYCInvalidSignature
+	}
+	return nil
+}
+
+// veriffWebhook covers both Veriff webhook shapes: decision webhooks carry a
+// "verification" object, event webhooks carry "action"/"code" at the top level
+type veriffWebhook struct {
+	Status       string `json:"status"`
+	Verification *struct {
+		ID         string `json:"id"`
+		Code       int    `json:"code"`
+		Status     string `json:"status"` // approved | declined | resubmission_requested | expired | abandoned | review
+		Reason     string `json:"reason"`
+		ReasonCode *int   `json:"reasonCode"`
+		VendorData string `json:"vendorData"`
+	} `json:"verification"`
+
+	// Event webhook fields
+	ID         string `json:"id"`
+	Action     string `json:"action"` // started | submitted
+	Feature    string `json:"feature"`
+	Code       int    `json:"code"`
+	VendorData string `json:"vendorData"`
+}
+
+// MapResult converts a Veriff webhook into a normalised result
+func (p *VeriffProvider) MapResult(body []byte) (*KYCProviderResult, error) {
+	var hook veriffWebhook
+	if err := json.Unmarshal(body, &hook); err != nil {
+		return nil, fmt.Errorf("invalid veriff payload: %w", err)
+	}
+
+	// Event webhook (session started / submitted)
+	if hook.Verification == nil {
+		if hook.ID == "" {
+			return nil, fmt.Errorf("invalid veriff payload: missing session id")
+		}
+		result := &KYCProviderResult{
+			Provider:       p.Name(),
+			EventType:      "event:" + hook.Action,
+			ApplicantID:    hook.ID,
+			ExternalUserID: hook.VendorData,
+			Decision:       KYCDecisionPending,
+		}
+		switch hook.Action {
+		case "started":
+			result.Steps = []KYCStepResult{{Name: "session", Status: "started"}}
+		case "submitted":
+			result.Decision = KYCDecisionInReview
+			result.Steps = []KYCStepResult{{Name: "documents", Status: "submitted"}}
+		}
+		return result, nil
+	}
+
+	v := hook.Verification
+	if v.ID == "" {
+		return nil, fmt.Errorf("invalid veriff payload: missing verification id")
+	}
+
+	result := &KYCProviderResult{
+		Provider:       p.Name(),
+		EventType:      "decision:" + v.Status,
+		ApplicantID:    v.ID,
+		ExternalUserID: v.VendorData,
+		Comment:        v.Reason,
+	}
+
+	var reasons []string
+	if v.ReasonCode != nil {
+		reasons = append(reasons, strconv.Itoa(*v.ReasonCode))
+	}
+	if v.Reason != "" {
+		reasons = append(reasons, v.Reason)
+	}
+
+	switch v.Status {
+	case "approved":
+		result.Decision = KYCDecisionApproved
+		result.Steps = []KYCStepResult{{Name: "decision", Status: "approved"}}
+	case "declined":
+		result.Decision = KYCDecisionRejected
+		result.RejectionReasons = reasons
+		result.Steps = []KYCStepResult{{Name: "decision", Status: "declined", Reasons: reasons}}
+	case "resubmission_requested", "expired", "abandoned":
+		// The user can start a new session
+		result.Decision = KYCDecisionResubmission
+		result.RejectionReasons = reasons
+		result.Steps = []KYCStepResult{{Name: "decision", Status: v.Status, Reasons: reasons}}
+	default:
+		result.Decision = KYCDecisionInReview
+		result.Steps = []KYCStepResult{{Name: "decision", Status: v.Status}}
+	}
+
+	return result, nil
+}
+
+// sign returns the hex HMAC-SHA256 of a payload with the shared secret
+func (p *VeriffProvider) sign(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(p.cfg.SharedSecret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// do sends an authenticated request to the Veriff API
+func (p *VeriffProvider) do(ctx context.Context, method, path string, body []byte, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, p.cfg.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-AUTH-CLIENT", p.cfg.APIKey)
+	if body != nil {
+		req.Header.Set("X-HMAC-SIGNATURE", p.sign(body))
+	}
+
+	resp, err := p.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("veriff request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("veriff %s %s: status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("invalid veriff response: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,384 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// KYC VERIFICATION SERVICE
+// ============================================
+
+// Errors returned by the KYC verification workflow
+var (
+	ErrKYCAlreadyVerified = errors.New("user is already verified")
+	ErrKYCRetryLimit      = errors.New("KYC retry limit reached")
+	ErrKYCNotFound        = errors.New("KYC verification not found")
+)
+
+// DefaultKYCMaxAttempts is the number of submissions allowed before a
+// resubmission request becomes a final rejection
+const DefaultKYCMaxAttempts = 3
+
+// KYCVerificationService runs the document verification workflow against
+// the configured KYC providers
+type KYCVerificationService struct {
+	db              *gorm.DB
+	registry        *KYCProviderRegistry
+	defaultProvider string
+	maxAttempts     int
+}
+
+// NewKYCVerificationService creates a KYC verification service using the
+// providers configured in the environment
+func NewKYCVerificationService(db *gorm.DB) *KYCVerificationService {
+	service := &KYCVerificationService{
+		db:              db,
+		registry:        DefaultKYCProviderRegistry(),
+		defaultProvider: models.KYCProviderSumSub,
+		maxAttempts:     DefaultKYCMaxAttempts,
+	}
+
+	if provider := os.Getenv("KYC_DEFAULT_PROVIDER"); provider != "" {
+		service.defaultProvider = provider
+	}
+	if v, err := strconv.Atoi(os.Getenv("KYC_MAX_ATTEMPTS")); err == nil && v > 0 {
+		service.maxAttempts = v
+	}
+
+	return service
+}
+
+// SetRegistry replaces the provider registry (for dependency injection)
+func (s *KYCVerificationService) SetRegistry(registry *KYCProviderRegistry) {
+	s.registry = registry
+}
+
+// Providers returns the registered provider names
+func (s *KYCVerificationService) Providers() []string {
+	return s.registry.Names()
+}
+
+// KYCStartResult is returned when a user starts or resumes verification
+type KYCStartResult struct {
+	Verification *models.KYCVerification `json:"verification"`
+	AccessToken  *KYCAccessToken         `json:"access"`
+}
+
+// StartVerification creates (or resumes) the user's applicant with a provider
+// and returns the SDK access token
+func (s *KYCVerificationService) StartVerification(ctx context.Context, userID uuid.UUID, providerName string) (*KYCStartResult, error) {
+	if providerName == "" {
+		providerName = s.defaultProvider
+	}
+	provider, err := s.registry.Get(providerName)
+	if err != nil {
+		return nil, err
+	}
+	if !provider.Enabled() {
+		return nil, ErrKYCProviderDisabled
+	}
+
+	var user models.AfftokUser
+	if err := s.db.Select("id, email, phone, full_name, country, kyc_status").First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	if user.IsKYCVerified() {
+		return nil, ErrKYCAlreadyVerified
+	}
+
+	latest, err := s.GetLatestVerification(userID)
+	if err != nil && !errors.Is(err, ErrKYCNotFound) {
+		return nil, err
+	}
+
+	// A final rejection or exhausted attempts can only be lifted by an admin
+	if latest != nil && (latest.Status == models.KYCVerificationRejected || latest.AttemptsRemaining() == 0) {
+		return nil, ErrKYCRetryLimit
+	}
+
+	// Resume an unfinished verification with the same provider
+	if latest != nil && !latest.IsFinal() && latest.Provider == providerName && latest.ApplicantID != "" {
+		token, err := provider.GetAccessToken(ctx, &KYCApplicant{
+			ApplicantID:    latest.ApplicantID,
+			ExternalUserID: userID.String(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if token.ApplicantID != "" && token.ApplicantID != latest.ApplicantID {
+			latest.ApplicantID = token.ApplicantID
+			s.db.Model(latest).Update("applicant_id", token.ApplicantID)
+		}
+		return &KYCStartResult{Verification: latest, AccessToken: token}, nil
+	}
+
+	applicant, err := provider.CreateApplicant(ctx, &KYCApplicantRequest{
+		ExternalUserID: userID.String(),
+		Email:          user.Email,
+		Phone:          user.Phone,
+		FirstName:      user.FullName,
+		Country:        user.Country,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := provider.GetAccessToken(ctx, applicant)
+	if err != nil {
+		return nil, err
+	}
+
+	// Attempts carry over so switching provider doesn't reset the limit
+	attempts := 0
+	if latest != nil {
+		attempts = latest.Attempts
+	}
+
+	verification := &models.KYCVerification{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Provider:    providerName,
+		ApplicantID: applicant.ApplicantID,
+		Status:      models.KYCVerificationPending,
+		CurrentStep: "applicant",
+		Attempts:    attempts,
+		MaxAttempts: s.maxAttempts,
+	}
+	if err := s.db.Create(verification).Error; err != nil {
+		return nil, err
+	}
+
+	s.db.Model(&models.AfftokUser{}).Where("id = ?", userID).
+		Update("kyc_provider_ref", providerName+":"+applicant.ApplicantID)
+
+	return &KYCStartResult{Verification: verification, AccessToken: token}, nil
+}
+
+// HandleWebhook verifies and applies a provider webhook
+func (s *KYCVerificationService) HandleWebhook(providerName string, headers http.Header, body []byte) (*models.KYCVerification, *KYCProviderResult, error) {
+	provider, err := s.registry.Get(providerName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := provider.VerifyWebhookSignature(headers, body); err != nil {
+		return nil, nil, err
+	}
+
+	result, err := provider.MapResult(body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	verification, err := s.findForResult(result)
+	if err != nil {
+		return nil, result, err
+	}
+
+	if err := s.applyResult(verification, result); err != nil {
+		return nil, result, err
+	}
+
+	return verification, result, nil
+}
+
+// findForResult locates the verification a webhook refers to
+func (s *KYCVerificationService) findForResult(result *KYCProviderResult) (*models.KYCVerification, error) {
+	var verification models.KYCVerification
+	err := s.db.Where("provider = ? AND applicant_id = ?", result.Provider, result.ApplicantID).
+		First(&verification).Error
+	if err == nil {
+		return &verification, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// Fall back to the user's latest verification with this provider
+	userID, parseErr := uuid.Parse(result.ExternalUserID)
+	if parseErr != nil {
+		return nil, ErrKYCNotFound
+	}
+	err = s.db.Where("user_id = ? AND provider = ?", userID, result.Provider).
+		Order("created_at DESC").First(&verification).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrKYCNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	verification.ApplicantID = result.ApplicantID
+	return &verification, nil
+}
+
+// applyResult updates the verification and the user's KYC status
+func (s *KYCVerificationService) applyResult(v *models.KYCVerification, result *KYCProviderResult) error {
+	if v.IsFinal() {
+		log.Printf("[KYC] ignoring %s event for finished verification %s", result.EventType, v.ID)
+		return nil
+	}
+
+	now := time.Now().UTC()
+	v.LastEventType = result.EventType
+	v.LastEventAt = &now
+	v.Steps = mergeKYCSteps(v.Steps, result.Steps, now)
+	if len(result.Steps) > 0 {
+		v.CurrentStep = result.Steps[len(result.Steps)-1].Name
+	}
+	if result.Comment != "" {
+		v.ProviderComment = result.Comment
+	}
+
+	var userVerified *bool
+	switch result.Decision {
+	case KYCDecisionInReview:
+		v.Status = models.KYCVerificationInReview
+	case KYCDecisionApproved:
+		v.Status = models.KYCVerificationApproved
+		v.CompletedAt = &now
+		verified := true
+		userVerified = &verified
+	case KYCDecisionResubmission, KYCDecisionRejected:
+		v.Attempts++
+		if len(result.RejectionReasons) > 0 {
+			v.RejectionReasons, _ = json.Marshal(result.RejectionReasons)
+		}
+		if result.Decision == KYCDecisionRejected || v.AttemptsRemaining() == 0 {
+			v.Status = models.KYCVerificationRejected
+			v.CompletedAt = &now
+			verified := false
+			userVerified = &verified
+		} else {
+			v.Status = models.KYCVerificationResubmission
+		}
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(v).Error; err != nil {
+			return err
+		}
+
+		ref := v.Provider + ":" + v.ApplicantID
+		if userVerified != nil {
+			if err := NewKYCAutoService(tx).UpdateKYCFromProvider(v.UserID, *userVerified, ref); err != nil {
+				return err
+			}
+			if *userVerified {
+				_, err := NewPayoutService(tx).ReleaseKYCHolds(v.UserID)
+				return err
+			}
+			return nil
+		}
+
+		// Resubmission keeps the user in "required" until a final decision
+		if v.Status == models.KYCVerificationResubmission {
+			return tx.Model(&models.AfftokUser{}).
+				Where("id = ? AND kyc_status = ?", v.UserID, models.KYCStatusNone).
+				Updates(map[string]interface{}{
+					"kyc_status":      models.KYCStatusRequired,
+					"kyc_required_at": now,
+				}).Error
+		}
+		return nil
+	})
+}
+
+// mergeKYCSteps updates the stored step list with the latest step statuses
+func mergeKYCSteps(existing datatypes.JSON, updates []KYCStepResult, at time.Time) datatypes.JSON {
+	var steps []models.KYCStepStatus
+	if len(existing) > 0 {
+		json.Unmarshal(existing, &steps)
+	}
+
+	for _, update := range updates {
+		found := false
+		for i := range steps {
+			if steps[i].Name == update.Name {
+				steps[i].Status = update.Status
+				steps[i].Reasons = update.Reasons
+				steps[i].UpdatedAt = at
+				found = true
+				break
+			}
+		}
+		if !found {
+			steps = append(steps, models.KYCStepStatus{
+				Name:      update.Name,
+				Status:    update.Status,
+				Reasons:   update.Reasons,
+				UpdatedAt: at,
+			})
+		}
+	}
+
+	data, _ := json.Marshal(steps)
+	return data
+}
+
+// GetLatestVerification returns the user's most recent verification
+func (s *KYCVerificationService) GetLatestVerification(userID uuid.UUID) (*models.KYCVerification, error) {
+	var verification models.KYCVerification
+	err := s.db.Where("user_id = ?", userID).Order("created_at DESC").First(&verification).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrKYCNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &verification, nil
+}
+
+// GetVerifications returns all verifications for a user (newest first)
+func (s *KYCVerificationService) GetVerifications(userID uuid.UUID) ([]models.KYCVerification, error) {
+	var verifications []models.KYCVerification
+	err := s.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&verifications).Error
+	return verifications, err
+}
+
+// ResetAttempts gives the user a fresh set of attempts after a rejection (admin only)
+func (s *KYCVerificationService) ResetAttempts(userID uuid.UUID) error {
+	latest, err := s.GetLatestVerification(userID)
+	if err != nil {
+		return err
+	}
+	if latest.Status == models.KYCVerificationApproved {
+		return ErrKYCAlreadyVerified
+	}
+	return s.db.Model(latest).Updates(map[string]interface{}{
+		"status":       models.KYCVerificationResubmission,
+		"attempts":     0,
+		"max_attempts": s.maxAttempts,
+		"completed_at": nil,
+	}).Error
+}
+
+// KYCErrorStatus maps workflow errors to an HTTP status
+func KYCErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrKYCInvalidSignature):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrKYCUnknownProvider):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrKYCProviderDisabled):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrKYCAlreadyVerified), errors.Is(err, ErrKYCRetryLimit):
+		return http.StatusConflict
+	case errors.Is(err, ErrKYCNotFound), errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadGateway
+	}
+}
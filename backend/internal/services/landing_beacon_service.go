@@ -0,0 +1,587 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/cache"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// LANDING BEACON SERVICE
+// ============================================
+
+// LandingBeaconService verifies clicks with landing-page beacons sent by the
+// web SDK and scores promoters by their verified visit rate. It also flags
+// referrers that don't match a promoter's declared traffic sources.
+type LandingBeaconService struct {
+	db            *gorm.DB
+	secret        []byte
+	observability *ObservabilityService
+
+	mu              sync.RWMutex
+	ineligible      map[uuid.UUID]bool
+	ineligibleUntil time.Time
+}
+
+// Landing beacon configuration
+const (
+	BeaconTokenParam        = "afftok_vt"         // query parameter appended to the landing URL
+	BeaconTokenTTL          = 2 * time.Hour       // beacons accepted this long after the click
+	BeaconGracePeriod       = 30 * time.Minute    // clicks without a beacon after this are flagged
+	BeaconSweepInterval     = 10 * time.Minute    // how often missing beacons are swept
+	BeaconMinVisibleMs      = 1000                // visible time for a verified view
+	BeaconMinViewportPx     = 10                  // smaller viewports are pixel/1x1 loads
+	VerifiedVisitWindow     = 30 * 24 * time.Hour // window for the verified visit rate
+	VerifiedVisitMinClicks  = 20                  // beacon-enabled clicks needed before the rate counts
+	VerifiedVisitMinRate    = 0.3                 // below this a promoter is penalised and off the leaderboard
+	verifiedVisitMaxPenalty = 40.0                // fraud score added at a 0% verified visit rate
+	verifiedVisitCacheTTL   = 10 * time.Minute
+)
+
+// Click flags set by beacon checks
+const (
+	BeaconFlagNoBeacon         = "no_beacon"
+	BeaconFlagHidden           = "hidden_view"
+	BeaconFlagShortVisit       = "short_visit"
+	BeaconFlagIframe           = "iframe_load"
+	BeaconFlagTinyViewport     = "tiny_viewport"
+	BeaconFlagReferrerMismatch = "referrer_mismatch"
+	BeaconFlagLowVisitRate     = "low_verified_visit_rate"
+)
+
+// Errors returned by the beacon service
+var (
+	ErrBeaconInvalidToken = errors.New("invalid beacon token")
+	ErrBeaconExpired      = errors.New("beacon token expired")
+)
+
+// NewLandingBeaconService creates a new landing beacon service
+func NewLandingBeaconService(db *gorm.DB) *LandingBeaconService {
+	secret := os.Getenv("BEACON_TOKEN_SECRET")
+	if secret == "" {
+		secret = os.Getenv("JWT_SECRET")
+	}
+	if secret == "" {
+		secret = "afftok-beacon-secret-change-in-production"
+	}
+
+	return &LandingBeaconService{
+		db:            db,
+		secret:        []byte(secret),
+		observability: NewObservabilityService(),
+	}
+}
+
+// ============================================
+// TOKENS
+// ============================================
+
+// IssueToken creates the signed token carried to the landing page
+// Format: base64url(clickID|unix).base64url(hmac)
+func (s *LandingBeaconService) IssueToken(clickID uuid.UUID) string {
+	payload := clickID.String() + "|" + strconv.FormatInt(time.Now().Unix(), 10)
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + s.sign(encoded)
+}
+
+// ParseToken validates a beacon token and returns the click ID
+func (s *LandingBeaconService) ParseToken(token string) (uuid.UUID, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 || !hmac.Equal([]byte(s.sign(parts[0])), []byte(parts[1])) {
+		return uuid.Nil, ErrBeaconInvalidToken
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return uuid.Nil, ErrBeaconInvalidToken
+	}
+
+	fields := strings.SplitN(string(raw), "|", 2)
+	if len(fields) != 2 {
+		return uuid.Nil, ErrBeaconInvalidToken
+	}
+
+	clickID, err := uuid.Parse(fields[0])
+	if err != nil {
+		return uuid.Nil, ErrBeaconInvalidToken
+	}
+	issued, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return uuid.Nil, ErrBeaconInvalidToken
+	}
+	if time.Since(time.Unix(issued, 0)) > BeaconTokenTTL {
+		return uuid.Nil, ErrBeaconExpired
+	}
+
+	return clickID, nil
+}
+
+// AppendToken adds the beacon token to a destination URL
+func (s *LandingBeaconService) AppendToken(destination string, clickID uuid.UUID) string {
+	u, err := url.Parse(destination)
+	if err != nil {
+		return destination
+	}
+	query := u.Query()
+	query.Set(BeaconTokenParam, s.IssueToken(clickID))
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func (s *LandingBeaconService) sign(data string) string {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:16])
+}
+
+// ============================================
+// BEACONS
+// ============================================
+
+// BeaconRequest is the payload posted by the web SDK
+type BeaconRequest struct {
+	Token        string `json:"token"`
+	Event        string `json:"event"` // view | leave
+	PageURL      string `json:"page_url"`
+	Referrer     string `json:"referrer"`
+	Visible      bool   `json:"visible"`
+	VisibleMs    int    `json:"visible_ms"`
+	TimeOnPageMs int    `json:"time_on_page_ms"`
+	ViewportW    int    `json:"viewport_w"`
+	ViewportH    int    `json:"viewport_h"`
+	InIframe     bool   `json:"in_iframe"`
+}
+
+// RecordBeacon stores (or updates) the beacon for a click and returns it.
+// Several beacons for the same click are merged, keeping the strongest signals.
+func (s *LandingBeaconService) RecordBeacon(req *BeaconRequest, ip, ua string) (*models.LandingBeacon, error) {
+	clickID, err := s.ParseToken(req.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	var click models.Click
+	if err := s.db.Preload("UserOffer").First(&click, "id = ?", clickID).Error; err != nil {
+		return nil, ErrBeaconInvalidToken
+	}
+	if click.UserOffer == nil {
+		return nil, ErrBeaconInvalidToken
+	}
+
+	var beacon models.LandingBeacon
+	isNew := false
+	if err := s.db.Where("click_id = ?", clickID).First(&beacon).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		isNew = true
+		beacon = models.LandingBeacon{
+			TenantModel: models.TenantModel{TenantID: click.TenantID},
+			ID:          uuid.New(),
+			ClickID:     clickID,
+			UserOfferID: click.UserOfferID,
+			UserID:      click.UserOffer.UserID,
+			IPAddress:   ip,
+			UserAgent:   ua,
+			PageURL:     truncate(req.PageURL, 2048),
+			Referrer:    truncate(req.Referrer, 2048),
+			ReceivedAt:  time.Now().UTC(),
+		}
+	}
+
+	beacon.Visible = beacon.Visible || req.Visible
+	beacon.VisibleMs = maxInt(beacon.VisibleMs, clampInt(req.VisibleMs, 0, 24*3600*1000))
+	beacon.TimeOnPageMs = maxInt(beacon.TimeOnPageMs, clampInt(req.TimeOnPageMs, 0, 24*3600*1000))
+	beacon.ViewportW = maxInt(beacon.ViewportW, clampInt(req.ViewportW, 0, 100000))
+	beacon.ViewportH = maxInt(beacon.ViewportH, clampInt(req.ViewportH, 0, 100000))
+	beacon.InIframe = beacon.InIframe || req.InIframe
+	beacon.UpdatedAt = time.Now().UTC()
+
+	flags := evaluateBeacon(&beacon)
+	beacon.Verified = len(flags) == 0
+	flagsJSON, _ := json.Marshal(flags)
+	beacon.Flags = string(flagsJSON)
+
+	if isNew {
+		err = s.db.Create(&beacon).Error
+	} else {
+		err = s.db.Save(&beacon).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Iframe and pixel loads are definitive stuffing signals - flag the click once
+	definitive := make([]string, 0, 2)
+	for _, flag := range flags {
+		if flag == BeaconFlagIframe || flag == BeaconFlagTinyViewport {
+			definitive = append(definitive, flag)
+		}
+	}
+	if len(definitive) > 0 && !clickHasFlags(click.FraudFlags, definitive) {
+		s.AddClickFlags(clickID, definitive, 40)
+		s.observability.LogFraud(ip, ua, "cookie_stuffing:"+strings.Join(definitive, ","), 80, 0.9,
+			append([]string{"cookie_stuffing"}, definitive...),
+			map[string]interface{}{
+				"click_id":      clickID.String(),
+				"user_offer_id": click.UserOfferID.String(),
+				"viewport":      fmt.Sprintf("%dx%d", beacon.ViewportW, beacon.ViewportH),
+				"page_url":      beacon.PageURL,
+			})
+	}
+
+	return &beacon, nil
+}
+
+// evaluateBeacon returns the reasons a beacon does not count as a real view
+func evaluateBeacon(b *models.LandingBeacon) []string {
+	flags := make([]string, 0, 4)
+	if b.InIframe {
+		flags = append(flags, BeaconFlagIframe)
+	}
+	if b.ViewportW > 0 && b.ViewportH > 0 && (b.ViewportW < BeaconMinViewportPx || b.ViewportH < BeaconMinViewportPx) {
+		flags = append(flags, BeaconFlagTinyViewport)
+	}
+	if !b.Visible {
+		flags = append(flags, BeaconFlagHidden)
+	} else if b.VisibleMs < BeaconMinVisibleMs {
+		flags = append(flags, BeaconFlagShortVisit)
+	}
+	return flags
+}
+
+// ============================================
+// CLICK FLAGS
+// ============================================
+
+// AddClickFlags appends fraud flags to a click and raises its fraud score
+func (s *LandingBeaconService) AddClickFlags(clickID uuid.UUID, flags []string, addScore float64) {
+	flagsJSON, _ := json.Marshal(flags)
+	if err := s.db.Exec(`
+		UPDATE clicks
+		SET fraud_flags = COALESCE(fraud_flags, '[]'::jsonb) || ?::jsonb,
+			fraud_score = LEAST(100, COALESCE(fraud_score, 0) + ?)
+		WHERE id = ?
+	`, string(flagsJSON), addScore, clickID).Error; err != nil {
+		log.Printf("[Beacon] failed to flag click %s: %v", clickID, err)
+	}
+}
+
+// clickHasFlags reports whether all flags are already present on a click
+func clickHasFlags(raw string, flags []string) bool {
+	var existing []string
+	if json.Unmarshal([]byte(raw), &existing) != nil {
+		return false
+	}
+	set := make(map[string]bool, len(existing))
+	for _, f := range existing {
+		set[f] = true
+	}
+	for _, f := range flags {
+		if !set[f] {
+			return false
+		}
+	}
+	return true
+}
+
+// SweepMissingBeacons flags clicks on beacon-enabled offers that never
+// produced a beacon within the grace period
+func (s *LandingBeaconService) SweepMissingBeacons() (int64, error) {
+	now := time.Now().UTC()
+	result := s.db.Exec(`
+		UPDATE clicks
+		SET fraud_flags = COALESCE(fraud_flags, '[]'::jsonb) || '["no_beacon"]'::jsonb,
+			fraud_score = LEAST(100, COALESCE(fraud_score, 0) + 15)
+		WHERE clicked_at >= ? AND clicked_at < ?
+			AND user_offer_id IN (
+				SELECT uo.id FROM user_offers uo
+				JOIN offers o ON o.id = uo.offer_id
+				WHERE o.beacon_enabled = true
+			)
+			AND NOT EXISTS (SELECT 1 FROM landing_beacons lb WHERE lb.click_id = clicks.id)
+			AND NOT (COALESCE(fraud_flags, '[]'::jsonb) @> '["no_beacon"]'::jsonb)
+	`, now.Add(-24*time.Hour), now.Add(-BeaconGracePeriod))
+	return result.RowsAffected, result.Error
+}
+
+// StartSweeper periodically flags clicks with missing beacons
+func (s *LandingBeaconService) StartSweeper() {
+	go func() {
+		ticker := time.NewTicker(BeaconSweepInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if flagged, err := s.SweepMissingBeacons(); err != nil {
+				log.Printf("[Beacon] sweep failed: %v", err)
+			} else if flagged > 0 {
+				log.Printf("[Beacon] flagged %d clicks without a landing beacon", flagged)
+			}
+		}
+	}()
+}
+
+// ============================================
+// REFERRER CHECK
+// ============================================
+
+// CheckReferrer reports whether a click referrer falls outside the promoter's
+// declared traffic sources. Promoters without declared sources and clicks
+// without a referrer are not checked.
+func (s *LandingBeaconService) CheckReferrer(userID uuid.UUID, referrer string) (bool, string) {
+	if referrer == "" {
+		return false, ""
+	}
+	ref, err := url.Parse(referrer)
+	if err != nil || ref.Hostname() == "" {
+		return false, ""
+	}
+	host := strings.ToLower(strings.TrimPrefix(ref.Hostname(), "www."))
+
+	var user models.AfftokUser
+	if err := s.db.Select("id, traffic_sources").First(&user, "id = ?", userID).Error; err != nil {
+		return false, host
+	}
+
+	sources := ParseTrafficSources(user.TrafficSources)
+	if len(sources) == 0 {
+		return false, host
+	}
+
+	for _, source := range sources {
+		if host == source || strings.HasSuffix(host, "."+source) {
+			return false, host
+		}
+	}
+	return true, host
+}
+
+// ParseTrafficSources normalises a stored traffic source list to bare hostnames
+func ParseTrafficSources(raw string) []string {
+	if raw == "" {
+		return nil
+	}
+	var entries []string
+	if json.Unmarshal([]byte(raw), &entries) != nil {
+		return nil
+	}
+	return NormalizeTrafficSources(entries)
+}
+
+// NormalizeTrafficSources turns URLs/hosts into lower-case hostnames without "www."
+func NormalizeTrafficSources(entries []string) []string {
+	sources := make([]string, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(strings.ToLower(entry))
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "://") {
+			entry = "https://" + entry
+		}
+		u, err := url.Parse(entry)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		host := strings.TrimPrefix(u.Hostname(), "www.")
+		if !seen[host] {
+			seen[host] = true
+			sources = append(sources, host)
+		}
+	}
+	return sources
+}
+
+// ============================================
+// VERIFIED VISIT RATE
+// ============================================
+
+// VerifiedVisitStats is a promoter's beacon coverage over the rate window
+type VerifiedVisitStats struct {
+	UserID         uuid.UUID `json:"user_id"`
+	BeaconClicks   int64     `json:"beacon_clicks"`   // clicks on beacon-enabled offers
+	VerifiedVisits int64     `json:"verified_visits"` // clicks with a verified beacon
+	Rate           float64   `json:"rate"`
+	Sufficient     bool      `json:"sufficient"` // enough clicks for the rate to count
+	Eligible       bool      `json:"eligible"`   // leaderboard eligibility
+}
+
+// GetVerifiedVisitStats returns the verified visit rate for a promoter
+func (s *LandingBeaconService) GetVerifiedVisitStats(userID uuid.UUID) (*VerifiedVisitStats, error) {
+	ctx := context.Background()
+	cacheKey := "beacon:vvr:" + userID.String()
+	if cache.RedisClient != nil {
+		if cached, err := cache.Get(ctx, cacheKey); err == nil {
+			var stats VerifiedVisitStats
+			if json.Unmarshal([]byte(cached), &stats) == nil {
+				return &stats, nil
+			}
+		}
+	}
+
+	now := time.Now().UTC()
+	var row struct {
+		BeaconClicks   int64
+		VerifiedVisits int64
+	}
+	err := s.db.Raw(`
+		SELECT COUNT(cl.id) AS beacon_clicks,
+			COUNT(lb.id) FILTER (WHERE lb.verified) AS verified_visits
+		FROM clicks cl
+		JOIN user_offers uo ON uo.id = cl.user_offer_id
+		JOIN offers o ON o.id = uo.offer_id AND o.beacon_enabled = true
+		LEFT JOIN landing_beacons lb ON lb.click_id = cl.id
+		WHERE uo.user_id = ? AND cl.clicked_at >= ? AND cl.clicked_at < ?
+	`, userID, now.Add(-VerifiedVisitWindow), now.Add(-BeaconGracePeriod)).Scan(&row).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stats := buildVisitStats(userID, row.BeaconClicks, row.VerifiedVisits)
+
+	if cache.RedisClient != nil {
+		if data, err := json.Marshal(stats); err == nil {
+			cache.Set(ctx, cacheKey, string(data), verifiedVisitCacheTTL)
+		}
+	}
+
+	return stats, nil
+}
+
+func buildVisitStats(userID uuid.UUID, clicks, verified int64) *VerifiedVisitStats {
+	stats := &VerifiedVisitStats{
+		UserID:         userID,
+		BeaconClicks:   clicks,
+		VerifiedVisits: verified,
+		Eligible:       true,
+	}
+	if clicks > 0 {
+		stats.Rate = round2(float64(verified) / float64(clicks))
+	}
+	stats.Sufficient = clicks >= VerifiedVisitMinClicks
+	if stats.Sufficient && stats.Rate < VerifiedVisitMinRate {
+		stats.Eligible = false
+	}
+	return stats
+}
+
+// FraudPenalty returns the fraud score to add for a promoter with a low
+// verified visit rate (0 when the rate is healthy or not yet measurable)
+func (s *LandingBeaconService) FraudPenalty(userID uuid.UUID) float64 {
+	stats, err := s.GetVerifiedVisitStats(userID)
+	if err != nil || !stats.Sufficient || stats.Rate >= VerifiedVisitMinRate {
+		return 0
+	}
+	return round2((VerifiedVisitMinRate - stats.Rate) / VerifiedVisitMinRate * verifiedVisitMaxPenalty)
+}
+
+// IneligiblePromoterIDs returns promoters excluded from the leaderboard for a
+// low verified visit rate
+func (s *LandingBeaconService) IneligiblePromoterIDs() []uuid.UUID {
+	s.mu.RLock()
+	if time.Now().Before(s.ineligibleUntil) {
+		ids := make([]uuid.UUID, 0, len(s.ineligible))
+		for id := range s.ineligible {
+			ids = append(ids, id)
+		}
+		s.mu.RUnlock()
+		return ids
+	}
+	s.mu.RUnlock()
+
+	now := time.Now().UTC()
+	var rows []struct {
+		UserID         uuid.UUID
+		BeaconClicks   int64
+		VerifiedVisits int64
+	}
+	if err := s.db.Raw(`
+		SELECT uo.user_id,
+			COUNT(cl.id) AS beacon_clicks,
+			COUNT(lb.id) FILTER (WHERE lb.verified) AS verified_visits
+		FROM clicks cl
+		JOIN user_offers uo ON uo.id = cl.user_offer_id
+		JOIN offers o ON o.id = uo.offer_id AND o.beacon_enabled = true
+		LEFT JOIN landing_beacons lb ON lb.click_id = cl.id
+		WHERE cl.clicked_at >= ? AND cl.clicked_at < ?
+		GROUP BY uo.user_id
+		HAVING COUNT(cl.id) >= ?
+	`, now.Add(-VerifiedVisitWindow), now.Add(-BeaconGracePeriod), VerifiedVisitMinClicks).Scan(&rows).Error; err != nil {
+		log.Printf("[Beacon] failed to compute leaderboard eligibility: %v", err)
+		return nil
+	}
+
+	ineligible := make(map[uuid.UUID]bool)
+	ids := make([]uuid.UUID, 0)
+	for _, row := range rows {
+		if !buildVisitStats(row.UserID, row.BeaconClicks, row.VerifiedVisits).Eligible {
+			ineligible[row.UserID] = true
+			ids = append(ids, row.UserID)
+		}
+	}
+
+	s.mu.Lock()
+	s.ineligible = ineligible
+	s.ineligibleUntil = time.Now().Add(verifiedVisitCacheTTL)
+	s.mu.Unlock()
+
+	return ids
+}
+
+// truncate limits a string to n bytes
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// ============================================
+// GLOBAL INSTANCE
+// ============================================
+
+var (
+	landingBeaconInstance *LandingBeaconService
+	landingBeaconOnce     sync.Once
+)
+
+// GetLandingBeaconService returns the global landing beacon service
+func GetLandingBeaconService(db *gorm.DB) *LandingBeaconService {
+	landingBeaconOnce.Do(func() {
+		landingBeaconInstance = NewLandingBeaconService(db)
+	})
+	return landingBeaconInstance
+}
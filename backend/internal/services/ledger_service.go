@@ -0,0 +1,1440 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ============================================
+// EARNINGS LEDGER SERVICE
+// ============================================
+// The ledger is the source of truth for money. Conversion lifecycle events
+// post balanced transactions; promoter balances, payouts and invoices are
+// derived from the postings. The per-currency earnings counters
+// (earnings_counters) are only updated here, in the same database
+// transaction as the posting.
+
+// DefaultPlatformFeeBps is the platform fee charged to advertisers on top of
+// the promoter commission where no fee rule applies, in basis points
+// (1000 = 10%)
+const DefaultPlatformFeeBps = 1000
+
+// Reference types recorded on ledger transactions
+const (
+	LedgerReferenceConversion = "conversion"
+	LedgerReferencePayout     = "payout"
+	LedgerReferenceInvoice    = "invoice"
+	LedgerReferenceWallet     = "wallet"
+	LedgerReferenceManual     = "manual"
+)
+
+// Ledger errors
+var (
+	ErrLedgerUnbalanced     = errors.New("ledger postings do not balance")
+	ErrLedgerInvalidPosting = errors.New("invalid ledger posting")
+)
+
+// LedgerPosting is one side of a transaction before it is written
+type LedgerPosting struct {
+	AccountType models.LedgerAccountType `json:"account_type"`
+	OwnerID     uuid.UUID                `json:"owner_id"`
+	Amount      int64                    `json:"amount"` // minor units, + debit / - credit
+}
+
+// LedgerTransactionRequest describes a transaction to post
+type LedgerTransactionRequest struct {
+	TenantID       uuid.UUID
+	Kind           models.LedgerTransactionKind
+	IdempotencyKey string
+	ReferenceType  string
+	ReferenceID    uuid.UUID
+	ReversesID     *uuid.UUID
+	AdvertiserID   *uuid.UUID
+	PromoterID     *uuid.UUID
+	OfferID        *uuid.UUID
+	UserOfferID    *uuid.UUID
+	Currency       string
+	FeeRuleID      *uuid.UUID
+	Description    string
+	OccurredAt     time.Time
+	CreatedBy      *uuid.UUID
+	Postings       []LedgerPosting
+
+	// ReportIn lists the reporting currencies of the accounts involved; the
+	// tenant's reporting currency and USD are always snapshotted
+	ReportIn []string
+}
+
+// LedgerService posts and queries the earnings ledger
+type LedgerService struct {
+	db        *gorm.DB
+	fx        *FXService
+	wallets   *AdvertiserWalletService
+	fees      *FeeRuleService
+	referrals *ReferralService
+}
+
+var (
+	ledgerServiceInstance *LedgerService
+	ledgerServiceOnce     sync.Once
+)
+
+// GetLedgerService returns the singleton ledger service
+func GetLedgerService(db *gorm.DB) *LedgerService {
+	ledgerServiceOnce.Do(func() {
+		ledgerServiceInstance = NewLedgerService(db)
+		ledgerServiceInstance.SetFXService(GetFXService(db))
+		ledgerServiceInstance.SetWalletService(GetAdvertiserWalletService(db))
+		ledgerServiceInstance.SetFeeRuleService(GetFeeRuleService(db))
+		ledgerServiceInstance.SetReferralService(GetReferralService(db))
+	})
+	return ledgerServiceInstance
+}
+
+// NewLedgerService creates a new ledger service. Without an FX service
+// postings carry no rate snapshot.
+func NewLedgerService(db *gorm.DB) *LedgerService {
+	return &LedgerService{db: db}
+}
+
+// SetFXService sets the FX service used for rate snapshots and reporting
+func (s *LedgerService) SetFXService(fx *FXService) {
+	s.fx = fx
+}
+
+// SetWalletService sets the prepaid wallets approved conversions are charged
+// to. Without it every advertiser is invoiced monthly.
+func (s *LedgerService) SetWalletService(wallets *AdvertiserWalletService) {
+	s.wallets = wallets
+}
+
+// SetFeeRuleService sets the fee rules conversions are charged by. Without
+// it every conversion pays DefaultPlatformFeeBps on top.
+func (s *LedgerService) SetFeeRuleService(fees *FeeRuleService) {
+	s.fees = fees
+}
+
+// SetReferralService sets the referrals override commissions are paid on.
+// Without it approvals post no overrides.
+func (s *LedgerService) SetReferralService(referrals *ReferralService) {
+	s.referrals = referrals
+}
+
+// ============================================
+// POSTING MATH
+// ============================================
+
+// PlatformFee returns the fee in basis points of a commission, rounded half up
+func PlatformFee(commission, feeBps int64) int64 {
+	if commission <= 0 || feeBps <= 0 {
+		return 0
+	}
+	return (commission*feeBps + 5000) / 10000
+}
+
+// ValidateLedgerPostings checks that a transaction has at least two non-zero
+// postings and that they sum to zero
+func ValidateLedgerPostings(postings []LedgerPosting) error {
+	if len(postings) < 2 {
+		return fmt.Errorf("%w: a transaction needs at least two postings", ErrLedgerInvalidPosting)
+	}
+	var total int64
+	for _, p := range postings {
+		if p.Amount == 0 {
+			return fmt.Errorf("%w: zero amount on %s", ErrLedgerInvalidPosting, p.AccountType)
+		}
+		if p.AccountType == "" {
+			return fmt.Errorf("%w: missing account type", ErrLedgerInvalidPosting)
+		}
+		total += p.Amount
+	}
+	if total != 0 {
+		return fmt.Errorf("%w: off by %d", ErrLedgerUnbalanced, total)
+	}
+	return nil
+}
+
+// BuildConversionPostings returns the postings of an approved conversion:
+// the advertiser owes commission + fee, the promoter is owed the commission
+// and the platform earns the fee. A missing advertiser is booked on the
+// tenant's unassigned receivable (uuid.Nil). A revenue share may leave the
+// promoter nothing.
+func BuildConversionPostings(advertiserID, promoterID uuid.UUID, commission, fee int64) []LedgerPosting {
+	postings := []LedgerPosting{
+		{AccountType: models.LedgerAccountAdvertiserReceivable, OwnerID: advertiserID, Amount: commission + fee},
+	}
+	if commission > 0 {
+		postings = append(postings, LedgerPosting{AccountType: models.LedgerAccountPromoterPayable, OwnerID: promoterID, Amount: -commission})
+	}
+	if fee > 0 {
+		postings = append(postings, LedgerPosting{AccountType: models.LedgerAccountPlatformRevenue, OwnerID: uuid.Nil, Amount: -fee})
+	}
+	return postings
+}
+
+// BuildOverridePostings returns the postings of an override: the platform
+// pays it out of its fee revenue to the beneficiary's payable
+func BuildOverridePostings(beneficiaryID uuid.UUID, amount int64) []LedgerPosting {
+	return []LedgerPosting{
+		{AccountType: models.LedgerAccountPlatformRevenue, OwnerID: uuid.Nil, Amount: amount},
+		{AccountType: models.LedgerAccountPromoterPayable, OwnerID: beneficiaryID, Amount: -amount},
+	}
+}
+
+// ReversePostings negates postings
+func ReversePostings(postings []LedgerPosting) []LedgerPosting {
+	reversed := make([]LedgerPosting, len(postings))
+	for i, p := range postings {
+		reversed[i] = LedgerPosting{AccountType: p.AccountType, OwnerID: p.OwnerID, Amount: -p.Amount}
+	}
+	return reversed
+}
+
+// ============================================
+// POSTING
+// ============================================
+
+// Post writes a balanced transaction inside tx. Posting the same idempotency
+// key twice returns the existing transaction with created=false.
+func (s *LedgerService) Post(tx *gorm.DB, req LedgerTransactionRequest) (*models.LedgerTransaction, bool, error) {
+	if req.IdempotencyKey == "" {
+		return nil, false, fmt.Errorf("%w: missing idempotency key", ErrLedgerInvalidPosting)
+	}
+	if req.TenantID == uuid.Nil {
+		req.TenantID = models.DefaultTenantID
+	}
+	currency := NormalizeCurrency(req.Currency)
+	if len(currency) != 3 {
+		return nil, false, fmt.Errorf("%w: currency %q", ErrLedgerInvalidPosting, req.Currency)
+	}
+	if err := ValidateLedgerPostings(req.Postings); err != nil {
+		return nil, false, err
+	}
+	if req.OccurredAt.IsZero() {
+		req.OccurredAt = time.Now().UTC()
+	}
+
+	txn := models.LedgerTransaction{
+		ID:             uuid.New(),
+		TenantID:       req.TenantID,
+		Kind:           req.Kind,
+		IdempotencyKey: req.IdempotencyKey,
+		ReferenceType:  req.ReferenceType,
+		ReferenceID:    req.ReferenceID,
+		ReversesID:     req.ReversesID,
+		AdvertiserID:   req.AdvertiserID,
+		PromoterID:     req.PromoterID,
+		OfferID:        req.OfferID,
+		UserOfferID:    req.UserOfferID,
+		Currency:       currency,
+		FeeRuleID:      req.FeeRuleID,
+		Description:    truncate(req.Description, 255),
+		OccurredAt:     req.OccurredAt,
+		CreatedBy:      req.CreatedBy,
+	}
+	s.snapshotFX(&txn, req.ReportIn)
+	result := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "idempotency_key"}}, DoNothing: true}).
+		Omit("Entries").Create(&txn)
+	if result.Error != nil {
+		return nil, false, fmt.Errorf("failed to post ledger transaction: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		var existing models.LedgerTransaction
+		if err := tx.Preload("Entries").Where("idempotency_key = ?", req.IdempotencyKey).First(&existing).Error; err != nil {
+			return nil, false, err
+		}
+		return &existing, false, nil
+	}
+
+	entries := make([]models.LedgerEntry, 0, len(req.Postings))
+	for _, p := range req.Postings {
+		account, err := s.account(tx, req.TenantID, p.AccountType, p.OwnerID, currency)
+		if err != nil {
+			return nil, false, err
+		}
+		entries = append(entries, models.LedgerEntry{
+			ID:            uuid.New(),
+			TenantID:      req.TenantID,
+			TransactionID: txn.ID,
+			AccountID:     account.ID,
+			Amount:        p.Amount,
+			Currency:      currency,
+		})
+	}
+	if err := tx.Create(&entries).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to post ledger entries: %w", err)
+	}
+	txn.Entries = entries
+	return &txn, true, nil
+}
+
+// snapshotFX stores the rates from the transaction currency into the
+// reporting currencies at posting time. Missing rates never block a posting;
+// reports fall back to the stored daily rates.
+func (s *LedgerService) snapshotFX(txn *models.LedgerTransaction, reportIn []string) {
+	if s.fx == nil {
+		return
+	}
+	targets := append([]string{FXPivotCurrency, GetTenantSettingsResolver(s.db).Get(txn.TenantID).ReportingCurrency}, reportIn...)
+	rates := s.fx.Snapshot(txn.Currency, targets, txn.OccurredAt)
+	if raw, err := json.Marshal(rates); err == nil {
+		day := fxDay(txn.OccurredAt)
+		txn.FXRates = datatypes.JSON(raw)
+		txn.FXRateDate = &day
+	}
+}
+
+// reportingCurrencies returns the reporting currencies of the given accounts
+func (s *LedgerService) reportingCurrencies(tenantID uuid.UUID, userIDs ...uuid.UUID) []string {
+	if s.fx == nil {
+		return nil
+	}
+	currencies := make([]string, 0, len(userIDs))
+	for _, id := range userIDs {
+		if id != uuid.Nil {
+			currencies = append(currencies, s.fx.ReportingCurrency(tenantID, id))
+		}
+	}
+	return currencies
+}
+
+// account returns (creating on first use) a tenant ledger account
+func (s *LedgerService) account(tx *gorm.DB, tenantID uuid.UUID, accountType models.LedgerAccountType, ownerID uuid.UUID, currency string) (*models.LedgerAccount, error) {
+	account := models.LedgerAccount{
+		ID:       uuid.New(),
+		TenantID: tenantID,
+		Type:     accountType,
+		OwnerID:  ownerID,
+		Currency: currency,
+	}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "type"}, {Name: "owner_id"}, {Name: "currency"}},
+		DoNothing: true,
+	}).Create(&account).Error; err != nil {
+		return nil, fmt.Errorf("failed to open ledger account: %w", err)
+	}
+	if err := tx.Where("tenant_id = ? AND type = ? AND owner_id = ? AND currency = ?",
+		tenantID, accountType, ownerID, currency).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// conversionParties loads the promoter, offer and advertiser of a conversion
+type conversionParties struct {
+	UserID              uuid.UUID
+	OfferID             uuid.UUID
+	AdvertiserID        *uuid.UUID
+	PromoterConversions int
+}
+
+func (s *LedgerService) conversionParties(tx *gorm.DB, userOfferID uuid.UUID) (*conversionParties, error) {
+	var parties conversionParties
+	err := tx.Table("user_offers uo").
+		Select("uo.user_id, uo.offer_id, o.advertiser_id, COALESCE(u.total_conversions, 0) AS promoter_conversions").
+		Joins("JOIN offers o ON o.id = uo.offer_id").
+		Joins("LEFT JOIN afftok_users u ON u.id = uo.user_id").
+		Where("uo.id = ?", userOfferID).
+		Take(&parties).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load conversion parties: %w", err)
+	}
+	return &parties, nil
+}
+
+func conversionApprovedKey(conversionID uuid.UUID) string {
+	return "conversion_approved:" + conversionID.String()
+}
+
+func conversionReversedKey(conversionID uuid.UUID) string {
+	return "conversion_reversed:" + conversionID.String()
+}
+
+func overrideEarnedKey(conversionID, beneficiaryID uuid.UUID) string {
+	return "override_earned:" + conversionID.String() + ":" + beneficiaryID.String()
+}
+
+func overrideReversedKey(conversionID, beneficiaryID uuid.UUID) string {
+	return "override_reversed:" + conversionID.String() + ":" + beneficiaryID.String()
+}
+
+// PostConversionApproved books an approved conversion and credits the
+// promoter's earnings counters. Conversions without commission post nothing.
+func (s *LedgerService) PostConversionApproved(tx *gorm.DB, conversion *models.Conversion) (*models.LedgerTransaction, error) {
+	return s.postConversionApproved(tx, conversion, true)
+}
+
+// postConversionApproved books the approval. Live approvals also charge the
+// advertiser's wallet and post overrides; bookings of conversions approved
+// before the ledger existed only credit the earnings counters.
+func (s *LedgerService) postConversionApproved(tx *gorm.DB, conversion *models.Conversion, live bool) (*models.LedgerTransaction, error) {
+	commission := WholeToMinor(int64(conversion.Commission), conversion.Currency)
+	if commission <= 0 {
+		return nil, nil
+	}
+	parties, err := s.conversionParties(tx, conversion.UserOfferID)
+	if err != nil {
+		return nil, err
+	}
+
+	advertiserID := uuid.Nil
+	if parties.AdvertiserID != nil {
+		advertiserID = *parties.AdvertiserID
+	}
+	occurredAt := time.Now().UTC()
+	if conversion.ApprovedAt != nil {
+		occurredAt = *conversion.ApprovedAt
+	}
+
+	conversionID, userOfferID := conversion.ID, conversion.UserOfferID
+	quote, err := s.conversionFee(tx, conversion, parties, commission, occurredAt)
+	if err != nil {
+		return nil, err
+	}
+	txn, created, err := s.Post(tx, LedgerTransactionRequest{
+		TenantID:       conversion.TenantID,
+		Kind:           models.LedgerKindConversionApproved,
+		IdempotencyKey: conversionApprovedKey(conversion.ID),
+		ReferenceType:  LedgerReferenceConversion,
+		ReferenceID:    conversionID,
+		AdvertiserID:   parties.AdvertiserID,
+		PromoterID:     &parties.UserID,
+		OfferID:        &parties.OfferID,
+		UserOfferID:    &userOfferID,
+		Currency:       conversion.Currency,
+		FeeRuleID:      quote.RuleID,
+		Description:    "Conversion approved",
+		OccurredAt:     occurredAt,
+		Postings:       BuildConversionPostings(advertiserID, parties.UserID, quote.Commission, quote.Fee),
+		ReportIn:       s.reportingCurrencies(conversion.TenantID, parties.UserID, advertiserID),
+	})
+	if err != nil || !created {
+		return txn, err
+	}
+	if err := s.applyEarnings(tx, conversion.TenantID, parties.UserID, userOfferID, quote.Commission, conversion.Currency); err != nil {
+		return nil, err
+	}
+	if !live {
+		return txn, nil
+	}
+	// Prepaid advertisers pay the conversion from their wallet right away
+	if s.wallets != nil {
+		if err := s.wallets.ChargeConversion(tx, conversion, advertiserID, NewMoney(quote.Charge, conversion.Currency)); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.postOverrides(tx, txn, quote); err != nil {
+		return nil, err
+	}
+	return txn, nil
+}
+
+// postOverrides books the referral and team overrides earned on an approved
+// conversion, each as its own transaction referencing the conversion
+func (s *LedgerService) postOverrides(tx *gorm.DB, source *models.LedgerTransaction, quote FeeQuote) error {
+	if s.referrals == nil || source.PromoterID == nil {
+		return nil
+	}
+	shares, err := s.referrals.Overrides(tx, source.TenantID, *source.PromoterID, quote.Commission, quote.Fee, source.OccurredAt)
+	if err != nil {
+		return err
+	}
+	for _, share := range shares {
+		beneficiaryID := share.BeneficiaryID
+		_, created, err := s.Post(tx, LedgerTransactionRequest{
+			TenantID:       source.TenantID,
+			Kind:           models.LedgerKindOverrideEarned,
+			IdempotencyKey: overrideEarnedKey(source.ReferenceID, beneficiaryID),
+			ReferenceType:  LedgerReferenceConversion,
+			ReferenceID:    source.ReferenceID,
+			AdvertiserID:   source.AdvertiserID,
+			PromoterID:     &beneficiaryID,
+			OfferID:        source.OfferID,
+			Currency:       source.Currency,
+			Description:    share.Description(),
+			OccurredAt:     source.OccurredAt,
+			Postings:       BuildOverridePostings(beneficiaryID, share.Amount),
+			ReportIn:       s.reportingCurrencies(source.TenantID, beneficiaryID),
+		})
+		if err != nil {
+			return err
+		}
+		if created {
+			if err := s.applyEarnings(tx, source.TenantID, beneficiaryID, uuid.Nil, share.Amount, source.Currency); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reverseOverrides negates every override posted on a conversion
+func (s *LedgerService) reverseOverrides(tx *gorm.DB, conversionID uuid.UUID, reason string, by *uuid.UUID) error {
+	var overrides []models.LedgerTransaction
+	if err := tx.Preload("Entries").
+		Where("reference_type = ? AND reference_id = ? AND kind = ?", LedgerReferenceConversion, conversionID, models.LedgerKindOverrideEarned).
+		Find(&overrides).Error; err != nil {
+		return err
+	}
+	for _, original := range overrides {
+		if original.PromoterID == nil {
+			continue
+		}
+		postings, err := s.postingsOf(tx, original.Entries)
+		if err != nil {
+			return err
+		}
+		var amount int64
+		for _, p := range postings {
+			if p.AccountType == models.LedgerAccountPromoterPayable {
+				amount -= p.Amount
+			}
+		}
+		originalID := original.ID
+		_, created, err := s.Post(tx, LedgerTransactionRequest{
+			TenantID:       original.TenantID,
+			Kind:           models.LedgerKindOverrideReversed,
+			IdempotencyKey: overrideReversedKey(conversionID, *original.PromoterID),
+			ReferenceType:  LedgerReferenceConversion,
+			ReferenceID:    conversionID,
+			ReversesID:     &originalID,
+			AdvertiserID:   original.AdvertiserID,
+			PromoterID:     original.PromoterID,
+			OfferID:        original.OfferID,
+			Currency:       original.Currency,
+			Description:    reason,
+			CreatedBy:      by,
+			Postings:       ReversePostings(postings),
+			ReportIn:       s.reportingCurrencies(original.TenantID, *original.PromoterID),
+		})
+		if err != nil {
+			return err
+		}
+		if created {
+			if err := s.applyEarnings(tx, original.TenantID, *original.PromoterID, uuid.Nil, -amount, original.Currency); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// conversionFee applies the fee rule in effect when the conversion was
+// approved. A revenue share credits the promoter less than the commission.
+func (s *LedgerService) conversionFee(tx *gorm.DB, conversion *models.Conversion, parties *conversionParties, commission int64, at time.Time) (FeeQuote, error) {
+	if s.fees == nil {
+		return ComputeFee(nil, commission, FeeVolume{Conversions: 1, Spend: commission}, conversion.Currency), nil
+	}
+	promoter := models.AfftokUser{TotalConversions: parties.PromoterConversions}
+	return s.fees.Quote(tx, FeeTarget{
+		TenantID:     conversion.TenantID,
+		PromoterTier: promoter.UserLevel(),
+		AdvertiserID: derefUUID(parties.AdvertiserID),
+		OfferID:      parties.OfferID,
+	}, conversion.Currency, commission, at)
+}
+
+// PostConversionReversed negates a conversion's approval posting and debits
+// the promoter's earnings counters. Credited conversions approved before the
+// ledger existed are booked first; conversions never credited post nothing.
+func (s *LedgerService) PostConversionReversed(tx *gorm.DB, conversionID uuid.UUID, reason string, by *uuid.UUID) (*models.LedgerTransaction, error) {
+	var original models.LedgerTransaction
+	err := tx.Preload("Entries").Where("idempotency_key = ?", conversionApprovedKey(conversionID)).First(&original).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var conversion models.Conversion
+		if err := tx.First(&conversion, "id = ?", conversionID).Error; err != nil {
+			return nil, err
+		}
+		if conversion.Status != models.ConversionStatusApproved && conversion.Status != models.ConversionStatusPaid {
+			return nil, nil
+		}
+		if conversion.ApprovedAt == nil {
+			conversion.ApprovedAt = &conversion.ConvertedAt
+		}
+		booked, err := s.postConversionApproved(tx, &conversion, false)
+		if err != nil || booked == nil {
+			return nil, err
+		}
+		original = *booked
+	} else if err != nil {
+		return nil, err
+	}
+
+	postings, err := s.postingsOf(tx, original.Entries)
+	if err != nil {
+		return nil, err
+	}
+	var commission int64
+	for _, p := range postings {
+		if p.AccountType == models.LedgerAccountPromoterPayable {
+			commission -= p.Amount
+		}
+	}
+
+	originalID := original.ID
+	txn, created, err := s.Post(tx, LedgerTransactionRequest{
+		TenantID:       original.TenantID,
+		Kind:           models.LedgerKindConversionReversed,
+		IdempotencyKey: conversionReversedKey(conversionID),
+		ReferenceType:  LedgerReferenceConversion,
+		ReferenceID:    conversionID,
+		ReversesID:     &originalID,
+		AdvertiserID:   original.AdvertiserID,
+		PromoterID:     original.PromoterID,
+		OfferID:        original.OfferID,
+		UserOfferID:    original.UserOfferID,
+		Currency:       original.Currency,
+		FeeRuleID:      original.FeeRuleID,
+		Description:    reason,
+		CreatedBy:      by,
+		Postings:       ReversePostings(postings),
+		ReportIn:       s.reportingCurrencies(original.TenantID, derefUUID(original.PromoterID), derefUUID(original.AdvertiserID)),
+	})
+	if err != nil || !created {
+		return txn, err
+	}
+	if s.wallets != nil {
+		if err := s.wallets.RefundConversion(tx, conversionID, reason); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.reverseOverrides(tx, conversionID, reason, by); err != nil {
+		return nil, err
+	}
+	if original.PromoterID == nil || original.UserOfferID == nil {
+		return txn, nil
+	}
+	return txn, s.applyEarnings(tx, original.TenantID, *original.PromoterID, *original.UserOfferID, -commission, original.Currency)
+}
+
+func derefUUID(id *uuid.UUID) uuid.UUID {
+	if id == nil {
+		return uuid.Nil
+	}
+	return *id
+}
+
+// postingsOf maps stored entries back to account-typed postings
+func (s *LedgerService) postingsOf(tx *gorm.DB, entries []models.LedgerEntry) ([]LedgerPosting, error) {
+	ids := make([]uuid.UUID, len(entries))
+	for i, e := range entries {
+		ids[i] = e.AccountID
+	}
+	var accounts []models.LedgerAccount
+	if err := tx.Where("id IN ?", ids).Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]models.LedgerAccount, len(accounts))
+	for _, a := range accounts {
+		byID[a.ID] = a
+	}
+
+	postings := make([]LedgerPosting, 0, len(entries))
+	for _, e := range entries {
+		account, ok := byID[e.AccountID]
+		if !ok {
+			return nil, fmt.Errorf("ledger account %s not found", e.AccountID)
+		}
+		postings = append(postings, LedgerPosting{AccountType: account.Type, OwnerID: account.OwnerID, Amount: e.Amount})
+	}
+	return postings, nil
+}
+
+// applyEarnings moves the earnings counters in currency by delta (minor
+// units). Overrides have no user offer (uuid.Nil) and only move the user's
+// counter.
+func (s *LedgerService) applyEarnings(tx *gorm.DB, tenantID, userID, userOfferID uuid.UUID, delta int64, currency string) error {
+	if delta == 0 {
+		return nil
+	}
+	if err := addEarnings(tx, tenantID, models.EarningsOwnerUser, userID, delta, currency); err != nil {
+		return fmt.Errorf("failed to update user earnings: %w", err)
+	}
+	if userOfferID == uuid.Nil {
+		return nil
+	}
+	if err := addEarnings(tx, tenantID, models.EarningsOwnerUserOffer, userOfferID, delta, currency); err != nil {
+		return fmt.Errorf("failed to update user offer earnings: %w", err)
+	}
+	return nil
+}
+
+func addEarnings(tx *gorm.DB, tenantID uuid.UUID, ownerType string, ownerID uuid.UUID, delta int64, currency string) error {
+	counter := &models.EarningsCounter{
+		ID:        uuid.New(),
+		TenantID:  tenantID,
+		OwnerType: ownerType,
+		OwnerID:   ownerID,
+		Currency:  currency,
+		Amount:    delta,
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "owner_type"}, {Name: "owner_id"}, {Name: "currency"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"amount":     gorm.Expr("earnings_counters.amount + EXCLUDED.amount"),
+			"updated_at": time.Now(),
+		}),
+	}).Create(counter).Error
+}
+
+// PostAdjustment credits (amount > 0) or debits (amount < 0) a promoter's
+// payable against the adjustments account
+func (s *LedgerService) PostAdjustment(tx *gorm.DB, tenantID, promoterID uuid.UUID, amount int64, currency, reason, idempotencyKey string, by *uuid.UUID) (*models.LedgerTransaction, bool, error) {
+	if idempotencyKey == "" {
+		idempotencyKey = "adjustment:" + uuid.NewString()
+	}
+	return s.Post(tx, LedgerTransactionRequest{
+		TenantID:       tenantID,
+		Kind:           models.LedgerKindAdjustment,
+		IdempotencyKey: idempotencyKey,
+		ReferenceType:  LedgerReferenceManual,
+		PromoterID:     &promoterID,
+		Currency:       currency,
+		Description:    reason,
+		CreatedBy:      by,
+		ReportIn:       s.reportingCurrencies(tenantID, promoterID),
+		Postings: []LedgerPosting{
+			{AccountType: models.LedgerAccountAdjustments, OwnerID: uuid.Nil, Amount: amount},
+			{AccountType: models.LedgerAccountPromoterPayable, OwnerID: promoterID, Amount: -amount},
+		},
+	})
+}
+
+// PostPayoutPaid records a payout paid to a promoter. Advertisers fund
+// promoter payouts, so the net amount settles the promoter's payable against
+// the advertiser's receivable.
+func (s *LedgerService) PostPayoutPaid(tx *gorm.DB, payout *models.Payout, by *uuid.UUID) (*models.LedgerTransaction, error) {
+	amount := MajorToMinor(payout.NetAmount, payout.Currency)
+	if amount <= 0 {
+		return nil, nil
+	}
+	occurredAt := time.Now().UTC()
+	if payout.PaidAt != nil {
+		occurredAt = *payout.PaidAt
+	}
+	advertiserID, promoterID := payout.AdvertiserID, payout.PublisherID
+	txn, _, err := s.Post(tx, LedgerTransactionRequest{
+		TenantID:       payout.TenantID,
+		Kind:           models.LedgerKindPayoutPaid,
+		IdempotencyKey: "payout_paid:" + payout.ID.String(),
+		ReferenceType:  LedgerReferencePayout,
+		ReferenceID:    payout.ID,
+		AdvertiserID:   &advertiserID,
+		PromoterID:     &promoterID,
+		Currency:       payout.Currency,
+		Description:    "Payout " + payout.Period,
+		OccurredAt:     occurredAt,
+		CreatedBy:      by,
+		ReportIn:       s.reportingCurrencies(payout.TenantID, promoterID, advertiserID),
+		Postings: []LedgerPosting{
+			{AccountType: models.LedgerAccountPromoterPayable, OwnerID: promoterID, Amount: amount},
+			{AccountType: models.LedgerAccountAdvertiserReceivable, OwnerID: advertiserID, Amount: -amount},
+		},
+	})
+	return txn, err
+}
+
+// PostAdvertiserAdjustment books a manual invoice charge (amount > 0) or
+// credit (amount < 0) on the advertiser's receivable
+func (s *LedgerService) PostAdvertiserAdjustment(tx *gorm.DB, adjustment *models.InvoiceAdjustment) (*models.LedgerTransaction, error) {
+	if adjustment.Amount == 0 {
+		return nil, nil
+	}
+	advertiserID := adjustment.AdvertiserID
+	txn, _, err := s.Post(tx, LedgerTransactionRequest{
+		TenantID:       adjustment.TenantID,
+		Kind:           models.LedgerKindAdjustment,
+		IdempotencyKey: "invoice_adjustment:" + adjustment.ID.String(),
+		ReferenceType:  LedgerReferenceManual,
+		ReferenceID:    adjustment.ID,
+		AdvertiserID:   &advertiserID,
+		Currency:       adjustment.Currency,
+		Description:    adjustment.Description,
+		CreatedBy:      adjustment.CreatedBy,
+		ReportIn:       s.reportingCurrencies(adjustment.TenantID, advertiserID),
+		Postings: []LedgerPosting{
+			{AccountType: models.LedgerAccountAdvertiserReceivable, OwnerID: advertiserID, Amount: adjustment.Amount},
+			{AccountType: models.LedgerAccountAdjustments, OwnerID: uuid.Nil, Amount: -adjustment.Amount},
+		},
+	})
+	return txn, err
+}
+
+// PostInvoiceIssued books the tax charged on an issued invoice: the
+// advertiser owes it and the platform owes it to the tax authority
+func (s *LedgerService) PostInvoiceIssued(tx *gorm.DB, invoice *models.Invoice) (*models.LedgerTransaction, error) {
+	tax := MajorToMinor(invoice.TaxAmount, invoice.Currency)
+	if tax <= 0 {
+		return nil, nil
+	}
+	advertiserID := invoice.AdvertiserID
+	txn, _, err := s.Post(tx, LedgerTransactionRequest{
+		TenantID:       invoice.TenantID,
+		Kind:           models.LedgerKindInvoiceIssued,
+		IdempotencyKey: "invoice_issued:" + invoice.ID.String(),
+		ReferenceType:  LedgerReferenceInvoice,
+		ReferenceID:    invoice.ID,
+		AdvertiserID:   &advertiserID,
+		Currency:       invoice.Currency,
+		Description:    invoice.TaxName + " " + invoice.Number,
+		ReportIn:       s.reportingCurrencies(invoice.TenantID, advertiserID),
+		Postings: []LedgerPosting{
+			{AccountType: models.LedgerAccountAdvertiserReceivable, OwnerID: advertiserID, Amount: tax},
+			{AccountType: models.LedgerAccountTaxPayable, OwnerID: uuid.Nil, Amount: -tax},
+		},
+	})
+	return txn, err
+}
+
+// PostInvoicePaid records the amount an advertiser paid against its invoice
+// (platform fee, adjustments and tax): cash received settles the receivable
+func (s *LedgerService) PostInvoicePaid(tx *gorm.DB, invoice *models.Invoice, by *uuid.UUID) (*models.LedgerTransaction, error) {
+	amount := MajorToMinor(invoice.AmountDue(), invoice.Currency)
+	if amount <= 0 {
+		return nil, nil
+	}
+	advertiserID := invoice.AdvertiserID
+	description := invoice.Number
+	if description == "" {
+		description = fmt.Sprintf("Invoice %d-%02d", invoice.Year, invoice.Month)
+	}
+	txn, _, err := s.Post(tx, LedgerTransactionRequest{
+		TenantID:       invoice.TenantID,
+		Kind:           models.LedgerKindInvoicePaid,
+		IdempotencyKey: "invoice_paid:" + invoice.ID.String(),
+		ReferenceType:  LedgerReferenceInvoice,
+		ReferenceID:    invoice.ID,
+		AdvertiserID:   &advertiserID,
+		Currency:       invoice.Currency,
+		Description:    description,
+		CreatedBy:      by,
+		ReportIn:       s.reportingCurrencies(invoice.TenantID, advertiserID),
+		Postings: []LedgerPosting{
+			{AccountType: models.LedgerAccountCash, OwnerID: uuid.Nil, Amount: amount},
+			{AccountType: models.LedgerAccountAdvertiserReceivable, OwnerID: advertiserID, Amount: -amount},
+		},
+	})
+	return txn, err
+}
+
+// ============================================
+// BALANCES & QUERIES
+// ============================================
+
+// LedgerBalance is the balance of one account
+type LedgerBalance struct {
+	AccountID   uuid.UUID                `json:"account_id"`
+	AccountType models.LedgerAccountType `json:"account_type"`
+	OwnerID     uuid.UUID                `json:"owner_id"`
+	Currency    string                   `json:"currency"`
+	Debits      int64                    `json:"debits"`
+	Credits     int64                    `json:"credits"`
+	Balance     int64                    `json:"balance"` // debits - credits, minor units
+}
+
+// PromoterLedgerSummary breaks a promoter's payable down by event, per currency.
+// All amounts are minor units from the promoter's point of view (positive = owed to them).
+type PromoterLedgerSummary struct {
+	Currency     string  `json:"currency"`
+	MinorUnits   int     `json:"minor_units"`
+	Earned       int64   `json:"earned"`
+	Reversed     int64   `json:"reversed"`
+	Overrides    int64   `json:"overrides"` // referral and team overrides, net of reversals
+	Adjustments  int64   `json:"adjustments"`
+	Paid         int64   `json:"paid"`
+	Balance      int64   `json:"balance"`
+	BalanceMajor float64 `json:"balance_major"`
+
+	// Balance converted at today's stored rate into the promoter's reporting
+	// currency; empty when no rate is available
+	ReportingCurrency string  `json:"reporting_currency,omitempty"`
+	ReportingBalance  float64 `json:"reporting_balance,omitempty"`
+}
+
+// AccountBalances returns account balances of a tenant, optionally filtered
+// by account type and owner
+func (s *LedgerService) AccountBalances(tenantID uuid.UUID, accountType models.LedgerAccountType, ownerID *uuid.UUID) ([]LedgerBalance, error) {
+	query := s.db.Table("ledger_accounts a").
+		Select(`a.id AS account_id, a.type AS account_type, a.owner_id, a.currency,
+			COALESCE(SUM(CASE WHEN e.amount > 0 THEN e.amount ELSE 0 END), 0) AS debits,
+			COALESCE(SUM(CASE WHEN e.amount < 0 THEN -e.amount ELSE 0 END), 0) AS credits,
+			COALESCE(SUM(e.amount), 0) AS balance`).
+		Joins("LEFT JOIN ledger_entries e ON e.account_id = a.id").
+		Where("a.tenant_id = ?", tenantID).
+		Group("a.id, a.type, a.owner_id, a.currency").
+		Order("a.type, a.currency, a.owner_id")
+	if accountType != "" {
+		query = query.Where("a.type = ?", accountType)
+	}
+	if ownerID != nil {
+		query = query.Where("a.owner_id = ?", *ownerID)
+	}
+
+	var balances []LedgerBalance
+	if err := query.Scan(&balances).Error; err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+// TrialBalanceLine is one currency of a trial balance
+type TrialBalanceLine struct {
+	Debits   int64 `json:"debits"`
+	Credits  int64 `json:"credits"`
+	Balanced bool  `json:"balanced"`
+}
+
+// TrialBalance returns per-currency debit and credit totals of a tenant's
+// ledger. Every currency must net to zero.
+func (s *LedgerService) TrialBalance(tenantID uuid.UUID) (map[string]TrialBalanceLine, error) {
+	var rows []struct {
+		Currency string
+		Debits   int64
+		Credits  int64
+	}
+	if err := s.db.Table("ledger_entries").
+		Select(`currency,
+			COALESCE(SUM(CASE WHEN amount > 0 THEN amount ELSE 0 END), 0) AS debits,
+			COALESCE(SUM(CASE WHEN amount < 0 THEN -amount ELSE 0 END), 0) AS credits`).
+		Where("tenant_id = ?", tenantID).
+		Group("currency").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	lines := make(map[string]TrialBalanceLine, len(rows))
+	for _, r := range rows {
+		lines[r.Currency] = TrialBalanceLine{Debits: r.Debits, Credits: r.Credits, Balanced: r.Debits == r.Credits}
+	}
+	return lines, nil
+}
+
+// PromoterSummary returns a promoter's payable broken down by event, per currency
+func (s *LedgerService) PromoterSummary(tenantID, promoterID uuid.UUID) ([]PromoterLedgerSummary, error) {
+	var rows []struct {
+		Currency string
+		Kind     models.LedgerTransactionKind
+		Amount   int64
+	}
+	if err := s.db.Table("ledger_entries e").
+		Select("e.currency, t.kind, COALESCE(SUM(-e.amount), 0) AS amount").
+		Joins("JOIN ledger_accounts a ON a.id = e.account_id").
+		Joins("JOIN ledger_transactions t ON t.id = e.transaction_id").
+		Where("a.tenant_id = ? AND a.type = ? AND a.owner_id = ?", tenantID, models.LedgerAccountPromoterPayable, promoterID).
+		Group("e.currency, t.kind").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int)
+	var summaries []PromoterLedgerSummary
+	for _, r := range rows {
+		i, ok := index[r.Currency]
+		if !ok {
+			i = len(summaries)
+			index[r.Currency] = i
+			summaries = append(summaries, PromoterLedgerSummary{Currency: r.Currency, MinorUnits: CurrencyMinorUnits(r.Currency)})
+		}
+		summary := &summaries[i]
+		switch r.Kind {
+		case models.LedgerKindConversionApproved:
+			summary.Earned += r.Amount
+		case models.LedgerKindConversionReversed:
+			summary.Reversed -= r.Amount
+		case models.LedgerKindOverrideEarned, models.LedgerKindOverrideReversed:
+			summary.Overrides += r.Amount
+		case models.LedgerKindAdjustment:
+			summary.Adjustments += r.Amount
+		case models.LedgerKindPayoutPaid:
+			summary.Paid -= r.Amount
+		}
+		summary.Balance += r.Amount
+	}
+	reportIn := ""
+	if s.fx != nil && len(summaries) > 0 {
+		reportIn = s.fx.ReportingCurrency(tenantID, promoterID)
+	}
+	for i := range summaries {
+		summaries[i].BalanceMajor = MinorToMajor(summaries[i].Balance, summaries[i].Currency)
+		if reportIn == "" {
+			continue
+		}
+		converted, _, err := s.fx.Convert(NewMoney(summaries[i].Balance, summaries[i].Currency), reportIn, time.Now())
+		if err == nil {
+			summaries[i].ReportingCurrency = reportIn
+			summaries[i].ReportingBalance = converted.Major()
+		}
+	}
+	return summaries, nil
+}
+
+// LedgerTransactionFilter narrows ListTransactions
+type LedgerTransactionFilter struct {
+	Kind          models.LedgerTransactionKind
+	PromoterID    *uuid.UUID
+	AdvertiserID  *uuid.UUID
+	ReferenceType string
+	ReferenceID   *uuid.UUID
+	From          *time.Time
+	To            *time.Time
+}
+
+// ListTransactions returns a tenant's transactions with their entries, newest first
+func (s *LedgerService) ListTransactions(tenantID uuid.UUID, filter LedgerTransactionFilter, limit, offset int) ([]models.LedgerTransaction, int64, error) {
+	query := s.db.Model(&models.LedgerTransaction{}).Where("tenant_id = ?", tenantID)
+	if filter.Kind != "" {
+		query = query.Where("kind = ?", filter.Kind)
+	}
+	if filter.PromoterID != nil {
+		query = query.Where("promoter_id = ?", *filter.PromoterID)
+	}
+	if filter.AdvertiserID != nil {
+		query = query.Where("advertiser_id = ?", *filter.AdvertiserID)
+	}
+	if filter.ReferenceType != "" {
+		query = query.Where("reference_type = ?", filter.ReferenceType)
+	}
+	if filter.ReferenceID != nil {
+		query = query.Where("reference_id = ?", *filter.ReferenceID)
+	}
+	if filter.From != nil {
+		query = query.Where("occurred_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("occurred_at < ?", *filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var txns []models.LedgerTransaction
+	err := query.Preload("Entries").Order("occurred_at DESC, created_at DESC").Limit(limit).Offset(offset).Find(&txns).Error
+	return txns, total, err
+}
+
+// ============================================
+// PERIOD TOTALS (PAYOUTS & INVOICES)
+// ============================================
+
+// LedgerPeriodTotal is the net conversion activity of one advertiser ×
+// promoter pair in one currency over a period. Reversals booked in the period
+// reduce it, so a clawback lands in the period it happened.
+type LedgerPeriodTotal struct {
+	TenantID     uuid.UUID  `json:"tenant_id"`
+	AdvertiserID *uuid.UUID `json:"advertiser_id"`
+	PromoterID   uuid.UUID  `json:"promoter_id"`
+	Currency     string     `json:"currency"`
+	Commission   int64      `json:"commission"` // owed to the promoter
+	PlatformFee  int64      `json:"platform_fee"`
+	Conversions  int        `json:"conversions"` // approvals minus reversals
+
+	// Amounts converted with each posting's FX snapshot, keyed by currency.
+	// A currency is only present when every posting had a rate for it.
+	Reporting map[string]LedgerReportingAmount `json:"reporting,omitempty"`
+}
+
+// LedgerReportingAmount is a period total converted to a reporting currency
+type LedgerReportingAmount struct {
+	Commission  int64 `json:"commission"`
+	PlatformFee int64 `json:"platform_fee"`
+}
+
+// Gross is what the advertiser owes for the period (commission + fee)
+func (t LedgerPeriodTotal) Gross() int64 {
+	return t.Commission + t.PlatformFee
+}
+
+// Merge adds another total of the same currency. Reporting currencies are
+// kept only when both totals have them.
+func (t *LedgerPeriodTotal) Merge(other LedgerPeriodTotal) {
+	first := t.Reporting == nil && t.Commission == 0 && t.PlatformFee == 0 && t.Conversions == 0
+	t.Commission += other.Commission
+	t.PlatformFee += other.PlatformFee
+	t.Conversions += other.Conversions
+	if first {
+		t.Reporting = make(map[string]LedgerReportingAmount, len(other.Reporting))
+		for currency, amount := range other.Reporting {
+			t.Reporting[currency] = amount
+		}
+		return
+	}
+	for currency, amount := range t.Reporting {
+		add, ok := other.Reporting[currency]
+		if !ok {
+			delete(t.Reporting, currency)
+			continue
+		}
+		amount.Commission += add.Commission
+		amount.PlatformFee += add.PlatformFee
+		t.Reporting[currency] = amount
+	}
+}
+
+// ReportIn returns a period total in a reporting currency and the effective
+// rate. Posting-time snapshots are used when every posting has one; otherwise
+// the whole total is converted at the stored rate of asOf.
+func (s *LedgerService) ReportIn(total LedgerPeriodTotal, currency string, asOf time.Time) (LedgerReportingAmount, float64, error) {
+	currency = NormalizeCurrency(currency)
+	rate := func(amount LedgerReportingAmount) float64 {
+		from := MinorToMajor(total.Commission, total.Currency)
+		if from == 0 {
+			return 0
+		}
+		return MinorToMajor(amount.Commission, currency) / from
+	}
+	if amount, ok := total.Reporting[currency]; ok {
+		return amount, rate(amount), nil
+	}
+	if currency == total.Currency {
+		return LedgerReportingAmount{Commission: total.Commission, PlatformFee: total.PlatformFee}, 1, nil
+	}
+	if s.fx == nil {
+		return LedgerReportingAmount{}, 0, ErrFXNoSource
+	}
+	conversion, err := s.fx.Rate(total.Currency, currency, asOf)
+	if err != nil {
+		return LedgerReportingAmount{}, 0, err
+	}
+	return LedgerReportingAmount{
+		Commission:  NewMoney(total.Commission, total.Currency).Convert(currency, conversion.Rate).Amount,
+		PlatformFee: NewMoney(total.PlatformFee, total.Currency).Convert(currency, conversion.Rate).Amount,
+	}, conversion.Rate, nil
+}
+
+// LedgerEarningKinds are the transactions promoters are paid out for:
+// conversions and the overrides earned on them
+var LedgerEarningKinds = []models.LedgerTransactionKind{
+	models.LedgerKindConversionApproved, models.LedgerKindConversionReversed,
+	models.LedgerKindOverrideEarned, models.LedgerKindOverrideReversed,
+}
+
+// PeriodTotals aggregates conversion and override postings in [from, to),
+// what promoters are paid for. Overrides move part of the platform fee to
+// the beneficiary's commission. A nil tenant aggregates every tenant.
+func (s *LedgerService) PeriodTotals(tenantID *uuid.UUID, from, to time.Time) ([]LedgerPeriodTotal, error) {
+	return s.periodTotals(tenantID, from, to, LedgerEarningKinds)
+}
+
+// ConversionTotals aggregates conversion postings only in [from, to), what
+// advertisers are invoiced for
+func (s *LedgerService) ConversionTotals(tenantID *uuid.UUID, from, to time.Time) ([]LedgerPeriodTotal, error) {
+	return s.periodTotals(tenantID, from, to, []models.LedgerTransactionKind{
+		models.LedgerKindConversionApproved, models.LedgerKindConversionReversed,
+	})
+}
+
+func (s *LedgerService) periodTotals(tenantID *uuid.UUID, from, to time.Time, kinds []models.LedgerTransactionKind) ([]LedgerPeriodTotal, error) {
+	query := s.db.Table("ledger_transactions t").
+		Select(`t.id, t.tenant_id, t.advertiser_id, t.promoter_id, t.kind, t.fx_rates, e.currency,
+			COALESCE(SUM(CASE WHEN a.type = ? THEN -e.amount ELSE 0 END), 0) AS commission,
+			COALESCE(SUM(CASE WHEN a.type = ? THEN -e.amount ELSE 0 END), 0) AS platform_fee`,
+			models.LedgerAccountPromoterPayable, models.LedgerAccountPlatformRevenue).
+		Joins("JOIN ledger_entries e ON e.transaction_id = t.id").
+		Joins("JOIN ledger_accounts a ON a.id = e.account_id").
+		Where("t.kind IN ?", kinds).
+		Where("t.promoter_id IS NOT NULL").
+		Where("t.occurred_at >= ? AND t.occurred_at < ?", from, to).
+		Group("t.id, t.tenant_id, t.advertiser_id, t.promoter_id, t.kind, t.fx_rates, e.currency").
+		Order("t.tenant_id, t.advertiser_id, t.promoter_id, e.currency")
+	if tenantID != nil {
+		query = query.Where("t.tenant_id = ?", *tenantID)
+	}
+
+	var rows []LedgerPeriodRow
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return AggregatePeriodRows(rows), nil
+}
+
+// LedgerPeriodRow is one conversion transaction of a period, as read for PeriodTotals
+type LedgerPeriodRow struct {
+	ID           uuid.UUID
+	TenantID     uuid.UUID
+	AdvertiserID *uuid.UUID
+	PromoterID   uuid.UUID
+	Kind         models.LedgerTransactionKind
+	FXRates      datatypes.JSON
+	Currency     string
+	Commission   int64
+	PlatformFee  int64
+}
+
+// AggregatePeriodRows sums transaction rows per tenant, advertiser, promoter
+// and currency, converting each row with its own FX snapshot
+func AggregatePeriodRows(rows []LedgerPeriodRow) []LedgerPeriodTotal {
+	type key struct {
+		tenant, advertiser, promoter uuid.UUID
+		currency                     string
+	}
+	index := make(map[key]int)
+	coverage := make(map[key]map[string]int) // reporting currency -> rows converted
+	counts := make(map[key]int)
+	var totals []LedgerPeriodTotal
+
+	for _, row := range rows {
+		k := key{row.TenantID, derefUUID(row.AdvertiserID), row.PromoterID, row.Currency}
+		i, ok := index[k]
+		if !ok {
+			i = len(totals)
+			index[k] = i
+			coverage[k] = make(map[string]int)
+			totals = append(totals, LedgerPeriodTotal{
+				TenantID:     row.TenantID,
+				AdvertiserID: row.AdvertiserID,
+				PromoterID:   row.PromoterID,
+				Currency:     row.Currency,
+				Reporting:    make(map[string]LedgerReportingAmount),
+			})
+		}
+		total := &totals[i]
+		total.Commission += row.Commission
+		total.PlatformFee += row.PlatformFee
+		switch row.Kind {
+		case models.LedgerKindConversionApproved:
+			total.Conversions++
+		case models.LedgerKindConversionReversed:
+			total.Conversions--
+		}
+		counts[k]++
+
+		rates := make(map[string]float64)
+		if len(row.FXRates) > 0 {
+			json.Unmarshal(row.FXRates, &rates)
+		}
+		rates[row.Currency] = 1
+		for currency, rate := range rates {
+			amount := total.Reporting[currency]
+			amount.Commission += NewMoney(row.Commission, row.Currency).Convert(currency, rate).Amount
+			amount.PlatformFee += NewMoney(row.PlatformFee, row.Currency).Convert(currency, rate).Amount
+			total.Reporting[currency] = amount
+			coverage[k][currency]++
+		}
+	}
+
+	for k, i := range index {
+		for currency, n := range coverage[k] {
+			if n < counts[k] {
+				delete(totals[i].Reporting, currency)
+			}
+		}
+	}
+	return totals
+}
+
+// ============================================
+// BACKFILL & RECONCILIATION
+// ============================================
+
+// Backfill posts approvals for approved or paid conversions that predate the
+// ledger and credits them to the earnings counters. Wallets and overrides are
+// not touched. A nil tenant backfills every tenant.
+func (s *LedgerService) Backfill(tenantID *uuid.UUID, limit int) (int, error) {
+	if limit <= 0 {
+		limit = 1000
+	}
+	query := s.db.Model(&models.Conversion{}).
+		Where("status IN ? AND commission > 0", []string{models.ConversionStatusApproved, models.ConversionStatusPaid}).
+		Where("NOT EXISTS (SELECT 1 FROM ledger_transactions t WHERE t.reference_type = ? AND t.reference_id = conversions.id AND t.kind = ?)",
+			LedgerReferenceConversion, models.LedgerKindConversionApproved).
+		Order("converted_at").
+		Limit(limit)
+	if tenantID != nil {
+		query = query.Where("tenant_id = ?", *tenantID)
+	}
+
+	var conversions []models.Conversion
+	if err := query.Find(&conversions).Error; err != nil {
+		return 0, err
+	}
+
+	posted := 0
+	for i := range conversions {
+		conversion := &conversions[i]
+		if conversion.ApprovedAt == nil {
+			conversion.ApprovedAt = &conversion.ConvertedAt
+		}
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			_, err := s.postConversionApproved(tx, conversion, false)
+			return err
+		})
+		if err != nil {
+			return posted, fmt.Errorf("conversion %s: %w", conversion.ID, err)
+		}
+		posted++
+	}
+	return posted, nil
+}
+
+// LedgerImbalance is a transaction whose entries do not net to zero
+type LedgerImbalance struct {
+	TransactionID uuid.UUID `json:"transaction_id"`
+	Currency      string    `json:"currency"`
+	Total         int64     `json:"total"`
+}
+
+// LedgerEarningsDrift is an earnings counter that disagrees with the ledger
+type LedgerEarningsDrift struct {
+	EntityType string    `json:"entity_type"` // afftok_user, user_offer
+	EntityID   uuid.UUID `json:"entity_id"`
+	Currency   string    `json:"currency"`
+	Counter    int64     `json:"counter"` // minor units
+	Ledger     int64     `json:"ledger"`
+}
+
+// LedgerReconciliation lists disagreements between the ledger and the rest
+// of the data
+type LedgerReconciliation struct {
+	Unbalanced       []LedgerImbalance     `json:"unbalanced"`
+	MissingApprovals []uuid.UUID           `json:"missing_approvals"` // approved/paid conversions never booked
+	MissingReversals []uuid.UUID           `json:"missing_reversals"` // rejected conversions still booked
+	EarningsDrift    []LedgerEarningsDrift `json:"earnings_drift"`
+	CheckedAt        time.Time             `json:"checked_at"`
+}
+
+// Clean reports whether nothing needs attention
+func (r *LedgerReconciliation) Clean() bool {
+	return len(r.Unbalanced) == 0 && len(r.MissingApprovals) == 0 &&
+		len(r.MissingReversals) == 0 && len(r.EarningsDrift) == 0
+}
+
+// earningsOwners maps each earnings counter owner type to the ledger
+// transaction column naming the owner. Overrides carry no user offer.
+var earningsOwners = []struct{ ownerType, column string }{
+	{models.EarningsOwnerUser, "promoter_id"},
+	{models.EarningsOwnerUserOffer, "user_offer_id"},
+}
+
+// ledgerConversionEarnings is the per-promoter (or per user offer) sum of
+// conversion and override postings on promoter payables, per currency in
+// minor units like the earnings counters
+func ledgerConversionEarnings(owner string) string {
+	return fmt.Sprintf(`SELECT t.tenant_id, t.%[1]s AS owner, e.currency, SUM(-e.amount) AS earned
+	FROM ledger_transactions t
+	JOIN ledger_entries e ON e.transaction_id = t.id
+	JOIN ledger_accounts a ON a.id = e.account_id AND a.type = 'promoter_payable'
+	WHERE t.kind IN ('conversion_approved', 'conversion_reversed', 'override_earned', 'override_reversed') AND t.%[1]s IS NOT NULL
+	GROUP BY t.tenant_id, t.%[1]s, e.currency`, owner)
+}
+
+// Reconcile compares the ledger with conversions and the earnings counters.
+// Each list is capped at limit rows. A nil tenant checks every tenant.
+func (s *LedgerService) Reconcile(tenantID *uuid.UUID, limit int) (*LedgerReconciliation, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	report := &LedgerReconciliation{CheckedAt: time.Now().UTC()}
+
+	// scoped adds "AND <alias>.tenant_id = ?" when reconciling one tenant
+	scoped := func(alias string) (string, []interface{}) {
+		if tenantID == nil {
+			return "", nil
+		}
+		return " AND " + alias + ".tenant_id = ?", []interface{}{*tenantID}
+	}
+	withLimit := func(args []interface{}) []interface{} {
+		return append(args, limit)
+	}
+
+	cond, args := scoped("e")
+	if err := s.db.Raw(`SELECT e.transaction_id, e.currency, SUM(e.amount) AS total
+		FROM ledger_entries e WHERE 1 = 1`+cond+`
+		GROUP BY e.transaction_id, e.currency HAVING SUM(e.amount) <> 0 LIMIT ?`, withLimit(args)...).
+		Scan(&report.Unbalanced).Error; err != nil {
+		return nil, err
+	}
+
+	cond, args = scoped("c")
+	if err := s.db.Raw(`SELECT c.id FROM conversions c
+		WHERE c.status IN ('approved', 'paid') AND c.commission > 0`+cond+`
+		AND NOT EXISTS (SELECT 1 FROM ledger_transactions t
+			WHERE t.reference_type = 'conversion' AND t.reference_id = c.id AND t.kind = 'conversion_approved')
+		LIMIT ?`, withLimit(args)...).Scan(&report.MissingApprovals).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Raw(`SELECT c.id FROM conversions c
+		WHERE c.status = 'rejected'`+cond+`
+		AND EXISTS (SELECT 1 FROM ledger_transactions t
+			WHERE t.reference_type = 'conversion' AND t.reference_id = c.id AND t.kind = 'conversion_approved')
+		AND NOT EXISTS (SELECT 1 FROM ledger_transactions t
+			WHERE t.reference_type = 'conversion' AND t.reference_id = c.id AND t.kind = 'conversion_reversed')
+		LIMIT ?`, withLimit(args)...).Scan(&report.MissingReversals).Error; err != nil {
+		return nil, err
+	}
+
+	cond, args = "", nil
+	if tenantID != nil {
+		cond, args = " AND COALESCE(c.tenant_id, l.tenant_id) = ?", []interface{}{*tenantID}
+	}
+	for _, owner := range earningsOwners {
+		var drift []LedgerEarningsDrift
+		if err := s.db.Raw(`SELECT ? AS entity_type, COALESCE(c.owner_id, l.owner) AS entity_id,
+				COALESCE(c.currency, l.currency) AS currency, COALESCE(c.amount, 0) AS counter, COALESCE(l.earned, 0) AS ledger
+			FROM (SELECT * FROM earnings_counters WHERE owner_type = ?) c
+			FULL JOIN (`+ledgerConversionEarnings(owner.column)+`) l ON l.owner = c.owner_id AND l.currency = c.currency
+			WHERE COALESCE(c.amount, 0) <> COALESCE(l.earned, 0)`+cond+` LIMIT ?`,
+			withLimit(append([]interface{}{owner.ownerType, owner.ownerType}, args...))...).Scan(&drift).Error; err != nil {
+			return nil, err
+		}
+		report.EarningsDrift = append(report.EarningsDrift, drift...)
+	}
+
+	return report, nil
+}
+
+// ReverseMissing posts reversals for rejected conversions that are still booked
+func (s *LedgerService) ReverseMissing(conversionIDs []uuid.UUID) (int, error) {
+	reversed := 0
+	for _, id := range conversionIDs {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			_, err := s.PostConversionReversed(tx, id, "Reconciliation: conversion rejected", nil)
+			return err
+		})
+		if err != nil {
+			return reversed, fmt.Errorf("conversion %s: %w", id, err)
+		}
+		reversed++
+	}
+	return reversed, nil
+}
+
+// RebuildEarningsCounters resets the earnings counters to the ledger's
+// conversion and override earnings
+func (s *LedgerService) RebuildEarningsCounters() (int64, error) {
+	var fixed int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for _, owner := range earningsOwners {
+			result := tx.Exec(`INSERT INTO earnings_counters (id, tenant_id, owner_type, owner_id, currency, amount, updated_at)
+				SELECT gen_random_uuid(), l.tenant_id, ?, l.owner, l.currency, l.earned, NOW()
+				FROM (`+ledgerConversionEarnings(owner.column)+`) l
+				ON CONFLICT (owner_type, owner_id, currency) DO UPDATE SET amount = EXCLUDED.amount, updated_at = EXCLUDED.updated_at
+				WHERE earnings_counters.amount <> EXCLUDED.amount`, owner.ownerType)
+			if result.Error != nil {
+				return result.Error
+			}
+			fixed += result.RowsAffected
+
+			result = tx.Exec(`UPDATE earnings_counters c SET amount = 0, updated_at = NOW()
+				WHERE c.owner_type = ? AND c.amount <> 0 AND NOT EXISTS (
+					SELECT 1 FROM (`+ledgerConversionEarnings(owner.column)+`) l
+					WHERE l.owner = c.owner_id AND l.currency = c.currency)`, owner.ownerType)
+			if result.Error != nil {
+				return result.Error
+			}
+			fixed += result.RowsAffected
+		}
+		return nil
+	})
+	return fixed, err
+}
@@ -164,6 +164,14 @@ func (s *LinkSigningService) createSignature(trackingCode string, timestamp int6
 
 // ValidateSignedLink validates a signed tracking link
 func (s *LinkSigningService) ValidateSignedLink(raw string) *SignedLinkValidationResult {
+	return s.ValidateSignedLinkTTL(raw, nil)
+}
+
+// ValidateSignedLinkTTL validates a signed link using the TTL returned by
+// ttlFor for its tracking code (the owning tenant's DefaultLinkTTL).
+// ttlFor runs only for correctly signed links; nil or a non-positive result
+// means the global TTL.
+func (s *LinkSigningService) ValidateSignedLinkTTL(raw string, ttlFor func(trackingCode string) int64) *SignedLinkValidationResult {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
@@ -212,9 +220,15 @@ func (s *LinkSigningService) ValidateSignedLink(raw string) *SignedLinkValidatio
 	}
 
 	// Step 2: Check TTL
+	ttl := s.ttlSeconds
+	if ttlFor != nil {
+		if tenantTTL := ttlFor(trackingCode); tenantTTL > 0 {
+			ttl = tenantTTL
+		}
+	}
 	now := time.Now().Unix()
 	age := now - timestamp
-	if age > s.ttlSeconds {
+	if age > ttl {
 		atomic.AddInt64(&linkMetrics.ExpiredLinks, 1)
 		result.Reason = "link_expired"
 		result.Indicators = append(result.Indicators, "expired_link")
@@ -238,7 +252,7 @@ func (s *LinkSigningService) ValidateSignedLink(raw string) *SignedLinkValidatio
 	}
 
 	// Step 5: Store nonce to prevent replay
-	s.storeNonce(nonce)
+	s.storeNonce(nonce, ttl)
 
 	// Valid!
 	atomic.AddInt64(&linkMetrics.ValidLinks, 1)
@@ -287,12 +301,12 @@ func (s *LinkSigningService) isReplayAttempt(nonce string) bool {
 }
 
 // storeNonce stores a nonce to prevent replay
-func (s *LinkSigningService) storeNonce(nonce string) {
+func (s *LinkSigningService) storeNonce(nonce string, linkTTL int64) {
 	ctx := context.Background()
 	key := fmt.Sprintf("replay:%s", nonce)
 	
 	// Store with TTL slightly longer than link TTL
-	ttl := time.Duration(s.ttlSeconds+3600) * time.Second
+	ttl := time.Duration(linkTTL+3600) * time.Second
 	cache.Set(ctx, key, "1", ttl)
 }
 
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ============================================
+// MONEY
+// ============================================
+// Amounts are integer minor units (cents, fils, ...) of an ISO 4217
+// currency. Floats only appear at the edges: decimal amounts stored on
+// legacy payout/invoice rows and FX rates. Conversion and offer commissions
+// and the earnings counters predate the ledger and are whole currency units.
+
+// ErrCurrencyMismatch is returned when adding amounts of different currencies
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// currencyExponents lists ISO 4217 currencies without two decimals
+var currencyExponents = map[string]int{
+	"KWD": 3, "BHD": 3, "OMR": 3, "JOD": 3, "IQD": 3, "LYD": 3, "TND": 3,
+	"JPY": 0, "KRW": 0, "VND": 0, "CLP": 0, "ISK": 0, "UGX": 0, "XAF": 0, "XOF": 0,
+}
+
+// NormalizeCurrency upper-cases a currency code, defaulting to USD
+func NormalizeCurrency(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return "USD"
+	}
+	return code
+}
+
+// ValidCurrency reports whether code looks like an ISO 4217 code (three letters)
+func ValidCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+// CurrencyMinorUnits returns the number of decimals of a currency
+// (KWD has 3 fils digits, JPY none, most others 2)
+func CurrencyMinorUnits(code string) int {
+	if exp, ok := currencyExponents[NormalizeCurrency(code)]; ok {
+		return exp
+	}
+	return 2
+}
+
+// MinorToMajor converts minor units to a decimal amount of the currency
+func MinorToMajor(amount int64, currency string) float64 {
+	return float64(amount) / math.Pow10(CurrencyMinorUnits(currency))
+}
+
+// MajorToMinor converts a decimal amount to minor units, rounding half away from zero
+func MajorToMinor(amount float64, currency string) int64 {
+	return int64(math.Round(amount * math.Pow10(CurrencyMinorUnits(currency))))
+}
+
+// WholeToMinor converts whole currency units (conversion commissions) to minor units
+func WholeToMinor(amount int64, currency string) int64 {
+	return amount * int64(math.Pow10(CurrencyMinorUnits(currency)))
+}
+
+// MinorToWhole converts minor units to whole currency units, rounding half
+// away from zero
+func MinorToWhole(amount int64, currency string) int64 {
+	return int64(math.Round(float64(amount) / math.Pow10(CurrencyMinorUnits(currency))))
+}
+
+// Money is an amount in minor units of a currency
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// NewMoney creates money from minor units
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: NormalizeCurrency(currency)}
+}
+
+// MoneyFromMajor creates money from a decimal amount
+func MoneyFromMajor(amount float64, currency string) Money {
+	return NewMoney(MajorToMinor(amount, currency), currency)
+}
+
+// Major returns the decimal amount
+func (m Money) Major() float64 {
+	return MinorToMajor(m.Amount, m.Currency)
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Neg returns the negated amount
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+// Add returns m + other; both must be in the same currency
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("%w: %s + %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+// Convert applies rate (units of `to` per one unit of m's currency),
+// rescaling between the currencies' minor units and rounding half away from zero
+func (m Money) Convert(to string, rate float64) Money {
+	to = NormalizeCurrency(to)
+	if to == m.Currency {
+		return m
+	}
+	scale := math.Pow10(CurrencyMinorUnits(to) - CurrencyMinorUnits(m.Currency))
+	return Money{Amount: int64(math.Round(float64(m.Amount) * rate * scale)), Currency: to}
+}
+
+// String formats the amount with the currency's decimals, e.g. "12.345 KWD"
+func (m Money) String() string {
+	return strconv.FormatFloat(m.Major(), 'f', CurrencyMinorUnits(m.Currency), 64) + " " + m.Currency
+}
@@ -0,0 +1,1023 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template/parse"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// PAGE TEMPLATE SERVICE
+// ============================================
+// Public HTML pages are rendered with html/template from a layout plus one
+// content template per page. Tenants with custom branding can override any
+// of them per locale. Overrides are sandboxed:
+//   - they only see plain view structs (no methods, no functions beyond the
+//     html/template builtins)
+//   - markup that could run script or leave the page is rejected on save
+//   - rendered pages carry a CSP that only allows the built-in nonce script
+//   - range only iterates over view fields, never over numbers
+//   - rendered output is size- and time-limited
+
+const (
+	// MaxPageTemplateSize is the largest override a tenant may store
+	MaxPageTemplateSize = 64 * 1024
+	// maxRenderedPageSize caps the output of a single render
+	maxRenderedPageSize = 1 << 20
+	// pageRenderTimeout caps how long a single render may run
+	pageRenderTimeout = 2 * time.Second
+	// pageTemplateCacheTTL bounds how long other instances serve a stale override
+	pageTemplateCacheTTL = time.Minute
+)
+
+var (
+	ErrInvalidPageTemplate  = errors.New("invalid page template")
+	ErrUnknownPageTemplate  = errors.New("unknown page or locale")
+	ErrRenderedPageTooLarge = errors.New("rendered page exceeds the size limit")
+	ErrPageRenderTimeout    = errors.New("page render timed out")
+)
+
+// Default AffTok branding
+const (
+	afftokBrandName      = "AffTok"
+	afftokHomeURL        = "https://afftokapp.com"
+	afftokPrimaryColor   = "#FF006E"
+	afftokSecondaryColor = "#FF4D00"
+)
+
+var brandColorPattern = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+
+// ============================================
+// VIEW MODEL
+// ============================================
+// Templates only ever receive these structs.
+
+// PageBranding is the resolved look of a tenant's pages
+type PageBranding struct {
+	Name           string
+	LogoURL        string
+	FaviconURL     string
+	PrimaryColor   string
+	SecondaryColor string
+	HomeURL        string
+	PoweredBy      bool // show "powered by AffTok" (custom branding without white label)
+}
+
+// PageOffer is an offer card on the promoter page
+type PageOffer struct {
+	Title       string
+	Description string
+	ImageURL    string
+	Category    string
+	Payout      string
+	Link        string
+}
+
+// PromoterPageData is the promoter landing page content
+type PromoterPageData struct {
+	ID          string
+	Name        string
+	Username    string
+	Bio         string
+	AvatarURL   string
+	Initial     string
+	Rating      float64
+	TotalClicks int64
+	TotalOffers int64
+	Offers      []PageOffer
+}
+
+// TeamPageMember is a member listed on the team invite page
+type TeamPageMember struct {
+	Name     string
+	Username string
+	IsOwner  bool
+}
+
+// TeamPageData is the team invite page content
+type TeamPageData struct {
+	Name         string
+	Description  string
+	LogoURL      string
+	InviteCode   string
+	JoinURL      template.URL // app deep link
+	MembersCount int
+	Conversions  int
+	Clicks       int
+	Members      []TeamPageMember
+}
+
+// ErrorPageData is the error page content
+type ErrorPageData struct {
+	Status  int
+	Title   string
+	Message string
+}
+
+// Error page kinds (translation key prefixes)
+const (
+	PageErrorInviteInvalid    = "invite_invalid"
+	PageErrorPromoterNotFound = "promoter_not_found"
+)
+
+// PageView is the data passed to every page template
+type PageView struct {
+	Locale      string
+	Dir         string
+	Title       string
+	Description string
+	Image       string
+	Nonce       string
+	Brand       PageBranding
+	T           map[string]string
+
+	Promoter *PromoterPageData
+	Team     *TeamPageData
+	Error    *ErrorPageData
+}
+
+// RenderedPage is a rendered page and the nonce its CSP must allow
+type RenderedPage struct {
+	Status int
+	HTML   []byte
+	Nonce  string
+}
+
+// PageCSP is the Content-Security-Policy of rendered pages: no script except
+// the built-in nonce script, no frames, no forms, no plugins
+func PageCSP(nonce string) string {
+	return fmt.Sprintf("default-src 'none'; script-src 'nonce-%s'; style-src 'unsafe-inline'; img-src https: data:; font-src https: data:; connect-src 'self'; form-action 'none'; base-uri 'none'; frame-ancestors 'none'", nonce)
+}
+
+// ============================================
+// SERVICE
+// ============================================
+
+type cachedPageTemplate struct {
+	tmpl     *template.Template
+	loadedAt time.Time
+}
+
+// PageTemplateService renders tenant-branded public pages and manages overrides
+type PageTemplateService struct {
+	db      *gorm.DB
+	tenants *TenantService
+	cache   sync.Map // "tenant|page|locale" -> *cachedPageTemplate
+}
+
+var (
+	pageTemplateService     *PageTemplateService
+	pageTemplateServiceOnce sync.Once
+)
+
+// GetPageTemplateService returns the singleton page template service
+func GetPageTemplateService(db *gorm.DB) *PageTemplateService {
+	pageTemplateServiceOnce.Do(func() {
+		pageTemplateService = NewPageTemplateService(db, GetTenantService(db))
+	})
+	return pageTemplateService
+}
+
+// NewPageTemplateService creates a page template service. Without a tenant
+// service every page gets the default AffTok branding.
+func NewPageTemplateService(db *gorm.DB, tenants *TenantService) *PageTemplateService {
+	return &PageTemplateService{db: db, tenants: tenants}
+}
+
+// IsPageTemplatePage reports whether page is an overridable page key
+func IsPageTemplatePage(page string) bool {
+	for _, p := range models.PageTemplatePages {
+		if p == page {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPageLocale reports whether locale is supported
+func IsPageLocale(locale string) bool {
+	_, ok := pageTranslations[locale]
+	return ok
+}
+
+// ResolvePageLocale picks the locale from ?lang, then Accept-Language, then
+// the default (Arabic)
+func ResolvePageLocale(requested, acceptLanguage string) string {
+	if requested = strings.ToLower(strings.TrimSpace(requested)); IsPageLocale(requested) {
+		return requested
+	}
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		if i := strings.Index(tag, "-"); i > 0 {
+			tag = tag[:i]
+		}
+		if IsPageLocale(tag) {
+			return tag
+		}
+	}
+	return models.PageTemplateLocales[0]
+}
+
+// ============================================
+// BRANDING
+// ============================================
+
+// Branding resolves a tenant's page branding and whether its template
+// overrides apply (the custom branding feature)
+func (s *PageTemplateService) Branding(tenantID uuid.UUID) (PageBranding, bool) {
+	if tenantID == uuid.Nil || tenantID == models.DefaultTenantID || s.tenants == nil {
+		return defaultPageBranding(), false
+	}
+	tenant, err := s.tenants.GetTenant(tenantID)
+	if err != nil {
+		return defaultPageBranding(), false
+	}
+	return TenantPageBranding(tenant, tenantFeatures(tenant))
+}
+
+// TenantPageBranding derives page branding from a tenant and its features.
+// Without custom branding pages look like AffTok; white label removes every
+// AffTok mention.
+func TenantPageBranding(tenant *models.Tenant, features models.TenantFeatures) (PageBranding, bool) {
+	brand := defaultPageBranding()
+	if !features.CustomBranding && !features.WhiteLabelEnabled {
+		return brand, false
+	}
+
+	brand.Name = tenant.Name
+	brand.LogoURL = safePageURL(tenant.LogoURL)
+	brand.FaviconURL = safePageURL(tenant.FaviconURL)
+	if brandColorPattern.MatchString(tenant.PrimaryColor) {
+		brand.PrimaryColor = tenant.PrimaryColor
+	}
+	if brandColorPattern.MatchString(tenant.SecondaryColor) {
+		brand.SecondaryColor = tenant.SecondaryColor
+	}
+
+	if features.WhiteLabelEnabled {
+		brand.PoweredBy = false
+		brand.HomeURL = ""
+		if tenant.CustomDomain != "" {
+			brand.HomeURL = "https://" + tenant.CustomDomain
+		}
+	} else {
+		brand.PoweredBy = true
+	}
+	return brand, true
+}
+
+func defaultPageBranding() PageBranding {
+	return PageBranding{
+		Name:           afftokBrandName,
+		PrimaryColor:   afftokPrimaryColor,
+		SecondaryColor: afftokSecondaryColor,
+		HomeURL:        afftokHomeURL,
+	}
+}
+
+// safePageURL keeps only absolute http(s) URLs
+func safePageURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return ""
+	}
+	return u.String()
+}
+
+// ============================================
+// RENDERING
+// ============================================
+
+func (s *PageTemplateService) newView(tenantID uuid.UUID, locale string) (*PageView, bool) {
+	if !IsPageLocale(locale) {
+		locale = models.PageTemplateLocales[0]
+	}
+	brand, custom := s.Branding(tenantID)
+	return newPageView(locale, brand), custom
+}
+
+func newPageView(locale string, brand PageBranding) *PageView {
+	view := &PageView{
+		Locale: locale,
+		Dir:    "ltr",
+		Nonce:  GenerateScriptNonce(),
+		Brand:  brand,
+		T:      pageTranslations[locale],
+	}
+	if locale == models.PageLocaleArabic {
+		view.Dir = "rtl"
+	}
+	return view
+}
+
+// RenderPromoterPage renders a promoter's landing page
+func (s *PageTemplateService) RenderPromoterPage(tenantID uuid.UUID, locale string, promoter *PromoterPageData) (*RenderedPage, error) {
+	view, custom := s.newView(tenantID, locale)
+	fillPromoterView(view, promoter)
+	return s.render(tenantID, custom, models.PageTemplatePromoterLanding, view, 200)
+}
+
+// RenderTeamInvitePage renders a team's invite landing page
+func (s *PageTemplateService) RenderTeamInvitePage(tenantID uuid.UUID, locale string, team *TeamPageData) (*RenderedPage, error) {
+	view, custom := s.newView(tenantID, locale)
+	fillTeamView(view, team)
+	return s.render(tenantID, custom, models.PageTemplateTeamInvite, view, 200)
+}
+
+// RenderErrorPage renders an error page of the given kind
+func (s *PageTemplateService) RenderErrorPage(tenantID uuid.UUID, locale string, status int, kind string) (*RenderedPage, error) {
+	view, custom := s.newView(tenantID, locale)
+	fillErrorView(view, status, kind)
+	return s.render(tenantID, custom, models.PageTemplateError, view, status)
+}
+
+func fillPromoterView(view *PageView, promoter *PromoterPageData) {
+	if promoter.Initial == "" {
+		if r, _ := utf8.DecodeRuneInString(promoter.Name); r != utf8.RuneError {
+			promoter.Initial = strings.ToUpper(string(r))
+		}
+	}
+	view.Promoter = promoter
+	view.Title = fmt.Sprintf(view.T["promoter_page_title"], promoter.Name, view.Brand.Name)
+	view.Description = promoter.Bio
+	view.Image = promoter.AvatarURL
+}
+
+func fillTeamView(view *PageView, team *TeamPageData) {
+	if team.JoinURL == "" && team.InviteCode != "" {
+		team.JoinURL = template.URL("afftok://join/" + url.PathEscape(team.InviteCode))
+	}
+	view.Team = team
+	view.Title = fmt.Sprintf(view.T["team_page_title"], team.Name, view.Brand.Name)
+	view.Description = team.Description
+	view.Image = team.LogoURL
+}
+
+func fillErrorView(view *PageView, status int, kind string) {
+	title, ok := view.T[kind+"_title"]
+	if !ok {
+		kind = "generic_error"
+		title = view.T[kind+"_title"]
+	}
+	view.Error = &ErrorPageData{Status: status, Title: title, Message: view.T[kind+"_message"]}
+	view.Title = title + " - " + view.Brand.Name
+}
+
+// render executes a page, falling back to the built-in templates if a
+// tenant override fails at runtime
+func (s *PageTemplateService) render(tenantID uuid.UUID, custom bool, page string, view *PageView, status int) (*RenderedPage, error) {
+	if !custom {
+		tenantID = uuid.Nil
+	}
+
+	tmpl, err := s.pageTemplate(tenantID, page, view.Locale)
+	if err == nil {
+		var html []byte
+		if html, err = executePageTemplate(tmpl, view); err == nil {
+			return &RenderedPage{Status: status, HTML: html, Nonce: view.Nonce}, nil
+		}
+	}
+	if tenantID == uuid.Nil {
+		return nil, err
+	}
+
+	log.Printf("[PageTemplates] tenant %s %s/%s override failed, using built-in: %v", tenantID, page, view.Locale, err)
+	return s.render(uuid.Nil, false, page, view, status)
+}
+
+// pageTemplate returns the compiled layout+content for a tenant (uuid.Nil
+// for the built-ins)
+func (s *PageTemplateService) pageTemplate(tenantID uuid.UUID, page, locale string) (*template.Template, error) {
+	key := tenantID.String() + "|" + page + "|" + locale
+	if value, ok := s.cache.Load(key); ok {
+		entry := value.(*cachedPageTemplate)
+		if time.Since(entry.loadedAt) < pageTemplateCacheTTL {
+			return entry.tmpl, nil
+		}
+	}
+
+	layout, content := builtinPageTemplates[models.PageTemplateLayout], builtinPageTemplates[page]
+	if tenantID != uuid.Nil && s.db != nil {
+		var overrides []models.TenantPageTemplate
+		s.db.Where("tenant_id = ? AND locale = ? AND page IN ?", tenantID, locale,
+			[]string{models.PageTemplateLayout, page}).Find(&overrides)
+		for _, o := range overrides {
+			if o.Page == models.PageTemplateLayout {
+				layout = o.Content
+			} else {
+				content = o.Content
+			}
+		}
+	}
+
+	tmpl, err := compilePageTemplate(layout, content)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.Store(key, &cachedPageTemplate{tmpl: tmpl, loadedAt: time.Now()})
+	return tmpl, nil
+}
+
+// Invalidate drops a tenant's compiled templates
+func (s *PageTemplateService) Invalidate(tenantID uuid.UUID) {
+	prefix := tenantID.String() + "|"
+	s.cache.Range(func(key, _ interface{}) bool {
+		if strings.HasPrefix(key.(string), prefix) {
+			s.cache.Delete(key)
+		}
+		return true
+	})
+}
+
+func compilePageTemplate(layout, content string) (*template.Template, error) {
+	tmpl, err := template.New(models.PageTemplateLayout).Option("missingkey=zero").Parse(layout)
+	if err != nil {
+		return nil, fmt.Errorf("%w: layout: %v", ErrInvalidPageTemplate, err)
+	}
+	if _, err := tmpl.New("content").Parse(content); err != nil {
+		return nil, fmt.Errorf("%w: content: %v", ErrInvalidPageTemplate, err)
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+		if err := checkPageRanges(t.Tree.Root); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidPageTemplate, t.Name(), err)
+		}
+	}
+	return tmpl, nil
+}
+
+// checkPageRanges only lets range iterate over a view field (.Items or
+// $.Team.Members): a number literal, variable or function result could make
+// it loop for as long as it likes without writing a byte
+func checkPageRanges(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkPageRanges(child); err != nil {
+				return err
+			}
+		}
+	case *parse.RangeNode:
+		if !isViewField(n.Pipe) {
+			return fmt.Errorf("range may only iterate over a view field, not %q", n.Pipe.String())
+		}
+		return checkPageBranch(&n.BranchNode)
+	case *parse.IfNode:
+		return checkPageBranch(&n.BranchNode)
+	case *parse.WithNode:
+		return checkPageBranch(&n.BranchNode)
+	}
+	return nil
+}
+
+func checkPageBranch(b *parse.BranchNode) error {
+	if err := checkPageRanges(b.List); err != nil {
+		return err
+	}
+	return checkPageRanges(b.ElseList)
+}
+
+func isViewField(pipe *parse.PipeNode) bool {
+	if pipe == nil || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+	switch arg := pipe.Cmds[0].Args[0].(type) {
+	case *parse.FieldNode:
+		return true
+	case *parse.VariableNode:
+		return len(arg.Ident) > 1 && arg.Ident[0] == "$"
+	}
+	return false
+}
+
+// limitedBuffer fails writes past max bytes, or any write once aborted
+type limitedBuffer struct {
+	bytes.Buffer
+	max     int
+	aborted atomic.Bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.aborted.Load() {
+		return 0, ErrPageRenderTimeout
+	}
+	if b.Len()+len(p) > b.max {
+		return 0, ErrRenderedPageTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// executePageTemplate renders a page within the size and time limits. A
+// render cannot be interrupted, so one that times out is abandoned and stops
+// at its next write.
+func executePageTemplate(tmpl *template.Template, view *PageView) ([]byte, error) {
+	out := &limitedBuffer{max: maxRenderedPageSize}
+	done := make(chan error, 1)
+	go func() {
+		done <- tmpl.ExecuteTemplate(out, models.PageTemplateLayout, view)
+	}()
+
+	timer := time.NewTimer(pageRenderTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	case <-timer.C:
+		out.aborted.Store(true)
+		return nil, ErrPageRenderTimeout
+	}
+}
+
+// ============================================
+// SANDBOX
+// ============================================
+
+// forbiddenPageMarkup is markup an override may not contain, whatever the
+// CSP would do with it
+var forbiddenPageMarkup = []struct {
+	pattern *regexp.Regexp
+	reason  string
+}{
+	{regexp.MustCompile(`(?i)<\s*/?\s*(script|iframe|frame|frameset|object|embed|applet|base|form)\b`), "tag is not allowed"},
+	{regexp.MustCompile(`(?i)\bon[a-z]+\s*=`), "event handler attributes are not allowed"},
+	{regexp.MustCompile(`(?i)\b(javascript|vbscript)\s*:`), "script URLs are not allowed"},
+	{regexp.MustCompile(`(?i)\b(srcdoc|formaction|http-equiv)\b`), "attribute is not allowed"},
+	{regexp.MustCompile(`(?i)(@import|expression\s*\()`), "CSS construct is not allowed"},
+	{regexp.MustCompile(`\{\{-?\s*(define|block)\b`), "templates cannot define templates"},
+}
+
+var templateCallPattern = regexp.MustCompile(`\{\{-?\s*template\s+"([^"]*)"`)
+
+// ValidatePageTemplate checks an override: size, forbidden markup, that it
+// parses, and that it renders against sample data for every page it is used by
+func ValidatePageTemplate(page, locale, content string) error {
+	if !IsPageTemplatePage(page) || !IsPageLocale(locale) {
+		return ErrUnknownPageTemplate
+	}
+	if strings.TrimSpace(content) == "" {
+		return fmt.Errorf("%w: template is empty", ErrInvalidPageTemplate)
+	}
+	if len(content) > MaxPageTemplateSize {
+		return fmt.Errorf("%w: template exceeds %d bytes", ErrInvalidPageTemplate, MaxPageTemplateSize)
+	}
+	if !utf8.ValidString(content) {
+		return fmt.Errorf("%w: template is not valid UTF-8", ErrInvalidPageTemplate)
+	}
+
+	for _, rule := range forbiddenPageMarkup {
+		if match := rule.pattern.FindString(content); match != "" {
+			return fmt.Errorf("%w: %s (%q)", ErrInvalidPageTemplate, rule.reason, match)
+		}
+	}
+
+	// Only the layout may (and must) include the page content
+	calls := templateCallPattern.FindAllStringSubmatch(content, -1)
+	for _, call := range calls {
+		if page != models.PageTemplateLayout || call[1] != "content" {
+			return fmt.Errorf("%w: templates cannot include %q", ErrInvalidPageTemplate, call[1])
+		}
+	}
+	if page == models.PageTemplateLayout && len(calls) == 0 {
+		return fmt.Errorf("%w: the layout must include {{template \"content\" .}}", ErrInvalidPageTemplate)
+	}
+
+	pages := []string{page}
+	if page == models.PageTemplateLayout {
+		pages = []string{models.PageTemplatePromoterLanding, models.PageTemplateTeamInvite, models.PageTemplateError}
+	}
+	for _, p := range pages {
+		layout, body := builtinPageTemplates[models.PageTemplateLayout], builtinPageTemplates[p]
+		if page == models.PageTemplateLayout {
+			layout = content
+		} else {
+			body = content
+		}
+		tmpl, err := compilePageTemplate(layout, body)
+		if err != nil {
+			return err
+		}
+		if _, err := executePageTemplate(tmpl, samplePageView(p, locale, defaultPageBranding())); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidPageTemplate, err)
+		}
+	}
+	return nil
+}
+
+// ============================================
+// OVERRIDE MANAGEMENT
+// ============================================
+
+// PageTemplateInfo describes the effective template of a page in a locale
+type PageTemplateInfo struct {
+	Page       string     `json:"page"`
+	Locale     string     `json:"locale"`
+	Overridden bool       `json:"overridden"`
+	Content    string     `json:"content"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+	UpdatedBy  *uuid.UUID `json:"updated_by,omitempty"`
+}
+
+func pageTemplateInfo(page, locale string, override *models.TenantPageTemplate) PageTemplateInfo {
+	info := PageTemplateInfo{Page: page, Locale: locale, Content: builtinPageTemplates[page]}
+	if override != nil {
+		info.Overridden = true
+		info.Content = override.Content
+		info.UpdatedAt = &override.UpdatedAt
+		info.UpdatedBy = override.UpdatedBy
+	}
+	return info
+}
+
+// ListTemplates returns every page/locale of a tenant with its effective content
+func (s *PageTemplateService) ListTemplates(tenantID uuid.UUID) ([]PageTemplateInfo, error) {
+	var overrides []models.TenantPageTemplate
+	if err := s.db.Where("tenant_id = ?", tenantID).Find(&overrides).Error; err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]*models.TenantPageTemplate, len(overrides))
+	for i := range overrides {
+		byKey[overrides[i].Page+"|"+overrides[i].Locale] = &overrides[i]
+	}
+
+	var infos []PageTemplateInfo
+	for _, page := range models.PageTemplatePages {
+		for _, locale := range models.PageTemplateLocales {
+			infos = append(infos, pageTemplateInfo(page, locale, byKey[page+"|"+locale]))
+		}
+	}
+	return infos, nil
+}
+
+// GetTemplate returns a tenant's effective template for a page and locale
+func (s *PageTemplateService) GetTemplate(tenantID uuid.UUID, page, locale string) (*PageTemplateInfo, error) {
+	if !IsPageTemplatePage(page) || !IsPageLocale(locale) {
+		return nil, ErrUnknownPageTemplate
+	}
+	override, err := s.findOverride(tenantID, page, locale)
+	if err != nil {
+		return nil, err
+	}
+	info := pageTemplateInfo(page, locale, override)
+	return &info, nil
+}
+
+func (s *PageTemplateService) findOverride(tenantID uuid.UUID, page, locale string) (*models.TenantPageTemplate, error) {
+	var override models.TenantPageTemplate
+	err := s.db.Where("tenant_id = ? AND page = ? AND locale = ?", tenantID, page, locale).First(&override).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &override, nil
+}
+
+// SaveTemplate validates and stores a tenant override
+func (s *PageTemplateService) SaveTemplate(tenantID uuid.UUID, page, locale, content string, actorID *uuid.UUID) (*PageTemplateInfo, error) {
+	if err := ValidatePageTemplate(page, locale, content); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.findOverride(tenantID, page, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	var oldContent interface{}
+	override := existing
+	if override == nil {
+		override = &models.TenantPageTemplate{ID: uuid.New(), TenantID: tenantID, Page: page, Locale: locale}
+	} else {
+		oldContent = map[string]interface{}{"page": page, "locale": locale, "size": len(existing.Content)}
+	}
+	override.Content = content
+	override.UpdatedBy = actorID
+
+	if err := s.db.Save(override).Error; err != nil {
+		return nil, err
+	}
+
+	s.Invalidate(tenantID)
+	s.tenants.logAudit(tenantID, models.TenantAuditPageTemplateUpdated, actorID, oldContent,
+		map[string]interface{}{"page": page, "locale": locale, "size": len(content)})
+
+	info := pageTemplateInfo(page, locale, override)
+	return &info, nil
+}
+
+// DeleteTemplate removes a tenant override, reverting the page to the built-in
+func (s *PageTemplateService) DeleteTemplate(tenantID uuid.UUID, page, locale string, actorID *uuid.UUID) error {
+	if !IsPageTemplatePage(page) || !IsPageLocale(locale) {
+		return ErrUnknownPageTemplate
+	}
+	result := s.db.Where("tenant_id = ? AND page = ? AND locale = ?", tenantID, page, locale).
+		Delete(&models.TenantPageTemplate{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	s.Invalidate(tenantID)
+	s.tenants.logAudit(tenantID, models.TenantAuditPageTemplateDeleted, actorID,
+		map[string]interface{}{"page": page, "locale": locale}, nil)
+	return nil
+}
+
+// Preview renders a page with sample data, the tenant's branding and either
+// a draft (when non-nil) or the tenant's current templates. Previewing the
+// layout renders the promoter page inside it.
+func (s *PageTemplateService) Preview(tenantID uuid.UUID, page, locale string, draft *string) (*RenderedPage, error) {
+	if !IsPageTemplatePage(page) || !IsPageLocale(locale) {
+		return nil, ErrUnknownPageTemplate
+	}
+	if draft != nil {
+		if err := ValidatePageTemplate(page, locale, *draft); err != nil {
+			return nil, err
+		}
+	}
+
+	brand, _ := s.Branding(tenantID)
+	target := page
+	if page == models.PageTemplateLayout {
+		target = models.PageTemplatePromoterLanding
+	}
+
+	layout, content := builtinPageTemplates[models.PageTemplateLayout], builtinPageTemplates[target]
+	for _, p := range []string{models.PageTemplateLayout, target} {
+		override, err := s.findOverride(tenantID, p, locale)
+		if err != nil {
+			return nil, err
+		}
+		if override == nil {
+			continue
+		}
+		if p == models.PageTemplateLayout {
+			layout = override.Content
+		} else {
+			content = override.Content
+		}
+	}
+	if draft != nil {
+		if page == models.PageTemplateLayout {
+			layout = *draft
+		} else {
+			content = *draft
+		}
+	}
+
+	tmpl, err := compilePageTemplate(layout, content)
+	if err != nil {
+		return nil, err
+	}
+	view := samplePageView(target, locale, brand)
+	html, err := executePageTemplate(tmpl, view)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPageTemplate, err)
+	}
+
+	return &RenderedPage{Status: 200, HTML: html, Nonce: view.Nonce}, nil
+}
+
+// samplePageView is the data used for previews and override validation
+func samplePageView(page, locale string, brand PageBranding) *PageView {
+	view := newPageView(locale, brand)
+	switch page {
+	case models.PageTemplatePromoterLanding:
+		fillPromoterView(view, &PromoterPageData{
+			ID:          "00000000-0000-0000-0000-000000000000",
+			Name:        "Sara Ahmed",
+			Username:    "sara",
+			Bio:         view.T["sample_bio"],
+			Rating:      4.8,
+			TotalClicks: 12840,
+			TotalOffers: 2,
+			Offers: []PageOffer{
+				{Title: "Premium VPN", Description: view.T["sample_offer"], Category: "apps", Payout: "$12 CPA", Link: "#"},
+				{Title: "Fashion Store", Description: view.T["sample_offer"], Category: "shopping", Payout: "8%", Link: "#"},
+			},
+		})
+	case models.PageTemplateTeamInvite:
+		fillTeamView(view, &TeamPageData{
+			Name:         "Growth Squad",
+			Description:  view.T["sample_team"],
+			InviteCode:   "abc12345",
+			MembersCount: 2,
+			Conversions:  310,
+			Clicks:       9200,
+			Members: []TeamPageMember{
+				{Name: "Sara Ahmed", Username: "sara", IsOwner: true},
+				{Name: "Omar Ali", Username: "omar"},
+			},
+		})
+	default:
+		fillErrorView(view, 404, PageErrorInviteInvalid)
+	}
+	return view
+}
+
+// ============================================
+// TRANSLATIONS
+// ============================================
+
+var pageTranslations = map[string]map[string]string{
+	models.PageLocaleArabic: {
+		"promoter_page_title":        "%s - %s",
+		"team_page_title":            "انضم لفريق %s - %s",
+		"active_offers":              "عروض نشطة",
+		"clicks":                     "نقرات",
+		"rating":                     "التقييم",
+		"rate_promoter":              "قيّم هذا المسوّق",
+		"offers":                     "العروض",
+		"get_offer":                  "احصل على العرض",
+		"no_offers":                  "لا توجد عروض متاحة حالياً",
+		"members":                    "الأعضاء",
+		"conversions":                "تحويلات",
+		"owner":                      "المالك",
+		"join_team":                  "انضم للفريق",
+		"invite_code":                "رمز الدعوة",
+		"visit_site":                 "زيارة الموقع",
+		"powered_by":                 "مدعوم من",
+		"invite_invalid_title":       "رابط الدعوة غير صالح",
+		"invite_invalid_message":     "هذا الرابط غير موجود أو منتهي الصلاحية. تأكد من صحة الرابط أو تواصل مع صاحب الدعوة.",
+		"promoter_not_found_title":   "المسوّق غير موجود",
+		"promoter_not_found_message": "لم نجد هذه الصفحة. تأكد من صحة الرابط.",
+		"generic_error_title":        "حدث خطأ",
+		"generic_error_message":      "تعذّر عرض هذه الصفحة. حاول مرة أخرى لاحقاً.",
+		"sample_bio":                 "أشارك أفضل العروض يومياً",
+		"sample_offer":               "وصف قصير للعرض",
+		"sample_team":                "فريق تسويق بالعمولة",
+	},
+	models.PageLocaleEnglish: {
+		"promoter_page_title":        "%s - %s",
+		"team_page_title":            "Join %s - %s",
+		"active_offers":              "Active offers",
+		"clicks":                     "Clicks",
+		"rating":                     "Rating",
+		"rate_promoter":              "Rate this promoter",
+		"offers":                     "Offers",
+		"get_offer":                  "Get offer",
+		"no_offers":                  "No offers available right now",
+		"members":                    "Members",
+		"conversions":                "Conversions",
+		"owner":                      "Owner",
+		"join_team":                  "Join the team",
+		"invite_code":                "Invite code",
+		"visit_site":                 "Visit website",
+		"powered_by":                 "Powered by",
+		"invite_invalid_title":       "Invalid invite link",
+		"invite_invalid_message":     "This link does not exist or has expired. Check the link or contact the person who invited you.",
+		"promoter_not_found_title":   "Promoter not found",
+		"promoter_not_found_message": "We could not find this page. Check that the link is correct.",
+		"generic_error_title":        "Something went wrong",
+		"generic_error_message":      "This page could not be displayed. Please try again later.",
+		"sample_bio":                 "Sharing the best deals every day",
+		"sample_offer":               "A short offer description",
+		"sample_team":                "An affiliate marketing team",
+	},
+}
+
+// ============================================
+// BUILT-IN TEMPLATES
+// ============================================
+
+var builtinPageTemplates = map[string]string{
+	models.PageTemplateLayout: `<!DOCTYPE html>
+<html lang="{{.Locale}}" dir="{{.Dir}}">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>{{.Title}}</title>
+{{if .Description}}<meta name="description" content="{{.Description}}">
+<meta property="og:description" content="{{.Description}}">{{end}}
+<meta property="og:title" content="{{.Title}}">
+{{if .Image}}<meta property="og:image" content="{{.Image}}">{{end}}
+<meta property="og:type" content="website">
+{{if .Brand.FaviconURL}}<link rel="icon" href="{{.Brand.FaviconURL}}">{{end}}
+<style>
+:root { --primary: {{.Brand.PrimaryColor}}; --secondary: {{.Brand.SecondaryColor}}; }
+* { margin: 0; padding: 0; box-sizing: border-box; }
+body { font-family: 'Segoe UI', Roboto, Tahoma, sans-serif; background: #0a0a0a; color: #fff; min-height: 100vh; display: flex; flex-direction: column; }
+header, footer { text-align: center; padding: 20px; }
+header img { max-height: 40px; }
+header span { font-size: 22px; font-weight: 700; color: var(--primary); }
+main { flex: 1; width: 100%; max-width: 720px; margin: 0 auto; padding: 0 16px 32px; }
+footer { color: #666; font-size: 13px; }
+footer a { color: var(--primary); text-decoration: none; }
+h1 { font-size: 26px; margin: 12px 0 6px; }
+h2 { font-size: 20px; margin: 28px 0 12px; }
+.card { background: rgba(255,255,255,0.04); border: 1px solid #2a2a2a; border-radius: 18px; padding: 20px; margin-bottom: 14px; }
+.center { text-align: center; }
+.muted { color: #999; line-height: 1.6; }
+.small { font-size: 13px; margin-top: 12px; }
+.avatar { width: 96px; height: 96px; border-radius: 50%; object-fit: cover; margin: 0 auto; display: flex; align-items: center; justify-content: center; font-size: 40px; background: linear-gradient(135deg, var(--primary), var(--secondary)); }
+.stats { display: flex; justify-content: space-around; margin-top: 18px; }
+.stats strong { display: block; font-size: 22px; }
+.stats span { color: #999; font-size: 13px; }
+.btn { display: inline-block; margin-top: 18px; padding: 12px 28px; border-radius: 50px; color: #fff; font-weight: 600; text-decoration: none; background: linear-gradient(135deg, var(--primary), var(--secondary)); }
+.tag { display: inline-block; padding: 2px 10px; border-radius: 10px; font-size: 12px; background: rgba(255,255,255,0.08); }
+.offer img { width: 100%; max-height: 180px; object-fit: cover; border-radius: 12px; margin-bottom: 12px; }
+.offer-footer { display: flex; align-items: center; justify-content: space-between; }
+.offer-footer .btn { margin-top: 0; }
+.payout { font-weight: 700; color: var(--primary); }
+.rate { margin-top: 16px; }
+.rate button { background: none; border: none; color: #555; font-size: 24px; cursor: pointer; }
+.rate button:hover { color: var(--primary); }
+.members li { list-style: none; padding: 8px 0; border-bottom: 1px solid #222; }
+.members li:last-child { border-bottom: none; }
+</style>
+</head>
+<body>
+<header>{{if .Brand.LogoURL}}<img src="{{.Brand.LogoURL}}" alt="{{.Brand.Name}}">{{else}}<span>{{.Brand.Name}}</span>{{end}}</header>
+<main>
+{{template "content" .}}
+</main>
+<footer>{{if .Brand.PoweredBy}}{{.T.powered_by}} <a href="https://afftokapp.com">AffTok</a>{{else if .Brand.HomeURL}}<a href="{{.Brand.HomeURL}}">{{.Brand.Name}}</a>{{end}}</footer>
+</body>
+</html>`,
+
+	models.PageTemplatePromoterLanding: `<section class="card center">
+{{if .Promoter.AvatarURL}}<img class="avatar" src="{{.Promoter.AvatarURL}}" alt="{{.Promoter.Name}}">{{else}}<div class="avatar">{{.Promoter.Initial}}</div>{{end}}
+<h1>{{.Promoter.Name}}</h1>
+<p class="muted">@{{.Promoter.Username}}</p>
+{{if .Promoter.Bio}}<p class="muted">{{.Promoter.Bio}}</p>{{end}}
+<div class="stats">
+<div><strong>{{.Promoter.TotalOffers}}</strong><span>{{.T.active_offers}}</span></div>
+<div><strong>{{.Promoter.TotalClicks}}</strong><span>{{.T.clicks}}</span></div>
+<div><strong id="rating">{{printf "%.1f" .Promoter.Rating}}</strong><span>{{.T.rating}}</span></div>
+</div>
+<div class="rate"><span class="muted">{{.T.rate_promoter}}</span>
+<button type="button" data-rating="1">★</button><button type="button" data-rating="2">★</button><button type="button" data-rating="3">★</button><button type="button" data-rating="4">★</button><button type="button" data-rating="5">★</button>
+</div>
+</section>
+<h2>{{.T.offers}}</h2>
+{{range .Promoter.Offers}}<article class="card offer">
+{{if .ImageURL}}<img src="{{.ImageURL}}" alt="{{.Title}}">{{end}}
+<h3>{{.Title}}</h3>
+{{if .Category}}<span class="tag">{{.Category}}</span>{{end}}
+<p class="muted">{{.Description}}</p>
+<div class="offer-footer"><span class="payout">{{.Payout}}</span><a class="btn" href="{{.Link}}" rel="nofollow">{{$.T.get_offer}}</a></div>
+</article>
+{{else}}<p class="muted center">{{.T.no_offers}}</p>
+{{end}}
+<script nonce="{{.Nonce}}">
+document.querySelectorAll('.rate button').forEach(function (star) {
+  star.addEventListener('click', function () {
+    fetch('/api/rate-promoter', {
+      method: 'POST',
+      headers: {'Content-Type': 'application/json'},
+      body: JSON.stringify({promoter_id: {{.Promoter.ID}}, rating: Number(star.dataset.rating)})
+    }).then(function (r) { return r.json(); }).then(function (d) {
+      if (d.average_rating) { document.getElementById('rating').textContent = d.average_rating.toFixed(1); }
+    });
+  });
+});
+</script>`,
+
+	models.PageTemplateTeamInvite: `<section class="card center">
+{{if .Team.LogoURL}}<img class="avatar" src="{{.Team.LogoURL}}" alt="{{.Team.Name}}">{{end}}
+<h1>{{.Team.Name}}</h1>
+{{if .Team.Description}}<p class="muted">{{.Team.Description}}</p>{{end}}
+<div class="stats">
+<div><strong>{{.Team.MembersCount}}</strong><span>{{.T.members}}</span></div>
+<div><strong>{{.Team.Conversions}}</strong><span>{{.T.conversions}}</span></div>
+<div><strong>{{.Team.Clicks}}</strong><span>{{.T.clicks}}</span></div>
+</div>
+<a class="btn" href="{{.Team.JoinURL}}">{{.T.join_team}}</a>
+<p class="muted small">{{.T.invite_code}}: <code>{{.Team.InviteCode}}</code></p>
+</section>
+{{if .Team.Members}}<h2>{{.T.members}}</h2>
+<ul class="card members">
+{{range .Team.Members}}<li>{{.Name}} <span class="muted">@{{.Username}}</span>{{if .IsOwner}} <span class="tag">{{$.T.owner}}</span>{{end}}</li>
+{{end}}</ul>{{end}}`,
+
+	models.PageTemplateError: `<section class="card center">
+<h1>{{.Error.Title}}</h1>
+<p class="muted">{{.Error.Message}}</p>
+{{if .Brand.HomeURL}}<a class="btn" href="{{.Brand.HomeURL}}">{{.T.visit_site}}</a>{{end}}
+</section>`,
+}
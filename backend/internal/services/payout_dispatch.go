@@ -0,0 +1,592 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+)
+
+// ============================================================
+// Payout Rails - إرسال الدفعات عبر قنوات الدفع
+// ============================================================
+// submit → lines get the rail of the promoter's default payout method →
+// one rail submission per batch × rail → results arrive by polling,
+// signed webhooks or the reconciliation upload → ReconcileBatch
+
+const (
+	// payoutDispatchTimeout bounds one dispatch or poll of a batch
+	payoutDispatchTimeout = 2 * time.Minute
+	// defaultRailPollInterval is how often submitted rail batches are polled
+	defaultRailPollInterval = 15 * time.Minute
+)
+
+// Payout method errors
+var (
+	ErrPayoutMethodNotFound = errors.New("payout method not found")
+	ErrPayoutMethodRail     = errors.New("payout rail not available for payout methods")
+)
+
+// ============================================================
+// Payout methods - وسائل استلام الأرباح
+// ============================================================
+
+// ListPayoutMethods returns a promoter's payout methods, default first
+func (s *PayoutService) ListPayoutMethods(userID uuid.UUID) ([]models.PayoutMethod, error) {
+	var methods []models.PayoutMethod
+	err := s.db.Where("user_id = ?", userID).Order("is_default DESC, created_at DESC").Find(&methods).Error
+	return methods, err
+}
+
+// AddPayoutMethod validates a payout method with its rail and stores it.
+// Invalid details are stored with status invalid and the rail's reason;
+// a promoter's first verified method becomes the default.
+func (s *PayoutService) AddPayoutMethod(ctx context.Context, tenantID uuid.UUID, method *models.PayoutMethod) error {
+	rail, err := s.rails.Get(method.Rail)
+	if err != nil || method.Rail == models.PayoutRailManual {
+		return fmt.Errorf("%w: %s", ErrPayoutMethodRail, method.Rail)
+	}
+	method.TenantID = tenantID
+	method.IBAN = NormalizeIBAN(method.IBAN)
+	method.BIC = strings.ToUpper(strings.TrimSpace(method.BIC))
+	method.Country = strings.ToUpper(method.Country)
+	method.Currency = NormalizeCurrency(method.Currency)
+
+	var user models.AfftokUser
+	if err := s.db.Select("id, username, full_name").First(&user, "id = ?", method.UserID).Error; err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := rail.ValidatePayee(ctx, PayoutPayeeFromMethod(method, &user)); err != nil {
+		if !errors.Is(err, ErrPayoutPayeeInvalid) {
+			return err
+		}
+		method.Status, method.ValidationError, method.IsDefault = models.PayoutMethodInvalid, err.Error(), false
+	} else {
+		method.Status, method.ValidationError, method.VerifiedAt = models.PayoutMethodVerified, "", &now
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if method.Status == models.PayoutMethodVerified && !method.IsDefault {
+			var count int64
+			tx.Model(&models.PayoutMethod{}).Where("user_id = ? AND is_default", method.UserID).Count(&count)
+			method.IsDefault = count == 0
+		}
+		if method.IsDefault {
+			if err := tx.Model(&models.PayoutMethod{}).Where("user_id = ?", method.UserID).Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(method).Error
+	})
+}
+
+// SetDefaultPayoutMethod makes a verified method the one future payouts use
+func (s *PayoutService) SetDefaultPayoutMethod(userID, methodID uuid.UUID) (*models.PayoutMethod, error) {
+	var method models.PayoutMethod
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).First(&method, "id = ?", methodID).Error; err != nil {
+			return ErrPayoutMethodNotFound
+		}
+		if method.Status != models.PayoutMethodVerified {
+			return fmt.Errorf("%w: payout method is %s", ErrPayoutPayeeInvalid, method.Status)
+		}
+		if err := tx.Model(&models.PayoutMethod{}).Where("user_id = ?", userID).Update("is_default", false).Error; err != nil {
+			return err
+		}
+		method.IsDefault = true
+		return tx.Model(&method).Update("is_default", true).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &method, nil
+}
+
+// DeletePayoutMethod removes a payout method; lines already submitted keep
+// their rail
+func (s *PayoutService) DeletePayoutMethod(userID, methodID uuid.UUID) error {
+	result := s.db.Where("user_id = ?", userID).Delete(&models.PayoutMethod{}, "id = ?", methodID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPayoutMethodNotFound
+	}
+	return nil
+}
+
+// payoutRailFor picks the rail a promoter is paid through: the default
+// verified payout method, else a verified Payoneer account, else manual
+func (s *PayoutService) payoutRailFor(tx *gorm.DB, promoterID uuid.UUID) (string, *uuid.UUID) {
+	var method models.PayoutMethod
+	if err := tx.Where("user_id = ? AND status = ?", promoterID, models.PayoutMethodVerified).
+		Order("is_default DESC, verified_at DESC").First(&method).Error; err == nil {
+		return method.Rail, &method.ID
+	}
+	var promoter models.AfftokUser
+	if tx.Select("id, payoneer_email, payoneer_status").First(&promoter, "id = ?", promoterID).Error == nil &&
+		promoter.PayoneerEmail != "" && promoter.PayoneerStatus == "verified" {
+		return models.PayoutRailPayoneer, nil
+	}
+	return models.PayoutRailManual, nil
+}
+
+// assignRails stamps the rail on every line a submitted batch sends out
+func (s *PayoutService) assignRails(tx *gorm.DB, batch *models.PayoutBatch) error {
+	var lines []models.Payout
+	if err := tx.Select("id, publisher_id").Where("batch_id = ? AND status = ?", batch.ID, models.PayoutStatusProcessing).
+		Find(&lines).Error; err != nil {
+		return err
+	}
+	type route struct {
+		rail     string
+		methodID *uuid.UUID
+	}
+	routes := make(map[uuid.UUID]route)
+	for _, line := range lines {
+		r, ok := routes[line.PublisherID]
+		if !ok {
+			r.rail, r.methodID = s.payoutRailFor(tx, line.PublisherID)
+			routes[line.PublisherID] = r
+		}
+		if err := tx.Model(&models.Payout{}).Where("id = ?", line.ID).
+			Updates(map[string]interface{}{"rail": r.rail, "payout_method_id": r.methodID}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linePayee loads where a line is paid to
+func (s *PayoutService) linePayee(line *models.Payout) (*PayoutPayee, error) {
+	var user models.AfftokUser
+	if err := s.db.Select("id, username, full_name, email, payoneer_email").First(&user, "id = ?", line.PublisherID).Error; err != nil {
+		return nil, err
+	}
+	if line.PayoutMethodID != nil {
+		var method models.PayoutMethod
+		if err := s.db.First(&method, "id = ?", *line.PayoutMethodID).Error; err != nil {
+			return nil, fmt.Errorf("%w: payout method was removed", ErrPayoutPayeeInvalid)
+		}
+		return PayoutPayeeFromMethod(&method, &user), nil
+	}
+	// Payoneer account registered from the profile: payee ID is the user ID
+	name := user.FullName
+	if name == "" {
+		name = user.Username
+	}
+	return &PayoutPayee{UserID: user.ID, Name: name, AccountName: name, Email: user.PayoneerEmail, PayeeID: user.ID.String()}, nil
+}
+
+// ============================================================
+// Dispatch - الإرسال
+// ============================================================
+
+// PayoutRailDispatch is the outcome of sending a batch's lines to one rail
+type PayoutRailDispatch struct {
+	Rail         string `json:"rail"`
+	Items        int    `json:"items"`
+	InvalidPayee int    `json:"invalid_payee"`
+	ExternalRef  string `json:"external_ref,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// DispatchBatch sends the processing lines of a submitted batch that no rail
+// has accepted yet. Each rail gets one submission per batch with a stable
+// reference, so retrying after an error cannot pay a line twice. Lines whose
+// payee fails validation are reconciled as failed and carried over.
+func (s *PayoutService) DispatchBatch(ctx context.Context, tenantID, batchID uuid.UUID, actor *uuid.UUID) ([]PayoutRailDispatch, error) {
+	var batch models.PayoutBatch
+	if err := s.db.Where("tenant_id = ?", tenantID).First(&batch, "id = ?", batchID).Error; err != nil {
+		return nil, ErrPayoutBatchNotFound
+	}
+	if batch.Status != models.BatchStatusSubmitted {
+		return nil, fmt.Errorf("%w: batch is %s", ErrPayoutTransition, batch.Status)
+	}
+
+	var lines []models.Payout
+	if err := s.db.Where("batch_id = ? AND status = ? AND rail <> ? AND COALESCE(external_ref, '') = ''",
+		batch.ID, models.PayoutStatusProcessing, models.PayoutRailManual).
+		Order("created_at").Find(&lines).Error; err != nil {
+		return nil, err
+	}
+	byRail := make(map[string][]models.Payout)
+	var order []string
+	for _, line := range lines {
+		if _, ok := byRail[line.Rail]; !ok {
+			order = append(order, line.Rail)
+		}
+		byRail[line.Rail] = append(byRail[line.Rail], line)
+	}
+
+	var report []PayoutRailDispatch
+	var settlements []PayoutSettlement
+	for _, name := range order {
+		dispatch, failed := s.dispatchRail(ctx, &batch, name, byRail[name])
+		report = append(report, dispatch)
+		settlements = append(settlements, failed...)
+	}
+
+	if len(settlements) > 0 {
+		if _, err := s.ReconcileBatch(tenantID, batch.ID, settlements, actor); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// dispatchRail sends one rail's lines; it returns settlements for lines that
+// were rejected or already settled by the rail
+func (s *PayoutService) dispatchRail(ctx context.Context, batch *models.PayoutBatch, name string, lines []models.Payout) (PayoutRailDispatch, []PayoutSettlement) {
+	dispatch := PayoutRailDispatch{Rail: name}
+	submission := models.PayoutRailSubmission{
+		BatchID:   batch.ID,
+		Rail:      name,
+		Reference: fmt.Sprintf("afftok-%s-%s", batch.ID.String(), name),
+		Status:    models.RailSubmissionError,
+	}
+	submission.TenantID = batch.TenantID
+	s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&submission)
+	if err := s.db.Where("batch_id = ? AND rail = ?", batch.ID, name).First(&submission).Error; err != nil {
+		dispatch.Error = err.Error()
+		return dispatch, nil
+	}
+	if submission.Status == models.RailSubmissionSubmitted || submission.Status == models.RailSubmissionCompleted {
+		// Already accepted by the rail; new results arrive by polling
+		dispatch.ExternalRef = submission.ExternalRef
+		return dispatch, nil
+	}
+
+	fail := func(err error) (PayoutRailDispatch, []PayoutSettlement) {
+		dispatch.Error = err.Error()
+		s.db.Model(&submission).Updates(map[string]interface{}{
+			"status": models.RailSubmissionError, "error": dispatch.Error, "updated_at": time.Now(),
+		})
+		return dispatch, nil
+	}
+
+	rail, err := s.rails.Get(name)
+	if err != nil {
+		return fail(err)
+	}
+
+	var settlements []PayoutSettlement
+	request := &PayoutRailBatch{Reference: submission.Reference, Period: batch.Period}
+	for i := range lines {
+		line := &lines[i]
+		payee, err := s.linePayee(line)
+		if err == nil {
+			err = rail.ValidatePayee(ctx, payee)
+		}
+		if err != nil {
+			if !errors.Is(err, ErrPayoutPayeeInvalid) {
+				return fail(err)
+			}
+			settlements = append(settlements, PayoutSettlement{PayoutID: line.ID, Status: models.PayoutStatusFailed, Reason: err.Error()})
+			dispatch.InvalidPayee++
+			continue
+		}
+		request.Items = append(request.Items, PayoutRailItem{
+			PayoutID:    line.ID,
+			Payee:       *payee,
+			Amount:      MoneyFromMajor(line.NetAmount, line.Currency),
+			Description: "AffTok earnings " + line.Period,
+		})
+	}
+	if len(request.Items) == 0 {
+		s.db.Model(&submission).Updates(map[string]interface{}{"status": models.RailSubmissionCompleted, "error": "", "updated_at": time.Now()})
+		return dispatch, settlements
+	}
+
+	receipt, err := rail.SubmitBatch(ctx, request)
+	if err != nil {
+		// Rejected payees are still settled; the rest is retried later
+		dispatch.Error = err.Error()
+		s.db.Model(&submission).Updates(map[string]interface{}{
+			"status": models.RailSubmissionError, "error": dispatch.Error, "updated_at": time.Now(),
+		})
+		return dispatch, settlements
+	}
+
+	dispatch.Items = len(request.Items)
+	dispatch.ExternalRef = receipt.ExternalRef
+	updates := map[string]interface{}{
+		"status": models.RailSubmissionSubmitted, "external_ref": receipt.ExternalRef, "items": dispatch.Items,
+		"error": "", "updated_at": time.Now(),
+	}
+	if receipt.File != nil {
+		updates["file_name"], updates["file_type"], updates["file_content"] = receipt.File.Name, receipt.File.ContentType, receipt.File.Content
+	}
+	s.db.Model(&submission).Updates(updates)
+
+	settled := s.applyRailResults(receipt.Results)
+	return dispatch, append(settlements, settled...)
+}
+
+// applyRailResults stores the rail's payment IDs on pending lines and returns
+// settlements for the results that are final
+func (s *PayoutService) applyRailResults(results []PayoutRailItemResult) []PayoutSettlement {
+	var settlements []PayoutSettlement
+	for _, result := range results {
+		if result.Settled() {
+			settlements = append(settlements, PayoutSettlement{
+				PayoutID: result.PayoutID, Status: result.Status, Reference: result.ItemRef,
+				Reason: result.Reason, PaidAt: result.PaidAt,
+			})
+			continue
+		}
+		ref := result.ItemRef
+		if ref == "" {
+			ref = "pending"
+		}
+		s.db.Model(&models.Payout{}).Where("id = ? AND status = ?", result.PayoutID, models.PayoutStatusProcessing).
+			Update("external_ref", ref)
+	}
+	return settlements
+}
+
+// ============================================================
+// Status polling and webhooks - متابعة النتائج
+// ============================================================
+
+// PollBatch asks each rail of a submitted batch for results and reconciles
+// the settled lines
+func (s *PayoutService) PollBatch(ctx context.Context, tenantID, batchID uuid.UUID, actor *uuid.UUID) (*PayoutReconciliation, error) {
+	var submissions []models.PayoutRailSubmission
+	if err := s.db.Where("tenant_id = ? AND batch_id = ? AND status = ?", tenantID, batchID, models.RailSubmissionSubmitted).
+		Find(&submissions).Error; err != nil {
+		return nil, err
+	}
+
+	var settlements []PayoutSettlement
+	for i := range submissions {
+		submission := &submissions[i]
+		rail, err := s.rails.Get(submission.Rail)
+		if err != nil {
+			log.Printf("[Payouts] poll %s: %v", submission.Reference, err)
+			continue
+		}
+		var lines []models.Payout
+		s.db.Select("id, external_ref").Where("batch_id = ? AND rail = ? AND status = ?", batchID, submission.Rail, models.PayoutStatusProcessing).
+			Find(&lines)
+		if len(lines) == 0 {
+			s.db.Model(submission).Updates(map[string]interface{}{"status": models.RailSubmissionCompleted, "updated_at": time.Now()})
+			continue
+		}
+
+		ref := &PayoutRailRef{Reference: submission.Reference, ExternalRef: submission.ExternalRef, Items: make(map[uuid.UUID]string, len(lines))}
+		for _, line := range lines {
+			ref.Items[line.ID] = line.ExternalRef
+		}
+		results, err := rail.PollStatus(ctx, ref)
+		now := time.Now()
+		if err != nil {
+			s.db.Model(submission).Updates(map[string]interface{}{"error": err.Error(), "last_polled_at": now})
+			continue
+		}
+		s.db.Model(submission).Updates(map[string]interface{}{"error": "", "last_polled_at": now})
+
+		// Only lines of this submission; a rail cannot settle another batch
+		var own []PayoutRailItemResult
+		for _, result := range results {
+			if _, ok := ref.Items[result.PayoutID]; ok {
+				own = append(own, result)
+			}
+		}
+		settlements = append(settlements, s.applyRailResults(own)...)
+	}
+
+	if len(settlements) == 0 {
+		var batch models.PayoutBatch
+		if err := s.db.Where("tenant_id = ?", tenantID).First(&batch, "id = ?", batchID).Error; err != nil {
+			return nil, ErrPayoutBatchNotFound
+		}
+		report := &PayoutReconciliation{BatchStatus: batch.Status}
+		s.db.Model(&models.Payout{}).Where("batch_id = ? AND status = ?", batchID, models.PayoutStatusProcessing).Count(&report.Outstanding)
+		return report, nil
+	}
+	return s.ReconcileBatch(tenantID, batchID, settlements, actor)
+}
+
+// HandleRailWebhook verifies and applies a rail webhook. Events are recorded
+// by ID so redelivered webhooks are ignored; a failed event is forgotten so
+// the rail's retry is processed again.
+func (s *PayoutService) HandleRailWebhook(ctx context.Context, railName string, headers http.Header, body []byte) (event *PayoutRailEvent, duplicate bool, err error) {
+	rail, err := s.rails.Get(railName)
+	if err != nil {
+		return nil, false, err
+	}
+	event, err = rail.ParseWebhook(ctx, headers, body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	record := &models.BillingWebhookEvent{ID: event.ID, Provider: "payout_" + rail.Name(), Type: event.Type}
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return event, false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return event, true, nil
+	}
+
+	if err = s.applyWebhookResults(rail.Name(), event.Results); err != nil {
+		s.db.Where("id = ? AND provider = ?", record.ID, record.Provider).Delete(&models.BillingWebhookEvent{})
+	}
+	return event, false, err
+}
+
+// applyWebhookResults reconciles webhook results batch by batch
+func (s *PayoutService) applyWebhookResults(railName string, results []PayoutRailItemResult) error {
+	type batchKey struct{ tenantID, batchID uuid.UUID }
+	byBatch := make(map[batchKey][]PayoutSettlement)
+	var order []batchKey
+	for _, result := range results {
+		var line models.Payout
+		if err := s.db.Select("id, tenant_id, batch_id").Where("rail = ? AND batch_id IS NOT NULL", railName).
+			First(&line, "id = ?", result.PayoutID).Error; err != nil {
+			continue
+		}
+		settled := s.applyRailResults([]PayoutRailItemResult{result})
+		if len(settled) == 0 {
+			continue
+		}
+		key := batchKey{line.TenantID, *line.BatchID}
+		if _, ok := byBatch[key]; !ok {
+			order = append(order, key)
+		}
+		byBatch[key] = append(byBatch[key], settled...)
+	}
+	for _, key := range order {
+		if _, err := s.ReconcileBatch(key.tenantID, key.batchID, byBatch[key], nil); err != nil && !errors.Is(err, ErrPayoutTransition) {
+			return err
+		}
+	}
+	return nil
+}
+
+// cancelAtRails cancels a submitted batch at every rail it was sent to
+func (s *PayoutService) cancelAtRails(batch *models.PayoutBatch) error {
+	var paid int64
+	s.db.Model(&models.Payout{}).Where("batch_id = ? AND status = ?", batch.ID, models.PayoutStatusPaid).Count(&paid)
+	if paid > 0 {
+		return fmt.Errorf("%w: %d lines already paid", ErrPayoutRailCannotCancel, paid)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), payoutDispatchTimeout)
+	defer cancel()
+
+	var submissions []models.PayoutRailSubmission
+	if err := s.db.Where("batch_id = ? AND status = ?", batch.ID, models.RailSubmissionSubmitted).Find(&submissions).Error; err != nil {
+		return err
+	}
+	for _, submission := range submissions {
+		rail, err := s.rails.Get(submission.Rail)
+		if err != nil {
+			return err
+		}
+		var lines []models.Payout
+		s.db.Select("id, external_ref").Where("batch_id = ? AND rail = ?", batch.ID, submission.Rail).Find(&lines)
+		ref := &PayoutRailRef{Reference: submission.Reference, ExternalRef: submission.ExternalRef, Items: make(map[uuid.UUID]string, len(lines))}
+		for _, line := range lines {
+			ref.Items[line.ID] = line.ExternalRef
+		}
+		if err := rail.CancelBatch(ctx, ref); err != nil {
+			return fmt.Errorf("%s: %w", submission.Rail, err)
+		}
+	}
+	return nil
+}
+
+// RailSubmissions lists the rail submissions of a batch
+func (s *PayoutService) RailSubmissions(tenantID, batchID uuid.UUID) ([]models.PayoutRailSubmission, error) {
+	var submissions []models.PayoutRailSubmission
+	err := s.db.Where("tenant_id = ? AND batch_id = ?", tenantID, batchID).Order("created_at").Find(&submissions).Error
+	return submissions, err
+}
+
+// RailFile returns the transfer file a file rail produced for a batch
+func (s *PayoutService) RailFile(tenantID, batchID uuid.UUID, rail string) (*models.PayoutRailSubmission, error) {
+	var submission models.PayoutRailSubmission
+	if err := s.db.Where("tenant_id = ? AND batch_id = ? AND rail = ? AND file_name <> ''", tenantID, batchID, rail).
+		First(&submission).Error; err != nil {
+		return nil, ErrPayoutBatchNotFound
+	}
+	return &submission, nil
+}
+
+// ============================================================
+// Background polling - المتابعة الدورية
+// ============================================================
+
+// StartRailPolling polls submitted rail batches every
+// PAYOUT_RAIL_POLL_INTERVAL (default 15m) so results that never arrive by
+// webhook are still reconciled
+func (s *PayoutService) StartRailPolling() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return
+	}
+	interval := defaultRailPollInterval
+	if d, err := time.ParseDuration(os.Getenv("PAYOUT_RAIL_POLL_INTERVAL")); err == nil && d > 0 {
+		interval = d
+	}
+	s.running = true
+	s.stop = make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				s.pollSubmitted()
+			}
+		}
+	}()
+}
+
+// Stop stops the polling job
+func (s *PayoutService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		close(s.stop)
+		s.running = false
+	}
+}
+
+func (s *PayoutService) pollSubmitted() {
+	var batches []models.PayoutRailSubmission
+	if err := s.db.Select("DISTINCT tenant_id, batch_id").Where("status = ?", models.RailSubmissionSubmitted).
+		Find(&batches).Error; err != nil {
+		log.Printf("[Payouts] rail poll: %v", err)
+		return
+	}
+	for _, b := range batches {
+		ctx, cancel := context.WithTimeout(context.Background(), payoutDispatchTimeout)
+		report, err := s.PollBatch(ctx, b.TenantID, b.BatchID, nil)
+		cancel()
+		if err != nil {
+			log.Printf("[Payouts] rail poll of batch %s failed: %v", b.BatchID, err)
+			continue
+		}
+		if report.Paid+report.Failed > 0 {
+			log.Printf("[Payouts] batch %s: %d paid, %d failed, %d outstanding", b.BatchID, report.Paid, report.Failed, report.Outstanding)
+		}
+	}
+}
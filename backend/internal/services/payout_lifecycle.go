@@ -0,0 +1,961 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+)
+
+// ============================================================
+// Payout Lifecycle - دورة حياة الدفعات
+// ============================================================
+// generate → review (hold/release) → approve → submit → reconcile → paid/failed
+//
+// A batch is generated once per tenant and period from the earnings ledger.
+// Lines below the promoter's minimum payout, and lines left unpaid by earlier
+// batches (failed, or held when their batch was submitted), are carried over
+// into the next period's batch. Every state change is written to
+// payout_events.
+
+// Payout hold and carry-over reasons
+const (
+	PayoutHoldReasonFraud      = "fraud_case"           // حالة احتيال مفتوحة على المروج
+	PayoutHoldReasonManual     = "manual"               // حجز يدوي أثناء المراجعة
+	PayoutHoldReasonTaxProfile = "tax_profile_required" // لا يوجد ملف ضريبي مطلوب لبلد المروج
+	PayoutCarryBelowMin        = "below_minimum"        // أقل من الحد الأدنى للسحب
+	PayoutCarryNoBalance       = "no_balance"           // لا يوجد رصيد موجب للدفع
+)
+
+// Payout lifecycle errors
+var (
+	ErrPayoutBatchNotFound = errors.New("payout batch not found")
+	ErrPayoutNotFound      = errors.New("payout not found")
+	ErrPayoutTransition    = errors.New("invalid payout state transition")
+	ErrPayoutPeriodOpen    = errors.New("payout period has not ended yet")
+	ErrPayoutHoldActive    = errors.New("the reason for this hold still applies")
+)
+
+// batchTransitions lists the allowed batch state changes
+var batchTransitions = map[string][]string{
+	models.BatchStatusDraft:     {models.BatchStatusApproved, models.BatchStatusCancelled},
+	models.BatchStatusApproved:  {models.BatchStatusSubmitted, models.BatchStatusDraft, models.BatchStatusCancelled},
+	models.BatchStatusSubmitted: {models.BatchStatusCompleted, models.BatchStatusPartiallyFailed, models.BatchStatusFailed, models.BatchStatusCancelled},
+}
+
+// payoutTransitions lists the allowed payout line state changes
+var payoutTransitions = map[string][]string{
+	models.PayoutStatusPending:    {models.PayoutStatusApproved, models.PayoutStatusOnHold, models.PayoutStatusCancelled, models.PayoutStatusCarriedOver},
+	models.PayoutStatusApproved:   {models.PayoutStatusProcessing, models.PayoutStatusOnHold, models.PayoutStatusPending, models.PayoutStatusCancelled},
+	models.PayoutStatusOnHold:     {models.PayoutStatusPending, models.PayoutStatusCancelled, models.PayoutStatusCarriedOver},
+	models.PayoutStatusProcessing: {models.PayoutStatusPaid, models.PayoutStatusFailed},
+	models.PayoutStatusFailed:     {models.PayoutStatusCarriedOver},
+}
+
+// finishedBatchStatuses are batches whose unpaid lines move on to the next period
+var finishedBatchStatuses = []string{
+	models.BatchStatusSubmitted, models.BatchStatusCompleted,
+	models.BatchStatusPartiallyFailed, models.BatchStatusFailed, models.BatchStatusCancelled,
+}
+
+// CanTransitionBatch reports whether a batch may move from one status to another
+func CanTransitionBatch(from, to string) bool {
+	return containsString(batchTransitions[from], to)
+}
+
+// CanTransitionPayout reports whether a payout line may move from one status to another
+func CanTransitionPayout(from, to string) bool {
+	return containsString(payoutTransitions[from], to)
+}
+
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ============================================================
+// Planning - حساب أسطر الدفعة
+// ============================================================
+
+// PayoutMinimum returns a promoter's minimum payout in a currency (major units)
+type PayoutMinimum func(promoterID uuid.UUID, currency string) float64
+
+// PayoutPlanLine is one line of a batch before it is saved
+type PayoutPlanLine struct {
+	Payout      models.Payout
+	Total       LedgerPeriodTotal // ledger amounts in minor units, including carried amounts
+	CarriedFrom []uuid.UUID       // earlier lines rolled into this one
+}
+
+// PlanPayoutLines builds a period's payout lines (one per advertiser, promoter
+// and currency) from ledger totals and lines carried over from earlier
+// periods. When a promoter's payable total in a currency is below their
+// minimum, all of their lines in that currency are carried over again; lines
+// with nothing to pay are always carried over.
+func PlanPayoutLines(totals []LedgerPeriodTotal, carried []models.Payout, minimum PayoutMinimum) []PayoutPlanLine {
+	type key struct {
+		advertiser, promoter uuid.UUID
+		currency             string
+	}
+	index := make(map[key]int)
+	var lines []PayoutPlanLine
+
+	line := func(k key, tenantID uuid.UUID) *PayoutPlanLine {
+		i, ok := index[k]
+		if !ok {
+			i = len(lines)
+			index[k] = i
+			advertiserID := k.advertiser
+			lines = append(lines, PayoutPlanLine{
+				Payout: models.Payout{AdvertiserID: k.advertiser, PublisherID: k.promoter, Currency: k.currency},
+				Total:  LedgerPeriodTotal{TenantID: tenantID, AdvertiserID: &advertiserID, PromoterID: k.promoter, Currency: k.currency},
+			})
+		}
+		return &lines[i]
+	}
+
+	for _, total := range totals {
+		if total.AdvertiserID == nil {
+			continue
+		}
+		l := line(key{*total.AdvertiserID, total.PromoterID, total.Currency}, total.TenantID)
+		l.Total.Merge(total)
+	}
+
+	carriedNet := make(map[int]int64)
+	for _, p := range carried {
+		currency := NormalizeCurrency(p.Currency)
+		k := key{p.AdvertiserID, p.PublisherID, currency}
+		l := line(k, p.TenantID)
+		net := MajorToMinor(p.NetAmount, currency)
+		fee := MajorToMinor(p.PlatformFee, currency)
+		// Carried amounts have no posting-time snapshot; the line is
+		// reported at the period-end rate instead
+		l.Total.Merge(LedgerPeriodTotal{Currency: currency, Commission: net, PlatformFee: fee, Conversions: p.ConversionsCount})
+		l.Total.Reporting = nil
+		l.CarriedFrom = append(l.CarriedFrom, p.ID)
+		carriedNet[index[k]] += net
+	}
+
+	payable := make(map[key]int64) // promoter × currency
+	for _, l := range lines {
+		if l.Total.Commission > 0 {
+			payable[key{promoter: l.Payout.PublisherID, currency: l.Payout.Currency}] += l.Total.Commission
+		}
+	}
+
+	for i := range lines {
+		l := &lines[i]
+		p := &l.Payout
+		p.Amount = MinorToMajor(l.Total.Gross(), p.Currency)
+		p.PlatformFee = MinorToMajor(l.Total.PlatformFee, p.Currency)
+		p.NetAmount = MinorToMajor(l.Total.Commission, p.Currency)
+		p.CarriedOverAmount = MinorToMajor(carriedNet[i], p.Currency)
+		p.ConversionsCount = l.Total.Conversions
+		p.Status = models.PayoutStatusPending
+
+		total := payable[key{promoter: p.PublisherID, currency: p.Currency}]
+		switch {
+		case l.Total.Commission <= 0:
+			p.Status, p.HoldReason = models.PayoutStatusCarriedOver, PayoutCarryNoBalance
+		case minimum != nil && MinorToMajor(total, p.Currency) < minimum(p.PublisherID, p.Currency):
+			p.Status, p.HoldReason = models.PayoutStatusCarriedOver, PayoutCarryBelowMin
+		}
+	}
+	return lines
+}
+
+// MinimumPayout returns a promoter's minimum payout in a currency: the
+// MinPayout of the payment network they are paid through (a verified
+// payment-provider account, else the network matching their payout method)
+func (s *PayoutService) MinimumPayout(promoterID uuid.UUID, currency string) float64 {
+	var network models.AffiliateNetwork
+	err := s.db.Model(&models.AffiliateNetwork{}).
+		Joins("JOIN promoter_network_accounts a ON a.network_id = affiliate_networks.id").
+		Where("a.user_id = ? AND a.status = ? AND affiliate_networks.type = ?", promoterID, "verified", models.NetworkTypePaymentProvider).
+		Order("affiliate_networks.priority DESC").
+		First(&network).Error
+	if err != nil {
+		method := models.PaymentSourceDirect
+		var promoter models.AfftokUser
+		if s.db.Select("id, payoneer_email, payoneer_status").First(&promoter, "id = ?", promoterID).Error == nil &&
+			promoter.PayoneerEmail != "" && promoter.PayoneerStatus == "verified" {
+			method = models.PaymentSourcePayoneer
+		}
+		if err := s.db.Where("payment_method = ? AND status <> ?", method, models.NetworkStatusInactive).
+			Order("priority DESC").First(&network).Error; err != nil {
+			return 0
+		}
+	}
+	if network.MinPayout <= 0 {
+		return 0
+	}
+
+	minimum := MoneyFromMajor(float64(network.MinPayout), network.PaymentCurrency)
+	converted, _, err := GetFXService(s.db).Convert(minimum, currency, time.Now())
+	if err != nil {
+		// بدون سعر صرف نطبق الحد كما هو
+		return float64(network.MinPayout)
+	}
+	return converted.Major()
+}
+
+// ============================================================
+// Generation - إنشاء الدفعة
+// ============================================================
+
+// GenerateBatch creates the tenant's batch for a period. It is idempotent:
+// when the period already has a batch, that batch is returned with created=false.
+func (s *PayoutService) GenerateBatch(tenantID uuid.UUID, year, month int, actor *uuid.UUID) (*models.PayoutBatch, bool, error) {
+	period := fmt.Sprintf("%d-%02d", year, month)
+	if existing, err := s.batchForPeriod(s.db, tenantID, period); err == nil {
+		return existing, false, nil
+	}
+
+	loc := s.settings.Location(tenantID)
+	periodStart := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, loc)
+	periodEndExclusive := periodStart.AddDate(0, 1, 0)
+	periodEnd := periodEndExclusive.Add(-time.Second)
+	if periodEndExclusive.After(time.Now()) {
+		return nil, false, ErrPayoutPeriodOpen
+	}
+
+	ledger := s.ledgerService()
+	totals, err := ledger.PeriodTotals(&tenantID, periodStart, periodEndExclusive)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to aggregate ledger: %w", err)
+	}
+	carried, err := s.carryOverCandidates(tenantID, period)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to load carried-over payouts: %w", err)
+	}
+
+	minimums := make(map[string]float64)
+	plan := PlanPayoutLines(totals, carried, func(promoterID uuid.UUID, currency string) float64 {
+		k := promoterID.String() + ":" + currency
+		if v, ok := minimums[k]; ok {
+			return v
+		}
+		minimums[k] = s.MinimumPayout(promoterID, currency)
+		return minimums[k]
+	})
+
+	now := time.Now()
+	batch := &models.PayoutBatch{
+		ID:          uuid.New(),
+		Period:      period,
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+		Status:      models.BatchStatusDraft,
+		Currency:    s.settings.Get(tenantID).ReportingCurrency,
+		CreatedByID: actor,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	batch.TenantID = tenantID
+
+	payouts := make([]models.Payout, len(plan))
+	for i, line := range plan {
+		p := line.Payout
+		p.ID = uuid.New()
+		p.TenantID = tenantID
+		p.BatchID = &batch.ID
+		p.Period = period
+		p.PeriodStart = periodStart
+		p.PeriodEnd = periodEnd
+		p.CreatedAt = now
+		p.UpdatedAt = now
+		s.setReporting(ledger, &p, line.Total, periodEnd)
+		payouts[i] = p
+	}
+
+	// الحجوزات - KYC, open fraud cases and missing tax profiles
+	s.ApplyKYCHolds(payouts)
+	s.applyFraudHolds(tenantID, payouts)
+	s.applyTaxProfileHolds(tenantID, payouts)
+	summarizeBatch(batch, payouts)
+
+	events := []models.PayoutEvent{{
+		BatchID: &batch.ID, Action: models.PayoutEventGenerated, ToStatus: batch.Status, ActorID: actor,
+		Note: fmt.Sprintf("%d lines, %d held, %d carried over", batch.TotalPayouts, batch.HeldPayouts, batch.CarriedPayouts),
+	}}
+	for i := range payouts {
+		p := &payouts[i]
+		action := models.PayoutEventGenerated
+		switch p.Status {
+		case models.PayoutStatusOnHold:
+			action = models.PayoutEventHeld
+		case models.PayoutStatusCarriedOver:
+			action = models.PayoutEventCarried
+		}
+		events = append(events, models.PayoutEvent{
+			BatchID: &batch.ID, PayoutID: &p.ID, Action: action, ToStatus: p.Status, ActorID: actor, Note: p.HoldReason,
+		})
+		for _, fromID := range plan[i].CarriedFrom {
+			fromID := fromID
+			events = append(events, models.PayoutEvent{
+				PayoutID: &fromID, Action: models.PayoutEventCarried, ToStatus: models.PayoutStatusCarriedOver, ActorID: actor,
+				Note: "carried into " + period, Data: eventData(map[string]interface{}{"carried_into_id": p.ID}),
+			})
+		}
+	}
+
+	created := true
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(batch)
+		if result.Error != nil {
+			return fmt.Errorf("failed to create batch: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			created = false // generated concurrently
+			return nil
+		}
+		if len(payouts) > 0 {
+			if err := tx.CreateInBatches(&payouts, 200).Error; err != nil {
+				return fmt.Errorf("failed to create payouts: %w", err)
+			}
+		}
+		for i, line := range plan {
+			if len(line.CarriedFrom) == 0 {
+				continue
+			}
+			result := tx.Model(&models.Payout{}).
+				Where("id IN ? AND carried_into_id IS NULL", line.CarriedFrom).
+				Updates(map[string]interface{}{
+					"status":          models.PayoutStatusCarriedOver,
+					"carried_into_id": payouts[i].ID,
+					"updated_at":      now,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected != int64(len(line.CarriedFrom)) {
+				return fmt.Errorf("%w: carried-over payouts changed during generation", ErrPayoutTransition)
+			}
+		}
+		return s.recordEvents(tx, tenantID, events)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if !created {
+		existing, err := s.batchForPeriod(s.db, tenantID, period)
+		return existing, false, err
+	}
+	return batch, true, nil
+}
+
+// carryOverCandidates returns earlier lines that still have to be paid:
+// lines carried over below the minimum, and failed, held or released lines
+// of batches that have already been submitted
+func (s *PayoutService) carryOverCandidates(tenantID uuid.UUID, period string) ([]models.Payout, error) {
+	var payouts []models.Payout
+	err := s.db.Model(&models.Payout{}).
+		Joins("LEFT JOIN payout_batches b ON b.id = payouts.batch_id").
+		Where("payouts.tenant_id = ? AND payouts.carried_into_id IS NULL AND payouts.period < ?", tenantID, period).
+		Where("payouts.status = ? OR (payouts.status IN ? AND b.status IN ?)",
+			models.PayoutStatusCarriedOver,
+			[]string{models.PayoutStatusFailed, models.PayoutStatusOnHold, models.PayoutStatusPending},
+			finishedBatchStatuses).
+		Find(&payouts).Error
+	return payouts, err
+}
+
+// setReporting fills a line's amounts in the promoter's reporting currency
+func (s *PayoutService) setReporting(ledger *LedgerService, payout *models.Payout, total LedgerPeriodTotal, asOf time.Time) {
+	reportIn := GetFXService(s.db).ReportingCurrency(payout.TenantID, payout.PublisherID)
+	amount, rate, err := ledger.ReportIn(total, reportIn, asOf)
+	if err != nil {
+		log.Printf("[Payout] No %s rate for %s payout of %s: %v", reportIn, payout.Currency, payout.PublisherID, err)
+		return
+	}
+	payout.ReportingCurrency = reportIn
+	payout.ReportingAmount = MinorToMajor(amount.Commission+amount.PlatformFee, reportIn)
+	payout.ReportingPlatformFee = MinorToMajor(amount.PlatformFee, reportIn)
+	payout.ReportingNetAmount = MinorToMajor(amount.Commission, reportIn)
+	payout.FXRate = rate
+}
+
+// applyFraudHolds holds pending lines of promoters with an open fraud case
+func (s *PayoutService) applyFraudHolds(tenantID uuid.UUID, payouts []models.Payout) int {
+	var promoterIDs []uuid.UUID
+	for _, p := range payouts {
+		if p.Status == models.PayoutStatusPending {
+			promoterIDs = append(promoterIDs, p.PublisherID)
+		}
+	}
+	if len(promoterIDs) == 0 {
+		return 0
+	}
+	var flagged []uuid.UUID
+	s.db.Model(&models.FraudCase{}).
+		Where("tenant_id = ? AND user_id IN ? AND status IN ?", tenantID, promoterIDs,
+			[]string{models.FraudCaseStatusOpen, models.FraudCaseStatusInvestigating}).
+		Distinct().Pluck("user_id", &flagged)
+
+	open := make(map[uuid.UUID]bool, len(flagged))
+	for _, id := range flagged {
+		open[id] = true
+	}
+	held := 0
+	for i := range payouts {
+		if payouts[i].Status == models.PayoutStatusPending && open[payouts[i].PublisherID] {
+			payouts[i].Status = models.PayoutStatusOnHold
+			payouts[i].HoldReason = PayoutHoldReasonFraud
+			held++
+		}
+	}
+	return held
+}
+
+// applyTaxProfileHolds holds pending lines of promoters whose country needs
+// a tax profile they have not saved
+func (s *PayoutService) applyTaxProfileHolds(tenantID uuid.UUID, payouts []models.Payout) int {
+	countries := s.settings.Get(tenantID).TaxProfileCountries
+	var promoterIDs []uuid.UUID
+	for _, p := range payouts {
+		if p.Status == models.PayoutStatusPending {
+			promoterIDs = append(promoterIDs, p.PublisherID)
+		}
+	}
+	missing, err := NewTaxProfileService(s.db).Missing(tenantID, countries, promoterIDs)
+	if err != nil {
+		log.Printf("[Payout] failed to check tax profiles: %v", err)
+		return 0
+	}
+	held := 0
+	for i := range payouts {
+		if payouts[i].Status == models.PayoutStatusPending && missing[payouts[i].PublisherID] {
+			payouts[i].Status = models.PayoutStatusOnHold
+			payouts[i].HoldReason = PayoutHoldReasonTaxProfile
+			held++
+		}
+	}
+	return held
+}
+
+// summarizeBatch sets a batch's totals from its lines. Amounts only count
+// lines that may still be paid from this batch.
+func summarizeBatch(batch *models.PayoutBatch, payouts []models.Payout) {
+	advertisers := make(map[uuid.UUID]bool)
+	publishers := make(map[uuid.UUID]bool)
+	batch.TotalAmount, batch.TotalPlatformFee, batch.TotalNetAmount = 0, 0, 0
+	batch.TotalConversions, batch.HeldPayouts, batch.CarriedPayouts = 0, 0, 0
+
+	for _, p := range payouts {
+		switch p.Status {
+		case models.PayoutStatusCarriedOver:
+			batch.CarriedPayouts++
+			continue
+		case models.PayoutStatusCancelled:
+			continue
+		case models.PayoutStatusOnHold:
+			batch.HeldPayouts++
+		}
+		batch.TotalAmount += p.Amount
+		batch.TotalPlatformFee += p.PlatformFee
+		batch.TotalNetAmount += p.NetAmount
+		batch.TotalConversions += p.ConversionsCount
+		advertisers[p.AdvertiserID] = true
+		publishers[p.PublisherID] = true
+	}
+	batch.TotalPayouts = len(payouts)
+	batch.TotalAdvertisers = len(advertisers)
+	batch.TotalPublishers = len(publishers)
+}
+
+// ============================================================
+// Review, approval and submission - المراجعة والموافقة والإرسال
+// ============================================================
+
+// HoldPayout holds a line of a batch that has not been submitted yet
+func (s *PayoutService) HoldPayout(tenantID, payoutID uuid.UUID, reason string, actor *uuid.UUID) (*models.Payout, error) {
+	if reason == "" {
+		reason = PayoutHoldReasonManual
+	}
+	return s.changeLine(tenantID, payoutID, models.PayoutStatusOnHold, models.PayoutEventHeld, actor, reason,
+		func(tx *gorm.DB, p *models.Payout) error {
+			p.HoldReason = reason
+			return nil
+		})
+}
+
+// ReleasePayout releases a held line back to pending once its hold no longer applies
+func (s *PayoutService) ReleasePayout(tenantID, payoutID uuid.UUID, actor *uuid.UUID) (*models.Payout, error) {
+	return s.changeLine(tenantID, payoutID, models.PayoutStatusPending, models.PayoutEventReleased, actor, "",
+		func(tx *gorm.DB, p *models.Payout) error {
+			switch p.HoldReason {
+			case PayoutHoldReasonKYC:
+				var promoter models.AfftokUser
+				if err := tx.Select("id, kyc_status").First(&promoter, "id = ?", p.PublisherID).Error; err != nil || !promoter.IsKYCVerified() {
+					return fmt.Errorf("%w: promoter is not KYC verified", ErrPayoutHoldActive)
+				}
+			case PayoutHoldReasonFraud:
+				var open int64
+				tx.Model(&models.FraudCase{}).Where("user_id = ? AND status IN ?", p.PublisherID,
+					[]string{models.FraudCaseStatusOpen, models.FraudCaseStatusInvestigating}).Count(&open)
+				if open > 0 {
+					return fmt.Errorf("%w: promoter has an open fraud case", ErrPayoutHoldActive)
+				}
+			case PayoutHoldReasonTaxProfile:
+				countries := s.settings.Get(tenantID).TaxProfileCountries
+				missing, err := NewTaxProfileService(tx).Missing(tenantID, countries, []uuid.UUID{p.PublisherID})
+				if err != nil {
+					return err
+				}
+				if missing[p.PublisherID] {
+					return fmt.Errorf("%w: promoter has no tax profile", ErrPayoutHoldActive)
+				}
+			}
+			p.HoldReason = ""
+			return nil
+		})
+}
+
+// changeLine moves one line of an unsubmitted batch to a new status
+func (s *PayoutService) changeLine(tenantID, payoutID uuid.UUID, to, action string, actor *uuid.UUID, note string, apply func(tx *gorm.DB, p *models.Payout) error) (*models.Payout, error) {
+	var payout models.Payout
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ?", tenantID).First(&payout, "id = ?", payoutID).Error; err != nil {
+			return ErrPayoutNotFound
+		}
+		if payout.BatchID != nil {
+			var batch models.PayoutBatch
+			if err := tx.Select("id, status").First(&batch, "id = ?", *payout.BatchID).Error; err == nil &&
+				batch.Status != models.BatchStatusDraft && batch.Status != models.BatchStatusApproved &&
+				!(to == models.PayoutStatusPending && containsString(finishedBatchStatuses, batch.Status)) {
+				return fmt.Errorf("%w: batch is %s", ErrPayoutTransition, batch.Status)
+			}
+		}
+		from := payout.Status
+		if !CanTransitionPayout(from, to) {
+			return fmt.Errorf("%w: %s → %s", ErrPayoutTransition, from, to)
+		}
+		if err := apply(tx, &payout); err != nil {
+			return err
+		}
+		payout.Status = to
+		payout.UpdatedAt = time.Now()
+		if err := tx.Model(&payout).Updates(map[string]interface{}{
+			"status":      payout.Status,
+			"hold_reason": payout.HoldReason,
+			"updated_at":  payout.UpdatedAt,
+		}).Error; err != nil {
+			return err
+		}
+		if err := s.refreshBatchTotals(tx, payout.BatchID); err != nil {
+			return err
+		}
+		return s.recordEvents(tx, tenantID, []models.PayoutEvent{{
+			BatchID: payout.BatchID, PayoutID: &payout.ID, Action: action, FromStatus: from, ToStatus: to, ActorID: actor, Note: note,
+		}})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &payout, nil
+}
+
+// ReleaseHolds moves a promoter's lines held for a reason back to pending.
+// Lines of batches that were already submitted are paid with the next batch.
+func (s *PayoutService) ReleaseHolds(publisherID uuid.UUID, reason string, actor *uuid.UUID) (int64, error) {
+	var held []models.Payout
+	if err := s.db.Select("id, tenant_id, batch_id").
+		Where("publisher_id = ? AND status = ? AND hold_reason = ?", publisherID, models.PayoutStatusOnHold, reason).
+		Find(&held).Error; err != nil || len(held) == 0 {
+		return 0, err
+	}
+	ids := make([]uuid.UUID, len(held))
+	for i, p := range held {
+		ids[i] = p.ID
+	}
+	result := s.db.Model(&models.Payout{}).
+		Where("id IN ? AND status = ?", ids, models.PayoutStatusOnHold).
+		Updates(map[string]interface{}{
+			"status":      models.PayoutStatusPending,
+			"hold_reason": "",
+			"updated_at":  time.Now(),
+		})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	for _, p := range held {
+		p := p
+		s.recordEvents(s.db, p.TenantID, []models.PayoutEvent{{
+			BatchID: p.BatchID, PayoutID: &p.ID, Action: models.PayoutEventReleased,
+			FromStatus: models.PayoutStatusOnHold, ToStatus: models.PayoutStatusPending, ActorID: actor, Note: reason,
+		}})
+	}
+	return result.RowsAffected, nil
+}
+
+// ApproveBatch approves a reviewed batch: its pending lines become payable
+func (s *PayoutService) ApproveBatch(tenantID, batchID uuid.UUID, actor *uuid.UUID) (*models.PayoutBatch, error) {
+	return s.transitionBatch(tenantID, batchID, models.BatchStatusApproved, actor, func(tx *gorm.DB, batch *models.PayoutBatch, now time.Time) ([]models.PayoutEvent, error) {
+		batch.ApprovedAt = &now
+		batch.ApprovedByID = actor
+		return s.moveLines(tx, batch, models.PayoutStatusPending, models.PayoutStatusApproved, models.PayoutEventApproved, actor,
+			map[string]interface{}{"approved_at": now})
+	})
+}
+
+// ReopenBatch sends an approved batch back to review
+func (s *PayoutService) ReopenBatch(tenantID, batchID uuid.UUID, actor *uuid.UUID) (*models.PayoutBatch, error) {
+	return s.transitionBatch(tenantID, batchID, models.BatchStatusDraft, actor, func(tx *gorm.DB, batch *models.PayoutBatch, now time.Time) ([]models.PayoutEvent, error) {
+		batch.ApprovedAt = nil
+		batch.ApprovedByID = nil
+		return s.moveLines(tx, batch, models.PayoutStatusApproved, models.PayoutStatusPending, models.PayoutEventReleased, actor,
+			map[string]interface{}{"approved_at": nil})
+	})
+}
+
+// CancelBatch cancels a batch. Its payable lines are carried over into the
+// next period's batch. A submitted batch is first cancelled at its payout
+// rails, which is only possible while nothing has been paid.
+func (s *PayoutService) CancelBatch(tenantID, batchID uuid.UUID, actor *uuid.UUID) (*models.PayoutBatch, error) {
+	var current models.PayoutBatch
+	if err := s.db.Where("tenant_id = ?", tenantID).First(&current, "id = ?", batchID).Error; err != nil {
+		return nil, ErrPayoutBatchNotFound
+	}
+	if current.Status == models.BatchStatusSubmitted {
+		if err := s.cancelAtRails(&current); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.transitionBatch(tenantID, batchID, models.BatchStatusCancelled, actor, func(tx *gorm.DB, batch *models.PayoutBatch, now time.Time) ([]models.PayoutEvent, error) {
+		batch.CompletedAt = &now
+		events, err := s.moveLines(tx, batch, models.PayoutStatusApproved, models.PayoutStatusPending, models.PayoutEventCancelled, actor,
+			map[string]interface{}{"approved_at": nil})
+		if err != nil {
+			return nil, err
+		}
+		if current.Status != models.BatchStatusSubmitted {
+			return events, nil
+		}
+		failed, err := s.moveLines(tx, batch, models.PayoutStatusProcessing, models.PayoutStatusFailed, models.PayoutEventCancelled, actor,
+			map[string]interface{}{"failure_reason": "batch cancelled before payment"})
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Model(&models.PayoutRailSubmission{}).Where("batch_id = ?", batch.ID).
+			Updates(map[string]interface{}{"status": models.RailSubmissionCancelled, "updated_at": now}).Error; err != nil {
+			return nil, err
+		}
+		return append(events, failed...), nil
+	})
+}
+
+// SubmitBatch submits an approved batch for payment: its approved lines
+// move to processing and are sent through each promoter's payout rail until
+// they are reconciled as paid or failed. Lines without a payout method are
+// paid outside the platform (advertisers pay promoters directly) and
+// reconciled by an admin. Rail errors are kept on the batch's rail
+// submissions; DispatchBatch retries them.
+func (s *PayoutService) SubmitBatch(tenantID, batchID uuid.UUID, actor *uuid.UUID) (*models.PayoutBatch, error) {
+	batch, err := s.transitionBatch(tenantID, batchID, models.BatchStatusSubmitted, actor, func(tx *gorm.DB, batch *models.PayoutBatch, now time.Time) ([]models.PayoutEvent, error) {
+		batch.SubmittedAt = &now
+		events, err := s.moveLines(tx, batch, models.PayoutStatusApproved, models.PayoutStatusProcessing, models.PayoutEventSubmitted, actor,
+			map[string]interface{}{"submitted_at": now})
+		if err != nil {
+			return nil, err
+		}
+		if len(events) == 0 {
+			// لا توجد أسطر للدفع - nothing to pay, the batch is done
+			batch.Status = models.BatchStatusCompleted
+			batch.CompletedAt = &now
+			return nil, nil
+		}
+		return events, s.assignRails(tx, batch)
+	})
+	if err != nil || batch.Status != models.BatchStatusSubmitted {
+		return batch, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), payoutDispatchTimeout)
+	defer cancel()
+	if _, err := s.DispatchBatch(ctx, tenantID, batchID, actor); err != nil {
+		log.Printf("[Payouts] dispatch of batch %s failed: %v", batchID, err)
+	}
+	if err := s.db.First(batch, "id = ?", batchID).Error; err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// transitionBatch locks a batch, checks the transition and applies it
+func (s *PayoutService) transitionBatch(tenantID, batchID uuid.UUID, to string, actor *uuid.UUID, apply func(tx *gorm.DB, batch *models.PayoutBatch, now time.Time) ([]models.PayoutEvent, error)) (*models.PayoutBatch, error) {
+	var batch models.PayoutBatch
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ?", tenantID).First(&batch, "id = ?", batchID).Error; err != nil {
+			return ErrPayoutBatchNotFound
+		}
+		from := batch.Status
+		if !CanTransitionBatch(from, to) {
+			return fmt.Errorf("%w: batch %s → %s", ErrPayoutTransition, from, to)
+		}
+		now := time.Now()
+		batch.Status = to
+		events, err := apply(tx, &batch, now)
+		if err != nil {
+			return err
+		}
+		batch.UpdatedAt = now
+		if err := tx.Model(&batch).Select("status", "approved_at", "approved_by_id", "submitted_at", "completed_at", "updated_at").
+			Updates(&batch).Error; err != nil {
+			return err
+		}
+		if err := s.refreshBatchTotals(tx, &batch.ID); err != nil {
+			return err
+		}
+		events = append([]models.PayoutEvent{{
+			BatchID: &batch.ID, Action: batchEventAction(batch.Status), FromStatus: from, ToStatus: batch.Status, ActorID: actor,
+		}}, events...)
+		return s.recordEvents(tx, tenantID, events)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// moveLines moves a batch's lines from one status to another, returning their events
+func (s *PayoutService) moveLines(tx *gorm.DB, batch *models.PayoutBatch, from, to, action string, actor *uuid.UUID, extra map[string]interface{}) ([]models.PayoutEvent, error) {
+	var ids []uuid.UUID
+	if err := tx.Model(&models.Payout{}).Where("batch_id = ? AND status = ?", batch.ID, from).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	updates := map[string]interface{}{"status": to, "updated_at": time.Now()}
+	for k, v := range extra {
+		updates[k] = v
+	}
+	if err := tx.Model(&models.Payout{}).Where("id IN ?", ids).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	events := make([]models.PayoutEvent, len(ids))
+	for i := range ids {
+		events[i] = models.PayoutEvent{BatchID: &batch.ID, PayoutID: &ids[i], Action: action, FromStatus: from, ToStatus: to, ActorID: actor}
+	}
+	return events, nil
+}
+
+func batchEventAction(status string) string {
+	switch status {
+	case models.BatchStatusApproved:
+		return models.PayoutEventApproved
+	case models.BatchStatusSubmitted:
+		return models.PayoutEventSubmitted
+	case models.BatchStatusCancelled:
+		return models.PayoutEventCancelled
+	case models.BatchStatusDraft:
+		return models.PayoutEventReleased
+	}
+	return models.PayoutEventCompleted
+}
+
+// ============================================================
+// Reconciliation - التسوية
+// ============================================================
+
+// PayoutSettlement is the payment result of one submitted line
+type PayoutSettlement struct {
+	PayoutID  uuid.UUID  `json:"payout_id" binding:"required"`
+	Status    string     `json:"status" binding:"required,oneof=paid failed"`
+	Reference string     `json:"reference"`
+	Reason    string     `json:"reason"`
+	PaidAt    *time.Time `json:"paid_at"`
+}
+
+// PayoutReconciliation summarizes a reconciliation run
+type PayoutReconciliation struct {
+	Paid        int      `json:"paid"`
+	Failed      int      `json:"failed"`
+	Skipped     []string `json:"skipped,omitempty"`
+	Outstanding int64    `json:"outstanding"` // lines still processing
+	BatchStatus string   `json:"batch_status"`
+}
+
+// ReconcileBatch records payment results for a submitted batch. Paid lines
+// settle the promoter's payable in the ledger; failed lines are carried over
+// into the next batch. The batch completes once no line is processing.
+// Results for lines that are not processing are skipped, so re-sending a
+// settlement file is harmless.
+func (s *PayoutService) ReconcileBatch(tenantID, batchID uuid.UUID, settlements []PayoutSettlement, actor *uuid.UUID) (*PayoutReconciliation, error) {
+	report := &PayoutReconciliation{}
+	ledger := s.ledgerService()
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var batch models.PayoutBatch
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ?", tenantID).First(&batch, "id = ?", batchID).Error; err != nil {
+			return ErrPayoutBatchNotFound
+		}
+		if batch.Status != models.BatchStatusSubmitted {
+			return fmt.Errorf("%w: batch is %s", ErrPayoutTransition, batch.Status)
+		}
+
+		var events []models.PayoutEvent
+		for _, result := range settlements {
+			var payout models.Payout
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("batch_id = ?", batch.ID).First(&payout, "id = ?", result.PayoutID).Error; err != nil {
+				report.Skipped = append(report.Skipped, result.PayoutID.String()+": not in batch")
+				continue
+			}
+			if payout.Status != models.PayoutStatusProcessing {
+				report.Skipped = append(report.Skipped, result.PayoutID.String()+": already "+payout.Status)
+				continue
+			}
+
+			now := time.Now()
+			updates := map[string]interface{}{"updated_at": now}
+			if result.Reference != "" {
+				updates["external_ref"] = result.Reference
+			}
+			event := models.PayoutEvent{BatchID: &batch.ID, PayoutID: &payout.ID, FromStatus: payout.Status, ActorID: actor, Note: result.Reference}
+			switch result.Status {
+			case models.PayoutStatusPaid:
+				paidAt := now
+				if result.PaidAt != nil {
+					paidAt = *result.PaidAt
+				}
+				payout.PaidAt = &paidAt
+				updates["status"], updates["paid_at"], updates["failure_reason"] = models.PayoutStatusPaid, paidAt, ""
+				if _, err := ledger.PostPayoutPaid(tx, &payout, actor); err != nil {
+					return fmt.Errorf("ledger posting for payout %s: %w", payout.ID, err)
+				}
+				event.Action, event.ToStatus = models.PayoutEventPaid, models.PayoutStatusPaid
+				report.Paid++
+			case models.PayoutStatusFailed:
+				updates["status"], updates["failure_reason"] = models.PayoutStatusFailed, result.Reason
+				event.Action, event.ToStatus = models.PayoutEventFailed, models.PayoutStatusFailed
+				if result.Reason != "" {
+					event.Note = result.Reason
+				}
+				report.Failed++
+			default:
+				report.Skipped = append(report.Skipped, result.PayoutID.String()+": unknown status "+result.Status)
+				continue
+			}
+			if err := tx.Model(&payout).Updates(updates).Error; err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+
+		tx.Model(&models.Payout{}).Where("batch_id = ? AND status = ?", batch.ID, models.PayoutStatusProcessing).Count(&report.Outstanding)
+		if report.Outstanding == 0 {
+			var paid, failed int64
+			tx.Model(&models.Payout{}).Where("batch_id = ? AND status = ?", batch.ID, models.PayoutStatusPaid).Count(&paid)
+			tx.Model(&models.Payout{}).Where("batch_id = ? AND status = ?", batch.ID, models.PayoutStatusFailed).Count(&failed)
+			to := models.BatchStatusCompleted
+			switch {
+			case failed > 0 && paid == 0:
+				to = models.BatchStatusFailed
+			case failed > 0:
+				to = models.BatchStatusPartiallyFailed
+			}
+			now := time.Now()
+			if err := tx.Model(&batch).Updates(map[string]interface{}{"status": to, "completed_at": now, "updated_at": now}).Error; err != nil {
+				return err
+			}
+			events = append(events, models.PayoutEvent{
+				BatchID: &batch.ID, Action: models.PayoutEventCompleted, FromStatus: models.BatchStatusSubmitted, ToStatus: to, ActorID: actor,
+				Note: fmt.Sprintf("%d paid, %d failed", paid, failed),
+			})
+			batch.Status = to
+		}
+		report.BatchStatus = batch.Status
+		return s.recordEvents(tx, tenantID, events)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// ============================================================
+// Audit trail - سجل التدقيق
+// ============================================================
+
+// refreshBatchTotals recomputes a batch's totals after lines changed
+func (s *PayoutService) refreshBatchTotals(tx *gorm.DB, batchID *uuid.UUID) error {
+	if batchID == nil {
+		return nil
+	}
+	var batch models.PayoutBatch
+	if err := tx.First(&batch, "id = ?", *batchID).Error; err != nil {
+		return nil
+	}
+	var payouts []models.Payout
+	if err := tx.Select("id, advertiser_id, publisher_id, amount, platform_fee, net_amount, conversions_count, status").
+		Where("batch_id = ?", batch.ID).Find(&payouts).Error; err != nil {
+		return err
+	}
+	summarizeBatch(&batch, payouts)
+	return tx.Model(&batch).Select("total_amount", "total_platform_fee", "total_net_amount", "total_payouts",
+		"total_publishers", "total_advertisers", "total_conversions", "held_payouts", "carried_payouts").
+		Updates(&batch).Error
+}
+
+// recordEvents writes audit events of a tenant
+func (s *PayoutService) recordEvents(tx *gorm.DB, tenantID uuid.UUID, events []models.PayoutEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for i := range events {
+		events[i].TenantID = tenantID
+		if events[i].ID == uuid.Nil {
+			events[i].ID = uuid.New()
+		}
+		if events[i].CreatedAt.IsZero() {
+			events[i].CreatedAt = now
+		}
+	}
+	return tx.CreateInBatches(&events, 500).Error
+}
+
+// ListEvents returns the audit trail of a batch or of a single line, oldest first
+func (s *PayoutService) ListEvents(tenantID uuid.UUID, batchID, payoutID *uuid.UUID) ([]models.PayoutEvent, error) {
+	query := s.db.Where("tenant_id = ?", tenantID)
+	if batchID != nil {
+		query = query.Where("batch_id = ?", *batchID)
+	}
+	if payoutID != nil {
+		query = query.Where("payout_id = ?", *payoutID)
+	}
+	var events []models.PayoutEvent
+	err := query.Order("created_at ASC").Limit(5000).Find(&events).Error
+	return events, err
+}
+
+func (s *PayoutService) batchForPeriod(tx *gorm.DB, tenantID uuid.UUID, period string) (*models.PayoutBatch, error) {
+	var batch models.PayoutBatch
+	if err := tx.Where("tenant_id = ? AND period = ?", tenantID, period).First(&batch).Error; err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+func eventData(v interface{}) []byte {
+	data, _ := json.Marshal(v)
+	return data
+}
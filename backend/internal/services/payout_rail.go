@@ -0,0 +1,490 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+)
+
+// ============================================
+// PAYOUT RAIL ABSTRACTION
+// ============================================
+
+// PayoutRailItemPending is the status of an item the rail has not settled yet;
+// settled items are models.PayoutStatusPaid or models.PayoutStatusFailed
+const PayoutRailItemPending = "pending"
+
+// Errors returned by payout rails
+var (
+	ErrPayoutRailInvalidSignature = errors.New("invalid payout webhook signature")
+	ErrPayoutRailDisabled         = errors.New("payout rail is not configured")
+	ErrPayoutRailUnknown          = errors.New("unknown payout rail")
+	ErrPayoutRailEventIgnored     = errors.New("payout event type is not handled")
+	ErrPayoutRailUnsupported      = errors.New("operation not supported by this payout rail")
+	ErrPayoutRailCannotCancel     = errors.New("payments already left the rail and cannot be cancelled")
+	ErrPayoutPayeeInvalid         = errors.New("invalid payee")
+)
+
+// PayoutRail is implemented by each way of paying promoters (Payoneer,
+// PayPal, bank-transfer files). Every rail has a fake mode so the full
+// submit → status → reconcile flow runs offline.
+type PayoutRail interface {
+	// Name returns the rail identifier stored on payout lines ("payoneer", "paypal")
+	Name() string
+
+	// Enabled reports whether the rail is configured
+	Enabled() bool
+
+	// ValidatePayee checks that the payee can receive money on this rail;
+	// errors wrap ErrPayoutPayeeInvalid when the payee details are wrong
+	ValidatePayee(ctx context.Context, payee *PayoutPayee) error
+
+	// SubmitBatch sends payments. Batch.Reference is stable across retries
+	// and must make re-submitting the same batch safe.
+	SubmitBatch(ctx context.Context, batch *PayoutRailBatch) (*PayoutRailReceipt, error)
+
+	// PollStatus returns the current status of a submitted batch's items
+	PollStatus(ctx context.Context, ref *PayoutRailRef) ([]PayoutRailItemResult, error)
+
+	// ParseWebhook verifies the signature on a raw webhook body and normalises it
+	ParseWebhook(ctx context.Context, headers http.Header, body []byte) (*PayoutRailEvent, error)
+
+	// CancelBatch cancels the items of a submitted batch that were not paid yet
+	CancelBatch(ctx context.Context, ref *PayoutRailRef) error
+}
+
+// PayoutPayee is where a promoter receives money
+type PayoutPayee struct {
+	UserID      uuid.UUID `json:"user_id"`
+	Name        string    `json:"name,omitempty"`
+	Email       string    `json:"email,omitempty"`
+	PayeeID     string    `json:"payee_id,omitempty"` // provider payee ID (Payoneer)
+	AccountName string    `json:"account_name,omitempty"`
+	IBAN        string    `json:"iban,omitempty"`
+	BIC         string    `json:"bic,omitempty"`
+	BankName    string    `json:"bank_name,omitempty"`
+	Country     string    `json:"country,omitempty"`
+}
+
+// PayoutPayeeFromMethod builds a payee from a stored payout method
+func PayoutPayeeFromMethod(method *models.PayoutMethod, user *models.AfftokUser) *PayoutPayee {
+	payee := &PayoutPayee{
+		UserID:      method.UserID,
+		Email:       method.Email,
+		PayeeID:     method.PayeeID,
+		AccountName: method.AccountName,
+		IBAN:        method.IBAN,
+		BIC:         method.BIC,
+		BankName:    method.BankName,
+		Country:     method.Country,
+	}
+	if user != nil {
+		payee.Name = user.FullName
+		if payee.Name == "" {
+			payee.Name = user.Username
+		}
+	}
+	if payee.AccountName == "" {
+		payee.AccountName = payee.Name
+	}
+	return payee
+}
+
+// PayoutRailItem is one payment of a rail batch
+type PayoutRailItem struct {
+	PayoutID    uuid.UUID   `json:"payout_id"`
+	Payee       PayoutPayee `json:"payee"`
+	Amount      Money       `json:"amount"`
+	Description string      `json:"description,omitempty"`
+}
+
+// PayoutRailBatch is the set of payments sent through one rail at once
+type PayoutRailBatch struct {
+	Reference string           `json:"reference"` // idempotency key, e.g. "afftok-<batch>-paypal"
+	Period    string           `json:"period"`
+	Items     []PayoutRailItem `json:"items"`
+}
+
+// PayoutRailFile is a transfer file produced by a file-based rail
+type PayoutRailFile struct {
+	Name        string
+	ContentType string
+	Content     []byte
+}
+
+// PayoutRailReceipt is the rail's answer to a submission
+type PayoutRailReceipt struct {
+	ExternalRef string                 `json:"external_ref"` // rail batch ID
+	Results     []PayoutRailItemResult `json:"results,omitempty"`
+	File        *PayoutRailFile        `json:"-"`
+}
+
+// PayoutRailItemResult is the status of one payment at the rail
+type PayoutRailItemResult struct {
+	PayoutID uuid.UUID  `json:"payout_id"`
+	ItemRef  string     `json:"item_ref,omitempty"` // rail payment ID
+	Status   string     `json:"status"`             // pending, paid, failed
+	Reason   string     `json:"reason,omitempty"`
+	PaidAt   *time.Time `json:"paid_at,omitempty"`
+}
+
+// Settled reports whether the rail reached a final result for the item
+func (r PayoutRailItemResult) Settled() bool {
+	return r.Status == models.PayoutStatusPaid || r.Status == models.PayoutStatusFailed
+}
+
+// PayoutRailRef identifies a submitted rail batch and its items
+type PayoutRailRef struct {
+	Reference   string
+	ExternalRef string
+	Items       map[uuid.UUID]string // payout ID -> rail payment ID (may be empty)
+}
+
+// PayoutRailEvent is a normalised rail webhook
+type PayoutRailEvent struct {
+	ID          string                 `json:"id"` // rail event ID, used for idempotency
+	Type        string                 `json:"type"`
+	ExternalRef string                 `json:"external_ref,omitempty"`
+	Results     []PayoutRailItemResult `json:"results"`
+}
+
+// ============================================
+// RAIL REGISTRY
+// ============================================
+
+// PayoutRails holds the configured rails by name
+type PayoutRails struct {
+	mu    sync.RWMutex
+	rails map[string]PayoutRail
+}
+
+var (
+	defaultPayoutRails     *PayoutRails
+	defaultPayoutRailsOnce sync.Once
+)
+
+// NewPayoutRails creates a registry with the given rails
+func NewPayoutRails(rails ...PayoutRail) *PayoutRails {
+	r := &PayoutRails{rails: make(map[string]PayoutRail)}
+	for _, rail := range rails {
+		r.Register(rail)
+	}
+	return r
+}
+
+// DefaultPayoutRails returns the rails configured from the environment.
+// PAYOUT_RAILS_MODE=fake replaces the Payoneer and PayPal adapters with fakes
+// (development and offline testing); the file rails are always available.
+func DefaultPayoutRails() *PayoutRails {
+	defaultPayoutRailsOnce.Do(func() {
+		if os.Getenv("PAYOUT_RAILS_MODE") == "fake" {
+			secret := os.Getenv("PAYOUT_FAKE_WEBHOOK_SECRET")
+			defaultPayoutRails = NewPayoutRails(
+				NewFakePayoutRail(models.PayoutRailPayoneer, secret),
+				NewFakePayoutRail(models.PayoutRailPayPal, secret),
+			)
+		} else {
+			defaultPayoutRails = NewPayoutRails(
+				NewPayoneerRail(PayoneerConfigFromEnv()),
+				NewPayPalRail(PayPalConfigFromEnv()),
+			)
+		}
+		defaultPayoutRails.Register(NewBankFileRail(models.PayoutRailBankTransfer))
+		defaultPayoutRails.Register(NewBankFileRail(models.PayoutRailWise))
+	})
+	return defaultPayoutRails
+}
+
+// Register adds or replaces a rail
+func (r *PayoutRails) Register(rail PayoutRail) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rails[rail.Name()] = rail
+}
+
+// Get returns an enabled rail by name
+func (r *PayoutRails) Get(name string) (PayoutRail, error) {
+	r.mu.RLock()
+	rail, ok := r.rails[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPayoutRailUnknown, name)
+	}
+	if !rail.Enabled() {
+		return nil, fmt.Errorf("%w: %s", ErrPayoutRailDisabled, name)
+	}
+	return rail, nil
+}
+
+// Status lists every known rail and whether it is enabled
+func (r *PayoutRails) Status() map[string]bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	status := make(map[string]bool, len(r.rails))
+	for name, rail := range r.rails {
+		status[name] = rail.Enabled()
+	}
+	return status
+}
+
+// ============================================
+// PAYEE CHECKS
+// ============================================
+
+var (
+	payeeEmailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	bicPattern        = regexp.MustCompile(`^[A-Z]{6}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+	ibanPattern       = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{11,30}$`)
+)
+
+// NormalizeIBAN strips spaces and upper-cases an IBAN
+func NormalizeIBAN(iban string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(iban), ""))
+}
+
+// ValidIBAN checks an IBAN's format and ISO 13616 mod-97 checksum
+func ValidIBAN(iban string) bool {
+	iban = NormalizeIBAN(iban)
+	if !ibanPattern.MatchString(iban) {
+		return false
+	}
+	rearranged := iban[4:] + iban[:4]
+	var digits strings.Builder
+	for _, r := range rearranged {
+		if r >= 'A' && r <= 'Z' {
+			digits.WriteString(strconv.Itoa(int(r-'A') + 10))
+		} else {
+			digits.WriteRune(r)
+		}
+	}
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// ValidBIC checks a SWIFT/BIC code's format
+func ValidBIC(bic string) bool {
+	return bicPattern.MatchString(strings.ToUpper(strings.TrimSpace(bic)))
+}
+
+func validatePayeeEmail(payee *PayoutPayee) error {
+	if !payeeEmailPattern.MatchString(payee.Email) {
+		return fmt.Errorf("%w: a valid email is required", ErrPayoutPayeeInvalid)
+	}
+	return nil
+}
+
+func validatePayeeBank(payee *PayoutPayee) error {
+	if strings.TrimSpace(payee.AccountName) == "" {
+		return fmt.Errorf("%w: account holder name is required", ErrPayoutPayeeInvalid)
+	}
+	if !ValidIBAN(payee.IBAN) {
+		return fmt.Errorf("%w: invalid IBAN", ErrPayoutPayeeInvalid)
+	}
+	if payee.BIC != "" && !ValidBIC(payee.BIC) {
+		return fmt.Errorf("%w: invalid BIC", ErrPayoutPayeeInvalid)
+	}
+	return nil
+}
+
+// formatRailAmount formats an amount with the currency's decimals ("12.50")
+func formatRailAmount(m Money) string {
+	return strconv.FormatFloat(m.Major(), 'f', CurrencyMinorUnits(m.Currency), 64)
+}
+
+// ============================================
+// FAKE RAIL
+// ============================================
+
+// FakePayoutRail is an in-memory rail for tests and local development.
+// Submitted items are pending until the next poll, which pays them unless
+// the payee is marked to fail.
+type FakePayoutRail struct {
+	RailName      string
+	WebhookSecret string
+
+	mu      sync.Mutex
+	failing map[string]string // payee email or ID -> failure reason
+	batches map[string]*fakeRailBatch
+}
+
+type fakeRailBatch struct {
+	externalRef string
+	items       []PayoutRailItem
+	results     map[uuid.UUID]*PayoutRailItemResult
+	cancelled   bool
+}
+
+// NewFakePayoutRail creates a fake rail; webhooks must carry X-Fake-Signature
+// equal to webhookSecret
+func NewFakePayoutRail(name, webhookSecret string) *FakePayoutRail {
+	return &FakePayoutRail{
+		RailName:      name,
+		WebhookSecret: webhookSecret,
+		failing:       make(map[string]string),
+		batches:       make(map[string]*fakeRailBatch),
+	}
+}
+
+// Name returns the rail identifier
+func (r *FakePayoutRail) Name() string {
+	return r.RailName
+}
+
+// Enabled is always true
+func (r *FakePayoutRail) Enabled() bool {
+	return true
+}
+
+// FailPayee makes payments to the payee (email or payee ID) fail with reason
+// (empty reason clears it)
+func (r *FakePayoutRail) FailPayee(payee, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if reason == "" {
+		delete(r.failing, payee)
+		return
+	}
+	r.failing[payee] = reason
+}
+
+// ValidatePayee requires an email or a payee ID
+func (r *FakePayoutRail) ValidatePayee(ctx context.Context, payee *PayoutPayee) error {
+	if payee.PayeeID != "" {
+		return nil
+	}
+	return validatePayeeEmail(payee)
+}
+
+// SubmitBatch records the batch; the same reference returns the same receipt
+func (r *FakePayoutRail) SubmitBatch(ctx context.Context, batch *PayoutRailBatch) (*PayoutRailReceipt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fb, ok := r.batches[batch.Reference]
+	if !ok {
+		fb = &fakeRailBatch{
+			externalRef: fmt.Sprintf("fake_%s_%d", r.RailName, len(r.batches)+1),
+			items:       batch.Items,
+			results:     make(map[uuid.UUID]*PayoutRailItemResult),
+		}
+		for i, item := range batch.Items {
+			fb.results[item.PayoutID] = &PayoutRailItemResult{
+				PayoutID: item.PayoutID,
+				ItemRef:  fmt.Sprintf("%s_item_%d", fb.externalRef, i+1),
+				Status:   PayoutRailItemPending,
+			}
+		}
+		r.batches[batch.Reference] = fb
+	}
+	return &PayoutRailReceipt{ExternalRef: fb.externalRef, Results: fb.snapshot()}, nil
+}
+
+// PollStatus settles every pending item of the batch and returns the results
+func (r *FakePayoutRail) PollStatus(ctx context.Context, ref *PayoutRailRef) ([]PayoutRailItemResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fb := r.find(ref)
+	if fb == nil {
+		return nil, fmt.Errorf("fake rail: unknown batch %s", ref.ExternalRef)
+	}
+	if fb.cancelled {
+		return fb.snapshot(), nil
+	}
+	now := time.Now()
+	for _, item := range fb.items {
+		result := fb.results[item.PayoutID]
+		if result.Status != PayoutRailItemPending {
+			continue
+		}
+		reason, failing := r.failing[item.Payee.Email]
+		if !failing && item.Payee.PayeeID != "" {
+			reason, failing = r.failing[item.Payee.PayeeID]
+		}
+		if failing {
+			result.Status, result.Reason = models.PayoutStatusFailed, reason
+		} else {
+			paidAt := now
+			result.Status, result.PaidAt = models.PayoutStatusPaid, &paidAt
+		}
+	}
+	return fb.snapshot(), nil
+}
+
+// ParseWebhook accepts a PayoutRailEvent as JSON
+func (r *FakePayoutRail) ParseWebhook(ctx context.Context, headers http.Header, body []byte) (*PayoutRailEvent, error) {
+	if r.WebhookSecret == "" || headers.Get("X-Fake-Signature") != r.WebhookSecret {
+		return nil, ErrPayoutRailInvalidSignature
+	}
+	var event PayoutRailEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("invalid fake webhook body: %w", err)
+	}
+	if event.ID == "" || len(event.Results) == 0 {
+		return nil, ErrPayoutRailEventIgnored
+	}
+	return &event, nil
+}
+
+// CancelBatch cancels the batch unless an item was already paid
+func (r *FakePayoutRail) CancelBatch(ctx context.Context, ref *PayoutRailRef) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fb := r.find(ref)
+	if fb == nil {
+		return fmt.Errorf("fake rail: unknown batch %s", ref.ExternalRef)
+	}
+	for _, result := range fb.results {
+		if result.Status == models.PayoutStatusPaid {
+			return ErrPayoutRailCannotCancel
+		}
+	}
+	fb.cancelled = true
+	return nil
+}
+
+// Submitted returns the distinct batches received so far
+func (r *FakePayoutRail) Submitted() []PayoutRailBatch {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	batches := make([]PayoutRailBatch, 0, len(r.batches))
+	for reference, fb := range r.batches {
+		batches = append(batches, PayoutRailBatch{Reference: reference, Items: fb.items})
+	}
+	sort.Slice(batches, func(i, j int) bool { return batches[i].Reference < batches[j].Reference })
+	return batches
+}
+
+func (r *FakePayoutRail) find(ref *PayoutRailRef) *fakeRailBatch {
+	if fb, ok := r.batches[ref.Reference]; ok {
+		return fb
+	}
+	for _, fb := range r.batches {
+		if fb.externalRef == ref.ExternalRef {
+			return fb
+		}
+	}
+	return nil
+}
+
+func (fb *fakeRailBatch) snapshot() []PayoutRailItemResult {
+	results := make([]PayoutRailItemResult, 0, len(fb.items))
+	for _, item := range fb.items {
+		results = append(results, *fb.results[item.PayoutID])
+	}
+	return results
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"net/http"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+)
+
+// ============================================
+// BANK-TRANSFER FILE RAIL
+// ============================================
+
+// BankFileRail exports a transfer file the finance team uploads to the bank
+// (bank_transfer) or to Wise batch payments (wise). Nothing leaves the
+// platform, so results come back through the reconciliation upload.
+type BankFileRail struct {
+	name string
+}
+
+// NewBankFileRail creates a file rail; name selects the file format
+// (models.PayoutRailBankTransfer or models.PayoutRailWise)
+func NewBankFileRail(name string) *BankFileRail {
+	return &BankFileRail{name: name}
+}
+
+// Name returns the rail identifier
+func (r *BankFileRail) Name() string {
+	return r.name
+}
+
+// Enabled is always true: the file works offline
+func (r *BankFileRail) Enabled() bool {
+	return true
+}
+
+// ValidatePayee checks the bank details (Wise also accepts an email recipient)
+func (r *BankFileRail) ValidatePayee(ctx context.Context, payee *PayoutPayee) error {
+	if r.name == models.PayoutRailWise && payee.IBAN == "" {
+		return validatePayeeEmail(payee)
+	}
+	return validatePayeeBank(payee)
+}
+
+// SubmitBatch builds the transfer file; every item stays pending until it
+// is reconciled
+func (r *BankFileRail) SubmitBatch(ctx context.Context, batch *PayoutRailBatch) (*PayoutRailReceipt, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if r.name == models.PayoutRailWise {
+		// Wise batch payment template
+		w.Write([]string{"name", "recipientEmail", "paymentReference", "receiverType", "amountCurrency", "amount", "sourceCurrency", "targetCurrency", "IBAN", "BIC"})
+	} else {
+		w.Write([]string{"payout_id", "beneficiary_name", "iban", "bic", "bank_name", "country", "amount", "currency", "reference"})
+	}
+
+	receipt := &PayoutRailReceipt{ExternalRef: batch.Reference}
+	for _, item := range batch.Items {
+		p := item.Payee
+		amount := formatRailAmount(item.Amount)
+		reference := "AFFTOK " + item.PayoutID.String()[:8]
+		if r.name == models.PayoutRailWise {
+			w.Write([]string{p.AccountName, p.Email, reference, "PRIVATE", item.Amount.Currency, amount,
+				item.Amount.Currency, item.Amount.Currency, NormalizeIBAN(p.IBAN), p.BIC})
+		} else {
+			w.Write([]string{item.PayoutID.String(), p.AccountName, NormalizeIBAN(p.IBAN), p.BIC, p.BankName, p.Country,
+				amount, item.Amount.Currency, reference})
+		}
+		receipt.Results = append(receipt.Results, PayoutRailItemResult{
+			PayoutID: item.PayoutID,
+			ItemRef:  reference,
+			Status:   PayoutRailItemPending,
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+
+	receipt.File = &PayoutRailFile{
+		Name:        batch.Reference + ".csv",
+		ContentType: "text/csv",
+		Content:     buf.Bytes(),
+	}
+	return receipt, nil
+}
+
+// PollStatus has nothing to poll: results arrive with the reconciliation upload
+func (r *BankFileRail) PollStatus(ctx context.Context, ref *PayoutRailRef) ([]PayoutRailItemResult, error) {
+	return nil, nil
+}
+
+// ParseWebhook is not supported by file rails
+func (r *BankFileRail) ParseWebhook(ctx context.Context, headers http.Header, body []byte) (*PayoutRailEvent, error) {
+	return nil, ErrPayoutRailUnsupported
+}
+
+// CancelBatch only withdraws the file; it must not have been uploaded yet
+func (r *BankFileRail) CancelBatch(ctx context.Context, ref *PayoutRailRef) error {
+	return nil
+}
@@ -0,0 +1,352 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+)
+
+// ============================================
+// PAYONEER MASS PAYOUT ADAPTER
+// ============================================
+
+// PayoneerConfig holds Payoneer API configuration
+type PayoneerConfig struct {
+	PartnerID     string // program ID
+	APIKey        string // OAuth client ID
+	APISecret     string // OAuth client secret
+	Environment   string // "sandbox" or "production"
+	BaseURL       string
+	AuthURL       string
+	WebhookSecret string // signs payout notifications (X-Payoneer-Signature)
+	Enabled       bool
+	HTTPClient    *http.Client
+}
+
+// DefaultPayoneerConfig returns a disabled sandbox config
+func DefaultPayoneerConfig() *PayoneerConfig {
+	return &PayoneerConfig{
+		Environment: "sandbox",
+		BaseURL:     "https://api.sandbox.payoneer.com",
+		AuthURL:     "https://login.sandbox.payoneer.com",
+		Enabled:     false,
+	}
+}
+
+// PayoneerConfigFromEnv loads Payoneer configuration from the environment;
+// the rail is enabled once a program and credentials are set
+func PayoneerConfigFromEnv() *PayoneerConfig {
+	cfg := DefaultPayoneerConfig()
+	cfg.PartnerID = os.Getenv("PAYONEER_PROGRAM_ID")
+	cfg.APIKey = os.Getenv("PAYONEER_CLIENT_ID")
+	cfg.APISecret = os.Getenv("PAYONEER_CLIENT_SECRET")
+	cfg.WebhookSecret = os.Getenv("PAYONEER_WEBHOOK_SECRET")
+	if os.Getenv("PAYONEER_ENV") == "production" {
+		cfg.Environment = "production"
+		cfg.BaseURL = "https://api.payoneer.com"
+		cfg.AuthURL = "https://login.payoneer.com"
+	}
+	if v := os.Getenv("PAYONEER_API_BASE"); v != "" {
+		cfg.BaseURL = v
+	}
+	if v := os.Getenv("PAYONEER_AUTH_BASE"); v != "" {
+		cfg.AuthURL = v
+	}
+	cfg.Enabled = cfg.PartnerID != "" && cfg.APIKey != "" && cfg.APISecret != ""
+	return cfg
+}
+
+// PayoneerRail pays promoters with Payoneer Mass Payout. Payees are
+// registered with Payoneer under our payee ID; each payment's
+// client_reference_id is the payout line ID.
+type PayoneerRail struct {
+	cfg PayoneerConfig
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// NewPayoneerRail creates a Payoneer adapter
+func NewPayoneerRail(cfg *PayoneerConfig) *PayoneerRail {
+	c := *cfg
+	if c.HTTPClient == nil {
+		c.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	c.BaseURL = strings.TrimRight(c.BaseURL, "/")
+	c.AuthURL = strings.TrimRight(c.AuthURL, "/")
+	return &PayoneerRail{cfg: c}
+}
+
+// Name returns the rail identifier
+func (r *PayoneerRail) Name() string {
+	return models.PayoutRailPayoneer
+}
+
+// Enabled reports whether the program and credentials are configured
+func (r *PayoneerRail) Enabled() bool {
+	return r.cfg.Enabled
+}
+
+// ValidatePayee checks that the payee is registered and active in the program
+func (r *PayoneerRail) ValidatePayee(ctx context.Context, payee *PayoutPayee) error {
+	payeeID := payoneerPayeeID(payee)
+	var resp struct {
+		Result struct {
+			Status struct {
+				Description string `json:"description"`
+			} `json:"status"`
+		} `json:"result"`
+	}
+	status, err := r.do(ctx, http.MethodGet, r.programPath("/payees/"+url.PathEscape(payeeID)+"/status"), nil, &resp)
+	if status == http.StatusNotFound {
+		return fmt.Errorf("%w: payee %s is not registered with Payoneer", ErrPayoutPayeeInvalid, payeeID)
+	}
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(resp.Result.Status.Description, "active") {
+		return fmt.Errorf("%w: Payoneer account is %s", ErrPayoutPayeeInvalid, strings.ToLower(resp.Result.Status.Description))
+	}
+	return nil
+}
+
+// SubmitBatch creates one mass payout with a payment per line
+func (r *PayoneerRail) SubmitBatch(ctx context.Context, batch *PayoutRailBatch) (*PayoutRailReceipt, error) {
+	type payment struct {
+		ClientReferenceID string `json:"client_reference_id"`
+		PayeeID           string `json:"payee_id"`
+		Description       string `json:"description"`
+		Currency          string `json:"currency"`
+		Amount            string `json:"amount"`
+	}
+	body := struct {
+		Payments []payment `json:"Payments"`
+	}{}
+	receipt := &PayoutRailReceipt{ExternalRef: batch.Reference}
+	for _, item := range batch.Items {
+		body.Payments = append(body.Payments, payment{
+			ClientReferenceID: item.PayoutID.String(),
+			PayeeID:           payoneerPayeeID(&item.Payee),
+			Description:       item.Description,
+			Currency:          item.Amount.Currency,
+			Amount:            formatRailAmount(item.Amount),
+		})
+		receipt.Results = append(receipt.Results, PayoutRailItemResult{
+			PayoutID: item.PayoutID,
+			ItemRef:  item.PayoutID.String(),
+			Status:   PayoutRailItemPending,
+		})
+	}
+	if _, err := r.do(ctx, http.MethodPost, r.programPath("/masspayouts"), body, nil); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// PollStatus reads the status of every payment of the batch
+func (r *PayoneerRail) PollStatus(ctx context.Context, ref *PayoutRailRef) ([]PayoutRailItemResult, error) {
+	var results []PayoutRailItemResult
+	for payoutID := range ref.Items {
+		var resp struct {
+			Result payoneerPayoutStatus `json:"result"`
+		}
+		if _, err := r.do(ctx, http.MethodGet, r.programPath("/payouts/"+payoutID.String()+"/status"), nil, &resp); err != nil {
+			return nil, err
+		}
+		results = append(results, resp.Result.result(payoutID))
+	}
+	return results, nil
+}
+
+// ParseWebhook verifies X-Payoneer-Signature (hex HMAC-SHA256 of the body)
+// and maps a payout status notification
+func (r *PayoneerRail) ParseWebhook(ctx context.Context, headers http.Header, body []byte) (*PayoutRailEvent, error) {
+	if r.cfg.WebhookSecret == "" {
+		return nil, ErrPayoutRailInvalidSignature
+	}
+	mac := hmac.New(sha256.New, []byte(r.cfg.WebhookSecret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(headers.Get("X-Payoneer-Signature")))) {
+		return nil, ErrPayoutRailInvalidSignature
+	}
+
+	var notification struct {
+		EventID           string `json:"event_id"`
+		EventType         string `json:"event_type"`
+		ClientReferenceID string `json:"client_reference_id"`
+		payoneerPayoutStatus
+	}
+	if err := json.Unmarshal(body, &notification); err != nil {
+		return nil, fmt.Errorf("invalid payoneer webhook body: %w", err)
+	}
+	payoutID, err := uuid.Parse(notification.ClientReferenceID)
+	if err != nil || notification.EventID == "" {
+		return nil, ErrPayoutRailEventIgnored
+	}
+	result := notification.result(payoutID)
+	if !result.Settled() {
+		return nil, ErrPayoutRailEventIgnored
+	}
+	return &PayoutRailEvent{ID: notification.EventID, Type: notification.EventType, Results: []PayoutRailItemResult{result}}, nil
+}
+
+// CancelBatch cancels every payment still pending; it fails when one was
+// already transferred
+func (r *PayoneerRail) CancelBatch(ctx context.Context, ref *PayoutRailRef) error {
+	results, err := r.PollStatus(ctx, ref)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if result.Status == models.PayoutStatusPaid {
+			return ErrPayoutRailCannotCancel
+		}
+	}
+	for _, result := range results {
+		if result.Status != PayoutRailItemPending {
+			continue
+		}
+		if _, err := r.do(ctx, http.MethodPost, r.programPath("/payouts/"+result.PayoutID.String()+"/cancel"), struct{}{}, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// payoneerPayoutStatus is the subset of a payout status we read
+type payoneerPayoutStatus struct {
+	Status     string `json:"status"`
+	Reason     string `json:"reason"`
+	PayoutDate string `json:"payout_date"`
+}
+
+func (s payoneerPayoutStatus) result(payoutID uuid.UUID) PayoutRailItemResult {
+	result := PayoutRailItemResult{PayoutID: payoutID, ItemRef: payoutID.String(), Status: PayoutRailItemPending}
+	switch strings.ToLower(s.Status) {
+	case "transferred", "completed", "paid":
+		result.Status = models.PayoutStatusPaid
+		if t, err := time.Parse(time.RFC3339, s.PayoutDate); err == nil {
+			result.PaidAt = &t
+		}
+	case "cancelled", "canceled", "failed", "declined", "rejected", "returned":
+		result.Status = models.PayoutStatusFailed
+		result.Reason = s.Reason
+		if result.Reason == "" {
+			result.Reason = "payoneer: " + strings.ToLower(s.Status)
+		}
+	}
+	return result
+}
+
+func payoneerPayeeID(payee *PayoutPayee) string {
+	if payee.PayeeID != "" {
+		return payee.PayeeID
+	}
+	return payee.UserID.String()
+}
+
+func (r *PayoneerRail) programPath(path string) string {
+	return "/v4/programs/" + url.PathEscape(r.cfg.PartnerID) + path
+}
+
+// accessToken returns a cached client-credentials token
+func (r *PayoneerRail) accessToken(ctx context.Context) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.token != "" && time.Now().Before(r.tokenExpiry) {
+		return r.token, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}, "scope": {"read write"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.cfg.AuthURL+"/api/v2/oauth2/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(r.cfg.APIKey, r.cfg.APISecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := r.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("payoneer token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("payoneer token request: status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("invalid payoneer token response")
+	}
+	r.token = token.AccessToken
+	r.tokenExpiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return r.token, nil
+}
+
+// do sends a JSON request to the Payoneer API
+func (r *PayoneerRail) do(ctx context.Context, method, path string, in, out interface{}) (int, error) {
+	if !r.Enabled() {
+		return 0, ErrPayoutRailDisabled
+	}
+	token, err := r.accessToken(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return 0, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, r.cfg.BaseURL+path, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := r.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("payoneer request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		json.Unmarshal(data, &apiErr)
+		return resp.StatusCode, fmt.Errorf("payoneer: status %d: %s %s", resp.StatusCode, apiErr.Error, apiErr.ErrorDescription)
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("invalid payoneer response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
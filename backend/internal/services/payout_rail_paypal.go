@@ -0,0 +1,353 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+)
+
+// ============================================
+// PAYPAL PAYOUTS ADAPTER
+// ============================================
+
+// PayPalConfig holds PayPal Payouts API configuration
+type PayPalConfig struct {
+	ClientID     string
+	ClientSecret string
+	WebhookID    string // webhook registered for PAYMENT.PAYOUTS-ITEM.* events
+	BaseURL      string
+	HTTPClient   *http.Client
+}
+
+// PayPalConfigFromEnv loads PayPal configuration from the environment
+// (sandbox unless PAYPAL_ENV=live)
+func PayPalConfigFromEnv() PayPalConfig {
+	cfg := PayPalConfig{
+		ClientID:     os.Getenv("PAYPAL_CLIENT_ID"),
+		ClientSecret: os.Getenv("PAYPAL_CLIENT_SECRET"),
+		WebhookID:    os.Getenv("PAYPAL_WEBHOOK_ID"),
+		BaseURL:      os.Getenv("PAYPAL_API_BASE"),
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://api-m.sandbox.paypal.com"
+		if os.Getenv("PAYPAL_ENV") == "live" {
+			cfg.BaseURL = "https://api-m.paypal.com"
+		}
+	}
+	return cfg
+}
+
+// PayPalRail pays promoters with PayPal Payouts to their PayPal email. Each
+// item's sender_item_id is the payout line ID.
+type PayPalRail struct {
+	cfg PayPalConfig
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// NewPayPalRail creates a PayPal adapter
+func NewPayPalRail(cfg PayPalConfig) *PayPalRail {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	return &PayPalRail{cfg: cfg}
+}
+
+// Name returns the rail identifier
+func (r *PayPalRail) Name() string {
+	return models.PayoutRailPayPal
+}
+
+// Enabled reports whether API credentials are configured
+func (r *PayPalRail) Enabled() bool {
+	return r.cfg.ClientID != "" && r.cfg.ClientSecret != ""
+}
+
+// ValidatePayee checks the receiver email; PayPal has no pre-payment lookup,
+// unknown receivers end up UNCLAIMED and are returned after 30 days
+func (r *PayPalRail) ValidatePayee(ctx context.Context, payee *PayoutPayee) error {
+	return validatePayeeEmail(payee)
+}
+
+// SubmitBatch creates a payout batch; sender_batch_id makes retries safe
+// (PayPal rejects a reused sender_batch_id)
+func (r *PayPalRail) SubmitBatch(ctx context.Context, batch *PayoutRailBatch) (*PayoutRailReceipt, error) {
+	type amount struct {
+		Value    string `json:"value"`
+		Currency string `json:"currency"`
+	}
+	type item struct {
+		RecipientType string `json:"recipient_type"`
+		Amount        amount `json:"amount"`
+		Receiver      string `json:"receiver"`
+		SenderItemID  string `json:"sender_item_id"`
+		Note          string `json:"note,omitempty"`
+	}
+	body := struct {
+		SenderBatchHeader struct {
+			SenderBatchID string `json:"sender_batch_id"`
+			EmailSubject  string `json:"email_subject"`
+		} `json:"sender_batch_header"`
+		Items []item `json:"items"`
+	}{}
+	body.SenderBatchHeader.SenderBatchID = batch.Reference
+	body.SenderBatchHeader.EmailSubject = "AffTok earnings " + batch.Period
+	for _, it := range batch.Items {
+		body.Items = append(body.Items, item{
+			RecipientType: "EMAIL",
+			Amount:        amount{Value: formatRailAmount(it.Amount), Currency: it.Amount.Currency},
+			Receiver:      it.Payee.Email,
+			SenderItemID:  it.PayoutID.String(),
+			Note:          it.Description,
+		})
+	}
+
+	var resp paypalBatch
+	if _, err := r.do(ctx, http.MethodPost, "/v1/payments/payouts", body, &resp); err != nil {
+		return nil, err
+	}
+	receipt := &PayoutRailReceipt{ExternalRef: resp.BatchHeader.PayoutBatchID}
+	for _, it := range batch.Items {
+		receipt.Results = append(receipt.Results, PayoutRailItemResult{PayoutID: it.PayoutID, Status: PayoutRailItemPending})
+	}
+	return receipt, nil
+}
+
+// PollStatus reads the batch and maps each item's transaction status
+func (r *PayPalRail) PollStatus(ctx context.Context, ref *PayoutRailRef) ([]PayoutRailItemResult, error) {
+	var results []PayoutRailItemResult
+	for page := 1; ; page++ {
+		var resp paypalBatch
+		path := fmt.Sprintf("/v1/payments/payouts/%s?page=%d&page_size=1000&total_required=true", url.PathEscape(ref.ExternalRef), page)
+		if _, err := r.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+			return nil, err
+		}
+		for _, it := range resp.Items {
+			if result, ok := it.result(); ok {
+				results = append(results, result)
+			}
+		}
+		if page >= resp.TotalPages || len(resp.Items) == 0 {
+			return results, nil
+		}
+	}
+}
+
+// ParseWebhook verifies the event with PayPal's verify-webhook-signature API
+// and maps PAYMENT.PAYOUTS-ITEM.* events
+func (r *PayPalRail) ParseWebhook(ctx context.Context, headers http.Header, body []byte) (*PayoutRailEvent, error) {
+	if r.cfg.WebhookID == "" || headers.Get("PAYPAL-TRANSMISSION-SIG") == "" {
+		return nil, ErrPayoutRailInvalidSignature
+	}
+	verify := map[string]interface{}{
+		"auth_algo":         headers.Get("PAYPAL-AUTH-ALGO"),
+		"cert_url":          headers.Get("PAYPAL-CERT-URL"),
+		"transmission_id":   headers.Get("PAYPAL-TRANSMISSION-ID"),
+		"transmission_sig":  headers.Get("PAYPAL-TRANSMISSION-SIG"),
+		"transmission_time": headers.Get("PAYPAL-TRANSMISSION-TIME"),
+		"webhook_id":        r.cfg.WebhookID,
+		"webhook_event":     json.RawMessage(body),
+	}
+	var verified struct {
+		VerificationStatus string `json:"verification_status"`
+	}
+	if _, err := r.do(ctx, http.MethodPost, "/v1/notifications/verify-webhook-signature", verify, &verified); err != nil {
+		return nil, err
+	}
+	if verified.VerificationStatus != "SUCCESS" {
+		return nil, ErrPayoutRailInvalidSignature
+	}
+
+	var event struct {
+		ID        string     `json:"id"`
+		EventType string     `json:"event_type"`
+		Resource  paypalItem `json:"resource"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("invalid paypal webhook body: %w", err)
+	}
+	if !strings.HasPrefix(event.EventType, "PAYMENT.PAYOUTS-ITEM.") {
+		return nil, ErrPayoutRailEventIgnored
+	}
+	result, ok := event.Resource.result()
+	if !ok || !result.Settled() {
+		return nil, ErrPayoutRailEventIgnored
+	}
+	return &PayoutRailEvent{
+		ID:          event.ID,
+		Type:        event.EventType,
+		ExternalRef: event.Resource.PayoutBatchID,
+		Results:     []PayoutRailItemResult{result},
+	}, nil
+}
+
+// CancelBatch cancels the batch's unclaimed items. PayPal can only cancel
+// UNCLAIMED items, so it fails once any item was paid or is in flight.
+func (r *PayPalRail) CancelBatch(ctx context.Context, ref *PayoutRailRef) error {
+	var resp paypalBatch
+	if _, err := r.do(ctx, http.MethodGet, "/v1/payments/payouts/"+url.PathEscape(ref.ExternalRef)+"?page_size=1000", nil, &resp); err != nil {
+		return err
+	}
+	var unclaimed []string
+	for _, it := range resp.Items {
+		switch it.TransactionStatus {
+		case "UNCLAIMED":
+			unclaimed = append(unclaimed, it.PayoutItemID)
+		case "FAILED", "RETURNED", "BLOCKED", "REFUNDED", "REVERSED":
+		default:
+			return ErrPayoutRailCannotCancel
+		}
+	}
+	for _, itemID := range unclaimed {
+		if _, err := r.do(ctx, http.MethodPost, "/v1/payments/payouts-item/"+url.PathEscape(itemID)+"/cancel", nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// paypalBatch is the subset of a payout batch we read
+type paypalBatch struct {
+	BatchHeader struct {
+		PayoutBatchID string `json:"payout_batch_id"`
+		BatchStatus   string `json:"batch_status"`
+	} `json:"batch_header"`
+	Items      []paypalItem `json:"items"`
+	TotalPages int          `json:"total_pages"`
+}
+
+// paypalItem is the subset of a payout item we read
+type paypalItem struct {
+	PayoutItemID      string `json:"payout_item_id"`
+	PayoutBatchID     string `json:"payout_batch_id"`
+	TransactionStatus string `json:"transaction_status"`
+	TimeProcessed     string `json:"time_processed"`
+	PayoutItem        struct {
+		SenderItemID string `json:"sender_item_id"`
+	} `json:"payout_item"`
+	Errors struct {
+		Name    string `json:"name"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func (it paypalItem) result() (PayoutRailItemResult, bool) {
+	payoutID, err := uuid.Parse(it.PayoutItem.SenderItemID)
+	if err != nil {
+		return PayoutRailItemResult{}, false
+	}
+	result := PayoutRailItemResult{PayoutID: payoutID, ItemRef: it.PayoutItemID, Status: PayoutRailItemPending}
+	switch it.TransactionStatus {
+	case "SUCCESS":
+		result.Status = models.PayoutStatusPaid
+		if t, err := time.Parse(time.RFC3339, it.TimeProcessed); err == nil {
+			result.PaidAt = &t
+		}
+	case "FAILED", "RETURNED", "BLOCKED", "REFUNDED", "REVERSED":
+		result.Status = models.PayoutStatusFailed
+		result.Reason = it.Errors.Message
+		if result.Reason == "" {
+			result.Reason = "paypal: " + strings.ToLower(it.TransactionStatus)
+		}
+	}
+	// PENDING, UNCLAIMED and ONHOLD stay pending
+	return result, true
+}
+
+// accessToken returns a cached client-credentials token
+func (r *PayPalRail) accessToken(ctx context.Context) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.token != "" && time.Now().Before(r.tokenExpiry) {
+		return r.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.cfg.BaseURL+"/v1/oauth2/token",
+		strings.NewReader(url.Values{"grant_type": {"client_credentials"}}.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(r.cfg.ClientID, r.cfg.ClientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := r.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("paypal token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("paypal token request: status %d", resp.StatusCode)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("invalid paypal token response")
+	}
+	r.token = token.AccessToken
+	r.tokenExpiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return r.token, nil
+}
+
+// do sends a JSON request to the PayPal API
+func (r *PayPalRail) do(ctx context.Context, method, path string, in, out interface{}) (int, error) {
+	if !r.Enabled() {
+		return 0, ErrPayoutRailDisabled
+	}
+	token, err := r.accessToken(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return 0, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, r.cfg.BaseURL+path, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("paypal request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Name    string `json:"name"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &apiErr)
+		return resp.StatusCode, fmt.Errorf("paypal: status %d: %s %s", resp.StatusCode, apiErr.Name, apiErr.Message)
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("invalid paypal response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
@@ -2,6 +2,10 @@ package services
 
 import (
 	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,36 +15,71 @@ import (
 )
 
 // PayoutService handles payout business logic
-// خدمة الدفعات - نظام Payoneer (معطل حالياً)
+// خدمة الدفعات - الدفع عبر قنوات الدفع (Payoneer, PayPal, ملفات التحويل البنكي)
 type PayoutService struct {
-	db *gorm.DB
+	db                   *gorm.DB
+	kycEarningsThreshold float64
+	rails                *PayoutRails
+	ledger               *LedgerService
+	settings             *TenantSettingsResolver
+
+	mu      sync.Mutex
+	running bool
+	stop    chan struct{}
 }
 
+// DefaultPayoutKYCThreshold is the lifetime payout amount above which a
+// promoter must be KYC verified before being paid
+const DefaultPayoutKYCThreshold = 500.0
+
+// PayoutHoldReasonKYC marks payouts held until the promoter passes KYC
+const PayoutHoldReasonKYC = "kyc_required"
+
 // NewPayoutService creates a new payout service
 func NewPayoutService(db *gorm.DB) *PayoutService {
-	return &PayoutService{db: db}
-}
-
-// PayoneerConfig holds Payoneer API configuration (للمستقبل)
-type PayoneerConfig struct {
-	PartnerID   string
-	APIKey      string
-	APISecret   string
-	Environment string // "sandbox" or "production"
-	BaseURL     string
-	Enabled     bool // false حالياً
-}
-
-// DefaultPayoneerConfig returns disabled config
-func DefaultPayoneerConfig() *PayoneerConfig {
-	return &PayoneerConfig{
-		PartnerID:   "",
-		APIKey:      "",
-		APISecret:   "",
-		Environment: "sandbox",
-		BaseURL:     "https://api.sandbox.payoneer.com",
-		Enabled:     false, // معطل حالياً
+	threshold := DefaultPayoutKYCThreshold
+	if v, err := strconv.ParseFloat(os.Getenv("PAYOUT_KYC_THRESHOLD"), 64); err == nil && v >= 0 {
+		threshold = v
+	}
+	return &PayoutService{db: db, kycEarningsThreshold: threshold, rails: DefaultPayoutRails(), settings: GetTenantSettingsResolver(db)}
+}
+
+// SetLedgerService sets the ledger batches are generated from and paid
+// payouts are posted to (default: the shared ledger service)
+func (s *PayoutService) SetLedgerService(ledger *LedgerService) {
+	s.ledger = ledger
+}
+
+// SetSettingsResolver sets where tenant payout settings are read from
+func (s *PayoutService) SetSettingsResolver(settings *TenantSettingsResolver) {
+	s.settings = settings
+}
+
+func (s *PayoutService) ledgerService() *LedgerService {
+	if s.ledger == nil {
+		return GetLedgerService(s.db)
 	}
+	return s.ledger
+}
+
+// SetRails sets the payout rails (for dependency injection)
+func (s *PayoutService) SetRails(rails *PayoutRails) {
+	s.rails = rails
+}
+
+// Rails returns the configured payout rails
+func (s *PayoutService) Rails() *PayoutRails {
+	return s.rails
+}
+
+// SetKYCEarningsThreshold sets the lifetime amount above which KYC is required
+func (s *PayoutService) SetKYCEarningsThreshold(threshold float64) {
+	s.kycEarningsThreshold = threshold
+}
+
+// KYCEarningsThreshold returns the configured KYC earnings threshold
+func (s *PayoutService) KYCEarningsThreshold() float64 {
+	return s.kycEarningsThreshold
 }
 
 // CalculateMonthlyPayouts calculates payouts for a specific month
@@ -49,60 +88,116 @@ func (s *PayoutService) CalculateMonthlyPayouts(year, month int) ([]models.Payou
 	periodEnd := periodStart.AddDate(0, 1, 0).Add(-time.Second)
 	period := fmt.Sprintf("%d-%02d", year, month)
 	
-	// جلب التحويلات المؤكدة مجمعة حسب المعلن والمروج
-	type ConversionAgg struct {
-		AdvertiserID uuid.UUID
-		PublisherID  uuid.UUID
-		TotalAmount  float64
-		TotalCount   int
-	}
-	
-	var aggregations []ConversionAgg
-	
-	err := s.db.Table("conversions c").
-		Select(`
-			o.advertiser_id,
-			uo.user_id as publisher_id,
-			COALESCE(SUM(c.amount), 0) as total_amount,
-			COUNT(*) as total_count
-		`).
-		Joins("JOIN user_offers uo ON c.user_offer_id = uo.id").
-		Joins("JOIN offers o ON uo.offer_id = o.id").
-		Where("c.status = ?", "approved").
-		Where("c.converted_at >= ? AND c.converted_at <= ?", periodStart, periodEnd).
-		Where("o.advertiser_id IS NOT NULL").
-		Group("o.advertiser_id, uo.user_id").
-		Scan(&aggregations).Error
-	
+	// المبالغ من دفتر الأستاذ - Amounts are derived from the earnings ledger,
+	// one payout per advertiser × promoter × currency
+	ledger := s.ledgerService()
+	totals, err := ledger.PeriodTotals(nil, periodStart, periodStart.AddDate(0, 1, 0))
 	if err != nil {
-		return nil, fmt.Errorf("failed to aggregate conversions: %w", err)
+		return nil, fmt.Errorf("failed to aggregate ledger: %w", err)
 	}
-	
+
 	// إنشاء كائنات Payout
 	var payouts []models.Payout
-	for _, agg := range aggregations {
+	for _, total := range totals {
+		if total.AdvertiserID == nil || total.Commission <= 0 {
+			continue
+		}
 		payout := models.Payout{
 			ID:               uuid.New(),
-			AdvertiserID:     agg.AdvertiserID,
-			PublisherID:      agg.PublisherID,
-			Amount:           agg.TotalAmount,
-			PlatformFee:      agg.TotalAmount * 0.10,
-			NetAmount:        agg.TotalAmount * 0.90,
-			Currency:         "USD",
+			AdvertiserID:     *total.AdvertiserID,
+			PublisherID:      total.PromoterID,
+			Amount:           MinorToMajor(total.Gross(), total.Currency),
+			PlatformFee:      MinorToMajor(total.PlatformFee, total.Currency),
+			NetAmount:        MinorToMajor(total.Commission, total.Currency),
+			Currency:         total.Currency,
 			Period:           period,
 			PeriodStart:      periodStart,
 			PeriodEnd:        periodEnd,
-			ConversionsCount: agg.TotalCount,
+			ConversionsCount: total.Conversions,
 			Status:           models.PayoutStatusPending,
 			CreatedAt:        time.Now(),
 			UpdatedAt:        time.Now(),
 		}
+		payout.TenantID = total.TenantID
+
+		s.setReporting(ledger, &payout, total, periodEnd)
 		payouts = append(payouts, payout)
 	}
+	s.ApplyKYCHolds(payouts)
 	
 	return payouts, nil
 }
 
+// ============================================================
+// KYC Gate - حجز الدفعات حتى التحقق من الهوية
+// ============================================================
+
+// CheckPayoutKYC reports whether a promoter may be paid the given amount.
+// Once lifetime payouts (including this one) cross the threshold, the
+// promoter must be KYC verified.
+func (s *PayoutService) CheckPayoutKYC(publisherID uuid.UUID, amount float64) (bool, string) {
+	var user models.AfftokUser
+	if err := s.db.Select("id, kyc_status").First(&user, "id = ?", publisherID).Error; err != nil {
+		return false, "user_not_found"
+	}
+	if user.IsKYCVerified() {
+		return true, ""
+	}
+
+	var lifetime float64
+	s.db.Model(&models.Payout{}).
+		Where("publisher_id = ? AND status IN ?", publisherID, []string{
+			models.PayoutStatusPending, models.PayoutStatusApproved, models.PayoutStatusProcessing, models.PayoutStatusPaid, models.PayoutStatusOnHold,
+		}).
+		Select("COALESCE(SUM(net_amount), 0)").
+		Scan(&lifetime)
+
+	if lifetime+amount <= s.kycEarningsThreshold {
+		return true, ""
+	}
+	return false, PayoutHoldReasonKYC
+}
+
+// ApplyKYCHolds puts pending payouts on hold for promoters who need KYC and
+// flags those promoters as requiring verification. Amounts for the same
+// promoter within the slice are added up before checking the threshold.
+func (s *PayoutService) ApplyKYCHolds(payouts []models.Payout) int {
+	held := 0
+	running := make(map[uuid.UUID]float64)
+	required := make(map[uuid.UUID]bool)
+
+	for i := range payouts {
+		payout := &payouts[i]
+		if payout.Status != models.PayoutStatusPending {
+			continue
+		}
+
+		running[payout.PublisherID] += payout.NetAmount
+		allowed, reason := s.CheckPayoutKYC(payout.PublisherID, running[payout.PublisherID])
+		if allowed {
+			continue
+		}
+
+		payout.Status = models.PayoutStatusOnHold
+		payout.HoldReason = reason
+		held++
+
+		if reason == PayoutHoldReasonKYC && !required[payout.PublisherID] {
+			required[payout.PublisherID] = true
+			if err := NewKYCAutoService(s.db).triggerKYCRequired(payout.PublisherID, "payout_threshold"); err != nil {
+				log.Printf("[Payout] failed to require KYC for %s: %v", payout.PublisherID, err)
+			}
+		}
+	}
+
+	return held
+}
+
+// ReleaseKYCHolds moves a promoter's KYC-held payouts back to pending
+func (s *PayoutService) ReleaseKYCHolds(publisherID uuid.UUID) (int64, error) {
+	return s.ReleaseHolds(publisherID, PayoutHoldReasonKYC, nil)
+}
+
 // CreateBatch creates a new payout batch
 func (s *PayoutService) CreateBatch(year, month int, payouts []models.Payout) (*models.PayoutBatch, error) {
 	period := fmt.Sprintf("%d-%02d", year, month)
@@ -189,64 +284,13 @@ func (s *PayoutService) GetAdvertiserPendingAmount(advertiserID uuid.UUID) (floa
 	return total, err
 }
 
-// ============================================================
-// Payoneer API Integration (معطل حالياً - للمستقبل)
-// ============================================================
-
-// SubmitBatchToPayoneer submits a batch to Payoneer API
-// هذه الدالة معطلة حالياً - ستُفعّل بعد التعاقد مع Payoneer
-func (s *PayoutService) SubmitBatchToPayoneer(batchID uuid.UUID, config *PayoneerConfig) error {
-	if !config.Enabled {
-		return fmt.Errorf("payoneer integration is not enabled - waiting for partnership")
-	}
-	
-	// TODO: Implement Payoneer API integration
-	// 1. Get batch with payouts
-	// 2. Validate all users have Payoneer emails
-	// 3. Create Payoneer batch request
-	// 4. Submit to Payoneer API
-	// 5. Update batch status
-	// 6. Handle response
-	
-	return fmt.Errorf("payoneer integration not implemented yet")
-}
-
-// ValidatePayoutsForPayoneer validates that all parties have Payoneer accounts
-func (s *PayoutService) ValidatePayoutsForPayoneer(payouts []models.Payout) ([]string, error) {
-	var errors []string
-	
-	for _, p := range payouts {
-		// تحقق من المعلن
-		var advertiser models.AfftokUser
-		if err := s.db.Select("id, email, payoneer_email, payoneer_status").
-			Where("id = ?", p.AdvertiserID).First(&advertiser).Error; err == nil {
-			if advertiser.PayoneerEmail == "" {
-				errors = append(errors, fmt.Sprintf("Advertiser %s has no Payoneer email", advertiser.Email))
-			}
-		}
-		
-		// تحقق من المروج
-		var publisher models.AfftokUser
-		if err := s.db.Select("id, email, payoneer_email, payoneer_status").
-			Where("id = ?", p.PublisherID).First(&publisher).Error; err == nil {
-			if publisher.PayoneerEmail == "" {
-				errors = append(errors, fmt.Sprintf("Publisher %s has no Payoneer email", publisher.Email))
-			}
-		}
-	}
-	
-	return errors, nil
-}
-
-// GetSystemStatus returns the current status of Payoneer integration
+// GetSystemStatus returns the current status of the payout rails
 func (s *PayoutService) GetSystemStatus() map[string]interface{} {
 	return map[string]interface{}{
-		"payoneer_enabled":     false,
-		"status":               "coming_soon",
-		"message_ar":           "نظام الدفعات التلقائية عبر Payoneer قادم قريباً",
-		"message_en":           "Automatic Payoneer payout system coming soon",
-		"current_system":       "direct_payment",
-		"current_system_desc":  "Promoters set their payment method, advertisers pay directly",
+		"rails":                  s.rails.Status(),
+		"current_system":         "payout_rails",
+		"current_system_desc":    "Lines are paid through the promoter's default payout method; lines without one are settled manually",
+		"kyc_earnings_threshold": s.kycEarningsThreshold,
 	}
 }
 
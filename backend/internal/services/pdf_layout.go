@@ -0,0 +1,215 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ============================================
+// BILINGUAL PDF LAYOUT (ARABIC / ENGLISH)
+// ============================================
+// Captions are printed in English on the left and Arabic on the right.
+// Arabic needs a TrueType font with Arabic glyphs: PDF_FONT (and
+// PDF_FONT_BOLD), else DejaVu Sans from the system font directory.
+// Without one documents are English only.
+
+// pdfFontPaths are tried when PDF_FONT is not set
+var pdfFontPaths = []string{
+	"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+	"/usr/share/fonts/dejavu/DejaVuSans.ttf",
+	"/usr/share/fonts/TTF/DejaVuSans.ttf",
+}
+
+var (
+	pdfFontsOnce   sync.Once
+	pdfFontRegular *trueTypeFont
+	pdfFontBold    *trueTypeFont
+)
+
+func loadPDFFonts() (*trueTypeFont, *trueTypeFont) {
+	pdfFontsOnce.Do(func() {
+		regular, bold := os.Getenv("PDF_FONT"), os.Getenv("PDF_FONT_BOLD")
+		if regular == "" {
+			for _, path := range pdfFontPaths {
+				if _, err := os.Stat(path); err == nil {
+					regular = path
+					break
+				}
+			}
+		}
+		if regular != "" && bold == "" {
+			if candidate := strings.TrimSuffix(regular, ".ttf") + "-Bold.ttf"; candidate != regular {
+				if _, err := os.Stat(candidate); err == nil {
+					bold = candidate
+				}
+			}
+		}
+
+		load := func(path string) *trueTypeFont {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				log.Printf("[PDF] cannot read font %s: %v", path, err)
+				return nil
+			}
+			font, err := parseTrueType(data, path)
+			if err != nil {
+				log.Printf("[PDF] cannot use font %s: %v", path, err)
+				return nil
+			}
+			return font
+		}
+		if regular != "" {
+			pdfFontRegular = load(regular)
+		}
+		if bold != "" {
+			pdfFontBold = load(bold)
+		}
+		if pdfFontBold == nil {
+			pdfFontBold = pdfFontRegular
+		}
+		if pdfFontRegular == nil {
+			log.Printf("[PDF] no TrueType font configured (PDF_FONT): documents are English only")
+		}
+	})
+	return pdfFontRegular, pdfFontBold
+}
+
+// Page geometry of bilingual documents
+const (
+	pdfMarginLeft   = 40.0
+	pdfMarginRight  = pdfPageWidth - 40
+	pdfMarginBottom = 90.0
+)
+
+// pdfColumn is one column of a table header. Arabic is aligned at ArabicX
+// when set, so a left-aligned English caption can have a right-aligned
+// Arabic one.
+type pdfColumn struct {
+	Label   [2]string
+	X       float64
+	Align   pdfAlign
+	ArabicX float64
+}
+
+// bilingualPDF lays an A4 document out page by page
+type bilingualPDF struct {
+	doc     pdfDocument
+	page    *pdfPage
+	regular *pdfFont
+	bold    *pdfFont
+	y       float64
+}
+
+func newBilingualPDF() *bilingualPDF {
+	regular, bold := loadPDFFonts()
+	p := &bilingualPDF{}
+	p.regular = p.doc.addFont(regular, false)
+	p.bold = p.doc.addFont(bold, true)
+	p.newPage()
+	return p
+}
+
+func (p *bilingualPDF) newPage() {
+	p.page = p.doc.addPage()
+	p.y = 800
+}
+
+// ensure starts a new page when less than height is left
+func (p *bilingualPDF) ensure(height float64) bool {
+	if p.y-height >= pdfMarginBottom {
+		return false
+	}
+	p.newPage()
+	return true
+}
+
+// header prints the issuer on the left and the bilingual title on the right
+func (p *bilingualPDF) header(issuer string, title [2]string) {
+	if issuer == "" {
+		issuer = "AffTok"
+	}
+	p.page.Text(p.bold, 16, pdfMarginLeft, 800, issuer, pdfAlignLeft)
+	p.page.Text(p.bold, 18, pdfMarginRight, 800, title[0], pdfAlignRight)
+	p.page.Text(p.bold, 14, pdfMarginRight, 780, title[1], pdfAlignRight)
+	p.page.Line(pdfMarginLeft, 770, pdfMarginRight, 770, 1, 0.6)
+	p.y = 750
+}
+
+// detail prints an English caption, a value and the Arabic caption on one row
+func (p *bilingualPDF) detail(label [2]string, value string) {
+	p.page.Gray(0.35)
+	p.page.Text(p.regular, 9, pdfMarginLeft, p.y, label[0], pdfAlignLeft)
+	p.page.Text(p.regular, 9, pdfMarginRight, p.y, label[1], pdfAlignRight)
+	p.page.Gray(0)
+	p.page.Text(p.regular, 10, 160, p.y, value, pdfAlignLeft)
+	p.y -= 16
+}
+
+func (p *bilingualPDF) caption(label [2]string) {
+	p.page.Text(p.bold, 11, pdfMarginLeft, p.y, label[0], pdfAlignLeft)
+	p.page.Text(p.bold, 11, pdfMarginRight, p.y, label[1], pdfAlignRight)
+	p.y -= 16
+}
+
+// note prints a small bilingual remark over two lines
+func (p *bilingualPDF) note(label [2]string) {
+	p.ensure(30)
+	p.page.Text(p.regular, 8, pdfMarginLeft, p.y, label[0], pdfAlignLeft)
+	p.y -= 11
+	p.page.Text(p.regular, 8, pdfMarginRight, p.y, label[1], pdfAlignRight)
+	p.y -= 14
+}
+
+func (p *bilingualPDF) tableHeader(columns []pdfColumn) {
+	p.page.Rect(pdfMarginLeft, p.y-18, pdfMarginRight-pdfMarginLeft, 32, 0.92)
+	for _, col := range columns {
+		p.page.Text(p.bold, 9, col.X, p.y, col.Label[0], col.Align)
+		if col.ArabicX != 0 {
+			p.page.Text(p.regular, 9, col.ArabicX, p.y-12, col.Label[1], pdfAlignRight)
+		} else {
+			p.page.Text(p.regular, 9, col.X, p.y-12, col.Label[1], col.Align)
+		}
+	}
+	p.y -= 34
+}
+
+// fit shortens s with an ellipsis to fit width at the table font size
+func (p *bilingualPDF) fit(s string, width float64) string {
+	if p.regular.Width(s, 9) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && p.regular.Width(string(runes)+"...", 9) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+// amountRow prints a bilingual caption and an amount in the totals column
+func (p *bilingualPDF) amountRow(english, arabic, amount string, strong bool) {
+	font, size := p.regular, 10.0
+	if strong {
+		font, size = p.bold, 11
+		p.page.Line(300, p.y+13, pdfMarginRight, p.y+13, 1, 0.3)
+	}
+	p.page.Text(font, size, 300, p.y, english, pdfAlignLeft)
+	p.page.Text(font, size, 445, p.y, arabic, pdfAlignRight)
+	p.page.Text(font, size, pdfMarginRight-6, p.y, amount, pdfAlignRight)
+	p.y -= 18
+}
+
+// finish adds a footer with the bilingual remark and page numbers to every
+// page and returns the PDF
+func (p *bilingualPDF) finish(remark [2]string, reference string) ([]byte, error) {
+	for i, page := range p.doc.pages {
+		page.Gray(0.45)
+		page.Text(p.regular, 8, pdfMarginLeft, 40, remark[0], pdfAlignLeft)
+		page.Text(p.regular, 8, pdfMarginRight, 40, remark[1], pdfAlignRight)
+		page.Text(p.regular, 8, pdfPageWidth/2, 40, fmt.Sprintf("%s  %d / %d", reference, i+1, len(p.doc.pages)), pdfAlignCenter)
+		page.Gray(0)
+	}
+	return p.doc.Bytes()
+}
@@ -0,0 +1,830 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ============================================
+// MINIMAL PDF WRITER
+// ============================================
+// Just enough PDF for generated documents (invoices): text, lines and filled
+// rectangles on A4 pages. Text is set in an embedded TrueType font (subset
+// to the glyphs used) so Arabic and other non-Latin scripts render;
+// without a font it falls back to Helvetica and Latin-1 text only.
+
+// A4 page size in points
+const (
+	pdfPageWidth  = 595.28
+	pdfPageHeight = 841.89
+)
+
+// ErrPDFFont is returned for font files that cannot be embedded
+var ErrPDFFont = errors.New("unsupported font file")
+
+type pdfAlign int
+
+const (
+	pdfAlignLeft pdfAlign = iota
+	pdfAlignRight
+	pdfAlignCenter
+)
+
+// pdfDocument is a PDF being built
+type pdfDocument struct {
+	pages []*pdfPage
+	fonts []*pdfFont
+}
+
+// pdfPage holds one page's content stream
+type pdfPage struct {
+	content bytes.Buffer
+}
+
+func (d *pdfDocument) addPage() *pdfPage {
+	page := &pdfPage{}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// addFont registers a font; a nil TrueType font falls back to the Helvetica
+// standard font (bold selects Helvetica-Bold)
+func (d *pdfDocument) addFont(ttf *trueTypeFont, bold bool) *pdfFont {
+	f := &pdfFont{ttf: ttf, bold: bold, index: len(d.fonts), resource: fmt.Sprintf("F%d", len(d.fonts)+1), used: map[uint16]rune{}}
+	d.fonts = append(d.fonts, f)
+	return f
+}
+
+// Text draws s with its baseline at y. x is the left, right or center edge
+// depending on align. Arabic text is shaped and laid out right to left.
+func (p *pdfPage) Text(f *pdfFont, size, x, y float64, s string, align pdfAlign) {
+	if s == "" {
+		return
+	}
+	if containsArabic(s) {
+		if f.ttf == nil {
+			return // the standard fonts have no Arabic glyphs
+		}
+		s = bidiVisual(shapeArabic(s))
+	}
+	encoded, width := f.encode(s, size)
+	switch align {
+	case pdfAlignRight:
+		x -= width
+	case pdfAlignCenter:
+		x -= width / 2
+	}
+	fmt.Fprintf(&p.content, "BT /%s %.2f Tf %.2f %.2f Td %s Tj ET\n", f.resource, size, x, y, encoded)
+}
+
+// Line draws a line of the given width and gray level (0 black, 1 white)
+func (p *pdfPage) Line(x1, y1, x2, y2, width, gray float64) {
+	fmt.Fprintf(&p.content, "q %.2f G %.2f w %.2f %.2f m %.2f %.2f l S Q\n", gray, width, x1, y1, x2, y2)
+}
+
+// Rect fills a rectangle with a gray level
+func (p *pdfPage) Rect(x, y, w, h, gray float64) {
+	fmt.Fprintf(&p.content, "q %.2f g %.2f %.2f %.2f %.2f re f Q\n", gray, x, y, w, h)
+}
+
+// Gray sets the fill (text) color for what follows
+func (p *pdfPage) Gray(gray float64) {
+	fmt.Fprintf(&p.content, "%.2f g\n", gray)
+}
+
+// Bytes serializes the document
+func (d *pdfDocument) Bytes() ([]byte, error) {
+	var out bytes.Buffer
+	var offsets []int
+	next := 1
+	reserve := func() int {
+		n := next
+		next++
+		offsets = append(offsets, 0)
+		return n
+	}
+	write := func(n int, body string) {
+		offsets[n-1] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", n, body)
+	}
+	writeStream := func(n int, dict string, data []byte) error {
+		compressed, err := pdfDeflate(data)
+		if err != nil {
+			return err
+		}
+		offsets[n-1] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n<< %s /Filter /FlateDecode /Length %d >>\nstream\n", n, dict, len(compressed))
+		out.Write(compressed)
+		out.WriteString("\nendstream\nendobj\n")
+		return nil
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	catalog, pages := reserve(), reserve()
+
+	fontRefs := make([]string, len(d.fonts))
+	fontObjs := make([]int, len(d.fonts))
+	for i, f := range d.fonts {
+		fontObjs[i] = reserve()
+		fontRefs[i] = fmt.Sprintf("/%s %d 0 R", f.resource, fontObjs[i])
+	}
+	resources := "<< /Font << " + strings.Join(fontRefs, " ") + " >> >>"
+
+	pageObjs := make([]int, len(d.pages))
+	for i, page := range d.pages {
+		pageObjs[i] = reserve()
+		contents := reserve()
+		write(pageObjs[i], fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources %s /Contents %d 0 R >>",
+			pages, pdfPageWidth, pdfPageHeight, resources, contents))
+		if err := writeStream(contents, "", page.content.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+
+	// Fonts last: only now is every used glyph known
+	for i, f := range d.fonts {
+		if f.ttf == nil {
+			base := "Helvetica"
+			if f.bold {
+				base = "Helvetica-Bold"
+			}
+			write(fontObjs[i], "<< /Type /Font /Subtype /Type1 /BaseFont /"+base+" /Encoding /WinAnsiEncoding >>")
+			continue
+		}
+		subset, err := f.ttf.subset(f.glyphs())
+		if err != nil {
+			return nil, err
+		}
+		name := f.baseName()
+		cid, descriptor, file, toUnicode := reserve(), reserve(), reserve(), reserve()
+		write(fontObjs[i], fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+			name, cid, toUnicode))
+		write(cid, fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /CIDToGIDMap /Identity /W %s >>",
+			name, descriptor, f.widthArray()))
+		t := f.ttf
+		stemV := 80
+		if f.bold {
+			stemV = 140
+		}
+		write(descriptor, fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV %d /FontFile2 %d 0 R >>",
+			name, t.scale(t.bbox[0]), t.scale(t.bbox[1]), t.scale(t.bbox[2]), t.scale(t.bbox[3]),
+			t.scale(t.ascent), t.scale(t.descent), t.scale(t.capHeight), stemV, file))
+		if err := writeStream(file, fmt.Sprintf("/Length1 %d", len(subset)), subset); err != nil {
+			return nil, err
+		}
+		if err := writeStream(toUnicode, "", f.toUnicodeCMap()); err != nil {
+			return nil, err
+		}
+	}
+
+	kids := make([]string, len(pageObjs))
+	for i, n := range pageObjs {
+		kids[i] = fmt.Sprintf("%d 0 R", n)
+	}
+	write(pages, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pageObjs)))
+	write(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pages))
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, catalog, xref)
+	return out.Bytes(), nil
+}
+
+func pdfDeflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ============================================
+// FONTS
+// ============================================
+
+// pdfFont is a font used in a document
+type pdfFont struct {
+	ttf      *trueTypeFont
+	bold     bool
+	index    int
+	resource string
+	used     map[uint16]rune // glyph -> character, for subsetting and ToUnicode
+}
+
+// Width returns the width of s at size in points (logical order is fine)
+func (f *pdfFont) Width(s string, size float64) float64 {
+	if containsArabic(s) && f.ttf != nil {
+		s = shapeArabic(s)
+	}
+	var units float64
+	for _, r := range s {
+		if f.ttf != nil {
+			units += float64(f.ttf.advance(f.ttf.cmap[r])) * 1000 / float64(f.ttf.unitsPerEm)
+		} else {
+			units += helveticaWidth(r)
+		}
+	}
+	return units * size / 1000
+}
+
+// encode returns s as a PDF string operand and its width at size
+func (f *pdfFont) encode(s string, size float64) (string, float64) {
+	if f.ttf == nil {
+		var b strings.Builder
+		b.WriteByte('(')
+		for _, r := range s {
+			switch {
+			case r == '(' || r == ')' || r == '\\':
+				b.WriteByte('\\')
+				b.WriteRune(r)
+			case r < 32:
+			case r < 256:
+				b.WriteByte(byte(r))
+			default:
+				b.WriteByte('?')
+			}
+		}
+		b.WriteByte(')')
+		return b.String(), f.Width(s, size)
+	}
+
+	var b strings.Builder
+	var units float64
+	b.WriteByte('<')
+	for _, r := range s {
+		gid := f.ttf.cmap[r]
+		if gid != 0 {
+			f.used[gid] = r
+		}
+		fmt.Fprintf(&b, "%04X", gid)
+		units += float64(f.ttf.advance(gid)) * 1000 / float64(f.ttf.unitsPerEm)
+	}
+	b.WriteByte('>')
+	return b.String(), units * size / 1000
+}
+
+func (f *pdfFont) glyphs() []uint16 {
+	glyphs := make([]uint16, 0, len(f.used)+1)
+	glyphs = append(glyphs, 0)
+	for gid := range f.used {
+		glyphs = append(glyphs, gid)
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+	return glyphs
+}
+
+// baseName is the subset font name, e.g. AFTKAB+DejaVuSans
+func (f *pdfFont) baseName() string {
+	name := strings.Map(func(r rune) rune {
+		if r < 128 && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-') {
+			return r
+		}
+		return -1
+	}, f.ttf.name)
+	if name == "" {
+		name = "Font"
+	}
+	// Subset fonts carry a six-letter tag unique within the document
+	return fmt.Sprintf("AFTK%c%c+%s", 'A'+f.index/26%26, 'A'+f.index%26, name)
+}
+
+func (f *pdfFont) widthArray() string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for _, gid := range f.glyphs() {
+		fmt.Fprintf(&b, " %d [%d]", gid, f.ttf.scale(int(f.ttf.advance(gid))))
+	}
+	b.WriteString(" ]")
+	return b.String()
+}
+
+func (f *pdfFont) toUnicodeCMap() []byte {
+	var b bytes.Buffer
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	b.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	b.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	b.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	glyphs := f.glyphs()[1:]
+	for start := 0; start < len(glyphs); start += 100 {
+		end := start + 100
+		if end > len(glyphs) {
+			end = len(glyphs)
+		}
+		fmt.Fprintf(&b, "%d beginbfchar\n", end-start)
+		for _, gid := range glyphs[start:end] {
+			r := f.used[gid]
+			if r > 0xFFFF {
+				r = unicode.ReplacementChar
+			}
+			fmt.Fprintf(&b, "<%04X> <%04X>\n", gid, r)
+		}
+		b.WriteString("endbfchar\n")
+	}
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return b.Bytes()
+}
+
+// helveticaWidths are the Helvetica advance widths of ASCII 32-126 (1/1000 em)
+var helveticaWidths = [...]float64{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+func helveticaWidth(r rune) float64 {
+	if r >= 32 && r <= 126 {
+		return helveticaWidths[r-32]
+	}
+	return 556
+}
+
+// ============================================
+// TRUETYPE
+// ============================================
+
+// trueTypeFont is a parsed TrueType (glyf outline) font
+type trueTypeFont struct {
+	name       string
+	tables     map[string][]byte
+	unitsPerEm int
+	bbox       [4]int
+	ascent     int
+	descent    int
+	capHeight  int
+	numGlyphs  int
+	advances   []uint16
+	cmap       map[rune]uint16
+	loca       []uint32 // numGlyphs+1 glyph offsets into glyf
+}
+
+// parseTrueType parses the tables needed to embed a font. name is used in
+// the PDF font name (usually the file name).
+func parseTrueType(data []byte, name string) (*trueTypeFont, error) {
+	if len(data) < 12 || binary.BigEndian.Uint32(data) != 0x00010000 && string(data[:4]) != "true" {
+		return nil, fmt.Errorf("%w: not a TrueType outline font", ErrPDFFont)
+	}
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	t := &trueTypeFont{name: strings.TrimSuffix(filepath.Base(name), filepath.Ext(name)), tables: map[string][]byte{}}
+	for i := 0; i < numTables; i++ {
+		rec := 12 + 16*i
+		if rec+16 > len(data) {
+			return nil, fmt.Errorf("%w: truncated table directory", ErrPDFFont)
+		}
+		tag := string(data[rec : rec+4])
+		off := int(binary.BigEndian.Uint32(data[rec+8:]))
+		length := int(binary.BigEndian.Uint32(data[rec+12:]))
+		if off < 0 || length < 0 || off+length > len(data) {
+			return nil, fmt.Errorf("%w: table %s out of range", ErrPDFFont, tag)
+		}
+		t.tables[tag] = data[off : off+length]
+	}
+	for _, tag := range []string{"head", "hhea", "hmtx", "maxp", "loca", "glyf", "cmap"} {
+		if t.tables[tag] == nil {
+			return nil, fmt.Errorf("%w: missing %s table", ErrPDFFont, tag)
+		}
+	}
+
+	head, hhea, maxp := t.tables["head"], t.tables["hhea"], t.tables["maxp"]
+	if len(head) < 54 || len(hhea) < 36 || len(maxp) < 6 {
+		return nil, fmt.Errorf("%w: truncated header tables", ErrPDFFont)
+	}
+	t.unitsPerEm = int(binary.BigEndian.Uint16(head[18:]))
+	if t.unitsPerEm == 0 {
+		return nil, fmt.Errorf("%w: zero unitsPerEm", ErrPDFFont)
+	}
+	for i := range t.bbox {
+		t.bbox[i] = int(int16(binary.BigEndian.Uint16(head[36+2*i:])))
+	}
+	t.ascent = int(int16(binary.BigEndian.Uint16(hhea[4:])))
+	t.descent = int(int16(binary.BigEndian.Uint16(hhea[6:])))
+	t.capHeight = t.ascent
+	if os2 := t.tables["OS/2"]; len(os2) >= 90 && binary.BigEndian.Uint16(os2) >= 2 {
+		t.capHeight = int(int16(binary.BigEndian.Uint16(os2[88:])))
+	}
+	t.numGlyphs = int(binary.BigEndian.Uint16(maxp[4:]))
+
+	numHMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	hmtx := t.tables["hmtx"]
+	if numHMetrics == 0 || len(hmtx) < 4*numHMetrics {
+		return nil, fmt.Errorf("%w: bad hmtx", ErrPDFFont)
+	}
+	t.advances = make([]uint16, numHMetrics)
+	for i := range t.advances {
+		t.advances[i] = binary.BigEndian.Uint16(hmtx[4*i:])
+	}
+
+	longLoca := binary.BigEndian.Uint16(head[50:]) == 1
+	loca := t.tables["loca"]
+	t.loca = make([]uint32, t.numGlyphs+1)
+	for i := range t.loca {
+		if longLoca {
+			if 4*i+4 > len(loca) {
+				return nil, fmt.Errorf("%w: truncated loca", ErrPDFFont)
+			}
+			t.loca[i] = binary.BigEndian.Uint32(loca[4*i:])
+		} else {
+			if 2*i+2 > len(loca) {
+				return nil, fmt.Errorf("%w: truncated loca", ErrPDFFont)
+			}
+			t.loca[i] = uint32(binary.BigEndian.Uint16(loca[2*i:])) * 2
+		}
+	}
+
+	cmap, err := parseCmap(t.tables["cmap"])
+	if err != nil {
+		return nil, err
+	}
+	t.cmap = cmap
+	return t, nil
+}
+
+// parseCmap reads the Unicode mapping (format 12 preferred, else format 4)
+func parseCmap(data []byte) (map[rune]uint16, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("%w: truncated cmap", ErrPDFFont)
+	}
+	var format4, format12 []byte
+	n := int(binary.BigEndian.Uint16(data[2:]))
+	for i := 0; i < n && 4+8*i+8 <= len(data); i++ {
+		rec := 4 + 8*i
+		platform := binary.BigEndian.Uint16(data[rec:])
+		encoding := binary.BigEndian.Uint16(data[rec+2:])
+		off := int(binary.BigEndian.Uint32(data[rec+4:]))
+		if off+4 > len(data) || !(platform == 0 || platform == 3 && (encoding == 1 || encoding == 10)) {
+			continue
+		}
+		sub := data[off:]
+		switch binary.BigEndian.Uint16(sub) {
+		case 4:
+			format4 = sub
+		case 12:
+			format12 = sub
+		}
+	}
+
+	cmap := make(map[rune]uint16)
+	switch {
+	case format12 != nil && len(format12) >= 16:
+		groups := int(binary.BigEndian.Uint32(format12[12:]))
+		for g := 0; g < groups && 16+12*g+12 <= len(format12); g++ {
+			rec := format12[16+12*g:]
+			start, end, gid := binary.BigEndian.Uint32(rec), binary.BigEndian.Uint32(rec[4:]), binary.BigEndian.Uint32(rec[8:])
+			for c := start; c <= end && c <= 0x10FFFF; c++ {
+				cmap[rune(c)] = uint16(gid + c - start)
+			}
+		}
+	case format4 != nil && len(format4) >= 14:
+		segX2 := int(binary.BigEndian.Uint16(format4[6:]))
+		ends, starts := 14, 16+segX2
+		deltas, ranges := starts+segX2, starts+2*segX2
+		if ranges+segX2 > len(format4) {
+			return nil, fmt.Errorf("%w: truncated cmap format 4", ErrPDFFont)
+		}
+		for s := 0; s < segX2/2; s++ {
+			end := int(binary.BigEndian.Uint16(format4[ends+2*s:]))
+			start := int(binary.BigEndian.Uint16(format4[starts+2*s:]))
+			delta := int(binary.BigEndian.Uint16(format4[deltas+2*s:]))
+			rangeOff := int(binary.BigEndian.Uint16(format4[ranges+2*s:]))
+			for c := start; c <= end && c != 0xFFFF; c++ {
+				gid := 0
+				if rangeOff == 0 {
+					gid = (c + delta) & 0xFFFF
+				} else {
+					addr := ranges + 2*s + rangeOff + 2*(c-start)
+					if addr+2 > len(format4) {
+						continue
+					}
+					if gid = int(binary.BigEndian.Uint16(format4[addr:])); gid != 0 {
+						gid = (gid + delta) & 0xFFFF
+					}
+				}
+				if gid != 0 {
+					cmap[rune(c)] = uint16(gid)
+				}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%w: no Unicode cmap", ErrPDFFont)
+	}
+	return cmap, nil
+}
+
+// HasGlyph reports whether the font maps r to a glyph
+func (t *trueTypeFont) HasGlyph(r rune) bool {
+	return t.cmap[r] != 0
+}
+
+func (t *trueTypeFont) advance(gid uint16) uint16 {
+	if int(gid) < len(t.advances) {
+		return t.advances[gid]
+	}
+	return t.advances[len(t.advances)-1]
+}
+
+// scale converts font units to the 1/1000 em PDF uses
+func (t *trueTypeFont) scale(v int) int {
+	return v * 1000 / t.unitsPerEm
+}
+
+func (t *trueTypeFont) glyph(gid uint16) []byte {
+	if int(gid)+1 >= len(t.loca) {
+		return nil
+	}
+	start, end := t.loca[gid], t.loca[gid+1]
+	glyf := t.tables["glyf"]
+	if start >= end || int(end) > len(glyf) {
+		return nil
+	}
+	return glyf[start:end]
+}
+
+// subset returns a font file keeping only the outlines of glyphs (and the
+// components of composite glyphs). Glyph IDs are unchanged.
+func (t *trueTypeFont) subset(glyphs []uint16) ([]byte, error) {
+	keep := make(map[uint16]bool)
+	queue := append([]uint16(nil), glyphs...)
+	for len(queue) > 0 {
+		gid := queue[0]
+		queue = queue[1:]
+		if keep[gid] || int(gid) >= t.numGlyphs {
+			continue
+		}
+		keep[gid] = true
+		data := t.glyph(gid)
+		if len(data) < 10 || int16(binary.BigEndian.Uint16(data)) >= 0 {
+			continue
+		}
+		// Composite glyph: follow its components
+		for pos := 10; pos+4 <= len(data); {
+			flags := binary.BigEndian.Uint16(data[pos:])
+			queue = append(queue, binary.BigEndian.Uint16(data[pos+2:]))
+			pos += 4
+			if flags&0x0001 != 0 {
+				pos += 4
+			} else {
+				pos += 2
+			}
+			switch {
+			case flags&0x0008 != 0:
+				pos += 2
+			case flags&0x0040 != 0:
+				pos += 4
+			case flags&0x0080 != 0:
+				pos += 8
+			}
+			if flags&0x0020 == 0 {
+				break
+			}
+		}
+	}
+
+	var glyf bytes.Buffer
+	loca := make([]byte, 4*(t.numGlyphs+1))
+	for gid := 0; gid < t.numGlyphs; gid++ {
+		binary.BigEndian.PutUint32(loca[4*gid:], uint32(glyf.Len()))
+		if keep[uint16(gid)] {
+			glyf.Write(t.glyph(uint16(gid)))
+			for glyf.Len()%4 != 0 {
+				glyf.WriteByte(0)
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(loca[4*t.numGlyphs:], uint32(glyf.Len()))
+
+	head := append([]byte(nil), t.tables["head"]...)
+	binary.BigEndian.PutUint32(head[8:], 0)  // checkSumAdjustment, set below
+	binary.BigEndian.PutUint16(head[50:], 1) // long loca
+
+	tables := map[string][]byte{"head": head, "loca": loca, "glyf": glyf.Bytes()}
+	for _, tag := range []string{"hhea", "hmtx", "maxp", "cvt ", "fpgm", "prep"} {
+		if data := t.tables[tag]; data != nil {
+			tables[tag] = data
+		}
+	}
+	return writeSfnt(tables), nil
+}
+
+// writeSfnt assembles tables into a TrueType file with valid checksums
+func writeSfnt(tables map[string][]byte) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	n := len(tags)
+	searchRange, selector := 1, 0
+	for searchRange*2 <= n {
+		searchRange *= 2
+		selector++
+	}
+	var out bytes.Buffer
+	header := make([]byte, 12+16*n)
+	binary.BigEndian.PutUint32(header, 0x00010000)
+	binary.BigEndian.PutUint16(header[4:], uint16(n))
+	binary.BigEndian.PutUint16(header[6:], uint16(searchRange*16))
+	binary.BigEndian.PutUint16(header[8:], uint16(selector))
+	binary.BigEndian.PutUint16(header[10:], uint16(n*16-searchRange*16))
+
+	offset := len(header)
+	headOffset := 0
+	var body bytes.Buffer
+	for i, tag := range tags {
+		data := tables[tag]
+		rec := header[12+16*i:]
+		copy(rec, tag)
+		binary.BigEndian.PutUint32(rec[4:], sfntChecksum(data))
+		binary.BigEndian.PutUint32(rec[8:], uint32(offset+body.Len()))
+		binary.BigEndian.PutUint32(rec[12:], uint32(len(data)))
+		if tag == "head" {
+			headOffset = offset + body.Len()
+		}
+		body.Write(data)
+		for body.Len()%4 != 0 {
+			body.WriteByte(0)
+		}
+	}
+	out.Write(header)
+	out.Write(body.Bytes())
+
+	font := out.Bytes()
+	binary.BigEndian.PutUint32(font[headOffset+8:], 0xB1B0AFBA-sfntChecksum(font))
+	return font
+}
+
+func sfntChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// ============================================
+// ARABIC SHAPING & BIDI
+// ============================================
+// PDF draws glyphs left to right exactly as given, so Arabic is converted to
+// its contextual presentation forms and reordered visually before drawing.
+
+// arabicForms maps a letter to its first presentation form (isolated) and
+// the number of forms: 4 = joins both sides (isolated, final, initial,
+// medial), 2 = joins the previous letter only (isolated, final)
+var arabicForms = map[rune][2]rune{
+	0x0621: {0xFE80, 1}, 0x0622: {0xFE81, 2}, 0x0623: {0xFE83, 2}, 0x0624: {0xFE85, 2},
+	0x0625: {0xFE87, 2}, 0x0626: {0xFE89, 4}, 0x0627: {0xFE8D, 2}, 0x0628: {0xFE8F, 4},
+	0x0629: {0xFE93, 2}, 0x062A: {0xFE95, 4}, 0x062B: {0xFE99, 4}, 0x062C: {0xFE9D, 4},
+	0x062D: {0xFEA1, 4}, 0x062E: {0xFEA5, 4}, 0x062F: {0xFEA9, 2}, 0x0630: {0xFEAB, 2},
+	0x0631: {0xFEAD, 2}, 0x0632: {0xFEAF, 2}, 0x0633: {0xFEB1, 4}, 0x0634: {0xFEB5, 4},
+	0x0635: {0xFEB9, 4}, 0x0636: {0xFEBD, 4}, 0x0637: {0xFEC1, 4}, 0x0638: {0xFEC5, 4},
+	0x0639: {0xFEC9, 4}, 0x063A: {0xFECD, 4}, 0x0641: {0xFED1, 4}, 0x0642: {0xFED5, 4},
+	0x0643: {0xFED9, 4}, 0x0644: {0xFEDD, 4}, 0x0645: {0xFEE1, 4}, 0x0646: {0xFEE5, 4},
+	0x0647: {0xFEE9, 4}, 0x0648: {0xFEED, 2}, 0x0649: {0xFEEF, 2}, 0x064A: {0xFEF1, 4},
+}
+
+// lamAlef maps the alef following a lam to the isolated ligature (final = +1)
+var lamAlef = map[rune]rune{0x0622: 0xFEF5, 0x0623: 0xFEF7, 0x0625: 0xFEF9, 0x0627: 0xFEFB}
+
+const arabicTatweel = 0x0640
+
+func isArabic(r rune) bool {
+	return r >= 0x0600 && r <= 0x06FF || r >= 0xFB50 && r <= 0xFDFF || r >= 0xFE70 && r <= 0xFEFF
+}
+
+func containsArabic(s string) bool {
+	for _, r := range s {
+		if isArabic(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// joinsNext reports whether r connects to the following letter
+func joinsNext(r rune) bool {
+	return r == arabicTatweel || arabicForms[r][1] == 4
+}
+
+// joinsPrev reports whether r connects to the preceding letter
+func joinsPrev(r rune) bool {
+	return r == arabicTatweel || arabicForms[r][1] >= 2
+}
+
+// shapeArabic replaces Arabic letters with their contextual forms, in
+// logical order. Harakat are dropped.
+func shapeArabic(s string) string {
+	var letters []rune
+	for _, r := range s {
+		if r >= 0x064B && r <= 0x065F || r == 0x0670 {
+			continue
+		}
+		letters = append(letters, r)
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(letters); i++ {
+		r := letters[i]
+		forms, ok := arabicForms[r]
+		if !ok {
+			b.WriteRune(r)
+			continue
+		}
+		prev := i > 0 && joinsNext(letters[i-1])
+		if r == 0x0644 && i+1 < len(letters) {
+			if lig, ok := lamAlef[letters[i+1]]; ok {
+				if prev {
+					lig++
+				}
+				b.WriteRune(lig)
+				i++
+				continue
+			}
+		}
+		next := forms[1] == 4 && i+1 < len(letters) && joinsPrev(letters[i+1])
+		switch {
+		case forms[1] == 1:
+			b.WriteRune(forms[0])
+		case prev && next:
+			b.WriteRune(forms[0] + 3)
+		case prev:
+			b.WriteRune(forms[0] + 1)
+		case next:
+			b.WriteRune(forms[0] + 2)
+		default:
+			b.WriteRune(forms[0])
+		}
+	}
+	return b.String()
+}
+
+// bidiVisual reorders a right-to-left line for left-to-right drawing:
+// Arabic and neutral characters are reversed while runs of Latin letters
+// and digits keep their order
+func bidiVisual(s string) string {
+	runes := []rune(s)
+	isLTR := func(r rune) bool {
+		return !isArabic(r) && (unicode.IsLetter(r) || unicode.IsDigit(r))
+	}
+
+	type run struct {
+		text []rune
+		ltr  bool
+	}
+	var runs []run
+	for i := 0; i < len(runes); {
+		if isLTR(runes[i]) {
+			// An LTR run spans neutrals between LTR characters ("VAT 15%")
+			j, last := i, i
+			for j < len(runes) && !isArabic(runes[j]) {
+				if isLTR(runes[j]) || runes[j] == '%' || runes[j] == '.' && j+1 < len(runes) && unicode.IsDigit(runes[j+1]) {
+					last = j
+				}
+				j++
+			}
+			runs = append(runs, run{runes[i : last+1], true})
+			i = last + 1
+			continue
+		}
+		j := i
+		for j < len(runes) && !isLTR(runes[j]) {
+			j++
+		}
+		runs = append(runs, run{runes[i:j], false})
+		i = j
+	}
+
+	mirror := map[rune]rune{'(': ')', ')': '(', '[': ']', ']': '[', '<': '>', '>': '<', '{': '}', '}': '{'}
+	var b strings.Builder
+	for k := len(runs) - 1; k >= 0; k-- {
+		if runs[k].ltr {
+			b.WriteString(string(runs[k].text))
+			continue
+		}
+		for i := len(runs[k].text) - 1; i >= 0; i-- {
+			r := runs[k].text[i]
+			if m, ok := mirror[r]; ok {
+				r = m
+			}
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,342 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ============================================
+// RECEIPT STORAGE
+// ============================================
+// Payment receipts advertisers upload for their invoices are kept on local
+// disk (development, single instance) or in an S3-compatible bucket (AWS S3,
+// MinIO, Cloudflare R2). Only PDFs and PNG/JPEG images are accepted; the type
+// is sniffed from the content, never taken from the client.
+
+// DefaultMaxReceiptBytes is the upload limit when RECEIPT_MAX_BYTES is unset
+const DefaultMaxReceiptBytes = 5 << 20
+
+// Receipt errors
+var (
+	ErrReceiptEmpty    = errors.New("receipt file is empty")
+	ErrReceiptTooLarge = errors.New("receipt file is too large")
+	ErrReceiptType     = errors.New("receipt must be a PDF, PNG or JPEG file")
+	ErrReceiptNotFound = errors.New("receipt not found")
+)
+
+// receiptTypes maps accepted content types to the stored file extension
+var receiptTypes = map[string]string{
+	"application/pdf": ".pdf",
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+}
+
+// ValidateReceipt checks an upload against the size limit and returns its
+// sniffed content type and file extension
+func ValidateReceipt(data []byte, maxBytes int64) (contentType, ext string, err error) {
+	if len(data) == 0 {
+		return "", "", ErrReceiptEmpty
+	}
+	if maxBytes > 0 && int64(len(data)) > maxBytes {
+		return "", "", fmt.Errorf("%w: limit is %d bytes", ErrReceiptTooLarge, maxBytes)
+	}
+	contentType = http.DetectContentType(data)
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	ext, ok := receiptTypes[contentType]
+	if !ok {
+		return "", "", ErrReceiptType
+	}
+	return contentType, ext, nil
+}
+
+// ReceiptKey is where a receipt of an invoice is stored. Every upload gets a
+// new key so a replaced receipt never overwrites the one under review.
+func ReceiptKey(tenantID, invoiceID uuid.UUID, ext string) string {
+	return fmt.Sprintf("receipts/%s/%s/%s%s", tenantID, invoiceID, uuid.New(), ext)
+}
+
+// ReceiptStorage stores and returns receipt files by key
+type ReceiptStorage interface {
+	Name() string
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// ReceiptStorageConfig selects and configures the receipt storage
+type ReceiptStorageConfig struct {
+	Backend  string // "local" (default) or "s3"
+	Dir      string // local: base directory
+	MaxBytes int64
+	S3       S3StorageConfig
+}
+
+// ReceiptStorageConfigFromEnv reads RECEIPT_STORAGE (local|s3),
+// RECEIPT_STORAGE_DIR, RECEIPT_MAX_BYTES and the RECEIPT_S3_* settings
+func ReceiptStorageConfigFromEnv() ReceiptStorageConfig {
+	cfg := ReceiptStorageConfig{
+		Backend:  strings.ToLower(os.Getenv("RECEIPT_STORAGE")),
+		Dir:      os.Getenv("RECEIPT_STORAGE_DIR"),
+		MaxBytes: DefaultMaxReceiptBytes,
+		S3: S3StorageConfig{
+			Endpoint:  os.Getenv("RECEIPT_S3_ENDPOINT"),
+			Region:    os.Getenv("RECEIPT_S3_REGION"),
+			Bucket:    os.Getenv("RECEIPT_S3_BUCKET"),
+			AccessKey: os.Getenv("RECEIPT_S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("RECEIPT_S3_SECRET_KEY"),
+		},
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = "data/receipts"
+	}
+	if n, err := strconv.ParseInt(os.Getenv("RECEIPT_MAX_BYTES"), 10, 64); err == nil && n > 0 {
+		cfg.MaxBytes = n
+	}
+	return cfg
+}
+
+// NewReceiptStorage creates the configured storage
+func NewReceiptStorage(cfg ReceiptStorageConfig) (ReceiptStorage, error) {
+	switch cfg.Backend {
+	case "", "local":
+		return NewLocalReceiptStorage(cfg.Dir), nil
+	case "s3":
+		storage, err := NewS3ReceiptStorage(cfg.S3)
+		if err != nil {
+			return nil, err
+		}
+		return storage, nil
+	}
+	return nil, fmt.Errorf("unknown receipt storage %q", cfg.Backend)
+}
+
+var (
+	receiptStorage     ReceiptStorage
+	receiptStorageErr  error
+	receiptStorageOnce sync.Once
+)
+
+// GetReceiptStorage returns the storage configured from the environment
+func GetReceiptStorage() (ReceiptStorage, error) {
+	receiptStorageOnce.Do(func() {
+		receiptStorage, receiptStorageErr = NewReceiptStorage(ReceiptStorageConfigFromEnv())
+	})
+	return receiptStorage, receiptStorageErr
+}
+
+// ============================================
+// LOCAL DISK
+// ============================================
+
+// LocalReceiptStorage keeps receipts under a directory
+type LocalReceiptStorage struct {
+	dir string
+}
+
+// NewLocalReceiptStorage creates a disk storage rooted at dir
+func NewLocalReceiptStorage(dir string) *LocalReceiptStorage {
+	return &LocalReceiptStorage{dir: dir}
+}
+
+func (s *LocalReceiptStorage) Name() string {
+	return "local"
+}
+
+// path resolves a key inside the storage directory
+func (s *LocalReceiptStorage) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" {
+		return "", fmt.Errorf("invalid receipt key %q", key)
+	}
+	return filepath.Join(s.dir, clean), nil
+}
+
+func (s *LocalReceiptStorage) Put(ctx context.Context, key, contentType string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("failed to create receipt directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o640); err != nil {
+		return fmt.Errorf("failed to write receipt: %w", err)
+	}
+	return nil
+}
+
+func (s *LocalReceiptStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrReceiptNotFound
+	}
+	return data, err
+}
+
+// ============================================
+// S3-COMPATIBLE
+// ============================================
+
+// S3StorageConfig configures an S3-compatible bucket. Objects are addressed
+// path-style (endpoint/bucket/key), which AWS and MinIO/R2 all accept.
+type S3StorageConfig struct {
+	Endpoint   string // e.g. https://s3.eu-central-1.amazonaws.com, http://minio:9000
+	Region     string
+	Bucket     string
+	AccessKey  string
+	SecretKey  string
+	HTTPClient *http.Client
+	Now        func() time.Time
+}
+
+// S3ReceiptStorage stores receipts in a bucket with SigV4-signed requests
+type S3ReceiptStorage struct {
+	cfg      S3StorageConfig
+	endpoint *url.URL
+}
+
+// NewS3ReceiptStorage creates a bucket storage
+func NewS3ReceiptStorage(cfg S3StorageConfig) (*S3ReceiptStorage, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" || cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("s3 receipt storage needs an endpoint, bucket and credentials")
+	}
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &S3ReceiptStorage{cfg: cfg, endpoint: endpoint}, nil
+}
+
+func (s *S3ReceiptStorage) Name() string {
+	return "s3"
+}
+
+func (s *S3ReceiptStorage) Put(ctx context.Context, key, contentType string, data []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, key, contentType, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("s3 upload failed: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (s *S3ReceiptStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrReceiptNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("s3 download failed: %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (s *S3ReceiptStorage) do(ctx context.Context, method, key, contentType string, body []byte) (*http.Response, error) {
+	target := *s.endpoint
+	target.Path = s.endpoint.Path + "/" + s.cfg.Bucket + "/" + strings.TrimLeft(key, "/")
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s.sign(req, body)
+	return s.cfg.HTTPClient.Do(req)
+}
+
+// sign adds AWS Signature Version 4 headers to a request without a query string
+func (s *S3ReceiptStorage) sign(req *http.Request, body []byte) {
+	now := s.cfg.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// Canonical headers must be lowercase and sorted
+	headers := [][2]string{}
+	if ct := req.Header.Get("Content-Type"); ct != "" {
+		headers = append(headers, [2]string{"content-type", ct})
+	}
+	headers = append(headers,
+		[2]string{"host", req.URL.Host},
+		[2]string{"x-amz-content-sha256", payloadHash},
+		[2]string{"x-amz-date", amzDate})
+	var canonicalHeaders strings.Builder
+	names := make([]string, len(headers))
+	for i, h := range headers {
+		canonicalHeaders.WriteString(h[0] + ":" + strings.TrimSpace(h[1]) + "\n")
+		names[i] = h[0]
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"", // no query string
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + s.cfg.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), day)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
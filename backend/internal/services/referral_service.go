@@ -0,0 +1,414 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// REFERRALS & OVERRIDE COMMISSIONS
+// ============================================
+// Overrides are a share of a promoter's approved commission paid to their
+// referrers (one rate per level) and to the owner of their team. The
+// platform funds them from its fee, so the advertiser's charge and the
+// promoter's commission are unchanged. Rates, depth, the referral window and
+// the per-conversion cap come from the tenant settings.
+
+// Referral errors
+var (
+	ErrReferralInvalid  = errors.New("invalid referral")
+	ErrReferralNotFound = errors.New("referral not found")
+)
+
+// Reasons an override candidate is not paid
+const (
+	OverrideExcludedSelf        = "self"              // the promoter would earn on their own conversion
+	OverrideExcludedReferral    = "referral_excluded" // an admin excluded the referral
+	OverrideExcludedExpired     = "referral_expired"  // the referral window has ended
+	OverrideExcludedInactive    = "inactive"          // the beneficiary is not an active promoter
+	OverrideExcludedKYCRejected = "kyc_rejected"
+	OverrideExcludedFraudCase   = "fraud_case" // the beneficiary or the source promoter has an open fraud case
+)
+
+// OverrideCandidate is a promoter who may earn an override on a conversion,
+// in the order the override budget is spent
+type OverrideCandidate struct {
+	BeneficiaryID uuid.UUID
+	Type          models.OverrideType
+	Level         int // referral level (1 = direct referrer); 0 for team overrides
+	RateBps       int64
+	Excluded      string // why no override is paid; empty when eligible
+}
+
+// OverrideShare is an override to post, in minor units of the conversion's currency
+type OverrideShare struct {
+	BeneficiaryID uuid.UUID           `json:"beneficiary_id"`
+	Type          models.OverrideType `json:"type"`
+	Level         int                 `json:"level,omitempty"`
+	RateBps       int64               `json:"rate_bps"`
+	Amount        int64               `json:"amount"`
+}
+
+// Description labels the override's ledger transaction
+func (s OverrideShare) Description() string {
+	if s.Type == models.OverrideTypeTeam {
+		return "Team override"
+	}
+	return fmt.Sprintf("Referral override (level %d)", s.Level)
+}
+
+// ComputeOverrides splits the override budget of a conversion between the
+// eligible candidates, in order. The budget is capBps of the commission and
+// never more than the platform fee; a candidate gets their rate of the
+// commission or whatever budget is left. Each promoter earns at most one
+// override per conversion and never one on their own conversion.
+func ComputeOverrides(sourceID uuid.UUID, commission, fee, capBps int64, candidates []OverrideCandidate) []OverrideShare {
+	budget := PlatformFee(commission, capBps)
+	if fee < budget {
+		budget = fee
+	}
+	paid := map[uuid.UUID]bool{sourceID: true}
+	var shares []OverrideShare
+	for _, c := range candidates {
+		if budget <= 0 {
+			break
+		}
+		if c.Excluded != "" || paid[c.BeneficiaryID] || c.BeneficiaryID == uuid.Nil {
+			continue
+		}
+		amount := PlatformFee(commission, c.RateBps)
+		if amount > budget {
+			amount = budget
+		}
+		if amount <= 0 {
+			continue
+		}
+		paid[c.BeneficiaryID] = true
+		budget -= amount
+		shares = append(shares, OverrideShare{
+			BeneficiaryID: c.BeneficiaryID,
+			Type:          c.Type,
+			Level:         c.Level,
+			RateBps:       c.RateBps,
+			Amount:        amount,
+		})
+	}
+	return shares
+}
+
+// ReferralWindowOpen reports whether a referral still earns overrides at t
+func ReferralWindowOpen(referredAt time.Time, months int, t time.Time) bool {
+	return t.Before(referredAt.AddDate(0, months, 0))
+}
+
+// OverrideEligibility returns why a beneficiary cannot earn overrides, or ""
+func OverrideEligibility(beneficiary *models.AfftokUser, openFraudCase bool) string {
+	switch {
+	case beneficiary == nil || beneficiary.Status != "active" || beneficiary.Role == "advertiser":
+		return OverrideExcludedInactive
+	case beneficiary.KYCStatus == models.KYCStatusRejected:
+		return OverrideExcludedKYCRejected
+	case openFraudCase:
+		return OverrideExcludedFraudCase
+	}
+	return ""
+}
+
+// ReferralService records referrals and works out override commissions
+type ReferralService struct {
+	db *gorm.DB
+}
+
+var (
+	referralServiceInstance *ReferralService
+	referralServiceOnce     sync.Once
+)
+
+// GetReferralService returns the singleton referral service
+func GetReferralService(db *gorm.DB) *ReferralService {
+	referralServiceOnce.Do(func() {
+		referralServiceInstance = NewReferralService(db)
+	})
+	return referralServiceInstance
+}
+
+// NewReferralService creates a new referral service
+func NewReferralService(db *gorm.DB) *ReferralService {
+	return &ReferralService{db: db}
+}
+
+// Register links a newly registered promoter to the promoter whose unique
+// code they signed up with
+func (s *ReferralService) Register(tx *gorm.DB, referee *models.AfftokUser, code string) (*models.Referral, error) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if code == "" {
+		return nil, fmt.Errorf("%w: referral code is required", ErrReferralInvalid)
+	}
+	if referee.Role == "advertiser" {
+		return nil, fmt.Errorf("%w: only promoters can be referred", ErrReferralInvalid)
+	}
+
+	var referrer models.AfftokUser
+	if err := tx.Where("tenant_id = ? AND unique_code = ? AND role <> ?", referee.TenantID, code, "advertiser").
+		First(&referrer).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: unknown referral code", ErrReferralInvalid)
+		}
+		return nil, err
+	}
+	if referrer.ID == referee.ID {
+		return nil, fmt.Errorf("%w: promoters cannot refer themselves", ErrReferralInvalid)
+	}
+
+	referral := models.Referral{
+		ID:         uuid.New(),
+		ReferrerID: referrer.ID,
+		RefereeID:  referee.ID,
+		Code:       code,
+		Status:     models.ReferralStatusActive,
+	}
+	referral.TenantID = referee.TenantID
+	if err := tx.Create(&referral).Error; err != nil {
+		return nil, fmt.Errorf("failed to record referral: %w", err)
+	}
+	return &referral, nil
+}
+
+// ReferralFilter narrows List
+type ReferralFilter struct {
+	ReferrerID *uuid.UUID
+	RefereeID  *uuid.UUID
+	Status     string
+}
+
+// List returns a tenant's referrals, newest first
+func (s *ReferralService) List(tenantID uuid.UUID, filter ReferralFilter, limit, offset int) ([]models.Referral, int64, error) {
+	query := s.db.Model(&models.Referral{}).Where("tenant_id = ?", tenantID)
+	if filter.ReferrerID != nil {
+		query = query.Where("referrer_id = ?", *filter.ReferrerID)
+	}
+	if filter.RefereeID != nil {
+		query = query.Where("referee_id = ?", *filter.RefereeID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var referrals []models.Referral
+	err := query.
+		Preload("Referrer", func(db *gorm.DB) *gorm.DB { return db.Select("id, username, full_name, unique_code") }).
+		Preload("Referee", func(db *gorm.DB) *gorm.DB { return db.Select("id, username, full_name, status, created_at") }).
+		Order("created_at DESC").Limit(limit).Offset(offset).
+		Find(&referrals).Error
+	return referrals, total, err
+}
+
+// SetExcluded excludes a referral from overrides (e.g. after a fraud
+// review) or reinstates it. Overrides already posted are not touched.
+func (s *ReferralService) SetExcluded(tenantID, referralID uuid.UUID, excluded bool, reason string, by *uuid.UUID) (*models.Referral, error) {
+	var referral models.Referral
+	if err := s.db.Where("tenant_id = ? AND id = ?", tenantID, referralID).First(&referral).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReferralNotFound
+		}
+		return nil, err
+	}
+
+	updates := map[string]interface{}{
+		"status":          models.ReferralStatusActive,
+		"excluded_reason": "",
+		"excluded_by":     nil,
+		"excluded_at":     nil,
+	}
+	if excluded {
+		if strings.TrimSpace(reason) == "" {
+			return nil, fmt.Errorf("%w: a reason is required", ErrReferralInvalid)
+		}
+		updates = map[string]interface{}{
+			"status":          models.ReferralStatusExcluded,
+			"excluded_reason": strings.TrimSpace(reason),
+			"excluded_by":     by,
+			"excluded_at":     time.Now().UTC(),
+		}
+	}
+	if err := s.db.Model(&referral).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return &referral, s.db.First(&referral, "id = ?", referral.ID).Error
+}
+
+// Candidates returns who may earn an override on a conversion of promoterID
+// approved at t: the referral chain up to the configured depth, then the
+// owner of the promoter's team. Excluded candidates carry the reason.
+func (s *ReferralService) Candidates(tx *gorm.DB, tenantID, promoterID uuid.UUID, at time.Time, settings models.TenantSettings) ([]OverrideCandidate, error) {
+	var candidates []OverrideCandidate
+
+	// Referral chain. The window runs from the promoter's own referral, so
+	// every level stops earning on them at the same time.
+	var windowOpen bool
+	seen := map[uuid.UUID]bool{promoterID: true}
+	current := promoterID
+	for level := 1; level <= len(settings.ReferralOverrideBps); level++ {
+		var referral models.Referral
+		err := tx.Where("tenant_id = ? AND referee_id = ?", tenantID, current).First(&referral).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if seen[referral.ReferrerID] {
+			break // a referral cycle
+		}
+		seen[referral.ReferrerID] = true
+		if level == 1 {
+			windowOpen = ReferralWindowOpen(referral.CreatedAt, settings.ReferralOverrideMonths, at)
+		}
+
+		candidate := OverrideCandidate{
+			BeneficiaryID: referral.ReferrerID,
+			Type:          models.OverrideTypeReferral,
+			Level:         level,
+			RateBps:       settings.ReferralOverrideBps[level-1],
+		}
+		switch {
+		case referral.Status == models.ReferralStatusExcluded:
+			candidate.Excluded = OverrideExcludedReferral
+		case !windowOpen:
+			candidate.Excluded = OverrideExcludedExpired
+		}
+		candidates = append(candidates, candidate)
+		current = referral.ReferrerID
+	}
+
+	// Team owner
+	if settings.TeamOverrideBps > 0 {
+		var ownerIDs []uuid.UUID
+		if err := tx.Table("team_members tm").
+			Joins("JOIN teams t ON t.id = tm.team_id").
+			Where("tm.tenant_id = ? AND tm.user_id = ? AND tm.status = ? AND t.status = ? AND tm.joined_at <= ?",
+				tenantID, promoterID, models.TeamMemberStatusActive, "active", at).
+			Order("tm.joined_at").Limit(1).
+			Pluck("t.owner_id", &ownerIDs).Error; err != nil {
+			return nil, err
+		}
+		if len(ownerIDs) > 0 {
+			candidate := OverrideCandidate{BeneficiaryID: ownerIDs[0], Type: models.OverrideTypeTeam, RateBps: settings.TeamOverrideBps}
+			if ownerIDs[0] == promoterID {
+				candidate.Excluded = OverrideExcludedSelf
+			}
+			candidates = append(candidates, candidate)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	// Fraud exclusions
+	ids := []uuid.UUID{promoterID}
+	for _, c := range candidates {
+		ids = append(ids, c.BeneficiaryID)
+	}
+	var users []models.AfftokUser
+	if err := tx.Select("id, role, status, kyc_status").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]*models.AfftokUser, len(users))
+	for i := range users {
+		byID[users[i].ID] = &users[i]
+	}
+	var flagged []uuid.UUID
+	if err := tx.Model(&models.FraudCase{}).
+		Where("tenant_id = ? AND user_id IN ? AND status IN ?", tenantID, ids,
+			[]string{models.FraudCaseStatusOpen, models.FraudCaseStatusInvestigating}).
+		Distinct().Pluck("user_id", &flagged).Error; err != nil {
+		return nil, err
+	}
+	open := make(map[uuid.UUID]bool, len(flagged))
+	for _, id := range flagged {
+		open[id] = true
+	}
+
+	for i := range candidates {
+		c := &candidates[i]
+		if c.Excluded != "" {
+			continue
+		}
+		if open[promoterID] {
+			c.Excluded = OverrideExcludedFraudCase
+			continue
+		}
+		c.Excluded = OverrideEligibility(byID[c.BeneficiaryID], open[c.BeneficiaryID])
+	}
+	return candidates, nil
+}
+
+// Overrides returns the overrides to post for a conversion of promoterID
+// with the given commission and platform fee
+func (s *ReferralService) Overrides(tx *gorm.DB, tenantID, promoterID uuid.UUID, commission, fee int64, at time.Time) ([]OverrideShare, error) {
+	if commission <= 0 || fee <= 0 {
+		return nil, nil
+	}
+	settings := GetTenantSettingsResolver(s.db).Get(tenantID)
+	if len(settings.ReferralOverrideBps) == 0 && settings.TeamOverrideBps <= 0 {
+		return nil, nil
+	}
+	candidates, err := s.Candidates(tx, tenantID, promoterID, at, *settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load override candidates: %w", err)
+	}
+	return ComputeOverrides(promoterID, commission, fee, settings.OverrideCapBps, candidates), nil
+}
+
+// OverrideEarning is one override posting as the beneficiary sees it
+type OverrideEarning struct {
+	TransactionID    uuid.UUID                    `json:"transaction_id"`
+	Kind             models.LedgerTransactionKind `json:"kind"`
+	ConversionID     uuid.UUID                    `json:"conversion_id"`
+	SourcePromoterID *uuid.UUID                   `json:"source_promoter_id,omitempty"`
+	SourceUsername   string                       `json:"source_username,omitempty"`
+	Description      string                       `json:"description"`
+	Currency         string                       `json:"currency"`
+	Amount           int64                        `json:"amount"` // minor units, negative for reversals
+	AmountMajor      float64                      `json:"amount_major"`
+	OccurredAt       time.Time                    `json:"occurred_at"`
+}
+
+// ListOverrideEarnings returns a promoter's override postings, newest first
+func (s *ReferralService) ListOverrideEarnings(tenantID, promoterID uuid.UUID, limit, offset int) ([]OverrideEarning, int64, error) {
+	query := s.db.Table("ledger_transactions t").
+		Where("t.tenant_id = ? AND t.promoter_id = ? AND t.kind IN ?", tenantID, promoterID,
+			[]models.LedgerTransactionKind{models.LedgerKindOverrideEarned, models.LedgerKindOverrideReversed})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	earnings := []OverrideEarning{}
+	if err := query.
+		Select(`t.id AS transaction_id, t.kind, t.reference_id AS conversion_id, uo.user_id AS source_promoter_id,
+			u.username AS source_username, t.description, t.currency, -e.amount AS amount, t.occurred_at`).
+		Joins("JOIN ledger_entries e ON e.transaction_id = t.id").
+		Joins("JOIN ledger_accounts a ON a.id = e.account_id AND a.type = ?", models.LedgerAccountPromoterPayable).
+		Joins("LEFT JOIN conversions c ON c.id = t.reference_id").
+		Joins("LEFT JOIN user_offers uo ON uo.id = c.user_offer_id").
+		Joins("LEFT JOIN afftok_users u ON u.id = uo.user_id").
+		Order("t.occurred_at DESC, t.created_at DESC").Limit(limit).Offset(offset).
+		Scan(&earnings).Error; err != nil {
+		return nil, 0, err
+	}
+	for i := range earnings {
+		earnings[i].AmountMajor = MinorToMajor(earnings[i].Amount, earnings[i].Currency)
+	}
+	return earnings, total, nil
+}
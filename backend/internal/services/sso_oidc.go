@@ -0,0 +1,331 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ============================================
+// OIDC CLIENT
+// ============================================
+// Authorization code flow with PKCE (S256), state and nonce. ID tokens are
+// verified against the issuer's JWKS (RS256 / ES256).
+
+const (
+	oidcDiscoveryTTL = time.Hour
+	oidcHTTPTimeout  = 10 * time.Second
+	oidcMaxBody      = 1 << 20
+)
+
+// ErrOIDCTokenInvalid is returned when an ID token fails verification
+var ErrOIDCTokenInvalid = errors.New("invalid OIDC ID token")
+
+// OIDCClient talks to one tenant's OpenID provider
+type OIDCClient struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	HTTPClient   *http.Client
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcProvider struct {
+	discovery oidcDiscovery
+	keys      map[string]interface{} // kid -> public key
+	fetchedAt time.Time
+}
+
+// Discovery documents and keys are shared by all logins of an issuer
+var (
+	oidcProviders   = map[string]*oidcProvider{}
+	oidcProvidersMu sync.Mutex
+)
+
+// NewOIDCClient builds the client for a tenant configuration
+func NewOIDCClient(cfg *models.TenantSSOConfig, redirectURL string, httpClient *http.Client) (*OIDCClient, error) {
+	if cfg.Issuer == "" || cfg.ClientID == "" {
+		return nil, errors.New("issuer and client_id are required")
+	}
+	scopes := strings.Fields(cfg.Scopes)
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	hasOpenID := false
+	for _, scope := range scopes {
+		if scope == "openid" {
+			hasOpenID = true
+		}
+	}
+	if !hasOpenID {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: oidcHTTPTimeout}
+	}
+	return &OIDCClient{
+		Issuer:       strings.TrimRight(cfg.Issuer, "/"),
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       scopes,
+		HTTPClient:   httpClient,
+	}, nil
+}
+
+// NewPKCEVerifier returns a random PKCE code verifier
+func NewPKCEVerifier() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// PKCEChallenge returns the S256 challenge of a verifier
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (c *OIDCClient) getJSON(ctx context.Context, target string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", target, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, oidcMaxBody)).Decode(out)
+}
+
+// provider returns the cached discovery document and keys, refreshing
+// them when stale or when refresh is set (unknown kid after key rotation)
+func (c *OIDCClient) provider(ctx context.Context, refresh bool) (*oidcProvider, error) {
+	oidcProvidersMu.Lock()
+	cached := oidcProviders[c.Issuer]
+	oidcProvidersMu.Unlock()
+	if cached != nil && !refresh && time.Since(cached.fetchedAt) < oidcDiscoveryTTL {
+		return cached, nil
+	}
+
+	var discovery oidcDiscovery
+	if err := c.getJSON(ctx, c.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if strings.TrimRight(discovery.Issuer, "/") != c.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer mismatch %q", discovery.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: incomplete document")
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := c.getJSON(ctx, discovery.JWKSURI, &jwks); err != nil {
+		return nil, fmt.Errorf("oidc jwks: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(jwks.Keys))
+	decode := func(s string) *big.Int {
+		b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+		if err != nil || len(b) == 0 {
+			return nil
+		}
+		return new(big.Int).SetBytes(b)
+	}
+	for _, key := range jwks.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		switch key.Kty {
+		case "RSA":
+			n, e := decode(key.N), decode(key.E)
+			if n != nil && e != nil && e.IsInt64() {
+				keys[key.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+			}
+		case "EC":
+			x, y := decode(key.X), decode(key.Y)
+			if key.Crv == "P-256" && x != nil && y != nil && elliptic.P256().IsOnCurve(x, y) {
+				keys[key.Kid] = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+			}
+		}
+	}
+
+	provider := &oidcProvider{discovery: discovery, keys: keys, fetchedAt: time.Now()}
+	oidcProvidersMu.Lock()
+	oidcProviders[c.Issuer] = provider
+	oidcProvidersMu.Unlock()
+	return provider, nil
+}
+
+// AuthCodeURL returns the IdP authorization URL
+func (c *OIDCClient) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	provider, err := c.provider(ctx, false)
+	if err != nil {
+		return "", err
+	}
+	target, err := url.Parse(provider.discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+	query := target.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", c.ClientID)
+	query.Set("redirect_uri", c.RedirectURL)
+	query.Set("scope", strings.Join(c.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", PKCEChallenge(codeVerifier))
+	query.Set("code_challenge_method", "S256")
+	target.RawQuery = query.Encode()
+	return target.String(), nil
+}
+
+// Exchange trades an authorization code for the ID token
+func (c *OIDCClient) Exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	provider, err := c.provider(ctx, false)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {c.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("oidc token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, oidcMaxBody)).Decode(&body); err != nil {
+		return "", fmt.Errorf("oidc token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("oidc token request failed: %s %s", body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("oidc token response has no id_token")
+	}
+	return body.IDToken, nil
+}
+
+// VerifyIDToken checks signature, issuer, audience, expiry and nonce
+func (c *OIDCClient) VerifyIDToken(ctx context.Context, raw, nonce string) (jwt.MapClaims, error) {
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		provider, err := c.provider(ctx, false)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := provider.keys[kid]
+		if !ok {
+			if provider, err = c.provider(ctx, true); err != nil {
+				return nil, err
+			}
+			if key, ok = provider.keys[kid]; !ok {
+				return nil, fmt.Errorf("unknown signing key %q", kid)
+			}
+		}
+		return key, nil
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, keyFunc,
+		jwt.WithValidMethods([]string{"RS256", "ES256"}),
+		jwt.WithIssuer(c.Issuer),
+		jwt.WithAudience(c.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCTokenInvalid, err)
+	}
+
+	if got, _ := claims["nonce"].(string); got == "" || got != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrOIDCTokenInvalid)
+	}
+	if verified, ok := claims["email_verified"].(bool); ok && !verified {
+		return nil, fmt.Errorf("%w: email not verified", ErrOIDCTokenInvalid)
+	}
+	return claims, nil
+}
+
+// OIDCIdentity reads the identity out of verified ID token claims
+func OIDCIdentity(claims jwt.MapClaims, groupsClaim string) models.SSOIdentity {
+	identity := models.SSOIdentity{}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+	switch groups := claims[groupsClaim].(type) {
+	case []interface{}:
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				identity.Groups = append(identity.Groups, s)
+			}
+		}
+	case string:
+		identity.Groups = strings.Fields(groups)
+	}
+	return identity
+}
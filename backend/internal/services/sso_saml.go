@@ -0,0 +1,337 @@
+package services
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/xmldsig"
+)
+
+// ============================================
+// SAML 2.0 SERVICE PROVIDER
+// ============================================
+// SP-initiated Web Browser SSO: AuthnRequest over HTTP-Redirect, Response
+// over HTTP-POST. Responses must be signed (Response or Assertion);
+// encrypted assertions are not supported.
+
+// SAML namespaces and values
+const (
+	samlProtocolNamespace  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	samlAssertionNamespace = "urn:oasis:names:tc:SAML:2.0:assertion"
+	samlMetadataNamespace  = "urn:oasis:names:tc:SAML:2.0:metadata"
+	samlStatusSuccess      = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	samlBearer             = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	samlBindingPOST        = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	samlNameIDEmail        = "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"
+	samlClockSkew          = 2 * time.Minute
+)
+
+// Common attribute names used by IdPs (Okta, Azure AD, ADFS, Google)
+var (
+	samlEmailAttributes = []string{"email", "mail", "emailAddress",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress"}
+	samlNameAttributes = []string{"name", "displayName",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name"}
+)
+
+// ErrSAMLResponseInvalid is returned for any rejected SAML response
+var ErrSAMLResponseInvalid = errors.New("invalid SAML response")
+
+// SAMLServiceProvider is our side of a tenant's SAML trust
+type SAMLServiceProvider struct {
+	EntityID        string // our entity ID, the expected Audience
+	ACSURL          string
+	IdPEntityID     string
+	IdPSSOURL       string
+	IdPCertificate  *x509.Certificate
+	EmailAttribute  string
+	NameAttribute   string
+	GroupsAttribute string
+	Now             func() time.Time
+}
+
+// SAMLAssertion is the verified content of a SAML response
+type SAMLAssertion struct {
+	ID           string
+	Identity     models.SSOIdentity
+	NotOnOrAfter time.Time
+}
+
+// NewSAMLServiceProvider builds the SP for a tenant configuration
+func NewSAMLServiceProvider(cfg *models.TenantSSOConfig, entityID, acsURL string) (*SAMLServiceProvider, error) {
+	if cfg.IdPSSOURL == "" {
+		return nil, errors.New("idp_sso_url is required")
+	}
+	cert, err := ParseIdPCertificate(cfg.IdPCertificate)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.SPEntityID != "" {
+		entityID = cfg.SPEntityID
+	}
+	return &SAMLServiceProvider{
+		EntityID:        entityID,
+		ACSURL:          acsURL,
+		IdPEntityID:     cfg.IdPEntityID,
+		IdPSSOURL:       cfg.IdPSSOURL,
+		IdPCertificate:  cert,
+		EmailAttribute:  cfg.EmailAttribute,
+		NameAttribute:   cfg.NameAttribute,
+		GroupsAttribute: cfg.GroupsClaim,
+		Now:             time.Now,
+	}, nil
+}
+
+// ParseIdPCertificate accepts a PEM certificate or its bare base64 body,
+// which is what most IdP admin consoles let you copy
+func ParseIdPCertificate(value string) (*x509.Certificate, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, errors.New("idp_certificate is required")
+	}
+
+	var der []byte
+	if block, _ := pem.Decode([]byte(value)); block != nil {
+		der = block.Bytes
+	} else {
+		decoded, err := base64.StdEncoding.DecodeString(xmldsig.StripSpace(value))
+		if err != nil {
+			return nil, errors.New("idp_certificate is not PEM or base64")
+		}
+		der = decoded
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid idp_certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// newSAMLID returns an xs:ID (must not start with a digit)
+func newSAMLID() string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	return "_" + hex.EncodeToString(b)
+}
+
+// AuthnRequestURL returns the IdP redirect URL and the request ID, which
+// the response must reference in InResponseTo
+func (sp *SAMLServiceProvider) AuthnRequestURL(relayState string) (string, string, error) {
+	id := newSAMLID()
+	request := fmt.Sprintf(`<samlp:AuthnRequest xmlns:samlp="%s" xmlns:saml="%s" ID="%s" Version="2.0" IssueInstant="%s" Destination="%s" AssertionConsumerServiceURL="%s" ProtocolBinding="%s"><saml:Issuer>%s</saml:Issuer><samlp:NameIDPolicy Format="%s" AllowCreate="true"/></samlp:AuthnRequest>`,
+		samlProtocolNamespace, samlAssertionNamespace, id,
+		sp.Now().UTC().Format(time.RFC3339),
+		xmldsig.EscapeAttr(sp.IdPSSOURL), xmldsig.EscapeAttr(sp.ACSURL), samlBindingPOST,
+		xmldsig.EscapeText(sp.EntityID), samlNameIDEmail)
+
+	var buf bytes.Buffer
+	writer, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return "", "", err
+	}
+	writer.Write([]byte(request))
+	writer.Close()
+
+	target, err := url.Parse(sp.IdPSSOURL)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid idp_sso_url: %w", err)
+	}
+	query := target.Query()
+	query.Set("SAMLRequest", base64.StdEncoding.EncodeToString(buf.Bytes()))
+	if relayState != "" {
+		query.Set("RelayState", relayState)
+	}
+	target.RawQuery = query.Encode()
+	return target.String(), id, nil
+}
+
+// Metadata returns the SP metadata document for the IdP admin
+func (sp *SAMLServiceProvider) Metadata() []byte {
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<md:EntityDescriptor xmlns:md="%s" entityID="%s"><md:SPSSODescriptor AuthnRequestsSigned="false" WantAssertionsSigned="true" protocolSupportEnumeration="%s"><md:NameIDFormat>%s</md:NameIDFormat><md:AssertionConsumerService Binding="%s" Location="%s" index="0" isDefault="true"/></md:SPSSODescriptor></md:EntityDescriptor>`,
+		samlMetadataNamespace, xmldsig.EscapeAttr(sp.EntityID), samlProtocolNamespace,
+		samlNameIDEmail, samlBindingPOST, xmldsig.EscapeAttr(sp.ACSURL)))
+}
+
+func samlInvalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrSAMLResponseInvalid, fmt.Sprintf(format, args...))
+}
+
+// ParseResponse verifies a base64 SAMLResponse posted to the ACS and
+// returns the asserted identity. requestID is the AuthnRequest it answers.
+func (sp *SAMLServiceProvider) ParseResponse(encoded, requestID string) (*SAMLAssertion, error) {
+	raw, err := base64.StdEncoding.DecodeString(xmldsig.StripSpace(encoded))
+	if err != nil {
+		return nil, samlInvalid("bad encoding")
+	}
+	response, err := xmldsig.Parse(raw)
+	if err != nil {
+		return nil, samlInvalid("%v", err)
+	}
+	if !response.Is(samlProtocolNamespace, "Response") {
+		return nil, samlInvalid("not a Response")
+	}
+
+	if dest := response.Attr("Destination"); dest != "" && dest != sp.ACSURL {
+		return nil, samlInvalid("wrong destination")
+	}
+	if response.Attr("InResponseTo") != requestID {
+		return nil, samlInvalid("unsolicited or mismatched response")
+	}
+
+	status := response.Child(samlProtocolNamespace, "Status")
+	if status == nil {
+		return nil, samlInvalid("no status")
+	}
+	if code := status.Child(samlProtocolNamespace, "StatusCode"); code == nil || code.Attr("Value") != samlStatusSuccess {
+		return nil, samlInvalid("IdP returned an error status")
+	}
+
+	if response.Child(samlAssertionNamespace, "EncryptedAssertion") != nil {
+		return nil, samlInvalid("encrypted assertions are not supported")
+	}
+	assertions := response.ChildrenNamed(samlAssertionNamespace, "Assertion")
+	if len(assertions) != 1 {
+		return nil, samlInvalid("expected exactly one assertion")
+	}
+	assertion := assertions[0]
+
+	// Either the whole response or the assertion must carry a valid
+	// signature. All data below is read from the verified subtree only.
+	err = xmldsig.VerifyEnveloped(response, sp.IdPCertificate)
+	if errors.Is(err, xmldsig.ErrSignatureMissing) {
+		err = xmldsig.VerifyEnveloped(assertion, sp.IdPCertificate)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSAMLResponseInvalid, err)
+	}
+
+	return sp.readAssertion(assertion, requestID)
+}
+
+func (sp *SAMLServiceProvider) readAssertion(assertion *xmldsig.Node, requestID string) (*SAMLAssertion, error) {
+	now := sp.Now()
+
+	issuer := assertion.Child(samlAssertionNamespace, "Issuer")
+	if issuer == nil || (sp.IdPEntityID != "" && strings.TrimSpace(issuer.Text()) != sp.IdPEntityID) {
+		return nil, samlInvalid("wrong issuer")
+	}
+
+	subject := assertion.Child(samlAssertionNamespace, "Subject")
+	if subject == nil {
+		return nil, samlInvalid("no subject")
+	}
+	nameID := subject.Child(samlAssertionNamespace, "NameID")
+	if nameID == nil || strings.TrimSpace(nameID.Text()) == "" {
+		return nil, samlInvalid("no NameID")
+	}
+
+	var notOnOrAfter time.Time
+	confirmed := false
+	for _, confirmation := range subject.ChildrenNamed(samlAssertionNamespace, "SubjectConfirmation") {
+		if confirmation.Attr("Method") != samlBearer {
+			continue
+		}
+		data := confirmation.Child(samlAssertionNamespace, "SubjectConfirmationData")
+		if data == nil || data.Attr("Recipient") != sp.ACSURL {
+			continue
+		}
+		if irt := data.Attr("InResponseTo"); irt != "" && irt != requestID {
+			continue
+		}
+		expires, err := time.Parse(time.RFC3339, data.Attr("NotOnOrAfter"))
+		if err != nil || !now.Before(expires.Add(samlClockSkew)) {
+			continue
+		}
+		notOnOrAfter = expires
+		confirmed = true
+		break
+	}
+	if !confirmed {
+		return nil, samlInvalid("no valid bearer subject confirmation")
+	}
+
+	conditions := assertion.Child(samlAssertionNamespace, "Conditions")
+	if conditions == nil {
+		return nil, samlInvalid("no conditions")
+	}
+	if v := conditions.Attr("NotBefore"); v != "" {
+		notBefore, err := time.Parse(time.RFC3339, v)
+		if err != nil || now.Add(samlClockSkew).Before(notBefore) {
+			return nil, samlInvalid("assertion not yet valid")
+		}
+	}
+	if v := conditions.Attr("NotOnOrAfter"); v != "" {
+		expires, err := time.Parse(time.RFC3339, v)
+		if err != nil || !now.Before(expires.Add(samlClockSkew)) {
+			return nil, samlInvalid("assertion expired")
+		}
+	}
+	audienceOK := false
+	for _, restriction := range conditions.ChildrenNamed(samlAssertionNamespace, "AudienceRestriction") {
+		for _, audience := range restriction.ChildrenNamed(samlAssertionNamespace, "Audience") {
+			if strings.TrimSpace(audience.Text()) == sp.EntityID {
+				audienceOK = true
+			}
+		}
+	}
+	if !audienceOK {
+		return nil, samlInvalid("wrong audience")
+	}
+
+	attributes := map[string][]string{}
+	for _, statement := range assertion.ChildrenNamed(samlAssertionNamespace, "AttributeStatement") {
+		for _, attribute := range statement.ChildrenNamed(samlAssertionNamespace, "Attribute") {
+			name := attribute.Attr("Name")
+			for _, value := range attribute.ChildrenNamed(samlAssertionNamespace, "AttributeValue") {
+				attributes[name] = append(attributes[name], strings.TrimSpace(value.Text()))
+			}
+		}
+	}
+	first := func(names ...string) string {
+		for _, name := range names {
+			if values := attributes[name]; len(values) > 0 && values[0] != "" {
+				return values[0]
+			}
+		}
+		return ""
+	}
+
+	identity := models.SSOIdentity{Subject: strings.TrimSpace(nameID.Text())}
+	if sp.EmailAttribute != "" {
+		identity.Email = first(sp.EmailAttribute)
+	} else {
+		identity.Email = first(samlEmailAttributes...)
+		if identity.Email == "" && strings.Contains(identity.Subject, "@") {
+			identity.Email = identity.Subject
+		}
+	}
+	if sp.NameAttribute != "" {
+		identity.Name = first(sp.NameAttribute)
+	} else {
+		identity.Name = first(samlNameAttributes...)
+	}
+	groupsAttribute := sp.GroupsAttribute
+	if groupsAttribute == "" {
+		groupsAttribute = "groups"
+	}
+	identity.Groups = attributes[groupsAttribute]
+
+	return &SAMLAssertion{
+		ID:           assertion.Attr("ID"),
+		Identity:     identity,
+		NotOnOrAfter: notOnOrAfter,
+	}, nil
+}
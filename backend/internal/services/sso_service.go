@@ -0,0 +1,932 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/cache"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/pkg/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// TENANT SSO SERVICE
+// ============================================
+// Sign-in through the tenant's IdP:
+//   1. /api/auth/sso/:tenant/login redirects to the IdP (state kept here)
+//   2. the IdP calls back (OIDC callback / SAML ACS), the identity is
+//      verified and the user provisioned just in time
+//   3. the browser is sent to the console with a one-time sso_code, which
+//      the console exchanges for our usual JWT pair
+
+const (
+	ssoStateTTL     = 10 * time.Minute
+	ssoCodeTTL      = 60 * time.Second
+	ssoStateKey     = "sso:state:"
+	ssoCodeKey      = "sso:code:"
+	ssoAssertionKey = "sso:assertion:"
+)
+
+// SSO roles in ascending privilege; a user in several mapped groups gets
+// the highest one
+// "admin" makes a tenant admin; on the platform tenant it would make a super
+// admin, so SSO may not grant it there.
+var ssoRoleRank = map[string]int{"promoter": 1, "advertiser": 2, "admin": 3}
+
+// Default roles that must use SSO once it is enforced
+var defaultSSOEnforcedRoles = []string{"admin", "advertiser"}
+
+// Errors returned by SSO
+var (
+	ErrSSONotConfigured     = errors.New("sso is not configured for this tenant")
+	ErrSSONotAvailable      = errors.New("sso is not available on the tenant's plan")
+	ErrSSOTenantInactive    = errors.New("tenant is not active")
+	ErrSSOInvalidConfig     = errors.New("invalid sso configuration")
+	ErrSSOInvalidState      = errors.New("sso login expired or invalid, please try again")
+	ErrSSOAssertionReplayed = errors.New("sso assertion already used")
+	ErrSSONoEmail           = errors.New("identity provider did not return an email")
+	ErrSSOEmailDomain       = errors.New("email domain is not allowed for this tenant")
+	ErrSSOEmailTaken        = errors.New("email is registered with another tenant")
+	ErrSSONotProvisioned    = errors.New("no account exists for this user and provisioning is disabled")
+	ErrSSOUserSuspended     = errors.New("account is suspended")
+	ErrSSOInvalidCode       = errors.New("invalid or expired sso code")
+)
+
+// SSOService handles tenant SSO configuration and login flows
+type SSOService struct {
+	db         *gorm.DB
+	tenants    *TenantService
+	usage      *UsageService
+	store      *ssoStore
+	httpClient *http.Client
+	baseURL    string
+}
+
+var (
+	ssoService     *SSOService
+	ssoServiceOnce sync.Once
+)
+
+// GetSSOService returns the global SSO service
+func GetSSOService(db *gorm.DB) *SSOService {
+	ssoServiceOnce.Do(func() {
+		ssoService = NewSSOService(db)
+	})
+	return ssoService
+}
+
+// NewSSOService creates a new SSO service
+func NewSSOService(db *gorm.DB) *SSOService {
+	baseURL := os.Getenv("SSO_CALLBACK_BASE_URL")
+	if baseURL == "" {
+		baseURL = os.Getenv("BASE_URL")
+	}
+	if baseURL == "" {
+		baseURL = "https://go.afftokapp.com"
+	}
+	return &SSOService{
+		db:         db,
+		tenants:    GetTenantService(db),
+		usage:      GetUsageService(db),
+		store:      &ssoStore{items: make(map[string]ssoStoreItem)},
+		httpClient: &http.Client{Timeout: oidcHTTPTimeout},
+		baseURL:    strings.TrimRight(baseURL, "/"),
+	}
+}
+
+// SetBaseURL sets the public API base URL used in callback URLs
+func (s *SSOService) SetBaseURL(baseURL string) {
+	s.baseURL = strings.TrimRight(baseURL, "/")
+}
+
+// SetHTTPClient sets the client used to reach OIDC providers
+func (s *SSOService) SetHTTPClient(client *http.Client) {
+	s.httpClient = client
+}
+
+// ============================================
+// URLS
+// ============================================
+
+// SSOEndpoints are the URLs a tenant admin registers at their IdP
+type SSOEndpoints struct {
+	LoginURL         string `json:"login_url"`
+	OIDCRedirectURI  string `json:"oidc_redirect_uri"`
+	SAMLEntityID     string `json:"saml_entity_id"`
+	SAMLACSURL       string `json:"saml_acs_url"`
+	SAMLMetadataURL  string `json:"saml_metadata_url"`
+	DefaultPostLogin string `json:"default_post_login_url"`
+}
+
+// Endpoints returns the SP URLs of a tenant
+func (s *SSOService) Endpoints(tenant *models.Tenant, cfg *models.TenantSSOConfig) SSOEndpoints {
+	prefix := s.baseURL + "/api/auth/sso/" + url.PathEscape(tenant.Slug)
+	endpoints := SSOEndpoints{
+		LoginURL:         prefix + "/login",
+		OIDCRedirectURI:  prefix + "/oidc/callback",
+		SAMLEntityID:     prefix + "/saml/metadata",
+		SAMLACSURL:       prefix + "/saml/acs",
+		SAMLMetadataURL:  prefix + "/saml/metadata",
+		DefaultPostLogin: defaultSSOPostLoginURL(),
+	}
+	if cfg != nil && cfg.SPEntityID != "" {
+		endpoints.SAMLEntityID = cfg.SPEntityID
+	}
+	return endpoints
+}
+
+func defaultSSOPostLoginURL() string {
+	appURL := os.Getenv("ADMIN_APP_URL")
+	if appURL == "" {
+		appURL = "https://admin.afftokapp.com"
+	}
+	return strings.TrimRight(appURL, "/") + "/sso/callback"
+}
+
+// ============================================
+// CONFIGURATION
+// ============================================
+
+// SSOConfigRequest is the tenant-editable SSO configuration
+type SSOConfigRequest struct {
+	Protocol            models.SSOProtocol `json:"protocol"`
+	Enabled             bool               `json:"enabled"`
+	Enforced            bool               `json:"enforced"`
+	EnforcedRoles       []string           `json:"enforced_roles"`
+	AllowedEmailDomains []string           `json:"allowed_email_domains"`
+	JITProvisioning     *bool              `json:"jit_provisioning"`
+	DefaultRole         string             `json:"default_role"`
+	RoleMappings        map[string]string  `json:"role_mappings"`
+	GroupsClaim         string             `json:"groups_claim"`
+	PostLoginURL        string             `json:"post_login_url"`
+
+	Issuer       string `json:"issuer"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"` // empty keeps the stored secret
+	Scopes       string `json:"scopes"`
+
+	IdPEntityID    string `json:"idp_entity_id"`
+	IdPSSOURL      string `json:"idp_sso_url"`
+	IdPCertificate string `json:"idp_certificate"`
+	SPEntityID     string `json:"sp_entity_id"`
+	EmailAttribute string `json:"email_attribute"`
+	NameAttribute  string `json:"name_attribute"`
+}
+
+func invalidSSOConfig(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrSSOInvalidConfig, fmt.Sprintf(format, args...))
+}
+
+// validSSOURL accepts https URLs, and http for local development IdPs
+func validSSOURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if u.Scheme == "https" {
+		return true
+	}
+	host := u.Hostname()
+	return u.Scheme == "http" && (host == "localhost" || host == "127.0.0.1" || host == "::1")
+}
+
+// tenantHasSSO reports whether the tenant's features include SSO
+func tenantHasSSO(tenant *models.Tenant) bool {
+	return tenantFeatures(tenant).SSO
+}
+
+// GetConfig returns a tenant's SSO configuration
+func (s *SSOService) GetConfig(tenantID uuid.UUID) (*models.TenantSSOConfig, error) {
+	var cfg models.TenantSSOConfig
+	if err := s.db.Where("tenant_id = ?", tenantID).First(&cfg).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSSONotConfigured
+		}
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// TenantConfig returns a tenant with its SSO configuration (nil if none)
+func (s *SSOService) TenantConfig(tenantID uuid.UUID) (*models.Tenant, *models.TenantSSOConfig, error) {
+	tenant, err := s.tenants.GetTenant(tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg, err := s.GetConfig(tenantID)
+	if err != nil && !errors.Is(err, ErrSSONotConfigured) {
+		return nil, nil, err
+	}
+	return tenant, cfg, nil
+}
+
+// SaveConfig validates and stores a tenant's SSO configuration
+func (s *SSOService) SaveConfig(tenantID uuid.UUID, req *SSOConfigRequest, actorID *uuid.UUID) (*models.TenantSSOConfig, error) {
+	tenant, err := s.tenants.GetTenant(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Enabled && !tenantHasSSO(tenant) {
+		return nil, ErrSSONotAvailable
+	}
+
+	existing, err := s.GetConfig(tenantID)
+	if err != nil && !errors.Is(err, ErrSSONotConfigured) {
+		return nil, err
+	}
+
+	cfg := &models.TenantSSOConfig{
+		TenantID:        tenantID,
+		Protocol:        req.Protocol,
+		Enabled:         req.Enabled,
+		Enforced:        req.Enforced,
+		JITProvisioning: req.JITProvisioning == nil || *req.JITProvisioning,
+		DefaultRole:     strings.ToLower(strings.TrimSpace(req.DefaultRole)),
+		GroupsClaim:     strings.TrimSpace(req.GroupsClaim),
+		PostLoginURL:    strings.TrimSpace(req.PostLoginURL),
+		Issuer:          strings.TrimRight(strings.TrimSpace(req.Issuer), "/"),
+		ClientID:        strings.TrimSpace(req.ClientID),
+		ClientSecret:    req.ClientSecret,
+		Scopes:          strings.TrimSpace(req.Scopes),
+		IdPEntityID:     strings.TrimSpace(req.IdPEntityID),
+		IdPSSOURL:       strings.TrimSpace(req.IdPSSOURL),
+		IdPCertificate:  strings.TrimSpace(req.IdPCertificate),
+		SPEntityID:      strings.TrimSpace(req.SPEntityID),
+		EmailAttribute:  strings.TrimSpace(req.EmailAttribute),
+		NameAttribute:   strings.TrimSpace(req.NameAttribute),
+	}
+	if existing != nil {
+		cfg.CreatedAt = existing.CreatedAt
+		if cfg.ClientSecret == "" {
+			cfg.ClientSecret = existing.ClientSecret
+		}
+	}
+	if cfg.DefaultRole == "" {
+		cfg.DefaultRole = "advertiser"
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+
+	switch cfg.Protocol {
+	case models.SSOProtocolOIDC:
+		if !validSSOURL(cfg.Issuer) {
+			return nil, invalidSSOConfig("issuer must be an https URL")
+		}
+		if cfg.ClientID == "" {
+			return nil, invalidSSOConfig("client_id is required")
+		}
+	case models.SSOProtocolSAML:
+		if !validSSOURL(cfg.IdPSSOURL) {
+			return nil, invalidSSOConfig("idp_sso_url must be an https URL")
+		}
+		if _, err := ParseIdPCertificate(cfg.IdPCertificate); err != nil {
+			return nil, invalidSSOConfig("%v", err)
+		}
+	default:
+		return nil, invalidSSOConfig("protocol must be oidc or saml")
+	}
+
+	if cfg.PostLoginURL != "" && !validSSOURL(cfg.PostLoginURL) {
+		return nil, invalidSSOConfig("post_login_url must be an https URL")
+	}
+	if cfg.Enforced && !cfg.Enabled {
+		return nil, invalidSSOConfig("sso must be enabled to be enforced")
+	}
+	if _, ok := ssoRoleRank[cfg.DefaultRole]; !ok {
+		return nil, invalidSSOConfig("invalid default_role %q", cfg.DefaultRole)
+	}
+	platformTenant := tenantID == models.DefaultTenantID
+	if platformTenant && cfg.DefaultRole == "admin" {
+		return nil, invalidSSOConfig("sso cannot grant admin on the platform tenant")
+	}
+
+	mappings := make(map[string]string, len(req.RoleMappings))
+	for group, role := range req.RoleMappings {
+		role = strings.ToLower(strings.TrimSpace(role))
+		if _, ok := ssoRoleRank[role]; !ok {
+			return nil, invalidSSOConfig("invalid role %q for group %q", role, group)
+		}
+		if platformTenant && role == "admin" {
+			return nil, invalidSSOConfig("sso cannot grant admin on the platform tenant")
+		}
+		mappings[strings.TrimSpace(group)] = role
+	}
+	cfg.RoleMappings, _ = json.Marshal(mappings)
+
+	enforcedRoles := make([]string, 0, len(defaultSSOEnforcedRoles))
+	for _, role := range req.EnforcedRoles {
+		role = strings.ToLower(strings.TrimSpace(role))
+		if _, ok := ssoRoleRank[role]; !ok && role != "user" {
+			return nil, invalidSSOConfig("invalid enforced role %q", role)
+		}
+		enforcedRoles = append(enforcedRoles, role)
+	}
+	if len(enforcedRoles) == 0 {
+		enforcedRoles = append(enforcedRoles, defaultSSOEnforcedRoles...)
+	}
+	cfg.EnforcedRoles, _ = json.Marshal(enforcedRoles)
+
+	domains := make([]string, 0, len(req.AllowedEmailDomains))
+	for _, domain := range req.AllowedEmailDomains {
+		if domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@")); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	cfg.AllowedEmailDomains, _ = json.Marshal(domains)
+
+	if err := s.db.Save(cfg).Error; err != nil {
+		return nil, err
+	}
+
+	s.tenants.logAudit(tenantID, models.TenantAuditSSOConfigured, actorID, existing, cfg)
+	return cfg, nil
+}
+
+// DeleteConfig removes a tenant's SSO configuration, re-enabling passwords
+func (s *SSOService) DeleteConfig(tenantID uuid.UUID, actorID *uuid.UUID) error {
+	existing, err := s.GetConfig(tenantID)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Delete(&models.TenantSSOConfig{}, "tenant_id = ?", tenantID).Error; err != nil {
+		return err
+	}
+	s.tenants.logAudit(tenantID, models.TenantAuditSSOConfigured, actorID, existing, nil)
+	return nil
+}
+
+// SSOPublicInfo tells a login page whether to offer (or require) SSO
+type SSOPublicInfo struct {
+	Tenant   string             `json:"tenant"`
+	Name     string             `json:"name"`
+	Enabled  bool               `json:"enabled"`
+	Enforced bool               `json:"enforced"`
+	Protocol models.SSOProtocol `json:"protocol,omitempty"`
+	LoginURL string             `json:"login_url,omitempty"`
+}
+
+// PublicInfo returns the SSO status of a tenant by slug
+func (s *SSOService) PublicInfo(slug string) (*SSOPublicInfo, error) {
+	tenant, cfg, err := s.activeConfig(slug)
+	if err != nil && !errors.Is(err, ErrSSONotConfigured) {
+		return nil, err
+	}
+	info := &SSOPublicInfo{Tenant: tenant.Slug, Name: tenant.Name}
+	if cfg != nil {
+		info.Enabled = true
+		info.Enforced = cfg.Enforced
+		info.Protocol = cfg.Protocol
+		info.LoginURL = s.Endpoints(tenant, cfg).LoginURL
+	}
+	return info, nil
+}
+
+// activeConfig loads the tenant by slug and its enabled SSO configuration.
+// The tenant is returned even when SSO is off.
+func (s *SSOService) activeConfig(slug string) (*models.Tenant, *models.TenantSSOConfig, error) {
+	tenant, err := s.tenants.GetTenantBySlug(slug)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tenant.Status != models.TenantStatusActive {
+		return nil, nil, ErrSSOTenantInactive
+	}
+	cfg, err := s.GetConfig(tenant.ID)
+	if err != nil {
+		return tenant, nil, err
+	}
+	if !cfg.Enabled || !tenantHasSSO(tenant) {
+		return tenant, nil, ErrSSONotConfigured
+	}
+	return tenant, cfg, nil
+}
+
+// SAMLMetadata returns the SP metadata of a tenant using SAML
+func (s *SSOService) SAMLMetadata(slug string) ([]byte, error) {
+	tenant, err := s.tenants.GetTenantBySlug(slug)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := s.GetConfig(tenant.ID)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Protocol != models.SSOProtocolSAML {
+		return nil, ErrSSONotConfigured
+	}
+	endpoints := s.Endpoints(tenant, cfg)
+	sp := &SAMLServiceProvider{EntityID: endpoints.SAMLEntityID, ACSURL: endpoints.SAMLACSURL}
+	return sp.Metadata(), nil
+}
+
+// ============================================
+// ENFORCEMENT
+// ============================================
+
+// SSOEnforcedFor reports whether password login is disabled for a role
+func SSOEnforcedFor(cfg *models.TenantSSOConfig, role string) bool {
+	if cfg == nil || !cfg.Enabled || !cfg.Enforced {
+		return false
+	}
+	roles := defaultSSOEnforcedRoles
+	if len(cfg.EnforcedRoles) > 0 {
+		var configured []string
+		if err := json.Unmarshal(cfg.EnforcedRoles, &configured); err == nil && len(configured) > 0 {
+			roles = configured
+		}
+	}
+	for _, r := range roles {
+		if strings.EqualFold(r, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// PasswordLoginBlocked reports whether the user must sign in through SSO
+// and returns the SSO login URL. The tenant owner keeps password access
+// as a break-glass account in case the IdP is misconfigured.
+func (s *SSOService) PasswordLoginBlocked(user *models.AfftokUser) (bool, string) {
+	cfg, err := s.GetConfig(user.TenantID)
+	if err != nil || !SSOEnforcedFor(cfg, user.Role) {
+		return false, ""
+	}
+	tenant, err := s.tenants.GetTenant(user.TenantID)
+	if err != nil || !tenantHasSSO(tenant) {
+		return false, ""
+	}
+	if tenant.AdminUserID != nil && *tenant.AdminUserID == user.ID {
+		return false, ""
+	}
+	return true, s.Endpoints(tenant, cfg).LoginURL
+}
+
+// ============================================
+// LOGIN FLOW
+// ============================================
+
+// ssoLoginState is kept between the redirect to the IdP and the callback
+type ssoLoginState struct {
+	TenantID     uuid.UUID          `json:"tenant_id"`
+	Protocol     models.SSOProtocol `json:"protocol"`
+	Nonce        string             `json:"nonce,omitempty"`
+	CodeVerifier string             `json:"code_verifier,omitempty"`
+	RequestID    string             `json:"request_id,omitempty"`
+}
+
+type ssoCode struct {
+	UserID   uuid.UUID `json:"user_id"`
+	TenantID uuid.UUID `json:"tenant_id"`
+}
+
+// SSOLoginResult is the outcome of a completed IdP callback
+type SSOLoginResult struct {
+	User        *models.AfftokUser
+	Provisioned bool
+	RedirectURL string // console URL carrying the one-time sso_code
+}
+
+func randomSSOToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (s *SSOService) oidcClient(tenant *models.Tenant, cfg *models.TenantSSOConfig) (*OIDCClient, error) {
+	return NewOIDCClient(cfg, s.Endpoints(tenant, cfg).OIDCRedirectURI, s.httpClient)
+}
+
+func (s *SSOService) samlProvider(tenant *models.Tenant, cfg *models.TenantSSOConfig) (*SAMLServiceProvider, error) {
+	endpoints := s.Endpoints(tenant, cfg)
+	return NewSAMLServiceProvider(cfg, endpoints.SAMLEntityID, endpoints.SAMLACSURL)
+}
+
+// BeginLogin returns the IdP URL to redirect the browser to
+func (s *SSOService) BeginLogin(ctx context.Context, slug string) (string, error) {
+	tenant, cfg, err := s.activeConfig(slug)
+	if err != nil {
+		return "", err
+	}
+
+	state := randomSSOToken()
+	loginState := ssoLoginState{TenantID: tenant.ID, Protocol: cfg.Protocol}
+	var redirect string
+
+	switch cfg.Protocol {
+	case models.SSOProtocolOIDC:
+		client, err := s.oidcClient(tenant, cfg)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrSSOInvalidConfig, err)
+		}
+		loginState.Nonce = randomSSOToken()
+		loginState.CodeVerifier = NewPKCEVerifier()
+		if redirect, err = client.AuthCodeURL(ctx, state, loginState.Nonce, loginState.CodeVerifier); err != nil {
+			return "", err
+		}
+	case models.SSOProtocolSAML:
+		sp, err := s.samlProvider(tenant, cfg)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrSSOInvalidConfig, err)
+		}
+		if redirect, loginState.RequestID, err = sp.AuthnRequestURL(state); err != nil {
+			return "", err
+		}
+	default:
+		return "", ErrSSOInvalidConfig
+	}
+
+	if err := s.store.put(ctx, ssoStateKey+state, loginState, ssoStateTTL); err != nil {
+		return "", err
+	}
+	return redirect, nil
+}
+
+// takeState consumes the login state of a callback
+func (s *SSOService) takeState(ctx context.Context, slug, state string, protocol models.SSOProtocol) (*models.Tenant, *models.TenantSSOConfig, *ssoLoginState, error) {
+	if state == "" {
+		return nil, nil, nil, ErrSSOInvalidState
+	}
+	var loginState ssoLoginState
+	if !s.store.take(ctx, ssoStateKey+state, &loginState) {
+		return nil, nil, nil, ErrSSOInvalidState
+	}
+
+	tenant, cfg, err := s.activeConfig(slug)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if loginState.TenantID != tenant.ID || loginState.Protocol != protocol || cfg.Protocol != protocol {
+		return nil, nil, nil, ErrSSOInvalidState
+	}
+	return tenant, cfg, &loginState, nil
+}
+
+// CompleteOIDC handles the OIDC redirect back from the IdP
+func (s *SSOService) CompleteOIDC(ctx context.Context, slug, state, code string) (*SSOLoginResult, error) {
+	tenant, cfg, loginState, err := s.takeState(ctx, slug, state, models.SSOProtocolOIDC)
+	if err != nil {
+		return nil, err
+	}
+	client, err := s.oidcClient(tenant, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSSOInvalidConfig, err)
+	}
+
+	rawIDToken, err := client.Exchange(ctx, code, loginState.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := client.VerifyIDToken(ctx, rawIDToken, loginState.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.finish(ctx, tenant, cfg, OIDCIdentity(claims, cfg.GroupsClaim))
+}
+
+// CompleteSAML handles a SAML response posted to the ACS
+func (s *SSOService) CompleteSAML(ctx context.Context, slug, samlResponse, relayState string) (*SSOLoginResult, error) {
+	tenant, cfg, loginState, err := s.takeState(ctx, slug, relayState, models.SSOProtocolSAML)
+	if err != nil {
+		return nil, err
+	}
+	sp, err := s.samlProvider(tenant, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSSOInvalidConfig, err)
+	}
+
+	assertion, err := sp.ParseResponse(samlResponse, loginState.RequestID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each assertion can be used once while it is valid
+	ttl := time.Until(assertion.NotOnOrAfter) + samlClockSkew
+	if !s.store.putOnce(ctx, ssoAssertionKey+tenant.ID.String()+":"+assertion.ID, ttl) {
+		return nil, ErrSSOAssertionReplayed
+	}
+
+	return s.finish(ctx, tenant, cfg, assertion.Identity)
+}
+
+// finish provisions the user and issues the one-time console code
+func (s *SSOService) finish(ctx context.Context, tenant *models.Tenant, cfg *models.TenantSSOConfig, identity models.SSOIdentity) (*SSOLoginResult, error) {
+	user, provisioned, err := s.provision(tenant, cfg, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	code := randomSSOToken()
+	if err := s.store.put(ctx, ssoCodeKey+code, ssoCode{UserID: user.ID, TenantID: tenant.ID}, ssoCodeTTL); err != nil {
+		return nil, err
+	}
+
+	target := cfg.PostLoginURL
+	if target == "" {
+		target = defaultSSOPostLoginURL()
+	}
+	redirect, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("%w: post_login_url", ErrSSOInvalidConfig)
+	}
+	query := redirect.Query()
+	query.Set("sso_code", code)
+	query.Set("tenant", tenant.Slug)
+	redirect.RawQuery = query.Encode()
+
+	return &SSOLoginResult{User: user, Provisioned: provisioned, RedirectURL: redirect.String()}, nil
+}
+
+// FailureRedirectURL sends the browser back to the console with the reason
+// a callback failed
+func (s *SSOService) FailureRedirectURL(slug string, failure error) string {
+	target := defaultSSOPostLoginURL()
+	if tenant, err := s.tenants.GetTenantBySlug(slug); err == nil {
+		if cfg, err := s.GetConfig(tenant.ID); err == nil && cfg.PostLoginURL != "" {
+			target = cfg.PostLoginURL
+		}
+	}
+	redirect, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	query := redirect.Query()
+	query.Set("sso_error", failure.Error())
+	query.Set("tenant", slug)
+	redirect.RawQuery = query.Encode()
+	return redirect.String()
+}
+
+// Exchange trades a one-time sso_code for the signed-in user
+func (s *SSOService) Exchange(ctx context.Context, code string) (*models.AfftokUser, error) {
+	var entry ssoCode
+	if code == "" || !s.store.take(ctx, ssoCodeKey+code, &entry) {
+		return nil, ErrSSOInvalidCode
+	}
+
+	var user models.AfftokUser
+	if err := s.db.Where("id = ? AND tenant_id = ?", entry.UserID, entry.TenantID).First(&user).Error; err != nil {
+		return nil, ErrSSOInvalidCode
+	}
+	if user.Status == "suspended" {
+		return nil, ErrSSOUserSuspended
+	}
+	return &user, nil
+}
+
+// ============================================
+// PROVISIONING
+// ============================================
+
+// MapSSORole returns the role for a user's IdP groups: the highest role of
+// any mapped group, or defaultRole when no group is mapped. matched tells
+// whether a mapping applied.
+func MapSSORole(mappings map[string]string, groups []string, defaultRole string) (role string, matched bool) {
+	role = defaultRole
+	best := 0
+	for _, group := range groups {
+		for mappedGroup, mappedRole := range mappings {
+			if !strings.EqualFold(mappedGroup, group) {
+				continue
+			}
+			if rank := ssoRoleRank[mappedRole]; rank > best {
+				best = rank
+				role = mappedRole
+				matched = true
+			}
+		}
+	}
+	return role, matched
+}
+
+// ssoEmailAllowed checks the email against the tenant's allowed domains
+func ssoEmailAllowed(cfg *models.TenantSSOConfig, email string) bool {
+	var domains []string
+	if len(cfg.AllowedEmailDomains) > 0 {
+		json.Unmarshal(cfg.AllowedEmailDomains, &domains)
+	}
+	if len(domains) == 0 {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := email[at+1:]
+	for _, allowed := range domains {
+		if domain == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// ssoUsername derives a free username from an email
+func (s *SSOService) ssoUsername(email string) string {
+	base := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		}
+		return -1
+	}, strings.ToLower(strings.SplitN(email, "@", 2)[0]))
+	if base == "" {
+		base = "user"
+	}
+	if len(base) > 40 {
+		base = base[:40]
+	}
+
+	username := base
+	for i := 0; i < 5; i++ {
+		var count int64
+		s.db.Model(&models.AfftokUser{}).Where("username = ?", username).Count(&count)
+		if count == 0 {
+			return username
+		}
+		username = base + "_" + uuid.New().String()[:6]
+	}
+	return username
+}
+
+// provision finds or creates (JIT) the user for an IdP identity and keeps
+// the role in sync with the mapped IdP groups
+func (s *SSOService) provision(tenant *models.Tenant, cfg *models.TenantSSOConfig, identity models.SSOIdentity) (*models.AfftokUser, bool, error) {
+	email := strings.ToLower(strings.TrimSpace(identity.Email))
+	if email == "" || !strings.Contains(email, "@") {
+		return nil, false, ErrSSONoEmail
+	}
+	if !ssoEmailAllowed(cfg, email) {
+		return nil, false, ErrSSOEmailDomain
+	}
+
+	var mappings map[string]string
+	if len(cfg.RoleMappings) > 0 {
+		json.Unmarshal(cfg.RoleMappings, &mappings)
+	}
+	role, matched := MapSSORole(mappings, identity.Groups, cfg.DefaultRole)
+
+	// Emails are unique across tenants
+	var user models.AfftokUser
+	err := s.db.Where("LOWER(email) = ?", email).First(&user).Error
+	if err == nil {
+		if user.TenantID != tenant.ID {
+			return nil, false, ErrSSOEmailTaken
+		}
+		if user.Status == "suspended" {
+			return nil, false, ErrSSOUserSuspended
+		}
+
+		updates := map[string]interface{}{}
+		if matched && user.Role != role {
+			updates["role"] = role
+		}
+		if user.FullName == "" && identity.Name != "" {
+			updates["full_name"] = identity.Name
+		}
+		if len(updates) > 0 {
+			if err := s.db.Model(&user).Updates(updates).Error; err != nil {
+				return nil, false, err
+			}
+		}
+		return &user, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	if !cfg.JITProvisioning {
+		return nil, false, ErrSSONotProvisioned
+	}
+	if err := s.usage.Enforce(tenant.ID, models.LimitResourceUsers); err != nil {
+		return nil, false, err
+	}
+
+	// SSO users never use this password; it only fills the column
+	passwordHash, err := utils.HashPassword(randomSSOToken())
+	if err != nil {
+		return nil, false, err
+	}
+
+	user = models.AfftokUser{
+		TenantModel:  models.TenantModel{TenantID: tenant.ID},
+		ID:           uuid.New(),
+		Username:     s.ssoUsername(email),
+		Email:        email,
+		PasswordHash: passwordHash,
+		FullName:     identity.Name,
+		Role:         role,
+		Status:       "active",
+		Points:       0,
+		Level:        1,
+	}
+	if err := s.db.Create(&user).Error; err != nil {
+		return nil, false, err
+	}
+
+	s.tenants.logAudit(tenant.ID, models.TenantAuditSSOUserProvisioned, nil, nil, map[string]interface{}{
+		"user_id":  user.ID,
+		"email":    user.Email,
+		"role":     user.Role,
+		"subject":  identity.Subject,
+		"protocol": cfg.Protocol,
+	})
+	return &user, true, nil
+}
+
+// ============================================
+// STATE STORE
+// ============================================
+
+// ssoStore keeps short-lived login state in Redis, or in memory when Redis
+// is not connected (single instance / development)
+type ssoStore struct {
+	mu    sync.Mutex
+	items map[string]ssoStoreItem
+}
+
+type ssoStoreItem struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func (st *ssoStore) sweep(now time.Time) {
+	for key, item := range st.items {
+		if now.After(item.expiresAt) {
+			delete(st.items, key)
+		}
+	}
+}
+
+func (st *ssoStore) put(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if cache.RedisClient != nil {
+		return cache.Set(ctx, key, data, ttl)
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	now := time.Now()
+	st.sweep(now)
+	st.items[key] = ssoStoreItem{value: data, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+// take returns and deletes a value so it can be used only once
+func (st *ssoStore) take(ctx context.Context, key string, out interface{}) bool {
+	var data []byte
+	if cache.RedisClient != nil {
+		value, err := cache.GetDel(ctx, key)
+		if err != nil {
+			return false
+		}
+		data = []byte(value)
+	} else {
+		st.mu.Lock()
+		item, ok := st.items[key]
+		delete(st.items, key)
+		st.mu.Unlock()
+		if !ok || time.Now().After(item.expiresAt) {
+			return false
+		}
+		data = item.value
+	}
+	return json.Unmarshal(data, out) == nil
+}
+
+// putOnce records a key and reports false if it was already present
+func (st *ssoStore) putOnce(ctx context.Context, key string, ttl time.Duration) bool {
+	if ttl <= 0 {
+		ttl = samlClockSkew
+	}
+	if cache.RedisClient != nil {
+		ok, err := cache.SetNX(ctx, key, "1", ttl)
+		return err == nil && ok
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	now := time.Now()
+	st.sweep(now)
+	if _, exists := st.items[key]; exists {
+		return false
+	}
+	st.items[key] = ssoStoreItem{expiresAt: now.Add(ttl)}
+	return true
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ============================================
+// PROMOTER TAX PROFILES
+// ============================================
+
+// Tax profile errors
+var (
+	ErrTaxProfileNotFound = errors.New("tax profile not found")
+	ErrTaxProfileInvalid  = errors.New("invalid tax profile")
+)
+
+// taxIDFormat is a country's tax ID format. Entities limits it to some
+// entity types; empty applies to all.
+type taxIDFormat struct {
+	Name     string
+	Pattern  *regexp.Regexp
+	Entities []string
+}
+
+// taxIDFormats are the tax ID formats checked per country, after spaces,
+// dashes and dots are removed. Other countries accept 4-30 letters and digits.
+var taxIDFormats = map[string][]taxIDFormat{
+	"US": {
+		{Name: "SSN/ITIN", Pattern: regexp.MustCompile(`^\d{9}$`), Entities: []string{models.TaxEntityIndividual, models.TaxEntitySoleProprietor}},
+		{Name: "EIN", Pattern: regexp.MustCompile(`^\d{9}$`)},
+	},
+	"SA": {
+		{Name: "TIN", Pattern: regexp.MustCompile(`^3\d{9}$`)},
+		{Name: "National ID/Iqama", Pattern: regexp.MustCompile(`^[12]\d{9}$`), Entities: []string{models.TaxEntityIndividual, models.TaxEntitySoleProprietor}},
+		{Name: "VAT number", Pattern: regexp.MustCompile(`^3\d{13}3$`)},
+	},
+	"AE": {{Name: "TRN", Pattern: regexp.MustCompile(`^100\d{12}$`)}},
+	"EG": {{Name: "Tax registration number", Pattern: regexp.MustCompile(`^\d{9}$`)}},
+	"GB": {
+		{Name: "UTR", Pattern: regexp.MustCompile(`^\d{10}$`)},
+		{Name: "National Insurance number", Pattern: regexp.MustCompile(`^[A-CEGHJ-PR-TW-Z]{2}\d{6}[A-D]$`), Entities: []string{models.TaxEntityIndividual, models.TaxEntitySoleProprietor}},
+		{Name: "Company number", Pattern: regexp.MustCompile(`^[A-Z0-9]{2}\d{6}$`), Entities: []string{models.TaxEntityCompany, models.TaxEntityPartnership, models.TaxEntityNonProfit}},
+	},
+	"IN": {{Name: "PAN", Pattern: regexp.MustCompile(`^[A-Z]{5}\d{4}[A-Z]$`)}},
+}
+
+var (
+	genericTaxID   = regexp.MustCompile(`^[A-Z0-9]{4,30}$`)
+	genericVATID   = regexp.MustCompile(`^[A-Z0-9]{4,20}$`)
+	countryCodeRe  = regexp.MustCompile(`^[A-Z]{2}$`)
+	taxIDSeparator = strings.NewReplacer(" ", "", "-", "", ".", "", "/", "")
+)
+
+// validCountryCode reports whether code looks like an ISO 3166-1 alpha-2 code
+func validCountryCode(code string) bool {
+	return countryCodeRe.MatchString(code)
+}
+
+// NormalizeTaxID upper-cases a tax ID and strips separators
+func NormalizeTaxID(id string) string {
+	return strings.ToUpper(taxIDSeparator.Replace(strings.TrimSpace(id)))
+}
+
+// ValidateTaxProfile normalizes a profile and checks it: an ISO country, a
+// known entity type, a legal name and a tax ID in the country's format
+func ValidateTaxProfile(p *models.PromoterTaxProfile) error {
+	p.Country = NormalizeTaxCountry(p.Country)
+	p.EntityType = strings.ToLower(strings.TrimSpace(p.EntityType))
+	p.LegalName = strings.TrimSpace(p.LegalName)
+	p.TaxID = NormalizeTaxID(p.TaxID)
+	p.VATNumber = NormalizeTaxID(p.VATNumber)
+	p.Address = strings.TrimSpace(p.Address)
+
+	if !validCountryCode(p.Country) {
+		return fmt.Errorf("%w: country must be a two-letter ISO code", ErrTaxProfileInvalid)
+	}
+	if !containsString(models.TaxEntityTypes, p.EntityType) {
+		return fmt.Errorf("%w: entity_type must be one of %s", ErrTaxProfileInvalid, strings.Join(models.TaxEntityTypes, ", "))
+	}
+	if p.LegalName == "" || len([]rune(p.LegalName)) > 200 {
+		return fmt.Errorf("%w: legal_name is required (at most 200 characters)", ErrTaxProfileInvalid)
+	}
+	if len([]rune(p.Address)) > 500 {
+		return fmt.Errorf("%w: address is too long", ErrTaxProfileInvalid)
+	}
+	if p.VATNumber != "" && !genericVATID.MatchString(strings.TrimPrefix(p.VATNumber, p.Country)) {
+		return fmt.Errorf("%w: vat_number is not valid", ErrTaxProfileInvalid)
+	}
+
+	formats, known := taxIDFormats[p.Country]
+	if !known {
+		if !genericTaxID.MatchString(p.TaxID) {
+			return fmt.Errorf("%w: tax_id must be 4-30 letters and digits", ErrTaxProfileInvalid)
+		}
+		return nil
+	}
+	var names []string
+	for _, f := range formats {
+		if len(f.Entities) > 0 && !containsString(f.Entities, p.EntityType) {
+			continue
+		}
+		if f.Pattern.MatchString(p.TaxID) {
+			return nil
+		}
+		names = append(names, f.Name)
+	}
+	return fmt.Errorf("%w: tax_id is not a valid %s for %s", ErrTaxProfileInvalid, strings.Join(names, " or "), p.Country)
+}
+
+// TaxProfileRequired reports whether promoters of country need a tax profile
+// under a tenant's tax_profile_countries setting
+func TaxProfileRequired(countries []string, country string) bool {
+	country = NormalizeTaxCountry(country)
+	for _, c := range countries {
+		c = NormalizeTaxCountry(c)
+		if c == "*" || (c != "" && c == country) {
+			return true
+		}
+	}
+	return false
+}
+
+// TaxProfileService stores promoter tax profiles
+type TaxProfileService struct {
+	db *gorm.DB
+}
+
+var (
+	taxProfileService     *TaxProfileService
+	taxProfileServiceOnce sync.Once
+)
+
+// GetTaxProfileService returns the singleton tax profile service
+func GetTaxProfileService(db *gorm.DB) *TaxProfileService {
+	taxProfileServiceOnce.Do(func() {
+		taxProfileService = NewTaxProfileService(db)
+	})
+	return taxProfileService
+}
+
+// NewTaxProfileService creates a new tax profile service
+func NewTaxProfileService(db *gorm.DB) *TaxProfileService {
+	return &TaxProfileService{db: db}
+}
+
+// Get returns a promoter's tax profile
+func (s *TaxProfileService) Get(tenantID, userID uuid.UUID) (*models.PromoterTaxProfile, error) {
+	var profile models.PromoterTaxProfile
+	err := s.db.Where("tenant_id = ? AND user_id = ?", tenantID, userID).First(&profile).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTaxProfileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// Save validates and stores a promoter's tax profile, then releases payouts
+// that were held for the missing profile
+func (s *TaxProfileService) Save(tenantID, userID uuid.UUID, profile *models.PromoterTaxProfile) error {
+	if err := ValidateTaxProfile(profile); err != nil {
+		return err
+	}
+	now := time.Now()
+	profile.TenantID = tenantID
+	profile.UserID = userID
+	profile.CertifiedAt = &now
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var existing models.PromoterTaxProfile
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ? AND user_id = ?", tenantID, userID).First(&existing).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := tx.Create(profile).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			profile.ID = existing.ID
+			profile.CreatedAt = existing.CreatedAt
+			if err := tx.Save(profile).Error; err != nil {
+				return err
+			}
+		}
+		_, err = NewPayoutService(tx).ReleaseHolds(userID, PayoutHoldReasonTaxProfile, &userID)
+		return err
+	})
+}
+
+// Missing returns the promoters among userIDs whose country requires a tax
+// profile they have not saved, going by the country on their account
+func (s *TaxProfileService) Missing(tenantID uuid.UUID, countries []string, userIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+	missing := make(map[uuid.UUID]bool)
+	if len(countries) == 0 || len(userIDs) == 0 {
+		return missing, nil
+	}
+
+	var profiled []uuid.UUID
+	if err := s.db.Model(&models.PromoterTaxProfile{}).
+		Where("tenant_id = ? AND user_id IN ?", tenantID, userIDs).
+		Pluck("user_id", &profiled).Error; err != nil {
+		return nil, err
+	}
+	has := make(map[uuid.UUID]bool, len(profiled))
+	for _, id := range profiled {
+		has[id] = true
+	}
+
+	var users []models.AfftokUser
+	if err := s.db.Select("id, country").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		if !has[u.ID] && TaxProfileRequired(countries, u.Country) {
+			missing[u.ID] = true
+		}
+	}
+	return missing, nil
+}
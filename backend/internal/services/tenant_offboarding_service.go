@@ -0,0 +1,982 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/cache"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// TENANT OFFBOARDING SERVICE
+// ============================================
+
+// Offboarding configuration
+const (
+	DefaultTenantDeletionCoolingOff = 30 * 24 * time.Hour
+	MinTenantDeletionCoolingOff     = 24 * time.Hour
+	TenantExportRetention           = 7 * 24 * time.Hour
+	offboardingPollInterval         = time.Minute
+	offboardingDeleteBatch          = 5000
+)
+
+// Errors returned by offboarding
+var (
+	ErrOffboardingDefaultTenant = errors.New("the default tenant cannot be exported for offboarding or deleted")
+	ErrExportInvalidFormat      = errors.New("invalid export format, must be ndjson or csv")
+	ErrExportNotFound           = errors.New("export not found")
+	ErrExportNotReady           = errors.New("export is not ready for download")
+	ErrDeletionAlreadyScheduled = errors.New("tenant deletion is already scheduled")
+	ErrDeletionNotFound         = errors.New("no scheduled deletion for tenant")
+	ErrDeletionCoolingOffTooLow = errors.New("cooling-off period is too short")
+)
+
+// Tables that are never exported (job bookkeeping, TLS private keys)
+var exportExcludedTables = map[string]bool{
+	"tenant_export_jobs":   true,
+	"tenant_deletion_jobs": true,
+	"tenant_certificates":  true,
+}
+
+// Tables the purge handles itself: the deletion job is the record of the
+// erasure, and audit logs are replaced by a final entry
+var purgeExcludedTables = map[string]bool{
+	"tenant_deletion_jobs": true,
+	"tenant_audit_logs":    true,
+}
+
+// redactedExportColumns are credentials left out of exports
+var redactedExportColumns = map[string]bool{
+	"password":      true,
+	"api_key":       true,
+	"private_key":   true,
+	"refresh_token": true,
+	"secret":        true,
+}
+
+// IsRedactedExportColumn reports whether a column holds a credential that
+// must not leave the platform in an export (keys such as signing_key included)
+func IsRedactedExportColumn(column string) bool {
+	column = strings.ToLower(column)
+	return redactedExportColumns[column] ||
+		strings.HasSuffix(column, "_key") ||
+		strings.HasSuffix(column, "_hash") ||
+		strings.HasSuffix(column, "_secret") ||
+		strings.HasSuffix(column, "_token")
+}
+
+// TenantOffboardingService runs tenant exports and hard deletes
+type TenantOffboardingService struct {
+	db        *gorm.DB
+	tenants   *TenantService
+	exportDir string
+	wal       *WALService
+
+	trigger  chan struct{}
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+	running  bool
+	mu       sync.Mutex
+}
+
+var (
+	offboardingService     *TenantOffboardingService
+	offboardingServiceOnce sync.Once
+)
+
+// GetTenantOffboardingService returns the singleton offboarding service.
+// Exports are written under TENANT_EXPORT_DIR (default ./data/exports).
+func GetTenantOffboardingService(db *gorm.DB) *TenantOffboardingService {
+	offboardingServiceOnce.Do(func() {
+		dir := os.Getenv("TENANT_EXPORT_DIR")
+		if dir == "" {
+			dir = "./data/exports"
+		}
+		offboardingService = NewTenantOffboardingService(db, dir)
+	})
+	return offboardingService
+}
+
+// NewTenantOffboardingService creates an offboarding service writing exports
+// under exportDir
+func NewTenantOffboardingService(db *gorm.DB, exportDir string) *TenantOffboardingService {
+	return &TenantOffboardingService{
+		db:        db,
+		tenants:   NewTenantService(db),
+		exportDir: exportDir,
+		trigger:   make(chan struct{}, 1),
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// SetWAL sets the WAL purged on hard delete (default: the shared WAL)
+func (s *TenantOffboardingService) SetWAL(wal *WALService) {
+	s.wal = wal
+}
+
+// DeletionCoolingOff returns the default cooling-off period, overridable with
+// TENANT_DELETION_COOLING_OFF_DAYS
+func DeletionCoolingOff() time.Duration {
+	if days, err := strconv.Atoi(os.Getenv("TENANT_DELETION_COOLING_OFF_DAYS")); err == nil && days > 0 {
+		return time.Duration(days) * 24 * time.Hour
+	}
+	return DefaultTenantDeletionCoolingOff
+}
+
+// ============================================
+// TABLE DISCOVERY
+// ============================================
+
+// TableForeignKey is a foreign key between two tables
+type TableForeignKey struct {
+	Child        string
+	ChildColumn  string
+	Parent       string
+	ParentColumn string
+}
+
+// TenantTableScope selects a tenant's rows in one table: directly by
+// tenant_id, or through a foreign key to a table that has it
+type TenantTableScope struct {
+	Table string
+	Via   *TableForeignKey
+}
+
+// Where returns the SQL condition selecting the tenant's rows (one tenant_id arg)
+func (s TenantTableScope) Where() string {
+	if s.Via == nil {
+		return "tenant_id = ?"
+	}
+	return fmt.Sprintf("%s IN (SELECT %s FROM %s WHERE tenant_id = ?)",
+		quoteIdent(s.Via.ChildColumn), quoteIdent(s.Via.ParentColumn), quoteIdent(s.Via.Parent))
+}
+
+// OrderTenantTables returns the scopes for tables with a tenant_id column
+// plus their direct child tables without one, ordered so that referencing
+// tables come before the tables they reference (safe deletion order).
+func OrderTenantTables(direct []string, fks []TableForeignKey) []TenantTableScope {
+	scopes := make(map[string]TenantTableScope)
+	for _, table := range direct {
+		scopes[table] = TenantTableScope{Table: table}
+	}
+	for i := range fks {
+		fk := fks[i]
+		if _, parentScoped := scopes[fk.Parent]; !parentScoped || fk.Parent == fk.Child {
+			continue
+		}
+		if _, seen := scopes[fk.Child]; seen {
+			continue
+		}
+		if isDirect(direct, fk.Parent) {
+			scopes[fk.Child] = TenantTableScope{Table: fk.Child, Via: &fk}
+		}
+	}
+
+	// referencedBy[parent] = children still to delete first
+	referencedBy := make(map[string]map[string]bool)
+	for _, fk := range fks {
+		if fk.Parent == fk.Child {
+			continue
+		}
+		if _, ok := scopes[fk.Parent]; !ok {
+			continue
+		}
+		if _, ok := scopes[fk.Child]; !ok {
+			continue
+		}
+		if referencedBy[fk.Parent] == nil {
+			referencedBy[fk.Parent] = make(map[string]bool)
+		}
+		referencedBy[fk.Parent][fk.Child] = true
+	}
+
+	names := make([]string, 0, len(scopes))
+	for name := range scopes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ordered := make([]TenantTableScope, 0, len(names))
+	done := make(map[string]bool)
+	for len(ordered) < len(names) {
+		progressed := false
+		for _, name := range names {
+			if done[name] {
+				continue
+			}
+			ready := true
+			for child := range referencedBy[name] {
+				if !done[child] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				ordered = append(ordered, scopes[name])
+				done[name] = true
+				progressed = true
+			}
+		}
+		if !progressed {
+			// Reference cycle: fall back to name order for the rest
+			for _, name := range names {
+				if !done[name] {
+					ordered = append(ordered, scopes[name])
+					done[name] = true
+				}
+			}
+		}
+	}
+	return ordered
+}
+
+func isDirect(direct []string, table string) bool {
+	for _, t := range direct {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// tenantScopes discovers tenant-scoped tables from the database schema
+func (s *TenantOffboardingService) tenantScopes(excluded map[string]bool) ([]TenantTableScope, error) {
+	var direct []string
+	if err := s.db.Raw(`
+		SELECT c.table_name FROM information_schema.columns c
+		JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE c.table_schema = current_schema() AND c.column_name = 'tenant_id' AND t.table_type = 'BASE TABLE'
+		ORDER BY c.table_name
+	`).Scan(&direct).Error; err != nil {
+		return nil, err
+	}
+
+	var fks []TableForeignKey
+	if err := s.db.Raw(`
+		SELECT kcu.table_name AS child, kcu.column_name AS child_column,
+		       ccu.table_name AS parent, ccu.column_name AS parent_column
+		FROM information_schema.table_constraints tc
+		JOIN information_schema.key_column_usage kcu
+		  ON kcu.constraint_name = tc.constraint_name AND kcu.table_schema = tc.table_schema
+		JOIN information_schema.constraint_column_usage ccu
+		  ON ccu.constraint_name = tc.constraint_name AND ccu.table_schema = tc.table_schema
+		WHERE tc.constraint_type = 'FOREIGN KEY' AND tc.table_schema = current_schema()
+	`).Scan(&fks).Error; err != nil {
+		return nil, err
+	}
+
+	var scopes []TenantTableScope
+	for _, scope := range OrderTenantTables(direct, fks) {
+		if excluded[scope.Table] || (scope.Via != nil && excluded[scope.Via.Parent]) {
+			continue
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes, nil
+}
+
+// ============================================
+// EXPORT
+// ============================================
+
+// RequestExport queues an export of all of a tenant's data
+func (s *TenantOffboardingService) RequestExport(tenantID uuid.UUID, format models.TenantExportFormat, requestedBy *uuid.UUID) (*models.TenantExportJob, error) {
+	if format == "" {
+		format = models.TenantExportNDJSON
+	}
+	if format != models.TenantExportNDJSON && format != models.TenantExportCSV {
+		return nil, ErrExportInvalidFormat
+	}
+	if _, err := s.tenants.GetTenant(tenantID); err != nil {
+		return nil, err
+	}
+
+	job := &models.TenantExportJob{
+		TenantID:    tenantID,
+		Status:      models.TenantJobPending,
+		Format:      format,
+		RequestedBy: requestedBy,
+	}
+	if err := s.db.Create(job).Error; err != nil {
+		return nil, err
+	}
+
+	s.tenants.logAudit(tenantID, models.TenantAuditExportRequested, requestedBy, nil, map[string]interface{}{
+		"export_id": job.ID,
+		"format":    format,
+	})
+	s.wake()
+	return job, nil
+}
+
+// GetExport returns an export job of a tenant
+func (s *TenantOffboardingService) GetExport(tenantID, exportID uuid.UUID) (*models.TenantExportJob, error) {
+	var job models.TenantExportJob
+	if err := s.db.First(&job, "id = ? AND tenant_id = ?", exportID, tenantID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrExportNotFound
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListExports lists a tenant's export jobs, newest first
+func (s *TenantOffboardingService) ListExports(tenantID uuid.UUID) ([]models.TenantExportJob, error) {
+	var jobs []models.TenantExportJob
+	err := s.db.Where("tenant_id = ?", tenantID).Order("created_at DESC").Limit(50).Find(&jobs).Error
+	return jobs, err
+}
+
+// ExportFile returns the archive path of a completed export
+func (s *TenantOffboardingService) ExportFile(tenantID, exportID uuid.UUID) (*models.TenantExportJob, string, error) {
+	job, err := s.GetExport(tenantID, exportID)
+	if err != nil {
+		return nil, "", err
+	}
+	if job.Status != models.TenantJobCompleted || job.FilePath == "" {
+		return nil, "", ErrExportNotReady
+	}
+	if _, err := os.Stat(job.FilePath); err != nil {
+		return nil, "", ErrExportNotReady
+	}
+	return job, job.FilePath, nil
+}
+
+// runExport writes the archive of a claimed export job
+func (s *TenantOffboardingService) runExport(job *models.TenantExportJob) error {
+	tenant, err := s.tenants.GetTenant(job.TenantID)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(s.exportDir, job.TenantID.String())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create export directory: %w", err)
+	}
+	path := filepath.Join(dir, job.ID.String()+".zip")
+	partial := path + ".partial"
+
+	file, err := os.OpenFile(partial, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	archiveHash := sha256.New()
+	archive := &countingWriter{w: io.MultiWriter(file, archiveHash)}
+	zw := zip.NewWriter(archive)
+
+	manifest, err := s.writeArchive(zw, job, tenant)
+	if err == nil {
+		err = writeZipJSON(zw, "manifest.json", manifest)
+	}
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(partial, path)
+	}
+	if err != nil {
+		os.Remove(partial)
+		return err
+	}
+
+	var rows int64
+	for _, f := range manifest.Files {
+		rows += f.Rows
+	}
+	now := time.Now().UTC()
+	expires := now.Add(TenantExportRetention)
+	job.Status = models.TenantJobCompleted
+	job.FilePath = path
+	job.SizeBytes = archive.n
+	job.SHA256 = hex.EncodeToString(archiveHash.Sum(nil))
+	job.Tables = len(manifest.Files)
+	job.Rows = rows
+	job.CompletedAt = &now
+	job.ExpiresAt = &expires
+	if err := s.db.Save(job).Error; err != nil {
+		return err
+	}
+
+	s.tenants.logAudit(job.TenantID, models.TenantAuditExportCompleted, job.RequestedBy, nil, map[string]interface{}{
+		"export_id": job.ID,
+		"tables":    job.Tables,
+		"rows":      job.Rows,
+		"sha256":    job.SHA256,
+	})
+	log.Printf("[Offboarding] export %s for tenant %s: %d tables, %d rows", job.ID, job.TenantID, job.Tables, job.Rows)
+	return nil
+}
+
+// writeArchive writes tenant.json and one file per table
+func (s *TenantOffboardingService) writeArchive(zw *zip.Writer, job *models.TenantExportJob, tenant *models.Tenant) (*models.TenantExportManifest, error) {
+	manifest := &models.TenantExportManifest{
+		TenantID:    tenant.ID,
+		TenantSlug:  tenant.Slug,
+		ExportID:    job.ID,
+		Format:      job.Format,
+		GeneratedAt: time.Now().UTC(),
+		Files:       []models.TenantExportManifestFile{},
+		Redacted:    []string{},
+	}
+
+	entry, err := zipJSONEntry(zw, "tenant.json", tenant)
+	if err != nil {
+		return nil, err
+	}
+	entry.Table = "tenants"
+	entry.Rows = 1
+	manifest.Files = append(manifest.Files, entry)
+
+	scopes, err := s.tenantScopes(exportExcludedTables)
+	if err != nil {
+		return nil, err
+	}
+	for _, scope := range scopes {
+		entry, redacted, err := s.exportTable(zw, scope, job.TenantID, job.Format)
+		if err != nil {
+			return nil, fmt.Errorf("export of %s failed: %w", scope.Table, err)
+		}
+		manifest.Files = append(manifest.Files, entry)
+		for _, column := range redacted {
+			manifest.Redacted = append(manifest.Redacted, scope.Table+"."+column)
+		}
+	}
+	return manifest, nil
+}
+
+// exportTable streams a table's tenant rows into the archive
+func (s *TenantOffboardingService) exportTable(zw *zip.Writer, scope TenantTableScope, tenantID uuid.UUID, format models.TenantExportFormat) (models.TenantExportManifestFile, []string, error) {
+	name := scope.Table + "." + string(format)
+	entry := models.TenantExportManifestFile{Name: name, Table: scope.Table}
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return entry, nil, err
+	}
+	fileHash := sha256.New()
+	out := &countingWriter{w: io.MultiWriter(w, fileHash)}
+
+	rows, err := s.db.Raw(fmt.Sprintf("SELECT * FROM %s WHERE %s", quoteIdent(scope.Table), scope.Where()), tenantID).Rows()
+	if err != nil {
+		return entry, nil, err
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return entry, nil, err
+	}
+
+	var columns, redacted []string
+	var keep []int
+	for i, ct := range columnTypes {
+		if IsRedactedExportColumn(ct.Name()) {
+			redacted = append(redacted, ct.Name())
+			continue
+		}
+		columns = append(columns, ct.Name())
+		keep = append(keep, i)
+	}
+
+	var csvWriter *csv.Writer
+	if format == models.TenantExportCSV {
+		csvWriter = csv.NewWriter(out)
+		if err := csvWriter.Write(columns); err != nil {
+			return entry, nil, err
+		}
+	}
+	encoder := json.NewEncoder(out)
+
+	values := make([]interface{}, len(columnTypes))
+	ptrs := make([]interface{}, len(columnTypes))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return entry, nil, err
+		}
+
+		if csvWriter != nil {
+			record := make([]string, len(keep))
+			for j, i := range keep {
+				record[j] = exportCSVValue(values[i])
+			}
+			if err := csvWriter.Write(record); err != nil {
+				return entry, nil, err
+			}
+		} else {
+			record := make(map[string]interface{}, len(keep))
+			for _, i := range keep {
+				record[columnTypes[i].Name()] = exportJSONValue(values[i], columnTypes[i].DatabaseTypeName())
+			}
+			if err := encoder.Encode(record); err != nil {
+				return entry, nil, err
+			}
+		}
+		entry.Rows++
+	}
+	if err := rows.Err(); err != nil {
+		return entry, nil, err
+	}
+	if csvWriter != nil {
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			return entry, nil, err
+		}
+	}
+
+	entry.Bytes = out.n
+	entry.SHA256 = hex.EncodeToString(fileHash.Sum(nil))
+	return entry, redacted, nil
+}
+
+func exportJSONValue(value interface{}, dbType string) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		if (dbType == "JSON" || dbType == "JSONB") && json.Valid(v) {
+			return json.RawMessage(v)
+		}
+		return string(v)
+	case string:
+		if (dbType == "JSON" || dbType == "JSONB") && json.Valid([]byte(v)) {
+			return json.RawMessage(v)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func exportCSVValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func zipJSONEntry(zw *zip.Writer, name string, value interface{}) (models.TenantExportManifestFile, error) {
+	entry := models.TenantExportManifestFile{Name: name}
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return entry, err
+	}
+	w, err := zw.Create(name)
+	if err != nil {
+		return entry, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return entry, err
+	}
+	sum := sha256.Sum256(data)
+	entry.Bytes = int64(len(data))
+	entry.SHA256 = hex.EncodeToString(sum[:])
+	return entry, nil
+}
+
+func writeZipJSON(zw *zip.Writer, name string, value interface{}) error {
+	_, err := zipJSONEntry(zw, name, value)
+	return err
+}
+
+// countingWriter counts bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// expireExports removes archives past their retention
+func (s *TenantOffboardingService) expireExports() {
+	var jobs []models.TenantExportJob
+	s.db.Where("status = ? AND expires_at <= ?", models.TenantJobCompleted, time.Now().UTC()).Find(&jobs)
+	for _, job := range jobs {
+		if job.FilePath != "" {
+			os.Remove(job.FilePath)
+		}
+		s.db.Model(&job).Updates(map[string]interface{}{
+			"status":    models.TenantJobExpired,
+			"file_path": "",
+		})
+	}
+}
+
+// ============================================
+// HARD DELETE
+// ============================================
+
+// ScheduleDeletion disables the tenant now and schedules its hard delete after
+// the cooling-off period (0 uses the default)
+func (s *TenantOffboardingService) ScheduleDeletion(tenantID uuid.UUID, reason string, coolingOff time.Duration, requestedBy *uuid.UUID) (*models.TenantDeletionJob, error) {
+	if tenantID == models.DefaultTenantID {
+		return nil, ErrOffboardingDefaultTenant
+	}
+	if coolingOff == 0 {
+		coolingOff = DeletionCoolingOff()
+	}
+	if coolingOff < MinTenantDeletionCoolingOff {
+		return nil, ErrDeletionCoolingOffTooLow
+	}
+
+	tenant, err := s.tenants.GetTenant(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing int64
+	s.db.Model(&models.TenantDeletionJob{}).
+		Where("tenant_id = ? AND status IN ?", tenantID, []models.TenantJobStatus{models.TenantJobScheduled, models.TenantJobRunning}).
+		Count(&existing)
+	if existing > 0 {
+		return nil, ErrDeletionAlreadyScheduled
+	}
+
+	previous := tenant.Status
+	if previous == models.TenantStatusDeleted {
+		previous = models.TenantStatusActive
+	}
+	job := &models.TenantDeletionJob{
+		TenantID:       tenantID,
+		Status:         models.TenantJobScheduled,
+		Reason:         reason,
+		RequestedBy:    requestedBy,
+		PreviousStatus: previous,
+		ScheduledFor:   time.Now().UTC().Add(coolingOff),
+	}
+	if err := s.db.Create(job).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.tenants.DeleteTenant(tenantID); err != nil {
+		return nil, err
+	}
+
+	s.tenants.logAudit(tenantID, models.TenantAuditDeletionScheduled, requestedBy, nil, map[string]interface{}{
+		"deletion_id":   job.ID,
+		"scheduled_for": job.ScheduledFor,
+		"reason":        reason,
+	})
+	return job, nil
+}
+
+// GetDeletion returns the latest deletion job of a tenant
+func (s *TenantOffboardingService) GetDeletion(tenantID uuid.UUID) (*models.TenantDeletionJob, error) {
+	var job models.TenantDeletionJob
+	if err := s.db.Where("tenant_id = ?", tenantID).Order("created_at DESC").First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeletionNotFound
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CancelDeletion cancels a scheduled deletion and restores the tenant
+func (s *TenantOffboardingService) CancelDeletion(tenantID uuid.UUID, actorID *uuid.UUID) (*models.TenantDeletionJob, error) {
+	now := time.Now().UTC()
+	result := s.db.Model(&models.TenantDeletionJob{}).
+		Where("tenant_id = ? AND status = ?", tenantID, models.TenantJobScheduled).
+		Updates(map[string]interface{}{
+			"status":      models.TenantJobCanceled,
+			"canceled_at": &now,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrDeletionNotFound
+	}
+
+	deletion, err := s.GetDeletion(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Model(&models.Tenant{}).Where("id = ?", tenantID).
+		Updates(map[string]interface{}{
+			"status":     deletion.PreviousStatus,
+			"deleted_at": nil,
+		}).Error; err != nil {
+		return nil, err
+	}
+	s.tenants.invalidateCache(tenantID)
+
+	s.tenants.logAudit(tenantID, models.TenantAuditDeletionCanceled, actorID, nil, map[string]interface{}{
+		"deletion_id": deletion.ID,
+		"status":      deletion.PreviousStatus,
+	})
+	return deletion, nil
+}
+
+// runDeletion purges a tenant: rows in every tenant table, export archives,
+// Redis keys and WAL entries. The tenant row is kept as an anonymised
+// tombstone with a single final audit entry.
+func (s *TenantOffboardingService) runDeletion(job *models.TenantDeletionJob) error {
+	tenantID := job.TenantID
+
+	// Export archives go first: their job rows are purged below
+	os.RemoveAll(filepath.Join(s.exportDir, tenantID.String()))
+
+	summary, total, err := s.purgeRows(tenantID)
+	summaryJSON, _ := json.Marshal(summary)
+	job.Summary = summaryJSON
+	job.RowsDeleted = total
+	if err != nil {
+		return err
+	}
+
+	// Redis: tenant-scoped keys and the tenant record
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := cache.NewTenantCache(tenantID).ClearTenantCache(ctx); err != nil {
+		log.Printf("[Offboarding] cache purge for tenant %s failed: %v", tenantID, err)
+	} else {
+		job.CacheCleared = true
+	}
+
+	// WAL entries not yet compacted
+	wal := s.wal
+	if wal == nil {
+		wal = GetWALService()
+	}
+	removed, err := wal.PurgeTenant(tenantID.String())
+	if err != nil {
+		return fmt.Errorf("WAL purge failed: %w", err)
+	}
+	job.WALEntriesRemoved = removed
+
+	// Anonymise the tenant row (kept so the audit trail has a parent)
+	now := time.Now().UTC()
+	if err := s.db.Model(&models.Tenant{}).Where("id = ?", tenantID).Updates(map[string]interface{}{
+		"name":               "deleted tenant",
+		"slug":               "deleted-" + tenantID.String(),
+		"status":             models.TenantStatusDeleted,
+		"admin_email":        "",
+		"admin_user_id":      nil,
+		"settings":           nil,
+		"logo_url":           "",
+		"favicon_url":        "",
+		"allowed_domains":    nil,
+		"custom_domain":      "",
+		"default_network_id": nil,
+		"features":           nil,
+		"billing_email":      "",
+		"stripe_customer_id": "",
+		"deleted_at":         &now,
+	}).Error; err != nil {
+		return err
+	}
+	s.tenants.invalidateCache(tenantID)
+
+	// Replace the audit trail with the final record of the erasure
+	if err := s.db.Where("tenant_id = ?", tenantID).Delete(&models.TenantAuditLog{}).Error; err != nil {
+		return err
+	}
+	s.tenants.logAudit(tenantID, models.TenantAuditHardDeleted, job.RequestedBy, nil, map[string]interface{}{
+		"deletion_id":         job.ID,
+		"reason":              job.Reason,
+		"rows_deleted":        job.RowsDeleted,
+		"tables":              summary,
+		"wal_entries_removed": job.WALEntriesRemoved,
+		"cache_cleared":       job.CacheCleared,
+	})
+
+	log.Printf("[Offboarding] tenant %s hard deleted: %d rows, %d WAL entries", tenantID, job.RowsDeleted, job.WALEntriesRemoved)
+	return nil
+}
+
+// purgeRows deletes the tenant's rows table by table in batches. It can be
+// re-run after a failure.
+func (s *TenantOffboardingService) purgeRows(tenantID uuid.UUID) (map[string]int64, int64, error) {
+	summary := make(map[string]int64)
+	var total int64
+
+	scopes, err := s.tenantScopes(purgeExcludedTables)
+	if err != nil {
+		return summary, 0, err
+	}
+
+	for _, scope := range scopes {
+		table := quoteIdent(scope.Table)
+		query := fmt.Sprintf("DELETE FROM %s WHERE ctid IN (SELECT ctid FROM %s WHERE %s LIMIT %d)",
+			table, table, scope.Where(), offboardingDeleteBatch)
+		for {
+			result := s.db.Exec(query, tenantID)
+			if result.Error != nil {
+				return summary, total, fmt.Errorf("purge of %s failed: %w", scope.Table, result.Error)
+			}
+			summary[scope.Table] += result.RowsAffected
+			total += result.RowsAffected
+			if result.RowsAffected < offboardingDeleteBatch {
+				break
+			}
+		}
+	}
+	return summary, total, nil
+}
+
+// ============================================
+// WORKER
+// ============================================
+
+// Start runs queued exports and due deletions in the background
+func (s *TenantOffboardingService) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return
+	}
+	s.running = true
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(offboardingPollInterval)
+		defer ticker.Stop()
+		for {
+			s.RunPending()
+			select {
+			case <-s.stopChan:
+				return
+			case <-ticker.C:
+			case <-s.trigger:
+			}
+		}
+	}()
+}
+
+// Stop stops the background worker
+func (s *TenantOffboardingService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	s.mu.Unlock()
+
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+func (s *TenantOffboardingService) wake() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// RunPending processes queued exports, due deletions and expired archives
+func (s *TenantOffboardingService) RunPending() {
+	var exports []models.TenantExportJob
+	s.db.Where("status = ?", models.TenantJobPending).Order("created_at ASC").Limit(10).Find(&exports)
+	for i := range exports {
+		job := &exports[i]
+		if !s.claim(&models.TenantExportJob{}, job.ID, models.TenantJobPending) {
+			continue
+		}
+		now := time.Now().UTC()
+		job.Status = models.TenantJobRunning
+		job.StartedAt = &now
+		if err := s.runExport(job); err != nil {
+			log.Printf("[Offboarding] export %s failed: %v", job.ID, err)
+			s.db.Model(job).Updates(map[string]interface{}{
+				"status": models.TenantJobFailed,
+				"error":  truncate(err.Error(), 500),
+			})
+		}
+	}
+
+	var deletions []models.TenantDeletionJob
+	s.db.Where("status = ? AND scheduled_for <= ?", models.TenantJobScheduled, time.Now().UTC()).
+		Order("scheduled_for ASC").Limit(5).Find(&deletions)
+	for i := range deletions {
+		job := &deletions[i]
+		if !s.claim(&models.TenantDeletionJob{}, job.ID, models.TenantJobScheduled) {
+			continue
+		}
+		now := time.Now().UTC()
+		job.Status = models.TenantJobRunning
+		job.StartedAt = &now
+		if err := s.runDeletion(job); err != nil {
+			log.Printf("[Offboarding] deletion of tenant %s failed: %v", job.TenantID, err)
+			job.Status = models.TenantJobFailed
+			job.Error = truncate(err.Error(), 500)
+		} else {
+			completed := time.Now().UTC()
+			job.Status = models.TenantJobCompleted
+			job.CompletedAt = &completed
+			job.Error = ""
+		}
+		s.db.Save(job)
+	}
+
+	s.expireExports()
+}
+
+// RetryDeletion re-queues a failed deletion to run immediately
+func (s *TenantOffboardingService) RetryDeletion(tenantID uuid.UUID) (*models.TenantDeletionJob, error) {
+	result := s.db.Model(&models.TenantDeletionJob{}).
+		Where("tenant_id = ? AND status = ?", tenantID, models.TenantJobFailed).
+		Updates(map[string]interface{}{
+			"status":        models.TenantJobScheduled,
+			"scheduled_for": time.Now().UTC(),
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrDeletionNotFound
+	}
+	s.wake()
+	return s.GetDeletion(tenantID)
+}
+
+// claim moves a job out of from to running; false if another worker has it
+func (s *TenantOffboardingService) claim(model interface{}, id uuid.UUID, from models.TenantJobStatus) bool {
+	result := s.db.Model(model).
+		Where("id = ? AND status = ?", id, from).
+		Updates(map[string]interface{}{
+			"status":     models.TenantJobRunning,
+			"started_at": time.Now().UTC(),
+		})
+	return result.Error == nil && result.RowsAffected == 1
+}
@@ -0,0 +1,493 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/pkg/utils"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// TENANT SIGNUP & ONBOARDING
+// ============================================
+//
+// Self-service signup creates a pending tenant with its admin user and seeds
+// defaults (settings, plan features, a network, a sample offer and a draft
+// webhook) in one transaction. The tenant activates once every step in
+// models.OnboardingSteps is complete.
+
+// Onboarding configuration
+const (
+	EmailVerificationTTL = 48 * time.Hour
+	sampleOfferURL       = "https://example.com/?ref=afftok"
+	sampleWebhookURL     = "https://example.com/webhooks/afftok"
+)
+
+// Errors returned by onboarding
+var (
+	ErrSignupUsernameTaken    = errors.New("username already exists")
+	ErrSignupEmailTaken       = errors.New("email already exists")
+	ErrOnboardingNotFound     = errors.New("onboarding not found")
+	ErrOnboardingInvalidToken = errors.New("invalid or expired verification token")
+	ErrOnboardingUnknownStep  = errors.New("unknown onboarding step")
+	ErrOnboardingStepBlocked  = errors.New("onboarding step requirements not met")
+)
+
+// TenantSignupRequest is the public signup form
+type TenantSignupRequest struct {
+	CompanyName   string `json:"company_name" binding:"required,min=2,max=100"`
+	Slug          string `json:"slug" binding:"required"`
+	AdminEmail    string `json:"admin_email" binding:"required,email,max=255"`
+	AdminUsername string `json:"admin_username" binding:"required,min=3,max=50"`
+	Password      string `json:"password" binding:"required,min=8,max=100"`
+	FullName      string `json:"full_name" binding:"max=100"`
+}
+
+// TenantSignupResult is what signup created
+type TenantSignupResult struct {
+	Tenant *models.Tenant
+	Admin  *models.AfftokUser
+}
+
+// OnboardingStepStatus is one step of the wizard
+type OnboardingStepStatus struct {
+	Key         string     `json:"key"`
+	Completed   bool       `json:"completed"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// OnboardingProgress is the wizard state shown by the admin frontend
+type OnboardingProgress struct {
+	TenantID       uuid.UUID              `json:"tenant_id"`
+	TenantStatus   models.TenantStatus    `json:"tenant_status"`
+	Steps          []OnboardingStepStatus `json:"steps"`
+	CompletedCount int                    `json:"completed_count"`
+	TotalCount     int                    `json:"total_count"`
+	Percent        int                    `json:"percent"`
+	NextStep       string                 `json:"next_step,omitempty"`
+	CompletedAt    *time.Time             `json:"completed_at,omitempty"`
+}
+
+// TenantOnboardingService handles self-service signup and the onboarding wizard
+type TenantOnboardingService struct {
+	db      *gorm.DB
+	tenants *TenantService
+	email   EmailSender
+}
+
+// NewTenantOnboardingService creates a new onboarding service
+func NewTenantOnboardingService(db *gorm.DB) *TenantOnboardingService {
+	return &TenantOnboardingService{
+		db:      db,
+		tenants: NewTenantService(db),
+		email:   GetEmailSender(),
+	}
+}
+
+// SetEmailSender sets the sender used for verification email
+func (s *TenantOnboardingService) SetEmailSender(sender EmailSender) {
+	s.email = sender
+}
+
+// ============================================
+// SIGNUP
+// ============================================
+
+// Signup creates a pending tenant, its admin user and seeded defaults, then
+// sends the email verification
+func (s *TenantOnboardingService) Signup(req *TenantSignupRequest) (*TenantSignupResult, error) {
+	req.Slug = strings.ToLower(strings.TrimSpace(req.Slug))
+	req.AdminEmail = strings.ToLower(strings.TrimSpace(req.AdminEmail))
+
+	if err := s.tenants.validateSlug(req.Slug); err != nil {
+		return nil, err
+	}
+
+	// Usernames and emails are unique across tenants
+	var count int64
+	s.db.Model(&models.AfftokUser{}).Where("username = ?", req.AdminUsername).Count(&count)
+	if count > 0 {
+		return nil, ErrSignupUsernameTaken
+	}
+	s.db.Model(&models.AfftokUser{}).Where("email = ?", req.AdminEmail).Count(&count)
+	if count > 0 {
+		return nil, ErrSignupEmailTaken
+	}
+
+	passwordHash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	tenant := &models.Tenant{
+		ID:           uuid.New(),
+		Name:         req.CompanyName,
+		Slug:         req.Slug,
+		Status:       models.TenantStatusPending,
+		Plan:         models.TenantPlanFree,
+		AdminEmail:   req.AdminEmail,
+		BillingEmail: req.AdminEmail,
+	}
+	applyPlanDefaults(tenant)
+
+	// "admin" in a tenant other than the platform tenant is a tenant admin:
+	// platform-wide routes require SuperAdminMiddleware
+	admin := &models.AfftokUser{
+		TenantModel:  models.TenantModel{TenantID: tenant.ID},
+		ID:           uuid.New(),
+		Username:     req.AdminUsername,
+		Email:        req.AdminEmail,
+		PasswordHash: passwordHash,
+		FullName:     req.FullName,
+		Role:         "admin",
+		Status:       "active",
+		Level:        1,
+		UniqueCode:   models.GenerateUniqueCode(),
+		CompanyName:  req.CompanyName,
+	}
+
+	token := newOnboardingToken()
+	expires := time.Now().Add(EmailVerificationTTL)
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(tenant).Error; err != nil {
+			return fmt.Errorf("failed to create tenant: %w", err)
+		}
+		if err := tx.Create(admin).Error; err != nil {
+			return fmt.Errorf("failed to create admin user: %w", err)
+		}
+
+		networkID, err := seedTenantDefaults(tx, tenant)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(tenant).Updates(map[string]interface{}{
+			"admin_user_id":      admin.ID,
+			"default_network_id": networkID,
+		}).Error; err != nil {
+			return err
+		}
+		tenant.AdminUserID = &admin.ID
+		tenant.DefaultNetworkID = &networkID
+
+		onboarding := &models.TenantOnboarding{
+			TenantID:            tenant.ID,
+			AdminUserID:         admin.ID,
+			Steps:               datatypes.JSON("{}"),
+			EmailTokenHash:      hashOnboardingToken(token),
+			EmailTokenExpiresAt: &expires,
+		}
+		return tx.Create(onboarding).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.tenants.logAudit(tenant.ID, models.TenantAuditCreated, &admin.ID, nil, map[string]string{
+		"source": "self_service_signup",
+		"slug":   tenant.Slug,
+	})
+	s.tenants.invalidateCache(tenant.ID)
+
+	if err := s.sendVerificationEmail(tenant, admin, token); err != nil {
+		// Signup still succeeds: the admin can ask for a new email
+		log.Printf("[Onboarding] verification email for tenant %s failed: %v", tenant.Slug, err)
+	}
+
+	return &TenantSignupResult{Tenant: tenant, Admin: admin}, nil
+}
+
+// seedTenantDefaults creates the starter network, a paused sample offer and a
+// draft webhook for a new tenant. It returns the network ID.
+func seedTenantDefaults(tx *gorm.DB, tenant *models.Tenant) (uuid.UUID, error) {
+	owner := models.TenantModel{TenantID: tenant.ID}
+
+	network := &models.Network{
+		TenantModel: owner,
+		ID:          uuid.New(),
+		Name:        tenant.Name + " Network",
+		Description: "Default network created at signup",
+		Status:      "active",
+	}
+	if err := tx.Create(network).Error; err != nil {
+		return uuid.Nil, fmt.Errorf("failed to seed network: %w", err)
+	}
+
+	offer := &models.Offer{
+		TenantModel:    owner,
+		ID:             uuid.New(),
+		NetworkID:      &network.ID,
+		Title:          "Sample offer",
+		TitleAr:        "عرض تجريبي",
+		Description:    "An example offer to explore tracking links. Edit it or activate your own offer.",
+		DestinationURL: sampleOfferURL,
+		Category:       "sample",
+		PayoutType:     "cpa",
+		Status:         "paused",
+	}
+	if err := tx.Create(offer).Error; err != nil {
+		return uuid.Nil, fmt.Errorf("failed to seed sample offer: %w", err)
+	}
+
+	pipeline := &models.WebhookPipeline{
+		TenantModel: owner,
+		ID:          uuid.New(),
+		Name:        "Sample conversion webhook",
+		Description: "Sends each conversion to your endpoint. Set the URL and activate it.",
+		OfferID:     &offer.ID,
+		TriggerType: models.WebhookTriggerConversion,
+		Status:      models.WebhookPipelineStatusDraft,
+		MaxRetries:  5,
+		TimeoutMs:   30000,
+		Steps: []models.WebhookStep{{
+			ID:           uuid.New(),
+			StepOrder:    1,
+			Name:         "Notify endpoint",
+			URL:          sampleWebhookURL,
+			Method:       models.WebhookMethodPOST,
+			BodyTemplate: `{"conversion_id":"{{conversion_id}}","offer_id":"{{offer_id}}","amount":"{{amount}}"}`,
+			TimeoutMs:    10000,
+			MaxAttempts:  3,
+		}},
+	}
+	if err := tx.Create(pipeline).Error; err != nil {
+		return uuid.Nil, fmt.Errorf("failed to seed webhook: %w", err)
+	}
+
+	return network.ID, nil
+}
+
+// ============================================
+// EMAIL VERIFICATION
+// ============================================
+
+func newOnboardingToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func hashOnboardingToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *TenantOnboardingService) sendVerificationEmail(tenant *models.Tenant, admin *models.AfftokUser, token string) error {
+	appURL := os.Getenv("ADMIN_APP_URL")
+	if appURL == "" {
+		appURL = "https://admin.afftokapp.com"
+	}
+	link := fmt.Sprintf("%s/verify-email?token=%s", strings.TrimRight(appURL, "/"), token)
+
+	return s.email.Send(EmailMessage{
+		To:      admin.Email,
+		Subject: "Verify your email for " + tenant.Name,
+		Text: fmt.Sprintf("Welcome to AffTok!\n\nConfirm your email to finish setting up %s:\n%s\n\nThe link expires in %d hours.\n",
+			tenant.Name, link, int(EmailVerificationTTL.Hours())),
+	})
+}
+
+// VerifyEmail completes the verify_email step for the token's tenant
+func (s *TenantOnboardingService) VerifyEmail(token string) (*OnboardingProgress, error) {
+	if token == "" {
+		return nil, ErrOnboardingInvalidToken
+	}
+
+	var onboarding models.TenantOnboarding
+	if err := s.db.Where("email_token_hash = ?", hashOnboardingToken(token)).First(&onboarding).Error; err != nil {
+		return nil, ErrOnboardingInvalidToken
+	}
+	if onboarding.EmailTokenExpiresAt == nil || time.Now().After(*onboarding.EmailTokenExpiresAt) {
+		return nil, ErrOnboardingInvalidToken
+	}
+
+	now := time.Now()
+	onboarding.EmailVerifiedAt = &now
+	onboarding.EmailTokenHash = ""
+	onboarding.EmailTokenExpiresAt = nil
+	onboarding.MarkStep(models.OnboardingStepVerifyEmail, now)
+
+	return s.saveAndMaybeActivate(&onboarding)
+}
+
+// ResendVerification issues a new verification token and emails it
+func (s *TenantOnboardingService) ResendVerification(tenantID uuid.UUID) error {
+	onboarding, err := s.getOnboarding(tenantID)
+	if err != nil {
+		return err
+	}
+	if onboarding.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	tenant, err := s.tenants.GetTenant(tenantID)
+	if err != nil {
+		return err
+	}
+	var admin models.AfftokUser
+	if err := s.db.First(&admin, "id = ?", onboarding.AdminUserID).Error; err != nil {
+		return err
+	}
+
+	token := newOnboardingToken()
+	expires := time.Now().Add(EmailVerificationTTL)
+	if err := s.db.Model(onboarding).Updates(map[string]interface{}{
+		"email_token_hash":       hashOnboardingToken(token),
+		"email_token_expires_at": &expires,
+	}).Error; err != nil {
+		return err
+	}
+
+	return s.sendVerificationEmail(tenant, &admin, token)
+}
+
+// ============================================
+// WIZARD STEPS
+// ============================================
+
+// OnboardingStepInput carries the data some steps save
+type OnboardingStepInput struct {
+	CompanyName    string `json:"company_name"`
+	BillingEmail   string `json:"billing_email"`
+	LogoURL        string `json:"logo_url"`
+	FaviconURL     string `json:"favicon_url"`
+	PrimaryColor   string `json:"primary_color"`
+	SecondaryColor string `json:"secondary_color"`
+}
+
+// CompleteStep saves a step's data and marks it done. verify_email can only
+// be completed through the emailed token.
+func (s *TenantOnboardingService) CompleteStep(tenantID uuid.UUID, step string, input *OnboardingStepInput) (*OnboardingProgress, error) {
+	onboarding, err := s.getOnboarding(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	switch step {
+	case models.OnboardingStepCompanyProfile:
+		if input.CompanyName != "" {
+			updates["name"] = input.CompanyName
+		}
+		if input.BillingEmail != "" {
+			updates["billing_email"] = strings.ToLower(input.BillingEmail)
+		}
+	case models.OnboardingStepBranding:
+		if input.LogoURL != "" {
+			updates["logo_url"] = input.LogoURL
+		}
+		if input.FaviconURL != "" {
+			updates["favicon_url"] = input.FaviconURL
+		}
+		if input.PrimaryColor != "" {
+			updates["primary_color"] = input.PrimaryColor
+		}
+		if input.SecondaryColor != "" {
+			updates["secondary_color"] = input.SecondaryColor
+		}
+	case models.OnboardingStepFirstOffer:
+		var active int64
+		s.db.Model(&models.Offer{}).Where("tenant_id = ? AND status = ?", tenantID, "active").Count(&active)
+		if active == 0 {
+			return nil, fmt.Errorf("%w: activate at least one offer", ErrOnboardingStepBlocked)
+		}
+	case models.OnboardingStepVerifyEmail:
+		return nil, fmt.Errorf("%w: use the link in the verification email", ErrOnboardingStepBlocked)
+	default:
+		return nil, ErrOnboardingUnknownStep
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.Model(&models.Tenant{}).Where("id = ?", tenantID).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+		s.tenants.invalidateCache(tenantID)
+	}
+
+	onboarding.MarkStep(step, time.Now())
+	return s.saveAndMaybeActivate(onboarding)
+}
+
+// saveAndMaybeActivate stores progress and activates a pending tenant once
+// every step is complete
+func (s *TenantOnboardingService) saveAndMaybeActivate(onboarding *models.TenantOnboarding) (*OnboardingProgress, error) {
+	if onboarding.IsComplete() && onboarding.CompletedAt == nil {
+		now := time.Now()
+		onboarding.CompletedAt = &now
+	}
+	if err := s.db.Save(onboarding).Error; err != nil {
+		return nil, err
+	}
+
+	if onboarding.CompletedAt != nil {
+		tenant, err := s.tenants.GetTenant(onboarding.TenantID)
+		if err == nil && tenant.Status == models.TenantStatusPending {
+			if err := s.tenants.ActivateTenant(onboarding.TenantID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return s.GetProgress(onboarding.TenantID)
+}
+
+// ============================================
+// PROGRESS
+// ============================================
+
+// GetProgress returns the wizard state for a tenant
+func (s *TenantOnboardingService) GetProgress(tenantID uuid.UUID) (*OnboardingProgress, error) {
+	onboarding, err := s.getOnboarding(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	tenant, err := s.tenants.GetTenant(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	completed := onboarding.CompletedSteps()
+	progress := &OnboardingProgress{
+		TenantID:     tenantID,
+		TenantStatus: tenant.Status,
+		TotalCount:   len(models.OnboardingSteps),
+		CompletedAt:  onboarding.CompletedAt,
+	}
+	for _, step := range models.OnboardingSteps {
+		status := OnboardingStepStatus{Key: step}
+		if at, done := completed[step]; done {
+			status.Completed = true
+			status.CompletedAt = &at
+			progress.CompletedCount++
+		} else if progress.NextStep == "" {
+			progress.NextStep = step
+		}
+		progress.Steps = append(progress.Steps, status)
+	}
+	progress.Percent = progress.CompletedCount * 100 / progress.TotalCount
+
+	return progress, nil
+}
+
+func (s *TenantOnboardingService) getOnboarding(tenantID uuid.UUID) (*models.TenantOnboarding, error) {
+	var onboarding models.TenantOnboarding
+	if err := s.db.First(&onboarding, "tenant_id = ?", tenantID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOnboardingNotFound
+		}
+		return nil, err
+	}
+	return &onboarding, nil
+}
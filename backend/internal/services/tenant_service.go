@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -49,13 +50,30 @@ func (s *TenantService) CreateTenant(tenant *models.Tenant) error {
 		return err
 	}
 
-	// Set defaults based on plan
+	applyPlanDefaults(tenant)
+
+	if err := s.db.Create(tenant).Error; err != nil {
+		return fmt.Errorf("failed to create tenant: %w", err)
+	}
+
+	// Log audit
+	s.logAudit(tenant.ID, models.TenantAuditCreated, nil, nil, tenant)
+
+	// Invalidate cache
+	s.invalidateCache(tenant.ID)
+
+	return nil
+}
+
+// applyPlanDefaults sets limits, features and default settings for the tenant's plan
+func applyPlanDefaults(tenant *models.Tenant) {
 	maxUsers, maxOffers, maxClicks, maxAPIKeys, maxWebhooks := models.GetLimitsForPlan(tenant.Plan)
 	tenant.MaxUsers = maxUsers
 	tenant.MaxOffers = maxOffers
 	tenant.MaxClicksPerDay = maxClicks
 	tenant.MaxAPIKeys = maxAPIKeys
 	tenant.MaxWebhooks = maxWebhooks
+	tenant.LimitGracePercent = models.GetLimitGracePercentForPlan(tenant.Plan)
 
 	// Set default features
 	features := models.GetFeaturesForPlan(tenant.Plan)
@@ -66,18 +84,6 @@ func (s *TenantService) CreateTenant(tenant *models.Tenant) error {
 	settings := models.DefaultTenantSettings()
 	settingsJSON, _ := json.Marshal(settings)
 	tenant.Settings = settingsJSON
-
-	if err := s.db.Create(tenant).Error; err != nil {
-		return fmt.Errorf("failed to create tenant: %w", err)
-	}
-
-	// Log audit
-	s.logAudit(tenant.ID, models.TenantAuditCreated, nil, nil, tenant)
-
-	// Invalidate cache
-	s.invalidateCache(tenant.ID)
-
-	return nil
 }
 
 // GetTenant gets a tenant by ID
@@ -128,30 +134,39 @@ func (s *TenantService) GetTenantBySlug(slug string) (*models.Tenant, error) {
 	return tenant, nil
 }
 
-// GetTenantByDomain gets a tenant by domain
+// GetTenantByDomain gets a tenant by a verified custom domain.
+// Unverified domains never resolve, so a tenant can't claim a hostname it
+// doesn't control. Results are cached briefly so unverification takes effect.
 func (s *TenantService) GetTenantByDomain(domain string) (*models.Tenant, error) {
-	cacheKey := "domain:" + strings.ToLower(domain)
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	cacheKey := "domain:" + domain
 
 	// Try cache first
 	if cached, ok := s.cache.Load(cacheKey); ok {
-		return cached.(*models.Tenant), nil
+		if entry := cached.(domainCacheEntry); time.Now().Before(entry.expiresAt) {
+			if tenant := entry.value.(*models.Tenant); tenant != nil {
+				return tenant, nil
+			}
+			return nil, gorm.ErrRecordNotFound
+		}
+		s.cache.Delete(cacheKey)
 	}
 
-	// Try custom domain first
-	tenant := &models.Tenant{}
-	if err := s.db.First(tenant, "custom_domain = ?", domain).Error; err == nil {
-		s.cache.Store(cacheKey, tenant)
-		s.cacheTenant(tenant)
-		return tenant, nil
-	}
-
-	// Try domain mapping
 	var tenantDomain models.TenantDomain
-	if err := s.db.Preload("Tenant").First(&tenantDomain, "domain = ?", domain).Error; err != nil {
+	err := s.db.Preload("Tenant").
+		First(&tenantDomain, "domain = ? AND is_verified = ?", domain, true).Error
+	if err == nil && tenantDomain.Tenant == nil {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Remember misses too: most hosts (platform domains) aren't custom domains
+			s.cache.Store(cacheKey, domainCacheEntry{value: (*models.Tenant)(nil), expiresAt: time.Now().Add(domainCacheTTL)})
+		}
 		return nil, err
 	}
 
-	s.cache.Store(cacheKey, tenantDomain.Tenant)
+	s.cache.Store(cacheKey, domainCacheEntry{value: tenantDomain.Tenant, expiresAt: time.Now().Add(domainCacheTTL)})
 	s.cacheTenant(tenantDomain.Tenant)
 
 	return tenantDomain.Tenant, nil
@@ -262,6 +277,7 @@ func (s *TenantService) ChangePlan(tenantID uuid.UUID, newPlan models.TenantPlan
 	tenant.MaxClicksPerDay = maxClicks
 	tenant.MaxAPIKeys = maxAPIKeys
 	tenant.MaxWebhooks = maxWebhooks
+	tenant.LimitGracePercent = models.GetLimitGracePercentForPlan(newPlan)
 
 	// Update features
 	features := models.GetFeaturesForPlan(newPlan)
@@ -285,28 +301,52 @@ func (s *TenantService) ChangePlan(tenantID uuid.UUID, newPlan models.TenantPlan
 // TENANT DOMAIN MANAGEMENT
 // ============================================
 
-// AddDomain adds a domain to a tenant
-func (s *TenantService) AddDomain(tenantID uuid.UUID, domain string, isPrimary bool) error {
-	tenantDomain := &models.TenantDomain{
-		ID:        uuid.New(),
-		TenantID:  tenantID,
-		Domain:    strings.ToLower(domain),
-		IsPrimary: isPrimary,
+// AddDomain adds a domain to a tenant. The domain starts unverified with a
+// fresh verification token; see DomainVerificationService.Instructions.
+func (s *TenantService) AddDomain(tenantID uuid.UUID, domain string, isPrimary bool) (*models.TenantDomain, error) {
+	domain, err := s.domainVerifier().NormalizeDomain(domain)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := s.db.Create(tenantDomain).Error; err != nil {
-		return err
+	var existing int64
+	s.db.Model(&models.TenantDomain{}).Where("domain = ?", domain).Count(&existing)
+	if existing > 0 {
+		return nil, ErrDomainTaken
+	}
+
+	tenantDomain := &models.TenantDomain{
+		ID:                uuid.New(),
+		TenantID:          tenantID,
+		Domain:            domain,
+		IsPrimary:         isPrimary,
+		VerificationToken: NewDomainVerificationToken(),
+		CertStatus:        models.DomainCertNone,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if isPrimary {
+			if err := tx.Model(&models.TenantDomain{}).Where("tenant_id = ?", tenantID).
+				Update("is_primary", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(tenantDomain).Error
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Log audit
 	s.logAudit(tenantID, models.TenantAuditDomainAdded, nil, nil, map[string]string{"domain": domain})
 
 	s.invalidateCache(tenantID)
-	return nil
+	return tenantDomain, nil
 }
 
 // RemoveDomain removes a domain from a tenant
 func (s *TenantService) RemoveDomain(tenantID uuid.UUID, domain string) error {
+	domain = strings.ToLower(domain)
 	if err := s.db.Where("tenant_id = ? AND domain = ?", tenantID, domain).
 		Delete(&models.TenantDomain{}).Error; err != nil {
 		return err
@@ -315,6 +355,8 @@ func (s *TenantService) RemoveDomain(tenantID uuid.UUID, domain string) error {
 	// Log audit
 	s.logAudit(tenantID, models.TenantAuditDomainRemoved, nil, nil, map[string]string{"domain": domain})
 
+	s.cache.Delete("domain:" + domain)
+	s.domainVerifier().Invalidate(tenantID, domain)
 	s.invalidateCache(tenantID)
 	return nil
 }
@@ -326,15 +368,20 @@ func (s *TenantService) GetDomains(tenantID uuid.UUID) ([]models.TenantDomain, e
 	return domains, err
 }
 
-// VerifyDomain marks a domain as verified
-func (s *TenantService) VerifyDomain(tenantID uuid.UUID, domain string) error {
-	now := time.Now()
-	return s.db.Model(&models.TenantDomain{}).
-		Where("tenant_id = ? AND domain = ?", tenantID, domain).
-		Updates(map[string]interface{}{
-			"is_verified": true,
-			"verified_at": &now,
-		}).Error
+// VerifyDomain checks the domain's DNS records for proof of ownership.
+// It returns the updated domain together with ErrDomainVerificationFailed
+// when the records are missing or wrong.
+func (s *TenantService) VerifyDomain(tenantID uuid.UUID, domain string) (*models.TenantDomain, error) {
+	tenantDomain, err := s.domainVerifier().Verify(tenantID, domain)
+	if tenantDomain != nil {
+		s.cache.Delete("domain:" + tenantDomain.Domain)
+		s.invalidateCache(tenantID)
+	}
+	return tenantDomain, err
+}
+
+func (s *TenantService) domainVerifier() *DomainVerificationService {
+	return GetDomainVerificationService(s.db)
 }
 
 // ============================================
@@ -348,18 +395,15 @@ func (s *TenantService) GetSettings(tenantID uuid.UUID) (*models.TenantSettings,
 		return nil, err
 	}
 
-	var settings models.TenantSettings
-	if tenant.Settings != nil {
-		json.Unmarshal(tenant.Settings, &settings)
-	} else {
-		settings = models.DefaultTenantSettings()
-	}
-
-	return &settings, nil
+	return ParseTenantSettings(tenant.Settings), nil
 }
 
 // UpdateSettings updates tenant settings
 func (s *TenantService) UpdateSettings(tenantID uuid.UUID, settings *models.TenantSettings) error {
+	if err := ValidateTenantSettings(settings); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTenantSettings, err)
+	}
+
 	settingsJSON, err := json.Marshal(settings)
 	if err != nil {
 		return err
@@ -400,8 +444,8 @@ func (s *TenantService) GetStats(tenantID uuid.UUID) (*models.TenantStats, error
 	// Count conversions
 	s.db.Model(&models.Conversion{}).Where("tenant_id = ?", tenantID).Count(&stats.TotalConversions)
 
-	// Today's clicks
-	today := time.Now().Truncate(24 * time.Hour)
+	// Today's clicks (today in the tenant's timezone)
+	today := StartOfDayIn(time.Now(), GetTenantSettingsResolver(s.db).Location(tenantID))
 	s.db.Model(&models.Click{}).
 		Where("tenant_id = ? AND clicked_at >= ?", tenantID, today).
 		Count(&stats.TodayClicks)
@@ -472,44 +516,40 @@ func (s *TenantService) ListTenants(
 
 // CheckUserLimit checks if tenant can add more users
 func (s *TenantService) CheckUserLimit(tenantID uuid.UUID) (bool, error) {
-	tenant, err := s.GetTenant(tenantID)
-	if err != nil {
-		return false, err
-	}
-
-	var count int64
-	s.db.Model(&models.AfftokUser{}).Where("tenant_id = ?", tenantID).Count(&count)
-
-	return int(count) < tenant.MaxUsers, nil
+	return s.checkLimit(tenantID, models.LimitResourceUsers)
 }
 
 // CheckOfferLimit checks if tenant can add more offers
 func (s *TenantService) CheckOfferLimit(tenantID uuid.UUID) (bool, error) {
-	tenant, err := s.GetTenant(tenantID)
-	if err != nil {
-		return false, err
-	}
+	return s.checkLimit(tenantID, models.LimitResourceOffers)
+}
 
-	var count int64
-	s.db.Model(&models.Offer{}).Where("tenant_id = ?", tenantID).Count(&count)
+// CheckAPIKeyLimit checks if tenant can add more active API keys
+func (s *TenantService) CheckAPIKeyLimit(tenantID uuid.UUID) (bool, error) {
+	return s.checkLimit(tenantID, models.LimitResourceAPIKeys)
+}
 
-	return int(count) < tenant.MaxOffers, nil
+// CheckWebhookLimit checks if tenant can activate more webhook pipelines
+func (s *TenantService) CheckWebhookLimit(tenantID uuid.UUID) (bool, error) {
+	return s.checkLimit(tenantID, models.LimitResourceWebhooks)
 }
 
 // CheckDailyClickLimit checks if tenant has exceeded daily click limit
 func (s *TenantService) CheckDailyClickLimit(tenantID uuid.UUID) (bool, int64, error) {
-	tenant, err := s.GetTenant(tenantID)
+	check, err := GetUsageService(s.db).CheckLimit(tenantID, models.LimitResourceClicksPerDay, 1)
 	if err != nil {
 		return false, 0, err
 	}
+	return check.Allowed, check.Used, nil
+}
 
-	today := time.Now().Truncate(24 * time.Hour)
-	var count int64
-	s.db.Model(&models.Click{}).
-		Where("tenant_id = ? AND clicked_at >= ?", tenantID, today).
-		Count(&count)
-
-	return int(count) < tenant.MaxClicksPerDay, count, nil
+// checkLimit reports whether one more of a resource fits under the hard limit
+func (s *TenantService) checkLimit(tenantID uuid.UUID, resource models.LimitResource) (bool, error) {
+	check, err := GetUsageService(s.db).CheckLimit(tenantID, resource, 1)
+	if err != nil {
+		return false, err
+	}
+	return check.Allowed, nil
 }
 
 // ============================================
@@ -552,12 +592,24 @@ func (s *TenantService) invalidateCache(tenantID uuid.UUID) {
 	ctx := context.Background()
 	key := fmt.Sprintf("tenant:%s", tenantID.String())
 	cache.Delete(ctx, key)
+
+	// Components read settings through the resolver
+	if tenantSettingsResolver != nil {
+		tenantSettingsResolver.Invalidate(tenantID)
+	}
 }
 
 // ============================================
 // VALIDATION
 // ============================================
 
+// reservedTenantSlugs are subdomains used by the platform itself
+var reservedTenantSlugs = []string{
+	"www", "api", "app", "admin", "go", "mail", "smtp", "status", "docs",
+	"developer", "developers", "dashboard", "cdn", "edge", "static", "domains",
+	"support", "help", "billing", "auth", "login", "signup",
+}
+
 func (s *TenantService) validateSlug(slug string) error {
 	if slug == "" {
 		return fmt.Errorf("slug is required")
@@ -573,6 +625,13 @@ func (s *TenantService) validateSlug(slug string) error {
 		return fmt.Errorf("slug must contain only lowercase letters, numbers, and hyphens")
 	}
 
+	// Slugs double as subdomains: platform hostnames can't be claimed
+	for _, reserved := range reservedTenantSlugs {
+		if slug == reserved {
+			return fmt.Errorf("slug is reserved")
+		}
+	}
+
 	// Check uniqueness
 	var count int64
 	s.db.Model(&models.Tenant{}).Where("slug = ?", slug).Count(&count)
@@ -583,6 +642,19 @@ func (s *TenantService) validateSlug(slug string) error {
 	return nil
 }
 
+// tenantFeatures returns the tenant's stored feature flags, or its plan's
+// defaults when none are stored
+func tenantFeatures(tenant *models.Tenant) models.TenantFeatures {
+	features := models.GetFeaturesForPlan(tenant.Plan)
+	if len(tenant.Features) > 0 {
+		var custom models.TenantFeatures
+		if err := json.Unmarshal(tenant.Features, &custom); err == nil {
+			features = custom
+		}
+	}
+	return features
+}
+
 // ============================================
 // AUDIT LOGGING
 // ============================================
@@ -0,0 +1,360 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // the runtime image has no zoneinfo; tenant timezones need it
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// TENANT SETTINGS RESOLVER
+// ============================================
+
+// Settings cache configuration. Entries are dropped on UpdateSettings in this
+// process; other instances pick the change up when their entry expires.
+const (
+	tenantSettingsTTL     = 30 * time.Second
+	trackingCodeTenantTTL = 10 * time.Minute
+)
+
+// Bounds applied to tenant settings before subsystems use them
+const (
+	MinLinkTTLSeconds     = 60
+	MaxWebhookRetryCount  = 20
+	MinWebhookTimeoutMs   = 1000
+	MaxWebhookTimeoutMs   = 60000
+	MaxAPIRateLimitPerMin = 10000
+
+	MaxReferralLevels         = 3
+	MaxReferralOverrideMonths = 120
+
+	MaxInvoiceReminderLeadDays = 30 // earliest reminder before the due date
+	MaxInvoiceReminderLateDays = 90
+	MaxInvoicePauseAfterDays   = 365
+)
+
+// ErrInvalidTenantSettings is returned by UpdateSettings for rejected values
+var ErrInvalidTenantSettings = errors.New("invalid tenant settings")
+
+type cachedTenantSettings struct {
+	settings *models.TenantSettings
+	location *time.Location
+	loadedAt time.Time
+}
+
+type cachedCodeTenant struct {
+	tenantID uuid.UUID
+	loadedAt time.Time
+}
+
+// TenantSettingsResolver answers "what are this tenant's settings" for the
+// click path, link signing, webhook workers, rate limiters and reports.
+// It never fails: unknown tenants and unreadable settings get the defaults.
+type TenantSettingsResolver struct {
+	db    *gorm.DB
+	cache sync.Map // tenant ID -> *cachedTenantSettings
+	codes sync.Map // tracking code -> *cachedCodeTenant
+}
+
+var (
+	tenantSettingsResolver     *TenantSettingsResolver
+	tenantSettingsResolverOnce sync.Once
+)
+
+// GetTenantSettingsResolver returns the singleton settings resolver
+func GetTenantSettingsResolver(db *gorm.DB) *TenantSettingsResolver {
+	tenantSettingsResolverOnce.Do(func() {
+		tenantSettingsResolver = NewTenantSettingsResolver(db)
+	})
+	return tenantSettingsResolver
+}
+
+// NewTenantSettingsResolver creates a new settings resolver
+func NewTenantSettingsResolver(db *gorm.DB) *TenantSettingsResolver {
+	return &TenantSettingsResolver{db: db}
+}
+
+// Get returns the effective settings of a tenant. The result is shared and
+// must not be modified.
+func (r *TenantSettingsResolver) Get(tenantID uuid.UUID) *models.TenantSettings {
+	return r.load(tenantID).settings
+}
+
+// Location returns the tenant's timezone (UTC when unset or invalid)
+func (r *TenantSettingsResolver) Location(tenantID uuid.UUID) *time.Location {
+	return r.load(tenantID).location
+}
+
+// Invalidate drops a tenant's cached settings
+func (r *TenantSettingsResolver) Invalidate(tenantID uuid.UUID) {
+	r.cache.Delete(tenantID)
+}
+
+func (r *TenantSettingsResolver) load(tenantID uuid.UUID) *cachedTenantSettings {
+	if tenantID == uuid.Nil {
+		tenantID = models.DefaultTenantID
+	}
+
+	if value, ok := r.cache.Load(tenantID); ok {
+		entry := value.(*cachedTenantSettings)
+		if time.Since(entry.loadedAt) < tenantSettingsTTL {
+			return entry
+		}
+	}
+
+	var tenant models.Tenant
+	if r.db != nil {
+		r.db.Select("id", "settings").Where("id = ?", tenantID).Limit(1).Find(&tenant)
+	}
+	settings := ParseTenantSettings(tenant.Settings)
+
+	entry := &cachedTenantSettings{
+		settings: settings,
+		location: TenantLocation(settings.Timezone),
+		loadedAt: time.Now(),
+	}
+	r.cache.Store(tenantID, entry)
+	return entry
+}
+
+// TenantForTrackingCode returns the tenant owning a click's tracking code,
+// user offer ID or offer ID (uuid.Nil when unknown)
+func (r *TenantSettingsResolver) TenantForTrackingCode(code string) uuid.UUID {
+	if i := strings.Index(code, "."); i >= 0 {
+		code = code[:i]
+	}
+	if code == "" || len(code) > 100 || r.db == nil {
+		return uuid.Nil
+	}
+
+	if value, ok := r.codes.Load(code); ok {
+		entry := value.(*cachedCodeTenant)
+		if time.Since(entry.loadedAt) < trackingCodeTenantTTL {
+			return entry.tenantID
+		}
+	}
+
+	var tenantIDs []uuid.UUID
+	if userOfferID, err := NewLinkService().ResolveTrackingCode(code); err == nil {
+		r.db.Model(&models.UserOffer{}).Where("id = ?", userOfferID).Limit(1).Pluck("tenant_id", &tenantIDs)
+	}
+	if len(tenantIDs) == 0 {
+		if id, err := uuid.Parse(code); err == nil {
+			r.db.Model(&models.UserOffer{}).Where("id = ?", id).Limit(1).Pluck("tenant_id", &tenantIDs)
+			if len(tenantIDs) == 0 {
+				r.db.Model(&models.Offer{}).Where("id = ?", id).Limit(1).Pluck("tenant_id", &tenantIDs)
+			}
+		}
+	}
+
+	tenantID := uuid.Nil
+	if len(tenantIDs) > 0 {
+		tenantID = tenantIDs[0]
+	}
+	r.codes.Store(code, &cachedCodeTenant{tenantID: tenantID, loadedAt: time.Now()})
+	return tenantID
+}
+
+// ============================================
+// PARSING & VALIDATION
+// ============================================
+
+// ParseTenantSettings decodes stored settings over the defaults, so keys a
+// tenant never set keep their default value, then normalizes the result
+func ParseTenantSettings(raw []byte) *models.TenantSettings {
+	settings := models.DefaultTenantSettings()
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &settings); err != nil {
+			settings = models.DefaultTenantSettings()
+		}
+	}
+	NormalizeTenantSettings(&settings)
+	return &settings
+}
+
+// NormalizeTenantSettings replaces unset or out-of-range values with defaults
+// or the nearest bound
+func NormalizeTenantSettings(settings *models.TenantSettings) {
+	defaults := models.DefaultTenantSettings()
+
+	if settings.DefaultLinkTTL <= 0 {
+		settings.DefaultLinkTTL = defaults.DefaultLinkTTL
+	} else if settings.DefaultLinkTTL < MinLinkTTLSeconds {
+		settings.DefaultLinkTTL = MinLinkTTLSeconds
+	}
+
+	if settings.WebhookRetryCount <= 0 {
+		settings.WebhookRetryCount = defaults.WebhookRetryCount
+	} else if settings.WebhookRetryCount > MaxWebhookRetryCount {
+		settings.WebhookRetryCount = MaxWebhookRetryCount
+	}
+
+	if settings.WebhookTimeoutMs <= 0 {
+		settings.WebhookTimeoutMs = defaults.WebhookTimeoutMs
+	} else if settings.WebhookTimeoutMs < MinWebhookTimeoutMs {
+		settings.WebhookTimeoutMs = MinWebhookTimeoutMs
+	} else if settings.WebhookTimeoutMs > MaxWebhookTimeoutMs {
+		settings.WebhookTimeoutMs = MaxWebhookTimeoutMs
+	}
+
+	if settings.APIRateLimitPerMin <= 0 {
+		settings.APIRateLimitPerMin = defaults.APIRateLimitPerMin
+	} else if settings.APIRateLimitPerMin > MaxAPIRateLimitPerMin {
+		settings.APIRateLimitPerMin = MaxAPIRateLimitPerMin
+	}
+
+	if settings.BotChallengeMinRisk <= 0 {
+		settings.BotChallengeMinRisk = defaults.BotChallengeMinRisk
+	}
+	if settings.BotChallengeMaxRisk <= 0 {
+		settings.BotChallengeMaxRisk = defaults.BotChallengeMaxRisk
+	}
+
+	if settings.Timezone == "" {
+		settings.Timezone = defaults.Timezone
+	} else if _, err := time.LoadLocation(settings.Timezone); err != nil {
+		settings.Timezone = defaults.Timezone
+	}
+
+	if ValidCurrency(settings.ReportingCurrency) {
+		settings.ReportingCurrency = strings.ToUpper(settings.ReportingCurrency)
+	} else {
+		settings.ReportingCurrency = defaults.ReportingCurrency
+	}
+
+	countries := settings.TaxProfileCountries[:0:0]
+	for _, country := range settings.TaxProfileCountries {
+		if country = NormalizeTaxCountry(country); country != "" && !containsString(countries, country) {
+			countries = append(countries, country)
+		}
+	}
+	settings.TaxProfileCountries = countries
+
+	if settings.ReferralOverrideMonths <= 0 {
+		settings.ReferralOverrideMonths = defaults.ReferralOverrideMonths
+	} else if settings.ReferralOverrideMonths > MaxReferralOverrideMonths {
+		settings.ReferralOverrideMonths = MaxReferralOverrideMonths
+	}
+	if settings.OverrideCapBps <= 0 {
+		settings.OverrideCapBps = defaults.OverrideCapBps
+	} else if settings.OverrideCapBps > MaxFeeBps {
+		settings.OverrideCapBps = MaxFeeBps
+	}
+	if len(settings.ReferralOverrideBps) > MaxReferralLevels {
+		settings.ReferralOverrideBps = settings.ReferralOverrideBps[:MaxReferralLevels]
+	}
+
+	reminders := settings.InvoiceReminderDays[:0:0]
+	for _, day := range settings.InvoiceReminderDays {
+		if day >= -MaxInvoiceReminderLeadDays && day <= MaxInvoiceReminderLateDays {
+			reminders = append(reminders, day)
+		}
+	}
+	sort.Ints(reminders)
+	settings.InvoiceReminderDays = reminders[:0]
+	for i, day := range reminders {
+		if i == 0 || day != reminders[i-1] {
+			settings.InvoiceReminderDays = append(settings.InvoiceReminderDays, day)
+		}
+	}
+	if settings.InvoicePauseAfterDays < 0 {
+		settings.InvoicePauseAfterDays = 0
+	} else if settings.InvoicePauseAfterDays > MaxInvoicePauseAfterDays {
+		settings.InvoicePauseAfterDays = MaxInvoicePauseAfterDays
+	}
+}
+
+// ValidateTenantSettings rejects settings an admin should fix rather than
+// have silently replaced
+func ValidateTenantSettings(settings *models.TenantSettings) error {
+	if settings.Timezone != "" {
+		if _, err := time.LoadLocation(settings.Timezone); err != nil {
+			return fmt.Errorf("unknown timezone %q", settings.Timezone)
+		}
+	}
+	if settings.ReportingCurrency != "" && !ValidCurrency(settings.ReportingCurrency) {
+		return fmt.Errorf("unknown reporting currency %q", settings.ReportingCurrency)
+	}
+	for _, country := range settings.TaxProfileCountries {
+		if country = NormalizeTaxCountry(country); country != "*" && !validCountryCode(country) {
+			return fmt.Errorf("tax_profile_countries: %q is not an ISO country code", country)
+		}
+	}
+	if len(settings.ReferralOverrideBps) > MaxReferralLevels {
+		return fmt.Errorf("referral_override_bps allows at most %d levels", MaxReferralLevels)
+	}
+	for _, bps := range append([]int64{settings.TeamOverrideBps, settings.OverrideCapBps}, settings.ReferralOverrideBps...) {
+		if bps < 0 || bps > MaxFeeBps {
+			return fmt.Errorf("override rates must be between 0 and %d basis points", MaxFeeBps)
+		}
+	}
+	if settings.ReferralOverrideMonths < 0 || settings.ReferralOverrideMonths > MaxReferralOverrideMonths {
+		return fmt.Errorf("referral_override_months must be between 1 and %d", MaxReferralOverrideMonths)
+	}
+	for _, day := range settings.InvoiceReminderDays {
+		if day < -MaxInvoiceReminderLeadDays || day > MaxInvoiceReminderLateDays {
+			return fmt.Errorf("invoice_reminder_days must be between -%d and %d", MaxInvoiceReminderLeadDays, MaxInvoiceReminderLateDays)
+		}
+	}
+	if settings.InvoicePauseAfterDays < 0 || settings.InvoicePauseAfterDays > MaxInvoicePauseAfterDays {
+		return fmt.Errorf("invoice_pause_after_days must be between 0 and %d", MaxInvoicePauseAfterDays)
+	}
+	if settings.DefaultLinkTTL < 0 || settings.WebhookRetryCount < 0 ||
+		settings.WebhookTimeoutMs < 0 || settings.APIRateLimitPerMin < 0 {
+		return fmt.Errorf("settings must not be negative")
+	}
+	if settings.WebhookRetryCount > MaxWebhookRetryCount {
+		return fmt.Errorf("webhook_retry_count must be at most %d", MaxWebhookRetryCount)
+	}
+	if settings.WebhookTimeoutMs > MaxWebhookTimeoutMs {
+		return fmt.Errorf("webhook_timeout_ms must be at most %d", MaxWebhookTimeoutMs)
+	}
+	if settings.APIRateLimitPerMin > MaxAPIRateLimitPerMin {
+		return fmt.Errorf("api_rate_limit_per_min must be at most %d", MaxAPIRateLimitPerMin)
+	}
+	return nil
+}
+
+// ============================================
+// TIMEZONE HELPERS
+// ============================================
+
+// TenantLocation loads a timezone name, falling back to UTC
+func TenantLocation(name string) *time.Location {
+	if name == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// StartOfDayIn returns midnight of t's calendar day in loc
+func StartOfDayIn(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+}
+
+// StartOfMonthIn returns midnight of the first day of t's month in loc
+func StartOfMonthIn(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc)
+}
+
+// DayIn returns t's calendar date in loc as a UTC-midnight date, the form
+// stored in date columns
+func DayIn(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+}
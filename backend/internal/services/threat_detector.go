@@ -538,6 +538,9 @@ type ThreatDetector struct {
 	// Metrics
 	totalThreats     int64
 	threatsByType    map[ThreatType]int64
+	
+	// Listeners notified for every recorded threat (e.g. fraud case intake)
+	listeners        []func(*ThreatEvent)
 }
 
 // NewThreatDetector creates a new threat detector
@@ -584,11 +587,22 @@ func (t *ThreatDetector) RecordThreat(threat *ThreatEvent) {
 	t.recordThreat(threat)
 }
 
+// OnThreat registers a listener called asynchronously for every recorded threat
+func (t *ThreatDetector) OnThreat(listener func(*ThreatEvent)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.listeners = append(t.listeners, listener)
+}
+
 // recordThreat internal method to record threat
 func (t *ThreatDetector) recordThreat(threat *ThreatEvent) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
+	for _, listener := range t.listeners {
+		go listener(threat)
+	}
+
 	atomic.AddInt64(&t.totalThreats, 1)
 	t.threatsByType[threat.Type]++
 
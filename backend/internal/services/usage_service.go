@@ -0,0 +1,545 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ============================================
+// USAGE METERING & PLAN ENFORCEMENT
+// ============================================
+
+// Metering configuration
+const (
+	UsageFlushInterval   = 30 * time.Second
+	UsageStorageInterval = 6 * time.Hour
+	usageTotalsTTL       = time.Minute
+	usageDefaultRowBytes = 512 // when pg_class has no estimate (new or partitioned tables)
+	usageDayLayout       = "2006-01-02"
+)
+
+// usageStorageTables are the tenant tables counted towards storage
+var usageStorageTables = []string{
+	"afftok_users",
+	"offers",
+	"user_offers",
+	"clicks",
+	"conversions",
+	"advertiser_api_keys",
+	"webhook_pipelines",
+}
+
+// ErrUsageLimitExceeded is returned (wrapped in UsageLimitError) when a
+// creation would go past the tenant's hard limit
+var ErrUsageLimitExceeded = errors.New("plan limit exceeded")
+
+// UsageLimitError carries the limit check that blocked a creation
+type UsageLimitError struct {
+	Check *models.LimitCheck
+}
+
+func (e *UsageLimitError) Error() string {
+	return fmt.Sprintf("%s limit reached (%d of %d allowed on current plan)",
+		e.Check.Resource, e.Check.Used, e.Check.HardLimit)
+}
+
+func (e *UsageLimitError) Unwrap() error {
+	return ErrUsageLimitExceeded
+}
+
+type usageKey struct {
+	tenantID uuid.UUID
+	day      string
+}
+
+type usageTotals struct {
+	row      models.TenantUsageDaily
+	loadedAt time.Time
+}
+
+// UsageService records per-tenant daily usage and enforces plan limits.
+// Counters are buffered in memory and flushed with an upsert; reads add the
+// unflushed part to the last totals loaded from the database.
+type UsageService struct {
+	db       *gorm.DB
+	tenants  *TenantService
+	settings *TenantSettingsResolver
+
+	mu      sync.Mutex
+	pending map[usageKey]*models.TenantUsageDaily
+	totals  map[usageKey]*usageTotals
+
+	alerted  sync.Map // tenant|resource|status|day -> struct{}
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+var (
+	usageService     *UsageService
+	usageServiceOnce sync.Once
+)
+
+// GetUsageService returns the singleton usage service
+func GetUsageService(db *gorm.DB) *UsageService {
+	usageServiceOnce.Do(func() {
+		usageService = NewUsageService(db)
+	})
+	return usageService
+}
+
+// NewUsageService creates a new usage service
+func NewUsageService(db *gorm.DB) *UsageService {
+	return &UsageService{
+		db:       db,
+		tenants:  NewTenantService(db),
+		settings: GetTenantSettingsResolver(db),
+		pending:  make(map[usageKey]*models.TenantUsageDaily),
+		totals:   make(map[usageKey]*usageTotals),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+func usageDay(t time.Time) string {
+	return t.UTC().Format(usageDayLayout)
+}
+
+// tenantDay returns t's calendar day in the tenant's timezone, so daily
+// buckets and the daily click limit roll over at the tenant's midnight
+func (s *UsageService) tenantDay(tenantID uuid.UUID, t time.Time) string {
+	return DayIn(t, s.settings.Location(tenantID)).Format(usageDayLayout)
+}
+
+// ============================================
+// RECORDING
+// ============================================
+
+// Record adds n to a tenant's metric for today
+func (s *UsageService) Record(tenantID uuid.UUID, metric models.UsageMetric, n int64) {
+	if tenantID == uuid.Nil {
+		tenantID = models.DefaultTenantID
+	}
+
+	key := usageKey{tenantID: tenantID, day: s.tenantDay(tenantID, time.Now())}
+
+	s.mu.Lock()
+	row, ok := s.pending[key]
+	if !ok {
+		row = &models.TenantUsageDaily{TenantID: tenantID}
+		s.pending[key] = row
+	}
+	row.Add(metric, n)
+	s.mu.Unlock()
+}
+
+// Flush writes buffered counters to tenant_usage_daily
+func (s *UsageService) Flush() error {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = make(map[usageKey]*models.TenantUsageDaily)
+	s.mu.Unlock()
+
+	var firstErr error
+	for key, row := range pending {
+		day, _ := time.Parse(usageDayLayout, key.day)
+		record := &models.TenantUsageDaily{
+			ID:                uuid.New(),
+			TenantID:          key.tenantID,
+			Date:              day,
+			Clicks:            row.Clicks,
+			Conversions:       row.Conversions,
+			APICalls:          row.APICalls,
+			WebhookDeliveries: row.WebhookDeliveries,
+		}
+
+		err := s.db.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "tenant_id"}, {Name: "date"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"clicks":             gorm.Expr("tenant_usage_daily.clicks + EXCLUDED.clicks"),
+				"conversions":        gorm.Expr("tenant_usage_daily.conversions + EXCLUDED.conversions"),
+				"api_calls":          gorm.Expr("tenant_usage_daily.api_calls + EXCLUDED.api_calls"),
+				"webhook_deliveries": gorm.Expr("tenant_usage_daily.webhook_deliveries + EXCLUDED.webhook_deliveries"),
+				"updated_at":         time.Now(),
+			}),
+		}).Create(record).Error
+
+		s.mu.Lock()
+		if err != nil {
+			// Keep the counts for the next flush
+			merged, ok := s.pending[key]
+			if !ok {
+				merged = &models.TenantUsageDaily{TenantID: key.tenantID}
+				s.pending[key] = merged
+			}
+			merged.Clicks += row.Clicks
+			merged.Conversions += row.Conversions
+			merged.APICalls += row.APICalls
+			merged.WebhookDeliveries += row.WebhookDeliveries
+			if firstErr == nil {
+				firstErr = err
+			}
+		} else if totals, ok := s.totals[key]; ok {
+			// Flushed counts move from pending into the loaded totals
+			totals.row.Clicks += row.Clicks
+			totals.row.Conversions += row.Conversions
+			totals.row.APICalls += row.APICalls
+			totals.row.WebhookDeliveries += row.WebhookDeliveries
+		}
+		s.mu.Unlock()
+	}
+
+	// Drop totals for past days
+	now := time.Now()
+	s.mu.Lock()
+	keys := make([]usageKey, 0, len(s.totals))
+	for key := range s.totals {
+		keys = append(keys, key)
+	}
+	s.mu.Unlock()
+	for _, key := range keys {
+		if key.day != s.tenantDay(key.tenantID, now) {
+			s.mu.Lock()
+			delete(s.totals, key)
+			s.mu.Unlock()
+		}
+	}
+
+	return firstErr
+}
+
+// DailyUsage returns today's value of a metric, including unflushed counts
+func (s *UsageService) DailyUsage(tenantID uuid.UUID, metric models.UsageMetric) int64 {
+	row := s.today(tenantID)
+	return row.Get(metric)
+}
+
+// today returns today's usage row: last loaded totals plus pending counts.
+// Totals are reloaded every usageTotalsTTL so other instances' flushes count.
+func (s *UsageService) today(tenantID uuid.UUID) models.TenantUsageDaily {
+	key := usageKey{tenantID: tenantID, day: s.tenantDay(tenantID, time.Now())}
+
+	s.mu.Lock()
+	totals, ok := s.totals[key]
+	s.mu.Unlock()
+
+	if !ok || time.Since(totals.loadedAt) > usageTotalsTTL {
+		var row models.TenantUsageDaily
+		day, _ := time.Parse(usageDayLayout, key.day)
+		s.db.Where("tenant_id = ? AND date = ?", tenantID, day).Limit(1).Find(&row)
+
+		totals = &usageTotals{row: row, loadedAt: time.Now()}
+		s.mu.Lock()
+		s.totals[key] = totals
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	row := totals.row
+	row.TenantID = tenantID
+	if pending, ok := s.pending[key]; ok {
+		row.Clicks += pending.Clicks
+		row.Conversions += pending.Conversions
+		row.APICalls += pending.APICalls
+		row.WebhookDeliveries += pending.WebhookDeliveries
+	}
+	return row
+}
+
+// ============================================
+// STORAGE SNAPSHOT
+// ============================================
+
+// SnapshotStorage estimates each tenant's stored bytes (row count × average
+// row size per table) and writes it to today's usage row
+func (s *UsageService) SnapshotStorage() error {
+	perTenant := make(map[uuid.UUID]int64)
+
+	for _, table := range usageStorageTables {
+		var avgRowBytes int64
+		s.db.Raw(`SELECT CASE WHEN c.reltuples > 0
+				THEN (pg_total_relation_size(c.oid) / c.reltuples)::bigint ELSE 0 END
+			FROM pg_class c WHERE c.relname = ? LIMIT 1`, table).Scan(&avgRowBytes)
+		if avgRowBytes <= 0 {
+			avgRowBytes = usageDefaultRowBytes
+		}
+
+		var counts []struct {
+			TenantID uuid.UUID
+			Rows     int64
+		}
+		if err := s.db.Table(table).
+			Select("tenant_id, COUNT(*) AS rows").
+			Group("tenant_id").
+			Scan(&counts).Error; err != nil {
+			return fmt.Errorf("count %s: %w", table, err)
+		}
+		for _, c := range counts {
+			perTenant[c.TenantID] += c.Rows * avgRowBytes
+		}
+	}
+
+	now := time.Now()
+	for tenantID, bytes := range perTenant {
+		day, _ := time.Parse(usageDayLayout, s.tenantDay(tenantID, now))
+		record := &models.TenantUsageDaily{
+			ID:           uuid.New(),
+			TenantID:     tenantID,
+			Date:         day,
+			StorageBytes: bytes,
+		}
+		if err := s.db.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "tenant_id"}, {Name: "date"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"storage_bytes": bytes,
+				"updated_at":    time.Now(),
+			}),
+		}).Create(record).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Start flushes counters and snapshots storage in the background
+func (s *UsageService) Start() {
+	go func() {
+		flush := time.NewTicker(UsageFlushInterval)
+		storage := time.NewTicker(UsageStorageInterval)
+		defer flush.Stop()
+		defer storage.Stop()
+
+		for {
+			select {
+			case <-flush.C:
+				if err := s.Flush(); err != nil {
+					log.Printf("[Usage] flush failed: %v", err)
+				}
+			case <-storage.C:
+				if err := s.SnapshotStorage(); err != nil {
+					log.Printf("[Usage] storage snapshot failed: %v", err)
+				}
+			case <-s.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background loop and flushes what is buffered
+func (s *UsageService) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopCh)
+		if err := s.Flush(); err != nil {
+			log.Printf("[Usage] final flush failed: %v", err)
+		}
+	})
+}
+
+// ============================================
+// LIMITS
+// ============================================
+
+// CheckLimit checks whether adding more of a resource fits the tenant's plan
+func (s *UsageService) CheckLimit(tenantID uuid.UUID, resource models.LimitResource, adding int64) (*models.LimitCheck, error) {
+	if tenantID == uuid.Nil {
+		tenantID = models.DefaultTenantID
+	}
+	tenant, err := s.tenants.GetTenant(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return s.evaluateLimit(s.db, tenant, resource, adding), nil
+}
+
+// evaluateLimit counts a tenant's resources on db and checks them against its plan
+func (s *UsageService) evaluateLimit(db *gorm.DB, tenant *models.Tenant, resource models.LimitResource, adding int64) *models.LimitCheck {
+	check := models.EvaluateLimit(resource, s.countResource(db, tenant.ID, resource), adding,
+		limitForResource(tenant, resource), tenant.LimitGracePercent)
+	if adding > 0 && check.Status != models.LimitStatusOK {
+		s.alert(tenant.ID, check)
+	}
+	return check
+}
+
+// Enforce returns a UsageLimitError when creating one more of a resource
+// would pass the tenant's hard limit. The count is not locked, so concurrent
+// creations may pass the limit together; use EnforceTx around the insert.
+func (s *UsageService) Enforce(tenantID uuid.UUID, resource models.LimitResource) error {
+	check, err := s.CheckLimit(tenantID, resource, 1)
+	if err != nil {
+		return err
+	}
+	if !check.Allowed {
+		return &UsageLimitError{Check: check}
+	}
+	return nil
+}
+
+// EnforceTx is Enforce inside the transaction that creates the resource. It
+// locks the tenant row before counting, so creations of the same tenant are
+// counted one after the other and cannot both take the last free slot.
+func (s *UsageService) EnforceTx(tx *gorm.DB, tenantID uuid.UUID, resource models.LimitResource) error {
+	if tenantID == uuid.Nil {
+		tenantID = models.DefaultTenantID
+	}
+	var tenant models.Tenant
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&tenant, "id = ?", tenantID).Error; err != nil {
+		return fmt.Errorf("failed to lock tenant: %w", err)
+	}
+	if check := s.evaluateLimit(tx, &tenant, resource, 1); !check.Allowed {
+		return &UsageLimitError{Check: check}
+	}
+	return nil
+}
+
+// AllowClick reports whether the tenant can record another click today.
+// Lookup failures fail open so tracking never stops on a cache miss.
+func (s *UsageService) AllowClick(tenantID uuid.UUID) bool {
+	check, err := s.CheckLimit(tenantID, models.LimitResourceClicksPerDay, 1)
+	if err != nil {
+		return true
+	}
+	return check.Allowed
+}
+
+// GetLimits returns every limit with current usage
+func (s *UsageService) GetLimits(tenantID uuid.UUID) ([]*models.LimitCheck, error) {
+	limits := make([]*models.LimitCheck, 0, len(models.LimitResources))
+	for _, resource := range models.LimitResources {
+		check, err := s.CheckLimit(tenantID, resource, 0)
+		if err != nil {
+			return nil, err
+		}
+		limits = append(limits, check)
+	}
+	return limits, nil
+}
+
+func limitForResource(tenant *models.Tenant, resource models.LimitResource) int64 {
+	switch resource {
+	case models.LimitResourceUsers:
+		return int64(tenant.MaxUsers)
+	case models.LimitResourceOffers:
+		return int64(tenant.MaxOffers)
+	case models.LimitResourceAPIKeys:
+		return int64(tenant.MaxAPIKeys)
+	case models.LimitResourceWebhooks:
+		return int64(tenant.MaxWebhooks)
+	case models.LimitResourceClicksPerDay:
+		return int64(tenant.MaxClicksPerDay)
+	}
+	return 0
+}
+
+func (s *UsageService) countResource(db *gorm.DB, tenantID uuid.UUID, resource models.LimitResource) int64 {
+	var count int64
+	switch resource {
+	case models.LimitResourceUsers:
+		db.Model(&models.AfftokUser{}).Where("tenant_id = ?", tenantID).Count(&count)
+	case models.LimitResourceOffers:
+		db.Model(&models.Offer{}).Where("tenant_id = ?", tenantID).Count(&count)
+	case models.LimitResourceAPIKeys:
+		db.Model(&models.AdvertiserAPIKey{}).
+			Where("tenant_id = ? AND status = ?", tenantID, models.APIKeyStatusActive).
+			Count(&count)
+	case models.LimitResourceWebhooks:
+		db.Model(&models.WebhookPipeline{}).
+			Where("tenant_id = ? AND status = ?", tenantID, models.WebhookPipelineStatusActive).
+			Count(&count)
+	case models.LimitResourceClicksPerDay:
+		count = s.DailyUsage(tenantID, models.UsageMetricClicks)
+	}
+	return count
+}
+
+// alert logs and audits the first soft/hard limit breach per resource per day
+func (s *UsageService) alert(tenantID uuid.UUID, check *models.LimitCheck) {
+	key := fmt.Sprintf("%s|%s|%s|%s", tenantID, check.Resource, check.Status, s.tenantDay(tenantID, time.Now()))
+	if _, seen := s.alerted.LoadOrStore(key, struct{}{}); seen {
+		return
+	}
+
+	action := models.TenantAuditUsageWarning
+	if check.Status == models.LimitStatusExceeded {
+		action = models.TenantAuditUsageExceeded
+	}
+	log.Printf("[Usage] tenant %s %s: %s %d/%d (hard %d)",
+		tenantID, check.Status, check.Resource, check.Used, check.Limit, check.HardLimit)
+	s.tenants.logAudit(tenantID, action, nil, nil, check)
+}
+
+// ============================================
+// HISTORY
+// ============================================
+
+// UsageHistory is a tenant's daily usage over a date range
+type UsageHistory struct {
+	TenantID uuid.UUID                 `json:"tenant_id"`
+	From     string                    `json:"from"`
+	To       string                    `json:"to"`
+	Days     []models.TenantUsageDaily `json:"days"`
+	Totals   models.TenantUsageDaily   `json:"totals"` // storage_bytes is the latest snapshot
+}
+
+// GetHistory returns daily usage between from and to (inclusive calendar
+// days; today is the tenant's local day)
+func (s *UsageService) GetHistory(tenantID uuid.UUID, from, to time.Time) (*UsageHistory, error) {
+	fromDay, _ := time.Parse(usageDayLayout, usageDay(from))
+	toDay, _ := time.Parse(usageDayLayout, usageDay(to))
+
+	var days []models.TenantUsageDaily
+	if err := s.db.Where("tenant_id = ? AND date >= ? AND date <= ?", tenantID, fromDay, toDay).
+		Order("date ASC").
+		Find(&days).Error; err != nil {
+		return nil, err
+	}
+
+	// Today's row includes counts not flushed yet
+	today := s.tenantDay(tenantID, time.Now())
+	if today >= usageDay(fromDay) && today <= usageDay(toDay) {
+		current := s.today(tenantID)
+		replaced := false
+		for i := range days {
+			if usageDay(days[i].Date) == today {
+				current.ID = days[i].ID
+				current.Date = days[i].Date
+				current.StorageBytes = days[i].StorageBytes
+				current.UpdatedAt = days[i].UpdatedAt
+				days[i] = current
+				replaced = true
+			}
+		}
+		if !replaced && (current.Clicks+current.Conversions+current.APICalls+current.WebhookDeliveries) > 0 {
+			current.Date, _ = time.Parse(usageDayLayout, today)
+			days = append(days, current)
+		}
+	}
+
+	history := &UsageHistory{
+		TenantID: tenantID,
+		From:     usageDay(fromDay),
+		To:       usageDay(toDay),
+		Days:     days,
+		Totals:   models.TenantUsageDaily{TenantID: tenantID},
+	}
+	for _, day := range days {
+		history.Totals.Clicks += day.Clicks
+		history.Totals.Conversions += day.Conversions
+		history.Totals.APICalls += day.APICalls
+		history.Totals.WebhookDeliveries += day.WebhookDeliveries
+		if day.StorageBytes > 0 {
+			history.Totals.StorageBytes = day.StorageBytes
+		}
+	}
+
+	return history, nil
+}
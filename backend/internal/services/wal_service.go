@@ -514,6 +514,72 @@ func (w *WALService) Compact() error {
 	return nil
 }
 
+// PurgeTenant removes every entry belonging to a tenant from the WAL files
+// (tenant hard delete). The current file is closed while files are rewritten
+// and a new one is opened afterwards. Lines that cannot be parsed are kept.
+func (w *WALService) PurgeTenant(tenantID string) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.currentFile.Sync(); err != nil {
+		return 0, err
+	}
+	if err := w.currentFile.Close(); err != nil {
+		return 0, err
+	}
+	defer w.initCurrentFile()
+
+	files, err := w.listWALFiles()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return removed, err
+		}
+
+		var kept []byte
+		fileRemoved := 0
+		for _, line := range strings.Split(string(data), "\n") {
+			if line == "" {
+				continue
+			}
+			var entry struct {
+				TenantID string `json:"tenant_id"`
+			}
+			if json.Unmarshal([]byte(line), &entry) == nil && entry.TenantID == tenantID {
+				fileRemoved++
+				continue
+			}
+			kept = append(kept, line...)
+			kept = append(kept, '\n')
+		}
+		if fileRemoved == 0 {
+			continue
+		}
+
+		if len(kept) == 0 {
+			if err := os.Remove(file); err != nil {
+				return removed, err
+			}
+		} else {
+			tmp := file + ".tmp"
+			if err := os.WriteFile(tmp, kept, 0644); err != nil {
+				return removed, err
+			}
+			if err := os.Rename(tmp, file); err != nil {
+				return removed, err
+			}
+		}
+		removed += fileRemoved
+	}
+
+	return removed, nil
+}
+
 // ============================================
 // LIFECYCLE
 // ============================================
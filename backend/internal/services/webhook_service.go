@@ -63,6 +63,13 @@ func (s *WebhookService) CreatePipeline(pipeline *models.WebhookPipeline) error
 		pipeline.ID = uuid.New()
 	}
 
+	// Active pipelines count against the tenant's webhook limit
+	if pipeline.Status == models.WebhookPipelineStatusActive {
+		if err := GetUsageService(s.db).Enforce(pipeline.TenantID, models.LimitResourceWebhooks); err != nil {
+			return err
+		}
+	}
+
 	// Validate steps
 	for i := range pipeline.Steps {
 		if pipeline.Steps[i].ID == uuid.Nil {
@@ -77,6 +84,18 @@ func (s *WebhookService) CreatePipeline(pipeline *models.WebhookPipeline) error
 
 // UpdatePipeline updates an existing pipeline
 func (s *WebhookService) UpdatePipeline(pipeline *models.WebhookPipeline) error {
+	var existing models.WebhookPipeline
+	if err := s.db.Select("id", "tenant_id", "status").First(&existing, "id = ?", pipeline.ID).Error; err != nil {
+		return err
+	}
+	pipeline.TenantID = existing.TenantID
+
+	if pipeline.Status == models.WebhookPipelineStatusActive && existing.Status != models.WebhookPipelineStatusActive {
+		if err := GetUsageService(s.db).Enforce(pipeline.TenantID, models.LimitResourceWebhooks); err != nil {
+			return err
+		}
+	}
+
 	return s.db.Transaction(func(tx *gorm.DB) error {
 		// Update pipeline
 		if err := tx.Save(pipeline).Error; err != nil {
@@ -28,6 +28,7 @@ type WebhookWorkerPool struct {
 	signingService  *WebhookSigningService
 	templateEngine  *TemplateEngine
 	observability   *ObservabilityService
+	settings        *TenantSettingsResolver
 	httpClient      *http.Client
 
 	// Worker counts
@@ -70,8 +71,10 @@ func NewWebhookWorkerPool(db *gorm.DB) *WebhookWorkerPool {
 		signingService:  NewWebhookSigningService(),
 		templateEngine:  NewTemplateEngine(),
 		observability:   NewObservabilityService(),
+		settings:        GetTenantSettingsResolver(db),
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			// Upper bound only: each request runs under the tenant's step timeout
+			Timeout: time.Duration(MaxWebhookTimeoutMs) * time.Millisecond,
 			Transport: &http.Transport{
 				MaxIdleConns:        100,
 				MaxIdleConnsPerHost: 10,
@@ -244,9 +247,10 @@ func (p *WebhookWorkerPool) processTask(task *models.WebhookTask) {
 	if err := p.db.Preload("Steps", func(db *gorm.DB) *gorm.DB {
 		return db.Order("step_order ASC")
 	}).First(&pipeline, "id = ?", task.PipelineID).Error; err != nil {
-		p.handleTaskError(task, fmt.Errorf("pipeline not found: %w", err))
+		p.handleTaskError(task, DefaultRetryPolicy(), fmt.Errorf("pipeline not found: %w", err))
 		return
 	}
+	settings := p.settings.Get(pipeline.TenantID)
 
 	// Get or create execution
 	var execution models.WebhookExecution
@@ -279,7 +283,9 @@ func (p *WebhookWorkerPool) processTask(task *models.WebhookTask) {
 	for i := task.StepIndex; i < len(pipeline.Steps); i++ {
 		step := pipeline.Steps[i]
 		
+		step.TimeoutMs = WebhookStepTimeoutMs(step.TimeoutMs, settings)
 		stepResult := p.executeStep(&step, ctx, task, i)
+		GetUsageService(p.db).Record(pipeline.TenantID, models.UsageMetricWebhookDeliveries, 1)
 		
 		// Store step result
 		p.storeStepResult(&execution, &step, stepResult, i, task.Attempts)
@@ -318,7 +324,7 @@ func (p *WebhookWorkerPool) processTask(task *models.WebhookTask) {
 			DurationMs:    durationMs,
 		})
 	} else {
-		p.handleTaskError(task, fmt.Errorf(task.LastError))
+		p.handleTaskError(task, WebhookRetryPolicy(settings), fmt.Errorf(task.LastError))
 	}
 }
 
@@ -339,7 +345,7 @@ func (p *WebhookWorkerPool) processFailoverTask(task *models.WebhookTask) {
 		failoverStep := models.WebhookStep{
 			URL:           pipeline.FailoverURL,
 			Method:        models.WebhookMethodPOST,
-			TimeoutMs:     pipeline.TimeoutMs,
+			TimeoutMs:     WebhookStepTimeoutMs(pipeline.TimeoutMs, p.settings.Get(pipeline.TenantID)),
 			SignatureMode: models.WebhookSignatureHMAC,
 		}
 
@@ -579,8 +585,24 @@ func (p *WebhookWorkerPool) buildTemplateContext(task *models.WebhookTask) *Temp
 	return ctx
 }
 
+// WebhookStepTimeoutMs returns the timeout of a step: its own timeout, capped
+// by the tenant's WebhookTimeoutMs, which also applies when the step has none
+func WebhookStepTimeoutMs(stepTimeoutMs int, settings *models.TenantSettings) int {
+	if stepTimeoutMs <= 0 || stepTimeoutMs > settings.WebhookTimeoutMs {
+		return settings.WebhookTimeoutMs
+	}
+	return stepTimeoutMs
+}
+
+// WebhookRetryPolicy returns the retry policy with the tenant's attempt count
+func WebhookRetryPolicy(settings *models.TenantSettings) *RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.MaxAttempts = settings.WebhookRetryCount
+	return policy
+}
+
 // handleTaskError handles a task error
-func (p *WebhookWorkerPool) handleTaskError(task *models.WebhookTask, err error) {
+func (p *WebhookWorkerPool) handleTaskError(task *models.WebhookTask, policy *RetryPolicy, err error) {
 	atomic.AddInt64(&p.metrics.TasksFailed, 1)
 	task.LastError = err.Error()
 
@@ -594,7 +616,6 @@ func (p *WebhookWorkerPool) handleTaskError(task *models.WebhookTask, err error)
 		})
 
 	// Schedule retry
-	policy := DefaultRetryPolicy()
 	if err := p.queueService.ScheduleRetry(task, policy); err != nil {
 		// Retry scheduling failed, move to DLQ
 		p.queueService.EnqueueDLQ(task)
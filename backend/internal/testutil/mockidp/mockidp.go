@@ -0,0 +1,351 @@
+// Package mockidp is a local OIDC + SAML identity provider for tests. Mount
+// it on an httptest server, set Issuer to its base URL and point a tenant's
+// SSO configuration at it:
+//
+//	OIDC: issuer = Issuer, client_id / client_secret as given
+//	SAML: idp_sso_url = Issuer + "/saml/sso", idp_entity_id = Issuer,
+//	      idp_certificate = CertificatePEM()
+//
+// Every login signs in as User without prompting.
+package mockidp
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"html"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/xmldsig"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "mock-idp-1"
+
+// SAML namespaces and values the IdP writes
+const (
+	samlProtocolNamespace  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	samlAssertionNamespace = "urn:oasis:names:tc:SAML:2.0:assertion"
+	samlStatusSuccess      = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	samlBearer             = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	samlNameIDEmail        = "urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress"
+)
+
+// IdP is an in-process identity provider
+type IdP struct {
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	User          models.SSOIdentity
+	EmailVerified bool
+	SignResponse  bool // sign the SAML Response instead of the Assertion
+
+	Key         *rsa.PrivateKey
+	Certificate *x509.Certificate
+
+	mu    sync.Mutex
+	codes map[string]authCode
+}
+
+type authCode struct {
+	redirectURI string
+	challenge   string
+	nonce       string
+}
+
+// New creates an IdP with a fresh signing key
+func New(clientID, clientSecret string) (*IdP, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "AffTok Mock IdP"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IdP{
+		ClientID:      clientID,
+		ClientSecret:  clientSecret,
+		EmailVerified: true,
+		User: models.SSOIdentity{
+			Subject: "mock-user-1",
+			Email:   "jane@example.com",
+			Name:    "Jane Doe",
+		},
+		Key:         key,
+		Certificate: cert,
+		codes:       make(map[string]authCode),
+	}, nil
+}
+
+// CertificatePEM returns the SAML signing certificate
+func (m *IdP) CertificatePEM() string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: m.Certificate.Raw}))
+}
+
+// ServeHTTP implements http.Handler
+func (m *IdP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		m.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                                m.Issuer,
+			"authorization_endpoint":                m.Issuer + "/authorize",
+			"token_endpoint":                        m.Issuer + "/token",
+			"jwks_uri":                              m.Issuer + "/jwks",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+			"code_challenge_methods_supported":      []string{"S256"},
+		})
+	case "/jwks":
+		pub := m.Key.PublicKey
+		m.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": keyID,
+				"kty": "RSA",
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			}},
+		})
+	case "/authorize":
+		m.authorize(w, r)
+	case "/token":
+		m.token(w, r)
+	case "/saml/sso":
+		m.samlSSO(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (m *IdP) writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func (m *IdP) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirectURI := q.Get("redirect_uri")
+	if q.Get("client_id") != m.ClientID || redirectURI == "" || q.Get("response_type") != "code" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "PKCE S256 required", http.StatusBadRequest)
+		return
+	}
+
+	code := randomToken()
+	m.mu.Lock()
+	m.codes[code] = authCode{redirectURI: redirectURI, challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	m.mu.Unlock()
+
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	query := target.Query()
+	query.Set("code", code)
+	query.Set("state", q.Get("state"))
+	target.RawQuery = query.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+func (m *IdP) token(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, _ := r.BasicAuth()
+	clientID, _ = url.QueryUnescape(clientID)
+	clientSecret, _ = url.QueryUnescape(clientSecret)
+	if r.Method != http.MethodPost || clientID != m.ClientID || clientSecret != m.ClientSecret {
+		m.writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	r.ParseForm()
+
+	m.mu.Lock()
+	grant, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" ||
+		r.PostForm.Get("redirect_uri") != grant.redirectURI ||
+		pkceChallenge(r.PostForm.Get("code_verifier")) != grant.challenge {
+		m.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken, err := m.IDToken(grant.nonce, m.ClientID)
+	if err != nil {
+		m.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	m.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomToken(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// IDToken signs an ID token for User
+func (m *IdP) IDToken(nonce, audience string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            m.Issuer,
+		"sub":            m.User.Subject,
+		"aud":            audience,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          nonce,
+		"email":          m.User.Email,
+		"email_verified": m.EmailVerified,
+		"name":           m.User.Name,
+		"groups":         m.User.Groups,
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	return token.SignedString(m.Key)
+}
+
+// samlSSO answers a redirect-binding AuthnRequest with an auto-posting form
+func (m *IdP) samlSSO(w http.ResponseWriter, r *http.Request) {
+	raw, err := base64.StdEncoding.DecodeString(r.URL.Query().Get("SAMLRequest"))
+	if err != nil {
+		http.Error(w, "invalid SAMLRequest", http.StatusBadRequest)
+		return
+	}
+	inflated, err := io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(raw)), xmldsig.MaxDocumentLen))
+	if err != nil {
+		http.Error(w, "invalid SAMLRequest", http.StatusBadRequest)
+		return
+	}
+	request, err := xmldsig.Parse(inflated)
+	if err != nil || !request.Is(samlProtocolNamespace, "AuthnRequest") {
+		http.Error(w, "invalid SAMLRequest", http.StatusBadRequest)
+		return
+	}
+	issuer := request.Child(samlAssertionNamespace, "Issuer")
+	if issuer == nil {
+		http.Error(w, "AuthnRequest has no issuer", http.StatusBadRequest)
+		return
+	}
+
+	acsURL := request.Attr("AssertionConsumerServiceURL")
+	response, err := m.SAMLResponse(acsURL, strings.TrimSpace(issuer.Text()), request.Attr("ID"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<!DOCTYPE html><html><body onload="document.forms[0].submit()"><form method="POST" action="%s"><input type="hidden" name="SAMLResponse" value="%s"><input type="hidden" name="RelayState" value="%s"><noscript><button type="submit">Continue</button></noscript></form></body></html>`,
+		html.EscapeString(acsURL), html.EscapeString(response), html.EscapeString(r.URL.Query().Get("RelayState")))
+}
+
+// SAMLResponse builds a signed, base64-encoded SAML response for User
+func (m *IdP) SAMLResponse(acsURL, audience, inResponseTo string) (string, error) {
+	now := time.Now().UTC()
+	instant := now.Format(time.RFC3339)
+	notOnOrAfter := now.Add(5 * time.Minute).Format(time.RFC3339)
+	a := xmldsig.EscapeAttr
+
+	var attributes strings.Builder
+	attribute := func(name string, values ...string) {
+		if len(values) == 0 {
+			return
+		}
+		attributes.WriteString(`<saml:Attribute Name="` + a(name) + `">`)
+		for _, v := range values {
+			attributes.WriteString(`<saml:AttributeValue>` + xmldsig.EscapeText(v) + `</saml:AttributeValue>`)
+		}
+		attributes.WriteString(`</saml:Attribute>`)
+	}
+	attribute("email", m.User.Email)
+	attribute("name", m.User.Name)
+	attribute("groups", m.User.Groups...)
+
+	document := fmt.Sprintf(`<samlp:Response xmlns:samlp="%s" xmlns:saml="%s" ID="%s" Version="2.0" IssueInstant="%s" Destination="%s" InResponseTo="%s">`+
+		`<saml:Issuer>%s</saml:Issuer>`+
+		`<samlp:Status><samlp:StatusCode Value="%s"/></samlp:Status>`+
+		`<saml:Assertion ID="%s" Version="2.0" IssueInstant="%s">`+
+		`<saml:Issuer>%s</saml:Issuer>`+
+		`<saml:Subject><saml:NameID Format="%s">%s</saml:NameID>`+
+		`<saml:SubjectConfirmation Method="%s"><saml:SubjectConfirmationData InResponseTo="%s" NotOnOrAfter="%s" Recipient="%s"/></saml:SubjectConfirmation></saml:Subject>`+
+		`<saml:Conditions NotBefore="%s" NotOnOrAfter="%s"><saml:AudienceRestriction><saml:Audience>%s</saml:Audience></saml:AudienceRestriction></saml:Conditions>`+
+		`<saml:AuthnStatement AuthnInstant="%s"><saml:AuthnContext><saml:AuthnContextClassRef>urn:oasis:names:tc:SAML:2.0:ac:classes:PasswordProtectedTransport</saml:AuthnContextClassRef></saml:AuthnContext></saml:AuthnStatement>`+
+		`<saml:AttributeStatement>%s</saml:AttributeStatement>`+
+		`</saml:Assertion></samlp:Response>`,
+		samlProtocolNamespace, samlAssertionNamespace, newSAMLID(), instant, a(acsURL), a(inResponseTo),
+		xmldsig.EscapeText(m.Issuer),
+		samlStatusSuccess,
+		newSAMLID(), instant,
+		xmldsig.EscapeText(m.Issuer),
+		samlNameIDEmail, xmldsig.EscapeText(m.User.Email),
+		samlBearer, a(inResponseTo), notOnOrAfter, a(acsURL),
+		now.Add(-time.Minute).Format(time.RFC3339), notOnOrAfter, xmldsig.EscapeText(audience),
+		instant,
+		attributes.String())
+
+	root, err := xmldsig.Parse([]byte(document))
+	if err != nil {
+		return "", err
+	}
+	signed := root
+	if !m.SignResponse {
+		signed = root.Child(samlAssertionNamespace, "Assertion")
+	}
+	if err := xmldsig.SignEnveloped(signed, m.Key, m.Certificate); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(xmldsig.Canonicalize(root, nil, nil)), nil
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// pkceChallenge returns the S256 challenge of a verifier
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// newSAMLID returns an xs:ID (must not start with a digit)
+func newSAMLID() string {
+	b := make([]byte, 20)
+	rand.Read(b)
+	return "_" + hex.EncodeToString(b)
+}
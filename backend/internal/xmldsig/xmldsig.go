@@ -0,0 +1,491 @@
+// Package xmldsig is a minimal XML-DSig implementation for SAML: enveloped
+// signatures with exclusive canonicalization (xml-exc-c14n#), RSA-SHA256/SHA1
+// and SHA256/SHA1 digests. Documents are parsed into a small DOM that keeps
+// namespace prefixes, which encoding/xml's resolved names drop.
+package xmldsig
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// XML namespaces and algorithms
+const (
+	xmlNamespace     = "http://www.w3.org/XML/1998/namespace"
+	xmlDSigNamespace = "http://www.w3.org/2000/09/xmldsig#"
+	xmlExcC14N       = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	xmlEnveloped     = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	xmlRSASHA256     = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	xmlRSASHA1       = "http://www.w3.org/2000/09/xmldsig#rsa-sha1"
+	xmlDigestSHA256  = "http://www.w3.org/2001/04/xmlenc#sha256"
+	xmlDigestSHA1    = "http://www.w3.org/2000/09/xmldsig#sha1"
+	MaxDocumentLen   = 1 << 20
+)
+
+// Errors returned by signature verification
+var (
+	ErrSignatureMissing = errors.New("xml signature missing")
+	ErrSignatureInvalid = errors.New("xml signature invalid")
+)
+
+// Node is an element with its prefix, namespace declarations and children
+type Node struct {
+	Prefix   string
+	Local    string
+	NS       []Attribute   // declarations: Local = prefix ("" for default), Value = URI
+	Attrs    []Attribute   // other attributes
+	Children []interface{} // *Node or CharData
+	Parent   *Node
+}
+
+// Attribute is an attribute or a namespace declaration
+type Attribute struct {
+	Prefix string
+	Local  string
+	Value  string
+}
+
+// CharData is text content
+type CharData string
+
+// Parse parses a document into a DOM. DTDs are rejected.
+func Parse(data []byte) (*Node, error) {
+	if len(data) > MaxDocumentLen {
+		return nil, errors.New("xml document too large")
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var root, current *Node
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &Node{Prefix: t.Name.Space, Local: t.Name.Local, Parent: current}
+			for _, a := range t.Attr {
+				switch {
+				case a.Name.Space == "xmlns":
+					node.NS = append(node.NS, Attribute{Local: a.Name.Local, Value: a.Value})
+				case a.Name.Space == "" && a.Name.Local == "xmlns":
+					node.NS = append(node.NS, Attribute{Local: "", Value: a.Value})
+				default:
+					node.Attrs = append(node.Attrs, Attribute{Prefix: a.Name.Space, Local: a.Name.Local, Value: a.Value})
+				}
+			}
+			if current == nil {
+				if root != nil {
+					return nil, errors.New("xml document has more than one root")
+				}
+				root = node
+			} else {
+				current.Children = append(current.Children, node)
+			}
+			current = node
+		case xml.EndElement:
+			if current == nil || t.Name.Space != current.Prefix || t.Name.Local != current.Local {
+				return nil, errors.New("malformed xml document")
+			}
+			current = current.Parent
+		case xml.CharData:
+			if current != nil {
+				current.Children = append(current.Children, CharData(string(t)))
+			}
+		case xml.Directive:
+			return nil, errors.New("xml DTDs are not allowed")
+		}
+	}
+	if root == nil || current != nil {
+		return nil, errors.New("malformed xml document")
+	}
+	return root, nil
+}
+
+// lookupNS resolves a prefix in scope at the node
+func (n *Node) lookupNS(prefix string) (string, bool) {
+	if prefix == "xml" {
+		return xmlNamespace, true
+	}
+	for node := n; node != nil; node = node.Parent {
+		for _, decl := range node.NS {
+			if decl.Local == prefix {
+				return decl.Value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Space returns the element's namespace URI
+func (n *Node) Space() string {
+	uri, _ := n.lookupNS(n.Prefix)
+	return uri
+}
+
+// Is reports whether the element has the namespace and local name
+func (n *Node) Is(space, local string) bool {
+	return n.Local == local && n.Space() == space
+}
+
+// Attr returns an unqualified attribute
+func (n *Node) Attr(local string) string {
+	for _, a := range n.Attrs {
+		if a.Prefix == "" && a.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// Child returns the first child element with the name
+func (n *Node) Child(space, local string) *Node {
+	for _, c := range n.Children {
+		if el, ok := c.(*Node); ok && el.Is(space, local) {
+			return el
+		}
+	}
+	return nil
+}
+
+// ChildrenNamed returns the child elements with the name
+func (n *Node) ChildrenNamed(space, local string) []*Node {
+	var out []*Node
+	for _, c := range n.Children {
+		if el, ok := c.(*Node); ok && el.Is(space, local) {
+			out = append(out, el)
+		}
+	}
+	return out
+}
+
+// Text returns the element's concatenated text content
+func (n *Node) Text() string {
+	var b strings.Builder
+	for _, c := range n.Children {
+		switch v := c.(type) {
+		case CharData:
+			b.WriteString(string(v))
+		case *Node:
+			b.WriteString(v.Text())
+		}
+	}
+	return b.String()
+}
+
+// ============================================
+// EXCLUSIVE CANONICALIZATION
+// ============================================
+
+// Canonicalize serializes the subtree with exclusive c14n (no comments).
+// skip is left out (enveloped signature); inclusive lists prefixes from an
+// InclusiveNamespaces PrefixList.
+func Canonicalize(n *Node, skip *Node, inclusive []string) []byte {
+	incl := make(map[string]bool, len(inclusive))
+	for _, p := range inclusive {
+		if p == "#default" {
+			p = ""
+		}
+		incl[p] = true
+	}
+	var buf bytes.Buffer
+	writeCanonical(&buf, n, skip, map[string]string{}, incl)
+	return buf.Bytes()
+}
+
+func writeCanonical(buf *bytes.Buffer, n, skip *Node, rendered map[string]string, inclusive map[string]bool) {
+	used := map[string]bool{n.Prefix: true}
+	for _, a := range n.Attrs {
+		if a.Prefix != "" {
+			used[a.Prefix] = true
+		}
+	}
+	for p := range inclusive {
+		if _, ok := n.lookupNS(p); ok {
+			used[p] = true
+		}
+	}
+
+	next := make(map[string]string, len(rendered))
+	for k, v := range rendered {
+		next[k] = v
+	}
+
+	var decls []Attribute
+	for p := range used {
+		if p == "xml" {
+			continue
+		}
+		uri, ok := n.lookupNS(p)
+		if !ok && p != "" {
+			continue
+		}
+		prev, had := rendered[p]
+		if p == "" && uri == "" && (!had || prev == "") {
+			continue
+		}
+		if had && prev == uri {
+			continue
+		}
+		decls = append(decls, Attribute{Local: p, Value: uri})
+		next[p] = uri
+	}
+	sort.Slice(decls, func(i, j int) bool { return decls[i].Local < decls[j].Local })
+
+	attrs := append([]Attribute(nil), n.Attrs...)
+	attrSpace := func(a Attribute) string {
+		if a.Prefix == "" {
+			return ""
+		}
+		uri, _ := n.lookupNS(a.Prefix)
+		return uri
+	}
+	sort.SliceStable(attrs, func(i, j int) bool {
+		si, sj := attrSpace(attrs[i]), attrSpace(attrs[j])
+		if si != sj {
+			return si < sj
+		}
+		return attrs[i].Local < attrs[j].Local
+	})
+
+	name := qualifiedName(n.Prefix, n.Local)
+	buf.WriteByte('<')
+	buf.WriteString(name)
+	for _, d := range decls {
+		if d.Local == "" {
+			buf.WriteString(` xmlns="`)
+		} else {
+			buf.WriteString(` xmlns:` + d.Local + `="`)
+		}
+		buf.WriteString(EscapeAttr(d.Value))
+		buf.WriteByte('"')
+	}
+	for _, a := range attrs {
+		buf.WriteString(" " + qualifiedName(a.Prefix, a.Local) + `="`)
+		buf.WriteString(EscapeAttr(a.Value))
+		buf.WriteByte('"')
+	}
+	buf.WriteByte('>')
+
+	for _, c := range n.Children {
+		switch v := c.(type) {
+		case CharData:
+			buf.WriteString(EscapeText(string(v)))
+		case *Node:
+			if v != skip {
+				writeCanonical(buf, v, skip, next, inclusive)
+			}
+		}
+	}
+
+	buf.WriteString("</" + name + ">")
+}
+
+func qualifiedName(prefix, local string) string {
+	if prefix == "" {
+		return local
+	}
+	return prefix + ":" + local
+}
+
+var (
+	c14nTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	c14nAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+// EscapeText escapes text content as canonical XML writes it
+func EscapeText(s string) string { return c14nTextEscaper.Replace(s) }
+
+// EscapeAttr escapes an attribute value as canonical XML writes it
+func EscapeAttr(s string) string { return c14nAttrEscaper.Replace(s) }
+
+// ============================================
+// VERIFICATION
+// ============================================
+
+// VerifyEnveloped checks the ds:Signature that is a direct child
+// of el and references el's ID. Only el itself is covered, so callers must
+// read data from el and nothing outside it.
+func VerifyEnveloped(el *Node, cert *x509.Certificate) error {
+	sig := el.Child(xmlDSigNamespace, "Signature")
+	if sig == nil {
+		return ErrSignatureMissing
+	}
+	if len(el.ChildrenNamed(xmlDSigNamespace, "Signature")) != 1 {
+		return fmt.Errorf("%w: more than one signature", ErrSignatureInvalid)
+	}
+
+	signedInfo := sig.Child(xmlDSigNamespace, "SignedInfo")
+	if signedInfo == nil {
+		return fmt.Errorf("%w: no SignedInfo", ErrSignatureInvalid)
+	}
+
+	c14nMethod := signedInfo.Child(xmlDSigNamespace, "CanonicalizationMethod")
+	if c14nMethod == nil || c14nMethod.Attr("Algorithm") != xmlExcC14N {
+		return fmt.Errorf("%w: unsupported canonicalization", ErrSignatureInvalid)
+	}
+
+	var hashFn crypto.Hash
+	sigMethod := signedInfo.Child(xmlDSigNamespace, "SignatureMethod")
+	switch {
+	case sigMethod != nil && sigMethod.Attr("Algorithm") == xmlRSASHA256:
+		hashFn = crypto.SHA256
+	case sigMethod != nil && sigMethod.Attr("Algorithm") == xmlRSASHA1:
+		hashFn = crypto.SHA1
+	default:
+		return fmt.Errorf("%w: unsupported signature method", ErrSignatureInvalid)
+	}
+
+	refs := signedInfo.ChildrenNamed(xmlDSigNamespace, "Reference")
+	if len(refs) != 1 {
+		return fmt.Errorf("%w: expected one reference", ErrSignatureInvalid)
+	}
+	ref := refs[0]
+	id := el.Attr("ID")
+	if id == "" || ref.Attr("URI") != "#"+id {
+		return fmt.Errorf("%w: reference does not cover the signed element", ErrSignatureInvalid)
+	}
+
+	var refInclusive []string
+	if transforms := ref.Child(xmlDSigNamespace, "Transforms"); transforms != nil {
+		for _, t := range transforms.ChildrenNamed(xmlDSigNamespace, "Transform") {
+			switch t.Attr("Algorithm") {
+			case xmlEnveloped:
+			case xmlExcC14N:
+				refInclusive = inclusivePrefixes(t)
+			default:
+				return fmt.Errorf("%w: unsupported transform", ErrSignatureInvalid)
+			}
+		}
+	}
+
+	var digest []byte
+	canonical := Canonicalize(el, sig, refInclusive)
+	digestMethod := ref.Child(xmlDSigNamespace, "DigestMethod")
+	switch {
+	case digestMethod != nil && digestMethod.Attr("Algorithm") == xmlDigestSHA256:
+		sum := sha256.Sum256(canonical)
+		digest = sum[:]
+	case digestMethod != nil && digestMethod.Attr("Algorithm") == xmlDigestSHA1:
+		sum := sha1.Sum(canonical)
+		digest = sum[:]
+	default:
+		return fmt.Errorf("%w: unsupported digest method", ErrSignatureInvalid)
+	}
+
+	digestValue := ref.Child(xmlDSigNamespace, "DigestValue")
+	if digestValue == nil {
+		return fmt.Errorf("%w: no digest", ErrSignatureInvalid)
+	}
+	expected, err := base64.StdEncoding.DecodeString(StripSpace(digestValue.Text()))
+	if err != nil || !bytes.Equal(expected, digest) {
+		return fmt.Errorf("%w: digest mismatch", ErrSignatureInvalid)
+	}
+
+	sigValue := sig.Child(xmlDSigNamespace, "SignatureValue")
+	if sigValue == nil {
+		return fmt.Errorf("%w: no signature value", ErrSignatureInvalid)
+	}
+	signature, err := base64.StdEncoding.DecodeString(StripSpace(sigValue.Text()))
+	if err != nil {
+		return fmt.Errorf("%w: bad signature encoding", ErrSignatureInvalid)
+	}
+
+	pub, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("%w: certificate key is not RSA", ErrSignatureInvalid)
+	}
+	h := hashFn.New()
+	h.Write(Canonicalize(signedInfo, nil, inclusivePrefixes(c14nMethod)))
+	if err := rsa.VerifyPKCS1v15(pub, hashFn, h.Sum(nil), signature); err != nil {
+		return fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+	}
+	return nil
+}
+
+// inclusivePrefixes reads ec:InclusiveNamespaces/@PrefixList under a transform
+func inclusivePrefixes(n *Node) []string {
+	for _, c := range n.Children {
+		if el, ok := c.(*Node); ok && el.Is(xmlExcC14N, "InclusiveNamespaces") {
+			return strings.Fields(el.Attr("PrefixList"))
+		}
+	}
+	return nil
+}
+
+// StripSpace removes the whitespace allowed inside base64 element text
+func StripSpace(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\n', '\r':
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// ============================================
+// SIGNING
+// ============================================
+
+// SignEnveloped adds an enveloped RSA-SHA256 signature to el, placed after
+// its saml:Issuer as the SAML schema requires
+func SignEnveloped(el *Node, key *rsa.PrivateKey, cert *x509.Certificate) error {
+	sig := &Node{Prefix: "ds", Local: "Signature", Parent: el,
+		NS: []Attribute{{Local: "ds", Value: xmlDSigNamespace}}}
+
+	// Insert after Issuer so that namespace lookups work during c14n
+	pos := 0
+	for i, c := range el.Children {
+		if child, ok := c.(*Node); ok && child.Local == "Issuer" {
+			pos = i + 1
+			break
+		}
+	}
+	el.Children = append(el.Children[:pos], append([]interface{}{sig}, el.Children[pos:]...)...)
+
+	digest := sha256.Sum256(Canonicalize(el, sig, nil))
+
+	element := func(parent *Node, local string, attrs ...Attribute) *Node {
+		node := &Node{Prefix: "ds", Local: local, Attrs: attrs, Parent: parent}
+		parent.Children = append(parent.Children, node)
+		return node
+	}
+	signedInfo := element(sig, "SignedInfo")
+	element(signedInfo, "CanonicalizationMethod", Attribute{Local: "Algorithm", Value: xmlExcC14N})
+	element(signedInfo, "SignatureMethod", Attribute{Local: "Algorithm", Value: xmlRSASHA256})
+	ref := element(signedInfo, "Reference", Attribute{Local: "URI", Value: "#" + el.Attr("ID")})
+	transforms := element(ref, "Transforms")
+	element(transforms, "Transform", Attribute{Local: "Algorithm", Value: xmlEnveloped})
+	element(transforms, "Transform", Attribute{Local: "Algorithm", Value: xmlExcC14N})
+	element(ref, "DigestMethod", Attribute{Local: "Algorithm", Value: xmlDigestSHA256})
+	element(ref, "DigestValue").Children = []interface{}{CharData(base64.StdEncoding.EncodeToString(digest[:]))}
+
+	h := sha256.Sum256(Canonicalize(signedInfo, nil, nil))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+	if err != nil {
+		return err
+	}
+	element(sig, "SignatureValue").Children = []interface{}{CharData(base64.StdEncoding.EncodeToString(signature))}
+
+	keyInfo := element(sig, "KeyInfo")
+	x509Data := element(keyInfo, "X509Data")
+	element(x509Data, "X509Certificate").Children = []interface{}{CharData(base64.StdEncoding.EncodeToString(cert.Raw))}
+	return nil
+}
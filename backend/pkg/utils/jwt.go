@@ -13,6 +13,7 @@ import (
 
 type Claims struct {
 	UserID   uuid.UUID `json:"user_id"`
+	TenantID uuid.UUID `json:"tenant_id,omitempty"` // Tenant the user belongs to
 	Username string    `json:"username"`
 	Email    string    `json:"email"`
 	Role     string    `json:"role"`
@@ -49,12 +50,18 @@ func generateTokenID() string {
 
 // GenerateToken generates a JWT token for a user with enhanced security
 func GenerateToken(userID uuid.UUID, username, email, role string) (string, error) {
+	return GenerateTenantToken(userID, uuid.Nil, username, email, role)
+}
+
+// GenerateTenantToken generates a JWT token bound to the user's tenant
+func GenerateTenantToken(userID, tenantID uuid.UUID, username, email, role string) (string, error) {
 	now := time.Now()
 	expirationTime := now.Add(AccessTokenExpiry)
 	tokenID := generateTokenID()
 
 	claims := &Claims{
 		UserID:   userID,
+		TenantID: tenantID,
 		Username: username,
 		Email:    email,
 		Role:     role,
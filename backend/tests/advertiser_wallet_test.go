@@ -0,0 +1,215 @@
+package tests
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================
+// ADVERTISER WALLETS
+// ============================================
+
+func TestWalletMovementExhaustsAndReplenishes(t *testing.T) {
+	now := time.Now()
+	w := &models.AdvertiserWallet{Status: models.WalletStatusActive, Balance: 1000, CreditLimit: 500}
+
+	if tr := services.ApplyWalletMovement(w, -1200, now); tr.Exhausted || w.Balance != -200 {
+		t.Fatalf("credit limit not honoured: %+v balance=%d", tr, w.Balance)
+	}
+	tr := services.ApplyWalletMovement(w, -300, now)
+	if !tr.Exhausted || w.Status != models.WalletStatusExhausted || w.ExhaustedAt == nil {
+		t.Fatalf("available=0 must exhaust the wallet: %+v status=%s", tr, w.Status)
+	}
+	if tr := services.ApplyWalletMovement(w, -100, now); tr.Exhausted {
+		t.Error("an exhausted wallet must not be exhausted again")
+	}
+
+	tr = services.ApplyWalletMovement(w, 2000, now)
+	if !tr.Replenished || w.Status != models.WalletStatusActive || w.ExhaustedAt != nil {
+		t.Errorf("top-up must replenish the wallet: %+v status=%s", tr, w.Status)
+	}
+	if w.Balance != 1400 {
+		t.Errorf("balance = %d; want 1400", w.Balance)
+	}
+}
+
+func TestWalletLowBalanceNotifiedOnce(t *testing.T) {
+	now := time.Now()
+	w := &models.AdvertiserWallet{Status: models.WalletStatusActive, Balance: 5000, LowBalanceThreshold: 1000}
+
+	if tr := services.ApplyWalletMovement(w, -4500, now); !tr.LowBalance || w.LowBalanceNotifiedAt == nil {
+		t.Fatalf("dropping below the threshold must notify: %+v", tr)
+	}
+	if tr := services.ApplyWalletMovement(w, -100, now); tr.LowBalance {
+		t.Error("low balance must be notified only once")
+	}
+	services.ApplyWalletMovement(w, 3000, now)
+	if w.LowBalanceNotifiedAt != nil {
+		t.Error("a top-up above the threshold must re-arm the notification")
+	}
+	if tr := services.ApplyWalletMovement(w, -3000, now); !tr.LowBalance {
+		t.Error("dropping below the threshold again must notify again")
+	}
+
+	disabled := &models.AdvertiserWallet{Status: models.WalletStatusDisabled, Balance: 100, LowBalanceThreshold: 1000}
+	if tr := services.ApplyWalletMovement(disabled, -500, now); tr.LowBalance || tr.Exhausted {
+		t.Errorf("disabled wallet transitioned: %+v", tr)
+	}
+}
+
+func TestWalletTopUpWebhookSignature(t *testing.T) {
+	svc := services.NewAdvertiserWalletService(nil)
+	svc.SetWebhookSecret("whsec")
+	body := []byte(`{"id":"evt_1","type":"payment.failed","payment_id":"pay_1","amount":1000,"currency":"USD"}`)
+
+	if _, _, err := svc.HandleTopUpWebhook(http.Header{services.WalletTopUpSignatureHeader: {"bad"}}, body); !errors.Is(err, services.ErrWalletInvalidSignature) {
+		t.Errorf("unsigned webhook = %v; want ErrWalletInvalidSignature", err)
+	}
+
+	mac := hmac.New(sha256.New, []byte("whsec"))
+	mac.Write(body)
+	headers := http.Header{services.WalletTopUpSignatureHeader: {hex.EncodeToString(mac.Sum(nil))}}
+	if _, _, err := svc.HandleTopUpWebhook(headers, body); !errors.Is(err, services.ErrWalletEventIgnored) {
+		t.Errorf("non-payment event = %v; want ErrWalletEventIgnored", err)
+	}
+}
+
+// walletFixture is a prepaid wallet in the in-memory database
+func walletFixture(t *testing.T, currency string, balance int64) (*gorm.DB, *memStore, *services.AdvertiserWalletService, uuid.UUID) {
+	t.Helper()
+	db, store := newMemDB(t)
+	store.unique["wallet_transactions"] = []string{"idempotency_key"}
+	store.unique["wallet_pending_charges"] = []string{"conversion_id"}
+	advertiserID := uuid.New()
+	store.insert("advertiser_wallets", map[string]interface{}{
+		"id": uuid.NewString(), "tenant_id": models.DefaultTenantID.String(), "advertiser_id": advertiserID.String(),
+		"currency": currency, "status": models.WalletStatusActive, "balance": balance,
+		"credit_limit": int64(0), "low_balance_threshold": int64(0),
+	})
+	return db, store, services.NewAdvertiserWalletService(db), advertiserID
+}
+
+func walletCharge(db *gorm.DB, svc *services.AdvertiserWalletService, advertiserID uuid.UUID, amount services.Money) (uuid.UUID, error) {
+	conversion := &models.Conversion{ID: uuid.New()}
+	conversion.TenantID = models.DefaultTenantID
+	return conversion.ID, db.Transaction(func(tx *gorm.DB) error {
+		return svc.ChargeConversion(tx, conversion, advertiserID, amount)
+	})
+}
+
+func walletBalance(t *testing.T, store *memStore) int64 {
+	t.Helper()
+	balance, ok := store.table("advertiser_wallets")[0]["balance"].(int64)
+	if !ok {
+		t.Fatalf("wallet balance not stored as int64: %#v", store.table("advertiser_wallets")[0]["balance"])
+	}
+	return balance
+}
+
+func TestWalletChargeWithoutFXRateIsPending(t *testing.T) {
+	db, store, svc, advertiserID := walletFixture(t, "EUR", 10000)
+
+	conversionID, err := walletCharge(db, svc, advertiserID, services.NewMoney(500, "USD"))
+	if err != nil {
+		t.Fatalf("a missing FX rate must not fail the conversion: %v", err)
+	}
+	pending := store.table("wallet_pending_charges")
+	if len(pending) != 1 || pending[0]["conversion_id"] != conversionID.String() || pending[0]["currency"] != "USD" {
+		t.Fatalf("pending charges = %v; want one for %s in USD", pending, conversionID)
+	}
+	if txns := store.table("wallet_transactions"); len(txns) != 0 || walletBalance(t, store) != 10000 {
+		t.Fatalf("wallet charged without a rate: %v", txns)
+	}
+
+	// A reversed conversion drops its pending charge
+	refunded, err := walletCharge(db, svc, advertiserID, services.NewMoney(300, "USD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error { return svc.RefundConversion(tx, refunded, "rejected") }); err != nil {
+		t.Fatal(err)
+	}
+	if pending := store.table("wallet_pending_charges"); len(pending) != 1 {
+		t.Fatalf("refund left %d pending charges; want 1", len(pending))
+	}
+
+	// Still no rate: the charge stays pending
+	if settled, err := svc.SettlePendingCharges(); err != nil || settled != 0 {
+		t.Fatalf("SettlePendingCharges() = %d, %v; want 0 without a rate", settled, err)
+	}
+	if attempts := store.table("wallet_pending_charges")[0]["attempts"]; attempts != int64(1) {
+		t.Errorf("attempts = %v; want 1", attempts)
+	}
+
+	svc.SetFXService(services.NewFXService(db, nil))
+	store.respond(`"fx_rates"`, []string{"id", "date", "base", "quote", "rate", "source"},
+		[]driver.Value{uuid.NewString(), time.Now().Truncate(24 * time.Hour), "USD", "EUR", 0.9, "manual"})
+	if settled, err := svc.SettlePendingCharges(); err != nil || settled != 1 {
+		t.Fatalf("SettlePendingCharges() = %d, %v; want 1", settled, err)
+	}
+	if pending := store.table("wallet_pending_charges"); len(pending) != 0 {
+		t.Errorf("settled charge still pending: %v", pending)
+	}
+	txns := store.table("wallet_transactions")
+	if len(txns) != 1 || txns[0]["amount"] != int64(-450) || txns[0]["idempotency_key"] != "conversion_charge:"+conversionID.String() {
+		t.Fatalf("wallet transactions = %v; want one -450 EUR conversion charge", txns)
+	}
+	if balance := walletBalance(t, store); balance != 9550 {
+		t.Errorf("balance = %d; want 9550", balance)
+	}
+}
+
+func TestWalletConcurrentChargesDoNotOverspend(t *testing.T) {
+	db, store, svc, advertiserID := walletFixture(t, "USD", 100000)
+	// Hold every wallet read long enough for unlocked charges to interleave
+	store.delayOn[`FROM "advertiser_wallets"`] = 5 * time.Millisecond
+
+	const charges = 20
+	start := make(chan struct{})
+	errs := make(chan error, charges)
+	var wg sync.WaitGroup
+	for i := 0; i < charges; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := walletCharge(db, svc, advertiserID, services.NewMoney(1000, "USD"))
+			errs <- err
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("charge failed: %v", err)
+		}
+	}
+
+	if balance := walletBalance(t, store); balance != 100000-charges*1000 {
+		t.Errorf("balance = %d; want %d (lost updates)", balance, 100000-charges*1000)
+	}
+	seen := make(map[int64]bool)
+	for _, txn := range store.table("wallet_transactions") {
+		after, _ := txn["balance_after"].(int64)
+		if seen[after] {
+			t.Errorf("two charges saw the same balance %d", after)
+		}
+		seen[after] = true
+	}
+	if len(seen) != charges {
+		t.Errorf("%d wallet transactions; want %d", len(seen), charges)
+	}
+}
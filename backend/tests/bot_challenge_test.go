@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================
+// BOT CHALLENGE
+// ============================================
+
+const (
+	challengeIP = "52.1.2.3" // datacenter range: +30 risk
+	challengeUA = "SomeBrowser/1.0 (Windows NT 10.0; Win64)"
+)
+
+func botChallengeRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.BotDetectionMiddleware())
+	router.GET("/api/c/:id", func(c *gin.Context) {
+		c.String(http.StatusOK, "redirect")
+	})
+	return router
+}
+
+// clickRequest builds a click from the test client; without browser headers
+// its risk is above the hard-block threshold, with them it is grey zone
+func clickRequest(browserHeaders bool, cookie string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/api/c/abc123", nil)
+	req.RemoteAddr = challengeIP + ":40000"
+	req.Header.Set("User-Agent", challengeUA)
+	if browserHeaders {
+		req.Header.Set("Accept", "text/html")
+		req.Header.Set("Accept-Language", "en")
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+	if cookie != "" {
+		req.AddCookie(&http.Cookie{Name: services.BotChallengeCookieName, Value: cookie})
+	}
+	return req
+}
+
+// solveChallenge passes the challenge served to the test client and returns
+// the clearance cookie value
+func solveChallenge(t *testing.T, router *gin.Engine) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, clickRequest(true, ""))
+	if w.Code != http.StatusOK || w.Body.String() == "redirect" {
+		t.Fatalf("grey-zone click must get the challenge page, got %d %q", w.Code, w.Body.String())
+	}
+	match := regexp.MustCompile(`[A-Za-z0-9_-]{20,}\.[A-Za-z0-9_-]{20,}`).FindString(w.Body.String())
+	if match == "" {
+		t.Fatal("challenge token not found in the page")
+	}
+
+	result, err := services.NewBotChallengeService().VerifyChallenge(&services.BotChallengeSignals{
+		Token:        match,
+		ScreenWidth:  1920,
+		ScreenHeight: 1080,
+		ColorDepth:   24,
+		Timezone:     "Asia/Kuwait",
+		CanvasHash:   "c4nv4s",
+		Languages:    []string{"en"},
+		ElapsedMs:    800,
+	}, challengeIP, challengeUA)
+	if err != nil || !result.Passed {
+		t.Fatalf("challenge not passed: %+v, %v", result, err)
+	}
+	return result.ClearanceValue
+}
+
+func TestBotChallengeClearance(t *testing.T) {
+	router := botChallengeRouter()
+	clearance := solveChallenge(t, router)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, clickRequest(true, clearance))
+	if w.Code != http.StatusOK || w.Body.String() != "redirect" {
+		t.Errorf("a cleared grey-zone click must pass, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestBotChallengeClearanceDoesNotBypassHardBlock(t *testing.T) {
+	router := botChallengeRouter()
+	clearance := solveChallenge(t, router)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, clickRequest(false, clearance))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("a high-risk click must be blocked despite its clearance, got %d", w.Code)
+	}
+}
+
+func TestBotChallengeSecretIsNotHardcoded(t *testing.T) {
+	t.Setenv("BOT_CHALLENGE_SECRET", "")
+	issuer, other := services.NewBotChallengeService(), services.NewBotChallengeService()
+	token := issuer.IssueChallenge(challengeIP, challengeUA, "/c/x", 50)
+	if _, err := other.VerifyChallenge(&services.BotChallengeSignals{Token: token}, challengeIP, challengeUA); err != nil {
+		t.Errorf("services of one process must share their secret: %v", err)
+	}
+
+	t.Setenv("BOT_CHALLENGE_SECRET", "afftok-bot-challenge-secret-change-in-production")
+	if _, err := services.NewBotChallengeService().VerifyChallenge(&services.BotChallengeSignals{Token: token}, challengeIP, challengeUA); err == nil {
+		t.Error("tokens must not verify against the old built-in secret")
+	}
+}
+
+func TestMergeChallengeFraud(t *testing.T) {
+	clearance := &services.BotChallengeClearance{FraudScore: 30, Flags: []string{"no_canvas", "vpn"}}
+
+	flags, score := services.MergeChallengeFraud(`["vpn","no_beacon"]`, 55, clearance)
+	var got []string
+	if err := json.Unmarshal([]byte(flags), &got); err != nil {
+		t.Fatalf("flags are not a JSON array: %q", flags)
+	}
+	want := []string{"vpn", "no_beacon", "js_challenge_passed", "no_canvas"}
+	if len(got) != len(want) {
+		t.Fatalf("got flags %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("flag %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+	if score != 55 {
+		t.Errorf("the higher existing score must be kept, got %v", score)
+	}
+
+	if _, score := services.MergeChallengeFraud("", 10, clearance); score != 30 {
+		t.Errorf("the challenge score must raise a lower one, got %v", score)
+	}
+}
@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+)
+
+// ============================================
+// CONVERSION ANOMALIES
+// ============================================
+
+func TestScoreDeviation(t *testing.T) {
+	series := []float64{0.10, 0.11, 0.09, 0.10, 0.10, 0.11, 0.09}
+
+	d := services.ScoreDeviation(models.AnomalyMetricConversionRate, 0.40, series, 0.01)
+	if d.Baseline != 0.1 {
+		t.Errorf("baseline should be the series mean, got %v", d.Baseline)
+	}
+	if d.ZScore < services.AnomalyZThreshold {
+		t.Errorf("a 4x conversion rate must be flagged, got z=%v", d.ZScore)
+	}
+
+	if d := services.ScoreDeviation(models.AnomalyMetricConversionRate, 0.105, series, 0.01); d.ZScore >= services.AnomalyZThreshold {
+		t.Errorf("a small change must not be flagged, got z=%v", d.ZScore)
+	}
+
+	// A perfectly stable history is floored instead of dividing by zero
+	flat := []float64{100, 100, 100, 100, 100, 100, 100}
+	if d := services.ScoreDeviation(models.AnomalyMetricAvgOrderValue, 102, flat, 1); d.StdDev != 5 || d.ZScore != 0.4 {
+		t.Errorf("expected the 5%% floor, got stddev=%v z=%v", d.StdDev, d.ZScore)
+	}
+}
+
+func TestScoreHourDistribution(t *testing.T) {
+	var baseline, same, shifted [24]float64
+	for h := 8; h < 20; h++ {
+		baseline[h] = 20
+		same[h] = 2
+	}
+	shifted[3] = 24
+
+	if d, ok := services.ScoreHourDistribution(baseline, same); !ok || d.Flagged {
+		t.Errorf("the usual hours must not be flagged: %+v %v", d, ok)
+	}
+	if d, ok := services.ScoreHourDistribution(baseline, shifted); !ok || !d.Flagged {
+		t.Errorf("conversions at 3am only must be flagged: %+v %v", d, ok)
+	}
+
+	var few [24]float64
+	few[3] = 3
+	if _, ok := services.ScoreHourDistribution(baseline, few); ok {
+		t.Error("too few current conversions must not be scored")
+	}
+	if _, ok := services.ScoreHourDistribution(few, shifted); ok {
+		t.Error("too small a baseline must not be scored")
+	}
+}
+
+func TestReleasedConversionStatus(t *testing.T) {
+	for held, want := range map[string]string{
+		models.ConversionStatusApproved: models.ConversionStatusApproved,
+		models.ConversionStatusPending:  models.ConversionStatusPending,
+		"":                              models.ConversionStatusPending,
+		models.ConversionStatusPaid:     models.ConversionStatusPending,
+	} {
+		if got := services.ReleasedConversionStatus(held); got != want {
+			t.Errorf("held %q: got %q, want %q", held, got, want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+)
+
+// ============================================
+// CUSTOM DOMAIN VERIFICATION
+// ============================================
+
+// stubResolver answers DNS lookups from maps instead of the network
+type stubResolver struct {
+	txt   map[string][]string
+	cname map[string]string
+}
+
+func (r *stubResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if records, ok := r.txt[name]; ok {
+		return records, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func (r *stubResolver) LookupCNAME(ctx context.Context, host string) (string, error) {
+	if target, ok := r.cname[host]; ok {
+		return target, nil
+	}
+	return "", errors.New("no such host")
+}
+
+func TestDomainOwnershipCheck(t *testing.T) {
+	const token = "0123456789abcdef"
+
+	resolver := &stubResolver{
+		txt: map[string][]string{
+			"_afftok-verify.txt.example.com":   {"v=spf1 -all", "afftok-verify=" + token},
+			"_afftok-verify.wrong.example.com": {"afftok-verify=someone-elses-token"},
+		},
+		cname: map[string]string{
+			"cname.example.com":  token + ".domains.afftokapp.com.",
+			"shared.example.com": "domains.afftokapp.com.",
+			"stolen.example.com": "someone-elses-token.domains.afftokapp.com.",
+			"other.example.com":  "elsewhere.example.net.",
+		},
+	}
+	svc := services.NewDomainVerificationService(nil, resolver)
+
+	cases := []struct {
+		domain string
+		method string // empty when the check must fail
+	}{
+		{"txt.example.com", models.DomainVerifyTXT},
+		{"cname.example.com", models.DomainVerifyCNAME},
+		{"wrong.example.com", ""},
+		{"shared.example.com", ""},
+		{"stolen.example.com", ""},
+		{"other.example.com", ""},
+		{"missing.example.com", ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.domain, func(t *testing.T) {
+			method, err := svc.CheckOwnership(context.Background(), tc.domain, token)
+			if tc.method == "" {
+				if !errors.Is(err, services.ErrDomainVerificationFailed) {
+					t.Fatalf("expected verification to fail, got method=%q err=%v", method, err)
+				}
+				return
+			}
+			if err != nil || method != tc.method {
+				t.Fatalf("expected %s verification, got method=%q err=%v", tc.method, method, err)
+			}
+		})
+	}
+
+	if _, err := svc.CheckOwnership(context.Background(), "shared.example.com", ""); !errors.Is(err, services.ErrDomainVerificationFailed) {
+		t.Errorf("a domain without a token must not verify, got %v", err)
+	}
+}
+
+func TestDomainNormalization(t *testing.T) {
+	svc := services.NewDomainVerificationService(nil, &stubResolver{})
+
+	valid := map[string]string{
+		"Links.Example.COM": "links.example.com",
+		"go.brand.example.": "go.brand.example",
+	}
+	for input, want := range valid {
+		got, err := svc.NormalizeDomain(input)
+		if err != nil || got != want {
+			t.Errorf("NormalizeDomain(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+
+	invalid := map[string]error{
+		"":                           services.ErrDomainInvalid,
+		"localhost":                  services.ErrDomainInvalid,
+		"10.0.0.1":                   services.ErrDomainInvalid,
+		"*.example.com":              services.ErrDomainInvalid,
+		"bad_label.example.com":      services.ErrDomainInvalid,
+		"https://example.com":        services.ErrDomainInvalid,
+		"go.afftokapp.com":           services.ErrDomainReserved,
+		"evil.domains.afftokapp.com": services.ErrDomainReserved,
+	}
+	for input, want := range invalid {
+		if _, err := svc.NormalizeDomain(input); !errors.Is(err, want) {
+			t.Errorf("NormalizeDomain(%q) error = %v; want %v", input, err, want)
+		}
+	}
+}
+
+func TestDomainInstructionsCarryToken(t *testing.T) {
+	svc := services.NewDomainVerificationService(nil, &stubResolver{})
+	domain := &models.TenantDomain{Domain: "links.example.com", VerificationToken: "abc"}
+
+	got := svc.Instructions(domain)
+	if got.TXTName != "_afftok-verify.links.example.com" || got.TXTValue != "afftok-verify=abc" {
+		t.Errorf("unexpected TXT instructions: %+v", got)
+	}
+	if got.CNAMETarget != "abc."+svc.CNAMETarget() {
+		t.Errorf("CNAME target = %q; want %q", got.CNAMETarget, "abc."+svc.CNAMETarget())
+	}
+}
@@ -0,0 +1,204 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/google/uuid"
+)
+
+// ============================================
+// EARNINGS STATEMENTS & TAX PROFILES
+// ============================================
+
+func statementRows() []services.EarningsStatementRow {
+	offerA, offerB := uuid.New(), uuid.New()
+	return []services.EarningsStatementRow{
+		{Currency: "USD", Month: 3, Kind: models.LedgerKindConversionApproved, OfferID: &offerB, OfferTitle: "Beta", Count: 2, Amount: 2000},
+		{Currency: "USD", Month: 3, Kind: models.LedgerKindConversionApproved, OfferID: &offerA, OfferTitle: "Alpha", Count: 5, Amount: 5000},
+		{Currency: "USD", Month: 1, Kind: models.LedgerKindConversionReversed, OfferID: &offerA, OfferTitle: "Alpha", Count: 1, Amount: -1000},
+		{Currency: "USD", Month: 1, Kind: models.LedgerKindAdjustment, Count: 1, Amount: 250},
+		{Currency: "USD", Month: 3, Kind: models.LedgerKindPayoutPaid, Count: 1, Amount: -4000},
+		{Currency: "SAR", Month: 2, Kind: models.LedgerKindConversionApproved, OfferID: &offerA, OfferTitle: "Alpha", Count: 1, Amount: 750},
+	}
+}
+
+func TestBuildEarningsStatementBalances(t *testing.T) {
+	currencies := services.BuildEarningsStatement(map[string]int64{"USD": 1500}, statementRows())
+	if len(currencies) != 2 || currencies[0].Currency != "SAR" || currencies[1].Currency != "USD" {
+		t.Fatalf("expected SAR and USD statements, got %+v", currencies)
+	}
+
+	usd := currencies[1]
+	if usd.Opening != 1500 || usd.Approved != 7000 || usd.Reversed != 1000 || usd.Adjustments != 250 || usd.Paid != 4000 {
+		t.Fatalf("unexpected USD totals: %+v", usd)
+	}
+	if usd.Closing != 1500+7000-1000+250-4000 {
+		t.Errorf("expected closing 3750, got %d", usd.Closing)
+	}
+	if usd.Conversions != 7 || usd.Reversals != 1 {
+		t.Errorf("expected 7 conversions and 1 reversal, got %d and %d", usd.Conversions, usd.Reversals)
+	}
+
+	if len(usd.Offers) != 2 || usd.Offers[0].OfferTitle != "Alpha" || usd.Offers[1].OfferTitle != "Beta" {
+		t.Fatalf("expected offers sorted by title, got %+v", usd.Offers)
+	}
+	if alpha := usd.Offers[0]; alpha.Approved != 5000 || alpha.Reversed != 1000 || alpha.Net != 4000 || alpha.Reversals != 1 {
+		t.Errorf("unexpected Alpha offer: %+v", alpha)
+	}
+
+	if len(usd.Months) != 2 || usd.Months[0].Month != 1 || usd.Months[1].Month != 3 {
+		t.Fatalf("expected months 1 and 3 in order, got %+v", usd.Months)
+	}
+	if m := usd.Months[1]; m.Approved != 7000 || m.Paid != 4000 {
+		t.Errorf("unexpected March totals: %+v", m)
+	}
+
+	sar := currencies[0]
+	if sar.Opening != 0 || sar.Closing != 750 || sar.MinorUnits != 2 {
+		t.Errorf("unexpected SAR statement: %+v", sar)
+	}
+}
+
+func TestBuildEarningsStatementOpeningOnly(t *testing.T) {
+	currencies := services.BuildEarningsStatement(map[string]int64{"EUR": 900}, nil)
+	if len(currencies) != 1 || currencies[0].Opening != 900 || currencies[0].Closing != 900 {
+		t.Fatalf("expected an unchanged EUR balance, got %+v", currencies)
+	}
+	if currencies[0].Offers == nil || len(currencies[0].Offers) != 0 {
+		t.Errorf("expected an empty offer list")
+	}
+}
+
+func TestEarningsStatementCSV(t *testing.T) {
+	st := &services.EarningsStatement{
+		Year:       2026,
+		Currencies: services.BuildEarningsStatement(map[string]int64{"USD": 1500}, statementRows()),
+	}
+	var buf bytes.Buffer
+	if err := services.WriteEarningsStatementCSV(&buf, st); err != nil {
+		t.Fatalf("csv: %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("read csv: %v", err)
+	}
+	if records[0][0] != "type" || records[0][12] != "closing" {
+		t.Fatalf("unexpected header: %v", records[0])
+	}
+	// SAR: summary, offer, month; USD: summary, 2 offers, 2 months
+	if len(records) != 1+3+5 {
+		t.Fatalf("expected 9 records, got %d", len(records))
+	}
+	usd := records[4]
+	if usd[0] != "summary" || usd[1] != "USD" || usd[7] != "15.00" || usd[12] != "37.50" {
+		t.Errorf("unexpected USD summary: %v", usd)
+	}
+	if month := records[len(records)-1]; month[0] != "month" || month[2] != "2026-03" || month[11] != "40.00" {
+		t.Errorf("unexpected month row: %v", month)
+	}
+}
+
+func TestEarningsStatementReference(t *testing.T) {
+	monthly := &services.EarningsStatement{Year: 2026, Month: 4}
+	annual := &services.EarningsStatement{Year: 2025}
+	if monthly.YearEnd() || monthly.Reference() != "earnings-2026-04" {
+		t.Errorf("unexpected monthly reference %q", monthly.Reference())
+	}
+	if !annual.YearEnd() || annual.Reference() != "earnings-2025" {
+		t.Errorf("unexpected annual reference %q", annual.Reference())
+	}
+}
+
+func TestRenderEarningsStatementPDF(t *testing.T) {
+	profile := &models.PromoterTaxProfile{Country: "US", EntityType: models.TaxEntityIndividual, LegalName: "Jane Doe", TaxID: "123456789"}
+	st := &services.EarningsStatement{
+		PromoterName: "Jane Doe",
+		Year:         2025,
+		PeriodStart:  time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		PeriodEnd:    time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		GeneratedAt:  time.Now(),
+		Currencies:   services.BuildEarningsStatement(map[string]int64{"USD": 1500}, statementRows()),
+		TaxProfile:   profile,
+		TaxIDMasked:  profile.MaskedTaxID(),
+	}
+	pdf, err := services.RenderEarningsStatementPDF(st)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !bytes.HasPrefix(pdf, []byte("%PDF-")) || !bytes.Contains(pdf, []byte("%%EOF")) {
+		t.Errorf("output is not a PDF document")
+	}
+}
+
+func TestValidateTaxProfile(t *testing.T) {
+	valid := []models.PromoterTaxProfile{
+		{Country: "us", EntityType: "Individual", LegalName: " Jane Doe ", TaxID: "123-45-6789"},
+		{Country: "US", EntityType: models.TaxEntityCompany, LegalName: "Acme Inc", TaxID: "12-3456789"},
+		{Country: "SA", EntityType: models.TaxEntityIndividual, LegalName: "Ali", TaxID: "1012345678"},
+		{Country: "SA", EntityType: models.TaxEntityCompany, LegalName: "Co", TaxID: "300000000000003"},
+		{Country: "AE", EntityType: models.TaxEntityCompany, LegalName: "Co", TaxID: "100 1234 5678 9012"},
+		{Country: "GB", EntityType: models.TaxEntityIndividual, LegalName: "Sam", TaxID: "AB 12 34 56 C"},
+		{Country: "FR", EntityType: models.TaxEntityCompany, LegalName: "SARL", TaxID: "FR12345", VATNumber: "FR 123456789"},
+	}
+	for _, p := range valid {
+		p := p
+		if err := services.ValidateTaxProfile(&p); err != nil {
+			t.Errorf("expected %s/%s to be valid: %v", p.Country, p.TaxID, err)
+		}
+	}
+
+	p := valid[0]
+	services.ValidateTaxProfile(&p)
+	if p.Country != "US" || p.EntityType != models.TaxEntityIndividual || p.LegalName != "Jane Doe" || p.TaxID != "123456789" {
+		t.Errorf("profile was not normalized: %+v", p)
+	}
+
+	invalid := []models.PromoterTaxProfile{
+		{Country: "USA", EntityType: models.TaxEntityIndividual, LegalName: "Jane", TaxID: "123456789"},
+		{Country: "US", EntityType: "trust", LegalName: "Jane", TaxID: "123456789"},
+		{Country: "US", EntityType: models.TaxEntityIndividual, LegalName: " ", TaxID: "123456789"},
+		{Country: "US", EntityType: models.TaxEntityIndividual, LegalName: "Jane", TaxID: "12345"},
+		{Country: "SA", EntityType: models.TaxEntityCompany, LegalName: "Co", TaxID: "1012345678"},      // national ID is for individuals
+		{Country: "GB", EntityType: models.TaxEntityCompany, LegalName: "Co", TaxID: "AB123456C"},       // NI number is for individuals
+		{Country: "AE", EntityType: models.TaxEntityCompany, LegalName: "Co", TaxID: "200123456789012"}, // TRN starts with 100
+		{Country: "FR", EntityType: models.TaxEntityCompany, LegalName: "Co", TaxID: "X1"},
+	}
+	for _, p := range invalid {
+		p := p
+		if err := services.ValidateTaxProfile(&p); !errors.Is(err, services.ErrTaxProfileInvalid) {
+			t.Errorf("expected %s/%s/%s to be rejected, got %v", p.Country, p.EntityType, p.TaxID, err)
+		}
+	}
+}
+
+func TestTaxProfileRequired(t *testing.T) {
+	if !services.TaxProfileRequired([]string{"US"}, "us") {
+		t.Errorf("expected US promoters to need a profile")
+	}
+	if services.TaxProfileRequired([]string{"US"}, "SA") {
+		t.Errorf("expected SA promoters not to need a profile")
+	}
+	if !services.TaxProfileRequired([]string{"*"}, "") {
+		t.Errorf("expected * to cover every promoter")
+	}
+	if services.TaxProfileRequired(nil, "US") || services.TaxProfileRequired([]string{"US"}, "") {
+		t.Errorf("expected no requirement without a setting or a country")
+	}
+}
+
+func TestMaskedTaxID(t *testing.T) {
+	profile := models.PromoterTaxProfile{TaxID: "123456789"}
+	if got := profile.MaskedTaxID(); got != "•••••6789" {
+		t.Errorf("unexpected mask %q", got)
+	}
+	short := models.PromoterTaxProfile{TaxID: "1234"}
+	if got := short.MaskedTaxID(); got != "••••" {
+		t.Errorf("short IDs should be fully masked, got %q", got)
+	}
+}
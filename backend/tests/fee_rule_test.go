@@ -0,0 +1,218 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/google/uuid"
+)
+
+// ============================================
+// PLATFORM FEE RULES
+// ============================================
+
+func feeRule(tenantID uuid.UUID, scope models.FeeRuleScope, value string, bps int64, from time.Time, to *time.Time) models.FeeRule {
+	rule := models.FeeRule{
+		ID:            uuid.New(),
+		Scope:         scope,
+		ScopeValue:    value,
+		Mode:          models.FeeModeMarkup,
+		RateBps:       bps,
+		EffectiveFrom: from,
+		EffectiveTo:   to,
+	}
+	rule.TenantID = tenantID
+	return rule
+}
+
+func TestResolveFeeRuleSpecificity(t *testing.T) {
+	tenant := uuid.New()
+	advertiser, offer := uuid.New(), uuid.New()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rules := []models.FeeRule{
+		feeRule(models.DefaultTenantID, models.FeeScopeTenant, "", 1200, start, nil),
+		feeRule(models.DefaultTenantID, models.FeeScopePlan, "pro", 900, start, nil),
+		feeRule(tenant, models.FeeScopePromoterTier, "legend", 500, start, nil),
+		feeRule(tenant, models.FeeScopeAdvertiser, advertiser.String(), 800, start, nil),
+		feeRule(tenant, models.FeeScopeOffer, offer.String(), 700, start, nil),
+	}
+	at := start.AddDate(0, 2, 0)
+	target := services.FeeTarget{TenantID: tenant, Plan: models.TenantPlanPro, PromoterTier: "legend", AdvertiserID: advertiser, OfferID: offer}
+
+	for _, tc := range []struct {
+		name   string
+		mutate func(*services.FeeTarget)
+		want   int64
+	}{
+		{"offer rule wins", func(*services.FeeTarget) {}, 700},
+		{"advertiser rule", func(t *services.FeeTarget) { t.OfferID = uuid.New() }, 800},
+		{"promoter tier rule", func(t *services.FeeTarget) { t.OfferID, t.AdvertiserID = uuid.New(), uuid.New() }, 500},
+		{"platform plan rule", func(t *services.FeeTarget) {
+			t.OfferID, t.AdvertiserID, t.PromoterTier = uuid.New(), uuid.New(), "rookie"
+		}, 900},
+		{"platform default", func(t *services.FeeTarget) {
+			t.OfferID, t.AdvertiserID, t.PromoterTier, t.Plan = uuid.New(), uuid.New(), "rookie", models.TenantPlanFree
+		}, 1200},
+	} {
+		target := target
+		tc.mutate(&target)
+		rule := services.ResolveFeeRule(rules, target, at)
+		if rule == nil || rule.RateBps != tc.want {
+			t.Errorf("%s: got %+v, want %d bps", tc.name, rule, tc.want)
+		}
+	}
+}
+
+func TestResolveFeeRuleTenantBeforePlatform(t *testing.T) {
+	tenant, offer := uuid.New(), uuid.New()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rules := []models.FeeRule{
+		feeRule(models.DefaultTenantID, models.FeeScopeOffer, offer.String(), 300, start, nil),
+		feeRule(tenant, models.FeeScopeTenant, "", 1500, start, nil),
+	}
+	rule := services.ResolveFeeRule(rules, services.FeeTarget{TenantID: tenant, OfferID: offer}, start)
+	if rule == nil || rule.RateBps != 1500 {
+		t.Fatalf("the tenant's own rule must come first, got %+v", rule)
+	}
+	if rule := services.ResolveFeeRule(nil, services.FeeTarget{TenantID: tenant}, start); rule != nil {
+		t.Errorf("expected no rule, got %+v", rule)
+	}
+}
+
+func TestResolveFeeRuleVersions(t *testing.T) {
+	tenant := uuid.New()
+	jan := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	mar := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	rules := []models.FeeRule{
+		feeRule(tenant, models.FeeScopeTenant, "", 1000, jan, &mar),
+		feeRule(tenant, models.FeeScopeTenant, "", 800, mar, nil),
+	}
+	target := services.FeeTarget{TenantID: tenant}
+
+	if rule := services.ResolveFeeRule(rules, target, mar.Add(-time.Second)); rule == nil || rule.RateBps != 1000 {
+		t.Errorf("February conversions keep version 1, got %+v", rule)
+	}
+	if rule := services.ResolveFeeRule(rules, target, mar); rule == nil || rule.RateBps != 800 {
+		t.Errorf("version 2 applies from its start, got %+v", rule)
+	}
+	if rule := services.ResolveFeeRule(rules, target, jan.Add(-time.Hour)); rule != nil {
+		t.Errorf("no version applies before the first one, got %+v", rule)
+	}
+}
+
+func TestComputeFeeModes(t *testing.T) {
+	volume := services.FeeVolume{Conversions: 1, Spend: 1250}
+
+	def := services.ComputeFee(nil, 1250, volume, "USD")
+	if def.RuleID != nil || def.Fee != 125 || def.Commission != 1250 || def.Charge != 1375 {
+		t.Errorf("default must be a 10%% markup, got %+v", def)
+	}
+
+	rule := feeRule(uuid.New(), models.FeeScopeTenant, "", 2000, time.Now(), nil)
+	markup := services.ComputeFee(&rule, 1250, volume, "USD")
+	if markup.RuleID == nil || *markup.RuleID != rule.ID || markup.Fee != 250 || markup.Charge != 1500 {
+		t.Errorf("unexpected markup quote: %+v", markup)
+	}
+
+	rule.Mode = models.FeeModeRevenueShare
+	share := services.ComputeFee(&rule, 1250, volume, "USD")
+	if share.Fee != 250 || share.Commission != 1000 || share.Charge != 1250 {
+		t.Errorf("revenue share must come out of the commission, got %+v", share)
+	}
+}
+
+func TestComputeFeeMinimum(t *testing.T) {
+	rule := feeRule(uuid.New(), models.FeeScopeTenant, "", 1000, time.Now(), nil)
+	rule.MinFee = 0.5
+	quote := services.ComputeFee(&rule, 200, services.FeeVolume{Conversions: 1, Spend: 200}, "USD")
+	if quote.Fee != 50 || quote.Charge != 250 {
+		t.Errorf("minimum fee not applied: %+v", quote)
+	}
+
+	rule.MinFee = 0.250
+	if quote := services.ComputeFee(&rule, 100, services.FeeVolume{}, "KWD"); quote.Fee != 250 {
+		t.Errorf("KWD minimum is in fils, got %d", quote.Fee)
+	}
+
+	rule.Mode = models.FeeModeRevenueShare
+	rule.MinFee = 5
+	quote = services.ComputeFee(&rule, 200, services.FeeVolume{}, "USD")
+	if quote.Fee != 200 || quote.Commission != 0 || quote.Charge != 200 {
+		t.Errorf("a revenue share cannot exceed the commission: %+v", quote)
+	}
+	if postings := services.BuildConversionPostings(uuid.New(), uuid.New(), quote.Commission, quote.Fee); services.ValidateLedgerPostings(postings) != nil {
+		t.Errorf("postings without promoter share must still balance: %+v", postings)
+	}
+}
+
+func TestComputeFeeVolumeTiers(t *testing.T) {
+	tenant := uuid.New()
+	rule := feeRule(tenant, models.FeeScopeTenant, "", 1000, time.Now(), nil)
+	rule.VolumeBasis = models.FeeVolumeSpend
+	tiers := []models.FeeTier{{From: 10000, RateBps: 600}, {From: 1000, RateBps: 800}}
+	if err := services.ValidateFeeRule(tenant, &rule, tiers); err != nil {
+		t.Fatalf("valid tiers rejected: %v", err)
+	}
+
+	for _, tc := range []struct {
+		spend int64
+		want  int64
+	}{
+		{50000, 1000},  // 500.00 USD
+		{100000, 800},  // 1000.00 USD reaches the first tier
+		{2000000, 600}, // 20000.00 USD
+	} {
+		if got := services.FeeRate(&rule, services.FeeVolume{Spend: tc.spend}, "USD"); got != tc.want {
+			t.Errorf("spend %d: rate %d, want %d", tc.spend, got, tc.want)
+		}
+	}
+
+	rule.VolumeBasis = models.FeeVolumeConversions
+	if got := services.FeeRate(&rule, services.FeeVolume{Conversions: 1500}, "USD"); got != 800 {
+		t.Errorf("conversions basis: rate %d, want 800", got)
+	}
+}
+
+func TestValidateFeeRule(t *testing.T) {
+	tenant := uuid.New()
+	advertiser := uuid.New()
+
+	rule := models.FeeRule{Scope: " Advertiser ", ScopeValue: advertiser.String(), RateBps: 1200, Mode: "Revenue_Share"}
+	if err := services.ValidateFeeRule(tenant, &rule, nil); err != nil {
+		t.Fatalf("valid rule rejected: %v", err)
+	}
+	if rule.Scope != models.FeeScopeAdvertiser || rule.Mode != models.FeeModeRevenueShare {
+		t.Errorf("rule was not normalized: %+v", rule)
+	}
+
+	plan := models.FeeRule{Scope: models.FeeScopePlan, ScopeValue: "pro", RateBps: 900}
+	if err := services.ValidateFeeRule(models.DefaultTenantID, &plan, nil); err != nil {
+		t.Errorf("platform plan rule rejected: %v", err)
+	}
+
+	for name, tc := range map[string]struct {
+		tenant uuid.UUID
+		rule   models.FeeRule
+		tiers  []models.FeeTier
+	}{
+		"unknown scope":          {tenant, models.FeeRule{Scope: "country"}, nil},
+		"tenant plan rule":       {tenant, models.FeeRule{Scope: models.FeeScopePlan, ScopeValue: "pro"}, nil},
+		"unknown plan":           {models.DefaultTenantID, models.FeeRule{Scope: models.FeeScopePlan, ScopeValue: "gold"}, nil},
+		"unknown tier":           {tenant, models.FeeRule{Scope: models.FeeScopePromoterTier, ScopeValue: "guru"}, nil},
+		"bad offer ID":           {tenant, models.FeeRule{Scope: models.FeeScopeOffer, ScopeValue: "abc"}, nil},
+		"rate too high":          {tenant, models.FeeRule{Scope: models.FeeScopeTenant, RateBps: 10001}, nil},
+		"negative minimum":       {tenant, models.FeeRule{Scope: models.FeeScopeTenant, MinFee: -1}, nil},
+		"unknown mode":           {tenant, models.FeeRule{Scope: models.FeeScopeTenant, Mode: "flat"}, nil},
+		"tiers without basis":    {tenant, models.FeeRule{Scope: models.FeeScopeTenant}, []models.FeeTier{{From: 10, RateBps: 500}}},
+		"basis without tiers":    {tenant, models.FeeRule{Scope: models.FeeScopeTenant, VolumeBasis: models.FeeVolumeSpend}, nil},
+		"duplicate tier volumes": {tenant, models.FeeRule{Scope: models.FeeScopeTenant, VolumeBasis: models.FeeVolumeConversions}, []models.FeeTier{{From: 10, RateBps: 500}, {From: 10, RateBps: 400}}},
+	} {
+		rule := tc.rule
+		if err := services.ValidateFeeRule(tc.tenant, &rule, tc.tiers); !errors.Is(err, services.ErrFeeRuleInvalid) {
+			t.Errorf("%s: expected ErrFeeRuleInvalid, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/google/uuid"
+)
+
+// ============================================
+// FRAUD CASES
+// ============================================
+
+func TestEscalateFraudSeverity(t *testing.T) {
+	for _, tc := range []struct {
+		current, incoming, want string
+	}{
+		{models.FraudCaseSeverityMedium, models.FraudCaseSeverityHigh, models.FraudCaseSeverityHigh},
+		{models.FraudCaseSeverityHigh, models.FraudCaseSeverityCritical, models.FraudCaseSeverityCritical},
+		{models.FraudCaseSeverityHigh, models.FraudCaseSeverityLow, models.FraudCaseSeverityHigh},
+		{models.FraudCaseSeverityCritical, models.FraudCaseSeverityMedium, models.FraudCaseSeverityCritical},
+		{models.FraudCaseSeverityLow, "bogus", models.FraudCaseSeverityLow},
+	} {
+		if got := services.EscalateFraudSeverity(tc.current, tc.incoming); got != tc.want {
+			t.Errorf("%s + %s: got %s, want %s", tc.current, tc.incoming, got, tc.want)
+		}
+	}
+}
+
+func TestFraudCaseLinkRowsDedupe(t *testing.T) {
+	caseID := uuid.New()
+	click, conversion := uuid.New(), uuid.New()
+
+	rows := services.FraudCaseLinkRows(caseID, services.FraudCaseLinks{
+		ClickIDs:      []string{click.String(), " " + click.String() + " ", "not-a-uuid"},
+		ConversionIDs: []string{conversion.String(), conversion.String()},
+		IPs:           []string{"203.0.113.7", " 203.0.113.7", "999.1.1.1", "2001:DB8::1", "2001:db8:0:0::1"},
+	})
+
+	want := map[string]bool{
+		models.FraudCaseLinkClick + ":" + click.String():           true,
+		models.FraudCaseLinkConversion + ":" + conversion.String(): true,
+		models.FraudCaseLinkIP + ":203.0.113.7":                    true,
+		models.FraudCaseLinkIP + ":2001:db8::1":                    true,
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("expected %d links, got %d: %+v", len(want), len(rows), rows)
+	}
+	for _, row := range rows {
+		if _, ok := want[row.EntityType+":"+row.EntityID]; !ok {
+			t.Errorf("unexpected link %s:%s", row.EntityType, row.EntityID)
+		}
+		if row.CaseID != caseID || row.ID == uuid.Nil {
+			t.Errorf("link not attached to the case: %+v", row)
+		}
+	}
+
+	if rows := services.FraudCaseLinkRows(caseID, services.FraudCaseLinks{IPs: []string{"bogus"}}); len(rows) != 0 {
+		t.Errorf("invalid entities must not be linked: %+v", rows)
+	}
+}
+
+func TestPlanFraudDecision(t *testing.T) {
+	for decision, want := range map[string]services.FraudDecisionPlan{
+		models.FraudDecisionClear:    {ReleaseHolds: true},
+		models.FraudDecisionClawback: {RejectPending: true, Reverse: true},
+		models.FraudDecisionBan:      {RejectPending: true, Reverse: true, Suspend: true, BlockIPs: true},
+	} {
+		got, err := services.PlanFraudDecision(decision)
+		if err != nil || got != want {
+			t.Errorf("%s: got %+v %v, want %+v", decision, got, err, want)
+		}
+	}
+
+	if _, err := services.PlanFraudDecision("refund"); !errors.Is(err, services.ErrFraudCaseDecision) {
+		t.Errorf("expected ErrFraudCaseDecision, got %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// ============================================
+// MULTI-CURRENCY
+// ============================================
+
+func TestMoneyConvertAndFormat(t *testing.T) {
+	kwd := services.MoneyFromMajor(12.345, "kwd")
+	if kwd.Amount != 12345 || kwd.Currency != "KWD" {
+		t.Fatalf("MoneyFromMajor = %+v; want 12345 KWD", kwd)
+	}
+	if got := kwd.String(); got != "12.345 KWD" {
+		t.Errorf("String() = %q; want %q", got, "12.345 KWD")
+	}
+
+	// 12.345 KWD at 3.25 USD per KWD = 40.12125 -> 40.12 USD
+	usd := kwd.Convert("USD", 3.25)
+	if usd.Amount != 4012 || usd.String() != "40.12 USD" {
+		t.Errorf("Convert = %s; want 40.12 USD", usd)
+	}
+	// 100.00 USD at 150.5 JPY per USD = 15050 JPY (no minor units)
+	if jpy := services.NewMoney(10000, "USD").Convert("JPY", 150.5); jpy.Amount != 15050 {
+		t.Errorf("USD->JPY = %d; want 15050", jpy.Amount)
+	}
+	if same := kwd.Convert("KWD", 9); same != kwd {
+		t.Errorf("converting to the same currency must not apply a rate, got %s", same)
+	}
+
+	if _, err := kwd.Add(usd); !errors.Is(err, services.ErrCurrencyMismatch) {
+		t.Errorf("adding KWD and USD must fail, got %v", err)
+	}
+	sum, err := kwd.Add(kwd.Neg())
+	if err != nil || !sum.IsZero() {
+		t.Errorf("x + -x = %s, %v; want zero", sum, err)
+	}
+}
+
+func TestParseFXRatesCSV(t *testing.T) {
+	input := "\uFEFFDate, Quote, Base, Rate\n2025-01-31, kwd, usd, 0.3085\n2025-01-31,EUR,USD,0.96\n"
+	quotes, err := services.ParseFXRatesCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseFXRatesCSV: %v", err)
+	}
+	if len(quotes) != 2 {
+		t.Fatalf("got %d quotes; want 2", len(quotes))
+	}
+	q := quotes[0]
+	if q.Base != "USD" || q.Quote != "KWD" || q.Rate != 0.3085 || q.Date.Format("2006-01-02") != "2025-01-31" {
+		t.Errorf("first quote = %+v", q)
+	}
+
+	for name, bad := range map[string]string{
+		"missing column": "date,base,rate\n2025-01-31,USD,1\n",
+		"bad rate":       "date,base,quote,rate\n2025-01-31,USD,EUR,-1\n",
+		"bad date":       "date,base,quote,rate\n31/01/2025,USD,EUR,0.9\n",
+		"bad currency":   "date,base,quote,rate\n2025-01-31,USD,EURO,0.9\n",
+	} {
+		if _, err := services.ParseFXRatesCSV(strings.NewReader(bad)); !errors.Is(err, services.ErrFXInvalidRate) {
+			t.Errorf("%s: got %v; want ErrFXInvalidRate", name, err)
+		}
+	}
+}
+
+func TestAggregatePeriodRowsReporting(t *testing.T) {
+	tenant, advertiser, promoter := uuid.New(), uuid.New(), uuid.New()
+	row := func(kind models.LedgerTransactionKind, commission, fee int64, rates string) services.LedgerPeriodRow {
+		return services.LedgerPeriodRow{
+			ID: uuid.New(), TenantID: tenant, AdvertiserID: &advertiser, PromoterID: promoter,
+			Kind: kind, Currency: "KWD", Commission: commission, PlatformFee: fee,
+			FXRates: datatypes.JSON(rates),
+		}
+	}
+
+	totals := services.AggregatePeriodRows([]services.LedgerPeriodRow{
+		row(models.LedgerKindConversionApproved, 1000, 100, `{"USD":3.2,"EUR":3.0}`),
+		row(models.LedgerKindConversionApproved, 2000, 200, `{"USD":3.3}`),
+		row(models.LedgerKindConversionReversed, -1000, -100, `{"USD":3.25}`),
+	})
+	if len(totals) != 1 {
+		t.Fatalf("got %d totals; want 1", len(totals))
+	}
+	total := totals[0]
+	if total.Commission != 2000 || total.PlatformFee != 200 || total.Conversions != 1 {
+		t.Errorf("total = %+v", total)
+	}
+
+	// Each row is converted at its own snapshot: 320 + 660 - 325 = 655 USD cents
+	usd, ok := total.Reporting["USD"]
+	if !ok || usd.Commission != 655 {
+		t.Errorf("USD commission = %+v (present %v); want 655", usd, ok)
+	}
+	if kwd := total.Reporting["KWD"]; kwd.Commission != 2000 || kwd.PlatformFee != 200 {
+		t.Errorf("original currency must always be reported, got %+v", kwd)
+	}
+	if _, ok := total.Reporting["EUR"]; ok {
+		t.Error("EUR must be dropped when not every posting had a rate for it")
+	}
+
+	// Merging keeps only currencies both sides can report in
+	merged := services.LedgerPeriodTotal{Currency: "KWD"}
+	merged.Merge(total)
+	merged.Merge(services.LedgerPeriodTotal{Currency: "KWD", Commission: 500, Conversions: 1,
+		Reporting: map[string]services.LedgerReportingAmount{"KWD": {Commission: 500}}})
+	if merged.Commission != 2500 || merged.Conversions != 2 {
+		t.Errorf("merged = %+v", merged)
+	}
+	if _, ok := merged.Reporting["USD"]; ok {
+		t.Error("USD must be dropped after merging a total without it")
+	}
+	if merged.Reporting["KWD"].Commission != 2500 {
+		t.Errorf("merged KWD = %+v; want 2500", merged.Reporting["KWD"])
+	}
+}
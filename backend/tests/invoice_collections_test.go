@@ -0,0 +1,336 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/database"
+	"github.com/aljapah/afftok-backend-prod/internal/handlers"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ============================================
+// INVOICE RECEIPTS & COLLECTIONS
+// ============================================
+
+var (
+	pdfReceipt  = []byte("%PDF-1.4\n1 0 obj << /Type /Catalog >> endobj\n%%EOF")
+	pngReceipt  = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	jpegReceipt = []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00")
+)
+
+func TestValidateReceipt(t *testing.T) {
+	for _, tc := range []struct {
+		data        []byte
+		contentType string
+		ext         string
+	}{
+		{pdfReceipt, "application/pdf", ".pdf"},
+		{pngReceipt, "image/png", ".png"},
+		{jpegReceipt, "image/jpeg", ".jpg"},
+	} {
+		contentType, ext, err := services.ValidateReceipt(tc.data, 1024)
+		if err != nil || contentType != tc.contentType || ext != tc.ext {
+			t.Errorf("%s: got %q %q %v", tc.contentType, contentType, ext, err)
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		data []byte
+		max  int64
+		want error
+	}{
+		"empty":          {nil, 1024, services.ErrReceiptEmpty},
+		"too large":      {pdfReceipt, 10, services.ErrReceiptTooLarge},
+		"html":           {[]byte("<html><script>alert(1)</script></html>"), 1024, services.ErrReceiptType},
+		"plain text":     {[]byte("paid, trust me"), 1024, services.ErrReceiptType},
+		"renamed binary": {[]byte("MZ\x90\x00\x03\x00\x00\x00"), 1024, services.ErrReceiptType},
+	} {
+		if _, _, err := services.ValidateReceipt(tc.data, tc.max); !errors.Is(err, tc.want) {
+			t.Errorf("%s: expected %v, got %v", name, tc.want, err)
+		}
+	}
+}
+
+func TestReceiptKeyIsUniquePerUpload(t *testing.T) {
+	tenant, invoice := uuid.New(), uuid.New()
+	first := services.ReceiptKey(tenant, invoice, ".pdf")
+	if !strings.HasPrefix(first, "receipts/"+tenant.String()+"/"+invoice.String()+"/") || !strings.HasSuffix(first, ".pdf") {
+		t.Errorf("unexpected key %q", first)
+	}
+	if first == services.ReceiptKey(tenant, invoice, ".pdf") {
+		t.Error("a new upload must not overwrite the previous receipt")
+	}
+}
+
+func TestLocalReceiptStorage(t *testing.T) {
+	dir := t.TempDir()
+	storage := services.NewLocalReceiptStorage(dir)
+	ctx := context.Background()
+
+	key := services.ReceiptKey(uuid.New(), uuid.New(), ".pdf")
+	if err := storage.Put(ctx, key, "application/pdf", pdfReceipt); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	data, err := storage.Get(ctx, key)
+	if err != nil || !bytes.Equal(data, pdfReceipt) {
+		t.Fatalf("get returned %q, %v", data, err)
+	}
+	if _, err := storage.Get(ctx, "receipts/missing.pdf"); !errors.Is(err, services.ErrReceiptNotFound) {
+		t.Errorf("expected ErrReceiptNotFound, got %v", err)
+	}
+
+	// Keys cannot escape the storage directory
+	if err := storage.Put(ctx, "../../outside.pdf", "application/pdf", pdfReceipt); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "outside.pdf")); err != nil {
+		t.Errorf("escaping key must be kept inside the directory: %v", err)
+	}
+}
+
+func TestS3ReceiptStorage(t *testing.T) {
+	objects := map[string][]byte{}
+	var auth []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			sum := sha256.Sum256(body)
+			if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			objects[r.URL.Path] = body
+		case http.MethodGet:
+			body, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(body)
+		}
+	}))
+	defer server.Close()
+
+	storage, err := services.NewS3ReceiptStorage(services.S3StorageConfig{
+		Endpoint:  server.URL,
+		Region:    "eu-central-1",
+		Bucket:    "receipts",
+		AccessKey: "AKIDEXAMPLE",
+		SecretKey: "secret",
+		Now:       func() time.Time { return time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC) },
+	})
+	if err != nil {
+		t.Fatalf("storage not created: %v", err)
+	}
+	ctx := context.Background()
+	if err := storage.Put(ctx, "receipts/a/b/c.png", "image/png", pngReceipt); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	if _, ok := objects["/receipts/receipts/a/b/c.png"]; !ok {
+		t.Fatalf("object must be stored path-style in the bucket, got %v", objects)
+	}
+	data, err := storage.Get(ctx, "receipts/a/b/c.png")
+	if err != nil || !bytes.Equal(data, pngReceipt) {
+		t.Fatalf("get returned %q, %v", data, err)
+	}
+	if _, err := storage.Get(ctx, "receipts/missing.png"); !errors.Is(err, services.ErrReceiptNotFound) {
+		t.Errorf("expected ErrReceiptNotFound, got %v", err)
+	}
+
+	wantPrefix := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20261001/eu-central-1/s3/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date, Signature="
+	if !strings.HasPrefix(auth[0], wantPrefix) {
+		t.Errorf("unexpected upload authorization %q", auth[0])
+	}
+	if !strings.Contains(auth[1], "SignedHeaders=host;x-amz-content-sha256;x-amz-date,") {
+		t.Errorf("unexpected download authorization %q", auth[1])
+	}
+
+	if _, err := services.NewS3ReceiptStorage(services.S3StorageConfig{Endpoint: server.URL}); err == nil {
+		t.Error("a bucket and credentials are required")
+	}
+	if _, err := services.NewReceiptStorage(services.ReceiptStorageConfig{Backend: "ftp"}); err == nil {
+		t.Error("unknown backends must be rejected")
+	}
+}
+
+func TestInvoiceReminderDue(t *testing.T) {
+	due := time.Date(2026, 11, 7, 0, 0, 0, 0, time.UTC)
+	offsets := []int{-3, 0, 3, 7}
+	intp := func(v int) *int { return &v }
+
+	for _, tc := range []struct {
+		name string
+		last *int
+		now  time.Time
+		want int
+		ok   bool
+	}{
+		{"too early", nil, due.AddDate(0, 0, -4), 0, false},
+		{"three days before", nil, due.AddDate(0, 0, -3), -3, true},
+		{"already reminded", intp(-3), due.AddDate(0, 0, -1), 0, false},
+		{"due day", intp(-3), due, 0, true},
+		{"missed reminders collapse", intp(-3), due.AddDate(0, 0, 10), 7, true},
+		{"all sent", intp(7), due.AddDate(0, 0, 30), 0, false},
+	} {
+		got, ok := services.InvoiceReminderDue(offsets, due, tc.last, tc.now)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("%s: got %d/%v, want %d/%v", tc.name, got, ok, tc.want, tc.ok)
+		}
+	}
+
+	if _, ok := services.InvoiceReminderDue(nil, due, nil, due.AddDate(1, 0, 0)); ok {
+		t.Error("no offsets, no reminders")
+	}
+}
+
+func TestInvoicePauseDue(t *testing.T) {
+	due := time.Date(2026, 11, 7, 0, 0, 0, 0, time.UTC)
+	if services.InvoicePauseDue(0, due, due.AddDate(1, 0, 0)) {
+		t.Error("pause after 0 days means never")
+	}
+	if services.InvoicePauseDue(14, due, due.AddDate(0, 0, 13)) {
+		t.Error("offers must not be paused before the limit")
+	}
+	if !services.InvoicePauseDue(14, due, due.AddDate(0, 0, 14)) {
+		t.Error("offers must be paused once the limit is reached")
+	}
+}
+
+func TestInvoiceReminderSubject(t *testing.T) {
+	for offset, want := range map[int]string{
+		-3: "Invoice INV-000042 is due in 3 days",
+		-1: "Invoice INV-000042 is due tomorrow",
+		0:  "Invoice INV-000042 is due today",
+		1:  "Invoice INV-000042 is 1 day overdue",
+		7:  "Invoice INV-000042 is 7 days overdue",
+	} {
+		if got := services.InvoiceReminderSubject("INV-000042", offset); got != want {
+			t.Errorf("offset %d: got %q, want %q", offset, got, want)
+		}
+	}
+}
+
+func TestPausedOffersRedirectToFallback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("FALLBACK_REDIRECT_URL", "https://fallback.example")
+
+	cases := []struct {
+		status, reason, want string
+	}{
+		{"paused", models.OfferPausedInvoiceOverdue, "https://fallback.example"},
+		{"paused", models.OfferPausedWalletExhausted, "https://fallback.example"},
+		{"paused", "", "https://fallback.example"},
+		{"active", "", "https://shop.example/landing"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.status+"/"+tc.reason, func(t *testing.T) {
+			db, store := newMemDB(t)
+			previous := database.DB
+			database.DB = db
+			defer func() { database.DB = previous }()
+
+			offerID, userOfferID := uuid.New(), uuid.New()
+			store.insert("offers", map[string]interface{}{
+				"id": offerID.String(), "tenant_id": models.DefaultTenantID.String(), "status": tc.status,
+				"paused_reason": tc.reason, "destination_url": "https://shop.example/landing",
+			})
+			store.insert("user_offers", map[string]interface{}{
+				"id": userOfferID.String(), "tenant_id": models.DefaultTenantID.String(), "offer_id": offerID.String(),
+				"user_id": uuid.NewString(), "tracking_code": "pausedcode", "status": "active",
+			})
+
+			router := gin.New()
+			router.GET("/api/c/:id", handlers.NewClickHandler(db).TrackClick)
+
+			// A tampered link still redirects to a live offer, never to a paused one
+			tampered := fmt.Sprintf("pausedcode.%d.nonce.badsignature", time.Now().Unix())
+			req := httptest.NewRequest(http.MethodGet, "/api/c/"+tampered, nil)
+			req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != http.StatusFound || rec.Header().Get("Location") != tc.want {
+				t.Errorf("tampered link: %d %q; want redirect to %q", rec.Code, rec.Header().Get("Location"), tc.want)
+			}
+
+			if tc.status != "paused" {
+				return
+			}
+			req = httptest.NewRequest(http.MethodGet, "/api/c/"+userOfferID.String(), nil)
+			req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)")
+			rec = httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != http.StatusFound || rec.Header().Get("Location") != tc.want {
+				t.Errorf("tracked link: %d %q; want redirect to %q", rec.Code, rec.Header().Get("Location"), tc.want)
+			}
+			if len(store.table("clicks")) != 0 {
+				t.Error("a click was recorded for a paused offer")
+			}
+		})
+	}
+}
+
+func TestRunCollectionsSendsEachReminderOnceAcrossReplicas(t *testing.T) {
+	db, store := newMemDB(t)
+	email := &captureEmail{}
+	svc := services.NewInvoiceCollectionsService(db)
+	svc.SetEmailSender(email)
+
+	tenantID, advertiserID := uuid.New(), uuid.New()
+	store.insert("afftok_users", map[string]interface{}{
+		"id": advertiserID.String(), "tenant_id": tenantID.String(), "email": "billing@advertiser.example", "role": "advertiser",
+	})
+	store.insert("invoices", map[string]interface{}{
+		"id": uuid.NewString(), "tenant_id": tenantID.String(), "advertiser_id": advertiserID.String(),
+		"number": "INV-000001", "status": models.InvoiceStatusPending, "currency": "USD", "total_amount": 100.0,
+		"due_date": time.Now().Add(-time.Hour), "last_reminder_offset": nil,
+	})
+	// Both replicas load the invoice before either records the reminder
+	store.delayOn[`FROM "invoices"`] = 20 * time.Millisecond
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := svc.RunCollections(time.Now()); err != nil {
+				t.Errorf("RunCollections: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(email.sent) != 1 || email.sent[0].Subject != "Invoice INV-000001 is due today" {
+		t.Fatalf("sent %+v; want one due-today reminder", email.sent)
+	}
+	if offset := store.table("invoices")[0]["last_reminder_offset"]; offset != int64(0) {
+		t.Errorf("last_reminder_offset = %v; want 0", offset)
+	}
+
+	// The next run has nothing new to send
+	if _, err := svc.RunCollections(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if len(email.sent) != 1 {
+		t.Errorf("a reminder was sent again: %+v", email.sent)
+	}
+}
@@ -0,0 +1,229 @@
+package tests
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/google/uuid"
+)
+
+// ============================================
+// INVOICES
+// ============================================
+
+func TestBuildInvoiceDraftsGroupsByOfferAndGoal(t *testing.T) {
+	advertiser := uuid.New()
+	offerA, offerB := uuid.New(), uuid.New()
+	rows := []services.InvoiceLineRow{
+		{AdvertiserID: advertiser, OfferID: &offerB, OfferTitle: "Beta", Goal: "sale", Kind: models.LedgerKindConversionApproved, Currency: "USD", Commission: 1000, PlatformFee: 100},
+		{AdvertiserID: advertiser, OfferID: &offerA, OfferTitle: "Alpha", Goal: "lead", Kind: models.LedgerKindConversionApproved, Currency: "USD", Commission: 500, PlatformFee: 50},
+		{AdvertiserID: advertiser, OfferID: &offerA, OfferTitle: "Alpha", Goal: "lead", Kind: models.LedgerKindConversionApproved, Currency: "USD", Commission: 500, PlatformFee: 50},
+		{AdvertiserID: advertiser, OfferID: &offerA, OfferTitle: "Alpha", Goal: "lead", Kind: models.LedgerKindConversionReversed, Currency: "USD", Commission: -500, PlatformFee: -50},
+		{AdvertiserID: advertiser, OfferID: &offerA, OfferTitle: "Alpha", Goal: "sale", Kind: models.LedgerKindConversionApproved, Currency: "USD", Commission: 2000, PlatformFee: 200},
+		{AdvertiserID: advertiser, OfferID: &offerA, OfferTitle: "Alpha", Goal: "lead", Kind: models.LedgerKindConversionApproved, Currency: "SAR", Commission: 300, PlatformFee: 30},
+	}
+
+	drafts := services.BuildInvoiceDrafts(rows)
+	if len(drafts) != 2 {
+		t.Fatalf("got %d drafts; want one per currency", len(drafts))
+	}
+	usd := drafts[0]
+	if usd.Currency != "USD" || len(usd.Lines) != 3 {
+		t.Fatalf("USD draft = %+v", usd)
+	}
+	if l := usd.Lines[0]; l.OfferTitle != "Alpha" || l.Goal != "lead" || l.Conversions != 1 || l.PlatformFee != 50 {
+		t.Errorf("first line = %+v; want Alpha/lead with the reversal netted", l)
+	}
+	if l := usd.Lines[2]; l.OfferTitle != "Beta" {
+		t.Errorf("lines not sorted by offer: %+v", usd.Lines)
+	}
+	if usd.Conversions != 3 || usd.Commission != 3500 || usd.PlatformFee != 350 {
+		t.Errorf("totals = %d/%d/%d; want 3/3500/350", usd.Conversions, usd.Commission, usd.PlatformFee)
+	}
+}
+
+func TestInvoiceDraftFinalize(t *testing.T) {
+	vat := &models.TaxRule{Name: "VAT", RateBps: 1500, Active: true}
+
+	d := &services.InvoiceDraft{PlatformFee: 10000, Adjustments: []models.InvoiceAdjustment{{Amount: -2000}}}
+	d.Finalize(vat)
+	if d.Subtotal != 8000 || d.Tax != 1200 || d.Total != 9200 || d.CarryOver != 0 {
+		t.Errorf("taxed draft = subtotal %d tax %d total %d", d.Subtotal, d.Tax, d.Total)
+	}
+
+	d.Finalize(&models.TaxRule{Name: "VAT", RateBps: 1500, ReverseCharge: true, Active: true})
+	if d.Tax != 0 || d.Total != 8000 {
+		t.Errorf("reverse charge must not add tax: tax %d total %d", d.Tax, d.Total)
+	}
+
+	credit := &services.InvoiceDraft{PlatformFee: 1000, Adjustments: []models.InvoiceAdjustment{{Amount: -3000}}}
+	credit.Finalize(vat)
+	if credit.CarryOver != 2000 || credit.Subtotal != 0 || credit.Tax != 0 || credit.Total != 0 {
+		t.Errorf("credit draft = carry %d subtotal %d total %d; want the credit carried over", credit.CarryOver, credit.Subtotal, credit.Total)
+	}
+	if !credit.Billable() || (&services.InvoiceDraft{}).Billable() {
+		t.Error("only drafts with fees or adjustments are billable")
+	}
+}
+
+func TestTaxAmountRoundsHalfUp(t *testing.T) {
+	cases := []struct{ base, bps, want int64 }{
+		{1000, 1500, 150},
+		{333, 1500, 50}, // 49.95
+		{1, 500, 0},     // 0.05
+		{-1000, 1500, 0},
+	}
+	for _, c := range cases {
+		if got := services.TaxAmount(c.base, c.bps); got != c.want {
+			t.Errorf("TaxAmount(%d, %d) = %d; want %d", c.base, c.bps, got, c.want)
+		}
+	}
+}
+
+func TestMatchTaxRulePriority(t *testing.T) {
+	tenant := uuid.New()
+	rule := func(owner uuid.UUID, country, name string) models.TaxRule {
+		r := models.TaxRule{Country: country, Name: name, Active: true}
+		r.TenantID = owner
+		return r
+	}
+	rules := []models.TaxRule{
+		rule(models.DefaultTenantID, "*", "platform-any"),
+		rule(models.DefaultTenantID, "SA", "platform-sa"),
+		rule(tenant, "*", "tenant-any"),
+		rule(tenant, "AE", "tenant-ae"),
+	}
+
+	for country, want := range map[string]string{"ae": "tenant-ae", "SA": "tenant-any", "": "tenant-any"} {
+		if got := services.MatchTaxRule(rules, tenant, country); got == nil || got.Name != want {
+			t.Errorf("MatchTaxRule(%q) = %v; want %s", country, got, want)
+		}
+	}
+	if got := services.MatchTaxRule(rules, uuid.New(), "SA"); got == nil || got.Name != "platform-sa" {
+		t.Errorf("other tenant = %v; want platform-sa", got)
+	}
+
+	rules[1].Active = false
+	if got := services.MatchTaxRule(rules, uuid.New(), "SA"); got == nil || got.Name != "platform-any" {
+		t.Errorf("inactive rule matched: %v", got)
+	}
+}
+
+func TestInvoiceNumberAndAmountFormatting(t *testing.T) {
+	if got := services.FormatInvoiceNumber(42); got != "INV-000042" {
+		t.Errorf("FormatInvoiceNumber = %s", got)
+	}
+	if got := services.FormatInvoiceAmount(1234567.5, "USD"); got != "1,234,567.50" {
+		t.Errorf("USD amount = %s", got)
+	}
+	if got := services.FormatInvoiceAmount(-1234.5, "KWD"); got != "-1,234.500" {
+		t.Errorf("KWD amount = %s", got)
+	}
+}
+
+func TestRenderInvoicePDF(t *testing.T) {
+	offerID := uuid.New()
+	now := time.Now()
+	invoice := &models.Invoice{
+		Number:         "INV-000001",
+		IssuedAt:       &now,
+		Year:           2025,
+		Month:          3,
+		PeriodStart:    time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		PeriodEnd:      time.Date(2025, 3, 31, 23, 59, 59, 0, time.UTC),
+		DueDate:        time.Date(2025, 4, 7, 0, 0, 0, 0, time.UTC),
+		Currency:       "SAR",
+		Status:         "pending",
+		PlatformAmount: 100,
+		Subtotal:       100,
+		TaxName:        "VAT",
+		TaxRate:        0.15,
+		TaxAmount:      15,
+		TotalAmount:    115,
+		Advertiser:     &models.AfftokUser{CompanyName: "شركة الاختبار", Email: "billing@example.com"},
+	}
+	items := []models.InvoiceItem{
+		{Kind: models.InvoiceItemConversions, OfferID: &offerID, OfferTitle: "Summer sale", Goal: "purchase", Conversions: 10, PromoterPayout: 1000, PlatformAmount: 100},
+	}
+	for i := 0; i < 60; i++ { // forces a second page
+		items = append(items, models.InvoiceItem{Kind: models.InvoiceItemAdjustment, Description: "تسوية يدوية", PlatformAmount: 0})
+	}
+
+	pdf, err := services.RenderInvoicePDF(services.InvoiceDocument{
+		Invoice:       invoice,
+		Items:         items,
+		OfferTitlesAr: map[uuid.UUID]string{offerID: "تخفيضات الصيف"},
+		IssuerName:    "AffTok",
+	})
+	if err != nil {
+		t.Fatalf("RenderInvoicePDF: %v", err)
+	}
+	if !bytes.HasPrefix(pdf, []byte("%PDF-")) || !bytes.Contains(pdf, []byte("%%EOF")) {
+		t.Fatal("output is not a PDF document")
+	}
+	if bytes.Count(pdf, []byte("/Type /Page ")) < 2 {
+		t.Error("long invoices must continue on a second page")
+	}
+}
+
+func TestGenerateMonthlyRerunKeepsNumbers(t *testing.T) {
+	db, store := newMemDB(t)
+	store.unique["invoice_sequences"] = []string{"tenant_id"}
+	svc := services.NewInvoiceService(db)
+	svc.SetLedgerService(services.NewLedgerService(db))
+
+	tenantID := uuid.New()
+	advertisers := []uuid.UUID{uuid.New(), uuid.New()}
+	var lines [][]driver.Value
+	for _, id := range advertisers {
+		store.insert("afftok_users", map[string]interface{}{
+			"id": id.String(), "tenant_id": tenantID.String(), "role": "advertiser", "country": "US",
+		})
+		lines = append(lines, []driver.Value{
+			id.String(), nil, "Offer", "", "", string(models.LedgerKindConversionApproved), "USD", int64(5000), int64(500),
+		})
+	}
+	store.respond("AS offer_title", []string{
+		"advertiser_id", "offer_id", "offer_title", "offer_title_ar", "goal", "kind", "currency", "commission", "platform_fee",
+	}, lines...)
+	store.respond("t.fx_rates", []string{"id"})
+
+	first, err := svc.GenerateMonthly(tenantID, 2026, 1, nil)
+	if err != nil {
+		t.Fatalf("GenerateMonthly: %v", err)
+	}
+	if first.Created != 2 {
+		t.Fatalf("first run created %d invoices; want 2 (errors %v)", first.Created, first.Errors)
+	}
+	numbers := map[string]string{}
+	for _, row := range store.table("invoices") {
+		numbers[row["advertiser_id"].(string)] = row["number"].(string)
+	}
+	if len(numbers) != 2 || numbers[advertisers[0].String()] == numbers[advertisers[1].String()] {
+		t.Fatalf("numbers = %v; want one distinct number per advertiser", numbers)
+	}
+
+	second, err := svc.GenerateMonthly(tenantID, 2026, 1, nil)
+	if err != nil {
+		t.Fatalf("rerun: %v", err)
+	}
+	if second.Created != 0 || second.Skipped != 2 {
+		t.Errorf("rerun created %d, skipped %d; want 0 and 2", second.Created, second.Skipped)
+	}
+	invoices := store.table("invoices")
+	if len(invoices) != 2 {
+		t.Fatalf("%d invoices after the rerun; want 2", len(invoices))
+	}
+	for _, row := range invoices {
+		if number := numbers[row["advertiser_id"].(string)]; row["number"] != number {
+			t.Errorf("invoice of %v renumbered %v; want %s", row["advertiser_id"], row["number"], number)
+		}
+	}
+	if seq := store.table("invoice_sequences"); len(seq) != 1 || seq[0]["last_number"] != int64(2) {
+		t.Errorf("sequence = %v; want last_number 2", seq)
+	}
+}
@@ -0,0 +1,317 @@
+package tests
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+)
+
+// ============================================
+// KYC PROVIDER ADAPTERS
+// ============================================
+
+const kycUserID = "3f2a4c5e-8f0b-4d3e-9a41-6f1f2b7d9c10"
+
+var kycNow = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+// kycFixture loads a recorded provider payload from testdata/kyc
+func kycFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "kyc", name))
+	if err != nil {
+		t.Fatalf("fixture %s: %v", name, err)
+	}
+	return data
+}
+
+func TestSumSubCreateApplicantAndToken(t *testing.T) {
+	var requests []*http.Request
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r)
+		bodies = append(bodies, body)
+		switch r.URL.Path {
+		case "/resources/applicants":
+			w.Write(kycFixture(t, "sumsub_applicant_created_response.json"))
+		case "/resources/accessTokens":
+			w.Write(kycFixture(t, "sumsub_access_token_response.json"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"description":"not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	provider := services.NewSumSubProvider(services.SumSubConfig{
+		AppToken:  "sbx:app-token",
+		SecretKey: "sumsub-secret",
+		LevelName: "basic-kyc-level",
+		BaseURL:   server.URL + "/",
+		Now:       func() time.Time { return kycNow },
+	})
+
+	applicant, err := provider.CreateApplicant(context.Background(), &services.KYCApplicantRequest{
+		ExternalUserID: kycUserID,
+		Email:          "sara@example.com",
+		FirstName:      "Sara",
+		LastName:       "Alkandari",
+		Country:        "KWT",
+	})
+	if err != nil {
+		t.Fatalf("create applicant: %v", err)
+	}
+	if applicant.ApplicantID != "5cb56e8e0a975a35f333cb83" || applicant.ExternalUserID != kycUserID {
+		t.Errorf("unexpected applicant %+v", applicant)
+	}
+
+	// Requests are signed with ts + method + path + body
+	req := requests[0]
+	ts := strconv.FormatInt(kycNow.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte("sumsub-secret"))
+	mac.Write([]byte(ts + http.MethodPost + req.URL.RequestURI()))
+	mac.Write(bodies[0])
+	if req.Header.Get("X-App-Access-Sig") != hex.EncodeToString(mac.Sum(nil)) || req.Header.Get("X-App-Access-Ts") != ts {
+		t.Error("create applicant request is not signed correctly")
+	}
+	if req.Header.Get("X-App-Token") != "sbx:app-token" || req.URL.Query().Get("levelName") != "basic-kyc-level" {
+		t.Errorf("unexpected request %s %v", req.URL, req.Header)
+	}
+	var sent map[string]interface{}
+	json.Unmarshal(bodies[0], &sent)
+	if sent["externalUserId"] != kycUserID || sent["fixedInfo"].(map[string]interface{})["country"] != "KWT" {
+		t.Errorf("unexpected applicant payload %s", bodies[0])
+	}
+
+	token, err := provider.GetAccessToken(context.Background(), applicant)
+	if err != nil {
+		t.Fatalf("access token: %v", err)
+	}
+	if token.Token != "_act-sbx-b4c1f3d2-7a1e-4b0f-9c2d-8e5f6a7b8c9d" || !token.ExpiresAt.Equal(kycNow.Add(10*time.Minute)) {
+		t.Errorf("unexpected token %+v", token)
+	}
+	if q := requests[1].URL.Query(); q.Get("userId") != kycUserID || q.Get("ttlInSecs") != "600" {
+		t.Errorf("unexpected token query %v", q)
+	}
+
+	if _, err := services.NewSumSubProvider(services.SumSubConfig{BaseURL: server.URL}).CreateApplicant(context.Background(), &services.KYCApplicantRequest{}); !errors.Is(err, services.ErrKYCProviderDisabled) {
+		t.Errorf("expected ErrKYCProviderDisabled, got %v", err)
+	}
+}
+
+func TestSumSubWebhookSignature(t *testing.T) {
+	provider := services.NewSumSubProvider(services.SumSubConfig{WebhookSecret: "hook-secret"})
+	body := kycFixture(t, "sumsub_applicant_reviewed_green.json")
+
+	sha1Mac := hmac.New(sha1.New, []byte("hook-secret"))
+	sha1Mac.Write(body)
+	sha256Mac := hmac.New(sha256.New, []byte("hook-secret"))
+	sha256Mac.Write(body)
+
+	for name, tc := range map[string]struct {
+		digest, alg string
+		want        error
+	}{
+		"sha1 default":  {hex.EncodeToString(sha1Mac.Sum(nil)), "", nil},
+		"sha256":        {hex.EncodeToString(sha256Mac.Sum(nil)), "HMAC_SHA256_HEX", nil},
+		"wrong alg":     {hex.EncodeToString(sha1Mac.Sum(nil)), "HMAC_SHA256_HEX", services.ErrKYCInvalidSignature},
+		"unknown alg":   {hex.EncodeToString(sha256Mac.Sum(nil)), "MD5", services.ErrKYCInvalidSignature},
+		"missing":       {"", "", services.ErrKYCInvalidSignature},
+		"tampered body": {hex.EncodeToString(sha256Mac.Sum([]byte("x"))), "HMAC_SHA256_HEX", services.ErrKYCInvalidSignature},
+	} {
+		headers := http.Header{}
+		headers.Set("X-Payload-Digest", tc.digest)
+		headers.Set("X-Payload-Digest-Alg", tc.alg)
+		if err := provider.VerifyWebhookSignature(headers, body); !errors.Is(err, tc.want) {
+			t.Errorf("%s: expected %v, got %v", name, tc.want, err)
+		}
+	}
+
+	if err := services.NewSumSubProvider(services.SumSubConfig{}).VerifyWebhookSignature(http.Header{}, body); !errors.Is(err, services.ErrKYCProviderDisabled) {
+		t.Errorf("expected ErrKYCProviderDisabled, got %v", err)
+	}
+}
+
+func TestSumSubMapResult(t *testing.T) {
+	provider := services.NewSumSubProvider(services.SumSubConfig{})
+
+	for fixture, want := range map[string]services.KYCProviderResult{
+		"sumsub_applicant_pending.json": {
+			EventType: "applicantPending",
+			Decision:  services.KYCDecisionInReview,
+			Steps:     []services.KYCStepResult{{Name: "documents", Status: "submitted"}},
+		},
+		"sumsub_applicant_reviewed_green.json": {
+			EventType: "applicantReviewed",
+			Decision:  services.KYCDecisionApproved,
+			Steps:     []services.KYCStepResult{{Name: "review", Status: "approved"}},
+		},
+		"sumsub_applicant_reviewed_retry.json": {
+			EventType:        "applicantReviewed",
+			Decision:         services.KYCDecisionResubmission,
+			RejectionReasons: []string{"UNSATISFACTORY_PHOTOS", "SELFIE_MISMATCH"},
+			Comment:          "The photo of your document is blurry. Please upload a clear photo.",
+			Steps: []services.KYCStepResult{
+				{Name: "identity", Status: "rejected", Reasons: []string{"UNSATISFACTORY_PHOTOS"}},
+				{Name: "selfie", Status: "rejected", Reasons: []string{"SELFIE_MISMATCH"}},
+			},
+		},
+		"sumsub_applicant_reviewed_final.json": {
+			EventType:        "applicantReviewed",
+			Decision:         services.KYCDecisionRejected,
+			RejectionReasons: []string{"FORGERY"},
+			Comment:          "Document forgery detected",
+			Steps:            []services.KYCStepResult{{Name: "identity", Status: "rejected", Reasons: []string{"FORGERY"}}},
+		},
+	} {
+		got, err := provider.MapResult(kycFixture(t, fixture))
+		if err != nil {
+			t.Fatalf("%s: %v", fixture, err)
+		}
+		want.Provider = "sumsub"
+		want.ApplicantID = "5cb56e8e0a975a35f333cb83"
+		want.ExternalUserID = kycUserID
+		if !reflect.DeepEqual(*got, want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", fixture, *got, want)
+		}
+	}
+
+	if _, err := provider.MapResult([]byte(`{"type":"applicantReviewed"}`)); err == nil {
+		t.Error("a payload without applicantId must be rejected")
+	}
+}
+
+func TestVeriffCreateSession(t *testing.T) {
+	var signature, client string
+	var sent map[string]map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		signature, client = r.Header.Get("X-HMAC-SIGNATURE"), r.Header.Get("X-AUTH-CLIENT")
+		json.Unmarshal(body, &sent)
+
+		mac := hmac.New(sha256.New, []byte("veriff-secret"))
+		mac.Write(body)
+		if r.URL.Path != "/v1/sessions" || signature != hex.EncodeToString(mac.Sum(nil)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write(kycFixture(t, "veriff_session_response.json"))
+	}))
+	defer server.Close()
+
+	provider := services.NewVeriffProvider(services.VeriffConfig{
+		APIKey:       "veriff-key",
+		SharedSecret: "veriff-secret",
+		CallbackURL:  "https://app.afftok.com/kyc/done",
+		BaseURL:      server.URL,
+		Now:          func() time.Time { return kycNow },
+	})
+
+	token, err := provider.GetAccessToken(context.Background(), &services.KYCApplicant{ExternalUserID: kycUserID})
+	if err != nil {
+		t.Fatalf("access token: %v", err)
+	}
+	if token.ApplicantID != "f04bdb47-d3be-4b28-b028-a652feb060b5" ||
+		token.VerificationURL != "https://alchemy.veriff.com/v/eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9.sample" ||
+		!token.ExpiresAt.Equal(kycNow.Add(7*24*time.Hour)) {
+		t.Errorf("unexpected token %+v", token)
+	}
+	if client != "veriff-key" || sent["verification"]["vendorData"] != kycUserID || sent["verification"]["callback"] != "https://app.afftok.com/kyc/done" {
+		t.Errorf("unexpected session request %v", sent)
+	}
+
+	// An existing session is reused without calling the API
+	signature = ""
+	existing := &services.KYCApplicant{ApplicantID: "s1", VerificationURL: "https://alchemy.veriff.com/v/s1", SessionToken: "t1"}
+	if token, err := provider.GetAccessToken(context.Background(), existing); err != nil || token.ApplicantID != "s1" || signature != "" {
+		t.Errorf("existing session must be reused: %+v %v", token, err)
+	}
+}
+
+func TestVeriffWebhookSignature(t *testing.T) {
+	provider := services.NewVeriffProvider(services.VeriffConfig{APIKey: "veriff-key", SharedSecret: "veriff-secret"})
+	body := kycFixture(t, "veriff_decision_approved.json")
+	mac := hmac.New(sha256.New, []byte("veriff-secret"))
+	mac.Write(body)
+	valid := hex.EncodeToString(mac.Sum(nil))
+
+	for name, tc := range map[string]struct {
+		signature, client string
+		want              error
+	}{
+		"valid":          {valid, "veriff-key", nil},
+		"no client":      {valid, "", nil},
+		"other client":   {valid, "someone-else", services.ErrKYCInvalidSignature},
+		"bad signature":  {valid[:len(valid)-2] + "00", "veriff-key", services.ErrKYCInvalidSignature},
+		"missing header": {"", "veriff-key", services.ErrKYCInvalidSignature},
+	} {
+		headers := http.Header{}
+		headers.Set("X-HMAC-SIGNATURE", tc.signature)
+		headers.Set("X-AUTH-CLIENT", tc.client)
+		if err := provider.VerifyWebhookSignature(headers, body); !errors.Is(err, tc.want) {
+			t.Errorf("%s: expected %v, got %v", name, tc.want, err)
+		}
+	}
+}
+
+func TestVeriffMapResult(t *testing.T) {
+	provider := services.NewVeriffProvider(services.VeriffConfig{})
+
+	for fixture, want := range map[string]services.KYCProviderResult{
+		"veriff_event_submitted.json": {
+			EventType: "event:submitted",
+			Decision:  services.KYCDecisionInReview,
+			Steps:     []services.KYCStepResult{{Name: "documents", Status: "submitted"}},
+		},
+		"veriff_decision_approved.json": {
+			EventType: "decision:approved",
+			Decision:  services.KYCDecisionApproved,
+			Steps:     []services.KYCStepResult{{Name: "decision", Status: "approved"}},
+		},
+		"veriff_decision_declined.json": {
+			EventType:        "decision:declined",
+			Decision:         services.KYCDecisionRejected,
+			RejectionReasons: []string{"102", "Physical document not used"},
+			Comment:          "Physical document not used",
+			Steps:            []services.KYCStepResult{{Name: "decision", Status: "declined", Reasons: []string{"102", "Physical document not used"}}},
+		},
+		"veriff_decision_resubmission.json": {
+			EventType:        "decision:resubmission_requested",
+			Decision:         services.KYCDecisionResubmission,
+			RejectionReasons: []string{"201", "Video and/or photos missing"},
+			Comment:          "Video and/or photos missing",
+			Steps:            []services.KYCStepResult{{Name: "decision", Status: "resubmission_requested", Reasons: []string{"201", "Video and/or photos missing"}}},
+		},
+	} {
+		got, err := provider.MapResult(kycFixture(t, fixture))
+		if err != nil {
+			t.Fatalf("%s: %v", fixture, err)
+		}
+		want.Provider = "veriff"
+		want.ApplicantID = "f04bdb47-d3be-4b28-b028-a652feb060b5"
+		want.ExternalUserID = kycUserID
+		if !reflect.DeepEqual(*got, want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", fixture, *got, want)
+		}
+	}
+
+	if _, err := provider.MapResult([]byte(`{"status":"success","verification":{"status":"approved"}}`)); err == nil {
+		t.Error("a decision without a verification id must be rejected")
+	}
+}
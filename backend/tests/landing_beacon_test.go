@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/google/uuid"
+)
+
+// ============================================
+// LANDING BEACONS
+// ============================================
+
+func TestBeaconTokenRoundTrip(t *testing.T) {
+	t.Setenv("BEACON_TOKEN_SECRET", "beacon-test-secret")
+	svc := services.NewLandingBeaconService(nil)
+	clickID := uuid.New()
+
+	got, err := svc.ParseToken(svc.IssueToken(clickID))
+	if err != nil || got != clickID {
+		t.Fatalf("round trip returned %s, %v", got, err)
+	}
+
+	t.Setenv("BEACON_TOKEN_SECRET", "other-secret")
+	foreign := services.NewLandingBeaconService(nil).IssueToken(clickID)
+
+	payload, sig, _ := strings.Cut(svc.IssueToken(clickID), ".")
+	for name, bad := range map[string]string{
+		"empty":           "",
+		"no signature":    payload,
+		"bad signature":   payload + ".AAAAAAAAAAAAAAAAAAAAAA",
+		"swapped payload": strings.Split(svc.IssueToken(uuid.New()), ".")[0] + "." + sig,
+		"not base64":      "!!!." + sig,
+		"other secret":    foreign,
+	} {
+		if _, err := svc.ParseToken(bad); !errors.Is(err, services.ErrBeaconInvalidToken) {
+			t.Errorf("%s: expected ErrBeaconInvalidToken, got %v", name, err)
+		}
+	}
+}
+
+func TestBeaconAppendToken(t *testing.T) {
+	t.Setenv("BEACON_TOKEN_SECRET", "beacon-test-secret")
+	svc := services.NewLandingBeaconService(nil)
+	clickID := uuid.New()
+
+	dest := svc.AppendToken("https://shop.example/landing?utm_source=afftok#top", clickID)
+	u, err := url.Parse(dest)
+	if err != nil {
+		t.Fatalf("invalid destination %q", dest)
+	}
+	if u.Query().Get("utm_source") != "afftok" || u.Fragment != "top" {
+		t.Errorf("existing query and fragment must be kept: %q", dest)
+	}
+	if got, err := svc.ParseToken(u.Query().Get(services.BeaconTokenParam)); err != nil || got != clickID {
+		t.Errorf("appended token does not parse: %s, %v", got, err)
+	}
+}
+
+func TestNormalizeTrafficSources(t *testing.T) {
+	got := services.NormalizeTrafficSources([]string{
+		"https://www.TikTok.com/@promo",
+		"instagram.com",
+		" www.instagram.com ",
+		"",
+		"http://blog.example.org:8080/posts",
+	})
+	want := []string{"tiktok.com", "instagram.com", "blog.example.org"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if got := services.ParseTrafficSources(`["youtube.com","https://www.youtube.com/c/x"]`); !reflect.DeepEqual(got, []string{"youtube.com"}) {
+		t.Errorf("got %v", got)
+	}
+	if got := services.ParseTrafficSources("not json"); got != nil {
+		t.Errorf("invalid JSON must give no sources, got %v", got)
+	}
+}
@@ -0,0 +1,310 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/google/uuid"
+)
+
+// ============================================
+// EARNINGS LEDGER
+// ============================================
+
+func TestConversionPostingsBalance(t *testing.T) {
+	advertiser, promoter := uuid.New(), uuid.New()
+
+	fee := services.PlatformFee(1250, services.DefaultPlatformFeeBps)
+	if fee != 125 {
+		t.Fatalf("10%% of 1250 = %d; want 125", fee)
+	}
+	if got := services.PlatformFee(5, services.DefaultPlatformFeeBps); got != 1 {
+		t.Errorf("fees round half up, got %d", got)
+	}
+
+	postings := services.BuildConversionPostings(advertiser, promoter, 1250, fee)
+	if err := services.ValidateLedgerPostings(postings); err != nil {
+		t.Fatalf("conversion postings must balance: %v", err)
+	}
+	want := map[models.LedgerAccountType]int64{
+		models.LedgerAccountAdvertiserReceivable: 1375,
+		models.LedgerAccountPromoterPayable:      -1250,
+		models.LedgerAccountPlatformRevenue:      -125,
+	}
+	for _, p := range postings {
+		if p.Amount != want[p.AccountType] {
+			t.Errorf("%s = %d; want %d", p.AccountType, p.Amount, want[p.AccountType])
+		}
+	}
+
+	reversed := services.ReversePostings(postings)
+	if err := services.ValidateLedgerPostings(reversed); err != nil {
+		t.Fatalf("reversal must balance: %v", err)
+	}
+	for i := range postings {
+		if postings[i].Amount+reversed[i].Amount != 0 || postings[i].OwnerID != reversed[i].OwnerID {
+			t.Errorf("reversal must negate posting %d", i)
+		}
+	}
+
+	if n := len(services.BuildConversionPostings(advertiser, promoter, 100, 0)); n != 2 {
+		t.Errorf("no revenue posting without a fee, got %d postings", n)
+	}
+}
+
+func TestValidateLedgerPostingsRejects(t *testing.T) {
+	owner := uuid.New()
+	cases := map[string]struct {
+		postings []services.LedgerPosting
+		want     error
+	}{
+		"single posting": {[]services.LedgerPosting{
+			{AccountType: models.LedgerAccountCash, Amount: 100},
+		}, services.ErrLedgerInvalidPosting},
+		"zero amount": {[]services.LedgerPosting{
+			{AccountType: models.LedgerAccountCash, Amount: 0},
+			{AccountType: models.LedgerAccountAdjustments, Amount: 0},
+		}, services.ErrLedgerInvalidPosting},
+		"unbalanced": {[]services.LedgerPosting{
+			{AccountType: models.LedgerAccountAdjustments, Amount: 100},
+			{AccountType: models.LedgerAccountPromoterPayable, OwnerID: owner, Amount: -99},
+		}, services.ErrLedgerUnbalanced},
+	}
+	for name, tc := range cases {
+		if err := services.ValidateLedgerPostings(tc.postings); !errors.Is(err, tc.want) {
+			t.Errorf("%s: got %v; want %v", name, err, tc.want)
+		}
+	}
+}
+
+func TestCurrencyMinorUnits(t *testing.T) {
+	cases := []struct {
+		currency string
+		digits   int
+		major    float64
+		minor    int64
+	}{
+		{"USD", 2, 12.34, 1234},
+		{"kwd", 3, 1.005, 1005},
+		{"JPY", 0, 500, 500},
+		{"", 2, 0.1, 10},
+	}
+	for _, tc := range cases {
+		if got := services.CurrencyMinorUnits(tc.currency); got != tc.digits {
+			t.Errorf("CurrencyMinorUnits(%q) = %d; want %d", tc.currency, got, tc.digits)
+		}
+		if got := services.MajorToMinor(tc.major, tc.currency); got != tc.minor {
+			t.Errorf("MajorToMinor(%v, %q) = %d; want %d", tc.major, tc.currency, got, tc.minor)
+		}
+		if got := services.MinorToMajor(tc.minor, tc.currency); got != tc.major {
+			t.Errorf("MinorToMajor(%d, %q) = %v; want %v", tc.minor, tc.currency, got, tc.major)
+		}
+	}
+}
+
+func TestLedgerPostingsAreImmutable(t *testing.T) {
+	if err := (&models.LedgerEntry{}).BeforeUpdate(nil); !errors.Is(err, models.ErrLedgerImmutable) {
+		t.Errorf("entries must not be updated, got %v", err)
+	}
+	if err := (&models.LedgerTransaction{}).BeforeDelete(nil); !errors.Is(err, models.ErrLedgerImmutable) {
+		t.Errorf("transactions must not be deleted, got %v", err)
+	}
+}
+
+func TestWholeUnitConversions(t *testing.T) {
+	if got := services.WholeToMinor(12, "USD"); got != 1200 {
+		t.Errorf("12 USD = %d cents; want 1200", got)
+	}
+	if got := services.WholeToMinor(12, "KWD"); got != 12000 {
+		t.Errorf("12 KWD = %d fils; want 12000", got)
+	}
+	if got := services.WholeToMinor(500, "JPY"); got != 500 {
+		t.Errorf("500 JPY = %d; want 500", got)
+	}
+	if got := services.MinorToWhole(1250, "USD"); got != 13 {
+		t.Errorf("1250 cents = %d USD; want 13 (half away from zero)", got)
+	}
+	if got := services.MinorToWhole(-1250, "USD"); got != -13 {
+		t.Errorf("-1250 cents = %d USD; want -13", got)
+	}
+	if got := services.MinorToWhole(12345, "KWD"); got != 12 {
+		t.Errorf("12345 fils = %d KWD; want 12", got)
+	}
+}
+
+// Conversion commissions are whole currency units; the ledger must book them
+// in minor units and move the counters by the same amount
+func TestConversionApprovalBooksMinorUnits(t *testing.T) {
+	for _, tc := range []struct {
+		currency string
+		minor    int64
+	}{
+		{"USD", 1000},
+		{"KWD", 10000},
+	} {
+		t.Run(tc.currency, func(t *testing.T) {
+			db, store := newMemDB(t)
+			tenantID, promoterID, advertiserID := uuid.New(), uuid.New(), uuid.New()
+			userOfferID, offerID := uuid.New(), uuid.New()
+
+			store.insert("afftok_users", map[string]interface{}{"id": promoterID, "tenant_id": tenantID})
+			// conversionParties joins offers and users; the harness reads the
+			// joined columns straight off the user offer row
+			store.insert("user_offers", map[string]interface{}{
+				"id": userOfferID, "tenant_id": tenantID, "user_id": promoterID, "offer_id": offerID,
+				"advertiser_id": advertiserID, "promoter_conversions": int64(0),
+			})
+
+			conversion := &models.Conversion{
+				TenantModel: models.TenantModel{TenantID: tenantID},
+				ID:          uuid.New(),
+				UserOfferID: userOfferID,
+				Commission:  10,
+				Currency:    tc.currency,
+				Status:      models.ConversionStatusApproved,
+			}
+			if _, err := services.NewLedgerService(db).PostConversionApproved(db, conversion); err != nil {
+				t.Fatalf("post: %v", err)
+			}
+
+			var payableAccount interface{}
+			for _, account := range store.table("ledger_accounts") {
+				if account["type"] == string(models.LedgerAccountPromoterPayable) {
+					payableAccount = account["id"]
+				}
+			}
+			var payable int64
+			for _, entry := range store.table("ledger_entries") {
+				if entry["account_id"] == payableAccount {
+					payable -= entry["amount"].(int64)
+				}
+			}
+			if payable != tc.minor {
+				t.Errorf("promoter payable = %d; want %d minor units", payable, tc.minor)
+			}
+
+			counters := earningsCounters(store)
+			if got := counters[earningsKey{models.EarningsOwnerUser, promoterID.String(), tc.currency}]; got != tc.minor {
+				t.Errorf("user earnings = %d; want %d minor units", got, tc.minor)
+			}
+			if got := counters[earningsKey{models.EarningsOwnerUserOffer, userOfferID.String(), tc.currency}]; got != tc.minor {
+				t.Errorf("user offer earnings = %d; want %d minor units", got, tc.minor)
+			}
+		})
+	}
+}
+
+// Fractions of a unit and other currencies must reach the counters exactly:
+// a 60% revenue share leaves the promoter 0.40 USD (or 0.400 KWD) per
+// conversion, which whole-unit counters rounded away
+func TestEarningsCountersReconcileWithLedger(t *testing.T) {
+	db, store := newMemDB(t)
+	tenantID, promoterID, advertiserID := uuid.New(), uuid.New(), uuid.New()
+	store.insert("afftok_users", map[string]interface{}{"id": promoterID, "tenant_id": tenantID})
+	store.respond(`FROM "fee_rules"`, []string{"id", "tenant_id", "scope", "scope_value", "mode", "rate_bps", "volume_basis", "effective_from"},
+		[]driver.Value{uuid.NewString(), tenantID.String(), string(models.FeeScopeTenant), "", string(models.FeeModeRevenueShare), int64(6000), "", time.Now().AddDate(-1, 0, 0)})
+
+	offers := map[string]uuid.UUID{"USD": uuid.New(), "KWD": uuid.New()}
+	for _, userOfferID := range offers {
+		store.insert("user_offers", map[string]interface{}{
+			"id": userOfferID, "tenant_id": tenantID, "user_id": promoterID, "offer_id": uuid.New(),
+			"advertiser_id": advertiserID, "promoter_conversions": int64(0),
+		})
+	}
+
+	ledger := services.NewLedgerService(db)
+	ledger.SetFeeRuleService(services.NewFeeRuleService(db))
+	var conversions []uuid.UUID
+	for _, currency := range []string{"USD", "USD", "USD", "KWD", "KWD"} {
+		conversion := &models.Conversion{
+			TenantModel: models.TenantModel{TenantID: tenantID},
+			ID:          uuid.New(),
+			UserOfferID: offers[currency],
+			Commission:  1,
+			Currency:    currency,
+			Status:      models.ConversionStatusApproved,
+		}
+		if _, err := ledger.PostConversionApproved(db, conversion); err != nil {
+			t.Fatalf("post %s: %v", currency, err)
+		}
+		conversions = append(conversions, conversion.ID)
+	}
+	if _, err := ledger.PostConversionReversed(db, conversions[0], "chargeback", nil); err != nil {
+		t.Fatalf("reverse: %v", err)
+	}
+
+	counters := earningsCounters(store)
+	want := map[earningsKey]int64{
+		{models.EarningsOwnerUser, promoterID.String(), "USD"}:         80,
+		{models.EarningsOwnerUser, promoterID.String(), "KWD"}:         800,
+		{models.EarningsOwnerUserOffer, offers["USD"].String(), "USD"}: 80,
+		{models.EarningsOwnerUserOffer, offers["KWD"].String(), "KWD"}: 800,
+	}
+	for key, amount := range want {
+		if counters[key] != amount {
+			t.Errorf("%s %s counter in %s = %d; want %d", key.ownerType, key.ownerID, key.currency, counters[key], amount)
+		}
+	}
+
+	ledgerEarnings := ledgerEarningsOf(store)
+	if len(ledgerEarnings) != len(counters) {
+		t.Errorf("ledger has %d earnings balances, counters %d", len(ledgerEarnings), len(counters))
+	}
+	for key, amount := range ledgerEarnings {
+		if counters[key] != amount {
+			t.Errorf("%s %s in %s: counter %d, ledger %d", key.ownerType, key.ownerID, key.currency, counters[key], amount)
+		}
+	}
+}
+
+type earningsKey struct{ ownerType, ownerID, currency string }
+
+func earningsCounters(store *memStore) map[earningsKey]int64 {
+	counters := make(map[earningsKey]int64)
+	for _, row := range store.table("earnings_counters") {
+		counters[earningsKey{fmt.Sprint(row["owner_type"]), fmt.Sprint(row["owner_id"]), fmt.Sprint(row["currency"])}] += row["amount"].(int64)
+	}
+	return counters
+}
+
+// ledgerEarningsOf sums conversion and override postings on promoter
+// payables per promoter and user offer, as Reconcile does in SQL
+func ledgerEarningsOf(store *memStore) map[earningsKey]int64 {
+	payables := make(map[string]bool)
+	for _, account := range store.table("ledger_accounts") {
+		if account["type"] == string(models.LedgerAccountPromoterPayable) {
+			payables[fmt.Sprint(account["id"])] = true
+		}
+	}
+	earningKinds := map[string]bool{
+		string(models.LedgerKindConversionApproved): true, string(models.LedgerKindConversionReversed): true,
+		string(models.LedgerKindOverrideEarned): true, string(models.LedgerKindOverrideReversed): true,
+	}
+	transactions := make(map[string]map[string]driver.Value)
+	for _, txn := range store.table("ledger_transactions") {
+		if earningKinds[fmt.Sprint(txn["kind"])] {
+			transactions[fmt.Sprint(txn["id"])] = txn
+		}
+	}
+
+	earnings := make(map[earningsKey]int64)
+	for _, entry := range store.table("ledger_entries") {
+		txn, ok := transactions[fmt.Sprint(entry["transaction_id"])]
+		if !ok || !payables[fmt.Sprint(entry["account_id"])] {
+			continue
+		}
+		currency := fmt.Sprint(entry["currency"])
+		if id := txn["promoter_id"]; id != nil {
+			earnings[earningsKey{models.EarningsOwnerUser, fmt.Sprint(id), currency}] -= entry["amount"].(int64)
+		}
+		if id := txn["user_offer_id"]; id != nil {
+			earnings[earningsKey{models.EarningsOwnerUserOffer, fmt.Sprint(id), currency}] -= entry["amount"].(int64)
+		}
+	}
+	return earnings
+}
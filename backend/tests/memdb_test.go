@@ -0,0 +1,659 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// ============================================
+// IN-MEMORY DATABASE
+// ============================================
+//
+// memStore is the one fake database: it backs service tests that need rows
+// to round-trip without Postgres. It does not evaluate SQL faithfully, so
+// tenant isolation is tested against Postgres instead (tenant_leakage_test.go).
+// INSERTs are stored as column maps and UPDATEs apply plain and counter
+// ("col = col + $n") assignments; SELECT, UPDATE and DELETE only honour
+// "column = $n", "column IN ($n, ...)", "column IS NULL" and "column < $n"-style
+// predicates ("col IS NULL OR col < $n" included); joins, ordering and limits are
+// ignored. Quoted select lists, as Pluck writes them, narrow the returned
+// columns; any other select list returns whole rows. SELECT ... FOR UPDATE inside a transaction locks the whole table
+// until that transaction ends, which is coarser than Postgres row locks but
+// serialises the same critical sections; there is no rollback. delayOn
+// stalls matching statements so tests can widen race windows. ON CONFLICT DO NOTHING skips rows whose unique key is
+// already stored; ON CONFLICT (cols) DO UPDATE applies "col = $n",
+// "col = EXCLUDED.col" and "col = table.col + EXCLUDED.col" to the stored row.
+// Statements are logged so tests can assert on them, and queries the harness
+// cannot evaluate (information_schema) can be answered with canned rows.
+
+type memStore struct {
+	mu         sync.Mutex
+	rows       map[string][]map[string]driver.Value
+	statements []string
+	failOn     map[string]error         // statement substring -> error
+	delayOn    map[string]time.Duration // statement substring -> stall after running it
+	unique     map[string][]string      // table -> conflict key columns (default id)
+	canned     map[string]*memRows      // query substring -> result
+	locks      map[string]*memConn      // table -> transaction holding FOR UPDATE
+	released   *sync.Cond
+}
+
+func newMemDB(t *testing.T) (*gorm.DB, *memStore) {
+	t.Helper()
+	store := &memStore{rows: make(map[string][]map[string]driver.Value), failOn: make(map[string]error), delayOn: make(map[string]time.Duration), unique: make(map[string][]string), canned: make(map[string]*memRows), locks: make(map[string]*memConn)}
+	store.released = sync.NewCond(&store.mu)
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(store)}), &gorm.Config{
+		Logger:               logger.Default.LogMode(logger.Silent),
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	return db, store
+}
+
+// table returns a copy of a table's rows
+func (s *memStore) table(name string) []map[string]driver.Value {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rows := make([]map[string]driver.Value, len(s.rows[name]))
+	copy(rows, s.rows[name])
+	return rows
+}
+
+// insert stores a row directly, converting values like database/sql does
+func (s *memStore) insert(table string, row map[string]interface{}) {
+	stored := make(map[string]driver.Value, len(row))
+	for col, v := range row {
+		value, err := driver.DefaultParameterConverter.ConvertValue(v)
+		if err != nil {
+			if valuer, ok := v.(driver.Valuer); ok {
+				value, err = valuer.Value()
+			}
+		}
+		if err != nil {
+			panic(fmt.Sprintf("memdb: cannot store %s.%s: %v", table, col, err))
+		}
+		stored[col] = normalizeMemValue(value)
+	}
+	s.mu.Lock()
+	s.rows[table] = append(s.rows[table], stored)
+	s.mu.Unlock()
+}
+
+// respond answers SELECTs containing fragment with fixed rows
+func (s *memStore) respond(fragment string, columns []string, rows ...[]driver.Value) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.canned[fragment] = &memRows{columns: columns, values: rows}
+}
+
+// executed reports whether a statement containing every fragment ran
+func (s *memStore) executed(fragments ...string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, stmt := range s.statements {
+		matched := true
+		for _, f := range fragments {
+			if !strings.Contains(stmt, f) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memStore) Connect(context.Context) (driver.Conn, error) { return &memConn{store: s}, nil }
+func (s *memStore) Driver() driver.Driver                        { return nil }
+
+var (
+	memTablePattern  = regexp.MustCompile(`(?i)\b(?:FROM|UPDATE|INTO)\s+"?(\w+)"?`)
+	memInsertPattern = regexp.MustCompile(`(?is)^INSERT INTO "?\w+"?\s*\((.*?)\)\s*VALUES\s*(.*?)(?:\s+ON CONFLICT.*?)?(?:\s+RETURNING\s+(.*))?$`)
+	memTuplePattern  = regexp.MustCompile(`\(([^()]*)\)`)
+	memEqPattern     = regexp.MustCompile(`(?:"?\w+"?\.)?"?(\w+)"?\s*=\s*\$(\d+)`)
+	memInPattern     = regexp.MustCompile(`(?i)(?:"?\w+"?\.)?"?(\w+)"?\s+IN\s+\(([$\d,\s]+)\)`)
+	memIncrPattern   = regexp.MustCompile(`"?(\w+)"?\s*=\s*"?(\w+)"?\s*([+-])\s*\$(\d+)`)
+	memCmpPattern    = regexp.MustCompile(`(?:"?\w+"?\.)?"?(\w+)"?\s*(<=|>=|<|>)\s*\$(\d+)`)
+	memNullOrPattern = regexp.MustCompile(`(?i)"?(\w+)"?\s+IS NULL OR`)
+	memPluckPattern  = regexp.MustCompile(`(?i)^SELECT\s+(?:DISTINCT\s+)?((?:"\w+"\.)?"\w+"(?:\s*,\s*(?:"\w+"\.)?"\w+")*)\s+FROM\b`)
+	memNullPattern   = regexp.MustCompile(`(?i)(?:"?\w+"?\.)?"?(\w+)"?\s+IS NULL(\s+OR)?`)
+
+	memUpsertPattern      = regexp.MustCompile(`(?is)ON CONFLICT\s*\(([^)]*)\)\s*DO UPDATE SET\s+(.*?)(?:\s+RETURNING\s.*)?$`)
+	memSetPattern         = regexp.MustCompile(`^\s*"?(\w+)"?\s*=\s*(.+?)\s*$`)
+	memExcludedPattern    = regexp.MustCompile(`(?i)^"?excluded"?\."?(\w+)"?$`)
+	memExcludedAddPattern = regexp.MustCompile(`(?i)^"?\w+"?\."?(\w+)"?\s*\+\s*"?excluded"?\."?(\w+)"?$`)
+)
+
+type memConn struct {
+	store *memStore
+	inTx  bool
+}
+
+func (c *memConn) Prepare(string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("memdb: prepare not supported")
+}
+func (c *memConn) Close() error { return nil }
+func (c *memConn) Begin() (driver.Tx, error) {
+	c.inTx = true
+	return memTx{c}, nil
+}
+
+type memTx struct{ conn *memConn }
+
+func (t memTx) Commit() error   { t.conn.unlock(); return nil }
+func (t memTx) Rollback() error { t.conn.unlock(); return nil }
+
+// lock waits until no other transaction holds a FOR UPDATE lock on the table
+func (c *memConn) lock(table string) {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+	for c.store.locks[table] != nil && c.store.locks[table] != c {
+		c.store.released.Wait()
+	}
+	c.store.locks[table] = c
+}
+
+// unlock releases the transaction's locks
+func (c *memConn) unlock() {
+	c.inTx = false
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+	for table, holder := range c.store.locks {
+		if holder == c {
+			delete(c.store.locks, table)
+		}
+	}
+	c.store.released.Broadcast()
+}
+
+func (c *memConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.record(query); err != nil {
+		return nil, err
+	}
+	upper := strings.ToUpper(strings.TrimSpace(query))
+	switch {
+	case strings.HasPrefix(upper, "INSERT"):
+		return c.insert(query, args), nil
+	case strings.HasPrefix(upper, "UPDATE"):
+		c.update(query, args)
+		return &memRows{}, nil
+	case strings.HasPrefix(upper, "DELETE"):
+		c.delete(query, args)
+		return &memRows{}, nil
+	case strings.HasPrefix(upper, "SELECT"):
+		if canned := c.cannedRows(query); canned != nil {
+			return canned, nil
+		}
+		if c.inTx && strings.HasSuffix(upper, "FOR UPDATE") {
+			c.lock(memTableName(query))
+		}
+		rows := memProject(query, c.match(query, args))
+		c.stall(query)
+		if strings.Contains(strings.ToLower(query), "count(") {
+			return &memRows{columns: []string{"count"}, values: [][]driver.Value{{int64(len(rows))}}}, nil
+		}
+		return newMemRows(rows), nil
+	}
+	return &memRows{}, nil
+}
+
+func (c *memConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.record(query); err != nil {
+		return nil, err
+	}
+	upper := strings.ToUpper(strings.TrimSpace(query))
+	switch {
+	case strings.HasPrefix(upper, "INSERT"):
+		rows := c.insert(query, args)
+		return driver.RowsAffected(rows.inserted), nil
+	case strings.HasPrefix(upper, "UPDATE"):
+		return driver.RowsAffected(c.update(query, args)), nil
+	case strings.HasPrefix(upper, "DELETE"):
+		return driver.RowsAffected(c.delete(query, args)), nil
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (c *memConn) cannedRows(query string) *memRows {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+	for fragment, rows := range c.store.canned {
+		if strings.Contains(query, fragment) {
+			return &memRows{columns: rows.columns, values: rows.values}
+		}
+	}
+	return nil
+}
+
+// stall sleeps after a statement matching a delayOn fragment
+func (c *memConn) stall(query string) {
+	c.store.mu.Lock()
+	var delay time.Duration
+	for fragment, d := range c.store.delayOn {
+		if strings.Contains(query, fragment) {
+			delay = d
+		}
+	}
+	c.store.mu.Unlock()
+	time.Sleep(delay)
+}
+
+func (c *memConn) record(query string) error {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+	c.store.statements = append(c.store.statements, query)
+	for fragment, err := range c.store.failOn {
+		if strings.Contains(query, fragment) {
+			return err
+		}
+	}
+	return nil
+}
+
+// insert stores every VALUES tuple and answers RETURNING with the stored row
+func (c *memConn) insert(query string, args []driver.NamedValue) *memRows {
+	table := memTableName(query)
+	m := memInsertPattern.FindStringSubmatch(query)
+	if m == nil {
+		return &memRows{}
+	}
+	columns := splitMemColumns(m[1])
+	var returning []string
+	if m[3] != "" {
+		returning = splitMemColumns(m[3])
+	}
+
+	doNothing := strings.Contains(strings.ToUpper(query), "ON CONFLICT DO NOTHING")
+	upsert := memUpsertPattern.FindStringSubmatch(query)
+	result := &memRows{columns: returning}
+	for _, tuple := range memTuplePattern.FindAllStringSubmatch(m[2], -1) {
+		row := make(map[string]driver.Value, len(columns))
+		for i, token := range strings.Split(tuple[1], ",") {
+			if i < len(columns) {
+				row[columns[i]] = memArg(strings.TrimSpace(token), args)
+			}
+		}
+		if id, ok := row["id"]; !ok || id == nil {
+			row["id"] = uuid.NewString()
+		}
+		c.store.mu.Lock()
+		if doNothing && c.store.conflicts(table, row) {
+			c.store.mu.Unlock()
+			continue
+		}
+		if upsert != nil {
+			if existing := c.store.conflicting(table, row, splitMemColumns(upsert[1])); existing != nil {
+				applyMemUpsert(existing, row, upsert[2], args)
+				c.store.mu.Unlock()
+				result.inserted++
+				continue
+			}
+		}
+		c.store.rows[table] = append(c.store.rows[table], row)
+		c.store.mu.Unlock()
+		result.inserted++
+
+		if len(returning) > 0 {
+			values := make([]driver.Value, len(returning))
+			for i, col := range returning {
+				values[i] = row[col]
+			}
+			result.values = append(result.values, values)
+		}
+	}
+	return result
+}
+
+// conflicts reports whether a row with the same unique key is stored; the
+// caller holds the lock
+func (s *memStore) conflicts(table string, row map[string]driver.Value) bool {
+	key := s.unique[table]
+	if len(key) == 0 {
+		key = []string{"id"}
+	}
+	return s.conflicting(table, row, key) != nil
+}
+
+// conflicting returns the stored row with the same values in key; the
+// caller holds the lock
+func (s *memStore) conflicting(table string, row map[string]driver.Value, key []string) map[string]driver.Value {
+	for _, existing := range s.rows[table] {
+		same := true
+		for _, col := range key {
+			if fmt.Sprint(existing[col]) != fmt.Sprint(row[col]) {
+				same = false
+				break
+			}
+		}
+		if same {
+			return existing
+		}
+	}
+	return nil
+}
+
+// applyMemUpsert applies a DO UPDATE SET list to the stored row; the caller
+// holds the lock
+func applyMemUpsert(existing, excluded map[string]driver.Value, set string, args []driver.NamedValue) {
+	for _, assignment := range strings.Split(set, ",") {
+		m := memSetPattern.FindStringSubmatch(assignment)
+		if m == nil {
+			continue
+		}
+		col, expr := m[1], m[2]
+		if add := memExcludedAddPattern.FindStringSubmatch(expr); add != nil {
+			current, _ := memNumber(existing[add[1]])
+			delta, _ := memNumber(excluded[add[2]])
+			existing[col] = int64(current + delta)
+		} else if ex := memExcludedPattern.FindStringSubmatch(expr); ex != nil {
+			existing[col] = excluded[ex[1]]
+		} else if strings.HasPrefix(expr, "$") {
+			existing[col] = memArg(expr, args)
+		}
+	}
+}
+
+// update applies "SET col = $n" and "SET col = col + $n" assignments to the
+// matching rows
+func (c *memConn) update(query string, args []driver.NamedValue) int64 {
+	setPart, wherePart := query, ""
+	if i := strings.Index(strings.ToUpper(query), " WHERE "); i >= 0 {
+		setPart, wherePart = query[:i], query[i:]
+	}
+	if i := strings.Index(strings.ToUpper(setPart), " SET "); i >= 0 {
+		setPart = setPart[i:]
+	}
+	assignments := memEqPattern.FindAllStringSubmatch(setPart, -1)
+	increments := memIncrPattern.FindAllStringSubmatch(setPart, -1)
+
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+	var affected int64
+	for _, row := range c.store.rows[memTableName(query)] {
+		if !memRowMatches(row, wherePart, args) {
+			continue
+		}
+		for _, a := range assignments {
+			idx, _ := strconv.Atoi(a[2])
+			row[a[1]] = memArgAt(idx, args)
+		}
+		for _, inc := range increments {
+			idx, _ := strconv.Atoi(inc[4])
+			current, _ := memNumber(row[inc[2]])
+			delta, _ := memNumber(memArgAt(idx, args))
+			if inc[3] == "-" {
+				delta = -delta
+			}
+			row[inc[1]] = int64(current + delta)
+		}
+		affected++
+	}
+	return affected
+}
+
+func (c *memConn) delete(query string, args []driver.NamedValue) int64 {
+	table := memTableName(query)
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+	var kept []map[string]driver.Value
+	for _, row := range c.store.rows[table] {
+		if !memRowMatches(row, memWhere(query), args) {
+			kept = append(kept, row)
+		}
+	}
+	affected := int64(len(c.store.rows[table]) - len(kept))
+	c.store.rows[table] = kept
+	return affected
+}
+
+func (c *memConn) match(query string, args []driver.NamedValue) []map[string]driver.Value {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+	var rows []map[string]driver.Value
+	for _, row := range c.store.rows[memTableName(query)] {
+		if memRowMatches(row, memWhere(query), args) {
+			copied := make(map[string]driver.Value, len(row))
+			for col, v := range row {
+				copied[col] = v
+			}
+			rows = append(rows, copied)
+		}
+	}
+	return rows
+}
+
+// memProject keeps only the columns of a quoted select list
+func memProject(query string, rows []map[string]driver.Value) []map[string]driver.Value {
+	m := memPluckPattern.FindStringSubmatch(strings.TrimSpace(query))
+	if m == nil {
+		return rows
+	}
+	var columns []string
+	for _, col := range splitMemColumns(m[1]) {
+		if i := strings.LastIndex(col, "."); i >= 0 {
+			col = strings.Trim(col[i+1:], `"`)
+		}
+		columns = append(columns, col)
+	}
+	projected := make([]map[string]driver.Value, len(rows))
+	for i, row := range rows {
+		projected[i] = make(map[string]driver.Value, len(columns))
+		for _, col := range columns {
+			projected[i][col] = row[col]
+		}
+	}
+	return projected
+}
+
+func memTableName(query string) string {
+	if m := memTablePattern.FindStringSubmatch(query); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+func memWhere(query string) string {
+	if i := strings.Index(strings.ToUpper(query), " WHERE "); i >= 0 {
+		return query[i:]
+	}
+	return ""
+}
+
+// memRowMatches checks a row against the supported predicates of a WHERE
+// clause; predicates on columns the row does not have are ignored
+func memRowMatches(row map[string]driver.Value, where string, args []driver.NamedValue) bool {
+	for _, p := range memEqPattern.FindAllStringSubmatch(where, -1) {
+		value, ok := row[p[1]]
+		if !ok {
+			continue
+		}
+		idx, _ := strconv.Atoi(p[2])
+		if fmt.Sprint(value) != fmt.Sprint(memArgAt(idx, args)) {
+			return false
+		}
+	}
+	for _, p := range memInPattern.FindAllStringSubmatch(where, -1) {
+		value, ok := row[p[1]]
+		if !ok {
+			continue
+		}
+		found := false
+		for _, token := range strings.Split(p[2], ",") {
+			if fmt.Sprint(value) == fmt.Sprint(memArg(strings.TrimSpace(token), args)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	orNull := make(map[string]bool)
+	for _, p := range memNullOrPattern.FindAllStringSubmatch(where, -1) {
+		orNull[p[1]] = true
+	}
+	for _, p := range memNullPattern.FindAllStringSubmatch(where, -1) {
+		if value, ok := row[p[1]]; ok && p[2] == "" && value != nil {
+			return false
+		}
+	}
+	for _, p := range memCmpPattern.FindAllStringSubmatch(where, -1) {
+		value, ok := row[p[1]]
+		if !ok || (value == nil && orNull[p[1]]) {
+			continue
+		}
+		idx, _ := strconv.Atoi(p[3])
+		cmp, ok := compareMemValues(value, memArgAt(idx, args))
+		if !ok {
+			return false // NULL or incomparable never matches
+		}
+		switch p[2] {
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// compareMemValues orders two times or two numbers
+func compareMemValues(a, b driver.Value) (int, bool) {
+	if ta, ok := a.(time.Time); ok {
+		tb, ok := b.(time.Time)
+		if !ok {
+			return 0, false
+		}
+		return ta.Compare(tb), true
+	}
+	fa, okA := memNumber(a)
+	fb, okB := memNumber(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	switch {
+	case fa < fb:
+		return -1, true
+	case fa > fb:
+		return 1, true
+	}
+	return 0, true
+}
+
+func memNumber(v driver.Value) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func memArg(token string, args []driver.NamedValue) driver.Value {
+	if strings.HasPrefix(token, "$") {
+		idx, _ := strconv.Atoi(token[1:])
+		return memArgAt(idx, args)
+	}
+	return nil // DEFAULT, NULL
+}
+
+func memArgAt(idx int, args []driver.NamedValue) driver.Value {
+	if idx < 1 || idx > len(args) {
+		return nil
+	}
+	return normalizeMemValue(args[idx-1].Value)
+}
+
+func normalizeMemValue(v driver.Value) driver.Value {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return v
+}
+
+func splitMemColumns(list string) []string {
+	var columns []string
+	for _, col := range strings.Split(list, ",") {
+		columns = append(columns, strings.Trim(strings.TrimSpace(col), `"`))
+	}
+	return columns
+}
+
+type memRows struct {
+	columns  []string
+	values   [][]driver.Value
+	pos      int
+	inserted int64
+}
+
+func newMemRows(rows []map[string]driver.Value) *memRows {
+	seen := make(map[string]bool)
+	r := &memRows{}
+	for _, row := range rows {
+		for col := range row {
+			if !seen[col] {
+				seen[col] = true
+				r.columns = append(r.columns, col)
+			}
+		}
+	}
+	if len(r.columns) == 0 {
+		r.columns = []string{"id"}
+		return r
+	}
+	sort.Strings(r.columns)
+	for _, row := range rows {
+		values := make([]driver.Value, len(r.columns))
+		for i, col := range r.columns {
+			values[i] = row[col]
+		}
+		r.values = append(r.values, values)
+	}
+	return r
+}
+
+func (r *memRows) Columns() []string {
+	if len(r.columns) == 0 {
+		return []string{"id"}
+	}
+	return r.columns
+}
+func (r *memRows) Close() error { return nil }
+func (r *memRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.pos])
+	r.pos++
+	return nil
+}
@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+)
+
+// ============================================
+// PAGE TEMPLATES
+// ============================================
+
+func TestResolvePageLocale(t *testing.T) {
+	cases := []struct{ query, header, want string }{
+		{"en", "ar-KW,ar;q=0.9", "en"},          // ?lang wins
+		{"", "en-US,en;q=0.9,ar;q=0.8", "en"},   // first supported Accept-Language
+		{"fr", "fr-FR,ar;q=0.5", "ar"},          // unsupported entries are skipped
+		{"", "", models.PageTemplateLocales[0]}, // default
+	}
+	for _, tc := range cases {
+		if got := services.ResolvePageLocale(tc.query, tc.header); got != tc.want {
+			t.Errorf("ResolvePageLocale(%q, %q) = %q; want %q", tc.query, tc.header, got, tc.want)
+		}
+	}
+}
+
+func TestTenantPageBranding(t *testing.T) {
+	tenant := &models.Tenant{
+		Name:           "Acme",
+		LogoURL:        "https://cdn.acme.test/logo.png",
+		FaviconURL:     "javascript:alert(1)",
+		PrimaryColor:   "#112233",
+		SecondaryColor: "red;}body{display:none",
+		CustomDomain:   "go.acme.test",
+	}
+
+	brand, custom := services.TenantPageBranding(tenant, models.GetFeaturesForPlan(models.TenantPlanFree))
+	if custom || brand.Name != "AffTok" || brand.LogoURL != "" {
+		t.Errorf("free plan must keep the AffTok branding, got %+v", brand)
+	}
+
+	brand, custom = services.TenantPageBranding(tenant, models.GetFeaturesForPlan(models.TenantPlanPro))
+	if !custom || brand.Name != "Acme" || !brand.PoweredBy {
+		t.Errorf("custom branding must use the tenant name with a powered-by footer, got %+v", brand)
+	}
+	if brand.LogoURL != tenant.LogoURL || brand.FaviconURL != "" {
+		t.Errorf("only http(s) URLs are kept, got logo %q favicon %q", brand.LogoURL, brand.FaviconURL)
+	}
+	if brand.PrimaryColor != "#112233" || brand.SecondaryColor == tenant.SecondaryColor {
+		t.Errorf("only hex colors are kept, got %q/%q", brand.PrimaryColor, brand.SecondaryColor)
+	}
+
+	brand, _ = services.TenantPageBranding(tenant, models.GetFeaturesForPlan(models.TenantPlanEnterprise))
+	if brand.PoweredBy || brand.HomeURL != "https://go.acme.test" {
+		t.Errorf("white label must drop AffTok and link the custom domain, got %+v", brand)
+	}
+}
+
+func TestValidatePageTemplate(t *testing.T) {
+	valid := `<section><h1 style="color: var(--primary)">{{.Promoter.Name}}</h1>{{range .Promoter.Offers}}<a href="{{.Link}}">{{.Title}}</a>{{end}}</section>`
+	if err := services.ValidatePageTemplate(models.PageTemplatePromoterLanding, "en", valid); err != nil {
+		t.Fatalf("valid override rejected: %v", err)
+	}
+	layout := `<html><body>{{template "content" .}}</body></html>`
+	if err := services.ValidatePageTemplate(models.PageTemplateLayout, "ar", layout); err != nil {
+		t.Fatalf("valid layout rejected: %v", err)
+	}
+
+	start := time.Now()
+	rejected := map[string]struct{ page, content string }{
+		"script tag":        {models.PageTemplateError, `<SCRIPT>alert(1)</script>`},
+		"event handler":     {models.PageTemplateError, `<img src="x" onerror = "alert(1)">`},
+		"script URL":        {models.PageTemplateError, `<a href="JavaScript:alert(1)">x</a>`},
+		"iframe":            {models.PageTemplateError, `<iframe src="https://evil.test"></iframe>`},
+		"form":              {models.PageTemplateError, `<form action="https://evil.test"><input name="password"></form>`},
+		"define":            {models.PageTemplateError, `{{define "layout"}}x{{end}}`},
+		"include":           {models.PageTemplateError, `{{template "layout" .}}`},
+		"layout no content": {models.PageTemplateLayout, `<html><body>static</body></html>`},
+		"parse error":       {models.PageTemplateError, `{{if .Error}}`},
+		"other page's data": {models.PageTemplatePromoterLanding, `{{.Team.Name}}`},
+		"unknown field":     {models.PageTemplateError, `{{.Secret}}`},
+		"too large":         {models.PageTemplateError, strings.Repeat("a", services.MaxPageTemplateSize+1)},
+		"range number":      {models.PageTemplateError, `{{range 100000000000}}{{end}}`},
+		"range variable":    {models.PageTemplateError, `{{$n := 100000000000}}{{if .Error}}{{range $i := $n}}{{end}}{{end}}`},
+		"range in branch":   {models.PageTemplatePromoterLanding, `{{range .Promoter.Offers}}{{with .Title}}{{range 100000000000}}{{end}}{{end}}{{end}}`},
+	}
+	for name, tc := range rejected {
+		if err := services.ValidatePageTemplate(tc.page, "en", tc.content); !errors.Is(err, services.ErrInvalidPageTemplate) {
+			t.Errorf("%s: expected ErrInvalidPageTemplate, got %v", name, err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("rejecting overrides took %s, a range must be refused before it renders", elapsed)
+	}
+
+	if err := services.ValidatePageTemplate("checkout", "en", valid); !errors.Is(err, services.ErrUnknownPageTemplate) {
+		t.Errorf("unknown page: got %v", err)
+	}
+	if err := services.ValidatePageTemplate(models.PageTemplateError, "fr", "x"); !errors.Is(err, services.ErrUnknownPageTemplate) {
+		t.Errorf("unknown locale: got %v", err)
+	}
+}
+
+func TestRenderBuiltinPages(t *testing.T) {
+	svc := services.NewPageTemplateService(nil, nil)
+
+	page, err := svc.RenderPromoterPage(models.DefaultTenantID, "ar", &services.PromoterPageData{
+		ID:   "3f6c1a9e-0000-0000-0000-000000000001",
+		Name: `<b>Sara</b>`,
+		Bio:  `"></script><script>alert(1)</script>`,
+		Offers: []services.PageOffer{
+			{Title: "VPN", Payout: "$5 CPA", Link: "/api/c/abc?promoter=3f6c1a9e-0000-0000-0000-000000000001"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(page.HTML)
+	for _, want := range []string{`dir="rtl"`, `lang="ar"`, "&lt;b&gt;Sara&lt;/b&gt;", `href="/api/c/abc?promoter=`, `nonce="`, "--primary: #FF006E"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("promoter page is missing %q", want)
+		}
+	}
+	if strings.Contains(html, "<script>alert(1)") || strings.Contains(html, "<b>Sara") {
+		t.Error("promoter data must be escaped")
+	}
+	if page.Nonce == "" || !strings.Contains(services.PageCSP(page.Nonce), "'nonce-"+page.Nonce+"'") {
+		t.Error("the page CSP must allow the page nonce")
+	}
+
+	page, err = svc.RenderTeamInvitePage(models.DefaultTenantID, "en", &services.TeamPageData{Name: "Growth", InviteCode: "abc12345"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	html = string(page.HTML)
+	if !strings.Contains(html, `dir="ltr"`) || !strings.Contains(html, `href="afftok://join/abc12345"`) || !strings.Contains(html, "Join Growth - AffTok") {
+		t.Errorf("unexpected team page:\n%s", html)
+	}
+
+	page, err = svc.RenderErrorPage(models.DefaultTenantID, "en", 404, services.PageErrorInviteInvalid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Status != 404 || !strings.Contains(string(page.HTML), "Invalid invite link") {
+		t.Errorf("unexpected error page (%d):\n%s", page.Status, page.HTML)
+	}
+}
@@ -0,0 +1,381 @@
+package tests
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/google/uuid"
+)
+
+// ============================================
+// PAYOUT LIFECYCLE
+// ============================================
+
+func TestPlanPayoutLinesThresholds(t *testing.T) {
+	tenant, advA, advB := uuid.New(), uuid.New(), uuid.New()
+	small, large, refunded := uuid.New(), uuid.New(), uuid.New()
+	total := func(advertiser, promoter uuid.UUID, commission, fee int64) services.LedgerPeriodTotal {
+		adv := advertiser
+		return services.LedgerPeriodTotal{
+			TenantID: tenant, AdvertiserID: &adv, PromoterID: promoter,
+			Currency: "USD", Commission: commission, PlatformFee: fee, Conversions: 1,
+		}
+	}
+	minimum := func(uuid.UUID, string) float64 { return 50 }
+
+	lines := services.PlanPayoutLines([]services.LedgerPeriodTotal{
+		total(advA, small, 2000, 200), // 20.00 alone is below 50
+		total(advA, large, 3000, 300), // 30.00 + 25.00 from advB clears 50
+		total(advB, large, 2500, 250),
+		total(advA, refunded, -500, -50), // net negative after reversals
+	}, nil, minimum)
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines; want 4", len(lines))
+	}
+
+	byPromoter := map[uuid.UUID][]models.Payout{}
+	for _, l := range lines {
+		byPromoter[l.Payout.PublisherID] = append(byPromoter[l.Payout.PublisherID], l.Payout)
+	}
+	if p := byPromoter[small][0]; p.Status != models.PayoutStatusCarriedOver || p.HoldReason != services.PayoutCarryBelowMin {
+		t.Errorf("below-minimum line = %s/%s; want carried_over/%s", p.Status, p.HoldReason, services.PayoutCarryBelowMin)
+	}
+	for _, p := range byPromoter[large] {
+		if p.Status != models.PayoutStatusPending {
+			t.Errorf("minimum applies per promoter and currency, got %s for %.2f", p.Status, p.NetAmount)
+		}
+	}
+	if p := byPromoter[refunded][0]; p.Status != models.PayoutStatusCarriedOver || p.HoldReason != services.PayoutCarryNoBalance {
+		t.Errorf("negative line = %s/%s; want carried_over/%s", p.Status, p.HoldReason, services.PayoutCarryNoBalance)
+	}
+	if p := byPromoter[large][0]; p.Amount != 33 || p.PlatformFee != 3 || p.NetAmount != 30 {
+		t.Errorf("amounts = %.2f/%.2f/%.2f; want 33/3/30", p.Amount, p.PlatformFee, p.NetAmount)
+	}
+}
+
+func TestPlanPayoutLinesCarryOver(t *testing.T) {
+	tenant, advertiser, promoter := uuid.New(), uuid.New(), uuid.New()
+	carried := models.Payout{
+		ID: uuid.New(), AdvertiserID: advertiser, PublisherID: promoter, Currency: "usd",
+		NetAmount: 20, PlatformFee: 2, ConversionsCount: 3, Status: models.PayoutStatusCarriedOver,
+	}
+	carried.TenantID = tenant
+	adv := advertiser
+	current := services.LedgerPeriodTotal{
+		TenantID: tenant, AdvertiserID: &adv, PromoterID: promoter,
+		Currency: "USD", Commission: 3500, PlatformFee: 350, Conversions: 2,
+	}
+
+	lines := services.PlanPayoutLines([]services.LedgerPeriodTotal{current}, []models.Payout{carried},
+		func(uuid.UUID, string) float64 { return 50 })
+	if len(lines) != 1 {
+		t.Fatalf("carried balance must merge into the current line, got %d lines", len(lines))
+	}
+	line := lines[0]
+	if line.Payout.Status != models.PayoutStatusPending || line.Payout.NetAmount != 55 {
+		t.Errorf("line = %s %.2f; want pending 55.00", line.Payout.Status, line.Payout.NetAmount)
+	}
+	if line.Payout.CarriedOverAmount != 20 || line.Payout.ConversionsCount != 5 {
+		t.Errorf("carried = %.2f, conversions = %d; want 20.00 and 5", line.Payout.CarriedOverAmount, line.Payout.ConversionsCount)
+	}
+	if len(line.CarriedFrom) != 1 || line.CarriedFrom[0] != carried.ID {
+		t.Errorf("CarriedFrom = %v; want [%s]", line.CarriedFrom, carried.ID)
+	}
+
+	// A carried balance alone still waits for the threshold
+	lines = services.PlanPayoutLines(nil, []models.Payout{carried}, func(uuid.UUID, string) float64 { return 50 })
+	if len(lines) != 1 || lines[0].Payout.Status != models.PayoutStatusCarriedOver {
+		t.Errorf("carried-only line must be carried again, got %+v", lines)
+	}
+}
+
+func TestPayoutTransitions(t *testing.T) {
+	allowed := [][2]string{
+		{models.BatchStatusDraft, models.BatchStatusApproved},
+		{models.BatchStatusApproved, models.BatchStatusDraft},
+		{models.BatchStatusApproved, models.BatchStatusSubmitted},
+	}
+	for _, tr := range allowed {
+		if !services.CanTransitionBatch(tr[0], tr[1]) {
+			t.Errorf("batch %s -> %s must be allowed", tr[0], tr[1])
+		}
+	}
+	denied := [][2]string{
+		{models.BatchStatusDraft, models.BatchStatusSubmitted},
+		{models.BatchStatusCompleted, models.BatchStatusDraft},
+		{models.BatchStatusCancelled, models.BatchStatusApproved},
+	}
+	for _, tr := range denied {
+		if services.CanTransitionBatch(tr[0], tr[1]) {
+			t.Errorf("batch %s -> %s must be rejected", tr[0], tr[1])
+		}
+	}
+
+	if !services.CanTransitionPayout(models.PayoutStatusPending, models.PayoutStatusOnHold) ||
+		!services.CanTransitionPayout(models.PayoutStatusProcessing, models.PayoutStatusPaid) {
+		t.Error("hold and settle transitions must be allowed")
+	}
+	if services.CanTransitionPayout(models.PayoutStatusPaid, models.PayoutStatusPending) ||
+		services.CanTransitionPayout(models.PayoutStatusOnHold, models.PayoutStatusPaid) {
+		t.Error("paid lines are final and held lines cannot be paid")
+	}
+}
+
+// payoutFixture is a tenant with a payout service on the in-memory database
+type payoutFixture struct {
+	store      *memStore
+	payouts    *services.PayoutService
+	tenantID   uuid.UUID
+	advertiser uuid.UUID
+	year       int
+	month      int
+}
+
+func newPayoutFixture(t *testing.T, settings string) *payoutFixture {
+	db, store := newMemDB(t)
+	store.unique["payout_batches"] = []string{"tenant_id", "period"}
+
+	f := &payoutFixture{store: store, tenantID: uuid.New(), advertiser: uuid.New()}
+	store.insert("tenants", map[string]interface{}{"id": f.tenantID, "settings": settings})
+
+	f.payouts = services.NewPayoutService(db)
+	f.payouts.SetLedgerService(services.NewLedgerService(db))
+	f.payouts.SetSettingsResolver(services.NewTenantSettingsResolver(db))
+	f.payouts.SetKYCEarningsThreshold(1000)
+
+	now := time.Now().UTC()
+	last := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+	f.year, f.month = last.Year(), int(last.Month())
+	return f
+}
+
+func (f *payoutFixture) period() string { return fmt.Sprintf("%d-%02d", f.year, f.month) }
+
+// promoter adds a promoter of the tenant
+func (f *payoutFixture) promoter(country, kycStatus string) uuid.UUID {
+	id := uuid.New()
+	f.store.insert("afftok_users", map[string]interface{}{
+		"id": id, "tenant_id": f.tenantID, "country": country, "kyc_status": kycStatus, "reporting_currency": "USD",
+	})
+	return id
+}
+
+// earned answers the period's ledger aggregation with one USD conversion
+// per promoter, commission in cents
+func (f *payoutFixture) earned(commissions map[uuid.UUID]int64) {
+	var rows [][]driver.Value
+	for promoter, commission := range commissions {
+		rows = append(rows, []driver.Value{
+			uuid.NewString(), f.tenantID.String(), f.advertiser.String(), promoter.String(),
+			string(models.LedgerKindConversionApproved), nil, "USD", commission, commission / 10,
+		})
+	}
+	f.store.respond("FROM ledger_transactions t",
+		[]string{"id", "tenant_id", "advertiser_id", "promoter_id", "kind", "fx_rates", "currency", "commission", "platform_fee"},
+		rows...)
+}
+
+// lines returns the stored payouts of a batch by promoter
+func (f *payoutFixture) lines(batchID uuid.UUID) map[string]map[string]driver.Value {
+	lines := make(map[string]map[string]driver.Value)
+	for _, row := range f.store.table("payouts") {
+		if fmt.Sprint(row["batch_id"]) == batchID.String() {
+			lines[fmt.Sprint(row["publisher_id"])] = row
+		}
+	}
+	return lines
+}
+
+func TestGenerateBatchOncePerPeriod(t *testing.T) {
+	f := newPayoutFixture(t, `{}`)
+	a, b := f.promoter("US", models.KYCStatusVerified), f.promoter("US", models.KYCStatusVerified)
+	f.earned(map[uuid.UUID]int64{a: 6000, b: 7000})
+	// Both runs miss the existing batch before either creates it
+	f.store.delayOn[`FROM "payout_batches"`] = 50 * time.Millisecond
+
+	type result struct {
+		batch   *models.PayoutBatch
+		created bool
+		err     error
+	}
+	results := make([]result, 2)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			batch, created, err := f.payouts.GenerateBatch(f.tenantID, f.year, f.month, nil)
+			results[i] = result{batch, created, err}
+		}(i)
+	}
+	wg.Wait()
+	delete(f.store.delayOn, `FROM "payout_batches"`)
+
+	created := 0
+	for _, r := range results {
+		if r.err != nil {
+			t.Fatalf("GenerateBatch: %v", r.err)
+		}
+		if r.created {
+			created++
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d runs created the batch; want 1", created)
+	}
+	if results[0].batch.ID != results[1].batch.ID {
+		t.Errorf("runs returned batches %s and %s; want the same batch", results[0].batch.ID, results[1].batch.ID)
+	}
+	if n := len(f.store.table("payout_batches")); n != 1 {
+		t.Fatalf("%d batches stored for %s; want 1", n, f.period())
+	}
+	if n := len(f.store.table("payouts")); n != 2 {
+		t.Errorf("%d payout lines stored; want the 2 lines of one run", n)
+	}
+
+	batch, created2, err := f.payouts.GenerateBatch(f.tenantID, f.year, f.month, nil)
+	if err != nil || created2 || batch.ID != results[0].batch.ID {
+		t.Errorf("rerun = %v, created %v, err %v; want the existing batch", batch, created2, err)
+	}
+}
+
+func TestGenerateBatchCarriesEarlierLines(t *testing.T) {
+	f := newPayoutFixture(t, `{}`)
+	promoter := f.promoter("US", models.KYCStatusVerified)
+	f.earned(map[uuid.UUID]int64{promoter: 3500})
+
+	earlier := uuid.New()
+	f.store.insert("payouts", map[string]interface{}{
+		"id": earlier, "tenant_id": f.tenantID, "advertiser_id": f.advertiser, "publisher_id": promoter,
+		"currency": "USD", "net_amount": 20.0, "status": models.PayoutStatusCarriedOver, "period": "2000-01",
+	})
+	candidates := []string{"id", "tenant_id", "advertiser_id", "publisher_id", "currency", "net_amount", "platform_fee", "conversions_count", "status", "period"}
+	f.store.respond("payouts.carried_into_id IS NULL", candidates, []driver.Value{
+		earlier.String(), f.tenantID.String(), f.advertiser.String(), promoter.String(),
+		"USD", 20.0, 2.0, int64(3), models.PayoutStatusCarriedOver, "2000-01",
+	})
+
+	batch, created, err := f.payouts.GenerateBatch(f.tenantID, f.year, f.month, nil)
+	if err != nil || !created {
+		t.Fatalf("GenerateBatch: created %v, err %v", created, err)
+	}
+	line := f.lines(batch.ID)[promoter.String()]
+	if line == nil {
+		t.Fatal("no line for the promoter")
+	}
+	if line["net_amount"] != 55.0 || line["carried_over_amount"] != 20.0 {
+		t.Errorf("line net %v, carried %v; want 55 and 20", line["net_amount"], line["carried_over_amount"])
+	}
+	for _, row := range f.store.table("payouts") {
+		if fmt.Sprint(row["id"]) == earlier.String() && fmt.Sprint(row["carried_into_id"]) != fmt.Sprint(line["id"]) {
+			t.Errorf("earlier line carried into %v; want %v", row["carried_into_id"], line["id"])
+		}
+	}
+
+	// A line carried by another run in the meantime must not be paid twice
+	g := newPayoutFixture(t, `{}`)
+	g.store.insert("payouts", map[string]interface{}{
+		"id": earlier, "tenant_id": g.tenantID, "advertiser_id": g.advertiser, "publisher_id": promoter,
+		"currency": "USD", "net_amount": 20.0, "status": models.PayoutStatusCarriedOver, "period": "2000-01",
+		"carried_into_id": uuid.New(),
+	})
+	g.store.respond("payouts.carried_into_id IS NULL", candidates, []driver.Value{
+		earlier.String(), g.tenantID.String(), g.advertiser.String(), promoter.String(),
+		"USD", 20.0, 2.0, int64(3), models.PayoutStatusCarriedOver, "2000-01",
+	})
+	g.earned(map[uuid.UUID]int64{promoter: 3500})
+	if _, _, err := g.payouts.GenerateBatch(g.tenantID, g.year, g.month, nil); !errors.Is(err, services.ErrPayoutTransition) {
+		t.Errorf("err = %v; want ErrPayoutTransition for an already carried line", err)
+	}
+}
+
+func TestGenerateBatchHolds(t *testing.T) {
+	f := newPayoutFixture(t, `{"tax_profile_countries":["DE"]}`)
+	clean := f.promoter("US", models.KYCStatusVerified)
+	unverified := f.promoter("US", models.KYCStatusNone)
+	flagged := f.promoter("US", models.KYCStatusVerified)
+	untaxed := f.promoter("DE", models.KYCStatusVerified)
+	f.store.insert("fraud_cases", map[string]interface{}{
+		"id": uuid.New(), "tenant_id": f.tenantID, "user_id": flagged, "status": models.FraudCaseStatusOpen,
+	})
+	f.earned(map[uuid.UUID]int64{clean: 6000, unverified: 200000, flagged: 6000, untaxed: 6000})
+
+	batch, _, err := f.payouts.GenerateBatch(f.tenantID, f.year, f.month, nil)
+	if err != nil {
+		t.Fatalf("GenerateBatch: %v", err)
+	}
+	lines := f.lines(batch.ID)
+	want := map[uuid.UUID][2]string{
+		clean:      {models.PayoutStatusPending, ""},
+		unverified: {models.PayoutStatusOnHold, services.PayoutHoldReasonKYC},
+		flagged:    {models.PayoutStatusOnHold, services.PayoutHoldReasonFraud},
+		untaxed:    {models.PayoutStatusOnHold, services.PayoutHoldReasonTaxProfile},
+	}
+	for promoter, w := range want {
+		line := lines[promoter.String()]
+		if line == nil {
+			t.Errorf("no line for %s", w[1])
+			continue
+		}
+		if fmt.Sprint(line["status"]) != w[0] || fmt.Sprint(line["hold_reason"]) != w[1] {
+			t.Errorf("line = %v/%v; want %s/%s", line["status"], line["hold_reason"], w[0], w[1])
+		}
+	}
+	if batch.HeldPayouts != 3 {
+		t.Errorf("HeldPayouts = %d; want 3", batch.HeldPayouts)
+	}
+}
+
+func TestReconcileBatchSettlesLines(t *testing.T) {
+	f := newPayoutFixture(t, `{}`)
+	batchID := uuid.New()
+	f.store.insert("payout_batches", map[string]interface{}{
+		"id": batchID, "tenant_id": f.tenantID, "period": f.period(), "status": models.BatchStatusSubmitted,
+	})
+	paid, failed := uuid.New(), uuid.New()
+	for _, id := range []uuid.UUID{paid, failed} {
+		f.store.insert("payouts", map[string]interface{}{
+			"id": id, "tenant_id": f.tenantID, "batch_id": batchID, "advertiser_id": f.advertiser,
+			"publisher_id": f.promoter("US", models.KYCStatusVerified), "currency": "USD", "net_amount": 55.0,
+			"period": f.period(), "status": models.PayoutStatusProcessing,
+		})
+	}
+
+	settlements := []services.PayoutSettlement{
+		{PayoutID: paid, Status: models.PayoutStatusPaid, Reference: "TX-1"},
+		{PayoutID: failed, Status: models.PayoutStatusFailed, Reason: "account closed"},
+	}
+	report, err := f.payouts.ReconcileBatch(f.tenantID, batchID, settlements, nil)
+	if err != nil {
+		t.Fatalf("ReconcileBatch: %v", err)
+	}
+	if report.Paid != 1 || report.Failed != 1 || report.Outstanding != 0 || report.BatchStatus != models.BatchStatusPartiallyFailed {
+		t.Errorf("report = %+v; want 1 paid, 1 failed, partially_failed", report)
+	}
+
+	status := make(map[string]map[string]driver.Value)
+	for _, row := range f.store.table("payouts") {
+		status[fmt.Sprint(row["id"])] = row
+	}
+	if row := status[paid.String()]; row["status"] != models.PayoutStatusPaid || row["external_ref"] != "TX-1" {
+		t.Errorf("paid line = %v/%v; want paid/TX-1", row["status"], row["external_ref"])
+	}
+	if row := status[failed.String()]; row["status"] != models.PayoutStatusFailed || row["failure_reason"] != "account closed" {
+		t.Errorf("failed line = %v/%v; want failed/account closed", row["status"], row["failure_reason"])
+	}
+	posted := 0
+	for _, txn := range f.store.table("ledger_transactions") {
+		if txn["kind"] == string(models.LedgerKindPayoutPaid) {
+			posted++
+		}
+	}
+	if posted != 1 {
+		t.Errorf("%d payout_paid ledger transactions; want 1", posted)
+	}
+}
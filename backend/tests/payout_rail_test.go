@@ -0,0 +1,200 @@
+package tests
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/google/uuid"
+)
+
+// ============================================
+// PAYOUT RAILS
+// ============================================
+
+func TestFakePayoutRailFlow(t *testing.T) {
+	ctx := context.Background()
+	rail := services.NewFakePayoutRail(models.PayoutRailPayPal, "secret")
+	paid, failed := uuid.New(), uuid.New()
+	batch := &services.PayoutRailBatch{
+		Reference: "afftok-batch-paypal",
+		Items: []services.PayoutRailItem{
+			{PayoutID: paid, Payee: services.PayoutPayee{Email: "ok@example.com"}, Amount: services.MoneyFromMajor(12.5, "USD")},
+			{PayoutID: failed, Payee: services.PayoutPayee{Email: "bad@example.com"}, Amount: services.MoneyFromMajor(30, "USD")},
+		},
+	}
+	rail.FailPayee("bad@example.com", "receiver unregistered")
+
+	first, err := rail.SubmitBatch(ctx, batch)
+	if err != nil {
+		t.Fatalf("SubmitBatch: %v", err)
+	}
+	again, _ := rail.SubmitBatch(ctx, batch)
+	if again.ExternalRef != first.ExternalRef || len(rail.Submitted()) != 1 {
+		t.Fatal("re-submitting the same reference must not create a second batch")
+	}
+	for _, r := range first.Results {
+		if r.Settled() {
+			t.Errorf("item %s settled on submit; want pending", r.PayoutID)
+		}
+	}
+
+	results, err := rail.PollStatus(ctx, &services.PayoutRailRef{Reference: batch.Reference})
+	if err != nil {
+		t.Fatalf("PollStatus: %v", err)
+	}
+	status := map[uuid.UUID]services.PayoutRailItemResult{}
+	for _, r := range results {
+		status[r.PayoutID] = r
+	}
+	if status[paid].Status != models.PayoutStatusPaid || status[paid].PaidAt == nil {
+		t.Errorf("good payee = %+v; want paid", status[paid])
+	}
+	if status[failed].Status != models.PayoutStatusFailed || status[failed].Reason != "receiver unregistered" {
+		t.Errorf("failing payee = %+v; want failed with reason", status[failed])
+	}
+	if err := rail.CancelBatch(ctx, &services.PayoutRailRef{Reference: batch.Reference}); !errors.Is(err, services.ErrPayoutRailCannotCancel) {
+		t.Errorf("cancel after payment = %v; want ErrPayoutRailCannotCancel", err)
+	}
+
+	event, _ := json.Marshal(services.PayoutRailEvent{ID: "evt_1", Results: []services.PayoutRailItemResult{status[paid]}})
+	if _, err := rail.ParseWebhook(ctx, http.Header{"X-Fake-Signature": {"wrong"}}, event); !errors.Is(err, services.ErrPayoutRailInvalidSignature) {
+		t.Errorf("unsigned webhook = %v; want ErrPayoutRailInvalidSignature", err)
+	}
+	if parsed, err := rail.ParseWebhook(ctx, http.Header{"X-Fake-Signature": {"secret"}}, event); err != nil || parsed.ID != "evt_1" {
+		t.Errorf("signed webhook = %+v, %v", parsed, err)
+	}
+}
+
+func TestPayoutPayeeValidation(t *testing.T) {
+	if !services.ValidIBAN("DE89 3704 0044 0532 0130 00") || !services.ValidIBAN("gb82west12345698765432") {
+		t.Error("valid IBANs rejected")
+	}
+	if services.ValidIBAN("DE89370400440532013001") || services.ValidIBAN("DE89") {
+		t.Error("IBAN with a bad checksum or length accepted")
+	}
+	if !services.ValidBIC("DEUTDEFF") || !services.ValidBIC("deutdeff500") || services.ValidBIC("DEUT1") {
+		t.Error("BIC format check is wrong")
+	}
+
+	ctx := context.Background()
+	bank := services.NewBankFileRail(models.PayoutRailBankTransfer)
+	if err := bank.ValidatePayee(ctx, &services.PayoutPayee{AccountName: "Sara", IBAN: "DE89370400440532013001"}); !errors.Is(err, services.ErrPayoutPayeeInvalid) {
+		t.Errorf("bad IBAN = %v; want ErrPayoutPayeeInvalid", err)
+	}
+	wise := services.NewBankFileRail(models.PayoutRailWise)
+	if err := wise.ValidatePayee(ctx, &services.PayoutPayee{Email: "sara@example.com"}); err != nil {
+		t.Errorf("wise email recipient rejected: %v", err)
+	}
+}
+
+func TestBankFileRailExport(t *testing.T) {
+	rail := services.NewBankFileRail(models.PayoutRailBankTransfer)
+	payoutID := uuid.New()
+	receipt, err := rail.SubmitBatch(context.Background(), &services.PayoutRailBatch{
+		Reference: "afftok-batch-bank_transfer",
+		Items: []services.PayoutRailItem{{
+			PayoutID: payoutID,
+			Payee:    services.PayoutPayee{AccountName: "Sara Ali", IBAN: "de89 3704 0044 0532 0130 00", BIC: "DEUTDEFF", Country: "DE"},
+			Amount:   services.MoneyFromMajor(1250.5, "EUR"),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("SubmitBatch: %v", err)
+	}
+	if receipt.File == nil || receipt.File.Name != "afftok-batch-bank_transfer.csv" {
+		t.Fatalf("file = %+v; want the batch transfer file", receipt.File)
+	}
+	rows, err := csv.NewReader(strings.NewReader(string(receipt.File.Content))).ReadAll()
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("csv = %v rows, %v; want header and one payment", len(rows), err)
+	}
+	row := rows[1]
+	if row[0] != payoutID.String() || row[2] != "DE89370400440532013000" || row[6] != "1250.50" || row[7] != "EUR" {
+		t.Errorf("payment row = %v", row)
+	}
+	if len(receipt.Results) != 1 || receipt.Results[0].Settled() {
+		t.Error("file payments stay pending until reconciled")
+	}
+}
+
+func TestPayoneerWebhookSignature(t *testing.T) {
+	cfg := services.DefaultPayoneerConfig()
+	cfg.WebhookSecret = "whsec"
+	rail := services.NewPayoneerRail(cfg)
+	payoutID := uuid.New()
+	body := []byte(`{"event_id":"ev_9","event_type":"payout.status","client_reference_id":"` + payoutID.String() +
+		`","status":"Transferred","payout_date":"2026-10-01T10:00:00Z"}`)
+	mac := hmac.New(sha256.New, []byte("whsec"))
+	mac.Write(body)
+
+	event, err := rail.ParseWebhook(context.Background(), http.Header{"X-Payoneer-Signature": {hex.EncodeToString(mac.Sum(nil))}}, body)
+	if err != nil {
+		t.Fatalf("ParseWebhook: %v", err)
+	}
+	if event.ID != "ev_9" || len(event.Results) != 1 || event.Results[0].PayoutID != payoutID ||
+		event.Results[0].Status != models.PayoutStatusPaid || event.Results[0].PaidAt == nil {
+		t.Errorf("event = %+v; want one paid result", event)
+	}
+
+	tampered := []byte(strings.Replace(string(body), "Transferred", "Failed", 1))
+	if _, err := rail.ParseWebhook(context.Background(), http.Header{"X-Payoneer-Signature": {hex.EncodeToString(mac.Sum(nil))}}, tampered); !errors.Is(err, services.ErrPayoutRailInvalidSignature) {
+		t.Errorf("tampered body = %v; want ErrPayoutRailInvalidSignature", err)
+	}
+}
+
+func TestPayPalRailSubmitAndPoll(t *testing.T) {
+	payoutID := uuid.New()
+	var submitted map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/oauth2/token":
+			w.Write([]byte(`{"access_token":"tok","expires_in":3600}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/payments/payouts":
+			if r.Header.Get("Authorization") != "Bearer tok" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewDecoder(r.Body).Decode(&submitted)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"batch_header":{"payout_batch_id":"PB1","batch_status":"PENDING"}}`))
+		case r.URL.Path == "/v1/payments/payouts/PB1":
+			w.Write([]byte(`{"batch_header":{"payout_batch_id":"PB1"},"total_pages":1,"items":[{"payout_item_id":"IT1",` +
+				`"transaction_status":"SUCCESS","time_processed":"2026-10-01T10:00:00Z","payout_item":{"sender_item_id":"` + payoutID.String() + `"}}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	rail := services.NewPayPalRail(services.PayPalConfig{ClientID: "id", ClientSecret: "secret", BaseURL: server.URL})
+	receipt, err := rail.SubmitBatch(context.Background(), &services.PayoutRailBatch{
+		Reference: "afftok-batch-paypal",
+		Items:     []services.PayoutRailItem{{PayoutID: payoutID, Payee: services.PayoutPayee{Email: "p@example.com"}, Amount: services.MoneyFromMajor(40, "USD")}},
+	})
+	if err != nil || receipt.ExternalRef != "PB1" {
+		t.Fatalf("SubmitBatch = %+v, %v", receipt, err)
+	}
+	header, _ := submitted["sender_batch_header"].(map[string]interface{})
+	if header["sender_batch_id"] != "afftok-batch-paypal" {
+		t.Errorf("sender_batch_id = %v; want the batch reference", header["sender_batch_id"])
+	}
+
+	results, err := rail.PollStatus(context.Background(), &services.PayoutRailRef{ExternalRef: "PB1"})
+	if err != nil || len(results) != 1 {
+		t.Fatalf("PollStatus = %+v, %v", results, err)
+	}
+	if results[0].PayoutID != payoutID || results[0].Status != models.PayoutStatusPaid || results[0].ItemRef != "IT1" {
+		t.Errorf("result = %+v; want paid IT1", results[0])
+	}
+}
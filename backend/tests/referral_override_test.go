@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/google/uuid"
+)
+
+// ============================================
+// REFERRAL & TEAM OVERRIDES
+// ============================================
+
+func referralCandidate(id uuid.UUID, level int, bps int64) services.OverrideCandidate {
+	return services.OverrideCandidate{BeneficiaryID: id, Type: models.OverrideTypeReferral, Level: level, RateBps: bps}
+}
+
+func TestComputeOverridesLevels(t *testing.T) {
+	promoter, direct, second, owner := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	candidates := []services.OverrideCandidate{
+		referralCandidate(direct, 1, 500),
+		referralCandidate(second, 2, 200),
+		{BeneficiaryID: owner, Type: models.OverrideTypeTeam, RateBps: 300},
+	}
+
+	// 100.00 commission, 10.00 fee, 20% cap: 5.00 + 2.00 + 3.00 fits the fee
+	shares := services.ComputeOverrides(promoter, 10000, 1000, 2000, candidates)
+	if len(shares) != 3 {
+		t.Fatalf("expected 3 overrides, got %+v", shares)
+	}
+	for i, want := range []int64{500, 200, 300} {
+		if shares[i].Amount != want {
+			t.Errorf("override %d: got %d, want %d", i, shares[i].Amount, want)
+		}
+	}
+	if shares[0].Description() != "Referral override (level 1)" || shares[2].Description() != "Team override" {
+		t.Errorf("unexpected descriptions: %q, %q", shares[0].Description(), shares[2].Description())
+	}
+}
+
+func TestComputeOverridesCaps(t *testing.T) {
+	promoter, direct, second, owner := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	candidates := []services.OverrideCandidate{
+		referralCandidate(direct, 1, 500),
+		referralCandidate(second, 2, 200),
+		{BeneficiaryID: owner, Type: models.OverrideTypeTeam, RateBps: 300},
+	}
+
+	// A 6% cap leaves 1.00 for the second level and nothing for the team
+	shares := services.ComputeOverrides(promoter, 10000, 1000, 600, candidates)
+	if len(shares) != 2 || shares[0].Amount != 500 || shares[1].Amount != 100 {
+		t.Errorf("cap not applied in order: %+v", shares)
+	}
+
+	// The platform fee funds overrides: a 4.00 fee is the ceiling
+	shares = services.ComputeOverrides(promoter, 10000, 400, 2000, candidates)
+	var total int64
+	for _, s := range shares {
+		total += s.Amount
+	}
+	if total != 400 {
+		t.Errorf("overrides must not exceed the fee, paid %d", total)
+	}
+
+	if shares := services.ComputeOverrides(promoter, 10000, 0, 2000, candidates); len(shares) != 0 {
+		t.Errorf("no fee, no overrides: %+v", shares)
+	}
+}
+
+func TestComputeOverridesExclusions(t *testing.T) {
+	promoter, direct, owner := uuid.New(), uuid.New(), uuid.New()
+	excluded := referralCandidate(direct, 1, 500)
+	excluded.Excluded = services.OverrideExcludedFraudCase
+
+	shares := services.ComputeOverrides(promoter, 10000, 1000, 2000, []services.OverrideCandidate{
+		excluded,
+		referralCandidate(promoter, 2, 200), // a referral ring back to the promoter
+		{BeneficiaryID: owner, Type: models.OverrideTypeTeam, RateBps: 300},
+		{BeneficiaryID: owner, Type: models.OverrideTypeReferral, Level: 3, RateBps: 100}, // owner again
+	})
+	if len(shares) != 1 || shares[0].BeneficiaryID != owner || shares[0].Amount != 300 {
+		t.Errorf("only the team owner's override should be paid, got %+v", shares)
+	}
+}
+
+func TestOverrideEligibility(t *testing.T) {
+	active := &models.AfftokUser{Role: "promoter", Status: "active", KYCStatus: models.KYCStatusNone}
+	if reason := services.OverrideEligibility(active, false); reason != "" {
+		t.Errorf("active promoter excluded: %s", reason)
+	}
+	for name, tc := range map[string]struct {
+		user  *models.AfftokUser
+		fraud bool
+		want  string
+	}{
+		"missing user":    {nil, false, services.OverrideExcludedInactive},
+		"suspended":       {&models.AfftokUser{Role: "promoter", Status: "suspended"}, false, services.OverrideExcludedInactive},
+		"advertiser":      {&models.AfftokUser{Role: "advertiser", Status: "active"}, false, services.OverrideExcludedInactive},
+		"KYC rejected":    {&models.AfftokUser{Role: "promoter", Status: "active", KYCStatus: models.KYCStatusRejected}, false, services.OverrideExcludedKYCRejected},
+		"open fraud case": {active, true, services.OverrideExcludedFraudCase},
+	} {
+		if got := services.OverrideEligibility(tc.user, tc.fraud); got != tc.want {
+			t.Errorf("%s: got %q, want %q", name, got, tc.want)
+		}
+	}
+}
+
+func TestReferralWindow(t *testing.T) {
+	referred := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	if !services.ReferralWindowOpen(referred, 12, time.Date(2027, 1, 15, 11, 59, 0, 0, time.UTC)) {
+		t.Error("window must be open until 12 months after the referral")
+	}
+	if services.ReferralWindowOpen(referred, 12, time.Date(2027, 1, 15, 12, 0, 0, 0, time.UTC)) {
+		t.Error("window must close 12 months after the referral")
+	}
+}
+
+func TestOverridePostingsAndPeriodTotals(t *testing.T) {
+	beneficiary := uuid.New()
+	postings := services.BuildOverridePostings(beneficiary, 500)
+	if err := services.ValidateLedgerPostings(postings); err != nil {
+		t.Fatalf("override postings must balance: %v", err)
+	}
+	if err := services.ValidateLedgerPostings(services.ReversePostings(postings)); err != nil {
+		t.Fatalf("reversed override postings must balance: %v", err)
+	}
+
+	// The beneficiary's payout line gets the override out of the platform
+	// fee; the advertiser's gross is unchanged and no conversion is counted
+	tenant, advertiser := uuid.New(), uuid.New()
+	totals := services.AggregatePeriodRows([]services.LedgerPeriodRow{
+		{ID: uuid.New(), TenantID: tenant, AdvertiserID: &advertiser, PromoterID: beneficiary, Kind: models.LedgerKindConversionApproved, Currency: "USD", Commission: 10000, PlatformFee: 1000},
+		{ID: uuid.New(), TenantID: tenant, AdvertiserID: &advertiser, PromoterID: beneficiary, Kind: models.LedgerKindOverrideEarned, Currency: "USD", Commission: 500, PlatformFee: -500},
+	})
+	if len(totals) != 1 {
+		t.Fatalf("expected one total, got %+v", totals)
+	}
+	total := totals[0]
+	if total.Commission != 10500 || total.PlatformFee != 500 || total.Gross() != 11000 || total.Conversions != 1 {
+		t.Errorf("unexpected total with an override: %+v", total)
+	}
+}
+
+func TestEarningsStatementOverrides(t *testing.T) {
+	currencies := services.BuildEarningsStatement(nil, []services.EarningsStatementRow{
+		{Currency: "USD", Month: 2, Kind: models.LedgerKindConversionApproved, Count: 1, Amount: 2000},
+		{Currency: "USD", Month: 2, Kind: models.LedgerKindOverrideEarned, Count: 2, Amount: 300},
+		{Currency: "USD", Month: 3, Kind: models.LedgerKindOverrideReversed, Count: 1, Amount: -100},
+	})
+	if len(currencies) != 1 {
+		t.Fatalf("expected one currency, got %d", len(currencies))
+	}
+	usd := currencies[0]
+	if usd.Overrides != 200 || usd.Adjustments != 0 || usd.Closing != 2200 {
+		t.Errorf("overrides must be reported on their own, got %+v", usd)
+	}
+	if len(usd.Offers) != 1 {
+		t.Errorf("overrides are not offer earnings, got %d offers", len(usd.Offers))
+	}
+}
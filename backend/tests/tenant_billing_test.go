@@ -0,0 +1,485 @@
+package tests
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/google/uuid"
+)
+
+// ============================================
+// PRORATION & OVERAGE
+// ============================================
+
+func TestProrateCents(t *testing.T) {
+	start := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	end := services.BillingPeriodEnd(start, models.BillingCycleMonthly) // 30 days
+
+	cases := []struct {
+		name string
+		at   time.Time
+		want int64
+	}{
+		{"before period", start.Add(-time.Hour), 9900},
+		{"halfway", start.Add(15 * 24 * time.Hour), 4950},
+		{"one third left", start.Add(20 * 24 * time.Hour), 3300},
+		{"period over", end, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := services.ProrateCents(9900, start, end, tc.at); got != tc.want {
+				t.Errorf("ProrateCents = %d; want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBillingPeriodEndAnnual(t *testing.T) {
+	start := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+	if got := services.BillingPeriodEnd(start, models.BillingCycleAnnual); !got.Equal(start.AddDate(1, 0, 0)) {
+		t.Errorf("annual period end = %s", got)
+	}
+}
+
+func TestComputeOverageLines(t *testing.T) {
+	pro := models.GetPlanBilling(models.TenantPlanPro)
+	days := []models.TenantUsageDaily{
+		{Clicks: 10500, APICalls: 300000, WebhookDeliveries: 100000, StorageBytes: 10 << 30},
+		{Clicks: 9000, APICalls: 250000, WebhookDeliveries: 50000, StorageBytes: 21<<30 + 1},
+	}
+
+	lines := services.ComputeOverageLines(pro, 10000, days)
+	got := map[string]models.TenantInvoiceLine{}
+	for _, line := range lines {
+		if line.Kind != models.TenantInvoiceLineOverage {
+			t.Fatalf("unexpected line kind %s", line.Kind)
+		}
+		got[line.Metric] = line
+	}
+
+	// 500 extra clicks at 50c/1000 rounds up to 25c
+	if l := got[string(models.UsageMetricClicks)]; l.Quantity != 500 || l.AmountCents != 25 {
+		t.Errorf("clicks overage = %+v", l)
+	}
+	// 50,000 API calls above 500,000 at 20c/1000
+	if l := got[string(models.UsageMetricAPICalls)]; l.Quantity != 50000 || l.AmountCents != 1000 {
+		t.Errorf("api overage = %+v", l)
+	}
+	// Webhooks within the included 200,000
+	if _, ok := got[string(models.UsageMetricWebhookDeliveries)]; ok {
+		t.Errorf("webhook deliveries should not be billed")
+	}
+	// Peak storage is just over 21GB: 2GB above 20GB included
+	if l := got[string(models.UsageMetricStorageBytes)]; l.Quantity != 2 || l.AmountCents != 50 {
+		t.Errorf("storage overage = %+v", l)
+	}
+}
+
+func TestFreePlanHasNoOverage(t *testing.T) {
+	free := models.GetPlanBilling(models.TenantPlanFree)
+	days := []models.TenantUsageDaily{{Clicks: 50000, APICalls: 1000000, StorageBytes: 5 << 30}}
+	if lines := services.ComputeOverageLines(free, 1000, days); len(lines) != 0 {
+		t.Fatalf("free plan produced overage: %+v", lines)
+	}
+}
+
+// ============================================
+// PAYMENT PROVIDERS
+// ============================================
+
+func stripeSignature(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%d.", ts)))
+	mac.Write(body)
+	return fmt.Sprintf("t=%d,v1=%s", ts, hex.EncodeToString(mac.Sum(nil)))
+}
+
+func TestStripeWebhookSignature(t *testing.T) {
+	now := time.Unix(1790000000, 0)
+	invoiceID := uuid.New()
+	provider := services.NewStripeProvider(services.StripeConfig{
+		WebhookSecret: "whsec_test",
+		Now:           func() time.Time { return now },
+	})
+	body := []byte(fmt.Sprintf(`{"id":"evt_1","type":"payment_intent.succeeded",`+
+		`"data":{"object":{"id":"pi_1","status":"succeeded","metadata":{"tenant_invoice_id":"%s"}}}}`, invoiceID))
+
+	headers := http.Header{}
+	headers.Set("Stripe-Signature", stripeSignature("whsec_test", now.Unix(), body))
+	event, err := provider.ParseWebhook(headers, body)
+	if err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+	if event.ID != "evt_1" || event.Type != services.PaymentEventSucceeded || event.InvoiceID != invoiceID || event.PaymentID != "pi_1" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+
+	headers.Set("Stripe-Signature", stripeSignature("whsec_other", now.Unix(), body))
+	if _, err := provider.ParseWebhook(headers, body); !errors.Is(err, services.ErrPaymentInvalidSignature) {
+		t.Errorf("wrong secret: got %v", err)
+	}
+
+	headers.Set("Stripe-Signature", stripeSignature("whsec_test", now.Add(-10*time.Minute).Unix(), body))
+	if _, err := provider.ParseWebhook(headers, body); !errors.Is(err, services.ErrPaymentInvalidSignature) {
+		t.Errorf("expired signature: got %v", err)
+	}
+}
+
+func TestFakeProviderChargesAreIdempotent(t *testing.T) {
+	provider := services.NewFakePaymentProvider("secret")
+	ctx := context.Background()
+
+	customer, _ := provider.CreateCustomer(ctx, &services.PaymentCustomerRequest{TenantID: uuid.New()})
+	req := &services.PaymentChargeRequest{
+		CustomerID:     customer,
+		InvoiceID:      uuid.New(),
+		AmountCents:    9900,
+		Currency:       models.BillingCurrency,
+		IdempotencyKey: "invoice-1",
+	}
+
+	first, _ := provider.Charge(ctx, req)
+	second, _ := provider.Charge(ctx, req)
+	if first.PaymentID != second.PaymentID || len(provider.Charges()) != 1 {
+		t.Fatalf("retry with the same key charged twice")
+	}
+
+	provider.FailCustomer(customer, "card declined")
+	req.IdempotencyKey = "invoice-2"
+	result, _ := provider.Charge(ctx, req)
+	if result.Status != services.PaymentStatusFailed || result.FailureMessage != "card declined" {
+		t.Fatalf("expected declined charge, got %+v", result)
+	}
+}
+
+// ============================================
+// COLLECTION, DUNNING & WEBHOOKS
+// ============================================
+
+type billingFixture struct {
+	svc      *services.BillingService
+	store    *memStore
+	provider *services.FakePaymentProvider
+	email    *captureEmail
+	tenantID uuid.UUID
+	customer string
+}
+
+func newBillingFixture(t *testing.T) *billingFixture {
+	t.Helper()
+	db, store := newMemDB(t)
+	store.unique["billing_webhook_events"] = []string{"id", "provider"}
+
+	f := &billingFixture{
+		store:    store,
+		provider: services.NewFakePaymentProvider("secret"),
+		email:    &captureEmail{},
+		tenantID: uuid.New(),
+	}
+	f.customer = "cus_fake_" + f.tenantID.String()[:8]
+	f.svc = services.NewBillingService(db, f.provider)
+	f.svc.SetEmailSender(f.email)
+
+	store.insert("tenants", map[string]interface{}{
+		"id": f.tenantID, "name": "Acme", "slug": "acme", "admin_email": "owner@acme.example",
+		"status": string(models.TenantStatusActive),
+	})
+	store.insert("tenant_subscriptions", map[string]interface{}{
+		"tenant_id": f.tenantID, "plan": string(models.TenantPlanPro), "status": string(models.SubscriptionActive),
+	})
+	return f
+}
+
+// openInvoice stores an open invoice that has already been attempted attempts times
+func (f *billingFixture) openInvoice(number string, attempts int, nextAttempt *time.Time) uuid.UUID {
+	id := uuid.New()
+	row := map[string]interface{}{
+		"id": id, "tenant_id": f.tenantID, "number": number, "status": string(models.TenantInvoiceOpen),
+		"currency": models.BillingCurrency, "total_cents": int64(9900), "attempts": int64(attempts),
+		"provider_payment_id": "", "last_error": "", "next_attempt_at": nil,
+	}
+	if nextAttempt != nil {
+		row["next_attempt_at"] = *nextAttempt
+	}
+	f.store.insert("tenant_invoices", row)
+	return id
+}
+
+func (f *billingFixture) row(table, col string, value interface{}) map[string]interface{} {
+	for _, row := range f.store.table(table) {
+		if fmt.Sprint(row[col]) == fmt.Sprint(value) {
+			out := make(map[string]interface{}, len(row))
+			for k, v := range row {
+				out[k] = v
+			}
+			return out
+		}
+	}
+	return nil
+}
+
+func (f *billingFixture) invoice(id uuid.UUID) map[string]interface{} {
+	return f.row("tenant_invoices", "id", id.String())
+}
+
+func (f *billingFixture) subscriptionStatus() interface{} {
+	return f.row("tenant_subscriptions", "tenant_id", f.tenantID.String())["status"]
+}
+
+func (f *billingFixture) webhook(event services.PaymentEvent) (*services.PaymentEvent, bool, error) {
+	body, _ := json.Marshal(event)
+	headers := http.Header{}
+	headers.Set("X-Fake-Signature", "secret")
+	return f.svc.HandleWebhook(headers, body)
+}
+
+func TestChargeInvoiceCollectsPayment(t *testing.T) {
+	f := newBillingFixture(t)
+	id := f.openInvoice("INV-1", 0, nil)
+
+	if err := f.svc.ChargeInvoice(id); err != nil {
+		t.Fatalf("charge: %v", err)
+	}
+
+	invoice := f.invoice(id)
+	if invoice["status"] != string(models.TenantInvoicePaid) || invoice["provider_payment_id"] != "pi_fake_1" {
+		t.Fatalf("invoice not settled: %+v", invoice)
+	}
+	charges := f.provider.Charges()
+	if len(charges) != 1 || charges[0].CustomerID != f.customer || charges[0].AmountCents != 9900 ||
+		charges[0].IdempotencyKey != fmt.Sprintf("tenant-invoice-%s-1", id) {
+		t.Fatalf("unexpected charge requests: %+v", charges)
+	}
+	if tenant := f.row("tenants", "id", f.tenantID.String()); tenant["stripe_customer_id"] != f.customer {
+		t.Errorf("provider customer not saved on the tenant: %+v", tenant)
+	}
+
+	if err := f.svc.ChargeInvoice(id); !errors.Is(err, services.ErrBillingInvoiceNotOpen) {
+		t.Errorf("charging a paid invoice: got %v", err)
+	}
+}
+
+func TestChargeInvoiceFailureSchedulesRetry(t *testing.T) {
+	f := newBillingFixture(t)
+	f.provider.FailCustomer(f.customer, "card declined")
+	id := f.openInvoice("INV-1", 0, nil)
+
+	before := time.Now().UTC()
+	if err := f.svc.ChargeInvoice(id); err != nil {
+		t.Fatalf("charge: %v", err)
+	}
+
+	invoice := f.invoice(id)
+	if invoice["status"] != string(models.TenantInvoiceOpen) || invoice["last_error"] != "card declined" || invoice["attempts"] != int64(1) {
+		t.Fatalf("failure not recorded: %+v", invoice)
+	}
+	next, ok := invoice["next_attempt_at"].(time.Time)
+	if !ok || next.Before(before.Add(services.DunningSchedule[0])) {
+		t.Errorf("next attempt = %v; want %s from now", invoice["next_attempt_at"], services.DunningSchedule[0])
+	}
+	if status := f.subscriptionStatus(); status != string(models.SubscriptionPastDue) {
+		t.Errorf("subscription status = %v; want past_due", status)
+	}
+	if len(f.email.sent) != 1 || f.email.sent[0].To != "owner@acme.example" {
+		t.Errorf("expected a payment failed notice, got %+v", f.email.sent)
+	}
+}
+
+func TestRunDunningRetriesDueInvoices(t *testing.T) {
+	f := newBillingFixture(t)
+	past := time.Now().UTC().Add(-time.Hour)
+	future := time.Now().UTC().Add(24 * time.Hour)
+	due := f.openInvoice("INV-1", 1, &past)
+	notDue := f.openInvoice("INV-2", 1, &future)
+
+	n, err := f.svc.RunDunning()
+	if err != nil || n != 1 {
+		t.Fatalf("RunDunning = %d, %v; want 1 invoice", n, err)
+	}
+	if status := f.invoice(due)["status"]; status != string(models.TenantInvoicePaid) {
+		t.Errorf("due invoice status = %v; want paid", status)
+	}
+	if status := f.invoice(notDue)["status"]; status != string(models.TenantInvoiceOpen) {
+		t.Errorf("invoice not yet due was charged: %v", status)
+	}
+	if charges := f.provider.Charges(); len(charges) != 1 || charges[0].IdempotencyKey != fmt.Sprintf("tenant-invoice-%s-2", due) {
+		t.Errorf("unexpected charge requests: %+v", charges)
+	}
+}
+
+func TestRunDunningSuspendsAfterLastAttempt(t *testing.T) {
+	f := newBillingFixture(t)
+	f.provider.FailCustomer(f.customer, "card declined")
+	past := time.Now().UTC().Add(-time.Hour)
+	id := f.openInvoice("INV-1", len(services.DunningSchedule), &past)
+
+	if n, err := f.svc.RunDunning(); err != nil || n != 1 {
+		t.Fatalf("RunDunning = %d, %v", n, err)
+	}
+
+	invoice := f.invoice(id)
+	if invoice["status"] != string(models.TenantInvoiceUncollectible) || invoice["next_attempt_at"] != nil {
+		t.Fatalf("exhausted invoice should be uncollectible: %+v", invoice)
+	}
+	if status := f.subscriptionStatus(); status != string(models.SubscriptionSuspended) {
+		t.Errorf("subscription status = %v; want suspended", status)
+	}
+	if tenant := f.row("tenants", "id", f.tenantID.String()); tenant["status"] != string(models.TenantStatusSuspended) {
+		t.Errorf("tenant status = %v; want suspended", tenant["status"])
+	}
+
+	// Nothing left to retry
+	if n, _ := f.svc.RunDunning(); n != 0 {
+		t.Errorf("uncollectible invoice retried (%d)", n)
+	}
+
+	// Paying it reactivates the tenant
+	if _, err := f.svc.MarkInvoicePaid(id, "wire-123"); err != nil {
+		t.Fatalf("mark paid: %v", err)
+	}
+	if status := f.subscriptionStatus(); status != string(models.SubscriptionActive) {
+		t.Errorf("subscription status after payment = %v; want active", status)
+	}
+	if tenant := f.row("tenants", "id", f.tenantID.String()); tenant["status"] != string(models.TenantStatusActive) {
+		t.Errorf("tenant status after payment = %v; want active", tenant["status"])
+	}
+}
+
+func TestHandleWebhookIgnoresRedelivery(t *testing.T) {
+	f := newBillingFixture(t)
+	id := f.openInvoice("INV-1", 1, nil)
+	event := services.PaymentEvent{ID: "evt_1", Type: services.PaymentEventSucceeded, PaymentID: "pi_async", InvoiceID: id}
+
+	if _, duplicate, err := f.webhook(event); err != nil || duplicate {
+		t.Fatalf("first delivery: duplicate=%v err=%v", duplicate, err)
+	}
+	if invoice := f.invoice(id); invoice["status"] != string(models.TenantInvoicePaid) || invoice["provider_payment_id"] != "pi_async" {
+		t.Fatalf("webhook did not settle the invoice: %+v", invoice)
+	}
+
+	if _, duplicate, err := f.webhook(event); err != nil || !duplicate {
+		t.Fatalf("redelivery: duplicate=%v err=%v; want duplicate", duplicate, err)
+	}
+	if n := len(f.store.table("billing_webhook_events")); n != 1 {
+		t.Errorf("recorded %d events; want 1", n)
+	}
+
+	headers := http.Header{}
+	headers.Set("X-Fake-Signature", "wrong")
+	if _, _, err := f.svc.HandleWebhook(headers, []byte(`{}`)); !errors.Is(err, services.ErrPaymentInvalidSignature) {
+		t.Errorf("bad signature: got %v", err)
+	}
+}
+
+func TestHandleWebhookFailureAllowsRetry(t *testing.T) {
+	f := newBillingFixture(t)
+	id := f.openInvoice("INV-1", 1, nil)
+	event := services.PaymentEvent{ID: "evt_1", Type: services.PaymentEventSucceeded, PaymentID: "pi_async", InvoiceID: id}
+
+	f.store.failOn[`UPDATE "tenant_invoices"`] = errors.New("connection reset")
+	if _, _, err := f.webhook(event); err == nil {
+		t.Fatal("expected the failed update to be reported")
+	}
+	if n := len(f.store.table("billing_webhook_events")); n != 0 {
+		t.Fatalf("failed event stayed recorded (%d rows); the retry would be ignored", n)
+	}
+
+	delete(f.store.failOn, `UPDATE "tenant_invoices"`)
+	if _, duplicate, err := f.webhook(event); err != nil || duplicate {
+		t.Fatalf("retry: duplicate=%v err=%v", duplicate, err)
+	}
+	if status := f.invoice(id)["status"]; status != string(models.TenantInvoicePaid) {
+		t.Errorf("retry did not settle the invoice: %v", status)
+	}
+}
+
+func TestHandleWebhookFailedPaymentSchedulesRetry(t *testing.T) {
+	f := newBillingFixture(t)
+	id := f.openInvoice("INV-1", 1, nil)
+	f.store.mu.Lock()
+	for _, row := range f.store.rows["tenant_invoices"] {
+		row["provider_payment_id"] = "pi_async"
+	}
+	f.store.mu.Unlock()
+
+	// A failure for another payment is stale and ignored
+	stale := services.PaymentEvent{ID: "evt_0", Type: services.PaymentEventFailed, PaymentID: "pi_old", InvoiceID: id, FailureMessage: "expired"}
+	if _, _, err := f.webhook(stale); err != nil {
+		t.Fatalf("stale event: %v", err)
+	}
+	if invoice := f.invoice(id); invoice["last_error"] != "" {
+		t.Fatalf("stale failure applied: %+v", invoice)
+	}
+
+	event := services.PaymentEvent{ID: "evt_1", Type: services.PaymentEventFailed, PaymentID: "pi_async", InvoiceID: id, FailureMessage: "insufficient funds"}
+	if _, _, err := f.webhook(event); err != nil {
+		t.Fatalf("failed event: %v", err)
+	}
+	invoice := f.invoice(id)
+	if invoice["last_error"] != "insufficient funds" || invoice["next_attempt_at"] == nil {
+		t.Errorf("failure not recorded: %+v", invoice)
+	}
+	if status := f.subscriptionStatus(); status != string(models.SubscriptionPastDue) {
+		t.Errorf("subscription status = %v; want past_due", status)
+	}
+}
+
+func TestRunDueBillsAPeriodOnceAcrossReplicas(t *testing.T) {
+	f := newBillingFixture(t)
+	end := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	start := end.AddDate(0, -1, 0)
+	f.store.mu.Lock()
+	for _, sub := range f.store.rows["tenant_subscriptions"] {
+		sub["cycle"] = string(models.BillingCycleMonthly)
+		sub["current_period_start"] = start
+		sub["current_period_end"] = end
+		sub["overage_billed_through"] = end
+	}
+	f.store.mu.Unlock()
+
+	// Both replicas read the subscription before either renews it
+	f.store.respond(`FROM "tenant_subscriptions" WHERE status IN`,
+		[]string{"tenant_id", "plan", "cycle", "status", "current_period_start", "current_period_end", "overage_billed_through", "cancel_at_period_end", "credit_cents"},
+		[]driver.Value{f.tenantID.String(), string(models.TenantPlanPro), string(models.BillingCycleMonthly), string(models.SubscriptionActive), start, end, end, false, int64(0)})
+
+	var wg sync.WaitGroup
+	renewed := make([]int, 2)
+	for i := range renewed {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			n, err := f.svc.RunDue()
+			if err != nil {
+				t.Errorf("RunDue: %v", err)
+			}
+			renewed[i] = n
+		}(i)
+	}
+	wg.Wait()
+
+	if renewed[0]+renewed[1] != 1 {
+		t.Errorf("renewed %v; want the period renewed once", renewed)
+	}
+	if invoices := f.store.table("tenant_invoices"); len(invoices) != 1 || invoices[0]["reason"] != "subscription_cycle" {
+		t.Fatalf("invoices = %v; want one subscription_cycle invoice", invoices)
+	}
+	if charges := f.provider.Charges(); len(charges) != 1 {
+		t.Fatalf("charges = %+v; want one", charges)
+	}
+	sub := f.row("tenant_subscriptions", "tenant_id", f.tenantID.String())
+	if periodEnd, ok := sub["current_period_end"].(time.Time); !ok || !periodEnd.After(time.Now()) {
+		t.Errorf("period not advanced: %v", sub["current_period_end"])
+	}
+}
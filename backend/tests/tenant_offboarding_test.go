@@ -0,0 +1,362 @@
+package tests
+
+import (
+	"archive/zip"
+	"bufio"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/google/uuid"
+)
+
+// ============================================
+// OFFBOARDING
+// ============================================
+
+func TestOrderTenantTablesDeletesChildrenFirst(t *testing.T) {
+	direct := []string{"offers", "clicks", "tenant_invoices", "afftok_users"}
+	fks := []services.TableForeignKey{
+		{Child: "clicks", ChildColumn: "offer_id", Parent: "offers", ParentColumn: "id"},
+		{Child: "offers", ChildColumn: "created_by", Parent: "afftok_users", ParentColumn: "id"},
+		{Child: "tenant_invoice_lines", ChildColumn: "invoice_id", Parent: "tenant_invoices", ParentColumn: "id"},
+		{Child: "tenant_domains", ChildColumn: "tenant_id", Parent: "tenants", ParentColumn: "id"},
+	}
+
+	scopes := services.OrderTenantTables(direct, fks)
+	position := map[string]int{}
+	for i, scope := range scopes {
+		position[scope.Table] = i
+	}
+
+	if len(scopes) != 5 {
+		t.Fatalf("expected 4 direct tables and 1 child table, got %+v", scopes)
+	}
+	if position["clicks"] > position["offers"] || position["offers"] > position["afftok_users"] {
+		t.Errorf("referencing tables must come first: %v", position)
+	}
+	if position["tenant_invoice_lines"] > position["tenant_invoices"] {
+		t.Errorf("invoice lines must be deleted before invoices: %v", position)
+	}
+
+	lines := scopes[position["tenant_invoice_lines"]]
+	if lines.Via == nil || !strings.Contains(lines.Where(), `"invoice_id" IN (SELECT "id" FROM "tenant_invoices" WHERE tenant_id = ?)`) {
+		t.Errorf("child table scope = %q", lines.Where())
+	}
+	if where := scopes[position["offers"]].Where(); where != "tenant_id = ?" {
+		t.Errorf("direct table scope = %q", where)
+	}
+}
+
+func TestOrderTenantTablesSurvivesCycles(t *testing.T) {
+	direct := []string{"a", "b"}
+	fks := []services.TableForeignKey{
+		{Child: "a", ChildColumn: "b_id", Parent: "b", ParentColumn: "id"},
+		{Child: "b", ChildColumn: "a_id", Parent: "a", ParentColumn: "id"},
+		{Child: "a", ChildColumn: "parent_id", Parent: "a", ParentColumn: "id"},
+	}
+	if scopes := services.OrderTenantTables(direct, fks); len(scopes) != 2 {
+		t.Fatalf("expected both tables despite the cycle, got %+v", scopes)
+	}
+}
+
+func TestExportRedactsCredentials(t *testing.T) {
+	for _, column := range []string{"password_hash", "key_hash", "hmac_secret", "api_key", "email_token_hash", "refresh_token", "signing_key"} {
+		if !services.IsRedactedExportColumn(column) {
+			t.Errorf("%s should be redacted", column)
+		}
+	}
+	for _, column := range []string{"email", "tenant_id", "payout_method", "secret_question_id"} {
+		if services.IsRedactedExportColumn(column) {
+			t.Errorf("%s should be exported", column)
+		}
+	}
+}
+
+func TestWALPurgeTenant(t *testing.T) {
+	cfg := services.DefaultWALConfig()
+	cfg.Dir = t.TempDir()
+	wal, err := services.NewWALService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gone, kept := uuid.New().String(), uuid.New().String()
+	for i := 0; i < 3; i++ {
+		wal.Append(services.WALEventClick, gone, map[string]interface{}{"i": i})
+	}
+	wal.Append(services.WALEventClick, kept, map[string]interface{}{"i": 0})
+	wal.Flush()
+
+	removed, err := wal.PurgeTenant(gone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 3 {
+		t.Fatalf("removed %d entries; want 3", removed)
+	}
+
+	pending, _ := wal.GetPendingEntries()
+	if len(pending) != 1 || pending[0].TenantID != kept {
+		t.Fatalf("unexpected entries after purge: %+v", pending)
+	}
+
+	// The WAL keeps working after the purge reopened its file
+	if _, err := wal.Append(services.WALEventClick, kept, nil); err != nil {
+		t.Fatalf("append after purge: %v", err)
+	}
+}
+
+// offboardingFixture is a tenant with users and webhook steps, next to
+// another tenant whose rows must survive its offboarding
+type offboardingFixture struct {
+	svc      *services.TenantOffboardingService
+	store    *memStore
+	wal      *services.WALService
+	tenantID uuid.UUID
+	otherID  uuid.UUID
+}
+
+func newOffboardingFixture(t *testing.T) *offboardingFixture {
+	t.Helper()
+	db, store := newMemDB(t)
+	cfg := services.DefaultWALConfig()
+	cfg.Dir = t.TempDir()
+	wal, err := services.NewWALService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &offboardingFixture{
+		svc:      services.NewTenantOffboardingService(db, t.TempDir()),
+		store:    store,
+		wal:      wal,
+		tenantID: uuid.New(),
+		otherID:  uuid.New(),
+	}
+	f.svc.SetWAL(wal)
+
+	// Schema discovery: two tenant tables, no foreign keys
+	store.respond("column_name = 'tenant_id'", []string{"table_name"},
+		[]driver.Value{"afftok_users"}, []driver.Value{"webhook_steps"})
+	store.respond("constraint_type = 'FOREIGN KEY'", []string{"child", "child_column", "parent", "parent_column"})
+
+	for i, id := range []uuid.UUID{f.tenantID, f.otherID} {
+		store.insert("tenants", map[string]interface{}{
+			"id": id, "name": "Tenant", "slug": []string{"acme", "other"}[i], "admin_email": "owner@example.com",
+			"status": string(models.TenantStatusActive),
+		})
+		store.insert("afftok_users", map[string]interface{}{
+			"id": uuid.New(), "tenant_id": id, "username": []string{"acme-user", "other-user"}[i], "password_hash": "$2a$hash",
+		})
+		store.insert("webhook_steps", map[string]interface{}{
+			"id": uuid.New(), "tenant_id": id, "url": "https://hooks.example.com", "signing_key": "whsk_live_secret",
+		})
+	}
+	return f
+}
+
+func (f *offboardingFixture) rows(table string, tenantID uuid.UUID) int {
+	n := 0
+	for _, row := range f.store.table(table) {
+		if row["tenant_id"] == tenantID.String() {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *offboardingFixture) job(table string) map[string]driver.Value {
+	rows := f.store.table(table)
+	if len(rows) != 1 {
+		return nil
+	}
+	return rows[0]
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestTenantExportArchive(t *testing.T) {
+	f := newOffboardingFixture(t)
+	if _, err := f.svc.RequestExport(f.tenantID, models.TenantExportNDJSON, nil); err != nil {
+		t.Fatalf("request export: %v", err)
+	}
+	f.svc.RunPending()
+
+	job := f.job("tenant_export_jobs")
+	if job["status"] != string(models.TenantJobCompleted) {
+		t.Fatalf("export not completed: %+v", job)
+	}
+	path := job["file_path"].(string)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read archive: %v", err)
+	}
+	if job["sha256"] != sha256Hex(data) || job["size_bytes"] != int64(len(data)) {
+		t.Errorf("job checksum/size do not match the archive")
+	}
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	contents := map[string][]byte{}
+	for _, file := range zr.File {
+		rc, _ := file.Open()
+		contents[file.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+
+	var manifest models.TenantExportManifest
+	if err := json.Unmarshal(contents["manifest.json"], &manifest); err != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+	if manifest.TenantID != f.tenantID || len(manifest.Files) != 3 {
+		t.Fatalf("unexpected manifest: %+v", manifest)
+	}
+	for _, file := range manifest.Files {
+		content, ok := contents[file.Name]
+		if !ok {
+			t.Errorf("%s listed in the manifest but missing from the archive", file.Name)
+			continue
+		}
+		if file.SHA256 != sha256Hex(content) || file.Bytes != int64(len(content)) {
+			t.Errorf("%s checksum/size mismatch", file.Name)
+		}
+		if file.Table != "tenants" && file.Rows != 1 {
+			t.Errorf("%s has %d rows; want only the tenant's row", file.Name, file.Rows)
+		}
+	}
+
+	redacted := strings.Join(manifest.Redacted, ",")
+	for _, column := range []string{"afftok_users.password_hash", "webhook_steps.signing_key"} {
+		if !strings.Contains(redacted, column) {
+			t.Errorf("%s not listed as redacted: %v", column, manifest.Redacted)
+		}
+	}
+	for name, content := range contents {
+		if strings.Contains(string(content), "whsk_live_secret") || strings.Contains(string(content), "$2a$hash") {
+			t.Errorf("%s leaks a credential", name)
+		}
+		if strings.Contains(string(content), "other-user") {
+			t.Errorf("%s contains another tenant's rows", name)
+		}
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(contents["afftok_users.ndjson"])))
+	for scanner.Scan() {
+		var row map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil || row["username"] != "acme-user" {
+			t.Errorf("unexpected user row %s", scanner.Text())
+		}
+	}
+}
+
+func TestTenantDeletionCoolingOff(t *testing.T) {
+	f := newOffboardingFixture(t)
+
+	if _, err := f.svc.ScheduleDeletion(f.tenantID, "closing", time.Hour, nil); !errors.Is(err, services.ErrDeletionCoolingOffTooLow) {
+		t.Fatalf("short cooling-off: got %v", err)
+	}
+	if _, err := f.svc.ScheduleDeletion(models.DefaultTenantID, "", 0, nil); !errors.Is(err, services.ErrOffboardingDefaultTenant) {
+		t.Fatalf("default tenant: got %v", err)
+	}
+
+	job, err := f.svc.ScheduleDeletion(f.tenantID, "closing", 0, nil)
+	if err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	if wait := time.Until(job.ScheduledFor); wait < services.DefaultTenantDeletionCoolingOff-time.Minute {
+		t.Errorf("scheduled in %s; want the default cooling-off", wait)
+	}
+	if _, err := f.svc.ScheduleDeletion(f.tenantID, "again", 0, nil); !errors.Is(err, services.ErrDeletionAlreadyScheduled) {
+		t.Errorf("second schedule: got %v", err)
+	}
+
+	// Nothing is purged while the cooling-off runs
+	f.svc.RunPending()
+	if f.rows("afftok_users", f.tenantID) != 1 || f.job("tenant_deletion_jobs")["status"] != string(models.TenantJobScheduled) {
+		t.Fatal("tenant purged before the cooling-off ended")
+	}
+
+	if _, err := f.svc.CancelDeletion(f.tenantID, nil); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	for _, row := range f.store.table("tenants") {
+		if row["id"] == f.tenantID.String() && row["status"] != string(models.TenantStatusActive) {
+			t.Errorf("canceled deletion left tenant %v", row["status"])
+		}
+	}
+}
+
+func TestTenantDeletionPurgesAndRetries(t *testing.T) {
+	f := newOffboardingFixture(t)
+	f.wal.Append(services.WALEventClick, f.tenantID.String(), nil)
+	f.wal.Append(services.WALEventClick, f.otherID.String(), nil)
+	f.wal.Flush()
+
+	if _, err := f.svc.ScheduleDeletion(f.tenantID, "closing", 0, nil); err != nil {
+		t.Fatalf("schedule: %v", err)
+	}
+	// The cooling-off has passed
+	f.store.mu.Lock()
+	f.store.rows["tenant_deletion_jobs"][0]["scheduled_for"] = time.Now().UTC().Add(-time.Minute)
+	f.store.mu.Unlock()
+
+	f.store.failOn[`DELETE FROM "webhook_steps"`] = errors.New("lock timeout")
+	f.svc.RunPending()
+	job := f.job("tenant_deletion_jobs")
+	if job["status"] != string(models.TenantJobFailed) || !strings.Contains(job["error"].(string), "webhook_steps") {
+		t.Fatalf("failed purge not recorded: %+v", job)
+	}
+
+	delete(f.store.failOn, `DELETE FROM "webhook_steps"`)
+	if _, err := f.svc.RetryDeletion(f.tenantID); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	f.svc.RunPending()
+
+	job = f.job("tenant_deletion_jobs")
+	if job["status"] != string(models.TenantJobCompleted) || job["error"] != "" {
+		t.Fatalf("retried deletion not completed: %+v", job)
+	}
+	for _, table := range []string{"afftok_users", "webhook_steps"} {
+		if n := f.rows(table, f.tenantID); n != 0 {
+			t.Errorf("%s still has %d of the tenant's rows", table, n)
+		}
+		if n := f.rows(table, f.otherID); n != 1 {
+			t.Errorf("%s lost the other tenant's rows", table)
+		}
+	}
+	if job["wal_entries_removed"] != int64(1) {
+		t.Errorf("WAL entries removed = %v; want 1", job["wal_entries_removed"])
+	}
+
+	for _, row := range f.store.table("tenants") {
+		if row["id"] == f.tenantID.String() && (row["status"] != string(models.TenantStatusDeleted) || row["admin_email"] != "") {
+			t.Errorf("tenant row not anonymised: %+v", row)
+		}
+	}
+	var audit []string
+	for _, row := range f.store.table("tenant_audit_logs") {
+		if row["tenant_id"] == f.tenantID.String() {
+			audit = append(audit, row["action"].(string))
+		}
+	}
+	if len(audit) != 1 || audit[0] != string(models.TenantAuditHardDeleted) {
+		t.Errorf("audit trail after purge = %v; want only the hard delete", audit)
+	}
+}
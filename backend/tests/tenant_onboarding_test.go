@@ -0,0 +1,203 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ============================================
+// TENANT ONBOARDING
+// ============================================
+
+func TestOnboardingStepsComplete(t *testing.T) {
+	onboarding := &models.TenantOnboarding{}
+	if onboarding.IsComplete() {
+		t.Fatal("empty onboarding must not be complete")
+	}
+
+	first := time.Now().Add(-time.Hour)
+	onboarding.MarkStep(models.OnboardingStepVerifyEmail, first)
+	onboarding.MarkStep(models.OnboardingStepVerifyEmail, time.Now())
+
+	steps := onboarding.CompletedSteps()
+	if !steps[models.OnboardingStepVerifyEmail].Equal(first) {
+		t.Errorf("first completion must win, got %v want %v", steps[models.OnboardingStepVerifyEmail], first)
+	}
+
+	for _, step := range models.OnboardingSteps[1:] {
+		if onboarding.IsComplete() {
+			t.Fatalf("onboarding complete before %s", step)
+		}
+		onboarding.MarkStep(step, time.Now())
+	}
+	if !onboarding.IsComplete() {
+		t.Error("onboarding should be complete after every step")
+	}
+}
+
+// captureEmail records sent messages instead of delivering them
+type captureEmail struct {
+	mu   sync.Mutex
+	sent []services.EmailMessage
+}
+
+func (e *captureEmail) Send(msg services.EmailMessage) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sent = append(e.sent, msg)
+	return nil
+}
+
+var verificationTokenPattern = regexp.MustCompile(`token=([0-9a-f]{64})`)
+
+func signupTenant(t *testing.T) (*services.TenantOnboardingService, *memStore, *services.TenantSignupResult, string) {
+	t.Helper()
+	db, store := newMemDB(t)
+	email := &captureEmail{}
+	svc := services.NewTenantOnboardingService(db)
+	svc.SetEmailSender(email)
+
+	result, err := svc.Signup(&services.TenantSignupRequest{
+		CompanyName:   "Acme Media",
+		Slug:          " Acme-Media ",
+		AdminEmail:    "Owner@Acme.example",
+		AdminUsername: "acme-owner",
+		Password:      "correct-horse-battery",
+	})
+	if err != nil {
+		t.Fatalf("signup: %v", err)
+	}
+	if len(email.sent) != 1 || email.sent[0].To != "owner@acme.example" {
+		t.Fatalf("expected one verification email to the admin, got %+v", email.sent)
+	}
+	m := verificationTokenPattern.FindStringSubmatch(email.sent[0].Text)
+	if m == nil {
+		t.Fatalf("verification link missing from %q", email.sent[0].Text)
+	}
+	return svc, store, result, m[1]
+}
+
+func TestTenantSignupSeedsDefaults(t *testing.T) {
+	svc, store, result, _ := signupTenant(t)
+	tenant, admin := result.Tenant, result.Admin
+
+	if tenant.Slug != "acme-media" || tenant.Status != models.TenantStatusPending {
+		t.Errorf("tenant must be pending with a normalised slug: %+v", tenant)
+	}
+	if admin.TenantID != tenant.ID || admin.TenantID == models.DefaultTenantID {
+		t.Errorf("admin must belong to the new tenant, got %s", admin.TenantID)
+	}
+	if tenant.AdminUserID == nil || *tenant.AdminUserID != admin.ID || tenant.DefaultNetworkID == nil {
+		t.Errorf("tenant must point at its admin and network: %+v", tenant)
+	}
+
+	seeded := map[string]int{"networks": 1, "offers": 1, "webhook_pipelines": 1, "webhook_steps": 1, "tenant_onboardings": 1}
+	for table, want := range seeded {
+		rows := store.table(table)
+		if len(rows) != want {
+			t.Errorf("%s: expected %d seeded rows, got %d", table, want, len(rows))
+			continue
+		}
+		if tenantID, ok := rows[0]["tenant_id"]; ok && tenantID != tenant.ID.String() {
+			t.Errorf("%s seeded for tenant %v, want %s", table, tenantID, tenant.ID)
+		}
+	}
+	if offers := store.table("offers"); len(offers) == 1 && offers[0]["status"] != "paused" {
+		t.Errorf("the sample offer must start paused, got %v", offers[0]["status"])
+	}
+	if pipelines := store.table("webhook_pipelines"); len(pipelines) == 1 && pipelines[0]["status"] != string(models.WebhookPipelineStatusDraft) {
+		t.Errorf("the sample webhook must start as a draft, got %v", pipelines[0]["status"])
+	}
+
+	_, err := svc.Signup(&services.TenantSignupRequest{
+		CompanyName: "Copycat", Slug: "copycat", AdminEmail: "other@copycat.example",
+		AdminUsername: "acme-owner", Password: "correct-horse-battery",
+	})
+	if !errors.Is(err, services.ErrSignupUsernameTaken) {
+		t.Errorf("expected ErrSignupUsernameTaken, got %v", err)
+	}
+}
+
+func TestTenantOnboardingActivation(t *testing.T) {
+	svc, store, result, token := signupTenant(t)
+	tenantID := result.Tenant.ID
+
+	if _, err := svc.VerifyEmail("not-the-token"); !errors.Is(err, services.ErrOnboardingInvalidToken) {
+		t.Errorf("expected ErrOnboardingInvalidToken, got %v", err)
+	}
+	if _, err := svc.CompleteStep(tenantID, models.OnboardingStepVerifyEmail, &services.OnboardingStepInput{}); !errors.Is(err, services.ErrOnboardingStepBlocked) {
+		t.Errorf("verify_email must only complete through the token, got %v", err)
+	}
+
+	progress, err := svc.VerifyEmail(token)
+	if err != nil {
+		t.Fatalf("verify email: %v", err)
+	}
+	if progress.CompletedCount != 1 || progress.TenantStatus != models.TenantStatusPending {
+		t.Errorf("only verify_email should be done: %+v", progress)
+	}
+	if _, err := svc.VerifyEmail(token); !errors.Is(err, services.ErrOnboardingInvalidToken) {
+		t.Errorf("a used token must not verify again, got %v", err)
+	}
+
+	input := &services.OnboardingStepInput{CompanyName: "Acme Media Group", PrimaryColor: "#112233"}
+	for _, step := range []string{models.OnboardingStepCompanyProfile, models.OnboardingStepBranding} {
+		if _, err := svc.CompleteStep(tenantID, step, input); err != nil {
+			t.Fatalf("%s: %v", step, err)
+		}
+	}
+
+	if _, err := svc.CompleteStep(tenantID, models.OnboardingStepFirstOffer, input); !errors.Is(err, services.ErrOnboardingStepBlocked) {
+		t.Fatalf("first_offer must wait for an active offer, got %v", err)
+	}
+	store.insert("offers", map[string]interface{}{"id": uuid.NewString(), "tenant_id": tenantID, "status": "active", "title": "Live offer"})
+
+	progress, err = svc.CompleteStep(tenantID, models.OnboardingStepFirstOffer, input)
+	if err != nil {
+		t.Fatalf("first_offer: %v", err)
+	}
+	if progress.Percent != 100 || progress.CompletedAt == nil || progress.TenantStatus != models.TenantStatusActive {
+		t.Errorf("tenant must activate once every step is done: %+v", progress)
+	}
+	if tenants := store.table("tenants"); len(tenants) != 1 || tenants[0]["status"] != string(models.TenantStatusActive) {
+		t.Errorf("activation must be stored on the tenant: %+v", tenants)
+	}
+}
+
+func TestSignupAdminIsNotSuperAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for name, tc := range map[string]struct {
+		tenantID uuid.UUID
+		want     int
+	}{
+		"tenant admin":   {uuid.New(), http.StatusForbidden},
+		"platform admin": {models.DefaultTenantID, http.StatusOK},
+	} {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("role", "admin")
+			c.Set("jwt_tenant_id", tc.tenantID)
+			c.Set(middleware.TenantIDKey, tc.tenantID)
+			c.Next()
+		}, middleware.TenantMembershipMiddleware(), middleware.SuperAdminMiddleware())
+		router.GET("/admin/dashboard", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/dashboard", nil))
+		if w.Code != tc.want {
+			t.Errorf("%s: got %d, want %d", name, w.Code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/aljapah/afftok-backend-prod/internal/database"
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// ============================================
+// ROW-LEVEL SECURITY HARNESS
+// ============================================
+//
+// Runs against a real Postgres (TEST_DATABASE_URL) because the policies are
+// enforced by the database. Uses the networks table as a representative
+// tenant table; rows are inserted as the owner and removed afterwards.
+
+// openTestPostgres connects to TEST_DATABASE_URL, skipping the test without it
+func openTestPostgres(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set - skipping Postgres tests")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	return db
+}
+
+func openRLSDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := openTestPostgres(t)
+
+	db.Exec(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`)
+	if err := db.AutoMigrate(&models.Tenant{}, &models.Network{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := database.MigrateTenantRLS(db); err != nil {
+		t.Fatalf("rls migration: %v", err)
+	}
+	return db
+}
+
+func seedRLSTenants(t *testing.T, db *gorm.DB) (uuid.UUID, uuid.UUID) {
+	t.Helper()
+	var ids []uuid.UUID
+	for _, slug := range []string{"rls-a", "rls-b"} {
+		tenant := models.Tenant{ID: uuid.New(), Name: slug, Slug: slug + "-" + uuid.NewString()[:8], Status: models.TenantStatusActive}
+		if err := db.Create(&tenant).Error; err != nil {
+			t.Fatalf("seed tenant: %v", err)
+		}
+		network := models.Network{TenantModel: models.TenantModel{TenantID: tenant.ID}, ID: uuid.New(), Name: "rls " + slug}
+		if err := db.Create(&network).Error; err != nil {
+			t.Fatalf("seed network: %v", err)
+		}
+		ids = append(ids, tenant.ID)
+	}
+
+	t.Cleanup(func() {
+		db.Where("tenant_id IN ?", ids).Delete(&models.Network{})
+		db.Where("id IN ?", ids).Delete(&models.Tenant{})
+	})
+	return ids[0], ids[1]
+}
+
+func countNetworks(t *testing.T, tx *gorm.DB, tenantIDs ...uuid.UUID) int64 {
+	t.Helper()
+	var count int64
+	if err := tx.Model(&models.Network{}).Where("tenant_id IN ?", tenantIDs).Count(&count).Error; err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	return count
+}
+
+func TestRLSWithoutTenantVariableReturnsNothing(t *testing.T) {
+	db := openRLSDB(t)
+	tenantA, tenantB := seedRLSTenants(t, db)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Tenant role, but app.tenant_id never set
+		if err := tx.Exec("SET LOCAL ROLE " + database.RLSTenantRole).Error; err != nil {
+			return err
+		}
+		if n := countNetworks(t, tx, tenantA, tenantB); n != 0 {
+			t.Errorf("expected no rows without %s, got %d", database.TenantRLSSetting, n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("transaction: %v", err)
+	}
+}
+
+func TestRLSScopesToTenant(t *testing.T) {
+	db := openRLSDB(t)
+	tenantA, tenantB := seedRLSTenants(t, db)
+
+	err := database.WithTenantRLS(db, tenantA, func(tx *gorm.DB) error {
+		// No tenant_id predicate from the application: Postgres filters
+		var networks []models.Network
+		if err := tx.Where("tenant_id IN ?", []uuid.UUID{tenantA, tenantB}).Find(&networks).Error; err != nil {
+			return err
+		}
+		if len(networks) != 1 || networks[0].TenantID != tenantA {
+			t.Errorf("expected only tenant A's row, got %+v", networks)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("transaction: %v", err)
+	}
+}
+
+func TestRLSRejectsWritesIntoAnotherTenant(t *testing.T) {
+	db := openRLSDB(t)
+	tenantA, tenantB := seedRLSTenants(t, db)
+
+	err := database.WithTenantRLS(db, tenantA, func(tx *gorm.DB) error {
+		network := models.Network{TenantModel: models.TenantModel{TenantID: tenantB}, ID: uuid.New(), Name: "cross-tenant"}
+		return tx.Create(&network).Error
+	})
+	if err == nil {
+		t.Fatal("expected the policy's WITH CHECK to reject a row for another tenant")
+	}
+}
+
+func TestRLSBypassSeesAllTenants(t *testing.T) {
+	db := openRLSDB(t)
+	tenantA, tenantB := seedRLSTenants(t, db)
+
+	var exists bool
+	db.Raw("SELECT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = ?)", database.RLSBypassRole).Scan(&exists)
+	if !exists {
+		t.Skipf("%s role not present (needs superuser to create)", database.RLSBypassRole)
+	}
+
+	err := database.WithRLSBypass(db, func(tx *gorm.DB) error {
+		if n := countNetworks(t, tx, tenantA, tenantB); n != 2 {
+			t.Errorf("bypass role should see both tenants, got %d", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("transaction: %v", err)
+	}
+}
+
+// ============================================
+// MIDDLEWARE COMMIT
+// ============================================
+
+// commitDriver accepts every statement and fails COMMIT when told to
+type commitDriver struct{ failCommit bool }
+
+func (d *commitDriver) Connect(context.Context) (driver.Conn, error) { return commitConn{d}, nil }
+func (d *commitDriver) Driver() driver.Driver                        { return nil }
+
+type commitConn struct{ d *commitDriver }
+
+func (c commitConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c commitConn) Close() error                        { return nil }
+func (c commitConn) Begin() (driver.Tx, error)           { return commitTx(c), nil }
+func (c commitConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+
+type commitTx commitConn
+
+func (t commitTx) Commit() error {
+	if t.d.failCommit {
+		return errors.New("could not serialize access")
+	}
+	return nil
+}
+func (t commitTx) Rollback() error { return nil }
+
+func rlsMiddlewareRouter(t *testing.T, failCommit bool) *gin.Engine {
+	t.Helper()
+	database.SetTenantRLSEnabled(true)
+	t.Cleanup(func() { database.SetTenantRLSEnabled(false) })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(&commitDriver{failCommit: failCommit})}), &gorm.Config{
+		Logger:               logger.Default.LogMode(logger.Silent),
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.TenantRLSMiddleware(db))
+	router.POST("/items", func(c *gin.Context) {
+		c.Header("X-Item", "1")
+		c.JSON(http.StatusCreated, gin.H{"success": true})
+	})
+	return router
+}
+
+func TestRLSMiddlewareSendsResponseAfterCommit(t *testing.T) {
+	w := httptest.NewRecorder()
+	rlsMiddlewareRouter(t, false).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/items", nil))
+	if w.Code != http.StatusCreated || w.Body.String() != `{"success":true}` || w.Header().Get("X-Item") != "1" {
+		t.Errorf("committed response not passed through: %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+}
+
+func TestRLSMiddlewareFailsRequestWhenCommitFails(t *testing.T) {
+	w := httptest.NewRecorder()
+	rlsMiddlewareRouter(t, true).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/items", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("a failed commit must not report success, got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+)
+
+// ============================================
+// TENANT SETTINGS
+// ============================================
+
+func TestParseTenantSettingsKeepsDefaults(t *testing.T) {
+	settings := services.ParseTenantSettings([]byte(`{"enable_geo_rules": false, "webhook_retry_count": 2}`))
+	defaults := models.DefaultTenantSettings()
+
+	if settings.EnableGeoRules {
+		t.Error("an explicit false must be kept")
+	}
+	if settings.WebhookRetryCount != 2 {
+		t.Errorf("webhook_retry_count = %d; want 2", settings.WebhookRetryCount)
+	}
+	if !settings.EnableBotDetection || !settings.EnableFraudDetection {
+		t.Error("missing booleans must keep their default")
+	}
+	if settings.DefaultLinkTTL != defaults.DefaultLinkTTL || settings.APIRateLimitPerMin != defaults.APIRateLimitPerMin ||
+		settings.WebhookTimeoutMs != defaults.WebhookTimeoutMs || settings.Timezone != defaults.Timezone {
+		t.Errorf("missing values must keep their default, got %+v", settings)
+	}
+
+	if empty := services.ParseTenantSettings(nil); !reflect.DeepEqual(*empty, defaults) {
+		t.Errorf("no stored settings must give the defaults, got %+v", empty)
+	}
+	if broken := services.ParseTenantSettings([]byte(`{not json`)); !reflect.DeepEqual(*broken, defaults) {
+		t.Errorf("unreadable settings must give the defaults, got %+v", broken)
+	}
+}
+
+func TestNormalizeTenantSettings(t *testing.T) {
+	settings := models.TenantSettings{
+		DefaultLinkTTL:        5,
+		WebhookRetryCount:     100,
+		WebhookTimeoutMs:      500000,
+		APIRateLimitPerMin:    -1,
+		Timezone:              "Mars/Olympus_Mons",
+		InvoiceReminderDays:   []int{7, -3, 0, 7, -100, 365},
+		InvoicePauseAfterDays: -5,
+	}
+	services.NormalizeTenantSettings(&settings)
+
+	if settings.DefaultLinkTTL != services.MinLinkTTLSeconds {
+		t.Errorf("link TTL = %d; want the minimum", settings.DefaultLinkTTL)
+	}
+	if settings.WebhookRetryCount != services.MaxWebhookRetryCount || settings.WebhookTimeoutMs != services.MaxWebhookTimeoutMs {
+		t.Errorf("webhook settings must be capped, got %d/%d", settings.WebhookRetryCount, settings.WebhookTimeoutMs)
+	}
+	if settings.APIRateLimitPerMin != 60 || settings.Timezone != "UTC" {
+		t.Errorf("invalid values must fall back to defaults, got %d/%q", settings.APIRateLimitPerMin, settings.Timezone)
+	}
+	if !reflect.DeepEqual(settings.InvoiceReminderDays, []int{-3, 0, 7}) || settings.InvoicePauseAfterDays != 0 {
+		t.Errorf("reminder days must be sorted, unique and in range, got %v (pause %d)", settings.InvoiceReminderDays, settings.InvoicePauseAfterDays)
+	}
+}
+
+func TestValidateTenantSettings(t *testing.T) {
+	valid := models.DefaultTenantSettings()
+	valid.Timezone = "Asia/Kuwait"
+	if err := services.ValidateTenantSettings(&valid); err != nil {
+		t.Fatalf("valid settings rejected: %v", err)
+	}
+
+	for name, mutate := range map[string]func(*models.TenantSettings){
+		"unknown timezone":    func(s *models.TenantSettings) { s.Timezone = "Nowhere/City" },
+		"negative TTL":        func(s *models.TenantSettings) { s.DefaultLinkTTL = -1 },
+		"too many retries":    func(s *models.TenantSettings) { s.WebhookRetryCount = services.MaxWebhookRetryCount + 1 },
+		"timeout too long":    func(s *models.TenantSettings) { s.WebhookTimeoutMs = services.MaxWebhookTimeoutMs + 1 },
+		"rate limit too high": func(s *models.TenantSettings) { s.APIRateLimitPerMin = services.MaxAPIRateLimitPerMin + 1 },
+		"bad tax country":     func(s *models.TenantSettings) { s.TaxProfileCountries = []string{"USA"} },
+		"too many referral levels": func(s *models.TenantSettings) {
+			s.ReferralOverrideBps = make([]int64, services.MaxReferralLevels+1)
+		},
+		"negative override rate":  func(s *models.TenantSettings) { s.TeamOverrideBps = -1 },
+		"override cap above 100%": func(s *models.TenantSettings) { s.OverrideCapBps = services.MaxFeeBps + 1 },
+		"reminder too early": func(s *models.TenantSettings) {
+			s.InvoiceReminderDays = []int{-services.MaxInvoiceReminderLeadDays - 1}
+		},
+		"negative pause days": func(s *models.TenantSettings) { s.InvoicePauseAfterDays = -1 },
+	} {
+		settings := models.DefaultTenantSettings()
+		mutate(&settings)
+		if err := services.ValidateTenantSettings(&settings); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestWebhookSettingsPerTenant(t *testing.T) {
+	settings := models.DefaultTenantSettings()
+	settings.WebhookTimeoutMs = 5000
+	settings.WebhookRetryCount = 2
+
+	cases := []struct{ step, want int }{
+		{0, 5000},     // step without a timeout uses the tenant's
+		{2000, 2000},  // shorter step timeouts are kept
+		{10000, 5000}, // longer ones are capped
+	}
+	for _, tc := range cases {
+		if got := services.WebhookStepTimeoutMs(tc.step, &settings); got != tc.want {
+			t.Errorf("step timeout %d: got %d; want %d", tc.step, got, tc.want)
+		}
+	}
+
+	policy := services.WebhookRetryPolicy(&settings)
+	if !policy.ShouldRetry(1) || policy.ShouldRetry(2) {
+		t.Errorf("retry policy must allow %d attempts, got MaxAttempts=%d", settings.WebhookRetryCount, policy.MaxAttempts)
+	}
+}
+
+func TestSignedLinkUsesTenantTTL(t *testing.T) {
+	t.Setenv("LINK_SIGNING_SECRET", "tenant-ttl-test-secret")
+	service := services.NewLinkSigningService()
+
+	// Signed two hours ago
+	timestamp := time.Now().Add(-2 * time.Hour).Unix()
+	sign := func(nonce string) string {
+		mac := hmac.New(sha256.New, []byte("tenant-ttl-test-secret"))
+		mac.Write([]byte(fmt.Sprintf("abc-123.%d.%s", timestamp, nonce)))
+		return fmt.Sprintf("abc-123.%d.%s.%s", timestamp, nonce, hex.EncodeToString(mac.Sum(nil))[:16])
+	}
+
+	var asked string
+	result := service.ValidateSignedLinkTTL(sign("nonce00001"), func(code string) int64 {
+		asked = code
+		return 3600
+	})
+	if result.Valid || result.Reason != "link_expired" {
+		t.Fatalf("a one-hour TTL must expire the link, got %+v", result)
+	}
+	if asked != "abc-123" {
+		t.Errorf("TTL looked up for %q; want the tracking code", asked)
+	}
+
+	result = service.ValidateSignedLinkTTL(sign("nonce00002"), func(string) int64 { return 86400 })
+	if !result.Valid {
+		t.Fatalf("a one-day TTL must accept the link, got %+v", result)
+	}
+
+	// Tampered links never reach the tenant lookup
+	asked = ""
+	service.ValidateSignedLinkTTL("abc-123.1700000000.nonce00003.0000000000000000", func(code string) int64 {
+		asked = code
+		return 86400
+	})
+	if asked != "" {
+		t.Error("TTL must only be looked up for correctly signed links")
+	}
+}
+
+func TestTenantDayBoundaries(t *testing.T) {
+	kuwait := services.TenantLocation("Asia/Kuwait") // UTC+3
+	if services.TenantLocation("not/a_zone") != time.UTC || services.TenantLocation("") != time.UTC {
+		t.Error("unknown timezones must fall back to UTC")
+	}
+
+	// 22:30 UTC on 1 March is already 2 March in Kuwait
+	at := time.Date(2026, 3, 1, 22, 30, 0, 0, time.UTC)
+	if day := services.DayIn(at, kuwait); !day.Equal(time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("DayIn = %v; want 2026-03-02", day)
+	}
+	if day := services.DayIn(at, time.UTC); !day.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("DayIn UTC = %v; want 2026-03-01", day)
+	}
+
+	start := services.StartOfDayIn(at, kuwait)
+	if want := time.Date(2026, 3, 1, 21, 0, 0, 0, time.UTC); !start.Equal(want) {
+		t.Errorf("StartOfDayIn = %v; want %v", start.UTC(), want)
+	}
+
+	// The billing month of 1 April 01:00 Kuwait time is April, which started at 21:00 UTC on 31 March
+	month := services.StartOfMonthIn(time.Date(2026, 3, 31, 22, 0, 0, 0, time.UTC), kuwait)
+	if want := time.Date(2026, 3, 31, 21, 0, 0, 0, time.UTC); !month.Equal(want) {
+		t.Errorf("StartOfMonthIn = %v; want %v", month.UTC(), want)
+	}
+}
@@ -0,0 +1,245 @@
+package tests
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/aljapah/afftok-backend-prod/internal/testutil/mockidp"
+	"gorm.io/datatypes"
+)
+
+// ============================================
+// TENANT SSO
+// ============================================
+
+func startMockIdP(t *testing.T) (*mockidp.IdP, *httptest.Server) {
+	t.Helper()
+	idp, err := mockidp.New("afftok-test", "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(idp)
+	t.Cleanup(server.Close)
+	idp.Issuer = server.URL
+	return idp, server
+}
+
+func TestOIDCLoginAgainstMockIdP(t *testing.T) {
+	idp, server := startMockIdP(t)
+	idp.User.Groups = []string{"Engineering", "AffTok-Admins"}
+
+	cfg := &models.TenantSSOConfig{Issuer: idp.Issuer, ClientID: "afftok-test", ClientSecret: "s3cret"}
+	client, err := services.NewOIDCClient(cfg, "https://api.example.com/api/auth/sso/acme/oidc/callback", server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	verifier := services.NewPKCEVerifier()
+	authURL, err := client.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Follow the IdP redirect without going to the callback host
+	noFollow := *server.Client()
+	noFollow.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := noFollow.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || callback.Query().Get("state") != "state-1" {
+		t.Fatalf("unexpected callback %q", resp.Header.Get("Location"))
+	}
+
+	if _, err := client.Exchange(ctx, callback.Query().Get("code"), services.NewPKCEVerifier()); err == nil {
+		t.Fatal("exchange with the wrong PKCE verifier must fail")
+	}
+
+	resp, _ = noFollow.Get(authURL)
+	resp.Body.Close()
+	callback, _ = url.Parse(resp.Header.Get("Location"))
+	idToken, err := client.Exchange(ctx, callback.Query().Get("code"), verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.VerifyIDToken(ctx, idToken, "other-nonce"); !errors.Is(err, services.ErrOIDCTokenInvalid) {
+		t.Fatalf("nonce mismatch must be rejected, got %v", err)
+	}
+	claims, err := client.VerifyIDToken(ctx, idToken, "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	identity := services.OIDCIdentity(claims, "groups")
+	if identity.Email != "jane@example.com" || identity.Subject != "mock-user-1" || len(identity.Groups) != 2 {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+
+	// A token for another client is rejected
+	foreign, _ := idp.IDToken("nonce-1", "someone-else")
+	if _, err := client.VerifyIDToken(ctx, foreign, "nonce-1"); err == nil {
+		t.Fatal("token with the wrong audience must be rejected")
+	}
+}
+
+func TestOIDCRejectsUnverifiedEmail(t *testing.T) {
+	idp, server := startMockIdP(t)
+	idp.EmailVerified = false
+
+	cfg := &models.TenantSSOConfig{Issuer: idp.Issuer, ClientID: "afftok-test"}
+	client, _ := services.NewOIDCClient(cfg, "https://api.example.com/cb", server.Client())
+	token, _ := idp.IDToken("n", "afftok-test")
+	if _, err := client.VerifyIDToken(context.Background(), token, "n"); err == nil {
+		t.Fatal("email_verified=false must be rejected")
+	}
+}
+
+func newTestSAMLProvider(t *testing.T, idp *mockidp.IdP) *services.SAMLServiceProvider {
+	t.Helper()
+	cfg := &models.TenantSSOConfig{
+		IdPEntityID:    idp.Issuer,
+		IdPSSOURL:      idp.Issuer + "/saml/sso",
+		IdPCertificate: idp.CertificatePEM(),
+		GroupsClaim:    "groups",
+	}
+	sp, err := services.NewSAMLServiceProvider(cfg, "https://api.example.com/api/auth/sso/acme/saml/metadata",
+		"https://api.example.com/api/auth/sso/acme/saml/acs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sp
+}
+
+func TestSAMLSignedResponse(t *testing.T) {
+	idp, _ := startMockIdP(t)
+	idp.User.Groups = []string{"marketing"}
+	sp := newTestSAMLProvider(t, idp)
+
+	for _, signResponse := range []bool{false, true} {
+		idp.SignResponse = signResponse
+		response, err := idp.SAMLResponse(sp.ACSURL, sp.EntityID, "_req1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertion, err := sp.ParseResponse(response, "_req1")
+		if err != nil {
+			t.Fatalf("signResponse=%v: %v", signResponse, err)
+		}
+		if assertion.Identity.Email != "jane@example.com" || assertion.Identity.Name != "Jane Doe" ||
+			len(assertion.Identity.Groups) != 1 || assertion.ID == "" {
+			t.Fatalf("unexpected assertion %+v", assertion)
+		}
+
+		if _, err := sp.ParseResponse(response, "_other"); err == nil {
+			t.Fatal("response to another request must be rejected")
+		}
+	}
+}
+
+func TestSAMLRejectsTamperedResponse(t *testing.T) {
+	idp, _ := startMockIdP(t)
+	sp := newTestSAMLProvider(t, idp)
+
+	response, _ := idp.SAMLResponse(sp.ACSURL, sp.EntityID, "_req1")
+	raw, _ := base64.StdEncoding.DecodeString(response)
+	tampered := strings.Replace(string(raw), "jane@example.com", "admin@example.com", -1)
+
+	_, err := sp.ParseResponse(base64.StdEncoding.EncodeToString([]byte(tampered)), "_req1")
+	if !errors.Is(err, services.ErrSAMLResponseInvalid) {
+		t.Fatalf("tampered response must be rejected, got %v", err)
+	}
+
+	// Signed by another IdP
+	other, _ := mockidp.New("x", "y")
+	other.Issuer = idp.Issuer
+	forged, _ := other.SAMLResponse(sp.ACSURL, sp.EntityID, "_req1")
+	if _, err := sp.ParseResponse(forged, "_req1"); err == nil {
+		t.Fatal("response signed with an unknown key must be rejected")
+	}
+}
+
+func TestSAMLRejectsWrongAudience(t *testing.T) {
+	idp, _ := startMockIdP(t)
+	sp := newTestSAMLProvider(t, idp)
+
+	response, _ := idp.SAMLResponse(sp.ACSURL, "https://other-sp.example.com", "_req1")
+	if _, err := sp.ParseResponse(response, "_req1"); err == nil || !strings.Contains(err.Error(), "audience") {
+		t.Fatalf("wrong audience must be rejected, got %v", err)
+	}
+}
+
+func TestSAMLAuthnRequestRoundTrip(t *testing.T) {
+	idp, server := startMockIdP(t)
+	sp := newTestSAMLProvider(t, idp)
+
+	redirect, requestID, err := sp.AuthnRequestURL("relay-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := server.Client().Get(redirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("mock IdP rejected the AuthnRequest: %d", resp.StatusCode)
+	}
+	if requestID == "" || !strings.HasPrefix(requestID, "_") {
+		t.Fatalf("invalid request ID %q", requestID)
+	}
+}
+
+func TestMapSSORole(t *testing.T) {
+	mappings := map[string]string{"AffTok-Admins": "admin", "marketing": "advertiser", "partners": "promoter"}
+
+	cases := []struct {
+		groups  []string
+		role    string
+		matched bool
+	}{
+		{[]string{"partners"}, "promoter", true},
+		{[]string{"partners", "Marketing"}, "advertiser", true},
+		{[]string{"marketing", "afftok-admins"}, "admin", true},
+		{[]string{"engineering"}, "advertiser", false},
+		{nil, "advertiser", false},
+	}
+	for _, tc := range cases {
+		role, matched := services.MapSSORole(mappings, tc.groups, "advertiser")
+		if role != tc.role || matched != tc.matched {
+			t.Errorf("groups %v: got %s/%v; want %s/%v", tc.groups, role, matched, tc.role, tc.matched)
+		}
+	}
+}
+
+func TestSSOEnforcedFor(t *testing.T) {
+	cfg := &models.TenantSSOConfig{Enabled: true, Enforced: true}
+	if !services.SSOEnforcedFor(cfg, "admin") || !services.SSOEnforcedFor(cfg, "advertiser") {
+		t.Error("admins and advertisers must use SSO by default")
+	}
+	if services.SSOEnforcedFor(cfg, "promoter") {
+		t.Error("promoters are not enforced by default")
+	}
+
+	cfg.EnforcedRoles = datatypes.JSON(`["promoter"]`)
+	if !services.SSOEnforcedFor(cfg, "promoter") || services.SSOEnforcedFor(cfg, "admin") {
+		t.Error("configured enforced roles must replace the default")
+	}
+
+	cfg.Enforced = false
+	if services.SSOEnforcedFor(cfg, "promoter") {
+		t.Error("nothing is enforced when enforcement is off")
+	}
+}
@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aljapah/afftok-backend-prod/internal/handlers"
+	"github.com/aljapah/afftok-backend-prod/internal/middleware"
+	"github.com/aljapah/afftok-backend-prod/internal/models"
+	"github.com/aljapah/afftok-backend-prod/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ============================================
+// PLAN LIMITS
+// ============================================
+
+func TestEvaluateLimitSoftAndHard(t *testing.T) {
+	cases := []struct {
+		name    string
+		used    int64
+		limit   int64
+		grace   int
+		status  models.LimitStatus
+		allowed bool
+	}{
+		{"under limit", 5, 10, 10, models.LimitStatusOK, true},
+		{"reaches soft limit", 9, 10, 10, models.LimitStatusOK, true},
+		{"inside grace", 10, 10, 10, models.LimitStatusWarning, true},
+		{"past grace", 11, 10, 10, models.LimitStatusExceeded, false},
+		{"no grace", 10, 10, 0, models.LimitStatusExceeded, false},
+		{"zero limit", 0, 0, 25, models.LimitStatusExceeded, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			check := models.EvaluateLimit(models.LimitResourceOffers, tc.used, 1, tc.limit, tc.grace)
+			if check.Status != tc.status || check.Allowed != tc.allowed {
+				t.Fatalf("got status=%s allowed=%v; want %s/%v (hard=%d)",
+					check.Status, check.Allowed, tc.status, tc.allowed, check.HardLimit)
+			}
+		})
+	}
+}
+
+func TestHardLimitRoundsGraceUp(t *testing.T) {
+	if got := models.HardLimitFor(5, 10); got != 6 {
+		t.Errorf("HardLimitFor(5, 10) = %d; want 6", got)
+	}
+	if got := models.HardLimitFor(100000, 10); got != 110000 {
+		t.Errorf("HardLimitFor(100000, 10) = %d; want 110000", got)
+	}
+}
+
+func TestUsageLimitErrorIsExceeded(t *testing.T) {
+	err := error(&services.UsageLimitError{
+		Check: models.EvaluateLimit(models.LimitResourceAPIKeys, 2, 1, 2, 0),
+	})
+	if !errors.Is(err, services.ErrUsageLimitExceeded) {
+		t.Fatalf("expected ErrUsageLimitExceeded, got %v", err)
+	}
+}
+
+func TestUsageDailyCounters(t *testing.T) {
+	var day models.TenantUsageDaily
+	day.Add(models.UsageMetricClicks, 3)
+	day.Add(models.UsageMetricClicks, 2)
+	day.Add(models.UsageMetricStorageBytes, 100)
+	day.Add(models.UsageMetricStorageBytes, 40)
+
+	if day.Get(models.UsageMetricClicks) != 5 {
+		t.Errorf("clicks = %d; want 5", day.Clicks)
+	}
+	if day.Get(models.UsageMetricStorageBytes) != 40 {
+		t.Errorf("storage is a snapshot, got %d; want 40", day.StorageBytes)
+	}
+}
+
+func TestCreateOfferStopsAtPlanLimit(t *testing.T) {
+	db, store := newMemDB(t)
+	tenantID := uuid.New()
+	store.insert("tenants", map[string]interface{}{
+		"id": tenantID.String(), "name": "Limited", "max_offers": 2, "limit_grace_percent": 0,
+	})
+	store.insert("offers", map[string]interface{}{"id": uuid.NewString(), "tenant_id": tenantID.String(), "title": "Existing"})
+	// Both requests count the offers before either inserts
+	store.delayOn[`FROM "offers"`] = 20 * time.Millisecond
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/offers", func(c *gin.Context) {
+		c.Set(middleware.TenantIDKey, tenantID)
+		c.Next()
+	}, handlers.NewOfferHandler(db).CreateOffer)
+	create := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/offers",
+			strings.NewReader(`{"title":"New","destination_url":"https://example.com"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	codes := make([]int, 2)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = create().Code
+		}(i)
+	}
+	wg.Wait()
+	delete(store.delayOn, `FROM "offers"`)
+
+	if n := len(store.table("offers")); n != 2 {
+		t.Fatalf("%d offers stored (responses %v); the limit is 2", n, codes)
+	}
+	if !(codes[0] == http.StatusCreated && codes[1] == http.StatusForbidden) &&
+		!(codes[0] == http.StatusForbidden && codes[1] == http.StatusCreated) {
+		t.Errorf("responses = %v; want one 201 and one 403", codes)
+	}
+
+	rec := create()
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusForbidden || body.Code != "PLAN_LIMIT_EXCEEDED" {
+		t.Errorf("over the limit = %d %s; want 403 PLAN_LIMIT_EXCEEDED", rec.Code, rec.Body)
+	}
+}
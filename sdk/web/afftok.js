@@ -18,6 +18,10 @@
     CONVERSION_ENDPOINT: '/api/sdk/conversion',
     FALLBACK_CLICK_ENDPOINT: '/api/c',
     FALLBACK_CONVERSION_ENDPOINT: '/api/convert',
+    BEACON_ENDPOINT: '/api/beacon',
+    BEACON_TOKEN_PARAM: 'afftok_vt',
+    BEACON_TOKEN_KEY: 'afftok_visit_token',
+    BEACON_MIN_VISIBLE_MS: 1000,
     
     MAX_QUEUE_SIZE: 1000,
     MAX_RETRY_ATTEMPTS: 5,
@@ -89,6 +93,7 @@
       this.isProcessing = false;
       this.deviceId = null;
       this.fingerprint = null;
+      this.beacon = null;
     }
 
     /**
@@ -107,6 +112,7 @@
         autoFlush: true,
         autoTrack: false,
         flushInterval: Config.FLUSH_INTERVAL_MS,
+        landingBeacon: false,
         ...options,
       };
 
@@ -121,6 +127,10 @@
         this._setupAutoTrack();
       }
 
+      if (this.options.landingBeacon) {
+        this._setupLandingBeacon();
+      }
+
       // Listen for online/offline events
       window.addEventListener('online', () => this.flush());
       
@@ -258,6 +268,15 @@
       this._setupAutoTrack();
     }
 
+    /**
+     * Enable the landing page beacon (confirms a real, visible page view
+     * for clicks redirected with a visit token)
+     */
+    enableLandingBeacon() {
+      this._ensureInitialized();
+      this._setupLandingBeacon();
+    }
+
     /**
      * Manually enqueue an event
      * @param {string} type - Event type
@@ -676,6 +695,134 @@
       this._log('Auto-track enabled for [data-afftok-offer] links');
     }
 
+    _setupLandingBeacon() {
+      if (this.beacon) {
+        return;
+      }
+
+      const token = this._readVisitToken();
+      if (!token) {
+        this._log('Landing beacon: no visit token on this page');
+        return;
+      }
+
+      const now = Date.now();
+      this.beacon = {
+        token,
+        startedAt: now,
+        visibleSince: document.visibilityState === 'visible' ? now : null,
+        visibleMs: 0,
+        viewSent: false,
+        leaveSent: false,
+        viewTimer: null,
+      };
+
+      const scheduleView = () => {
+        if (this.beacon.viewSent || this.beacon.viewTimer) return;
+        this.beacon.viewTimer = setTimeout(() => {
+          this.beacon.viewTimer = null;
+          if (document.visibilityState === 'visible') {
+            this._sendBeacon('view');
+          }
+        }, Config.BEACON_MIN_VISIBLE_MS);
+      };
+
+      document.addEventListener('visibilitychange', () => {
+        const b = this.beacon;
+        if (document.visibilityState === 'visible') {
+          b.visibleSince = Date.now();
+          scheduleView();
+        } else {
+          if (b.visibleSince) {
+            b.visibleMs += Date.now() - b.visibleSince;
+            b.visibleSince = null;
+          }
+          if (b.viewTimer) {
+            clearTimeout(b.viewTimer);
+            b.viewTimer = null;
+          }
+        }
+      });
+
+      window.addEventListener('pagehide', () => this._sendBeacon('leave'));
+
+      if (document.visibilityState === 'visible') {
+        scheduleView();
+      }
+
+      this._log('Landing beacon enabled');
+    }
+
+    _readVisitToken() {
+      let token = null;
+      try {
+        const params = new URLSearchParams(window.location.search);
+        token = params.get(Config.BEACON_TOKEN_PARAM);
+        if (token) {
+          sessionStorage.setItem(Config.BEACON_TOKEN_KEY, token);
+        } else {
+          token = sessionStorage.getItem(Config.BEACON_TOKEN_KEY);
+        }
+      } catch (error) {
+        this._log(`Error reading visit token: ${error.message}`);
+      }
+      return token;
+    }
+
+    _sendBeacon(event) {
+      const b = this.beacon;
+      if (!b || (event === 'view' && b.viewSent) || (event === 'leave' && b.leaveSent)) {
+        return;
+      }
+
+      const now = Date.now();
+      let visibleMs = b.visibleMs;
+      if (b.visibleSince) {
+        visibleMs += now - b.visibleSince;
+      }
+
+      let inIframe = false;
+      try {
+        inIframe = window.self !== window.top;
+      } catch (error) {
+        inIframe = true; // cross-origin parent
+      }
+
+      const payload = {
+        token: b.token,
+        event,
+        page_url: window.location.href,
+        referrer: document.referrer,
+        visible: document.visibilityState === 'visible' || visibleMs > 0,
+        visible_ms: visibleMs,
+        time_on_page_ms: now - b.startedAt,
+        viewport_w: window.innerWidth || 0,
+        viewport_h: window.innerHeight || 0,
+        in_iframe: inIframe,
+      };
+
+      if (event === 'view') b.viewSent = true;
+      if (event === 'leave') b.leaveSent = true;
+
+      // text/plain keeps sendBeacon free of CORS preflight
+      const url = `${this.options.baseUrl}${Config.BEACON_ENDPOINT}`;
+      const body = JSON.stringify(payload);
+      let sent = false;
+      if (navigator.sendBeacon) {
+        sent = navigator.sendBeacon(url, new Blob([body], { type: 'text/plain' }));
+      }
+      if (!sent) {
+        fetch(url, {
+          method: 'POST',
+          headers: { 'Content-Type': 'text/plain' },
+          body,
+          keepalive: true,
+        }).catch(() => {});
+      }
+
+      this._log(`Landing beacon sent: ${event} (visible ${visibleMs}ms)`);
+    }
+
     _log(message) {
       if (this.options?.debug) {
         console.log(`[AffTok SDK] ${message}`);